@@ -0,0 +1,539 @@
+package accounts
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud-ai-api/atrest"
+)
+
+// KeyPrefix starts every issued API key, so the gateway can tell them from
+// keys it does not manage
+const KeyPrefix = "cak_"
+
+// tokenPrefix starts account tokens, which manage an account's keys
+const tokenPrefix = "cat_"
+
+// maxEvents caps the audit events kept per account
+const maxEvents = 100
+
+var (
+	// ErrExists is returned when registering an already verified email
+	ErrExists = errors.New("an account with this email already exists")
+	// ErrInvalidToken is returned for unknown or expired verification tokens
+	ErrInvalidToken = errors.New("invalid or expired verification token")
+	// ErrNotFound is returned for unknown accounts and keys
+	ErrNotFound = errors.New("not found")
+	// ErrTooManyKeys is returned when an account has all the keys it may hold
+	ErrTooManyKeys = errors.New("key limit reached; revoke a key first")
+	// ErrRotated is returned when rotating a key that is already being
+	// replaced
+	ErrRotated = errors.New("key has already been rotated")
+)
+
+// Audit event actions
+const (
+	KeyCreated = "key_created"
+	KeyRotated = "key_rotated"
+	KeyRevoked = "key_revoked"
+)
+
+// Event is an audited change to an account's keys
+type Event struct {
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"`
+	KeyID    string    `json:"key_id"`
+	NewKeyID string    `json:"new_key_id,omitempty"`
+	// ClientIP is the address the change was requested from
+	ClientIP string `json:"client_ip,omitempty"`
+}
+
+// Quota limits the requests an issued key may make per UTC day. Zero means
+// unlimited.
+type Quota struct {
+	DailyRequests int `json:"daily_requests"`
+}
+
+// Key is an API key issued to an account. Only its hash is stored; Prefix
+// identifies it to its owner. A rotated key stays valid until ExpiresAt
+// alongside the key that replaces it, and the two share a daily quota.
+type Key struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name,omitempty"`
+	Prefix      string     `json:"prefix"`
+	Hash        string     `json:"hash,omitempty"`
+	Quota       Quota      `json:"quota"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	RotatedFrom string     `json:"rotated_from,omitempty"`
+	RotatedTo   string     `json:"rotated_to,omitempty"`
+	// Series is the ID of the first key in a chain of rotations
+	Series string `json:"series,omitempty"`
+}
+
+// Active reports whether the key may be used at now
+func (k Key) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// series returns the key that counts towards the key's quota
+func (k Key) series() string {
+	if k.Series != "" {
+		return k.Series
+	}
+	return k.ID
+}
+
+// Account is a self-registered user. Its ID is the tenant its keys are
+// billed to.
+type Account struct {
+	ID         string     `json:"id"`
+	Email      string     `json:"email"`
+	Name       string     `json:"name,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+	Keys       []Key      `json:"keys"`
+	Events     []Event    `json:"events,omitempty"`
+
+	TokenHash     string     `json:"token_hash,omitempty"`
+	VerifyHash    string     `json:"verify_hash,omitempty"`
+	VerifyExpires *time.Time `json:"verify_expires,omitempty"`
+}
+
+// public returns a copy of the account without its secrets' hashes
+func (a *Account) public() Account {
+	out := *a
+	out.TokenHash, out.VerifyHash, out.VerifyExpires = "", "", nil
+	out.Keys = make([]Key, len(a.Keys))
+	for i, k := range a.Keys {
+		k.Hash = ""
+		out.Keys[i] = k
+	}
+	return out
+}
+
+// Options configures a store
+type Options struct {
+	// DefaultQuota is given to every new key
+	DefaultQuota Quota
+	// MaxKeys is how many unrevoked keys an account may hold
+	MaxKeys int
+	// VerifyTTL is how long a verification token is valid
+	VerifyTTL time.Duration
+	// RotationGrace is how long a rotated key stays valid
+	RotationGrace time.Duration
+	// Keys encrypts the accounts file; nil writes it in plaintext
+	Keys *atrest.Keyring
+}
+
+// Store holds accounts and their keys, writing every change to a JSON file
+// so they survive restarts. Daily request counts are kept in memory.
+type Store struct {
+	file string
+	opts Options
+
+	mu       sync.Mutex
+	accounts []*Account
+	byKey    map[string]*Account
+	used     map[string]int
+	day      string
+}
+
+// Open loads accounts from file, which need not exist yet
+func Open(file string, opts Options) (*Store, error) {
+	s := &Store{file: file, opts: opts, byKey: make(map[string]*Account), used: make(map[string]int)}
+
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err == nil {
+		data, err = opts.Keys.Open(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st struct {
+		Accounts []*Account `json:"accounts"`
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("invalid accounts file: %w", err)
+	}
+	s.accounts = st.Accounts
+	for _, a := range s.accounts {
+		for _, k := range a.Keys {
+			s.byKey[k.Hash] = a
+		}
+	}
+	return s, nil
+}
+
+// Register creates an unverified account for email and returns a token to
+// verify it with. Registering an unverified email again replaces its token.
+func (s *Store) Register(email, name string) (Account, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.findEmail(email)
+	if a != nil && a.VerifiedAt != nil {
+		return Account{}, "", ErrExists
+	}
+	created := a == nil
+	if created {
+		a = &Account{ID: "acct_" + randomHex(8), Email: email, CreatedAt: time.Now().UTC(), Keys: []Key{}}
+	}
+	if name != "" {
+		a.Name = name
+	}
+	token := randomHex(16)
+	expires := time.Now().UTC().Add(s.opts.VerifyTTL)
+	a.VerifyHash, a.VerifyExpires = hash(token), &expires
+
+	if created {
+		s.accounts = append(s.accounts, a)
+	}
+	if err := s.save(); err != nil {
+		return Account{}, "", err
+	}
+	return a.public(), token, nil
+}
+
+// Verify verifies the account a token was issued to and returns a new
+// account token, which authenticates key management
+func (s *Store) Verify(token string) (Account, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h := hash(token)
+	for _, a := range s.accounts {
+		if a.VerifyHash == "" || a.VerifyHash != h {
+			continue
+		}
+		if time.Now().After(*a.VerifyExpires) {
+			return Account{}, "", ErrInvalidToken
+		}
+		now := time.Now().UTC()
+		accountToken := tokenPrefix + randomHex(24)
+		a.VerifiedAt = &now
+		a.VerifyHash, a.VerifyExpires = "", nil
+		a.TokenHash = hash(accountToken)
+		if err := s.save(); err != nil {
+			return Account{}, "", err
+		}
+		return a.public(), accountToken, nil
+	}
+	return Account{}, "", ErrInvalidToken
+}
+
+// Pending returns the unverified account a verification token was issued
+// to, without using the token up
+func (s *Store) Pending(token string) (Account, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h := hash(token)
+	for _, a := range s.accounts {
+		if a.VerifyHash != "" && a.VerifyHash == h && time.Now().Before(*a.VerifyExpires) {
+			return a.public(), true
+		}
+	}
+	return Account{}, false
+}
+
+// Get returns an account by ID
+func (s *Store) Get(id string) (Account, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.find(id)
+	if a == nil {
+		return Account{}, false
+	}
+	return a.public(), true
+}
+
+// Authenticate returns the verified account an account token belongs to
+func (s *Store) Authenticate(accountToken string) (Account, bool) {
+	if !strings.HasPrefix(accountToken, tokenPrefix) {
+		return Account{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h := hash(accountToken)
+	for _, a := range s.accounts {
+		if a.TokenHash != "" && a.TokenHash == h {
+			return a.public(), true
+		}
+	}
+	return Account{}, false
+}
+
+// CreateKey issues a key with the default quota to an account, returning
+// the key and its secret, which is not stored. clientIP is audited.
+func (s *Store) CreateKey(accountID, name, clientIP string) (Key, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.find(accountID)
+	if a == nil || a.VerifiedAt == nil {
+		return Key{}, "", ErrNotFound
+	}
+	// Keys being rotated out do not count, so a full account can rotate
+	active := 0
+	for _, k := range a.Keys {
+		if k.RevokedAt == nil && k.ExpiresAt == nil {
+			active++
+		}
+	}
+	if active >= s.opts.MaxKeys {
+		return Key{}, "", ErrTooManyKeys
+	}
+
+	k, secret := newKey(name, s.opts.DefaultQuota)
+	prev := *a
+	a.Keys = append(a.Keys, k)
+	a.audit(Event{Time: k.CreatedAt, Action: KeyCreated, KeyID: k.ID, ClientIP: clientIP})
+	if err := s.save(); err != nil {
+		*a = prev
+		return Key{}, "", err
+	}
+	s.byKey[k.Hash] = a
+	k.Hash = ""
+	return k, secret, nil
+}
+
+// RotateKey replaces one of an account's keys with a new key of the same
+// name and quota. The old key stays valid for the rotation grace period so
+// clients can switch without downtime. It returns the new key and its
+// secret, and the old key.
+func (s *Store) RotateKey(accountID, keyID, clientIP string) (Key, string, Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.find(accountID)
+	if a == nil {
+		return Key{}, "", Key{}, ErrNotFound
+	}
+	i := a.keyIndex(keyID)
+	if i < 0 || a.Keys[i].RevokedAt != nil {
+		return Key{}, "", Key{}, ErrNotFound
+	}
+	if a.Keys[i].ExpiresAt != nil {
+		return Key{}, "", Key{}, ErrRotated
+	}
+
+	prev := *a
+	a.Keys = append([]Key{}, a.Keys...)
+	old := &a.Keys[i]
+	k, secret := newKey(old.Name, old.Quota)
+	k.RotatedFrom, k.Series = old.ID, old.series()
+	expires := k.CreatedAt.Add(s.opts.RotationGrace)
+	old.ExpiresAt, old.RotatedTo = &expires, k.ID
+	a.Keys = append(a.Keys, k)
+	a.audit(Event{Time: k.CreatedAt, Action: KeyRotated, KeyID: old.ID, NewKeyID: k.ID, ClientIP: clientIP})
+	if err := s.save(); err != nil {
+		*a = prev
+		return Key{}, "", Key{}, err
+	}
+	s.byKey[k.Hash] = a
+	previous := a.Keys[i]
+	k.Hash, previous.Hash = "", ""
+	return k, secret, previous, nil
+}
+
+// RevokeKey revokes one of an account's keys at once, ending any rotation
+// grace period. clientIP is audited.
+func (s *Store) RevokeKey(accountID, keyID, clientIP string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.find(accountID)
+	if a == nil {
+		return ErrNotFound
+	}
+	i := a.keyIndex(keyID)
+	if i < 0 || a.Keys[i].RevokedAt != nil {
+		return ErrNotFound
+	}
+	prev := *a
+	a.Keys = append([]Key{}, a.Keys...)
+	now := time.Now().UTC()
+	a.Keys[i].RevokedAt = &now
+	a.audit(Event{Time: now, Action: KeyRevoked, KeyID: keyID, ClientIP: clientIP})
+	if err := s.save(); err != nil {
+		*a = prev
+		return err
+	}
+	return nil
+}
+
+// Admission is the outcome of a request made with an issued key
+type Admission struct {
+	Account Account
+	Key     Key
+	// Allowed is false if the key is revoked, expired or over its daily
+	// quota
+	Allowed bool
+	// Remaining is how many requests the key may make today, or -1 if it
+	// has no quota
+	Remaining int
+}
+
+// Admit looks up an issued API key and counts a request against its daily
+// quota, reporting false if the key was not issued by the store
+func (s *Store) Admit(apiKey string, now time.Time) (Admission, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h := hash(apiKey)
+	a := s.byKey[h]
+	if a == nil {
+		return Admission{}, false
+	}
+	adm := Admission{Account: a.public()}
+	for _, k := range a.Keys {
+		if k.Hash == h {
+			k.Hash = ""
+			adm.Key = k
+		}
+	}
+	if !adm.Key.Active(now) {
+		return adm, true
+	}
+
+	if day := now.UTC().Format("2006-01-02"); day != s.day {
+		s.day = day
+		s.used = make(map[string]int)
+	}
+	limit := adm.Key.Quota.DailyRequests
+	if limit <= 0 {
+		adm.Allowed, adm.Remaining = true, -1
+		return adm, true
+	}
+	series := adm.Key.series()
+	if s.used[series] < limit {
+		s.used[series]++
+		adm.Allowed = true
+		adm.Remaining = limit - s.used[series]
+	}
+	return adm, true
+}
+
+// List returns every account, most recently created first
+func (s *Store) List() []Account {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]Account, 0, len(s.accounts))
+	for _, a := range s.accounts {
+		out = append(out, a.public())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out
+}
+
+// keyIndex returns the index of a key in a.Keys, or -1
+func (a *Account) keyIndex(id string) int {
+	for i, k := range a.Keys {
+		if k.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// audit records an event, dropping the oldest past maxEvents
+func (a *Account) audit(ev Event) {
+	events := append([]Event{}, a.Events...)
+	a.Events = append(events, ev)
+	if len(a.Events) > maxEvents {
+		a.Events = a.Events[len(a.Events)-maxEvents:]
+	}
+}
+
+func (s *Store) find(id string) *Account {
+	for _, a := range s.accounts {
+		if a.ID == id {
+			return a
+		}
+	}
+	return nil
+}
+
+func (s *Store) findEmail(email string) *Account {
+	for _, a := range s.accounts {
+		if strings.EqualFold(a.Email, email) {
+			return a
+		}
+	}
+	return nil
+}
+
+// save writes the accounts to the file atomically. The caller must hold
+// s.mu.
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(struct {
+		Accounts []*Account `json:"accounts"`
+	}{s.accounts}, "", "  ")
+	if err == nil {
+		data, err = s.opts.Keys.Seal(data)
+	}
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.file), ".accounts-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to save accounts: %w", err)
+	}
+	_, werr := tmp.Write(data)
+	cerr := tmp.Close()
+	if werr == nil {
+		werr = cerr
+	}
+	if werr == nil {
+		werr = os.Rename(tmp.Name(), s.file)
+	}
+	if werr != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save accounts: %w", werr)
+	}
+	return nil
+}
+
+// newKey creates a key and its secret
+func newKey(name string, quota Quota) (Key, string) {
+	secret := KeyPrefix + randomHex(24)
+	return Key{
+		ID:        "key_" + randomHex(6),
+		Name:      name,
+		Prefix:    secret[:len(KeyPrefix)+6],
+		Hash:      hash(secret),
+		Quota:     quota,
+		CreatedAt: time.Now().UTC(),
+	}, secret
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
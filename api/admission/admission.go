@@ -0,0 +1,195 @@
+package admission
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Priority is a request's priority class
+type Priority int
+
+// Priority classes, lowest first
+const (
+	Low Priority = iota
+	Normal
+	High
+)
+
+// priorities lists the classes, highest first
+var priorities = []Priority{High, Normal, Low}
+
+// ErrTimeout is returned when a request waited too long for a slot
+var ErrTimeout = errors.New("timed out waiting for a slot")
+
+// ParsePriority parses "high", "normal" or "low"
+func ParsePriority(s string) (Priority, bool) {
+	switch s {
+	case "high":
+		return High, true
+	case "normal":
+		return Normal, true
+	case "low":
+		return Low, true
+	}
+	return Normal, false
+}
+
+// String returns the class name
+func (p Priority) String() string {
+	switch p {
+	case High:
+		return "high"
+	case Low:
+		return "low"
+	}
+	return "normal"
+}
+
+// Gate admits up to a fixed number of concurrent holders. When it is full,
+// waiters are admitted highest priority first and, within a class, in
+// arrival order. A waiter queued longer than the starvation limit is
+// admitted next regardless of class, so low-priority traffic still makes
+// progress under sustained high-priority load.
+type Gate struct {
+	mu         sync.Mutex
+	capacity   int
+	active     int
+	starvation time.Duration
+	queues     map[Priority][]*waiter
+}
+
+type waiter struct {
+	priority Priority
+	since    time.Time
+	ready    chan struct{}
+	granted  bool
+}
+
+// Status reports a gate's current load
+type Status struct {
+	Capacity int            `json:"capacity"`
+	Active   int            `json:"active"`
+	Waiting  map[string]int `json:"waiting"`
+}
+
+// NewGate creates a gate with the given capacity and starvation limit
+func NewGate(capacity int, starvation time.Duration) *Gate {
+	return &Gate{
+		capacity:   capacity,
+		starvation: starvation,
+		queues:     make(map[Priority][]*waiter),
+	}
+}
+
+// Acquire waits for a slot until ctx is done. Every successful Acquire must
+// be followed by Release.
+func (g *Gate) Acquire(ctx context.Context, p Priority) error {
+	g.mu.Lock()
+	if g.active < g.capacity && g.waiting() == 0 {
+		g.active++
+		g.mu.Unlock()
+		return nil
+	}
+	w := &waiter{priority: p, since: time.Now(), ready: make(chan struct{})}
+	g.queues[p] = append(g.queues[p], w)
+	g.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	g.mu.Lock()
+	if w.granted {
+		// The slot was handed over as ctx ended; pass it on
+		g.mu.Unlock()
+		g.Release()
+	} else {
+		g.remove(w)
+		g.mu.Unlock()
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	return ctx.Err()
+}
+
+// Release frees a slot, handing it to the next waiter if there is one
+func (g *Gate) Release() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if w := g.next(); w != nil {
+		w.granted = true
+		close(w.ready)
+		return
+	}
+	g.active--
+}
+
+// Status reports the active holders and waiters per class
+func (g *Gate) Status() Status {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	waiting := make(map[string]int, len(priorities))
+	for _, p := range priorities {
+		waiting[p.String()] = len(g.queues[p])
+	}
+	return Status{Capacity: g.capacity, Active: g.active, Waiting: waiting}
+}
+
+// Waiting returns the number of queued waiters
+func (g *Gate) Waiting() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.waiting()
+}
+
+func (g *Gate) waiting() int {
+	n := 0
+	for _, q := range g.queues {
+		n += len(q)
+	}
+	return n
+}
+
+// next dequeues the waiter to admit: the oldest waiter if it has waited
+// past the starvation limit, otherwise the oldest of the highest class
+func (g *Gate) next() *waiter {
+	var oldest Priority
+	found := false
+	for _, p := range priorities {
+		q := g.queues[p]
+		if len(q) > 0 && (!found || q[0].since.Before(g.queues[oldest][0].since)) {
+			oldest, found = p, true
+		}
+	}
+	if !found {
+		return nil
+	}
+	pick := oldest
+	if time.Since(g.queues[oldest][0].since) < g.starvation {
+		for _, p := range priorities {
+			if len(g.queues[p]) > 0 {
+				pick = p
+				break
+			}
+		}
+	}
+	w := g.queues[pick][0]
+	g.queues[pick] = g.queues[pick][1:]
+	return w
+}
+
+// remove drops a waiter that gave up
+func (g *Gate) remove(w *waiter) {
+	q := g.queues[w.priority]
+	for i, other := range q {
+		if other == w {
+			g.queues[w.priority] = append(q[:i:i], q[i+1:]...)
+			return
+		}
+	}
+}
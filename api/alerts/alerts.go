@@ -0,0 +1,266 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Alert statuses
+const (
+	StatusOK      = "ok"
+	StatusNoData  = "no_data"
+	StatusPending = "pending"
+	StatusFiring  = "firing"
+)
+
+// Severities
+const (
+	Warning  = "warning"
+	Critical = "critical"
+)
+
+// Rule raises an alert while Metric compared with Threshold by Op holds.
+// The metric is measured over Window (e.g. "5m") and the condition must
+// hold for For (e.g. "2m", default immediately) before the alert fires.
+// Route or Model narrow the metric to one route pattern (e.g.
+// "POST /api/v1/predict/:model") or ML model; MinRequests is how many
+// requests the window needs before the rule is evaluated at all.
+type Rule struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Metric      string  `json:"metric"`
+	Route       string  `json:"route,omitempty"`
+	Model       string  `json:"model,omitempty"`
+	Op          string  `json:"op"`
+	Threshold   float64 `json:"threshold"`
+	Window      string  `json:"window,omitempty"`
+	For         string  `json:"for,omitempty"`
+	MinRequests int64   `json:"min_requests,omitempty"`
+	Severity    string  `json:"severity,omitempty"`
+
+	window  time.Duration
+	pending time.Duration
+}
+
+// Condition describes the rule, e.g. "ml_p99_ms > 3000 over 5m0s"
+func (r *Rule) Condition() string {
+	subject := r.Metric
+	if r.Route != "" {
+		subject += "{route=" + r.Route + "}"
+	}
+	if r.Model != "" {
+		subject += "{model=" + r.Model + "}"
+	}
+	return fmt.Sprintf("%s %s %s over %s", subject, r.Op, strconv.FormatFloat(r.Threshold, 'g', -1, 64), r.window)
+}
+
+// WindowDuration is the period the rule's metric is measured over
+func (r *Rule) WindowDuration() time.Duration {
+	return r.window
+}
+
+// Source measures a rule's metric over its window. ok is false when there
+// is too little traffic to judge.
+type Source func(r *Rule) (value float64, ok bool)
+
+// State is a rule's latest evaluation. Since is when it entered its
+// current status.
+type State struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Severity    string     `json:"severity"`
+	Condition   string     `json:"condition"`
+	For         string     `json:"for"`
+	Status      string     `json:"status"`
+	Value       *float64   `json:"value,omitempty"`
+	Since       *time.Time `json:"since,omitempty"`
+	Evaluated   *time.Time `json:"evaluated_at,omitempty"`
+}
+
+// Engine evaluates rules against a source and tracks their states
+type Engine struct {
+	rules  []*Rule
+	source Source
+
+	mu     sync.Mutex
+	states []State
+}
+
+// Load reads rules from a JSON file of the form {"rules": [...]}
+func Load(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alert rules file: %w", err)
+	}
+	var f struct {
+		Rules []Rule `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse alert rules file: %w", err)
+	}
+	return f.Rules, nil
+}
+
+// NewEngine checks the rules and creates an engine for them. metrics
+// lists the metric names source can measure; a rule without a window uses
+// maxWindow, which no window may exceed.
+func NewEngine(rules []Rule, metrics []string, maxWindow time.Duration, source Source) (*Engine, error) {
+	known := make(map[string]bool, len(metrics))
+	for _, m := range metrics {
+		known[m] = true
+	}
+
+	e := &Engine{source: source}
+	seen := make(map[string]bool)
+	for i := range rules {
+		r := rules[i]
+		if r.Name == "" {
+			return nil, fmt.Errorf("rule %d: name is required", i+1)
+		}
+		if seen[r.Name] {
+			return nil, fmt.Errorf("rule %q declared more than once", r.Name)
+		}
+		seen[r.Name] = true
+		if !known[r.Metric] {
+			return nil, fmt.Errorf("rule %q: unknown metric %q", r.Name, r.Metric)
+		}
+		if r.Op != ">" && r.Op != ">=" && r.Op != "<" && r.Op != "<=" {
+			return nil, fmt.Errorf("rule %q: op must be >, >=, < or <=", r.Name)
+		}
+		if r.MinRequests < 0 {
+			return nil, fmt.Errorf("rule %q: min_requests must not be negative", r.Name)
+		}
+		if r.Severity == "" {
+			r.Severity = Warning
+		}
+		if r.Severity != Warning && r.Severity != Critical {
+			return nil, fmt.Errorf("rule %q: severity must be warning or critical", r.Name)
+		}
+
+		r.window = maxWindow
+		if r.Window != "" {
+			d, err := time.ParseDuration(r.Window)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("rule %q: window must be a positive duration such as 5m", r.Name)
+			}
+			if d > maxWindow {
+				return nil, fmt.Errorf("rule %q: window must not exceed the statistics window %s", r.Name, maxWindow)
+			}
+			r.window = d
+		}
+		if r.For != "" {
+			d, err := time.ParseDuration(r.For)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("rule %q: for must be a duration such as 2m", r.Name)
+			}
+			r.pending = d
+		}
+
+		e.rules = append(e.rules, &r)
+		e.states = append(e.states, State{
+			Name:        r.Name,
+			Description: r.Description,
+			Severity:    r.Severity,
+			Condition:   r.Condition(),
+			For:         r.pending.String(),
+			Status:      StatusNoData,
+		})
+	}
+	return e, nil
+}
+
+// Transition is a rule starting or stopping firing
+type Transition struct {
+	Rule   *Rule
+	State  State
+	Firing bool
+}
+
+// Evaluate measures every rule and returns the rules that started or
+// stopped firing. A rule without enough data stops firing.
+func (e *Engine) Evaluate() []Transition {
+	now := time.Now()
+	values := make([]*float64, len(e.rules))
+	for i, r := range e.rules {
+		if v, ok := e.source(r); ok {
+			values[i] = &v
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var transitions []Transition
+	for i, r := range e.rules {
+		st := &e.states[i]
+		wasFiring := st.Status == StatusFiring
+		status := StatusOK
+		switch {
+		case values[i] == nil:
+			status = StatusNoData
+		case breached(r.Op, *values[i], r.Threshold):
+			status = StatusPending
+			if wasFiring {
+				status = StatusFiring
+			}
+		}
+
+		if status != st.Status {
+			st.Since = &now
+		}
+		if status == StatusPending && now.Sub(*st.Since) >= r.pending {
+			status = StatusFiring
+			st.Since = &now
+		}
+		st.Status = status
+		st.Value = values[i]
+		st.Evaluated = &now
+
+		if firing := status == StatusFiring; firing != wasFiring {
+			transitions = append(transitions, Transition{Rule: r, State: *st, Firing: firing})
+		}
+	}
+	return transitions
+}
+
+// Watch evaluates the rules every interval until ctx is done, calling
+// onChange for each rule that starts or stops firing
+func (e *Engine) Watch(ctx context.Context, interval time.Duration, onChange func(Transition)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, t := range e.Evaluate() {
+			onChange(t)
+		}
+	}
+}
+
+// States returns every rule's latest evaluation in declaration order
+func (e *Engine) States() []State {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]State(nil), e.states...)
+}
+
+func breached(op string, value, threshold float64) bool {
+	switch op {
+	case ">":
+		return value > threshold
+	case ">=":
+		return value >= threshold
+	case "<":
+		return value < threshold
+	default:
+		return value <= threshold
+	}
+}
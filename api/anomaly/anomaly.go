@@ -0,0 +1,308 @@
+package anomaly
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// bucketsPerWindow is how many time buckets the traffic window is split into
+const bucketsPerWindow = 12
+
+// outlierZ is how many standard deviations from the recent mean a numeric
+// input must be to count as an outlier
+const outlierZ = 4
+
+// Signal kinds
+const (
+	NewValue = "new_value"
+	Outlier  = "outlier"
+	Burst    = "burst"
+)
+
+// Options controls what counts as unusual. Inputs are compared with the
+// successful predictions of the last Window once it holds MinSamples of
+// them; more than BurstLimit identical payloads within BurstWindow is a
+// burst.
+type Options struct {
+	Window      time.Duration
+	MinSamples  int
+	BurstWindow time.Duration
+	BurstLimit  int
+}
+
+// Signal is one reason an input looks anomalous, scored from 0 to 1. Field
+// is empty for signals about the payload as a whole.
+type Signal struct {
+	Kind    string
+	Field   string
+	Score   float64
+	Message string
+}
+
+// Result is an input's anomaly score, from 0 (ordinary) to 1, and the
+// signals that contributed to it
+type Result struct {
+	Score   float64
+	Signals []Signal
+}
+
+// Detector scores prediction inputs against recent traffic per model
+type Detector struct {
+	opts   Options
+	bucket time.Duration
+
+	mu     sync.Mutex
+	models map[string][]*bucket
+	bursts map[string]*burst
+	swept  time.Time
+}
+
+// bucket aggregates the inputs seen in one time slice
+type bucket struct {
+	start   time.Time
+	count   int
+	values  map[string]map[string]int
+	numbers map[string]*moments
+}
+
+// moments accumulates a numeric field's count, sum and sum of squares
+type moments struct {
+	n     int
+	sum   float64
+	sumSq float64
+}
+
+// burst counts identical payloads within one burst window
+type burst struct {
+	start time.Time
+	n     int
+}
+
+// NewDetector creates a detector with the given options
+func NewDetector(opts Options) *Detector {
+	bucketSize := opts.Window / bucketsPerWindow
+	if bucketSize < time.Second {
+		bucketSize = time.Second
+	}
+	return &Detector{
+		opts:   opts,
+		bucket: bucketSize,
+		models: make(map[string][]*bucket),
+		bursts: make(map[string]*burst),
+	}
+}
+
+// Score rates a payload against model's recent traffic. fingerprint
+// identifies identical payloads; every call counts towards its burst, so
+// rejected repeats keep counting.
+func (d *Detector) Score(model, fingerprint string, payload map[string]interface{}) Result {
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var signals []Signal
+	if s, ok := d.countBurst(model+":"+fingerprint, now); ok {
+		signals = append(signals, s)
+	}
+
+	seen := d.merge(model, now)
+	if seen.count >= d.opts.MinSamples {
+		signals = append(signals, inputSignals(seen, payload)...)
+	}
+
+	result := Result{Signals: signals}
+	if len(signals) > 0 {
+		// Independent signals combine like probabilities: 1 - prod(1 - s)
+		ordinary := 1.0
+		for _, s := range signals {
+			ordinary *= 1 - s.Score
+		}
+		result.Score = round(1 - ordinary)
+	}
+	return result
+}
+
+// countBurst counts one payload and returns a burst signal once it has
+// been seen more than BurstLimit times in the current burst window
+func (d *Detector) countBurst(key string, now time.Time) (Signal, bool) {
+	if now.Sub(d.swept) >= d.opts.BurstWindow {
+		for k, b := range d.bursts {
+			if now.Sub(b.start) >= d.opts.BurstWindow {
+				delete(d.bursts, k)
+			}
+		}
+		d.swept = now
+	}
+
+	b := d.bursts[key]
+	if b == nil || now.Sub(b.start) >= d.opts.BurstWindow {
+		b = &burst{start: now}
+		d.bursts[key] = b
+	}
+	b.n++
+	if d.opts.BurstLimit <= 0 || b.n <= d.opts.BurstLimit {
+		return Signal{}, false
+	}
+	over := float64(b.n-d.opts.BurstLimit) / float64(d.opts.BurstLimit)
+	return Signal{
+		Kind:    Burst,
+		Score:   round(math.Min(1, 0.5+0.5*over)),
+		Message: fmt.Sprintf("Identical payload received %d times in %s", b.n, d.opts.BurstWindow),
+	}, true
+}
+
+// inputSignals compares each top-level field of payload with the values
+// seen in recent traffic
+func inputSignals(seen *bucket, payload map[string]interface{}) []Signal {
+	fields := make([]string, 0, len(payload))
+	for field := range payload {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var signals []Signal
+	for _, field := range fields {
+		switch v := payload[field].(type) {
+		case string:
+			counts, ok := seen.values[field]
+			if ok && counts[v] == 0 {
+				signals = append(signals, Signal{
+					Kind:    NewValue,
+					Field:   field,
+					Score:   0.8,
+					Message: fmt.Sprintf("Value %q has not been seen in recent traffic", v),
+				})
+			}
+		default:
+			x, ok := number(v)
+			m := seen.numbers[field]
+			if !ok || m == nil || m.n < 2 {
+				continue
+			}
+			mean := m.sum / float64(m.n)
+			std := math.Sqrt(math.Max(0, m.sumSq/float64(m.n)-mean*mean))
+			if std == 0 {
+				if x != mean {
+					signals = append(signals, Signal{
+						Kind:    Outlier,
+						Field:   field,
+						Score:   0.8,
+						Message: fmt.Sprintf("Value %g differs from the constant %g in recent traffic", x, mean),
+					})
+				}
+				continue
+			}
+			if z := math.Abs(x-mean) / std; z > outlierZ {
+				signals = append(signals, Signal{
+					Kind:    Outlier,
+					Field:   field,
+					Score:   round(math.Min(1, 0.5+0.5*(z-outlierZ)/outlierZ)),
+					Message: fmt.Sprintf("Value %g is %.1f standard deviations from the recent mean %.4g", x, z, mean),
+				})
+			}
+		}
+	}
+	return signals
+}
+
+// Observe records the inputs of a successful prediction as ordinary traffic
+func (d *Detector) Observe(model string, payload map[string]interface{}) {
+	now := time.Now()
+	start := now.Truncate(d.bucket)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	buckets := d.models[model]
+	var b *bucket
+	if n := len(buckets); n > 0 && buckets[n-1].start.Equal(start) {
+		b = buckets[n-1]
+	} else {
+		b = &bucket{start: start, values: make(map[string]map[string]int), numbers: make(map[string]*moments)}
+		buckets = append(d.prune(buckets, now), b)
+		d.models[model] = buckets
+	}
+
+	b.count++
+	for field, v := range payload {
+		if s, ok := v.(string); ok {
+			counts := b.values[field]
+			if counts == nil {
+				counts = make(map[string]int)
+				b.values[field] = counts
+			}
+			counts[s]++
+			continue
+		}
+		if x, ok := number(v); ok {
+			m := b.numbers[field]
+			if m == nil {
+				m = &moments{}
+				b.numbers[field] = m
+			}
+			m.n++
+			m.sum += x
+			m.sumSq += x * x
+		}
+	}
+}
+
+// merge totals a model's buckets within the window
+func (d *Detector) merge(model string, now time.Time) *bucket {
+	total := &bucket{values: make(map[string]map[string]int), numbers: make(map[string]*moments)}
+	for _, b := range d.prune(d.models[model], now) {
+		total.count += b.count
+		for field, counts := range b.values {
+			t := total.values[field]
+			if t == nil {
+				t = make(map[string]int)
+				total.values[field] = t
+			}
+			for v, n := range counts {
+				t[v] += n
+			}
+		}
+		for field, m := range b.numbers {
+			t := total.numbers[field]
+			if t == nil {
+				t = &moments{}
+				total.numbers[field] = t
+			}
+			t.n += m.n
+			t.sum += m.sum
+			t.sumSq += m.sumSq
+		}
+	}
+	return total
+}
+
+// prune drops buckets older than the window
+func (d *Detector) prune(buckets []*bucket, now time.Time) []*bucket {
+	cutoff := now.Add(-d.opts.Window)
+	i := 0
+	for i < len(buckets) && buckets[i].start.Add(d.bucket).Before(cutoff) {
+		i++
+	}
+	return buckets[i:]
+}
+
+// number reads a JSON number decoded as float64 or json.Number
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func round(v float64) float64 {
+	return math.Round(v*1e4) / 1e4
+}
@@ -0,0 +1,196 @@
+// Package atrest encrypts the gateway's state files with AES-256-GCM.
+// Every sealed record names the key that encrypted it, so keys can be
+// rotated: new records are sealed with the newest key, and older keys keep
+// opening records written before the rotation.
+package atrest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// prefix starts every sealed record: "enc:v1:<key ID>:<base64 nonce and
+// ciphertext>". JSON never starts with it, so plaintext files written
+// before encryption was turned on are told apart and still read.
+const prefix = "enc:v1:"
+
+// KeySize is the length of a key in bytes (AES-256)
+const KeySize = 32
+
+// Keyring holds the keys records are sealed and opened with. A nil Keyring
+// writes plaintext.
+type Keyring struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// ParseKeyring reads keys written as "<id>:<base64 key>", newest first. The
+// first key seals new records; the others only open old ones.
+func ParseKeyring(list []string) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]cipher.AEAD)}
+	for _, entry := range list {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || id == "" || strings.ContainsAny(id, ": ") {
+			return nil, fmt.Errorf("invalid key %q: must be <id>:<base64 key>", id)
+		}
+		if _, dup := k.keys[id]; dup {
+			return nil, fmt.Errorf("key %s is listed twice", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != KeySize {
+			return nil, fmt.Errorf("key %s must be %d bytes, base64-encoded", id, KeySize)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[id] = aead
+		if k.current == "" {
+			k.current = id
+		}
+	}
+	if k.current == "" {
+		return nil, errors.New("no keys given")
+	}
+	return k, nil
+}
+
+// Current returns the ID of the key new records are sealed with
+func (k *Keyring) Current() string {
+	if k == nil {
+		return ""
+	}
+	return k.current
+}
+
+// Seal encrypts data with the current key. Without a keyring data is
+// returned as it is.
+func (k *Keyring) Seal(data []byte) ([]byte, error) {
+	if k == nil {
+		return data, nil
+	}
+	aead := k.keys[k.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	// The key ID is authenticated, so a record cannot be relabelled
+	sealed := aead.Seal(nonce, nonce, data, []byte(k.current))
+	return []byte(prefix + k.current + ":" + base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// Open decrypts a record sealed by Seal with any key in the ring. Data that
+// is not sealed is returned as it is, so plaintext files stay readable
+// after encryption is turned on and are sealed when next written.
+func (k *Keyring) Open(data []byte) ([]byte, error) {
+	rest, ok := bytes.CutPrefix(bytes.TrimSpace(data), []byte(prefix))
+	if !ok {
+		return data, nil
+	}
+	id, encoded, ok := bytes.Cut(rest, []byte(":"))
+	if !ok {
+		return nil, errors.New("malformed encrypted record")
+	}
+	if k == nil {
+		return nil, fmt.Errorf("record is encrypted with key %s, but no keys are configured", id)
+	}
+	aead, ok := k.keys[string(id)]
+	if !ok {
+		return nil, fmt.Errorf("record is encrypted with unknown key %s", id)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, errors.New("malformed encrypted record")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, id)
+	if err != nil {
+		return nil, fmt.Errorf("record does not decrypt with key %s", id)
+	}
+	return plain, nil
+}
+
+// OpenLines opens a file of records written one per line by a LineWriter.
+// Plaintext lines are kept as they are.
+func (k *Keyring) OpenLines(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for n := 1; scanner.Scan(); n++ {
+		line, err := k.Open(scanner.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		out.Write(line)
+		out.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// LineWriter seals each line written to it as a record of its own, so
+// appending to a file keeps every line readable on its own
+type LineWriter struct {
+	keys    *Keyring
+	out     io.WriteCloser
+	pending []byte
+}
+
+// NewLineWriter seals the lines written to out with keys
+func NewLineWriter(keys *Keyring, out io.WriteCloser) *LineWriter {
+	return &LineWriter{keys: keys, out: out}
+}
+
+// Write seals and writes each complete line, keeping any partial line
+// until its newline arrives
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if err := w.writeLine(w.pending[:i]); err != nil {
+			return 0, err
+		}
+		w.pending = w.pending[i+1:]
+	}
+}
+
+// Close writes any partial line and closes the output
+func (w *LineWriter) Close() error {
+	var err error
+	if len(w.pending) > 0 {
+		err = w.writeLine(w.pending)
+		w.pending = nil
+	}
+	if cerr := w.out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (w *LineWriter) writeLine(line []byte) error {
+	sealed, err := w.keys.Seal(line)
+	if err != nil {
+		return err
+	}
+	record := make([]byte, 0, len(sealed)+1)
+	record = append(append(record, sealed...), '\n')
+	_, err = w.out.Write(record)
+	return err
+}
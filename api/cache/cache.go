@@ -0,0 +1,188 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache is an in-memory LRU cache of prediction responses with a time to
+// live. Entries remember their model so a model's entries can be purged
+// together.
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List
+	entries map[string]*list.Element
+	hits    uint64
+	misses  uint64
+}
+
+type entry struct {
+	key     string
+	model   string
+	value   map[string]interface{}
+	stored  time.Time
+	expires time.Time
+}
+
+// Entry describes a cached response, without its value
+type Entry struct {
+	Key     string    `json:"key"`
+	Model   string    `json:"model"`
+	Stored  time.Time `json:"stored_at"`
+	Expires time.Time `json:"expires_at"`
+}
+
+// Stats are the cache's counters since start or the last reset
+type Stats struct {
+	Size    int     `json:"size"`
+	MaxSize int     `json:"max_size"`
+	Hits    uint64  `json:"hits"`
+	Misses  uint64  `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+	TTL     string  `json:"ttl"`
+}
+
+// New creates a cache holding up to size entries for ttl each
+func New(size int, ttl time.Duration) *Cache {
+	return &Cache{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns a copy of the cached value for key
+func (c *Cache) Get(key string) (map[string]interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if time.Now().After(e.expires) {
+		c.removeLocked(el)
+		c.misses++
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	c.hits++
+	return copyMap(e.value), true
+}
+
+// Set stores a copy of value under key, evicting the least recently used
+// entry if the cache is full
+func (c *Cache) Set(key, model string, value map[string]interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*entry)
+		e.model, e.value, e.stored, e.expires = model, copyMap(value), now, now.Add(c.ttl)
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&entry{
+		key:     key,
+		model:   model,
+		value:   copyMap(value),
+		stored:  now,
+		expires: now.Add(c.ttl),
+	})
+	for c.order.Len() > c.size {
+		c.removeLocked(c.order.Back())
+	}
+}
+
+// Delete removes key, reporting whether it was cached
+func (c *Cache) Delete(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if ok {
+		c.removeLocked(el)
+	}
+	return ok
+}
+
+// PurgeModel removes every entry for model and returns how many were removed
+func (c *Cache) PurgeModel(model string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for el := c.order.Front(); el != nil; {
+		next := el.Next()
+		if el.Value.(*entry).model == model {
+			c.removeLocked(el)
+			n++
+		}
+		el = next
+	}
+	return n
+}
+
+// Flush removes every entry and returns how many were removed
+func (c *Cache) Flush() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := c.order.Len()
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
+	return n
+}
+
+// Entries lists unexpired entries, most recently used first, optionally
+// only those for model
+func (c *Cache) Entries(model string) []Entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	out := []Entry{}
+	for el := c.order.Front(); el != nil; el = el.Next() {
+		e := el.Value.(*entry)
+		if now.After(e.expires) || (model != "" && e.model != model) {
+			continue
+		}
+		out = append(out, Entry{Key: e.key, Model: e.model, Stored: e.stored, Expires: e.expires})
+	}
+	return out
+}
+
+// Stats returns the cache's size and hit counters
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := Stats{Size: c.order.Len(), MaxSize: c.size, Hits: c.hits, Misses: c.misses, TTL: c.ttl.String()}
+	if total := c.hits + c.misses; total > 0 {
+		s.HitRate = float64(c.hits) / float64(total)
+	}
+	return s
+}
+
+func (c *Cache) removeLocked(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*entry).key)
+}
+
+// copyMap copies the top level of a response; nested values are shared and
+// must not be modified
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// WarmupSet describes popular inputs for one model: every combination of
+// the Vary values, each merged over the Base fields
+type WarmupSet struct {
+	Model string                   `json:"model"`
+	Base  map[string]interface{}   `json:"base,omitempty"`
+	Vary  map[string][]interface{} `json:"vary"`
+}
+
+// maxWarmupPayloads bounds the combinations a warm-up file may expand to
+const maxWarmupPayloads = 10000
+
+// LoadWarmup reads warm-up sets from a JSON file holding {"sets": [...]}
+func LoadWarmup(path string) ([]WarmupSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read warm-up file: %w", err)
+	}
+	defer f.Close()
+
+	var file struct {
+		Sets []WarmupSet `json:"sets"`
+	}
+	dec := json.NewDecoder(f)
+	dec.UseNumber()
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse warm-up file: %w", err)
+	}
+
+	total := 0
+	for i, set := range file.Sets {
+		if set.Model == "" {
+			return nil, fmt.Errorf("warm-up set %d: model is required", i+1)
+		}
+		n := 1
+		for field, values := range set.Vary {
+			if len(values) == 0 {
+				return nil, fmt.Errorf("warm-up set %d: vary.%s has no values", i+1, field)
+			}
+			n *= len(values)
+		}
+		if total += n; total > maxWarmupPayloads {
+			return nil, fmt.Errorf("warm-up file expands to more than %d payloads", maxWarmupPayloads)
+		}
+	}
+	return file.Sets, nil
+}
+
+// Payloads expands the set into one payload per combination of Vary values
+func (s WarmupSet) Payloads() []map[string]interface{} {
+	fields := make([]string, 0, len(s.Vary))
+	for field := range s.Vary {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	payloads := []map[string]interface{}{{}}
+	for _, field := range fields {
+		var next []map[string]interface{}
+		for _, p := range payloads {
+			for _, v := range s.Vary[field] {
+				q := copyMap(p)
+				q[field] = v
+				next = append(next, q)
+			}
+		}
+		payloads = next
+	}
+
+	for _, p := range payloads {
+		for k, v := range s.Base {
+			if _, varied := p[k]; !varied {
+				p[k] = v
+			}
+		}
+	}
+	return payloads
+}
@@ -0,0 +1,261 @@
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud-ai-api/atrest"
+)
+
+// versionsKept is how many versions are kept per model, newest first
+const versionsKept = 50
+
+// Change kinds
+const (
+	ModelAdded     = "model_added"
+	VersionAdded   = "version_added"
+	ModelRemoved   = "model_removed"
+	ModelRestored  = "model_restored"
+	VersionRevived = "version_revived"
+)
+
+// Version is one version of a model seen on the ML service
+type Version struct {
+	Version   string    `json:"version"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Source    string    `json:"source"`
+}
+
+// Model is what is known about one model served by the ML service: its
+// current version, the attributes from the latest listing, whether that
+// listing included it, and the versions seen, newest first
+type Model struct {
+	Name      string                 `json:"name"`
+	Current   string                 `json:"current,omitempty"`
+	Available bool                   `json:"available"`
+	Info      map[string]interface{} `json:"info,omitempty"`
+	FirstSeen time.Time              `json:"first_seen"`
+	UpdatedAt time.Time              `json:"updated_at"`
+	Versions  []Version              `json:"versions"`
+}
+
+// Change is a difference found between the catalog and the ML service
+type Change struct {
+	Kind    string `json:"kind"`
+	Model   string `json:"model"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+	Source  string `json:"source"`
+	Initial bool   `json:"-"`
+}
+
+// Catalog holds the models and versions the ML service has served. Changes
+// are written to a JSON file so the history survives restarts; the zero
+// file name keeps it in memory.
+type Catalog struct {
+	file string
+	keys *atrest.Keyring
+
+	mu     sync.RWMutex
+	models map[string]*Model
+	synced time.Time
+}
+
+// state is the on-disk format
+type state struct {
+	Synced time.Time         `json:"synced"`
+	Models map[string]*Model `json:"models"`
+}
+
+// Open loads the catalog from file, which need not exist yet, and encrypts
+// it with keys when it is saved. An empty file name keeps the catalog in
+// memory only.
+func Open(file string, keys *atrest.Keyring) (*Catalog, error) {
+	c := &Catalog{file: file, keys: keys, models: make(map[string]*Model)}
+	if file == "" {
+		return c, nil
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err == nil {
+		data, err = keys.Open(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("invalid model catalog file: %w", err)
+	}
+	for name, m := range st.Models {
+		if m != nil {
+			m.Name = name
+			if m.Versions == nil {
+				m.Versions = []Version{}
+			}
+			c.models[name] = m
+		}
+	}
+	c.synced = st.Synced
+	return c, nil
+}
+
+// Sync updates the catalog from the ML service's model listing: each
+// model's attributes and the version they name, if any. Models missing
+// from the listing are marked unavailable. The changes are returned; on
+// the first sync into an empty catalog they are marked Initial.
+func (c *Catalog) Sync(listing map[string]map[string]interface{}, versions map[string]string, now time.Time) ([]Change, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	initial := c.synced.IsZero() && len(c.models) == 0
+	var changes []Change
+	for name, info := range listing {
+		m, known := c.models[name]
+		if !known {
+			m = &Model{Name: name, FirstSeen: now, UpdatedAt: now, Versions: []Version{}}
+			c.models[name] = m
+			changes = append(changes, Change{Kind: ModelAdded, Model: name, To: versions[name], Source: "poll"})
+		} else if !m.Available {
+			m.UpdatedAt = now
+			changes = append(changes, Change{Kind: ModelRestored, Model: name, To: versions[name], Source: "poll"})
+		}
+		m.Available = true
+		if !reflect.DeepEqual(m.Info, info) {
+			m.Info = info
+			m.UpdatedAt = now
+		}
+		// A new model's first version is part of its addition
+		if change, ok := m.observe(versions[name], "poll", now); ok && known {
+			changes = append(changes, change)
+		}
+	}
+	for name, m := range c.models {
+		if _, ok := listing[name]; !ok && m.Available {
+			m.Available = false
+			m.UpdatedAt = now
+			changes = append(changes, Change{Kind: ModelRemoved, Model: name, From: m.Current, Source: "poll"})
+		}
+	}
+	c.synced = now
+	for i := range changes {
+		changes[i].Initial = initial
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Model < changes[j].Model })
+	return changes, c.save()
+}
+
+// Observe records a version of a model reported outside a sync, such as by
+// a deployment webhook, returning the change if the version is not the
+// current one
+func (c *Catalog) Observe(model, version, source string, now time.Time) (Change, bool, error) {
+	if version == "" {
+		return Change{}, false, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m, ok := c.models[model]
+	if !ok {
+		m = &Model{Name: model, FirstSeen: now, Available: true, Versions: []Version{}}
+		c.models[model] = m
+	}
+	change, changed := m.observe(version, source, now)
+	if !ok {
+		change = Change{Kind: ModelAdded, Model: model, To: version, Source: source}
+		changed = true
+	}
+	if !changed {
+		return Change{}, false, nil
+	}
+	return change, true, c.save()
+}
+
+// observe makes version current, reporting a change if it was not. A
+// version seen before becomes current again without being added twice.
+func (m *Model) observe(version, source string, now time.Time) (Change, bool) {
+	if version == "" {
+		return Change{}, false
+	}
+	if m.Current == version {
+		if len(m.Versions) > 0 {
+			m.Versions[0].LastSeen = now
+		}
+		return Change{}, false
+	}
+	change := Change{Kind: VersionAdded, Model: m.Name, From: m.Current, To: version, Source: source}
+	v := Version{Version: version, FirstSeen: now, LastSeen: now, Source: source}
+	for i, old := range m.Versions {
+		if old.Version == version {
+			change.Kind = VersionRevived
+			v = old
+			v.LastSeen = now
+			m.Versions = append(m.Versions[:i], m.Versions[i+1:]...)
+			break
+		}
+	}
+	m.Versions = append([]Version{v}, m.Versions...)
+	if len(m.Versions) > versionsKept {
+		m.Versions = m.Versions[:versionsKept]
+	}
+	m.Current = version
+	m.UpdatedAt = now
+	return change, true
+}
+
+// List returns the models, sorted by name, and when the catalog was last
+// synced with the ML service
+func (c *Catalog) List() ([]Model, time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make([]Model, 0, len(c.models))
+	for _, m := range c.models {
+		cp := *m
+		cp.Versions = append([]Version{}, m.Versions...)
+		out = append(out, cp)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, c.synced
+}
+
+// save persists the catalog. The caller must hold c.mu.
+func (c *Catalog) save() error {
+	if c.file == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(state{Synced: c.synced, Models: c.models}, "", "  ")
+	if err == nil {
+		data, err = c.keys.Seal(data)
+	}
+	if err != nil {
+		return err
+	}
+	// Write atomically so a crash never leaves a truncated file
+	tmp, err := os.CreateTemp(filepath.Dir(c.file), ".catalog-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to save model catalog: %w", err)
+	}
+	_, werr := tmp.Write(data)
+	cerr := tmp.Close()
+	if werr == nil {
+		werr = cerr
+	}
+	if werr == nil {
+		werr = os.Rename(tmp.Name(), c.file)
+	}
+	if werr != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save model catalog: %w", werr)
+	}
+	return nil
+}
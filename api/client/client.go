@@ -0,0 +1,174 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cloud-ai-api/jobs"
+	"cloud-ai-api/models"
+)
+
+// maxResponseBytes caps the gateway responses read into memory
+const maxResponseBytes = 64 << 20
+
+// Client calls a Cloud AI API gateway
+type Client struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+// New creates a client for the gateway at baseURL, sending apiKey as
+// X-API-Key if set
+func New(baseURL, apiKey string, timeout time.Duration) *Client {
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		http:    &http.Client{Timeout: timeout},
+	}
+}
+
+// APIError is an error response from the gateway
+type APIError struct {
+	Status   int
+	Response models.ErrorResponse
+}
+
+func (e *APIError) Error() string {
+	msg := e.Response.Error
+	if msg == "" {
+		msg = http.StatusText(e.Status)
+	}
+	if e.Response.Details != "" {
+		msg += ": " + e.Response.Details
+	}
+	return fmt.Sprintf("gateway returned %d: %s", e.Status, msg)
+}
+
+// Predict sends a prediction request for model and returns the response
+func (c *Client) Predict(ctx context.Context, model string, payload map[string]interface{}) (map[string]interface{}, error) {
+	var resp map[string]interface{}
+	err := c.do(ctx, http.MethodPost, "/api/v1/predict/"+url.PathEscape(model), payload, &resp)
+	return resp, err
+}
+
+// Health returns the gateway's health report. Unlike the other calls it
+// does not fail on an unhealthy status, which is reported in the response.
+func (c *Client) Health(ctx context.Context) (models.HealthResponse, error) {
+	var resp models.HealthResponse
+	err := c.do(ctx, http.MethodGet, "/api/v1/health", nil, &resp)
+	return resp, err
+}
+
+// Ready returns the gateway's readiness. A gateway that is not ready
+// answers 503 with its checks, which is not an error here.
+func (c *Client) Ready(ctx context.Context) (models.ReadinessResponse, error) {
+	var resp models.ReadinessResponse
+	err := c.do(ctx, http.MethodGet, "/api/v1/ready", nil, &resp)
+	if apiErr, ok := err.(*APIError); ok && apiErr.Status == http.StatusServiceUnavailable {
+		return resp, nil
+	}
+	return resp, err
+}
+
+// SubmitJob queues a batch prediction job
+func (c *Client) SubmitJob(ctx context.Context, model string, rows []map[string]interface{}) (jobs.Job, error) {
+	var job jobs.Job
+	err := c.do(ctx, http.MethodPost, "/api/v1/jobs", models.JobRequest{Model: model, Rows: rows}, &job)
+	return job, err
+}
+
+// Job returns the progress of a batch job
+func (c *Client) Job(ctx context.Context, id string) (jobs.Job, error) {
+	var job jobs.Job
+	err := c.do(ctx, http.MethodGet, "/api/v1/jobs/"+url.PathEscape(id), nil, &job)
+	return job, err
+}
+
+// JobResults returns the per-row results of a finished batch job
+func (c *Client) JobResults(ctx context.Context, id string) ([]jobs.Result, error) {
+	var resp struct {
+		Results []jobs.Result `json:"results"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/v1/jobs/"+url.PathEscape(id)+"/results", nil, &resp)
+	return resp.Results, err
+}
+
+// WaitJob polls a batch job every interval until it finishes, calling
+// progress, if set, after each poll
+func (c *Client) WaitJob(ctx context.Context, id string, interval time.Duration, progress func(jobs.Job)) (jobs.Job, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		job, err := c.Job(ctx, id)
+		if err != nil {
+			return job, err
+		}
+		if progress != nil {
+			progress(job)
+		}
+		if job.Status == jobs.StatusCompleted || job.Status == jobs.StatusFailed {
+			return job, nil
+		}
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// do sends a request with an optional JSON body and decodes the JSON
+// response into out. Responses with a status of 400 or above are returned
+// as an *APIError, with out still decoded where the body allows.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := &APIError{Status: resp.StatusCode}
+		json.Unmarshal(data, &apiErr.Response)
+		json.Unmarshal(data, out)
+		return apiErr
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"cloud-ai-api/registry"
+)
+
+// Row input formats
+const (
+	FormatCSV   = "csv"
+	FormatJSON  = "json"
+	FormatJSONL = "jsonl"
+)
+
+// ReadRows reads batch prediction rows as CSV with a header line, a JSON
+// array of objects or JSON lines. CSV columns and JSON keys may use field
+// aliases, which are replaced by the field names; CSV values are converted
+// to the declared field types, and empty CSV values are left out.
+func ReadRows(r io.Reader, format string, model *registry.Model) ([]map[string]interface{}, error) {
+	var rows []map[string]interface{}
+	var err error
+	switch format {
+	case FormatCSV:
+		rows, err = readCSV(r, model)
+	case FormatJSON:
+		decoder := json.NewDecoder(r)
+		decoder.UseNumber()
+		if err = decoder.Decode(&rows); err != nil {
+			err = fmt.Errorf("invalid JSON rows: %w", err)
+		}
+	case FormatJSONL:
+		rows, err = readJSONLines(r)
+	default:
+		return nil, fmt.Errorf("unknown row format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, row := range rows {
+		if row == nil {
+			return nil, fmt.Errorf("row %d: must be a JSON object", i+1)
+		}
+		rows[i] = Canonical(model, row)
+	}
+	return rows, nil
+}
+
+// Canonical renames fields given by alias to their field names
+func Canonical(model *registry.Model, row map[string]interface{}) map[string]interface{} {
+	if model == nil {
+		return row
+	}
+	out := make(map[string]interface{}, len(row))
+	for name, value := range row {
+		if f, ok := model.Field(name); ok {
+			name = f.Name
+		}
+		out[name] = value
+	}
+	return out
+}
+
+func readCSV(r io.Reader, model *registry.Model) ([]map[string]interface{}, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+	types := make([]string, len(header))
+	for i, name := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		if model != nil {
+			if f, ok := model.Field(header[i]); ok {
+				types[i] = f.Type
+			}
+		}
+	}
+
+	var rows []map[string]interface{}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		row := make(map[string]interface{}, len(header))
+		for i, value := range record {
+			if value = strings.TrimSpace(value); value != "" {
+				row[header[i]] = registry.TypedValue(value, types[i])
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+func readJSONLines(r io.Reader) ([]map[string]interface{}, error) {
+	var rows []map[string]interface{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.UseNumber()
+		var row map[string]interface{}
+		if err := decoder.Decode(&row); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if row == nil {
+			return nil, fmt.Errorf("line %d: must be a JSON object", line)
+		}
+		rows = append(rows, row)
+	}
+	return rows, scanner.Err()
+}
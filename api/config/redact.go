@@ -0,0 +1,329 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// redacted replaces secret values in the effective configuration
+const redacted = "[REDACTED]"
+
+// Redacted returns the effective configuration with secrets masked, for
+// operators to confirm what a running instance is using. Durations are
+// reported as strings such as "30s".
+func (cfg *Config) Redacted() map[string]interface{} {
+	return map[string]interface{}{
+		"server": map[string]interface{}{
+			"port":             cfg.Port,
+			"socket":           cfg.ListenSocket,
+			"socket_mode":      fmt.Sprintf("%#o", cfg.SocketMode),
+			"listeners":        emptyList(cfg.Listeners),
+			"pid_file":         cfg.PIDFile,
+			"shutdown_timeout": cfg.Shutdown.String(),
+			"upgrade_timeout":  cfg.Upgrade.String(),
+			"gin_mode":         cfg.GinMode,
+			"slow_request":     cfg.SlowRequest.String(),
+			"demo_ui":          cfg.DemoUI,
+		},
+		"ml_service": map[string]interface{}{
+			"url":          redactURL(cfg.MLServiceURL),
+			"socket":       cfg.MLSocket,
+			"timeout":      cfg.MLTimeout.String(),
+			"max_response": cfg.MLMaxBody,
+			"connections": map[string]interface{}{
+				"http2":        cfg.MLHTTP2,
+				"idle_timeout": cfg.MLIdleConn.String(),
+				"max_idle":     cfg.MLMaxIdle,
+				"max_streams":  cfg.MLMaxStreams,
+			},
+			"slow_call":    cfg.SlowML.String(),
+			"keep_warm":    cfg.KeepWarm.String(),
+			"version_poll": cfg.VersionPoll.String(),
+			"self_test":    cfg.SelfTest,
+			"contract":     cfg.MLContractFile,
+			"routes":       cfg.MLRoutesFile,
+			"regions": map[string]interface{}{
+				"file":           cfg.MLRegionsFile,
+				"country_header": cfg.CountryHeader,
+			},
+			"bulkhead": map[string]interface{}{
+				"max_concurrent":   cfg.MLMaxConcurrent,
+				"queue_timeout":    cfg.MLQueueTimeout.String(),
+				"starvation_limit": cfg.MLStarvation.String(),
+				"priority_keys":    len(cfg.PriorityKeys),
+			},
+			"discovery": map[string]interface{}{
+				"mode":      cfg.Discovery.Mode,
+				"name":      cfg.Discovery.Name,
+				"scheme":    cfg.Discovery.Scheme,
+				"interval":  cfg.Discovery.Interval.String(),
+				"namespace": cfg.Discovery.Namespace,
+				"port":      cfg.Discovery.Port,
+			},
+		},
+		"registry": map[string]interface{}{
+			"file":         cfg.RegistryFile,
+			"hook_plugins": emptyList(cfg.HookPlugins),
+			"ensembles":    cfg.EnsembleFile,
+			"catalog":      cfg.CatalogFile,
+		},
+		"alerts": map[string]interface{}{
+			"rules_file": cfg.AlertRulesFile,
+			"interval":   cfg.AlertInterval.String(),
+		},
+		"usage": map[string]interface{}{
+			"currency":      cfg.CostCurrency,
+			"cost_per_call": cfg.CostPerCall,
+			"state_file":    cfg.UsageStateFile,
+		},
+		"slo": map[string]interface{}{
+			"file":       cfg.SLOFile,
+			"state_file": cfg.SLOStateFile,
+		},
+		"localization": map[string]interface{}{
+			"catalog": cfg.MessagesFile,
+		},
+		"plausibility": map[string]interface{}{
+			"file": cfg.PlausibilityFile,
+			"mode": cfg.PlausibilityMode,
+		},
+		"sanity": map[string]interface{}{
+			"file": cfg.SanityFile,
+			"mode": cfg.SanityMode,
+		},
+		"drift": map[string]interface{}{
+			"window":          cfg.DriftWindow.String(),
+			"baseline":        cfg.DriftBaseline.String(),
+			"psi_threshold":   cfg.DriftPSI,
+			"shift_threshold": cfg.DriftShift,
+			"min_samples":     cfg.DriftMinSamples,
+		},
+		"anomaly": map[string]interface{}{
+			"window":       cfg.AnomalyWindow.String(),
+			"min_samples":  cfg.AnomalyMinSamples,
+			"burst_window": cfg.AnomalyBurstWindow.String(),
+			"burst_limit":  cfg.AnomalyBurstLimit,
+			"reject_score": cfg.AnomalyRejectScore,
+		},
+		"cache": map[string]interface{}{
+			"http_max_age":    cfg.HTTPCacheMaxAge.String(),
+			"response_size":   cfg.ResponseCacheSize,
+			"response_ttl":    cfg.ResponseCacheTTL.String(),
+			"warmup_file":     cfg.WarmupFile,
+			"warmup_schedule": cfg.WarmupSchedule,
+			"fanout_size":     cfg.FanOutCacheSize,
+			"fanout_ttl":      cfg.FanOutCacheTTL.String(),
+		},
+		"access_log": map[string]interface{}{
+			"sample_rate":    cfg.AccessLogSampleRate,
+			"capture_bodies": cfg.AccessLogBodies,
+			"max_body_bytes": cfg.AccessLogMaxBody,
+		},
+		"health": map[string]interface{}{
+			"check_interval": cfg.HealthCheck.String(),
+			"critical":       emptyList(cfg.HealthCritical),
+		},
+		"stats": map[string]interface{}{
+			"window": cfg.StatsWindow.String(),
+		},
+		"history": map[string]interface{}{
+			"size": cfg.HistorySize,
+		},
+		"jobs": map[string]interface{}{
+			"max_rows": cfg.JobMaxRows,
+		},
+		"appraisals": map[string]interface{}{
+			"max_properties": cfg.AppraisalMax,
+		},
+		"schedules": map[string]interface{}{
+			"file":          cfg.SchedulesFile,
+			"max_per_owner": cfg.ScheduleMax,
+		},
+		"routes": map[string]interface{}{
+			"state_file":       cfg.RouteStateFile,
+			"deprecation_file": cfg.DeprecationFile,
+			"flags_file":       cfg.FlagsFile,
+			"concurrency_file": cfg.ConcurrencyFile,
+			"ip_filter_file":   cfg.IPFilterFile,
+		},
+		"replay": map[string]interface{}{
+			"capture_dir": cfg.CaptureDir,
+		},
+		"dynamic_config": map[string]interface{}{
+			"source":         cfg.Dynamic.Source,
+			"prefix":         cfg.Dynamic.Prefix,
+			"etcd_endpoints": emptyList(cfg.Dynamic.EtcdEndpoints),
+		},
+		"consul": map[string]interface{}{
+			"addr":  redactURL(cfg.Consul.Addr),
+			"token": secret(cfg.Consul.Token),
+		},
+		"load_shedding": map[string]interface{}{
+			"max_queue_depth": cfg.ShedQueueDepth,
+			"max_p99":         cfg.ShedP99.String(),
+			"max_fraction":    cfg.ShedMaxFraction,
+		},
+		"abuse": map[string]interface{}{
+			"window":            cfg.AbuseWindow.String(),
+			"max_errors":        cfg.AbuseMaxErrors,
+			"max_auth_failures": cfg.AbuseMaxAuthFailures,
+			"ban_duration":      cfg.AbuseBanDuration.String(),
+			"exempt_ips":        emptyList(cfg.AbuseExemptIPs),
+			"honeypot_paths":    emptyList(cfg.HoneypotPaths),
+		},
+		"cors": map[string]interface{}{
+			"allowed_origins": emptyList(cfg.CORSOrigins),
+		},
+		"rate_limit": map[string]interface{}{
+			"requests_per_second": cfg.RateLimitRPS,
+			"burst":               cfg.RateLimitBurst,
+		},
+		"client_ip": map[string]interface{}{
+			"trusted_proxies":      emptyList(cfg.TrustedProxies),
+			"headers":              cfg.RealIPHeaders,
+			"trust_caller_headers": cfg.TrustCallerHeaders,
+		},
+		"secrets": map[string]interface{}{
+			"vault_addr":      cfg.Secrets.VaultAddr,
+			"vault_token":     secret(cfg.Secrets.VaultToken),
+			"vault_namespace": cfg.Secrets.VaultNamespace,
+			"vault_k8s_role":  cfg.Secrets.VaultRole,
+			"vault_k8s_auth":  cfg.Secrets.VaultAuthPath,
+			"aws_endpoint":    cfg.Secrets.AWSEndpoint,
+			"refresh":         cfg.Secrets.Refresh.String(),
+			"reload":          cfg.Secrets.Reload,
+		},
+		"storage_encryption": map[string]interface{}{
+			"key_ids": keyIDs(cfg.StorageKeys),
+		},
+		"response_signing": map[string]interface{}{
+			"key_file": cfg.SigningKeyFile,
+			"key_id":   cfg.SigningKeyID,
+		},
+		"admin": map[string]interface{}{
+			"token":            secret(cfg.AdminToken),
+			"diagnostics_port": cfg.AdminPort,
+			"oidc": map[string]interface{}{
+				"issuer":         cfg.OIDC.Issuer,
+				"client_id":      cfg.OIDC.ClientID,
+				"client_secret":  secret(cfg.OIDC.ClientSecret),
+				"audiences":      emptyList(cfg.OIDC.Audiences),
+				"groups_claim":   cfg.OIDC.GroupsClaim,
+				"role_map":       emptyList(cfg.OIDC.RoleMap),
+				"scopes":         emptyList(cfg.OIDC.Scopes),
+				"session_ttl":    cfg.OIDC.SessionTTL.String(),
+				"session_secret": secret(cfg.OIDC.SessionSecret),
+			},
+		},
+		"maintenance": map[string]interface{}{
+			"enabled_at_startup": cfg.Maintenance,
+			"message":            cfg.MaintenanceMessage,
+			"allow_ips":          emptyList(cfg.MaintenanceAllowIPs),
+			"allow_keys":         len(cfg.MaintenanceAllowKeys),
+		},
+		"object_storage": map[string]interface{}{
+			"s3": map[string]interface{}{
+				"endpoint":      cfg.S3.Endpoint,
+				"region":        cfg.S3.Region,
+				"access_key":    cfg.S3.AccessKey,
+				"secret_key":    secret(cfg.S3.SecretKey),
+				"session_token": secret(cfg.S3.SessionToken),
+			},
+			"gcs": map[string]interface{}{
+				"access_key": cfg.GCS.AccessKey,
+				"secret_key": secret(cfg.GCS.SecretKey),
+			},
+			"export_destinations": emptyList(cfg.ExportDestinations),
+		},
+		"smtp": map[string]interface{}{
+			"host":     cfg.SMTP.Host,
+			"port":     cfg.SMTP.Port,
+			"username": cfg.SMTP.Username,
+			"password": secret(cfg.SMTP.Password),
+			"from":     cfg.SMTP.From,
+			"tls":      cfg.SMTP.TLS,
+
+			"notify_domains":  emptyList(cfg.SMTP.NotifyDomains),
+			"notify_per_hour": cfg.SMTP.NotifyPerHour,
+		},
+		"public_url":   cfg.PublicURL,
+		"feedback_url": cfg.FeedbackURL,
+		"accounts": map[string]interface{}{
+			"file":           cfg.Accounts.File,
+			"daily_quota":    cfg.Accounts.DailyQuota,
+			"max_keys":       cfg.Accounts.MaxKeys,
+			"verify_ttl":     cfg.Accounts.VerifyTTL.String(),
+			"rotation_grace": cfg.Accounts.RotationGrace.String(),
+
+			"registrations_per_address": cfg.Accounts.RegistrationsPerAddress,
+			"registrations_per_ip":      cfg.Accounts.RegistrationsPerIP,
+		},
+		"envelope_keys":   len(cfg.EnvelopeKeys),
+		"method_override": cfg.MethodOverride,
+		"notify": map[string]interface{}{
+			"slack_webhooks":     len(cfg.Notify.SlackWebhooks),
+			"teams_webhooks":     len(cfg.Notify.TeamsWebhooks),
+			"events":             emptyList(cfg.Notify.Events),
+			"ml_unhealthy_after": cfg.Notify.MLUnhealthyAfter.String(),
+			"error_rate":         cfg.Notify.ErrorRate,
+			"min_requests":       cfg.Notify.MinRequests,
+		},
+		"kafka": map[string]interface{}{
+			"brokers": emptyList(cfg.KafkaBrokers),
+			"topic":   cfg.KafkaTopic,
+		},
+		"statsd": map[string]interface{}{
+			"addr":      cfg.StatsD.Addr,
+			"prefix":    cfg.StatsD.Prefix,
+			"tags":      emptyList(cfg.StatsD.Tags),
+			"dogstatsd": cfg.StatsD.DogStatsD,
+		},
+		"sentry": map[string]interface{}{
+			"dsn":         secret(cfg.Sentry.DSN),
+			"environment": cfg.Sentry.Environment,
+			"sample_rate": cfg.Sentry.SampleRate,
+		},
+		"queue": map[string]interface{}{
+			"driver":      cfg.Queue.Driver,
+			"url":         redactURL(cfg.Queue.URL),
+			"request":     cfg.Queue.Request,
+			"reply":       cfg.Queue.Reply,
+			"concurrency": cfg.Queue.Concurrency,
+		},
+	}
+}
+
+// secret masks a value if it is set, so operators can still tell set from unset
+func secret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return redacted
+}
+
+// keyIDs lists the IDs of "<id>:<key>" entries without the keys
+func keyIDs(entries []string) []string {
+	ids := []string{}
+	for _, entry := range entries {
+		id, _, _ := strings.Cut(entry, ":")
+		ids = append(ids, strings.TrimSpace(id))
+	}
+	return ids
+}
+
+// redactURL masks the password in a URL's user info
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	return u.Redacted()
+}
+
+func emptyList(items []string) []string {
+	if items == nil {
+		return []string{}
+	}
+	return items
+}
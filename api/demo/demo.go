@@ -0,0 +1,12 @@
+package demo
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed static
+var static embed.FS
+
+// Files holds the demo page and its script and stylesheet
+var Files, _ = fs.Sub(static, "static")
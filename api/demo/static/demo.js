@@ -0,0 +1,196 @@
+// Builds a form for each model listed by /api/v2/models and shows the
+// predictions returned by /api/v1/predict/<model>.
+(function () {
+  "use strict";
+
+  // Example inputs the forms start with
+  var examples = {
+    housing: { property_type: "D", is_new: "N", duration: "F", county: "GREATER LONDON", year: 2016, month: 6 },
+    electricity: { year: 2024, month: 1, day: 15, hour: 18 }
+  };
+
+  // Readable names for enum values
+  var enumLabels = {
+    property_type: { D: "Detached", S: "Semi-detached", T: "Terraced", F: "Flat", O: "Other" },
+    is_new: { Y: "New build", N: "Existing" },
+    duration: { F: "Freehold", L: "Leasehold", U: "Unknown" }
+  };
+
+  var apiKey = document.getElementById("api-key");
+  apiKey.value = sessionStorage.getItem("demo-api-key") || "";
+  apiKey.addEventListener("change", function () {
+    sessionStorage.setItem("demo-api-key", apiKey.value);
+  });
+
+  function headers() {
+    var h = { "Content-Type": "application/json", "Accept": "application/json" };
+    if (apiKey.value) {
+      h["X-API-Key"] = apiKey.value;
+    }
+    return h;
+  }
+
+  function label(field) {
+    var text = field.label || field.name.replace(/_/g, " ");
+    return text.charAt(0).toUpperCase() + text.slice(1);
+  }
+
+  function input(field, example) {
+    var el;
+    if (field.enum && field.enum.length) {
+      el = document.createElement("select");
+      field.enum.forEach(function (value) {
+        var option = document.createElement("option");
+        option.value = value;
+        option.textContent = (enumLabels[field.name] || {})[value] || value;
+        el.appendChild(option);
+      });
+    } else if (field.type === "boolean") {
+      el = document.createElement("input");
+      el.type = "checkbox";
+      el.checked = example === true;
+      return el;
+    } else {
+      el = document.createElement("input");
+      if (field.type === "integer" || field.type === "number") {
+        el.type = "number";
+        el.step = field.type === "integer" ? "1" : "any";
+        if (field.min !== undefined) { el.min = field.min; }
+        if (field.max !== undefined) { el.max = field.max; }
+      } else {
+        el.type = "text";
+      }
+    }
+    el.required = !!field.required;
+    if (example !== undefined) {
+      el.value = example;
+    }
+    return el;
+  }
+
+  function value(field, el) {
+    if (field.type === "boolean") {
+      return el.checked;
+    }
+    if (el.value === "") {
+      return undefined;
+    }
+    if (field.type === "integer" || field.type === "number") {
+      return Number(el.value);
+    }
+    return el.value;
+  }
+
+  function money(n) {
+    return "£" + Math.round(n).toLocaleString("en-GB");
+  }
+
+  // headline summarizes a prediction in a line, for the built-in models
+  function headline(model, resp) {
+    if (typeof resp.price === "number") {
+      var text = money(resp.price);
+      if (typeof resp.confidence_lower === "number" && typeof resp.confidence_upper === "number") {
+        return [text, "likely between " + money(resp.confidence_lower) + " and " + money(resp.confidence_upper)];
+      }
+      return [text, ""];
+    }
+    if (typeof resp.demand_mw === "number") {
+      return [Math.round(resp.demand_mw).toLocaleString("en-GB") + " MW", resp.datetime ? "demand at " + resp.datetime : ""];
+    }
+    return ["Prediction received", "see the response below"];
+  }
+
+  function show(section, ok, title, detail, warnings, body) {
+    var result = section.querySelector(".result");
+    result.hidden = false;
+    result.classList.toggle("error", !ok);
+    var head = result.querySelector(".headline");
+    head.textContent = title;
+    if (detail) {
+      var small = document.createElement("small");
+      small.textContent = detail;
+      head.appendChild(small);
+    }
+    var list = result.querySelector(".warnings");
+    list.textContent = "";
+    (warnings || []).forEach(function (text) {
+      var item = document.createElement("li");
+      item.textContent = text;
+      list.appendChild(item);
+    });
+    result.querySelector("pre").textContent = JSON.stringify(body, null, 2);
+  }
+
+  function predict(model, section, inputs) {
+    var payload = {};
+    model.fields.forEach(function (field) {
+      var v = value(field, inputs[field.name]);
+      if (v !== undefined) {
+        payload[field.name] = v;
+      }
+    });
+    var button = section.querySelector("button");
+    button.disabled = true;
+    fetch("../api/v1/predict/" + encodeURIComponent(model.name), {
+      method: "POST",
+      headers: headers(),
+      body: JSON.stringify(payload)
+    }).then(function (resp) {
+      return resp.json().then(function (body) {
+        if (!resp.ok) {
+          var problems = (body.violations || []).map(function (v) {
+            return v.pointer.replace(/^\//, "") + ": " + v.message;
+          });
+          show(section, false, body.error || ("Request failed (" + resp.status + ")"), body.details, problems, body);
+          return;
+        }
+        var line = headline(model, body);
+        var warnings = (body.warnings || []).map(function (w) { return w.message; });
+        show(section, true, line[0], line[1], warnings, body);
+      });
+    }).catch(function (err) {
+      show(section, false, "Request failed", String(err), [], {});
+    }).then(function () {
+      button.disabled = false;
+    });
+  }
+
+  function render(model) {
+    var template = document.getElementById("model-template");
+    var section = template.content.firstElementChild.cloneNode(true);
+    section.querySelector("h2").textContent = model.name;
+    section.querySelector(".description").textContent = model.description || "";
+    var fields = section.querySelector(".fields");
+    var inputs = {};
+    var example = examples[model.name] || {};
+    model.fields.forEach(function (field) {
+      var wrapper = document.createElement("label");
+      wrapper.textContent = label(field) + (field.required ? " *" : "");
+      inputs[field.name] = input(field, example[field.name]);
+      wrapper.appendChild(inputs[field.name]);
+      fields.appendChild(wrapper);
+    });
+    section.querySelector("form").addEventListener("submit", function (event) {
+      event.preventDefault();
+      predict(model, section, inputs);
+    });
+    return section;
+  }
+
+  var main = document.getElementById("models");
+  fetch("../api/v2/models", { headers: headers() }).then(function (resp) {
+    return resp.json();
+  }).then(function (body) {
+    main.textContent = "";
+    var list = (body.data || []).filter(function (m) { return m.fields && m.fields.length; });
+    if (!list.length) {
+      main.textContent = "No models with declared fields are available.";
+      return;
+    }
+    list.forEach(function (model) {
+      main.appendChild(render(model));
+    });
+  }).catch(function (err) {
+    document.getElementById("loading").textContent = "Could not load models: " + err;
+  });
+})();
@@ -0,0 +1,103 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ConsulWatcher resolves backends from the passing instances of a Consul
+// service, using blocking queries so changes arrive as soon as Consul
+// sees them
+type ConsulWatcher struct {
+	Addr    string
+	Token   string
+	Service string
+	Scheme  string
+}
+
+// consulWait is how long a blocking query may wait for a change
+const consulWait = 5 * time.Minute
+
+// Watch runs blocking queries until ctx is cancelled
+func (w *ConsulWatcher) Watch(ctx context.Context, update func([]string)) {
+	client := &http.Client{Timeout: consulWait + 30*time.Second}
+	var index uint64
+	for {
+		backends, next, err := w.query(ctx, client, index)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Consul lookup for %s failed: %v", w.Service, err)
+			index = 0
+			if !sleep(ctx, backoff) {
+				return
+			}
+			continue
+		}
+		update(backends)
+
+		// A lower index means Consul's state was reset; start over. Without
+		// an index the query cannot block, so fall back to polling.
+		if next < index {
+			next = 0
+		}
+		index = next
+		if index == 0 && !sleep(ctx, backoff) {
+			return
+		}
+	}
+}
+
+// query fetches the passing instances, waiting for a change past index
+func (w *ConsulWatcher) query(ctx context.Context, client *http.Client, index uint64) ([]string, uint64, error) {
+	u := fmt.Sprintf("%s/v1/health/service/%s?passing=true&index=%d&wait=%s",
+		w.Addr, url.PathEscape(w.Service), index, consulWait)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if w.Token != "" {
+		req.Header.Set("X-Consul-Token", w.Token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var entries []struct {
+		Node struct {
+			Address string `json:"Address"`
+		} `json:"Node"`
+		Service struct {
+			Address string `json:"Address"`
+			Port    int    `json:"Port"`
+		} `json:"Service"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, 0, fmt.Errorf("invalid response format: %w", err)
+	}
+
+	backends := make([]string, 0, len(entries))
+	for _, e := range entries {
+		host := e.Service.Address
+		if host == "" {
+			host = e.Node.Address
+		}
+		backends = append(backends, fmt.Sprintf("%s://%s", w.Scheme, net.JoinHostPort(host, strconv.Itoa(e.Service.Port))))
+	}
+	next, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	return backends, next, nil
+}
@@ -0,0 +1,197 @@
+package discovery
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// serviceAccountDir holds the in-cluster credentials mounted into every pod
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// KubernetesWatcher resolves backends from the ready addresses of a
+// Kubernetes Service's Endpoints, watching the API server for changes.
+// It uses the pod's service account, which needs get/list/watch on
+// endpoints in Namespace. Port selects a named port; if empty, the first
+// port is used.
+type KubernetesWatcher struct {
+	Namespace string
+	Service   string
+	Port      string
+	Scheme    string
+
+	api    string
+	client *http.Client
+}
+
+// endpoints is the subset of a Kubernetes Endpoints object the watcher uses
+type endpoints struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Subsets []struct {
+		Addresses []struct {
+			IP string `json:"ip"`
+		} `json:"addresses"`
+		Ports []struct {
+			Name string `json:"name"`
+			Port int    `json:"port"`
+		} `json:"ports"`
+	} `json:"subsets"`
+}
+
+// NewKubernetesWatcher configures a watcher from the in-cluster environment.
+// An empty namespace means the pod's own namespace.
+func NewKubernetesWatcher(namespace, service, port, scheme string) (*KubernetesWatcher, error) {
+	host, apiPort := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || apiPort == "" {
+		return nil, fmt.Errorf("not running in a Kubernetes cluster (KUBERNETES_SERVICE_HOST is not set)")
+	}
+
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account CA: %w", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("service account CA contains no certificates")
+	}
+
+	if namespace == "" {
+		ns, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pod namespace: %w", err)
+		}
+		namespace = strings.TrimSpace(string(ns))
+	}
+
+	return &KubernetesWatcher{
+		Namespace: namespace,
+		Service:   service,
+		Port:      port,
+		Scheme:    scheme,
+		api:       "https://" + net.JoinHostPort(host, apiPort),
+		client: &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots},
+		}},
+	}, nil
+}
+
+// Watch lists the Endpoints, then watches for changes from that version,
+// listing again whenever the watch ends
+func (w *KubernetesWatcher) Watch(ctx context.Context, update func([]string)) {
+	for {
+		if err := w.listAndWatch(ctx, update); err != nil && ctx.Err() == nil {
+			log.Printf("Kubernetes endpoints watch for %s/%s failed: %v", w.Namespace, w.Service, err)
+		}
+		if !sleep(ctx, backoff) {
+			return
+		}
+	}
+}
+
+func (w *KubernetesWatcher) listAndWatch(ctx context.Context, update func([]string)) error {
+	var current endpoints
+	path := fmt.Sprintf("/api/v1/namespaces/%s/endpoints/%s", url.PathEscape(w.Namespace), url.PathEscape(w.Service))
+	resp, err := w.get(ctx, path)
+	if err != nil {
+		return err
+	}
+	err = json.NewDecoder(resp.Body).Decode(&current)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("invalid endpoints: %w", err)
+	}
+	update(w.backends(current))
+
+	path = fmt.Sprintf("/api/v1/namespaces/%s/endpoints?watch=true&fieldSelector=%s&resourceVersion=%s",
+		url.PathEscape(w.Namespace), url.QueryEscape("metadata.name="+w.Service), url.QueryEscape(current.Metadata.ResourceVersion))
+	resp, err = w.get(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var ev struct {
+			Type   string          `json:"type"`
+			Object json.RawMessage `json:"object"`
+		}
+		if err := dec.Decode(&ev); err != nil {
+			if err == io.EOF {
+				// The API server ends watches after a timeout
+				return nil
+			}
+			return err
+		}
+		switch ev.Type {
+		case "ADDED", "MODIFIED":
+			var ep endpoints
+			if err := json.Unmarshal(ev.Object, &ep); err != nil {
+				return fmt.Errorf("invalid endpoints: %w", err)
+			}
+			update(w.backends(ep))
+		case "DELETED":
+			update(nil)
+		case "ERROR":
+			// Usually "resource version too old"; list again
+			return fmt.Errorf("watch error: %s", ev.Object)
+		}
+	}
+}
+
+// get calls the API server with the service account token, which is read
+// for every request because Kubernetes rotates it
+func (w *KubernetesWatcher) get(ctx context.Context, path string) (*http.Response, error) {
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.api+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// backends builds backend URLs from the ready addresses and selected port
+func (w *KubernetesWatcher) backends(ep endpoints) []string {
+	var out []string
+	for _, subset := range ep.Subsets {
+		port := 0
+		for _, p := range subset.Ports {
+			if w.Port == "" || p.Name == w.Port {
+				port = p.Port
+				break
+			}
+		}
+		if port == 0 {
+			continue
+		}
+		for _, addr := range subset.Addresses {
+			out = append(out, fmt.Sprintf("%s://%s", w.Scheme, net.JoinHostPort(addr.IP, strconv.Itoa(port))))
+		}
+	}
+	return out
+}
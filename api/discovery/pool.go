@@ -0,0 +1,164 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoBackends is returned when discovery has found no ML backends
+var ErrNoBackends = errors.New("no ML backends available")
+
+// Pool is the set of ML backend base URLs, used in round-robin order
+type Pool struct {
+	mu       sync.RWMutex
+	backends []string
+	updated  time.Time
+	next     uint64
+}
+
+// NewPool creates a pool with the given backends
+func NewPool(backends ...string) *Pool {
+	p := &Pool{}
+	p.Set(backends)
+	return p
+}
+
+// Next returns the backend to send the next request to
+func (p *Pool) Next() (string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if len(p.backends) == 0 {
+		return "", ErrNoBackends
+	}
+	n := atomic.AddUint64(&p.next, 1)
+	return p.backends[(n-1)%uint64(len(p.backends))], nil
+}
+
+// Pick returns the backend for an affinity key, such as a client's API key,
+// so that a client's requests keep landing on the same backend. It uses
+// rendezvous hashing: each backend is scored by a hash of it and the key,
+// and the highest wins, so adding or removing a backend only moves the keys
+// that scored highest on it. An empty key picks the next backend.
+func (p *Pool) Pick(key string) (string, error) {
+	if key == "" {
+		return p.Next()
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if len(p.backends) == 0 {
+		return "", ErrNoBackends
+	}
+	var best string
+	var bestScore uint64
+	for _, b := range p.backends {
+		h := fnv.New64a()
+		h.Write([]byte(b))
+		h.Write([]byte{0})
+		h.Write([]byte(key))
+		if score := h.Sum64(); best == "" || score > bestScore {
+			best, bestScore = b, score
+		}
+	}
+	return best, nil
+}
+
+// Set replaces the backends, reporting whether they changed
+func (p *Pool) Set(backends []string) bool {
+	unique := make([]string, 0, len(backends))
+	seen := make(map[string]bool, len(backends))
+	for _, b := range backends {
+		if !seen[b] {
+			seen[b] = true
+			unique = append(unique, b)
+		}
+	}
+	sort.Strings(unique)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if equal(p.backends, unique) && !p.updated.IsZero() {
+		return false
+	}
+	p.backends = unique
+	p.updated = time.Now()
+	return true
+}
+
+// Backends returns the current backends and when they last changed
+func (p *Pool) Backends() ([]string, time.Time) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	out := make([]string, len(p.backends))
+	copy(out, p.backends)
+	return out, p.updated
+}
+
+// ParseBackends decodes a JSON array of backend base URLs, such as
+// ["http://10.0.0.5:5000", "http://10.0.0.6:5000"]
+func ParseBackends(data []byte) ([]string, error) {
+	var backends []string
+	if err := json.Unmarshal(data, &backends); err != nil {
+		return nil, fmt.Errorf("must be a JSON array of URLs: %w", err)
+	}
+	for i, b := range backends {
+		u, err := url.Parse(b)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("backend %q is not an http(s) URL with a host", b)
+		}
+		backends[i] = strings.TrimSuffix(b, "/")
+	}
+	return backends, nil
+}
+
+// Watcher discovers ML backends, calling update with the full backend list
+// whenever it may have changed. Watch blocks until ctx is cancelled;
+// lookup failures are logged and retried, keeping the last known list.
+type Watcher interface {
+	Watch(ctx context.Context, update func(backends []string))
+}
+
+// Run keeps the pool in sync with the watcher in the background
+func Run(ctx context.Context, w Watcher, pool *Pool) {
+	go w.Watch(ctx, func(backends []string) {
+		if pool.Set(backends) {
+			log.Printf("ML backends updated: count=%d backends=%v", len(backends), backends)
+		}
+	})
+}
+
+// backoff is how long watchers wait before retrying a failed lookup
+const backoff = 5 * time.Second
+
+// sleep waits for d or until ctx is cancelled, reporting whether to go on
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,39 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// SRVWatcher resolves backends from DNS SRV records such as
+// _http._tcp.ml-service.default.svc.cluster.local. DNS has no change
+// notifications, so the records are looked up again every Interval.
+type SRVWatcher struct {
+	Name     string
+	Scheme   string
+	Interval time.Duration
+}
+
+// Watch looks up the SRV records until ctx is cancelled
+func (w *SRVWatcher) Watch(ctx context.Context, update func([]string)) {
+	for {
+		_, records, err := net.DefaultResolver.LookupSRV(ctx, "", "", w.Name)
+		if err != nil {
+			log.Printf("SRV lookup for %s failed: %v", w.Name, err)
+		} else {
+			backends := make([]string, 0, len(records))
+			for _, r := range records {
+				host := strings.TrimSuffix(r.Target, ".")
+				backends = append(backends, fmt.Sprintf("%s://%s", w.Scheme, net.JoinHostPort(host, fmt.Sprint(r.Port))))
+			}
+			update(backends)
+		}
+		if !sleep(ctx, w.Interval) {
+			return
+		}
+	}
+}
@@ -0,0 +1,434 @@
+package drift
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bucketsPerWindow is how many time buckets the recent window is split
+// into; the baseline uses buckets of the same size
+const bucketsPerWindow = 12
+
+// topValues is how many of a field's most frequent values a report lists
+const topValues = 10
+
+// psiEpsilon stands in for a share of zero when computing the population
+// stability index, which is undefined for empty bins
+const psiEpsilon = 1e-4
+
+// Thresholds flag a shift between the recent window and the baseline. PSI
+// is the population stability index above which a categorical input's
+// distribution has shifted (0.1 is a moderate and 0.25 a major shift);
+// Shift is the relative change in the mean predicted value or interval
+// width. Neither is flagged until both periods have MinSamples predictions.
+type Thresholds struct {
+	PSI        float64
+	Shift      float64
+	MinSamples int
+}
+
+// Observation is one prediction's inputs and outputs. Categories maps
+// categorical input fields to their values; Value is the predicted value,
+// Width the interval width relative to it, and Month the input period
+// (YYYY-MM), each if known.
+type Observation struct {
+	Categories map[string]string
+	Value      *float64
+	Width      *float64
+	Month      string
+}
+
+// Monitor keeps rolling statistics on predictions per model, comparing the
+// most recent window with the baseline period before it
+type Monitor struct {
+	window     time.Duration
+	baseline   time.Duration
+	bucket     time.Duration
+	thresholds Thresholds
+
+	mu     sync.Mutex
+	models map[string][]*bucket
+
+	// active is the set of alerting model/metric pairs, used only by Watch
+	active map[string]bool
+}
+
+// bucket aggregates the predictions made in one time slice
+type bucket struct {
+	start      time.Time
+	count      int
+	categories map[string]map[string]int
+	value      mean
+	width      mean
+	months     map[string]*mean
+}
+
+type mean struct {
+	sum float64
+	n   int
+}
+
+func (m *mean) add(v float64) {
+	m.sum += v
+	m.n++
+}
+
+func (m mean) value() *float64 {
+	if m.n == 0 {
+		return nil
+	}
+	v := round(m.sum / float64(m.n))
+	return &v
+}
+
+// NewMonitor creates a monitor comparing the last window of predictions
+// with the baseline period before it
+func NewMonitor(window, baseline time.Duration, thresholds Thresholds) *Monitor {
+	bucketSize := window / bucketsPerWindow
+	if bucketSize < time.Second {
+		bucketSize = time.Second
+	}
+	return &Monitor{
+		window:     window,
+		baseline:   baseline,
+		bucket:     bucketSize,
+		thresholds: thresholds,
+		models:     make(map[string][]*bucket),
+		active:     make(map[string]bool),
+	}
+}
+
+// Observe records a prediction for model
+func (m *Monitor) Observe(model string, obs Observation) {
+	now := time.Now()
+	start := now.Truncate(m.bucket)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	buckets := m.models[model]
+	var b *bucket
+	if n := len(buckets); n > 0 && buckets[n-1].start.Equal(start) {
+		b = buckets[n-1]
+	} else {
+		b = &bucket{start: start, categories: make(map[string]map[string]int), months: make(map[string]*mean)}
+		buckets = append(m.prune(buckets, now), b)
+		m.models[model] = buckets
+	}
+
+	b.count++
+	for field, value := range obs.Categories {
+		counts := b.categories[field]
+		if counts == nil {
+			counts = make(map[string]int)
+			b.categories[field] = counts
+		}
+		counts[value]++
+	}
+	if obs.Value != nil {
+		b.value.add(*obs.Value)
+		if obs.Month != "" {
+			acc := b.months[obs.Month]
+			if acc == nil {
+				acc = &mean{}
+				b.months[obs.Month] = acc
+			}
+			acc.add(*obs.Value)
+		}
+	}
+	if obs.Width != nil {
+		b.width.add(*obs.Width)
+	}
+}
+
+// prune drops buckets older than the baseline period
+func (m *Monitor) prune(buckets []*bucket, now time.Time) []*bucket {
+	cutoff := now.Add(-m.window - m.baseline)
+	i := 0
+	for i < len(buckets) && buckets[i].start.Add(m.bucket).Before(cutoff) {
+		i++
+	}
+	return buckets[i:]
+}
+
+// Report is the drift report for every model
+type Report struct {
+	Window      string                 `json:"window"`
+	Baseline    string                 `json:"baseline"`
+	GeneratedAt string                 `json:"generated_at"`
+	Thresholds  ReportThresholds       `json:"thresholds"`
+	Models      map[string]ModelReport `json:"models"`
+}
+
+// ReportThresholds echoes the configured alert thresholds
+type ReportThresholds struct {
+	PSI        float64 `json:"psi"`
+	Shift      float64 `json:"shift"`
+	MinSamples int     `json:"min_samples"`
+}
+
+// ModelReport compares a model's recent predictions with its baseline
+type ModelReport struct {
+	Window     PeriodStats           `json:"window"`
+	Baseline   PeriodStats           `json:"baseline"`
+	Inputs     map[string]InputDrift `json:"inputs,omitempty"`
+	ValueShift *float64              `json:"value_shift,omitempty"`
+	WidthShift *float64              `json:"width_shift,omitempty"`
+	ByMonth    []MonthStats          `json:"by_month,omitempty"`
+	Alerts     []Alert               `json:"alerts"`
+}
+
+// PeriodStats summarizes the predictions in one period
+type PeriodStats struct {
+	Count     int      `json:"count"`
+	MeanValue *float64 `json:"mean_value,omitempty"`
+	MeanWidth *float64 `json:"mean_width,omitempty"`
+}
+
+// InputDrift is how far a categorical input's distribution has moved
+type InputDrift struct {
+	PSI float64      `json:"psi"`
+	Top []ValueShare `json:"top"`
+}
+
+// ValueShare is one value's share of predictions in each period
+type ValueShare struct {
+	Value    string  `json:"value"`
+	Window   float64 `json:"window"`
+	Baseline float64 `json:"baseline"`
+}
+
+// MonthStats is the mean predicted value for one input month, over the
+// window and baseline together
+type MonthStats struct {
+	Month     string  `json:"month"`
+	Count     int     `json:"count"`
+	MeanValue float64 `json:"mean_value"`
+}
+
+// Alert is a metric past its threshold
+type Alert struct {
+	Metric    string  `json:"metric"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	Message   string  `json:"message"`
+}
+
+// Report compares each model's recent window with its baseline
+func (m *Monitor) Report() Report {
+	now := time.Now()
+	report := Report{
+		Window:      m.window.String(),
+		Baseline:    m.baseline.String(),
+		GeneratedAt: now.Format(time.RFC3339),
+		Thresholds:  ReportThresholds{PSI: m.thresholds.PSI, Shift: m.thresholds.Shift, MinSamples: m.thresholds.MinSamples},
+		Models:      make(map[string]ModelReport),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	split := now.Add(-m.window)
+	for model, buckets := range m.models {
+		buckets = m.prune(buckets, now)
+		m.models[model] = buckets
+		if len(buckets) == 0 {
+			delete(m.models, model)
+			continue
+		}
+		var recent, base []*bucket
+		for _, b := range buckets {
+			if b.start.Before(split) {
+				base = append(base, b)
+			} else {
+				recent = append(recent, b)
+			}
+		}
+		report.Models[model] = m.compare(recent, base, buckets)
+	}
+	return report
+}
+
+// compare builds a model report from its recent and baseline buckets
+func (m *Monitor) compare(recent, base, all []*bucket) ModelReport {
+	w, b := merge(recent), merge(base)
+	r := ModelReport{
+		Window:   PeriodStats{Count: w.count, MeanValue: w.value.value(), MeanWidth: w.width.value()},
+		Baseline: PeriodStats{Count: b.count, MeanValue: b.value.value(), MeanWidth: b.width.value()},
+		Alerts:   []Alert{},
+	}
+	enough := w.count >= m.thresholds.MinSamples && b.count >= m.thresholds.MinSamples
+
+	fields := make([]string, 0, len(w.categories))
+	for field := range w.categories {
+		fields = append(fields, field)
+	}
+	for field := range b.categories {
+		if _, ok := w.categories[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	if len(fields) > 0 {
+		r.Inputs = make(map[string]InputDrift, len(fields))
+	}
+	for _, field := range fields {
+		drift := inputDrift(w.categories[field], b.categories[field], w.count, b.count)
+		r.Inputs[field] = drift
+		if enough && m.thresholds.PSI > 0 && drift.PSI > m.thresholds.PSI {
+			r.Alerts = append(r.Alerts, Alert{
+				Metric:    "inputs." + field,
+				Value:     drift.PSI,
+				Threshold: m.thresholds.PSI,
+				Message:   fmt.Sprintf("Distribution of %s has shifted (PSI %.3f)", field, drift.PSI),
+			})
+		}
+	}
+
+	r.ValueShift = shift(r.Window.MeanValue, r.Baseline.MeanValue)
+	r.WidthShift = shift(r.Window.MeanWidth, r.Baseline.MeanWidth)
+	for _, s := range []struct {
+		metric, label string
+		shift         *float64
+	}{
+		{"value_shift", "Mean predicted value", r.ValueShift},
+		{"width_shift", "Mean interval width", r.WidthShift},
+	} {
+		if enough && s.shift != nil && m.thresholds.Shift > 0 && math.Abs(*s.shift) > m.thresholds.Shift {
+			r.Alerts = append(r.Alerts, Alert{
+				Metric:    s.metric,
+				Value:     *s.shift,
+				Threshold: m.thresholds.Shift,
+				Message:   fmt.Sprintf("%s changed by %+.1f%%", s.label, *s.shift*100),
+			})
+		}
+	}
+
+	months := merge(all).months
+	for month, acc := range months {
+		r.ByMonth = append(r.ByMonth, MonthStats{Month: month, Count: acc.n, MeanValue: round(acc.sum / float64(acc.n))})
+	}
+	sort.Slice(r.ByMonth, func(i, j int) bool { return r.ByMonth[i].Month < r.ByMonth[j].Month })
+	return r
+}
+
+// inputDrift computes a categorical field's PSI and its top values
+func inputDrift(window, baseline map[string]int, wTotal, bTotal int) InputDrift {
+	values := make(map[string]bool)
+	for v := range window {
+		values[v] = true
+	}
+	for v := range baseline {
+		values[v] = true
+	}
+
+	shares := make([]ValueShare, 0, len(values))
+	var psi float64
+	for v := range values {
+		p, q := share(window[v], wTotal), share(baseline[v], bTotal)
+		shares = append(shares, ValueShare{Value: v, Window: round(p), Baseline: round(q)})
+		if wTotal > 0 && bTotal > 0 {
+			pp, qq := math.Max(p, psiEpsilon), math.Max(q, psiEpsilon)
+			psi += (pp - qq) * math.Log(pp/qq)
+		}
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].Window != shares[j].Window {
+			return shares[i].Window > shares[j].Window
+		}
+		return shares[i].Value < shares[j].Value
+	})
+	if len(shares) > topValues {
+		shares = shares[:topValues]
+	}
+	return InputDrift{PSI: round(psi), Top: shares}
+}
+
+// merge sums buckets into one
+func merge(buckets []*bucket) *bucket {
+	total := &bucket{categories: make(map[string]map[string]int), months: make(map[string]*mean)}
+	for _, b := range buckets {
+		total.count += b.count
+		for field, counts := range b.categories {
+			dst := total.categories[field]
+			if dst == nil {
+				dst = make(map[string]int)
+				total.categories[field] = dst
+			}
+			for v, n := range counts {
+				dst[v] += n
+			}
+		}
+		total.value.sum += b.value.sum
+		total.value.n += b.value.n
+		total.width.sum += b.width.sum
+		total.width.n += b.width.n
+		for month, acc := range b.months {
+			dst := total.months[month]
+			if dst == nil {
+				dst = &mean{}
+				total.months[month] = dst
+			}
+			dst.sum += acc.sum
+			dst.n += acc.n
+		}
+	}
+	return total
+}
+
+// shift is the relative change from baseline to window
+func shift(window, baseline *float64) *float64 {
+	if window == nil || baseline == nil || *baseline == 0 {
+		return nil
+	}
+	v := round((*window - *baseline) / math.Abs(*baseline))
+	return &v
+}
+
+func share(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+func round(v float64) float64 {
+	return math.Round(v*1e4) / 1e4
+}
+
+// Watch evaluates the report every bucket until ctx is done, calling
+// onAlert when a metric first crosses its threshold and onResolve when it
+// falls back
+func (m *Monitor) Watch(ctx context.Context, onAlert func(model string, a Alert), onResolve func(model, metric string)) {
+	ticker := time.NewTicker(m.bucket)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current := make(map[string]bool)
+		for model, r := range m.Report().Models {
+			for _, a := range r.Alerts {
+				key := model + "\x00" + a.Metric
+				current[key] = true
+				if !m.active[key] {
+					onAlert(model, a)
+				}
+			}
+		}
+		for key := range m.active {
+			if !current[key] {
+				model, metric, _ := strings.Cut(key, "\x00")
+				onResolve(model, metric)
+			}
+		}
+		m.active = current
+	}
+}
@@ -0,0 +1,100 @@
+package dynconfig
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConsulSource reads settings from Consul KV under Prefix, using blocking
+// queries so changes arrive as soon as Consul sees them
+type ConsulSource struct {
+	Addr   string
+	Token  string
+	Prefix string
+}
+
+// consulWait is how long a blocking query may wait for a change
+const consulWait = 5 * time.Minute
+
+// Watch runs blocking queries until ctx is cancelled
+func (s *ConsulSource) Watch(ctx context.Context, update func(map[string][]byte)) {
+	client := &http.Client{Timeout: consulWait + 30*time.Second}
+	var index uint64
+	for {
+		values, next, err := s.query(ctx, client, index)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Consul KV read of %s failed: %v", s.Prefix, err)
+			index = 0
+			if !sleep(ctx, backoff) {
+				return
+			}
+			continue
+		}
+		update(values)
+
+		// A lower index means Consul's state was reset; start over. Without
+		// an index the query cannot block, so fall back to polling.
+		if next < index {
+			next = 0
+		}
+		index = next
+		if index == 0 && !sleep(ctx, backoff) {
+			return
+		}
+	}
+}
+
+// query reads every key under the prefix, waiting for a change past index
+func (s *ConsulSource) query(ctx context.Context, client *http.Client, index uint64) (map[string][]byte, uint64, error) {
+	u := fmt.Sprintf("%s/v1/kv/%s?recurse=true&index=%d&wait=%s", s.Addr, s.Prefix, index, consulWait)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if s.Token != "" {
+		req.Header.Set("X-Consul-Token", s.Token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	next, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	values := make(map[string][]byte)
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		// No keys under the prefix
+		return values, next, nil
+	default:
+		return nil, 0, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	// Consul returns values base64-encoded; []byte decodes them
+	var entries []struct {
+		Key   string `json:"Key"`
+		Value []byte `json:"Value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, 0, fmt.Errorf("invalid response format: %w", err)
+	}
+	for _, e := range entries {
+		key := strings.TrimPrefix(e.Key, s.Prefix)
+		if key == "" || strings.HasSuffix(key, "/") {
+			continue
+		}
+		values[key] = e.Value
+	}
+	return values, next, nil
+}
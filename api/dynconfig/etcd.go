@@ -0,0 +1,161 @@
+package dynconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// EtcdSource reads settings from etcd v3 under Prefix through etcd's
+// JSON gateway (/v3/kv/range and /v3/watch). Endpoints are tried in turn.
+// After each read it watches the prefix and reads it again on any change.
+type EtcdSource struct {
+	Endpoints []string
+	Prefix    string
+
+	client http.Client
+}
+
+// Watch reads and watches the prefix until ctx is cancelled
+func (s *EtcdSource) Watch(ctx context.Context, update func(map[string][]byte)) {
+	for i := 0; ; i++ {
+		endpoint := s.Endpoints[i%len(s.Endpoints)]
+		if err := s.readAndWatch(ctx, endpoint, update); err != nil && ctx.Err() == nil {
+			log.Printf("etcd read of %s from %s failed: %v", s.Prefix, endpoint, err)
+		}
+		if !sleep(ctx, backoff) {
+			return
+		}
+	}
+}
+
+// readAndWatch reads the prefix, then reads it again each time the watch
+// reports changes, until the watch ends
+func (s *EtcdSource) readAndWatch(ctx context.Context, endpoint string, update func(map[string][]byte)) error {
+	values, revision, err := s.read(ctx, endpoint)
+	if err != nil {
+		return err
+	}
+	update(values)
+
+	resp, err := s.post(ctx, endpoint, "/v3/watch", map[string]interface{}{
+		"create_request": map[string]interface{}{
+			"key":            []byte(s.Prefix),
+			"range_end":      prefixEnd(s.Prefix),
+			"start_revision": strconv.FormatInt(revision+1, 10),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Result struct {
+				Events   []json.RawMessage `json:"events"`
+				Canceled bool              `json:"canceled"`
+			} `json:"result"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := dec.Decode(&msg); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if msg.Error != nil {
+			return fmt.Errorf("watch error: %s", msg.Error.Message)
+		}
+		if msg.Result.Canceled {
+			// Usually a compacted revision; read again
+			return fmt.Errorf("watch canceled")
+		}
+		if len(msg.Result.Events) == 0 {
+			continue
+		}
+		if values, _, err = s.read(ctx, endpoint); err != nil {
+			return err
+		}
+		update(values)
+	}
+}
+
+// read returns every key under the prefix and the store revision
+func (s *EtcdSource) read(ctx context.Context, endpoint string) (map[string][]byte, int64, error) {
+	resp, err := s.post(ctx, endpoint, "/v3/kv/range", map[string]interface{}{
+		"key":       []byte(s.Prefix),
+		"range_end": prefixEnd(s.Prefix),
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	// etcd's JSON gateway base64-encodes keys and values and quotes int64s
+	var body struct {
+		Header struct {
+			Revision string `json:"revision"`
+		} `json:"header"`
+		KVs []struct {
+			Key   []byte `json:"key"`
+			Value []byte `json:"value"`
+		} `json:"kvs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, 0, fmt.Errorf("invalid response format: %w", err)
+	}
+
+	values := make(map[string][]byte, len(body.KVs))
+	for _, kv := range body.KVs {
+		if key := strings.TrimPrefix(string(kv.Key), s.Prefix); key != "" {
+			values[key] = kv.Value
+		}
+	}
+	revision, _ := strconv.ParseInt(body.Header.Revision, 10, 64)
+	return values, revision, nil
+}
+
+func (s *EtcdSource) post(ctx context.Context, endpoint, path string, body interface{}) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// prefixEnd is the range end covering every key that starts with prefix
+func prefixEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// Every byte is 0xff: range to the end of the keyspace
+	return []byte{0}
+}
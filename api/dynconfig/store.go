@@ -0,0 +1,112 @@
+package dynconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Source reads the settings stored under a key prefix, calling update
+// with every key (prefix removed) and value whenever they may have
+// changed. Watch blocks until ctx is cancelled; read failures are logged
+// and retried, keeping the last known settings.
+type Source interface {
+	Watch(ctx context.Context, update func(values map[string][]byte))
+}
+
+// Store holds the latest dynamic settings and notifies subscribers when a
+// setting changes, so every replica watching the same source converges on
+// the same values
+type Store struct {
+	mu          sync.Mutex
+	values      map[string][]byte
+	updated     time.Time
+	subscribers map[string][]func(value []byte)
+}
+
+// NewStore creates an empty store
+func NewStore() *Store {
+	return &Store{
+		values:      make(map[string][]byte),
+		subscribers: make(map[string][]func([]byte)),
+	}
+}
+
+// OnChange calls fn with a setting's new value whenever it changes, and
+// with nil when the setting is removed. Subscribe before Run.
+func (s *Store) OnChange(key string, fn func(value []byte)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribers[key] = append(s.subscribers[key], fn)
+}
+
+// Apply replaces the settings, notifying subscribers of changed keys
+func (s *Store) Apply(values map[string][]byte) {
+	s.mu.Lock()
+	var changed []string
+	for key, value := range values {
+		if old, ok := s.values[key]; !ok || !bytes.Equal(old, value) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range s.values {
+		if _, ok := values[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	s.values = values
+	s.updated = time.Now()
+	calls := make(map[string][]func([]byte), len(changed))
+	for _, key := range changed {
+		calls[key] = s.subscribers[key]
+	}
+	s.mu.Unlock()
+
+	sort.Strings(changed)
+	for _, key := range changed {
+		log.Printf("Dynamic config changed: key=%s", key)
+		for _, fn := range calls[key] {
+			fn(values[key])
+		}
+	}
+}
+
+// Snapshot returns the current settings, decoded where they are JSON, and
+// when they were last read
+func (s *Store) Snapshot() (map[string]interface{}, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]interface{}, len(s.values))
+	for key, value := range s.values {
+		if json.Valid(value) {
+			out[key] = json.RawMessage(value)
+		} else {
+			out[key] = string(value)
+		}
+	}
+	return out, s.updated
+}
+
+// Run keeps the store in sync with the source in the background
+func Run(ctx context.Context, src Source, store *Store) {
+	go src.Watch(ctx, store.Apply)
+}
+
+// backoff is how long sources wait before retrying a failed read
+const backoff = 5 * time.Second
+
+// sleep waits for d or until ctx is cancelled, reporting whether to go on
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
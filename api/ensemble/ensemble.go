@@ -0,0 +1,135 @@
+package ensemble
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Ways of combining member predictions into one estimate
+const (
+	Mean     = "mean"
+	Median   = "median"
+	Weighted = "weighted"
+)
+
+// Member is one model version or backend in an ensemble. URL overrides the
+// ML service URL and MLPath the model's ml_path; Weight (default 1) is its
+// share of a weighted estimate.
+type Member struct {
+	Name   string  `json:"name"`
+	URL    string  `json:"url,omitempty"`
+	MLPath string  `json:"ml_path,omitempty"`
+	Weight float64 `json:"weight,omitempty"`
+}
+
+// Ensemble is the set of members queried for one model. Value names the
+// response field combined across members, defaulting to the model's
+// interval value; Combine is the default combination method.
+type Ensemble struct {
+	Combine string   `json:"combine,omitempty"`
+	Value   string   `json:"value,omitempty"`
+	Members []Member `json:"members"`
+}
+
+// Load reads ensembles by model name from a JSON file holding
+// {"ensembles": {"<model>": {...}}}
+func Load(path string) (map[string]*Ensemble, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ensemble file: %w", err)
+	}
+	var file struct {
+		Ensembles map[string]*Ensemble `json:"ensembles"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse ensemble file: %w", err)
+	}
+
+	for model, e := range file.Ensembles {
+		if e == nil || len(e.Members) < 2 {
+			return nil, fmt.Errorf("ensemble %q: at least two members are required", model)
+		}
+		if e.Combine == "" {
+			e.Combine = Mean
+		}
+		if !ValidCombine(e.Combine) {
+			return nil, fmt.Errorf("ensemble %q: combine must be mean, median or weighted", model)
+		}
+		seen := make(map[string]bool)
+		for i := range e.Members {
+			m := &e.Members[i]
+			if m.Name == "" {
+				return nil, fmt.Errorf("ensemble %q: member %d has no name", model, i+1)
+			}
+			if seen[m.Name] {
+				return nil, fmt.Errorf("ensemble %q: member %q declared more than once", model, m.Name)
+			}
+			seen[m.Name] = true
+			if m.URL != "" {
+				if u, err := url.Parse(m.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+					return nil, fmt.Errorf("ensemble %q: member %q: url must be an http(s) URL", model, m.Name)
+				}
+				m.URL = strings.TrimRight(m.URL, "/")
+			}
+			if m.MLPath != "" && !strings.HasPrefix(m.MLPath, "/") {
+				return nil, fmt.Errorf("ensemble %q: member %q: ml_path must start with '/'", model, m.Name)
+			}
+			if m.Weight < 0 {
+				return nil, fmt.Errorf("ensemble %q: member %q: weight must be positive", model, m.Name)
+			}
+			if m.Weight == 0 {
+				m.Weight = 1
+			}
+		}
+	}
+	return file.Ensembles, nil
+}
+
+// ValidCombine reports whether method is a known combination method
+func ValidCombine(method string) bool {
+	return method == Mean || method == Median || method == Weighted
+}
+
+// Combine merges member values into one estimate; weights apply only to
+// the weighted method. values must not be empty.
+func Combine(method string, values, weights []float64) float64 {
+	switch method {
+	case Median:
+		sorted := append([]float64(nil), values...)
+		sort.Float64s(sorted)
+		mid := len(sorted) / 2
+		if len(sorted)%2 == 0 {
+			return (sorted[mid-1] + sorted[mid]) / 2
+		}
+		return sorted[mid]
+	case Weighted:
+		var sum, total float64
+		for i, v := range values {
+			sum += v * weights[i]
+			total += weights[i]
+		}
+		if total > 0 {
+			return sum / total
+		}
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// StdDev is the population standard deviation of values
+func StdDev(values []float64) float64 {
+	mean := Combine(Mean, values, nil)
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(sq / float64(len(values)))
+}
@@ -0,0 +1,91 @@
+package errorreport
+
+import (
+	"strings"
+	"time"
+)
+
+// Event is an error, panic or server error response to report
+type Event struct {
+	Message   string
+	Err       error
+	Panic     interface{}
+	Stack     []byte
+	Method    string
+	Route     string
+	Path      string
+	RequestID string
+	Status    int
+	Model     string
+	Payload   map[string]interface{}
+}
+
+// Reporter sends events to an error tracker. Capture must not block the
+// request path.
+type Reporter interface {
+	Capture(ev Event)
+	Flush(timeout time.Duration) bool
+}
+
+// Nop discards all events
+type Nop struct{}
+
+// Capture discards the event
+func (Nop) Capture(Event) {}
+
+// Flush does nothing
+func (Nop) Flush(time.Duration) bool { return true }
+
+// maxStringLength caps string values in sanitized payloads
+const maxStringLength = 256
+
+// sensitiveKeys are payload key fragments whose values are never reported
+var sensitiveKeys = []string{"password", "secret", "token", "key", "auth", "credential", "email", "phone"}
+
+// Sanitize returns a copy of a payload that is safe to send to an error
+// tracker: values under sensitive-looking keys are redacted and long
+// strings are truncated
+func Sanitize(payload map[string]interface{}) map[string]interface{} {
+	if payload == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(payload))
+	for k, v := range payload {
+		if sensitive(k) {
+			out[k] = "[REDACTED]"
+			continue
+		}
+		out[k] = sanitizeValue(v)
+	}
+	return out
+}
+
+func sanitizeValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return Sanitize(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = sanitizeValue(item)
+		}
+		return out
+	case string:
+		if len(v) > maxStringLength {
+			return v[:maxStringLength] + "..."
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func sensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
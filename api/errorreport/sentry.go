@@ -0,0 +1,84 @@
+package errorreport
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// Sentry reports events to Sentry. Events are queued and sent by the SDK's
+// background transport.
+type Sentry struct {
+	hub *sentry.Hub
+}
+
+// SentryConfig configures the Sentry reporter
+type SentryConfig struct {
+	DSN         string
+	Environment string
+	Release     string
+	SampleRate  float64
+}
+
+// NewSentry creates a reporter for the given DSN
+func NewSentry(cfg SentryConfig) (*Sentry, error) {
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:              cfg.DSN,
+		Environment:      cfg.Environment,
+		Release:          cfg.Release,
+		SampleRate:       cfg.SampleRate,
+		AttachStacktrace: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Sentry client: %w", err)
+	}
+	return &Sentry{hub: sentry.NewHub(client, sentry.NewScope())}, nil
+}
+
+// Capture sends the event with its request context as tags and extras
+func (s *Sentry) Capture(ev Event) {
+	s.hub.WithScope(func(scope *sentry.Scope) {
+		if ev.Route != "" {
+			scope.SetTag("route", ev.Method+" "+ev.Route)
+		}
+		if ev.RequestID != "" {
+			scope.SetTag("request_id", ev.RequestID)
+		}
+		if ev.Model != "" {
+			scope.SetTag("model", ev.Model)
+		}
+		if ev.Status != 0 {
+			scope.SetTag("status", fmt.Sprint(ev.Status))
+		}
+		if ev.Path != "" {
+			scope.SetExtra("path", ev.Path)
+		}
+		if ev.Payload != nil {
+			scope.SetExtra("payload", Sanitize(ev.Payload))
+		}
+		if ev.Stack != nil {
+			scope.SetExtra("stack", string(ev.Stack))
+		}
+
+		switch {
+		case ev.Panic != nil:
+			scope.SetLevel(sentry.LevelFatal)
+			s.hub.CaptureException(fmt.Errorf("panic: %v", ev.Panic))
+		case ev.Err != nil:
+			scope.SetLevel(sentry.LevelError)
+			if ev.Message != "" {
+				scope.SetExtra("message", ev.Message)
+			}
+			s.hub.CaptureException(ev.Err)
+		default:
+			scope.SetLevel(sentry.LevelError)
+			s.hub.CaptureMessage(ev.Message)
+		}
+	})
+}
+
+// Flush waits up to timeout for queued events to be sent
+func (s *Sentry) Flush(timeout time.Duration) bool {
+	return s.hub.Flush(timeout)
+}
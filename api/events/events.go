@@ -0,0 +1,50 @@
+package events
+
+import (
+	"time"
+)
+
+// SchemaVersion is the version of the PredictionEvent schema. It is bumped
+// whenever a field is removed or changes meaning; new fields may be added
+// without a bump.
+const SchemaVersion = 1
+
+// PredictionEvent is published for every successful prediction
+type PredictionEvent struct {
+	SchemaVersion int                    `json:"schema_version"`
+	EventID       string                 `json:"event_id"`
+	Timestamp     time.Time              `json:"timestamp"`
+	Model         string                 `json:"model"`
+	ModelVersion  string                 `json:"model_version,omitempty"`
+	Request       map[string]interface{} `json:"request"`
+	Response      map[string]interface{} `json:"response"`
+	LatencyMs     float64                `json:"latency_ms"`
+}
+
+// Publisher emits prediction events to an external system. Publish must
+// not block the prediction path.
+type Publisher interface {
+	Publish(ev PredictionEvent)
+	Close() error
+}
+
+// Nop discards all events
+type Nop struct{}
+
+// Publish discards the event
+func (Nop) Publish(PredictionEvent) {}
+
+// Close does nothing
+func (Nop) Close() error { return nil }
+
+// ModelVersion extracts the model version reported by the ML service,
+// preferring an explicit model_version over the model name
+func ModelVersion(response map[string]interface{}) string {
+	if v, ok := response["model_version"].(string); ok && v != "" {
+		return v
+	}
+	if v, ok := response["model"].(string); ok {
+		return v
+	}
+	return ""
+}
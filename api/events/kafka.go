@@ -0,0 +1,74 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaPublisher writes prediction events to a Kafka topic as JSON,
+// keyed by model name so a model's events stay ordered within a partition
+type KafkaPublisher struct {
+	writer  *kafka.Writer
+	brokers []string
+}
+
+// NewKafkaPublisher creates a publisher for the given brokers and topic.
+// Writes are asynchronous and batched; failures are logged.
+func NewKafkaPublisher(brokers []string, topic string) *KafkaPublisher {
+	return &KafkaPublisher{
+		brokers: brokers,
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			BatchTimeout: 50 * time.Millisecond,
+			RequiredAcks: kafka.RequireOne,
+			Async:        true,
+			Completion: func(messages []kafka.Message, err error) {
+				if err != nil {
+					log.Printf("Failed to publish %d prediction events: %v", len(messages), err)
+				}
+			},
+		},
+	}
+}
+
+// Publish queues the event for delivery
+func (p *KafkaPublisher) Publish(ev PredictionEvent) {
+	value, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("Failed to marshal prediction event: %v", err)
+		return
+	}
+
+	// Async writers return immediately; delivery errors go to Completion
+	if err := p.writer.WriteMessages(context.Background(), kafka.Message{
+		Key:   []byte(ev.Model),
+		Value: value,
+		Time:  ev.Timestamp,
+	}); err != nil {
+		log.Printf("Failed to queue prediction event: %v", err)
+	}
+}
+
+// Ping checks that at least one broker accepts connections
+func (p *KafkaPublisher) Ping(ctx context.Context) error {
+	var err error
+	for _, broker := range p.brokers {
+		var conn *kafka.Conn
+		if conn, err = kafka.DialContext(ctx, "tcp", broker); err == nil {
+			return conn.Close()
+		}
+	}
+	return fmt.Errorf("no Kafka broker reachable: %w", err)
+}
+
+// Close flushes pending events and closes the writer
+func (p *KafkaPublisher) Close() error {
+	return p.writer.Close()
+}
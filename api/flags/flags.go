@@ -0,0 +1,127 @@
+package flags
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Flag is a feature flag's state: on or off by default, with overrides for
+// particular tenants (X-Tenant-ID) and API keys (X-API-Key). An API key
+// override wins over a tenant override.
+type Flag struct {
+	Enabled bool            `json:"enabled"`
+	Tenants map[string]bool `json:"tenants,omitempty"`
+	Keys    map[string]bool `json:"keys,omitempty"`
+}
+
+// Caller identifies who a flag is evaluated for; either field may be empty
+type Caller struct {
+	Tenant string
+	APIKey string
+}
+
+// Set holds the feature flags. Flags not defined anywhere are off.
+type Set struct {
+	mu       sync.RWMutex
+	defaults map[string]Flag
+	flags    map[string]Flag
+}
+
+// NewSet creates a set with the built-in defaults for each flag
+func NewSet(defaults map[string]Flag) *Set {
+	s := &Set{defaults: defaults}
+	s.Replace(nil)
+	return s
+}
+
+// Replace sets the flags from configuration on top of the defaults; nil
+// restores the defaults
+func (s *Set) Replace(flags map[string]Flag) {
+	merged := make(map[string]Flag, len(s.defaults)+len(flags))
+	for name, f := range s.defaults {
+		merged[name] = f
+	}
+	for name, f := range flags {
+		merged[name] = f
+	}
+	s.mu.Lock()
+	s.flags = merged
+	s.mu.Unlock()
+}
+
+// Enabled evaluates a flag for a caller
+func (s *Set) Enabled(name string, caller Caller) bool {
+	s.mu.RLock()
+	f, ok := s.flags[name]
+	s.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	if on, ok := f.Keys[caller.APIKey]; ok && caller.APIKey != "" {
+		return on
+	}
+	if on, ok := f.Tenants[caller.Tenant]; ok && caller.Tenant != "" {
+		return on
+	}
+	return f.Enabled
+}
+
+// Snapshot returns the current flags with API keys in overrides masked
+func (s *Set) Snapshot() map[string]Flag {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string]Flag, len(s.flags))
+	for name, f := range s.flags {
+		masked := Flag{Enabled: f.Enabled, Tenants: f.Tenants}
+		if len(f.Keys) > 0 {
+			masked.Keys = make(map[string]bool, len(f.Keys))
+			for key, on := range f.Keys {
+				masked.Keys[MaskKey(key)] = on
+			}
+		}
+		out[name] = masked
+	}
+	return out
+}
+
+// Names returns the flag names in order
+func (s *Set) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.flags))
+	for name := range s.flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parse decodes flags from a JSON object of flag name to state, such as
+// {"strict_validation": {"enabled": false, "tenants": {"acme": true}}}
+func Parse(data []byte) (map[string]Flag, error) {
+	var flags map[string]Flag
+	if err := json.Unmarshal(data, &flags); err != nil {
+		return nil, fmt.Errorf("failed to parse feature flags: %w", err)
+	}
+	return flags, nil
+}
+
+// Load reads flags from a JSON file
+func Load(path string) (map[string]Flag, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feature flags: %w", err)
+	}
+	return Parse(data)
+}
+
+// MaskKey keeps the first four characters of an API key
+func MaskKey(key string) string {
+	if len(key) <= 4 {
+		return "****"
+	}
+	return key[:4] + "****"
+}
@@ -0,0 +1,156 @@
+package gatewaytest
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Response is a programmed reply. A zero Status means 200.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   interface{}
+	// Delay holds the reply back, to test client timeouts
+	Delay time.Duration
+}
+
+// Call is a request received by a fake
+type Call struct {
+	Method string
+	Path   string
+	Query  string
+	// Model is the model the request was for, if any
+	Model  string
+	Header http.Header
+	// Body is the decoded JSON request body, if it was a JSON object
+	Body map[string]interface{}
+	At   time.Time
+}
+
+// fake holds the programmed responses and recorded calls shared by the
+// gateway and ML service fakes
+type fake struct {
+	mu      sync.Mutex
+	calls   []Call
+	fixed   map[string]Response
+	queued  map[string][]Response
+	handler func(Call) (Response, bool)
+}
+
+func newFake() *fake {
+	return &fake{fixed: make(map[string]Response), queued: make(map[string][]Response)}
+}
+
+// respond sets the reply to every later request for key
+func (f *fake) respond(key string, r Response) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fixed[key] = r
+}
+
+// enqueue adds one-off replies for key, used in order before the fixed one
+func (f *fake) enqueue(key string, rs ...Response) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queued[key] = append(f.queued[key], rs...)
+}
+
+// record stores a call and returns the programmed reply for key, if any
+func (f *fake) record(call Call, key string) (Response, bool) {
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	handler := f.handler
+	if q := f.queued[key]; len(q) > 0 {
+		f.queued[key] = q[1:]
+		f.mu.Unlock()
+		return q[0], true
+	}
+	r, ok := f.fixed[key]
+	f.mu.Unlock()
+	if ok {
+		return r, true
+	}
+	if handler != nil {
+		return handler(call)
+	}
+	return Response{}, false
+}
+
+// recorded returns the calls received, optionally only those for a model
+func (f *fake) recorded(model string) []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var calls []Call
+	for _, c := range f.calls {
+		if model == "" || c.Model == model {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// reset forgets calls and programmed replies
+func (f *fake) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = nil
+	f.fixed = make(map[string]Response)
+	f.queued = make(map[string][]Response)
+	f.handler = nil
+}
+
+// newCall describes a request, decoding a JSON object body
+func newCall(r *http.Request, model string) Call {
+	call := Call{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.RawQuery,
+		Model:  model,
+		Header: r.Header.Clone(),
+		At:     time.Now(),
+	}
+	if r.Body != nil {
+		decoder := json.NewDecoder(r.Body)
+		decoder.UseNumber()
+		var body map[string]interface{}
+		if decoder.Decode(&body) == nil {
+			call.Body = body
+		}
+	}
+	return call
+}
+
+// write sends a reply, encoding its body as JSON unless it is a string or
+// bytes
+func write(w http.ResponseWriter, r Response) {
+	if r.Delay > 0 {
+		time.Sleep(r.Delay)
+	}
+	for name, values := range r.Header {
+		for _, v := range values {
+			w.Header().Add(name, v)
+		}
+	}
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	switch body := r.Body.(type) {
+	case nil:
+		w.WriteHeader(status)
+	case []byte:
+		w.WriteHeader(status)
+		w.Write(body)
+	case string:
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	default:
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+	}
+}
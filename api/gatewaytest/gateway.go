@@ -0,0 +1,198 @@
+package gatewaytest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"cloud-ai-api/models"
+	"cloud-ai-api/registry"
+)
+
+// Server is a fake gateway serving the health, readiness, version and
+// v1/v2 prediction routes. Prediction requests are validated against the
+// model registry like the real gateway and answered with a canned
+// prediction unless a response has been programmed for the model.
+type Server struct {
+	*httptest.Server
+	fake     *fake
+	registry *registry.Registry
+}
+
+// NewServer starts a fake gateway serving the built-in models. Call Close
+// when done.
+func NewServer() *Server {
+	s := &Server{fake: newFake(), registry: registry.Default()}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// UseRegistry replaces the models the fake serves and validates against
+func (s *Server) UseRegistry(reg *registry.Registry) {
+	s.fake.mu.Lock()
+	defer s.fake.mu.Unlock()
+	s.registry = reg
+}
+
+// Respond programs the reply to every later request for a model, or for
+// another route given by its path (e.g. "/api/v1/health"). The reply is
+// sent as is by both API versions and skips validation.
+func (s *Server) Respond(key string, r Response) {
+	s.fake.respond(key, r)
+}
+
+// Enqueue programs one-off replies for a model or path, sent in order
+// before falling back to Respond's reply or the default one, e.g. to
+// return 503 once and then succeed
+func (s *Server) Enqueue(key string, rs ...Response) {
+	s.fake.enqueue(key, rs...)
+}
+
+// Fail programs every later request for a model or path to fail with the
+// gateway's error format
+func (s *Server) Fail(key string, status int, message, details string) {
+	s.fake.respond(key, Response{Status: status, Body: models.ErrorResponse{Error: message, Details: details}})
+}
+
+// HandleFunc sets a function deciding the reply to requests with no
+// programmed reply; returning false falls back to the default one
+func (s *Server) HandleFunc(fn func(Call) (Response, bool)) {
+	s.fake.mu.Lock()
+	defer s.fake.mu.Unlock()
+	s.fake.handler = fn
+}
+
+// Calls returns every request received, oldest first
+func (s *Server) Calls() []Call {
+	return s.fake.recorded("")
+}
+
+// CallsFor returns the prediction requests received for a model
+func (s *Server) CallsFor(model string) []Call {
+	return s.fake.recorded(model)
+}
+
+// Reset forgets recorded calls and programmed replies
+func (s *Server) Reset() {
+	s.fake.reset()
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	version, model, isPredict := predictRoute(r.URL.Path)
+	key := r.URL.Path
+	if isPredict {
+		key = model
+	}
+	call := newCall(r, model)
+	if r.Method == http.MethodGet && isPredict {
+		call.Body = queryPayload(r)
+	}
+	if resp, ok := s.fake.record(call, key); ok {
+		write(w, resp)
+		return
+	}
+
+	switch {
+	case isPredict && (r.Method == http.MethodPost || r.Method == http.MethodGet):
+		status, body := s.predict(call)
+		if version == "v2" {
+			body = envelope(call, status, body)
+		}
+		write(w, Response{Status: status, Body: body})
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/health":
+		write(w, Response{Body: map[string]interface{}{
+			"status":             "healthy",
+			"service":            "Cloud AI API Gateway",
+			"version":            "gatewaytest",
+			"ml_service_healthy": true,
+		}})
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/ready":
+		write(w, Response{Body: models.ReadinessResponse{Ready: true, Checks: []models.ReadinessCheck{}}})
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/version":
+		write(w, Response{Body: models.VersionResponse{Service: "Cloud AI API Gateway", Version: "gatewaytest"}})
+	default:
+		write(w, Response{Status: http.StatusNotFound, Body: models.ErrorResponse{Error: "Not found"}})
+	}
+}
+
+// predict validates a prediction request and returns the status and body
+// the gateway would
+func (s *Server) predict(call Call) (int, interface{}) {
+	s.fake.mu.Lock()
+	reg := s.registry
+	s.fake.mu.Unlock()
+
+	model, ok := reg.Get(call.Model)
+	if !ok {
+		return http.StatusNotFound, models.ErrorResponse{
+			Error:   "Unknown model",
+			Details: fmt.Sprintf("Must be one of: %s", strings.Join(reg.Names(), ", ")),
+		}
+	}
+	if call.Body == nil {
+		return http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format", Details: "body must be a JSON object"}
+	}
+	if errs := model.Validate(call.Body); len(errs) > 0 {
+		resp := models.ErrorResponse{Error: "Invalid " + errs[0].Label, Details: errs[0].Message}
+		for _, e := range errs {
+			resp.Violations = append(resp.Violations, models.Violation{Pointer: e.Pointer, Message: e.Message})
+			if e.Field != "" {
+				resp.Fields = append(resp.Fields, e.Field)
+			}
+		}
+		if errs[0].Missing {
+			resp.Error = "Missing required fields"
+		}
+		return http.StatusBadRequest, resp
+	}
+
+	prediction := cannedPrediction(model.Name)
+	prediction["processing_time_ms"] = 1.0
+	prediction["prediction_time"] = time.Now().Format(time.RFC3339)
+	return http.StatusOK, prediction
+}
+
+// predictRoute reports the API version and model of a prediction path
+func predictRoute(path string) (string, string, bool) {
+	for _, version := range []string{"v1", "v2"} {
+		if model, ok := strings.CutPrefix(path, "/api/"+version+"/predict/"); ok && model != "" && !strings.Contains(model, "/") {
+			return version, model, true
+		}
+	}
+	return "", "", false
+}
+
+// queryPayload maps the query parameters of a GET prediction onto a
+// payload, as strings
+func queryPayload(r *http.Request) map[string]interface{} {
+	payload := make(map[string]interface{})
+	for name, values := range r.URL.Query() {
+		if len(values) > 0 {
+			payload[name] = values[0]
+		}
+	}
+	return payload
+}
+
+// envelope wraps a v1 body in the API v2 envelope
+func envelope(call Call, status int, body interface{}) models.V2Response {
+	resp := models.V2Response{Meta: models.V2Meta{
+		APIVersion: "2",
+		RequestID:  call.Header.Get("X-Request-ID"),
+		Model:      call.Model,
+		Timestamp:  time.Now().Format(time.RFC3339),
+	}}
+	if status < http.StatusBadRequest {
+		resp.Data = body
+		return resp
+	}
+	e, _ := body.(models.ErrorResponse)
+	code := models.CodeValidationFailed
+	if status == http.StatusNotFound {
+		code = models.CodeUnknownModel
+	}
+	resp.Error = &models.V2Error{Code: code, Message: e.Error, Details: e.Details, Fields: e.Fields, Violations: e.Violations}
+	return resp
+}
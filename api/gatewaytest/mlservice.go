@@ -0,0 +1,153 @@
+package gatewaytest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"cloud-ai-api/registry"
+)
+
+// MLServer is a fake ML service serving /health, /models and the
+// prediction path of every model in the registry. Point the gateway's
+// ML_SERVICE_URL at its URL to test the gateway without the Python service.
+type MLServer struct {
+	*httptest.Server
+	fake     *fake
+	registry *registry.Registry
+}
+
+// NewMLServer starts a fake ML service for the built-in models. Call Close
+// when done.
+func NewMLServer() *MLServer {
+	s := &MLServer{fake: newFake(), registry: registry.Default()}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// UseRegistry replaces the models the fake serves
+func (s *MLServer) UseRegistry(reg *registry.Registry) {
+	s.fake.mu.Lock()
+	defer s.fake.mu.Unlock()
+	s.registry = reg
+}
+
+// Respond programs the reply to every later request for a model, or for
+// another route given by its path (e.g. "/health")
+func (s *MLServer) Respond(key string, r Response) {
+	s.fake.respond(key, r)
+}
+
+// Enqueue programs one-off replies for a model or path, sent in order
+// before falling back to Respond's reply or the default one
+func (s *MLServer) Enqueue(key string, rs ...Response) {
+	s.fake.enqueue(key, rs...)
+}
+
+// Fail programs every later request for a model or path to fail with the
+// ML service's error format
+func (s *MLServer) Fail(key string, status int, message string) {
+	s.fake.respond(key, Response{Status: status, Body: map[string]interface{}{"error": message}})
+}
+
+// HandleFunc sets a function deciding the reply to requests with no
+// programmed reply; returning false falls back to the default one
+func (s *MLServer) HandleFunc(fn func(Call) (Response, bool)) {
+	s.fake.mu.Lock()
+	defer s.fake.mu.Unlock()
+	s.fake.handler = fn
+}
+
+// Calls returns every request received, oldest first
+func (s *MLServer) Calls() []Call {
+	return s.fake.recorded("")
+}
+
+// CallsFor returns the prediction requests received for a model
+func (s *MLServer) CallsFor(model string) []Call {
+	return s.fake.recorded(model)
+}
+
+// Reset forgets recorded calls and programmed replies
+func (s *MLServer) Reset() {
+	s.fake.reset()
+}
+
+func (s *MLServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.fake.mu.Lock()
+	reg := s.registry
+	s.fake.mu.Unlock()
+
+	var model *registry.Model
+	for _, m := range reg.Models() {
+		if m.MLPath == r.URL.Path {
+			model = m
+			break
+		}
+	}
+	key := r.URL.Path
+	call := newCall(r, "")
+	if model != nil {
+		key = model.Name
+		call.Model = model.Name
+	}
+	if resp, ok := s.fake.record(call, key); ok {
+		write(w, resp)
+		return
+	}
+
+	switch {
+	case model != nil && r.Method == http.MethodPost:
+		if call.Body == nil {
+			write(w, Response{Status: http.StatusBadRequest, Body: map[string]interface{}{"error": "No input data provided"}})
+			return
+		}
+		write(w, Response{Body: cannedPrediction(model.Name)})
+	case r.Method == http.MethodGet && r.URL.Path == "/health":
+		write(w, Response{Body: map[string]interface{}{
+			"status":    "ok",
+			"service":   "ML Prediction Service",
+			"timestamp": time.Now().Format(time.RFC3339),
+		}})
+	case r.Method == http.MethodGet && r.URL.Path == "/models":
+		available := make(map[string]interface{})
+		for _, m := range reg.Models() {
+			available[m.Name] = map[string]interface{}{
+				"status":     "loaded",
+				"endpoint":   m.MLPath,
+				"model_type": "gatewaytest",
+			}
+		}
+		write(w, Response{Body: map[string]interface{}{"available_models": available}})
+	default:
+		write(w, Response{Status: http.StatusNotFound, Body: map[string]interface{}{"error": "Not found"}})
+	}
+}
+
+// cannedPrediction is the fixed prediction returned for a model, shaped
+// like the ML service's response for the built-in models
+func cannedPrediction(model string) map[string]interface{} {
+	switch model {
+	case "housing":
+		return map[string]interface{}{
+			"price":            250000.0,
+			"price_log":        12.429216,
+			"confidence_lower": 225000.0,
+			"confidence_upper": 275000.0,
+			"model":            "gatewaytest",
+			"features_used":    6,
+		}
+	case "electricity":
+		return map[string]interface{}{
+			"demand_mw": 30000.0,
+			"datetime":  "2024-01-01T12:00:00",
+			"model":     "gatewaytest",
+			"note":      "canned prediction",
+		}
+	default:
+		return map[string]interface{}{
+			"prediction": 1.0,
+			"model":      "gatewaytest",
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"cloud-ai-api/middleware"
+	"cloud-ai-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Abuse bans client IPs with too many failed requests; nil when disabled
+var Abuse *middleware.AbuseGuard
+
+// ListBansHandler lists the client IPs currently banned for abuse
+func ListBansHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"bans": Abuse.Bans()})
+}
+
+// LiftBanHandler handles DELETE /admin/bans/:ip, lifting a ban early
+func LiftBanHandler(c *gin.Context) {
+	ip := c.Param("ip")
+	if !Abuse.Lift(ip) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Ban not found",
+			Details: ip + " is not banned",
+		})
+		return
+	}
+	log.Printf("Ban lifted: ip=%s by=%s", ip, c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{"ip": ip, "status": "lifted"})
+}
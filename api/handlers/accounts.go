@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud-ai-api/accounts"
+	"cloud-ai-api/middleware"
+	"cloud-ai-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Accounts holds self-registered accounts and their API keys, or nil if
+// self-service registration is off
+var Accounts *accounts.Store
+
+// AccountVerifyTTL is how long an emailed verification link is valid
+var AccountVerifyTTL = 24 * time.Hour
+
+// RegistrationsPerAddress and RegistrationsPerIP limit how often
+// registration emails are sent to one address and requested from one
+// client IP, so the gateway cannot be used to flood an inbox
+var (
+	RegistrationsPerAddress = middleware.NewRateLimiter(middleware.RateLimit{RequestsPerSecond: 3.0 / 3600, Burst: 3})
+	RegistrationsPerIP      = middleware.NewRateLimiter(middleware.RateLimit{RequestsPerSecond: 10.0 / 3600, Burst: 10})
+)
+
+// RegisterAccountRequest is the body of POST /api/v1/accounts
+type RegisterAccountRequest struct {
+	Email string `json:"email" binding:"required"`
+	Name  string `json:"name"`
+}
+
+// CreateKeyRequest is the body of POST /api/v1/accounts/keys
+type CreateKeyRequest struct {
+	Name string `json:"name"`
+}
+
+// RegisterAccountHandler handles POST /api/v1/accounts, creating an
+// unverified account and emailing a link to verify it
+func RegisterAccountHandler(c *gin.Context) {
+	var req RegisterAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Details: err.Error(),
+		})
+		return
+	}
+	if addr, err := mail.ParseAddress(req.Email); err != nil || addr.Address != req.Email {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid email",
+			Details: "Must be a bare address such as user@example.com",
+			Fields:  []string{"email"},
+		})
+		return
+	}
+
+	if ok, wait := registrationAllowed(c.ClientIP(), req.Email); !ok {
+		Metrics.Incr("accounts.registrations", "outcome:throttled")
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, models.ErrorResponse{
+			Error:   "Too many registrations",
+			Details: "Too many verification emails requested; retry after the time in the Retry-After header",
+		})
+		return
+	}
+
+	account, token, err := Accounts.Register(req.Email, strings.TrimSpace(req.Name))
+	if errors.Is(err, accounts.ErrExists) {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Account exists",
+			Details: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("WARN account_register_failed error=%q", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to register account"})
+		return
+	}
+
+	link := PublicURL + "/api/v1/accounts/verify?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Verify your account by opening this link within %s:\n\n%s\n\n"+
+		"If you did not register, ignore this email.\n", AccountVerifyTTL, link)
+	if err := Mailer.Send(account.Email, "Verify your account", body); err != nil {
+		Metrics.Incr("accounts.registrations", "outcome:email_failed")
+		log.Printf("WARN account_verify_email_failed account=%s error=%q", account.ID, err)
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{
+			Error:   "Failed to send verification email",
+			Details: "Try registering again later",
+		})
+		return
+	}
+	Metrics.Incr("accounts.registrations", "outcome:sent")
+	c.JSON(http.StatusAccepted, gin.H{
+		"id":     account.ID,
+		"email":  account.Email,
+		"status": "verification_sent",
+	})
+}
+
+// registrationAllowed takes a registration from the client IP's and the
+// address's allowance, returning how long to wait if either is used up
+func registrationAllowed(clientIP, email string) (bool, time.Duration) {
+	if ok, wait := RegistrationsPerIP.Allow("ip:" + clientIP); !ok {
+		return false, wait
+	}
+	return RegistrationsPerAddress.Allow("email:" + strings.ToLower(email))
+}
+
+// verifyPage is the page an emailed verification link opens. Opening the
+// link changes nothing, so mail scanners that follow links cannot use the
+// token up; the form posts it back to verify the account and then shows
+// the account token.
+var verifyPage = template.Must(template.New("verify").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="referrer" content="no-referrer">
+<title>Verify your account</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; color: #222; max-width: 560px; margin: 48px auto; padding: 0 16px; }
+h1 { font-size: 24px; color: #1f77b4; }
+code { display: block; background: #f4f4f4; padding: 12px; word-break: break-all; }
+button { font-size: 16px; padding: 8px 20px; }
+</style>
+</head>
+<body>
+{{if .AccountToken}}<h1>Account verified</h1>
+<p>Your account token for {{.Email}} is shown only once. Send it as <code>Authorization: Bearer {{.AccountToken}}</code> to create API keys.</p>
+{{else if .Token}}<h1>Verify your account</h1>
+<p>Confirm that you registered {{.Email}}.</p>
+<form method="post" action="verify">
+<input type="hidden" name="token" value="{{.Token}}">
+<button type="submit">Verify account</button>
+</form>
+{{else}}<h1>Link invalid</h1>
+<p>The verification link is invalid or has expired; register again for a new one.</p>
+{{end}}</body>
+</html>
+`))
+
+// renderVerifyPage writes the verification page
+func renderVerifyPage(c *gin.Context, status int, data map[string]string) {
+	var buf bytes.Buffer
+	if err := verifyPage.Execute(&buf, data); err != nil {
+		c.String(http.StatusInternalServerError, "failed to render page")
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// VerifyAccountPageHandler handles GET /api/v1/accounts/verify, the
+// emailed link, showing a page that asks the user to confirm. The token is
+// not used up until the page is submitted.
+func VerifyAccountPageHandler(c *gin.Context) {
+	token := c.Query("token")
+	account, ok := Accounts.Pending(token)
+	if !ok {
+		renderVerifyPage(c, http.StatusNotFound, nil)
+		return
+	}
+	renderVerifyPage(c, http.StatusOK, map[string]string{"Token": token, "Email": account.Email})
+}
+
+// VerifyAccountHandler handles POST /api/v1/accounts/verify, verifying an
+// account with the token from its emailed link and returning the account
+// token used to manage its keys. The token is shown only once: as JSON to
+// API clients, or on the page to a browser submitting the form.
+func VerifyAccountHandler(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" form:"token"`
+	}
+	if err := c.ShouldBind(&req); err != nil || req.Token == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Details: "Send the token from the verification link as token",
+			Fields:  []string{"token"},
+		})
+		return
+	}
+	browser := c.ContentType() == "application/x-www-form-urlencoded"
+
+	account, token, err := Accounts.Verify(req.Token)
+	if errors.Is(err, accounts.ErrInvalidToken) {
+		if browser {
+			renderVerifyPage(c, http.StatusNotFound, nil)
+			return
+		}
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Invalid token",
+			Details: "The verification link is invalid or has expired; register again for a new one",
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("WARN account_verify_failed error=%q", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to verify account"})
+		return
+	}
+	Metrics.Incr("accounts.verifications")
+	if browser {
+		renderVerifyPage(c, http.StatusOK, map[string]string{"Email": account.Email, "AccountToken": token})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"account":       account,
+		"account_token": token,
+	})
+}
+
+// authenticateAccount reads the account token from the Authorization
+// header, responding 401 Unauthorized if it is missing or unknown
+func authenticateAccount(c *gin.Context) (accounts.Account, bool) {
+	token, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	account, ok := Accounts.Authenticate(token)
+	if !ok {
+		c.Header("WWW-Authenticate", `Bearer realm="account"`)
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "Unauthorized",
+			Details: "Send the account token from verification as a Bearer token",
+		})
+	}
+	return account, ok
+}
+
+// GetAccountHandler handles GET /api/v1/accounts/me, returning the
+// authenticated account and its keys
+func GetAccountHandler(c *gin.Context) {
+	account, ok := authenticateAccount(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, account)
+}
+
+// CreateKeyHandler handles POST /api/v1/accounts/keys, issuing an API key
+// with the default quota. The key is shown only once.
+func CreateKeyHandler(c *gin.Context) {
+	account, ok := authenticateAccount(c)
+	if !ok {
+		return
+	}
+	var req CreateKeyRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid request",
+				Details: err.Error(),
+			})
+			return
+		}
+	}
+
+	key, secret, err := Accounts.CreateKey(account.ID, strings.TrimSpace(req.Name), c.ClientIP())
+	if errors.Is(err, accounts.ErrTooManyKeys) {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Too many keys",
+			Details: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("WARN account_key_failed account=%s error=%q", account.ID, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to create key"})
+		return
+	}
+	Metrics.Incr("accounts.keys_created")
+	log.Printf("AUDIT key_created account=%s key=%s client_ip=%s", account.ID, key.ID, c.ClientIP())
+	c.JSON(http.StatusCreated, gin.H{
+		"key":     key,
+		"api_key": secret,
+	})
+}
+
+// RotateKeyHandler handles POST /api/v1/accounts/keys/:id/rotate, issuing
+// a replacement key. The old key stays valid for the rotation grace period
+// so clients can switch over without downtime. The new key is shown only
+// once.
+func RotateKeyHandler(c *gin.Context) {
+	account, ok := authenticateAccount(c)
+	if !ok {
+		return
+	}
+	key, secret, previous, err := Accounts.RotateKey(account.ID, c.Param("id"), c.ClientIP())
+	if errors.Is(err, accounts.ErrNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Key not found"})
+		return
+	}
+	if errors.Is(err, accounts.ErrRotated) {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Key already rotated",
+			Details: "The key is already being replaced; rotate its replacement instead",
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("WARN account_key_failed account=%s error=%q", account.ID, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to rotate key"})
+		return
+	}
+	Metrics.Incr("accounts.keys_rotated")
+	log.Printf("AUDIT key_rotated account=%s key=%s new_key=%s expires=%s client_ip=%s",
+		account.ID, previous.ID, key.ID, previous.ExpiresAt.Format(time.RFC3339), c.ClientIP())
+	c.JSON(http.StatusCreated, gin.H{
+		"key":      key,
+		"api_key":  secret,
+		"previous": previous,
+	})
+}
+
+// RevokeKeyHandler handles DELETE /api/v1/accounts/keys/:id
+func RevokeKeyHandler(c *gin.Context) {
+	account, ok := authenticateAccount(c)
+	if !ok {
+		return
+	}
+	err := Accounts.RevokeKey(account.ID, c.Param("id"), c.ClientIP())
+	if errors.Is(err, accounts.ErrNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Key not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("WARN account_key_failed account=%s error=%q", account.ID, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to revoke key"})
+		return
+	}
+	log.Printf("AUDIT key_revoked account=%s key=%s client_ip=%s", account.ID, c.Param("id"), c.ClientIP())
+	c.Status(http.StatusNoContent)
+}
+
+// AccountEventsHandler handles GET /api/v1/accounts/events, the audit trail
+// of the account's key changes, most recent first
+func AccountEventsHandler(c *gin.Context) {
+	account, ok := authenticateAccount(c)
+	if !ok {
+		return
+	}
+	events := make([]accounts.Event, 0, len(account.Events))
+	for i := len(account.Events) - 1; i >= 0; i-- {
+		events = append(events, account.Events[i])
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"count":  len(events),
+		"events": events,
+	})
+}
+
+// ListAccountsHandler handles GET /admin/accounts, listing every account
+// and its keys, most recently registered first
+func ListAccountsHandler(c *gin.Context) {
+	list := Accounts.List()
+	c.JSON(http.StatusOK, gin.H{
+		"count":    len(list),
+		"accounts": list,
+	})
+}
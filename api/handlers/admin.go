@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud-ai-api/dynconfig"
+	"cloud-ai-api/middleware"
+	"cloud-ai-api/models"
+	"cloud-ai-api/routes"
+	"github.com/gin-gonic/gin"
+)
+
+// Routes holds the routes disabled at runtime
+var Routes = new(routes.Switch)
+
+// EffectiveConfig is the redacted configuration the gateway started with
+var EffectiveConfig = map[string]interface{}{}
+
+// ConfigHandler returns the effective configuration with secrets redacted
+// along with the current feature flags
+func ConfigHandler(c *gin.Context) {
+	resp := make(map[string]interface{}, len(EffectiveConfig)+1)
+	for area, settings := range EffectiveConfig {
+		resp[area] = settings
+	}
+	resp["feature_flags"] = Flags.Snapshot()
+	c.JSON(http.StatusOK, resp)
+}
+
+// DynamicConfig holds the settings watched in etcd or Consul; nil when
+// dynamic configuration is off
+var DynamicConfig *dynconfig.Store
+
+// DynamicConfigHandler returns the settings last read from etcd or Consul
+func DynamicConfigHandler(c *gin.Context) {
+	if DynamicConfig == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Dynamic configuration is disabled",
+			Details: "Set DYNAMIC_CONFIG to etcd or consul to enable it",
+		})
+		return
+	}
+	values, updated := DynamicConfig.Snapshot()
+	resp := gin.H{"values": values}
+	if !updated.IsZero() {
+		resp["updated"] = updated.UTC().Format(time.RFC3339)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Bulkheads holds the per-route concurrency limits; nil when none are set
+var Bulkheads *middleware.Bulkheads
+
+// Shedder sheds load when the gateway is overloaded; nil when disabled
+var Shedder *middleware.LoadShedder
+
+// ConcurrencyHandler reports each concurrency limit's active and queued
+// requests and rejections
+func ConcurrencyHandler(c *gin.Context) {
+	if Bulkheads == nil {
+		c.JSON(http.StatusOK, []middleware.ConcurrencyStatus{})
+		return
+	}
+	c.JSON(http.StatusOK, Bulkheads.Status())
+}
+
+// BackendsHandler lists the ML service instances predictions are sent to
+func BackendsHandler(c *gin.Context) {
+	if MLBackends == nil {
+		c.JSON(http.StatusOK, gin.H{"dynamic": false, "backends": []string{MLServiceURL}})
+		return
+	}
+	backends, updated := MLBackends.Backends()
+	c.JSON(http.StatusOK, gin.H{
+		"dynamic":  true,
+		"backends": backends,
+		"updated":  updated.UTC().Format(time.RFC3339),
+	})
+}
+
+// ListRoutesHandler lists the routes disabled at runtime
+func ListRoutesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"disabled": Routes.List()})
+}
+
+// UpdateRouteHandler disables a route, making it return 503, or re-enables it
+func UpdateRouteHandler(c *gin.Context) {
+	// Parse request
+	var req models.RouteToggleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Validate route
+	if !strings.HasPrefix(req.Path, "/") || req.Path == "/admin" || strings.HasPrefix(req.Path, "/admin/") {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid path",
+			Details: "Must be a request path starting with / (optionally ending in *); admin routes cannot be disabled",
+			Fields:  []string{"path"},
+		})
+		return
+	}
+	if req.Enabled == nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Missing required fields",
+			Details: "Required: enabled",
+			Fields:  []string{"enabled"},
+		})
+		return
+	}
+	if req.RetryAfter < 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid retry_after",
+			Details: "Must be at least 0",
+			Fields:  []string{"retry_after"},
+		})
+		return
+	}
+	method := strings.ToUpper(req.Method)
+
+	if *req.Enabled {
+		found, err := Routes.Enable(method, req.Path)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to update route", Details: err.Error()})
+			return
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Route is not disabled"})
+			return
+		}
+	} else {
+		err := Routes.Disable(routes.Rule{
+			Method:     method,
+			Path:       req.Path,
+			Reason:     req.Reason,
+			RetryAfter: req.RetryAfter,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to update route", Details: err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"disabled": Routes.List()})
+}
+
+// MaintenanceStatusHandler returns the maintenance mode state
+func MaintenanceStatusHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, Routes.Maintenance())
+}
+
+// UpdateMaintenanceHandler turns maintenance mode on or off
+func UpdateMaintenanceHandler(c *gin.Context) {
+	// Parse request
+	var req models.MaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+	if req.Enabled == nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Missing required fields",
+			Details: "Required: enabled",
+			Fields:  []string{"enabled"},
+		})
+		return
+	}
+	if req.RetryAfter < 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid retry_after",
+			Details: "Must be at least 0",
+			Fields:  []string{"retry_after"},
+		})
+		return
+	}
+
+	err := Routes.SetMaintenance(routes.Maintenance{
+		Enabled:    *req.Enabled,
+		Message:    req.Message,
+		RetryAfter: req.RetryAfter,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to update maintenance mode", Details: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Routes.Maintenance())
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+
+	"cloud-ai-api/models"
+	"cloud-ai-api/plausibility"
+	"github.com/gin-gonic/gin"
+)
+
+// Limits on the mortgage terms an affordability request may give
+const (
+	defaultMortgageTermYears = 25
+	maxMortgageTermYears     = 40
+	maxInterestRate          = 25
+)
+
+// AffordabilityHandler handles POST /api/v1/tools/affordability, predicting
+// a property's price and working out the mortgage it implies for the
+// caller's deposit, interest rate and term: the loan, its loan-to-value
+// ratio and the monthly repayment, each as a range across the prediction's
+// confidence interval. The prediction is recorded like any other.
+func AffordabilityHandler(c *gin.Context) {
+	startTime := time.Now()
+
+	model, perr := lookupModel("housing")
+	if perr != nil {
+		respond(c, perr.Status, perr.Response)
+		return
+	}
+	if model.Interval == nil || model.StreamResponse {
+		respond(c, http.StatusNotFound, models.ErrorResponse{
+			Error:   "Affordability unavailable",
+			Details: "The housing model declares no confidence interval",
+		})
+		return
+	}
+	lenient, perr := parseLenient(c)
+	if perr != nil {
+		respond(c, perr.Status, perr.Response)
+		return
+	}
+
+	var req models.AffordabilityRequest
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&req); err != nil {
+		respond(c, http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+	invalid := func(field, details string) {
+		respond(c, http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid affordability request",
+			Details: details,
+			Fields:  []string{field},
+		})
+	}
+	if req.Property == nil {
+		invalid("property", "Must be a housing prediction request")
+		return
+	}
+	if req.Deposit == nil || *req.Deposit < 0 {
+		invalid("deposit", "Must be a number of at least 0")
+		return
+	}
+	if req.InterestRate == nil || *req.InterestRate < 0 || *req.InterestRate > maxInterestRate {
+		invalid("interest_rate", "Must be an annual percentage between 0 and 25")
+		return
+	}
+	term := defaultMortgageTermYears
+	if req.TermYears != nil {
+		term = *req.TermYears
+	}
+	if term < 1 || term > maxMortgageTermYears {
+		invalid("term_years", "Must be a whole number of years between 1 and 40")
+		return
+	}
+	if lenient {
+		coercePayload(model, req.Property)
+	}
+
+	mlResp, id, perr := runPrediction(c, model, req.Property, startTime)
+	if perr != nil {
+		respond(c, perr.Status, perr.Response)
+		return
+	}
+	value, ok := responseNumber(mlResp[model.Interval.Value])
+	if !ok || value <= 0 {
+		respond(c, http.StatusBadGateway, models.ErrorResponse{
+			Error:   "ML service error",
+			Details: "Response has no positive " + model.Interval.Value,
+		})
+		return
+	}
+	// Without a confidence interval every range collapses to the estimate.
+	// Each end of the range divides the loan-to-value ratio, so both must
+	// be positive.
+	lower, upper := value, value
+	l, okLower := responseNumber(mlResp[model.Interval.Lower])
+	u, okUpper := responseNumber(mlResp[model.Interval.Upper])
+	if okLower && okUpper {
+		if l <= 0 || u <= 0 {
+			invalid("property", "The predicted price range for this property includes prices of 0 or less")
+			return
+		}
+		lower, upper = l, u
+	}
+
+	deposit, rate := *req.Deposit, *req.InterestRate
+	resp := models.Affordability{
+		Model:        model.Name,
+		Currency:     model.Currency,
+		Price:        affordabilityRange(func(p float64) float64 { return p }, lower, value, upper),
+		Deposit:      deposit,
+		InterestRate: rate,
+		TermYears:    term,
+		Loan:         affordabilityRange(func(p float64) float64 { return loanAmount(p, deposit) }, lower, value, upper),
+		LoanToValue: affordabilityRange(func(p float64) float64 {
+			return loanAmount(p, deposit) / p * 100
+		}, lower, value, upper),
+		MonthlyPayment: affordabilityRange(func(p float64) float64 {
+			return monthlyPayment(loanAmount(p, deposit), rate, term)
+		}, lower, value, upper),
+		TotalRepayable: affordabilityRange(func(p float64) float64 {
+			return monthlyPayment(loanAmount(p, deposit), rate, term) * float64(term*12)
+		}, lower, value, upper),
+		RequestID:        id,
+		ProcessingTimeMs: float64(time.Since(startTime).Milliseconds()),
+	}
+	resp.Warnings, _ = mlResp["warnings"].([]plausibility.Warning)
+	Metrics.Incr("affordability.requests", "model:"+model.Name)
+	respond(c, http.StatusOK, resp)
+}
+
+// affordabilityRange applies f to the prices at both ends of a confidence
+// interval and to the estimate, ordering the results and rounding them to
+// two decimal places
+func affordabilityRange(f func(price float64) float64, lower, value, upper float64) models.AffordabilityRange {
+	a, b := f(lower), f(upper)
+	return models.AffordabilityRange{
+		Low:      roundPrice(math.Min(a, b)),
+		Expected: roundPrice(f(value)),
+		High:     roundPrice(math.Max(a, b)),
+	}
+}
+
+// loanAmount is what must be borrowed to buy at price with deposit
+func loanAmount(price, deposit float64) float64 {
+	return math.Max(0, price-deposit)
+}
+
+// monthlyPayment is the monthly repayment of a repayment mortgage of loan
+// over years at an annual interest rate in percent, compounded monthly
+func monthlyPayment(loan, annualRate float64, years int) float64 {
+	n := float64(years * 12)
+	r := annualRate / 100 / 12
+	if r == 0 {
+		return loan / n
+	}
+	return loan * r / (1 - math.Pow(1+r, -n))
+}
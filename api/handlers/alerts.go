@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cloud-ai-api/alerts"
+	"cloud-ai-api/notify"
+	"github.com/gin-gonic/gin"
+)
+
+// Alerts evaluates the configured alert rules; nil when there are none
+var Alerts *alerts.Engine
+
+// AlertMetrics are the metrics alert rules can use. Route metrics cover
+// requests to registered routes, ML metrics calls to the ML service.
+var AlertMetrics = []string{
+	"requests", "error_rate", "p50_ms", "p95_ms", "p99_ms", "max_ms",
+	"ml_calls", "ml_error_rate", "ml_p50_ms", "ml_p95_ms", "ml_p99_ms", "ml_max_ms",
+}
+
+// MeasureAlert measures a rule's metric over its window from the request
+// or ML call statistics. Counts and error rates are totalled across the
+// routes or models the rule covers; latencies are the worst of them.
+func MeasureAlert(r *alerts.Rule) (float64, bool) {
+	recorder, filter, metric := RequestStats, r.Route, r.Metric
+	if strings.HasPrefix(metric, "ml_") {
+		recorder, filter, metric = MLStats, r.Model, strings.TrimPrefix(metric, "ml_")
+	}
+
+	var count, errors int64
+	var worst float64
+	for key, s := range recorder.SnapshotWindow(r.WindowDuration()) {
+		if filter != "" && key != filter {
+			continue
+		}
+		count += s.Count
+		errors += s.Errors
+		switch metric {
+		case "p50_ms":
+			worst = max(worst, s.P50Ms)
+		case "p95_ms":
+			worst = max(worst, s.P95Ms)
+		case "p99_ms":
+			worst = max(worst, s.P99Ms)
+		case "max_ms":
+			worst = max(worst, s.MaxMs)
+		}
+	}
+
+	switch metric {
+	case "requests", "calls":
+		return float64(count), true
+	}
+	if count == 0 || count < r.MinRequests {
+		return 0, false
+	}
+	if metric == "error_rate" {
+		return float64(errors) / float64(count), true
+	}
+	return worst, true
+}
+
+// AlertChanged logs, counts and notifies a rule starting or stopping firing
+func AlertChanged(t alerts.Transition) {
+	st := t.State
+	var value string
+	if st.Value != nil {
+		value = strconv.FormatFloat(*st.Value, 'g', 4, 64)
+	}
+
+	ev := notify.Event{
+		Kind:  notify.Alert,
+		Title: fmt.Sprintf("[%s] %s resolved", strings.ToUpper(st.Severity), st.Name),
+		Text:  st.Description,
+		Fields: []notify.Field{
+			{Name: "Condition", Value: st.Condition},
+			{Name: "Value", Value: value},
+		},
+		Resolved: !t.Firing,
+	}
+	if t.Firing {
+		log.Printf("WARN alert_firing rule=%s severity=%s value=%s condition=%q", st.Name, st.Severity, value, st.Condition)
+		Metrics.Incr("alerts.firing", "rule:"+st.Name, "severity:"+st.Severity)
+		ev.Title = fmt.Sprintf("[%s] %s firing", strings.ToUpper(st.Severity), st.Name)
+	} else {
+		log.Printf("alert_resolved rule=%s severity=%s value=%s", st.Name, st.Severity, value)
+	}
+	if ev.Text == "" {
+		ev.Text = st.Condition
+	}
+	if Notifier.Enabled(notify.Alert) {
+		go sendOpsEvent(ev)
+	}
+}
+
+// AlertsHandler handles GET /admin/alerts, listing every rule's latest
+// state with firing rules first
+func AlertsHandler(c *gin.Context) {
+	if Alerts == nil {
+		c.JSON(http.StatusOK, gin.H{"firing": 0, "alerts": []alerts.State{}})
+		return
+	}
+	states := Alerts.States()
+	var firing, other []alerts.State
+	for _, st := range states {
+		if st.Status == alerts.StatusFiring {
+			firing = append(firing, st)
+		} else {
+			other = append(other, st)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"firing": len(firing), "alerts": append(append([]alerts.State{}, firing...), other...)})
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"cloud-ai-api/anomaly"
+	"cloud-ai-api/middleware"
+	"cloud-ai-api/models"
+	"cloud-ai-api/registry"
+	"github.com/gin-gonic/gin"
+)
+
+// Anomalies scores prediction inputs against recent traffic; nil disables
+// anomaly detection
+var Anomalies *anomaly.Detector
+
+// RejectAnomalyScore rejects inputs scoring at least this with 422; 0
+// only logs them
+var RejectAnomalyScore float64
+
+// checkAnomaly scores a payload, attaches the score to the request's logs
+// and metrics and, above RejectAnomalyScore, rejects it
+func checkAnomaly(c *gin.Context, model *registry.Model, payload map[string]interface{}, fingerprint string) *predictionError {
+	if Anomalies == nil {
+		return nil
+	}
+	result := Anomalies.Score(model.Name, fingerprint, payload)
+	Metrics.Histogram("prediction.anomaly_score", result.Score, "model:"+model.Name)
+	if result.Score == 0 {
+		return nil
+	}
+
+	rejected := RejectAnomalyScore > 0 && result.Score >= RejectAnomalyScore
+	c.Set(middleware.AnomalyScoreKey, result.Score)
+	for _, s := range result.Signals {
+		Metrics.Incr("prediction.anomalous", "model:"+model.Name, "signal:"+s.Kind, "rejected:"+strconv.FormatBool(rejected))
+		log.Printf("WARN anomalous_input request_id=%s model=%s fingerprint=%s anomaly_score=%.2f signal=%s field=%s rejected=%t message=%q",
+			middleware.RequestID(c), model.Name, fingerprint, result.Score, s.Kind, s.Field, rejected, s.Message)
+	}
+	if !rejected {
+		return nil
+	}
+
+	resp := models.ErrorResponse{
+		Error:   "Anomalous input",
+		Details: fmt.Sprintf("Anomaly score %.2f is at or above the limit %.2f", result.Score, RejectAnomalyScore),
+	}
+	for _, s := range result.Signals {
+		pointer := ""
+		if s.Field != "" {
+			pointer = "/" + s.Field
+			resp.Fields = append(resp.Fields, s.Field)
+		}
+		resp.Violations = append(resp.Violations, models.Violation{Pointer: pointer, Message: s.Message})
+	}
+	return &predictionError{http.StatusUnprocessableEntity, models.CodeAnomalousInput, resp}
+}
+
+// observeAnomalies records a successful prediction's inputs as ordinary
+// traffic
+func observeAnomalies(model *registry.Model, payload map[string]interface{}) {
+	if Anomalies != nil {
+		Anomalies.Observe(model.Name, payload)
+	}
+}
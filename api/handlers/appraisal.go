@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud-ai-api/models"
+	"cloud-ai-api/plausibility"
+	"github.com/gin-gonic/gin"
+)
+
+// MaxAppraisalProperties is the maximum number of properties in one
+// portfolio appraisal
+var MaxAppraisalProperties = 100
+
+// appraisalIDField is the property field naming a property in the results;
+// it is not sent to the model
+const appraisalIDField = "id"
+
+// AppraisalHandler handles POST /api/v1/appraisals/housing, predicting each
+// property of a portfolio and totalling the results: the portfolio's value
+// and its interval, the value held in each county and the value weighted by
+// each prediction's confidence. Properties that fail are listed with their
+// error and left out of the totals; the request fails only if every one
+// does.
+func AppraisalHandler(c *gin.Context) {
+	startTime := time.Now()
+
+	model, perr := lookupModel("housing")
+	if perr != nil {
+		respond(c, perr.Status, perr.Response)
+		return
+	}
+	if model.Interval == nil || model.StreamResponse {
+		respond(c, http.StatusNotFound, models.ErrorResponse{
+			Error:   "Appraisals unavailable",
+			Details: "The housing model declares no confidence interval",
+		})
+		return
+	}
+	lenient, perr := parseLenient(c)
+	if perr != nil {
+		respond(c, perr.Status, perr.Response)
+		return
+	}
+
+	var req models.AppraisalRequest
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&req); err != nil {
+		respond(c, http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+	if len(req.Properties) == 0 || len(req.Properties) > MaxAppraisalProperties {
+		respond(c, http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid properties",
+			Details: fmt.Sprintf("Must contain between 1 and %d properties", MaxAppraisalProperties),
+			Fields:  []string{"properties"},
+		})
+		return
+	}
+	if perr := checkModelEnabled(c.Request, model); perr != nil {
+		respond(c, perr.Status, perr.Response)
+		return
+	}
+
+	ids := make([]string, len(req.Properties))
+	payloads := make([]map[string]interface{}, len(req.Properties))
+	for i, property := range req.Properties {
+		payload := make(map[string]interface{}, len(property))
+		for k, v := range property {
+			payload[k] = v
+		}
+		if id, ok := payload[appraisalIDField]; ok {
+			ids[i] = fmt.Sprint(id)
+			delete(payload, appraisalIDField)
+		}
+		if lenient {
+			coercePayload(model, payload)
+		}
+		payloads[i] = payload
+	}
+	results := fanOut(c.Request, model, payloads, "appraisal")
+
+	appraisal := models.Appraisal{
+		Model:      model.Name,
+		Currency:   model.Currency,
+		Properties: make([]models.AppraisedProperty, len(results)),
+	}
+	summary := &appraisal.Summary
+	summary.Properties = len(results)
+	counties := make(map[string]*models.CountyValue)
+	var firstErr *predictionError
+	for i, r := range results {
+		property := models.AppraisedProperty{Index: i, ID: ids[i], Input: payloads[i]}
+		if r.perr != nil {
+			if firstErr == nil {
+				firstErr = r.perr
+			}
+			property.Error = &r.perr.Response
+			appraisal.Properties[i] = property
+			summary.Failed++
+			continue
+		}
+		if r.cached {
+			appraisal.Cached++
+		}
+		value := r.value
+		property.Value = &value
+		property.Warnings, _ = r.resp["warnings"].([]plausibility.Warning)
+
+		// Without a confidence interval a value counts as its own bounds and
+		// at full confidence
+		lower, upper, confidence := value, value, 1.0
+		l, okLower := responseNumber(r.resp[model.Interval.Lower])
+		u, okUpper := responseNumber(r.resp[model.Interval.Upper])
+		if okLower && okUpper {
+			lower, upper = l, u
+			confidence = intervalConfidence(value, lower, upper)
+			property.Lower, property.Upper, property.Confidence = &lower, &upper, &confidence
+		}
+
+		summary.Appraised++
+		summary.TotalValue += value
+		summary.TotalLower += lower
+		summary.TotalUpper += upper
+		summary.ConfidenceWeightedValue += value * confidence
+
+		county := normalizeCounty(payloads[i]["county"])
+		cv, ok := counties[county]
+		if !ok {
+			cv = &models.CountyValue{County: county}
+			counties[county] = cv
+		}
+		cv.Properties++
+		cv.TotalValue += value
+		appraisal.Properties[i] = property
+	}
+
+	Metrics.Incr("appraisal.requests", "model:"+model.Name, "partial:"+strconv.FormatBool(summary.Failed > 0))
+	Metrics.Histogram("appraisal.properties", float64(summary.Properties), "model:"+model.Name)
+	if summary.Appraised == 0 {
+		// Every property failed; report the first failure as a single
+		// prediction would
+		respond(c, firstErr.Status, firstErr.Response)
+		return
+	}
+
+	summary.ConfidenceWeightedValue = math.Round(summary.ConfidenceWeightedValue*100) / 100
+	summary.ByCounty = make([]models.CountyValue, 0, len(counties))
+	for _, cv := range counties {
+		if summary.TotalValue != 0 {
+			cv.Share = math.Round(cv.TotalValue/summary.TotalValue*1e4) / 1e4
+		}
+		summary.ByCounty = append(summary.ByCounty, *cv)
+	}
+	sort.Slice(summary.ByCounty, func(i, j int) bool {
+		a, b := summary.ByCounty[i], summary.ByCounty[j]
+		if a.TotalValue != b.TotalValue {
+			return a.TotalValue > b.TotalValue
+		}
+		return a.County < b.County
+	})
+	appraisal.ProcessingTimeMs = float64(time.Since(startTime).Milliseconds())
+	respond(c, http.StatusOK, appraisal)
+}
+
+// normalizeCounty writes a county as the model reads it, upper case with
+// single spaces, so "kent" and "KENT" are totalled together
+func normalizeCounty(v interface{}) string {
+	return strings.Join(strings.Fields(strings.ToUpper(fmt.Sprint(v))), " ")
+}
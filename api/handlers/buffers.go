@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledBuffer is the largest buffer returned to the pool; bigger ones
+// are left to the garbage collector so one huge response does not pin its
+// memory for the life of the process
+const maxPooledBuffer = 1 << 20
+
+// bufferPool recycles the buffers used to encode ML requests and read ML
+// responses
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// getBuffer returns an empty buffer from the pool
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBuffer returns a buffer to the pool unless it has grown too large
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// pooledBody is a request body read from a pooled buffer, returning the
+// buffer once the HTTP client has closed it
+type pooledBody struct {
+	*bytes.Reader
+	buf  *bytes.Buffer
+	once sync.Once
+}
+
+func newPooledBody(buf *bytes.Buffer) *pooledBody {
+	return &pooledBody{Reader: bytes.NewReader(buf.Bytes()), buf: buf}
+}
+
+// Close returns the buffer to the pool
+func (b *pooledBody) Close() error {
+	b.once.Do(func() { putBuffer(b.buf) })
+	return nil
+}
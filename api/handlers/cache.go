@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"cloud-ai-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// cacheDisabled answers admin cache requests when no response cache is configured
+func cacheDisabled(c *gin.Context) bool {
+	if ResponseCache != nil {
+		return false
+	}
+	c.JSON(http.StatusNotFound, models.ErrorResponse{
+		Error:   "Response cache is disabled",
+		Details: "Set RESPONSE_CACHE_SIZE to enable it",
+	})
+	return true
+}
+
+// CacheStatsHandler reports the response cache's size and hit/miss counts
+func CacheStatsHandler(c *gin.Context) {
+	if cacheDisabled(c) {
+		return
+	}
+	c.JSON(http.StatusOK, ResponseCache.Stats())
+}
+
+// CacheKeysHandler lists cached responses, most recently used first,
+// optionally for one model (?model=) and limited (?limit=, default 100)
+func CacheKeysHandler(c *gin.Context) {
+	if cacheDisabled(c) {
+		return
+	}
+
+	limit := 100
+	if l := c.Query("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid limit",
+				Details: "Must be a positive integer",
+				Fields:  []string{"limit"},
+			})
+			return
+		}
+		limit = n
+	}
+
+	entries := ResponseCache.Entries(c.Query("model"))
+	total := len(entries)
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	c.JSON(http.StatusOK, gin.H{"total": total, "keys": entries})
+}
+
+// DeleteCacheKeyHandler removes one cached response by key
+func DeleteCacheKeyHandler(c *gin.Context) {
+	if cacheDisabled(c) {
+		return
+	}
+	if !ResponseCache.Delete(c.Param("key")) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Key not cached"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"purged": 1})
+}
+
+// PurgeCacheHandler removes the cached response for a model and input, or
+// every cached response for the model if no input is given
+func PurgeCacheHandler(c *gin.Context) {
+	if cacheDisabled(c) {
+		return
+	}
+
+	// Decode numbers as the prediction routes do, so the key matches
+	var req models.CachePurgeRequest
+	dec := json.NewDecoder(c.Request.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+	if req.Model == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Missing required fields",
+			Details: "Required: model",
+			Fields:  []string{"model"},
+		})
+		return
+	}
+
+	if req.Input == nil {
+		c.JSON(http.StatusOK, gin.H{"purged": ResponseCache.PurgeModel(req.Model)})
+		return
+	}
+
+	key := cacheKey(req.Model, payloadHash(req.Input))
+	purged := 0
+	if ResponseCache.Delete(key) {
+		purged = 1
+	}
+	c.JSON(http.StatusOK, gin.H{"purged": purged, "key": key})
+}
+
+// FlushCacheHandler removes every cached response
+func FlushCacheHandler(c *gin.Context) {
+	if cacheDisabled(c) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"purged": ResponseCache.Flush()})
+}
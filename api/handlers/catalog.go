@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"cloud-ai-api/catalog"
+	"cloud-ai-api/notify"
+	"github.com/gin-gonic/gin"
+)
+
+// ModelCatalog holds the models and versions the ML service has served,
+// with each model's version history
+var ModelCatalog, _ = catalog.Open("", nil)
+
+// catalogEntry is a catalog model and whether the gateway serves it
+type catalogEntry struct {
+	catalog.Model
+	Registered bool `json:"registered"`
+}
+
+// SyncModelCatalog fetches the ML service's model listing once and syncs
+// the catalog with it
+func SyncModelCatalog(timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	listing, err := fetchModelListing(ctx)
+	if err != nil {
+		log.Printf("Model catalog sync failed: %v", err)
+		return
+	}
+	syncCatalog(listing, listingVersions(listing))
+}
+
+// syncCatalog updates the catalog from a /models listing and reports the
+// changes
+func syncCatalog(listing map[string]map[string]interface{}, versions map[string]string) {
+	changes, err := ModelCatalog.Sync(listing, versions, time.Now().UTC())
+	if err != nil {
+		log.Printf("WARN %v", err)
+	}
+	for _, change := range changes {
+		catalogChanged(change)
+	}
+}
+
+// catalogChanged logs and counts a catalog change and, for a new model or
+// version after the catalog's first sync, notifies operators
+func catalogChanged(change catalog.Change) {
+	log.Printf("model_catalog kind=%s model=%s from=%s to=%s source=%s", change.Kind, change.Model, change.From, change.To, change.Source)
+	Metrics.Incr("model.catalog.changes", "model:"+change.Model, "kind:"+change.Kind)
+	if change.Initial || (change.Kind != catalog.VersionAdded && change.Kind != catalog.ModelAdded) {
+		return
+	}
+	if !Notifier.Enabled(notify.ModelVersion) {
+		return
+	}
+	title := fmt.Sprintf("New %s model version", change.Model)
+	text := fmt.Sprintf("The ML service is serving version %s of the %s model.", change.To, change.Model)
+	if change.Kind == catalog.ModelAdded {
+		title = fmt.Sprintf("New model %s", change.Model)
+		text = fmt.Sprintf("The ML service has started serving the %s model.", change.Model)
+	}
+	fields := []notify.Field{{Name: "Source", Value: change.Source}}
+	if change.From != "" {
+		fields = append(fields, notify.Field{Name: "Previous version", Value: change.From})
+	}
+	go sendOpsEvent(notify.Event{Kind: notify.ModelVersion, Title: title, Text: text, Fields: fields})
+}
+
+// CatalogHandler handles GET /api/v1/models/catalog. It lists every model
+// the ML service has served, with its current version, the attributes it
+// last reported, its version history and whether the gateway serves it.
+func CatalogHandler(c *gin.Context) {
+	list, synced := ModelCatalog.List()
+	entries := make([]catalogEntry, len(list))
+	for i, m := range list {
+		_, registered := Registry.Get(m.Name)
+		entries[i] = catalogEntry{Model: m, Registered: registered}
+	}
+	resp := gin.H{"models": entries, "synced": nil}
+	if !synced.IsZero() {
+		resp["synced"] = synced.Format(time.RFC3339)
+	}
+	c.JSON(http.StatusOK, resp)
+}
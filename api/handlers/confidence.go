@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"cloud-ai-api/models"
+	"cloud-ai-api/registry"
+	"github.com/gin-gonic/gin"
+)
+
+// minConfidenceParam is the query parameter a caller sets to refuse
+// predictions whose confidence interval is too wide
+const minConfidenceParam = "min_confidence"
+
+// parseMinConfidence reads the min_confidence query parameter, returning 0
+// if it is not set. It must be between 0 and 1 and the model must report a
+// confidence interval.
+func parseMinConfidence(c *gin.Context, model *registry.Model) (float64, *predictionError) {
+	v := c.Query(minConfidenceParam)
+	if v == "" {
+		return 0, nil
+	}
+	min, err := strconv.ParseFloat(v, 64)
+	if err != nil || min <= 0 || min >= 1 {
+		return 0, &predictionError{http.StatusBadRequest, models.CodeInvalidRequest, models.ErrorResponse{
+			Error:   "Invalid min_confidence",
+			Details: "Must be a number between 0 and 1 (exclusive)",
+		}}
+	}
+	if model.Interval == nil || model.StreamResponse {
+		return 0, &predictionError{http.StatusBadRequest, models.CodeInvalidRequest, models.ErrorResponse{
+			Error:   "Invalid min_confidence",
+			Details: fmt.Sprintf("The %s model does not report a confidence interval", model.Name),
+		}}
+	}
+	return min, nil
+}
+
+// checkConfidence rejects a prediction whose confidence is below min. A
+// prediction's confidence is one minus the width of its interval relative
+// to the estimate, so an interval of +/-10% of the value has confidence 0.8.
+func checkConfidence(model *registry.Model, mlResp map[string]interface{}, min float64) *predictionError {
+	if min == 0 {
+		return nil
+	}
+	value, okValue := responseNumber(mlResp[model.Interval.Value])
+	lower, okLower := responseNumber(mlResp[model.Interval.Lower])
+	upper, okUpper := responseNumber(mlResp[model.Interval.Upper])
+	if !okValue || !okLower || !okUpper {
+		return &predictionError{http.StatusBadGateway, models.CodeMLServiceError, models.ErrorResponse{
+			Error:   "ML service error",
+			Details: fmt.Sprintf("Response has no confidence interval (%s, %s, %s)", model.Interval.Value, model.Interval.Lower, model.Interval.Upper),
+		}}
+	}
+
+	confidence := intervalConfidence(value, lower, upper)
+	if confidence >= min {
+		return nil
+	}
+	Metrics.Incr("prediction.low_confidence", "model:"+model.Name)
+	return &predictionError{http.StatusUnprocessableEntity, models.CodeLowConfidence, models.ErrorResponse{
+		Error:   "Prediction confidence too low",
+		Details: fmt.Sprintf("Confidence %.2f is below the requested %.2f", confidence, min),
+		Interval: &models.ConfidenceInterval{
+			Value:      value,
+			Lower:      lower,
+			Upper:      upper,
+			Confidence: confidence,
+			Required:   min,
+		},
+	}}
+}
+
+// intervalConfidence is one minus the interval's width relative to the
+// estimate, clamped to [0, 1]
+func intervalConfidence(value, lower, upper float64) float64 {
+	if value == 0 {
+		return 0
+	}
+	confidence := 1 - (upper-lower)/math.Abs(value)
+	return math.Round(math.Max(0, math.Min(1, confidence))*1e4) / 1e4
+}
+
+// responseNumber reads a numeric field from a decoded ML response
+func responseNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
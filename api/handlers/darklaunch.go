@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud-ai-api/history"
+	"cloud-ai-api/middleware"
+	"cloud-ai-api/models"
+	"cloud-ai-api/registry"
+	"cloud-ai-api/stats"
+	"cloud-ai-api/usage"
+	"github.com/gin-gonic/gin"
+)
+
+// DarkStats holds per-model statistics for dark-launched predictions
+var DarkStats = stats.NewRecorder(5 * time.Minute)
+
+// darkResultsKept is how many recent dark-launch results are kept per model
+const darkResultsKept = 50
+
+// darkLaunches holds recent dark-launch results and comparison counts per model
+var darkLaunches = struct {
+	sync.Mutex
+	models map[string]*models.DarkLaunchSummary
+}{models: make(map[string]*models.DarkLaunchSummary)}
+
+// darkFlag is the name of the flag dark-launching a model
+func darkFlag(model string) string {
+	return "dark." + model
+}
+
+// runDarkLaunch runs a prediction for a dark-launched model: the request is
+// validated and forwarded as usual, but the result is only logged and
+// compared with the latest live prediction for the same input. Invalid
+// requests are still rejected; ML service failures are recorded and the
+// caller is told the request was accepted.
+func runDarkLaunch(c *gin.Context, model *registry.Model, payload map[string]interface{}) (models.DarkLaunchAccepted, *predictionError) {
+	hash := payloadHash(payload)
+	c.Set(middleware.PayloadFingerprintKey, hash[:16])
+
+	if perr := checkModelEnabled(c.Request, model); perr != nil {
+		return models.DarkLaunchAccepted{}, perr
+	}
+	return evaluateDarkLaunch(c.Request, model, payload)
+}
+
+// checkDarkLaunch withholds the result of a dark-launched model from the
+// caller: the prediction is evaluated as a dark launch and a 202 takes the
+// place of the result. Every entry point returning predictions to a caller
+// calls it; r is nil for batch jobs, which run as their owner.
+func checkDarkLaunch(r *http.Request, caller usage.Caller, model *registry.Model, payload map[string]interface{}) *predictionError {
+	if !flagEnabledFor(darkFlag(model.Name), caller) {
+		return nil
+	}
+	accepted, perr := evaluateDarkLaunch(r, model, payload)
+	if perr != nil {
+		return perr
+	}
+	return &predictionError{http.StatusAccepted, models.CodeAcceptedForEvaluation, models.ErrorResponse{
+		Error:   "Accepted for evaluation",
+		Details: accepted.Message,
+	}}
+}
+
+// evaluateDarkLaunch forwards a dark-launch prediction and records the
+// result
+func evaluateDarkLaunch(r *http.Request, model *registry.Model, payload map[string]interface{}) (models.DarkLaunchAccepted, *predictionError) {
+	hash := payloadHash(payload)
+	var requestID string
+	if r != nil {
+		requestID = middleware.RequestIDFromContext(r.Context())
+	}
+
+	start := time.Now()
+	mlResp, perr := runPipeline(r, model, payload, flagEnabled(FlagStrictValidation, r))
+	latency := time.Since(start)
+	if perr != nil && perr.Status < http.StatusInternalServerError {
+		return models.DarkLaunchAccepted{}, perr
+	}
+
+	result := models.DarkLaunchResult{
+		At:          start.UTC().Format(time.RFC3339),
+		RequestID:   requestID,
+		Fingerprint: hash[:16],
+		LatencyMs:   float64(latency.Microseconds()) / 1000,
+		Input:       payload,
+		Output:      mlResp,
+	}
+	if perr != nil {
+		result.Error = perr.Error()
+	} else {
+		result.Comparison = compareWithLive(model.Name, payload, mlResp)
+	}
+	recordDarkLaunch(model.Name, result, latency)
+
+	return models.DarkLaunchAccepted{
+		Status:    "accepted_for_evaluation",
+		Model:     model.Name,
+		RequestID: requestID,
+		Message:   fmt.Sprintf("The %s model is being evaluated; its result is not returned yet", model.Name),
+	}, nil
+}
+
+// compareWithLive compares a dark-launch response with the newest live
+// prediction for the same input in the history, if there is one
+func compareWithLive(model string, payload, darkResp map[string]interface{}) *models.DarkLaunchComparison {
+	hash := payloadHash(payload)
+	for _, e := range History.List(history.Filter{Model: model}) {
+		if payloadHash(e.Request) != hash {
+			continue
+		}
+		cmp := &models.DarkLaunchComparison{LiveID: e.ID}
+		for _, field := range responseFields(darkResp, e.Response) {
+			if !reflect.DeepEqual(normalize(darkResp[field]), normalize(e.Response[field])) {
+				cmp.Differences = append(cmp.Differences, field)
+			}
+		}
+		cmp.Matches = len(cmp.Differences) == 0
+		return cmp
+	}
+	return nil
+}
+
+// responseFields lists the fields of two responses, except timing fields
+// added by the gateway
+func responseFields(a, b map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	for _, m := range []map[string]interface{}{a, b} {
+		for k := range m {
+			if k != "processing_time_ms" && k != "prediction_time" && k != provenanceField {
+				seen[k] = true
+			}
+		}
+	}
+	fields := make([]string, 0, len(seen))
+	for k := range seen {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// normalize round-trips a value through JSON so numbers compare equal
+// regardless of their decoded type
+func normalize(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if json.Unmarshal(data, &out) != nil {
+		return v
+	}
+	return out
+}
+
+// recordDarkLaunch logs a dark-launch result and keeps it for the admin API
+func recordDarkLaunch(model string, result models.DarkLaunchResult, latency time.Duration) {
+	DarkStats.Observe(model, latency, result.Error != "")
+
+	outcome, compared := "success", "no_live_result"
+	if result.Error != "" {
+		outcome = "error"
+	}
+	if cmp := result.Comparison; cmp != nil {
+		compared = "match"
+		if !cmp.Matches {
+			compared = "mismatch"
+		}
+	}
+	output, _ := json.Marshal(result.Output)
+	log.Printf("dark_launch request_id=%s model=%s fingerprint=%s duration_ms=%.1f outcome=%s comparison=%s output=%s",
+		result.RequestID, model, result.Fingerprint, result.LatencyMs, outcome, compared, output)
+	Metrics.Incr("dark_launch.predictions", "model:"+model, "outcome:"+outcome, "comparison:"+compared)
+
+	darkLaunches.Lock()
+	defer darkLaunches.Unlock()
+	summary, ok := darkLaunches.models[model]
+	if !ok {
+		summary = &models.DarkLaunchSummary{}
+		darkLaunches.models[model] = summary
+	}
+	if result.Comparison != nil {
+		summary.Compared++
+		if !result.Comparison.Matches {
+			summary.Mismatches++
+		}
+	}
+	summary.Recent = append([]models.DarkLaunchResult{result}, summary.Recent...)
+	if len(summary.Recent) > darkResultsKept {
+		summary.Recent = summary.Recent[:darkResultsKept]
+	}
+}
+
+// DarkLaunchHandler reports dark-launch statistics, comparison counts and
+// recent results per model
+func DarkLaunchHandler(c *gin.Context) {
+	snapshot := DarkStats.Snapshot()
+
+	darkLaunches.Lock()
+	defer darkLaunches.Unlock()
+	out := make(map[string]models.DarkLaunchSummary, len(darkLaunches.models))
+	for name, summary := range darkLaunches.models {
+		s := *summary
+		s.Stats = snapshot[name]
+		s.Recent = append([]models.DarkLaunchResult(nil), summary.Recent...)
+		out[name] = s
+	}
+	c.JSON(http.StatusOK, out)
+}
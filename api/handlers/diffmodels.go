@@ -0,0 +1,397 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud-ai-api/ensemble"
+	"cloud-ai-api/history"
+	"cloud-ai-api/hooks"
+	"cloud-ai-api/models"
+	"cloud-ai-api/registry"
+	"cloud-ai-api/stats"
+	"github.com/gin-gonic/gin"
+)
+
+// maxDiffSamples caps the payloads compared in one request
+const maxDiffSamples = 1000
+
+// diffHistoryDefault is how many recent history entries are compared when
+// the request gives no payloads
+const diffHistoryDefault = 100
+
+// diffBuckets is the number of histogram buckets the deltas are counted in
+const diffBuckets = 10
+
+// diffLargestKept and diffErrorsKept cap the samples listed in a response
+const (
+	diffLargestKept = 5
+	diffErrorsKept  = 10
+)
+
+// diffSample is the outcome of running one payload against both versions
+type diffSample struct {
+	input    map[string]interface{}
+	invalid  string
+	values   [2]*float64
+	latency  [2]time.Duration
+	called   [2]bool
+	failures [2]string
+}
+
+// DiffModelsHandler handles POST /admin/diff-models. It runs a set of
+// payloads against two versions of a model, given by the ML backend and
+// path serving each, and reports the distribution of the deltas between
+// their predictions of a field alongside their latencies. Given payloads
+// are validated and transformed like predictions; history payloads were
+// already, and are sent as recorded.
+func DiffModelsHandler(c *gin.Context) {
+	startTime := time.Now()
+
+	var req models.DiffModelsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+	if req.Model == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Missing required fields",
+			Details: "Required: model",
+			Fields:  []string{"model"},
+		})
+		return
+	}
+	model, ok := Registry.Get(req.Model)
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Unknown model",
+			Details: fmt.Sprintf("Must be one of: %s", strings.Join(Registry.Names(), ", ")),
+			Fields:  []string{"model"},
+		})
+		return
+	}
+	if model.StreamResponse {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid model",
+			Details: "Streamed models cannot be compared",
+			Fields:  []string{"model"},
+		})
+		return
+	}
+	field := req.Field
+	if field == "" && model.Interval != nil {
+		field = model.Interval.Value
+	}
+	if field == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Missing required fields",
+			Details: "Required: field, as the model declares no interval",
+			Fields:  []string{"field"},
+		})
+		return
+	}
+
+	versions := [2]ensemble.Member{}
+	for i, target := range []struct {
+		name   string
+		target models.DiffTarget
+	}{{"baseline", req.Baseline}, {"candidate", req.Candidate}} {
+		member, err := diffMember(target.name, target.target)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid " + target.name,
+				Details: err.Error(),
+				Fields:  []string{target.name},
+			})
+			return
+		}
+		versions[i] = member
+	}
+	if versions[0].URL == versions[1].URL && versions[0].MLPath == versions[1].MLPath {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid candidate",
+			Details: "The baseline and candidate are the same backend and path",
+			Fields:  []string{"baseline", "candidate"},
+		})
+		return
+	}
+
+	concurrency := req.Concurrency
+	if concurrency == 0 {
+		concurrency = 4
+	}
+	if concurrency < 1 || concurrency > 32 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid concurrency",
+			Details: "Must be between 1 and 32",
+			Fields:  []string{"concurrency"},
+		})
+		return
+	}
+
+	payloads, source := req.Payloads, "payloads"
+	if len(payloads) == 0 {
+		source = "history"
+		n := req.History
+		if n == 0 {
+			n = diffHistoryDefault
+		}
+		if n < 0 || n > maxDiffSamples {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid history",
+				Details: fmt.Sprintf("Must be between 1 and %d", maxDiffSamples),
+				Fields:  []string{"history"},
+			})
+			return
+		}
+		for _, e := range History.List(history.Filter{Model: model.Name, Limit: n}) {
+			payloads = append(payloads, e.Request)
+		}
+		if len(payloads) == 0 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "No payloads",
+				Details: fmt.Sprintf("The history has no %s predictions; send payloads", model.Name),
+				Fields:  []string{"payloads"},
+			})
+			return
+		}
+	} else if len(payloads) > maxDiffSamples {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Too many payloads",
+			Details: fmt.Sprintf("At most %d payloads can be compared at once", maxDiffSamples),
+			Fields:  []string{"payloads"},
+		})
+		return
+	}
+
+	samples := runDiff(c.Request, model, versions, field, payloads, source == "payloads", concurrency)
+	resp := summarizeDiff(samples)
+	resp.Model = model.Name
+	resp.Field = field
+	resp.Baseline = versions[0].Name
+	resp.Candidate = versions[1].Name
+	resp.Source = source
+	resp.ProcessingTimeMs = float64(time.Since(startTime).Milliseconds())
+
+	var meanAbs float64
+	if resp.Deltas != nil {
+		meanAbs = resp.Deltas.MeanAbs
+	}
+	log.Printf("Model diff: model=%s baseline=%s candidate=%s samples=%d compared=%d mean_abs_delta=%g",
+		model.Name, resp.Baseline, resp.Candidate, resp.Samples, resp.Compared, meanAbs)
+	c.JSON(http.StatusOK, resp)
+}
+
+// diffMember checks a version's backend and path, naming it after its side
+// if it has no name
+func diffMember(side string, t models.DiffTarget) (ensemble.Member, error) {
+	m := ensemble.Member{Name: t.Name, URL: strings.TrimRight(t.URL, "/"), MLPath: t.MLPath}
+	if m.Name == "" {
+		m.Name = side
+	}
+	if t.URL != "" {
+		if u, err := url.Parse(t.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return m, fmt.Errorf("url must be an http(s) URL")
+		}
+	}
+	if t.MLPath != "" && !strings.HasPrefix(t.MLPath, "/") {
+		return m, fmt.Errorf("ml_path must start with '/'")
+	}
+	return m, nil
+}
+
+// runDiff sends every payload to both versions, at most concurrency
+// payloads at a time, each call through the ML call gate
+func runDiff(r *http.Request, model *registry.Model, versions [2]ensemble.Member, field string, payloads []map[string]interface{}, prepare bool, concurrency int) []diffSample {
+	samples := make([]diffSample, len(payloads))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, payload := range payloads {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(s *diffSample, payload map[string]interface{}) {
+			defer func() { <-sem; wg.Done() }()
+			s.input = payload
+			if prepare {
+				if _, perr := prepareRequest(r, model, payload, false); perr != nil {
+					s.invalid = perr.Error()
+					return
+				}
+			}
+			for v, version := range versions {
+				s.values[v], s.latency[v], s.called[v], s.failures[v] = callDiffVersion(r, model, version, field, payload)
+			}
+		}(&samples[i], payload)
+	}
+	wg.Wait()
+	return samples
+}
+
+// callDiffVersion predicts a payload with one version, returning the
+// numeric value of field, the call's latency and whether the call was made
+func callDiffVersion(r *http.Request, model *registry.Model, version ensemble.Member, field string, payload map[string]interface{}) (*float64, time.Duration, bool, string) {
+	release, perr := acquireML(r)
+	if perr != nil {
+		return nil, 0, false, perr.Response.Details
+	}
+	start := time.Now()
+	mlResp, err := callEnsembleMember(model, version, payload)
+	release()
+	latency := time.Since(start)
+	if err == nil {
+		err = runHooks(r, model, hooks.PostResponse, mlResp)
+	}
+	if err != nil {
+		return nil, latency, true, err.Error()
+	}
+	value, ok := responseNumber(mlResp[field])
+	if !ok {
+		return nil, latency, true, fmt.Sprintf("response has no numeric %q field", field)
+	}
+	return &value, latency, true, ""
+}
+
+// summarizeDiff computes the delta distribution, latency comparison and
+// notable samples
+func summarizeDiff(samples []diffSample) models.DiffModelsResponse {
+	resp := models.DiffModelsResponse{Samples: len(samples), Largest: []models.DiffSample{}}
+	var deltas, relative []float64
+	var latencies [2][]float64
+	addError := func(sample int, version, message string) {
+		if len(resp.Errors) < diffErrorsKept {
+			resp.Errors = append(resp.Errors, models.DiffSampleError{Sample: sample, Version: version, Error: message})
+		}
+	}
+	for i, s := range samples {
+		if s.invalid != "" {
+			resp.Invalid++
+			addError(i, "", s.invalid)
+			continue
+		}
+		for v, name := range []string{"baseline", "candidate"} {
+			if s.called[v] {
+				latencies[v] = append(latencies[v], float64(s.latency[v].Microseconds())/1000)
+			}
+			if s.failures[v] != "" {
+				if v == 0 {
+					resp.Failed.Baseline++
+				} else {
+					resp.Failed.Candidate++
+				}
+				addError(i, name, s.failures[v])
+			}
+		}
+		if s.values[0] == nil || s.values[1] == nil {
+			continue
+		}
+		base, cand := *s.values[0], *s.values[1]
+		delta := cand - base
+		resp.Compared++
+		if delta == 0 {
+			resp.Identical++
+		}
+		deltas = append(deltas, delta)
+		if base != 0 {
+			relative = append(relative, math.Abs(delta/base))
+		}
+		resp.Largest = append(resp.Largest, models.DiffSample{Input: s.input, Baseline: base, Candidate: cand, Delta: delta})
+	}
+
+	sort.SliceStable(resp.Largest, func(i, j int) bool {
+		return math.Abs(resp.Largest[i].Delta) > math.Abs(resp.Largest[j].Delta)
+	})
+	if len(resp.Largest) > diffLargestKept {
+		resp.Largest = resp.Largest[:diffLargestKept]
+	}
+	if len(deltas) > 0 {
+		resp.Deltas = deltaDistribution(deltas, relative)
+	}
+
+	resp.Latency.Baseline = latencySummary(latencies[0])
+	resp.Latency.Candidate = latencySummary(latencies[1])
+	if resp.Latency.Baseline.P50Ms > 0 {
+		resp.Latency.P50Ratio = resp.Latency.Candidate.P50Ms / resp.Latency.Baseline.P50Ms
+	}
+	if resp.Latency.Baseline.P95Ms > 0 {
+		resp.Latency.P95Ratio = resp.Latency.Candidate.P95Ms / resp.Latency.Baseline.P95Ms
+	}
+	return resp
+}
+
+// deltaDistribution summarizes signed deltas and the relative size of each
+func deltaDistribution(deltas, relative []float64) *models.DiffDeltas {
+	d := &models.DiffDeltas{StdDev: ensemble.StdDev(deltas)}
+	abs := make([]float64, len(deltas))
+	for i, delta := range deltas {
+		d.Mean += delta
+		abs[i] = math.Abs(delta)
+		d.MeanAbs += abs[i]
+	}
+	d.Mean /= float64(len(deltas))
+	d.MeanAbs /= float64(len(deltas))
+	sort.Float64s(abs)
+	d.P50Abs = stats.Percentile(abs, 0.5)
+	d.P90Abs = stats.Percentile(abs, 0.9)
+	d.P99Abs = stats.Percentile(abs, 0.99)
+	d.MaxAbs = abs[len(abs)-1]
+	if len(relative) > 0 {
+		for _, r := range relative {
+			d.MeanAbsRelative += r
+		}
+		d.MeanAbsRelative /= float64(len(relative))
+		sort.Float64s(relative)
+		d.P90AbsRelative = stats.Percentile(relative, 0.9)
+	}
+
+	sorted := append([]float64(nil), deltas...)
+	sort.Float64s(sorted)
+	lo, hi := sorted[0], sorted[len(sorted)-1]
+	if lo == hi {
+		d.Histogram = []models.DiffBucket{{From: lo, To: hi, Count: len(sorted)}}
+		return d
+	}
+	width := (hi - lo) / diffBuckets
+	d.Histogram = make([]models.DiffBucket, diffBuckets)
+	for i := range d.Histogram {
+		d.Histogram[i].From = lo + float64(i)*width
+		d.Histogram[i].To = lo + float64(i+1)*width
+	}
+	d.Histogram[diffBuckets-1].To = hi
+	for _, delta := range sorted {
+		i := int((delta - lo) / width)
+		if i >= diffBuckets {
+			i = diffBuckets - 1
+		}
+		d.Histogram[i].Count++
+	}
+	return d
+}
+
+// latencySummary describes call latencies in milliseconds
+func latencySummary(ms []float64) models.LatencySummary {
+	if len(ms) == 0 {
+		return models.LatencySummary{}
+	}
+	sorted := append([]float64(nil), ms...)
+	sort.Float64s(sorted)
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+	return models.LatencySummary{
+		MeanMs: sum / float64(len(sorted)),
+		P50Ms:  stats.Percentile(sorted, 0.5),
+		P95Ms:  stats.Percentile(sorted, 0.95),
+		P99Ms:  stats.Percentile(sorted, 0.99),
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+
+	"cloud-ai-api/drift"
+	"cloud-ai-api/models"
+	"cloud-ai-api/registry"
+	"github.com/gin-gonic/gin"
+)
+
+// Drift keeps rolling statistics on prediction inputs and outputs; nil
+// disables drift monitoring
+var Drift *drift.Monitor
+
+// observeDrift records a prediction's categorical inputs, predicted value,
+// relative interval width and input month with the drift monitor
+func observeDrift(model *registry.Model, payload, mlResp map[string]interface{}) {
+	if Drift == nil {
+		return
+	}
+	obs := drift.Observation{Categories: make(map[string]string)}
+	for _, f := range model.Fields {
+		if f.Type != "string" {
+			continue
+		}
+		if v, ok := payload[f.Name].(string); ok {
+			obs.Categories[f.Name] = v
+		}
+	}
+	if year, ok := responseNumber(payload["year"]); ok {
+		if month, ok := responseNumber(payload["month"]); ok {
+			obs.Month = fmt.Sprintf("%04d-%02d", int(year), int(month))
+		}
+	}
+	if model.Interval != nil {
+		if value, ok := responseNumber(mlResp[model.Interval.Value]); ok {
+			obs.Value = &value
+			lower, okLower := responseNumber(mlResp[model.Interval.Lower])
+			upper, okUpper := responseNumber(mlResp[model.Interval.Upper])
+			if okLower && okUpper && value != 0 {
+				width := (upper - lower) / math.Abs(value)
+				obs.Width = &width
+			}
+		}
+	}
+	Drift.Observe(model.Name, obs)
+}
+
+// DriftHandler handles GET /api/v1/metrics/drift, comparing each model's
+// recent predictions with its baseline period. ?model= limits the report
+// to one model.
+func DriftHandler(c *gin.Context) {
+	if Drift == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Drift monitoring disabled",
+			Details: "Set DRIFT_WINDOW to enable it",
+		})
+		return
+	}
+	report := Drift.Report()
+	if name := c.Query("model"); name != "" {
+		if _, perr := lookupModel(name); perr != nil {
+			c.JSON(perr.Status, perr.Response)
+			return
+		}
+		for m := range report.Models {
+			if m != name {
+				delete(report.Models, m)
+			}
+		}
+	}
+	c.JSON(http.StatusOK, report)
+}
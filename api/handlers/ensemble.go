@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"cloud-ai-api/ensemble"
+	"cloud-ai-api/hooks"
+	"cloud-ai-api/models"
+	"cloud-ai-api/registry"
+	"github.com/gin-gonic/gin"
+)
+
+// Ensembles holds the configured ensemble for each model that has one
+var Ensembles map[string]*ensemble.Ensemble
+
+// CheckEnsembles verifies that every ensemble names a registered model
+// and a value field to combine
+func CheckEnsembles(ensembles map[string]*ensemble.Ensemble) error {
+	for name, e := range ensembles {
+		model, ok := Registry.Get(name)
+		if !ok {
+			return fmt.Errorf("ensemble %q: unknown model", name)
+		}
+		if model.StreamResponse {
+			return fmt.Errorf("ensemble %q: streamed models cannot be ensembled", name)
+		}
+		if e.Value == "" && model.Interval == nil {
+			return fmt.Errorf("ensemble %q: value is required as the model declares no interval", name)
+		}
+	}
+	return nil
+}
+
+// EnsembleHandler handles POST /api/v1/predict/:model/ensemble. It sends
+// the request to every member of the model's ensemble in parallel and
+// returns their predictions with a combined estimate. The combine query
+// parameter overrides the ensemble's default method.
+func EnsembleHandler(c *gin.Context) {
+	startTime := time.Now()
+
+	model, payload, perr := parseBodyRequest(c)
+	if perr != nil {
+		respond(c, perr.Status, perr.Response)
+		return
+	}
+	ens, ok := Ensembles[model.Name]
+	if !ok {
+		respond(c, http.StatusNotFound, models.ErrorResponse{
+			Error:   "No ensemble configured",
+			Details: fmt.Sprintf("The %s model has no ensemble", model.Name),
+		})
+		return
+	}
+	combine := ens.Combine
+	if method := c.Query("combine"); method != "" {
+		if !ensemble.ValidCombine(method) {
+			respond(c, http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid combine method",
+				Details: "Must be one of: mean, median, weighted",
+			})
+			return
+		}
+		combine = method
+	}
+
+	if perr := checkModelEnabled(c.Request, model); perr != nil {
+		respond(c, perr.Status, perr.Response)
+		return
+	}
+	warnings, perr := prepareRequest(c.Request, model, payload, flagEnabled(FlagStrictValidation, c.Request))
+	if perr != nil {
+		respond(c, perr.Status, perr.Response)
+		return
+	}
+
+	field := ens.Value
+	if field == "" {
+		field = model.Interval.Value
+	}
+	members := callEnsemble(c.Request, model, ens, field, payload)
+
+	var values, weights []float64
+	for _, m := range members {
+		if m.Value != nil {
+			values = append(values, *m.Value)
+			weights = append(weights, m.Weight)
+		}
+	}
+	Metrics.Incr("ensemble.predictions", "model:"+model.Name, "combine:"+combine, "failed:"+strconv.Itoa(len(members)-len(values)))
+	if len(values) == 0 {
+		perr := &predictionError{http.StatusBadGateway, models.CodeMLServiceError, models.ErrorResponse{
+			Error:   "ML service error",
+			Details: "Every ensemble member failed",
+		}}
+		reportPredictionError(c, model, payload, perr)
+		respond(c, perr.Status, perr.Response)
+		return
+	}
+
+	resp := models.EnsembleResponse{
+		Model:     model.Name,
+		Combine:   combine,
+		Field:     field,
+		Estimate:  ensemble.Combine(combine, values, weights),
+		Succeeded: len(values),
+		Failed:    len(members) - len(values),
+		Members:   members,
+		Warnings:  warnings,
+	}
+	resp.Spread = models.EnsembleSpread{Min: values[0], Max: values[0], StdDev: ensemble.StdDev(values)}
+	for _, v := range values[1:] {
+		if v < resp.Spread.Min {
+			resp.Spread.Min = v
+		}
+		if v > resp.Spread.Max {
+			resp.Spread.Max = v
+		}
+	}
+
+	resp.RequestID = recordPrediction(usageCaller(c.Request), model, payload, map[string]interface{}{
+		field:       resp.Estimate,
+		"ensemble":  combine,
+		"succeeded": resp.Succeeded,
+		"failed":    resp.Failed,
+	}, startTime)
+	resp.ProcessingTimeMs = float64(time.Since(startTime).Milliseconds())
+	respond(c, http.StatusOK, resp)
+}
+
+// callEnsemble queries every member in parallel, each through the ML call
+// gate, returning their results in declaration order
+func callEnsemble(r *http.Request, model *registry.Model, ens *ensemble.Ensemble, field string, payload map[string]interface{}) []models.EnsembleMember {
+	results := make([]models.EnsembleMember, len(ens.Members))
+	var wg sync.WaitGroup
+	for i, member := range ens.Members {
+		wg.Add(1)
+		go func(i int, member ensemble.Member) {
+			defer wg.Done()
+			result := models.EnsembleMember{Name: member.Name, Weight: member.Weight}
+			defer func() { results[i] = result }()
+
+			release, perr := acquireML(r)
+			if perr != nil {
+				result.Error = perr.Response.Details
+				return
+			}
+			start := time.Now()
+			mlResp, err := callEnsembleMember(model, member, payload)
+			release()
+			latency := time.Since(start)
+			observeMLCall(r, model.Name, payload, latency, err)
+			result.LatencyMs = float64(latency.Microseconds()) / 1000
+			if err == nil {
+				err = runHooks(r, model, hooks.PostResponse, mlResp)
+			}
+			if err != nil {
+				result.Error = err.Error()
+				return
+			}
+
+			value, ok := responseNumber(mlResp[field])
+			if !ok {
+				result.Error = fmt.Sprintf("response has no numeric %q field", field)
+				return
+			}
+			result.Value = &value
+			result.Prediction = mlResp
+		}(i, member)
+	}
+	wg.Wait()
+	return results
+}
+
+// callEnsembleMember sends a payload to one member's backend and path,
+// defaulting to the ML service and the model's ml_path
+func callEnsembleMember(model *registry.Model, member ensemble.Member, payload map[string]interface{}) (map[string]interface{}, error) {
+	base := member.URL
+	if base == "" {
+		var err error
+		if base, err = mlBaseURL(""); err != nil {
+			return nil, err
+		}
+	}
+	path := member.MLPath
+	if path == "" {
+		path = model.MLPath
+	}
+	resp, err := postMLURL(base+path, payload)
+	if err != nil {
+		return nil, err
+	}
+	return readMLResponse(model, resp)
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"cloud-ai-api/history"
+	"cloud-ai-api/jobs"
+	"cloud-ai-api/models"
+	"cloud-ai-api/usage"
+	"cloud-ai-api/xlsx"
+	"github.com/gin-gonic/gin"
+)
+
+// ExportHistoryHandler handles GET /api/v1/predictions/export, streaming
+// the authenticated caller's stored predictions as CSV or Excel. Query
+// parameters: format (csv or xlsx), model, since and until (RFC 3339) and
+// limit.
+func ExportHistoryHandler(c *gin.Context) {
+	caller := usageCaller(c.Request)
+	if caller.Tenant == "" && caller.APIKey == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Caller not identified",
+			Details: "Authenticate with an API key to export your predictions",
+		})
+		return
+	}
+	exportHistory(c, &caller)
+}
+
+// AdminExportHistoryHandler handles GET /admin/predictions/export, streaming
+// every caller's stored predictions like ExportHistoryHandler
+func AdminExportHistoryHandler(c *gin.Context) {
+	exportHistory(c, nil)
+}
+
+// exportHistory streams the stored predictions selected by the query
+// parameters, only those made for owner unless it is nil
+func exportHistory(c *gin.Context, owner *usage.Caller) {
+	// Parse filters
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid format",
+			Details: "Must be one of: csv, xlsx",
+			Fields:  []string{"format"},
+		})
+		return
+	}
+
+	filter, field, err := historyFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid " + field,
+			Details: err.Error(),
+			Fields:  []string{field},
+		})
+		return
+	}
+
+	if owner != nil {
+		filter.Tenant, filter.APIKey = owner.Tenant, owner.APIKey
+	}
+	entries := History.List(filter)
+	requestCols := entryColumns(entries, func(e history.Entry) map[string]interface{} { return e.Request })
+	responseCols := entryColumns(entries, func(e history.Entry) map[string]interface{} { return e.Response })
+
+	header := []interface{}{"id", "timestamp", "model", "latency_ms"}
+	for _, name := range requestCols {
+		header = append(header, "request."+name)
+	}
+	for _, name := range responseCols {
+		header = append(header, "response."+name)
+	}
+	row := func(e history.Entry) []interface{} {
+		values := []interface{}{e.ID, e.Timestamp.Format(time.RFC3339), e.Model, e.LatencyMs}
+		for _, name := range requestCols {
+			values = append(values, e.Request[name])
+		}
+		for _, name := range responseCols {
+			values = append(values, e.Response[name])
+		}
+		return values
+	}
+
+	filename := fmt.Sprintf("predictions-%s.%s", time.Now().Format("20060102-150405"), format)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Status(http.StatusOK)
+		w := csv.NewWriter(c.Writer)
+		write := func(values []interface{}) {
+			record := make([]string, len(values))
+			for i, v := range values {
+				record[i] = jobs.CSVValue(v)
+			}
+			w.Write(record)
+		}
+		write(header)
+		for _, e := range entries {
+			write(row(e))
+		}
+		w.Flush()
+		return
+	}
+
+	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	c.Status(http.StatusOK)
+	w, err := xlsx.NewWriter(c.Writer, "Predictions")
+	if err != nil {
+		return
+	}
+	w.WriteRow(header)
+	for _, e := range entries {
+		values := row(e)
+		for i, v := range values {
+			values[i] = spreadsheetValue(v)
+		}
+		if err := w.WriteRow(values); err != nil {
+			return
+		}
+	}
+	w.Close()
+}
+
+// historyFilter builds a history filter from query parameters, returning the
+// offending parameter name on error
+func historyFilter(c *gin.Context) (history.Filter, string, error) {
+	filter := history.Filter{Model: c.Query("model")}
+	if filter.Model != "" {
+		if _, ok := Registry.Get(filter.Model); !ok {
+			return filter, "model", fmt.Errorf("Must be one of: %v", Registry.Names())
+		}
+	}
+	for _, param := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		if v := c.Query(param.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, param.name, fmt.Errorf("Must be an RFC 3339 timestamp")
+			}
+			*param.dst = t
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return filter, "limit", fmt.Errorf("Must be a positive integer")
+		}
+		filter.Limit = n
+	}
+	return filter, "", nil
+}
+
+// entryColumns returns the sorted union of the keys selected from each entry
+func entryColumns(entries []history.Entry, fields func(history.Entry) map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, e := range entries {
+		for name := range fields(e) {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// spreadsheetValue converts a JSON value to a typed spreadsheet cell value,
+// keeping numbers numeric and writing nested values as JSON text
+func spreadsheetValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case json.Number:
+		if f, err := value.Float64(); err == nil {
+			return f
+		}
+		return value.String()
+	case map[string]interface{}, []interface{}:
+		return jobs.CSVValue(value)
+	}
+	return v
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"cloud-ai-api/cache"
+	"cloud-ai-api/models"
+	"cloud-ai-api/registry"
+)
+
+// fanOutConcurrency caps the predictions one fan-out runs at once
+const fanOutConcurrency = 4
+
+// FanOutCache holds the predictions that leaderboards and reports fan out
+// to, so repeating one for the same input skips the ML service; nil
+// disables it
+var FanOutCache *cache.Cache
+
+// fanOutResult is one fan-out prediction and the model's interval value
+// read from it
+type fanOutResult struct {
+	value  float64
+	resp   map[string]interface{}
+	cached bool
+	perr   *predictionError
+}
+
+// fanOut runs the prediction pipeline for each payload, serving repeats
+// from FanOutCache. Fan-out predictions are not recorded in the history.
+// Results are in payload order.
+func fanOut(r *http.Request, model *registry.Model, payloads []map[string]interface{}, source string) []fanOutResult {
+	results := make([]fanOutResult, len(payloads))
+	slots := make(chan struct{}, fanOutConcurrency)
+	var wg sync.WaitGroup
+	for i, payload := range payloads {
+		wg.Add(1)
+		go func(i int, payload map[string]interface{}) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			var result fanOutResult
+			result.perr = checkDarkLaunch(r, usageCaller(r), model, payload)
+			key := cacheKey(model.Name, payloadHash(payload)) + routeCacheKeySuffix(r, model)
+			if FanOutCache != nil && result.perr == nil {
+				result.resp, result.cached = FanOutCache.Get(key)
+			}
+			if !result.cached && result.perr == nil {
+				result.resp, result.perr = runPipeline(r, model, payload, false)
+				if result.perr == nil && FanOutCache != nil {
+					FanOutCache.Set(key, model.Name, result.resp)
+				}
+			}
+			Metrics.Incr("fanout.predictions", "model:"+model.Name, "source:"+source, "cached:"+strconv.FormatBool(result.cached))
+			if result.perr == nil {
+				value, ok := responseNumber(result.resp[model.Interval.Value])
+				if !ok {
+					result.perr = &predictionError{http.StatusBadGateway, models.CodeMLServiceError, models.ErrorResponse{
+						Error:   "ML service error",
+						Details: fmt.Sprintf("Response has no numeric %q field", model.Interval.Value),
+					}}
+				}
+				result.value = value
+			}
+			results[i] = result
+		}(i, payload)
+	}
+	wg.Wait()
+	return results
+}
+
+// withField returns a copy of payload with field set to value
+func withField(payload map[string]interface{}, field string, value interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(payload)+1)
+	for k, v := range payload {
+		out[k] = v
+	}
+	out[field] = value
+	return out
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cloud-ai-api/flags"
+	"cloud-ai-api/usage"
+)
+
+// Built-in feature flags. Each model also has "model.<name>" and
+// "dark.<name>" flags.
+const (
+	FlagResponseCache    = "response_cache"
+	FlagStrictValidation = "strict_validation"
+)
+
+// Flags holds the feature flags evaluated per request
+var Flags = flags.NewSet(DefaultFlags())
+
+// DefaultFlags returns the built-in flags: every registered model and the
+// response cache are enabled; strict validation and dark launches are off
+func DefaultFlags() map[string]flags.Flag {
+	defaults := map[string]flags.Flag{
+		FlagResponseCache:    {Enabled: true},
+		FlagStrictValidation: {Enabled: false},
+	}
+	for _, name := range Registry.Names() {
+		defaults[modelFlag(name)] = flags.Flag{Enabled: true}
+		defaults[darkFlag(name)] = flags.Flag{Enabled: false}
+	}
+	return defaults
+}
+
+// modelFlag is the name of the flag enabling a model's predictions
+func modelFlag(model string) string {
+	return "model." + model
+}
+
+// flagEnabled evaluates a flag for the request's authenticated tenant and
+// API key and counts the evaluation. r is nil for internal predictions, which use the
+// flag's default.
+func flagEnabled(name string, r *http.Request) bool {
+	return flagEnabledFor(name, usageCaller(r))
+}
+
+// flagEnabledFor evaluates a flag for a caller and counts the evaluation
+func flagEnabledFor(name string, caller usage.Caller) bool {
+	on := Flags.Enabled(name, flags.Caller{Tenant: caller.Tenant, APIKey: caller.APIKey})
+	Metrics.Incr("flags.evaluated", "flag:"+name, "enabled:"+strconv.FormatBool(on))
+	return on
+}
+
+// CheckFlags rejects configured flags that are not built in, which are
+// usually typos
+func CheckFlags(configured map[string]flags.Flag) error {
+	known := DefaultFlags()
+	for name := range configured {
+		if _, ok := known[name]; !ok {
+			return fmt.Errorf("unknown feature flag %q (known: %s)", name, strings.Join(flags.NewSet(known).Names(), ", "))
+		}
+	}
+	return nil
+}
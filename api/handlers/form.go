@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+
+	"cloud-ai-api/registry"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// mimeForm is the content type of HTML form submissions
+const mimeForm = binding.MIMEPOSTForm // application/x-www-form-urlencoded
+
+// maxFormBytes caps the size of a form-encoded request body
+const maxFormBytes = 1 << 20
+
+// decodeFormPayload reads a payload from a form-encoded body. Each form
+// field becomes a request field; like XML, values of fields the model
+// declares as integer, number or boolean are converted to those types.
+// Fields left empty are omitted, so a blank required input is reported as
+// missing, and repeated fields become arrays.
+func decodeFormPayload(body io.Reader, model *registry.Model) (map[string]interface{}, error) {
+	data, err := io.ReadAll(io.LimitReader(body, maxFormBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFormBytes {
+		return nil, fmt.Errorf("form body larger than %d bytes", maxFormBytes)
+	}
+	form, err := url.ParseQuery(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid form body: %w", err)
+	}
+
+	types := fieldTypes(model)
+	payload := make(map[string]interface{}, len(form))
+	for name, values := range form {
+		var present []interface{}
+		for _, v := range values {
+			if v != "" {
+				present = append(present, registry.TypedValue(v, types[name]))
+			}
+		}
+		switch {
+		case len(present) == 1 && types[name] != "array":
+			payload[name] = present[0]
+		case len(present) > 0:
+			payload[name] = present
+		}
+	}
+	return payload, nil
+}
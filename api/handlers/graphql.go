@@ -0,0 +1,434 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud-ai-api/history"
+	"cloud-ai-api/models"
+	"cloud-ai-api/registry"
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// maxTrendMonths caps the number of ML calls a single trend field can make
+const maxTrendMonths = 24
+
+// requestKey carries the incoming HTTP request through GraphQL resolvers
+type requestKey struct{}
+
+// graphQLRequest is the standard GraphQL-over-HTTP request body
+type graphQLRequest struct {
+	Query         string                 `json:"query" form:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName" form:"operationName"`
+}
+
+// GraphQLHandler handles GraphQL queries for predictions, model metadata and history
+func GraphQLHandler(c *gin.Context) {
+	var req graphQLRequest
+	if c.Request.Method == http.MethodGet {
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		if vars := c.Query("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				c.JSON(http.StatusBadRequest, models.ErrorResponse{
+					Error:   "Invalid variables",
+					Details: err.Error(),
+				})
+				return
+			}
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if req.Query == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Missing query",
+		})
+		return
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         graphQLSchema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        context.WithValue(c.Request.Context(), requestKey{}, c.Request),
+	})
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Extensions exposes the HTTP status and validation details of a failed
+// prediction in GraphQL error responses
+func (e *predictionError) Extensions() map[string]interface{} {
+	ext := map[string]interface{}{"status": e.Status}
+	if len(e.Response.Fields) > 0 {
+		ext["fields"] = e.Response.Fields
+	}
+	if len(e.Response.Violations) > 0 {
+		ext["violations"] = e.Response.Violations
+	}
+	return ext
+}
+
+var graphQLSchema = buildGraphQLSchema()
+
+var jsonScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:         "JSON",
+	Description:  "Arbitrary JSON value",
+	Serialize:    func(value interface{}) interface{} { return value },
+	ParseValue:   func(value interface{}) interface{} { return value },
+	ParseLiteral: parseJSONLiteral,
+})
+
+// parseJSONLiteral converts an inline GraphQL literal into a JSON value
+func parseJSONLiteral(value ast.Value) interface{} {
+	switch v := value.(type) {
+	case *ast.ObjectValue:
+		obj := make(map[string]interface{}, len(v.Fields))
+		for _, f := range v.Fields {
+			obj[f.Name.Value] = parseJSONLiteral(f.Value)
+		}
+		return obj
+	case *ast.ListValue:
+		list := make([]interface{}, 0, len(v.Values))
+		for _, item := range v.Values {
+			list = append(list, parseJSONLiteral(item))
+		}
+		return list
+	case *ast.IntValue:
+		return json.Number(v.Value)
+	case *ast.FloatValue:
+		return json.Number(v.Value)
+	case *ast.StringValue:
+		return v.Value
+	case *ast.BooleanValue:
+		return v.Value
+	case *ast.EnumValue:
+		return v.Value
+	}
+	return nil
+}
+
+func buildGraphQLSchema() graphql.Schema {
+	trendPointType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "TrendPoint",
+		Fields: graphql.Fields{
+			"year":             &graphql.Field{Type: graphql.Int},
+			"month":            &graphql.Field{Type: graphql.Int},
+			"price":            &graphql.Field{Type: graphql.Float},
+			"confidence_lower": &graphql.Field{Type: graphql.Float},
+			"confidence_upper": &graphql.Field{Type: graphql.Float},
+		},
+	})
+
+	housingType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "HousingPrediction",
+		Fields: graphql.Fields{
+			"id":                 &graphql.Field{Type: graphql.String},
+			"price":              &graphql.Field{Type: graphql.Float},
+			"price_log":          &graphql.Field{Type: graphql.Float},
+			"confidence_lower":   &graphql.Field{Type: graphql.Float},
+			"confidence_upper":   &graphql.Field{Type: graphql.Float},
+			"model":              &graphql.Field{Type: graphql.String},
+			"features_used":      &graphql.Field{Type: graphql.Int},
+			"prediction_time":    &graphql.Field{Type: graphql.String},
+			"processing_time_ms": &graphql.Field{Type: graphql.Float},
+			"provenance":         &graphql.Field{Type: jsonScalar},
+			"input":              &graphql.Field{Type: jsonScalar},
+			"trend": &graphql.Field{
+				Type:        graphql.NewList(trendPointType),
+				Description: "Predictions for the same property over the preceding months",
+				Args: graphql.FieldConfigArgument{
+					"months": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 12},
+				},
+				Resolve: resolveHousingTrend,
+			},
+		},
+	})
+
+	electricityType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ElectricityPrediction",
+		Fields: graphql.Fields{
+			"id":                 &graphql.Field{Type: graphql.String},
+			"demand_mw":          &graphql.Field{Type: graphql.Float},
+			"datetime":           &graphql.Field{Type: graphql.String},
+			"model":              &graphql.Field{Type: graphql.String},
+			"note":               &graphql.Field{Type: graphql.String},
+			"prediction_time":    &graphql.Field{Type: graphql.String},
+			"processing_time_ms": &graphql.Field{Type: graphql.Float},
+			"provenance":         &graphql.Field{Type: jsonScalar},
+			"input":              &graphql.Field{Type: jsonScalar},
+		},
+	})
+
+	fieldType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ModelField",
+		Fields: graphql.Fields{
+			"name":     &graphql.Field{Type: graphql.String},
+			"type":     &graphql.Field{Type: graphql.String},
+			"required": &graphql.Field{Type: graphql.Boolean},
+			"enum":     &graphql.Field{Type: graphql.NewList(graphql.String)},
+			"min":      &graphql.Field{Type: graphql.Float},
+			"max":      &graphql.Field{Type: graphql.Float},
+		},
+	})
+
+	modelType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Model",
+		Fields: graphql.Fields{
+			"name":        &graphql.Field{Type: graphql.String},
+			"description": &graphql.Field{Type: graphql.String},
+			"ml_path":     &graphql.Field{Type: graphql.String},
+			"fields":      &graphql.Field{Type: graphql.NewList(fieldType)},
+			"schema": &graphql.Field{
+				Type: jsonScalar,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					m := p.Source.(*registry.Model)
+					if len(m.Schema) == 0 {
+						return nil, nil
+					}
+					var schema interface{}
+					err := json.Unmarshal(m.Schema, &schema)
+					return schema, err
+				},
+			},
+		},
+	})
+
+	historyType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "HistoryEntry",
+		Fields: graphql.Fields{
+			"id":         &graphql.Field{Type: graphql.String},
+			"model":      &graphql.Field{Type: graphql.String},
+			"request":    &graphql.Field{Type: jsonScalar},
+			"response":   &graphql.Field{Type: jsonScalar},
+			"latency_ms": &graphql.Field{Type: graphql.Float},
+			"timestamp": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(history.Entry).Timestamp.Format(time.RFC3339), nil
+				},
+			},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"housing": &graphql.Field{
+				Type: housingType,
+				Args: graphql.FieldConfigArgument{
+					"property_type": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"is_new":        &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"duration":      &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"county":        &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"year":          &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+					"month":         &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: resolvePrediction("housing"),
+			},
+			"electricity": &graphql.Field{
+				Type: electricityType,
+				Args: graphql.FieldConfigArgument{
+					"year":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+					"month": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+					"day":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+					"hour":  &graphql.ArgumentConfig{Type: graphql.Int},
+				},
+				Resolve: resolvePrediction("electricity"),
+			},
+			"predict": &graphql.Field{
+				Type:        jsonScalar,
+				Description: "Prediction from any registered model",
+				Args: graphql.FieldConfigArgument{
+					"model": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(jsonScalar)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					input, ok := p.Args["input"].(map[string]interface{})
+					if !ok {
+						return nil, fmt.Errorf("input must be an object")
+					}
+					return runGraphQLPrediction(p.Context, p.Args["model"].(string), input)
+				},
+			},
+			"models": &graphql.Field{
+				Type: graphql.NewList(modelType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return Registry.Models(), nil
+				},
+			},
+			"model": &graphql.Field{
+				Type: modelType,
+				Args: graphql.FieldConfigArgument{
+					"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if m, ok := Registry.Get(p.Args["name"].(string)); ok {
+						return m, nil
+					}
+					return nil, nil
+				},
+			},
+			"history": &graphql.Field{
+				Type: graphql.NewList(historyType),
+				Args: graphql.FieldConfigArgument{
+					"model": &graphql.ArgumentConfig{Type: graphql.String},
+					"limit": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 50},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					// Callers see only the predictions made for them
+					r, _ := p.Context.Value(requestKey{}).(*http.Request)
+					caller := usageCaller(r)
+					if caller.Tenant == "" && caller.APIKey == "" {
+						return nil, fmt.Errorf("authenticate with an API key to see your prediction history")
+					}
+					model, _ := p.Args["model"].(string)
+					limit, _ := p.Args["limit"].(int)
+					return History.List(history.Filter{Model: model, Tenant: caller.Tenant, APIKey: caller.APIKey, Limit: limit}), nil
+				},
+			},
+		},
+	})
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+	if err != nil {
+		panic(fmt.Sprintf("invalid GraphQL schema: %s", err))
+	}
+	return schema
+}
+
+// resolvePrediction returns a resolver that predicts with the named model
+// using the field arguments as the request payload
+func resolvePrediction(modelName string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		payload := make(map[string]interface{}, len(p.Args))
+		for k, v := range p.Args {
+			payload[k] = v
+		}
+		return runGraphQLPrediction(p.Context, modelName, payload)
+	}
+}
+
+// runGraphQLPrediction runs the prediction pipeline and records the result in history
+func runGraphQLPrediction(ctx context.Context, modelName string, payload map[string]interface{}) (map[string]interface{}, error) {
+	startTime := time.Now()
+
+	model, ok := Registry.Get(modelName)
+	if !ok {
+		return nil, fmt.Errorf("model %q is not registered", modelName)
+	}
+
+	r, _ := ctx.Value(requestKey{}).(*http.Request)
+	mlResp, perr := predict(r, model, payload)
+	if perr != nil {
+		return nil, perr
+	}
+
+	mlResp["processing_time_ms"] = float64(time.Since(startTime).Milliseconds())
+	mlResp["prediction_time"] = time.Now().Format(time.RFC3339)
+	id := recordPrediction(usageCaller(r), model, payload, mlResp, startTime)
+
+	result := make(map[string]interface{}, len(mlResp)+2)
+	for k, v := range mlResp {
+		result[k] = v
+	}
+	result["id"] = id
+	result["input"] = payload
+	return result, nil
+}
+
+// resolveHousingTrend predicts the same property for each of the preceding
+// months, skipping months the model rejects
+func resolveHousingTrend(p graphql.ResolveParams) (interface{}, error) {
+	source, _ := p.Source.(map[string]interface{})
+	input, _ := source["input"].(map[string]interface{})
+	model, ok := Registry.Get("housing")
+	if input == nil || !ok {
+		return nil, nil
+	}
+
+	months, _ := p.Args["months"].(int)
+	if months < 1 || months > maxTrendMonths {
+		return nil, fmt.Errorf("months must be between 1 and %d", maxTrendMonths)
+	}
+
+	year, _ := toInt(input["year"])
+	month, _ := toInt(input["month"])
+	r, _ := p.Context.Value(requestKey{}).(*http.Request)
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		points []map[string]interface{}
+	)
+	for i := months; i >= 1; i-- {
+		y, m := year, month-i
+		for m < 1 {
+			m += 12
+			y--
+		}
+
+		payload := make(map[string]interface{}, len(input))
+		for k, v := range input {
+			payload[k] = v
+		}
+		payload["year"] = y
+		payload["month"] = m
+
+		wg.Add(1)
+		go func(y, m int, payload map[string]interface{}) {
+			defer wg.Done()
+			resp, perr := predict(r, model, payload)
+			if perr != nil {
+				return
+			}
+			mu.Lock()
+			points = append(points, map[string]interface{}{
+				"year":             y,
+				"month":            m,
+				"price":            resp["price"],
+				"confidence_lower": resp["confidence_lower"],
+				"confidence_upper": resp["confidence_upper"],
+			})
+			mu.Unlock()
+		}(y, m, payload)
+	}
+	wg.Wait()
+
+	sort.Slice(points, func(i, j int) bool {
+		yi, yj := points[i]["year"].(int), points[j]["year"].(int)
+		if yi != yj {
+			return yi < yj
+		}
+		return points[i]["month"].(int) < points[j]["month"].(int)
+	})
+	return points, nil
+}
+
+func toInt(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	case json.Number:
+		n, err := v.Int64()
+		return int(n), err == nil
+	}
+	return 0, false
+}
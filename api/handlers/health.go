@@ -1,60 +1,82 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"time"
 
-	"github.com/gin-gonic/gin"
+	"cloud-ai-api/buildinfo"
+	"cloud-ai-api/health"
 	"cloud-ai-api/models"
+	"github.com/gin-gonic/gin"
 )
 
+// MLServiceComponent is the health component name of the ML service
+const MLServiceComponent = "ml_service"
+
+// Health monitors the gateway's dependencies in the background; /health
+// serves the latest results
+var Health = health.NewMonitor(10*time.Second, 3*time.Second)
+
 // HealthCheckHandler handles health check requests
 func HealthCheckHandler(c *gin.Context) {
-	// Check ML service health
-	mlHealthy, mlResponse := checkMLServiceHealth()
+	status, components := Health.Report()
 
-	status := "healthy"
-	if !mlHealthy {
-		status = "degraded"
+	resp := models.HealthResponse{
+		Status:     status,
+		Service:    "Cloud AI API Gateway",
+		Version:    buildinfo.Version,
+		Components: components,
+	}
+	for _, comp := range components {
+		if comp.Name != MLServiceComponent {
+			continue
+		}
+		resp.MLServiceHealthy = comp.Status == health.StatusUp
+		resp.MLServiceResponse = "OK"
+		if comp.LastError != "" {
+			resp.MLServiceResponse = comp.LastError
+		}
+		resp.MLServiceLatencyMs = comp.LatencyMs
+		resp.LastChecked = comp.LastChecked
 	}
 
-	c.JSON(http.StatusOK, models.HealthResponse{
-		Status:            status,
-		Service:           "Cloud AI API Gateway",
-		Version:           "1.0.0",
-		MLServiceHealthy:  mlHealthy,
-		MLServiceResponse: mlResponse,
-	})
+	c.JSON(http.StatusOK, resp)
 }
 
-// checkMLServiceHealth checks if ML service is responsive
-func checkMLServiceHealth() (bool, string) {
-	client := http.Client{
-		Timeout: 3 * time.Second,
+// CheckMLService checks that the ML service health endpoint responds with JSON
+func CheckMLService(ctx context.Context) error {
+	base, err := mlBaseURL("")
+	if err != nil {
+		return err
 	}
-
-	resp, err := client.Get(fmt.Sprintf("%s/health", MLServiceURL))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/health", base), nil)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Transport: MLClient.Transport}
+	resp, err := client.Do(req)
 	if err != nil {
-		return false, fmt.Sprintf("Failed to connect: %s", err.Error())
+		return fmt.Errorf("Failed to connect: %s", err.Error())
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return false, fmt.Sprintf("Unhealthy status: %d", resp.StatusCode)
+		return fmt.Errorf("Unhealthy status: %d", resp.StatusCode)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return false, "Failed to read response"
+		return fmt.Errorf("Failed to read response")
 	}
 
 	var healthResp map[string]interface{}
 	if err := json.Unmarshal(body, &healthResp); err != nil {
-		return false, "Invalid response format"
+		return fmt.Errorf("Invalid response format")
 	}
 
-	return true, "OK"
+	return nil
 }
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cloud-ai-api/models"
+	"cloud-ai-api/registry"
+	"github.com/gin-gonic/gin"
+)
+
+// Query parameters selecting the confidence level and quantiles the ML
+// service reports
+const (
+	confidenceLevelParam = "confidence_level"
+	quantilesParam       = "quantiles"
+)
+
+// maxQuantiles caps how many quantiles one request may ask for
+const maxQuantiles = 20
+
+// intervalRequest is a caller's choice of confidence level and quantiles,
+// forwarded to the ML service in place of its default interval
+type intervalRequest struct {
+	Level     float64
+	Quantiles []float64
+}
+
+type intervalRequestKey struct{}
+
+// parseIntervalRequest reads the confidence_level and quantiles query
+// parameters, returning nil if neither is set. The level must be one the
+// model declares, given as a fraction (0.8) or percentage (80); quantiles
+// are a comma-separated list of fractions between 0 and 1.
+func parseIntervalRequest(c *gin.Context, model *registry.Model) (*intervalRequest, *predictionError) {
+	levelParam := c.Query(confidenceLevelParam)
+	quantilesParamValue := c.Query(quantilesParam)
+	if levelParam == "" && quantilesParamValue == "" {
+		return nil, nil
+	}
+	invalid := func(format string, args ...interface{}) *predictionError {
+		return &predictionError{http.StatusBadRequest, models.CodeInvalidRequest, models.ErrorResponse{
+			Error:   "Invalid interval request",
+			Details: fmt.Sprintf(format, args...),
+		}}
+	}
+	if model.Interval == nil || model.StreamResponse {
+		return nil, invalid("The %s model does not report a confidence interval", model.Name)
+	}
+
+	ir := &intervalRequest{}
+	if levelParam != "" {
+		level, err := strconv.ParseFloat(levelParam, 64)
+		if err == nil && level > 1 {
+			level /= 100
+		}
+		matched, ok := matchLevel(model.Interval.Levels, level)
+		if err != nil || !ok {
+			if len(model.Interval.Levels) == 0 {
+				return nil, invalid("The %s model does not support confidence levels", model.Name)
+			}
+			return nil, invalid("confidence_level must be one of: %s", formatLevels(model.Interval.Levels))
+		}
+		ir.Level = matched
+	}
+
+	if quantilesParamValue != "" {
+		if !model.Interval.Quantiles {
+			return nil, invalid("The %s model does not support quantiles", model.Name)
+		}
+		seen := make(map[float64]bool)
+		for _, s := range strings.Split(quantilesParamValue, ",") {
+			q, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil || q <= 0 || q >= 1 {
+				return nil, invalid("quantiles must be numbers between 0 and 1 (exclusive), got %q", s)
+			}
+			if !seen[q] {
+				seen[q] = true
+				ir.Quantiles = append(ir.Quantiles, q)
+			}
+		}
+		if len(ir.Quantiles) > maxQuantiles {
+			return nil, invalid("At most %d quantiles may be requested", maxQuantiles)
+		}
+		sort.Float64s(ir.Quantiles)
+	}
+	return ir, nil
+}
+
+// withIntervalRequest attaches an interval request to r for the pipeline
+// to forward
+func withIntervalRequest(r *http.Request, ir *intervalRequest) *http.Request {
+	if ir == nil {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), intervalRequestKey{}, ir))
+}
+
+// intervalRequestFrom returns the interval request attached to r, if any
+func intervalRequestFrom(r *http.Request) *intervalRequest {
+	if r == nil {
+		return nil
+	}
+	ir, _ := r.Context().Value(intervalRequestKey{}).(*intervalRequest)
+	return ir
+}
+
+// forward adds the requested level and quantiles to the ML request payload
+func (ir *intervalRequest) forward(payload map[string]interface{}) {
+	if ir.Level != 0 {
+		payload[confidenceLevelParam] = ir.Level
+	}
+	if len(ir.Quantiles) > 0 {
+		payload[quantilesParam] = ir.Quantiles
+	}
+}
+
+// cacheKeySuffix distinguishes cached responses by level and quantiles
+func (ir *intervalRequest) cacheKeySuffix() string {
+	if ir == nil {
+		return ""
+	}
+	parts := make([]string, 0, len(ir.Quantiles))
+	for _, q := range ir.Quantiles {
+		parts = append(parts, formatQuantile(q))
+	}
+	return fmt.Sprintf(":level=%s:quantiles=%s", formatQuantile(ir.Level), strings.Join(parts, ","))
+}
+
+// shapeInterval reports the requested level and quantiles in the response:
+// the model's lower and upper fields become an "interval" object carrying
+// the level, and the ML service's quantiles map becomes an ordered list. A
+// response missing a requested value is an ML service error.
+func shapeInterval(model *registry.Model, mlResp map[string]interface{}, ir *intervalRequest) *predictionError {
+	if ir == nil {
+		return nil
+	}
+	missing := func(what string) *predictionError {
+		return &predictionError{http.StatusBadGateway, models.CodeMLServiceError, models.ErrorResponse{
+			Error:   "ML service error",
+			Details: fmt.Sprintf("Response is missing the requested %s", what),
+		}}
+	}
+
+	if ir.Level != 0 {
+		lower, okLower := responseNumber(mlResp[model.Interval.Lower])
+		upper, okUpper := responseNumber(mlResp[model.Interval.Upper])
+		if !okLower || !okUpper {
+			return missing("confidence interval")
+		}
+		delete(mlResp, model.Interval.Lower)
+		delete(mlResp, model.Interval.Upper)
+		mlResp["interval"] = models.PredictionInterval{Level: ir.Level, Lower: lower, Upper: upper}
+	}
+
+	if len(ir.Quantiles) > 0 {
+		raw, _ := mlResp[quantilesParam].(map[string]interface{})
+		quantiles := make([]models.Quantile, 0, len(ir.Quantiles))
+		for _, q := range ir.Quantiles {
+			v, ok := responseNumber(raw[formatQuantile(q)])
+			if !ok {
+				return missing(fmt.Sprintf("quantile %s", formatQuantile(q)))
+			}
+			quantiles = append(quantiles, models.Quantile{Quantile: q, Value: v})
+		}
+		mlResp[quantilesParam] = quantiles
+	}
+	return nil
+}
+
+// matchLevel finds level among levels, allowing for floating-point
+// rounding in percentages such as 95 -> 0.95
+func matchLevel(levels []float64, level float64) (float64, bool) {
+	for _, l := range levels {
+		if math.Abs(l-level) < 1e-9 {
+			return l, true
+		}
+	}
+	return 0, false
+}
+
+func formatLevels(levels []float64) string {
+	parts := make([]string, len(levels))
+	for i, l := range levels {
+		parts[i] = formatQuantile(l)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatQuantile formats a fraction without trailing zeros, e.g. 0.1
+func formatQuantile(q float64) string {
+	return strconv.FormatFloat(q, 'f', -1, 64)
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+
+	"cloud-ai-api/ipfilter"
+	"cloud-ai-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// IPFilter holds the IP allow and deny rules per route
+var IPFilter, _ = ipfilter.Open("", nil)
+
+// ListIPRulesHandler lists the IP allow and deny rules and how many
+// requests each has blocked
+func ListIPRulesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"rules": IPFilter.Rules()})
+}
+
+// UpdateIPRulesHandler replaces the IP allow and deny rules. Rules that
+// would block the caller from this route are refused unless force=true, so
+// an operator cannot lock themselves out by mistake.
+func UpdateIPRulesHandler(c *gin.Context) {
+	var req models.IPRulesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+	rules, err := ipfilter.Compile(req.Rules)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid rules",
+			Details: err.Error(),
+			Fields:  []string{"rules"},
+		})
+		return
+	}
+	clientIP := c.ClientIP()
+	rule, reason := ipfilter.Check(rules, c.Request.URL.Path, net.ParseIP(clientIP))
+	if reason != "" && c.Query("force") != "true" {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Rules would lock you out",
+			Details: fmt.Sprintf("The rule for %s would block your address %s (%s); add ?force=true to apply anyway", rule.Path, clientIP, reason),
+		})
+		return
+	}
+
+	if err := IPFilter.Set(rules); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to update IP rules", Details: err.Error()})
+		return
+	}
+	log.Printf("IP rules updated: rules=%d client_ip=%s", len(rules), clientIP)
+	c.JSON(http.StatusOK, gin.H{"rules": IPFilter.Rules()})
+}
@@ -0,0 +1,365 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/mail"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud-ai-api/jobs"
+	"cloud-ai-api/mailer"
+	"cloud-ai-api/middleware"
+	"cloud-ai-api/models"
+	"cloud-ai-api/objectstore"
+	"cloud-ai-api/usage"
+	"github.com/gin-gonic/gin"
+)
+
+// MaxJobRows is the maximum number of rows accepted in a single batch job
+var MaxJobRows = 10000
+
+// Jobs runs asynchronous batch prediction jobs
+var Jobs = jobs.NewManager(predictRow, exportResults, notifyJob, 4, time.Hour)
+
+// ObjectStores holds the configured object storage clients by destination
+// scheme ("s3" or "gs")
+var ObjectStores = map[string]*objectstore.Client{}
+
+// ExportDestinations are the bucket prefixes batch jobs may export results
+// under; with none, destination exports are refused. A prefix with an empty
+// key allows the whole bucket.
+var ExportDestinations []objectstore.Location
+
+// Mailer sends job notification emails; nil disables notify_email
+var Mailer *mailer.Sender
+
+// NotifyEmailDomains are the domains notify_email may name besides the
+// caller's own verified account address
+var NotifyEmailDomains []string
+
+// NotifyEmailsPerAddress limits how many job notifications one address is
+// sent
+var NotifyEmailsPerAddress = middleware.NewRateLimiter(middleware.RateLimit{RequestsPerSecond: 10.0 / 3600, Burst: 10})
+
+// PublicURL is the externally reachable base URL of the gateway, used for
+// links in notifications
+var PublicURL string
+
+// SubmitJobHandler queues an asynchronous batch prediction job
+func SubmitJobHandler(c *gin.Context) {
+	// Parse request
+	var req models.JobRequest
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Validate model and row count
+	if _, ok := Registry.Get(req.Model); !ok {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Unknown model",
+			Details: fmt.Sprintf("Must be one of: %v", Registry.Names()),
+			Fields:  []string{"model"},
+		})
+		return
+	}
+	if len(req.Rows) == 0 || len(req.Rows) > MaxJobRows {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid rows",
+			Details: fmt.Sprintf("Must contain between 1 and %d rows", MaxJobRows),
+			Fields:  []string{"rows"},
+		})
+		return
+	}
+
+	// Validate export destination
+	if req.Destination != "" {
+		if err := checkDestination(req.Destination); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid destination",
+				Details: err.Error(),
+				Fields:  []string{"destination"},
+			})
+			return
+		}
+	}
+
+	// Validate notification address
+	caller := usageCaller(c.Request)
+	if req.NotifyEmail != "" {
+		if err := checkNotifyEmail(req.NotifyEmail, caller); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid notify_email",
+				Details: err.Error(),
+				Fields:  []string{"notify_email"},
+			})
+			return
+		}
+		if ok, wait := NotifyEmailsPerAddress.Allow(strings.ToLower(req.NotifyEmail)); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, models.ErrorResponse{
+				Error:   "Too many notification emails",
+				Details: "Too many jobs notify this address; retry after the time in the Retry-After header or omit notify_email",
+				Fields:  []string{"notify_email"},
+			})
+			return
+		}
+	}
+
+	owner := jobs.Owner{Tenant: caller.Tenant, APIKey: caller.APIKey}
+	job := Jobs.Submit(owner, req.Model, req.Rows, req.Destination, req.NotifyEmail)
+	c.Header("Location", "/api/v1/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// JobStatusHandler returns the status of a batch job
+func JobStatusHandler(c *gin.Context) {
+	job, ok := Jobs.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Job not found"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// JobResultsHandler returns the per-row results of a finished batch job
+func JobResultsHandler(c *gin.Context) {
+	job, ok := Jobs.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Job not found"})
+		return
+	}
+
+	results, done := Jobs.Results(job.ID)
+	if !done {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Job not finished",
+			Details: fmt.Sprintf("Job is %s", job.Status),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"job_id": job.ID, "results": results})
+}
+
+// JobEventsHandler streams job progress as server-sent events until the job
+// finishes. Events are named "progress", then "completed" or "failed".
+func JobEventsHandler(c *gin.Context) {
+	events, cancel, ok := Jobs.Subscribe(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Job not found"})
+		return
+	}
+	defer cancel()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case ev, open := <-events:
+			if !open {
+				return false
+			}
+			name := "progress"
+			if ev.Done() {
+				name = ev.Status
+			}
+			c.SSEvent(name, ev)
+			return !ev.Done()
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// checkDestination verifies that results can be exported to destination:
+// its store is configured, it lies under one of ExportDestinations and it
+// names a CSV object or a directory
+func checkDestination(destination string) error {
+	loc, err := objectstore.Parse(destination)
+	if err != nil {
+		return err
+	}
+	if ObjectStores[loc.Scheme] == nil {
+		return fmt.Errorf("%s:// export is not configured", loc.Scheme)
+	}
+	for _, segment := range strings.Split(loc.Key, "/") {
+		if segment == "." || segment == ".." {
+			return fmt.Errorf("destination must not contain %q path segments", segment)
+		}
+	}
+	if !exportAllowed(loc) {
+		return fmt.Errorf("%s is not under an allowed export destination", destination)
+	}
+	switch ext := path.Ext(loc.Key); {
+	case loc.Key == "" || strings.HasSuffix(loc.Key, "/") || ext == ".csv":
+	case ext == ".parquet":
+		return fmt.Errorf("Parquet export is not supported; results are exported as .csv")
+	default:
+		return fmt.Errorf("unsupported export format %q; results are exported as .csv", ext)
+	}
+	return nil
+}
+
+// exportAllowed reports whether loc lies under one of ExportDestinations.
+// Prefixes match whole path segments, so "exports" allows "exports/a.csv"
+// but not "exports-other/a.csv".
+func exportAllowed(loc objectstore.Location) bool {
+	for _, allowed := range ExportDestinations {
+		if allowed.Scheme != loc.Scheme || allowed.Bucket != loc.Bucket {
+			continue
+		}
+		prefix := allowed.Key
+		if prefix != "" && !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+		if strings.HasPrefix(loc.Key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// exportResults writes a finished job's results as CSV to its destination.
+// Objects are namespaced under the job ID, so a job never overwrites
+// another's results: "s3://bucket/dir/out.csv" is written as
+// "dir/<job id>/out.csv", and a destination ending in "/" (or a bare
+// bucket) gets "<job id>/results.csv" appended.
+func exportResults(job jobs.Job, results []jobs.Result) (string, error) {
+	if err := checkDestination(job.Destination); err != nil {
+		return "", err
+	}
+	loc, err := objectstore.Parse(job.Destination)
+	if err != nil {
+		return "", err
+	}
+	dir, name := path.Split(loc.Key)
+	if name == "" {
+		name = "results.csv"
+	}
+	loc.Key = dir + job.ID + "/" + name
+
+	var buf bytes.Buffer
+	if err := jobs.WriteCSV(&buf, results); err != nil {
+		return "", fmt.Errorf("failed to encode results: %w", err)
+	}
+	return ObjectStores[loc.Scheme].Put(context.Background(), loc.Bucket, loc.Key, "text/csv", buf.Bytes())
+}
+
+// checkNotifyEmail verifies that a job's completion can be emailed to addr
+// for caller. Anyone may submit jobs, so the address must be the caller's
+// own verified account address or be in one of NotifyEmailDomains;
+// otherwise the gateway would mail whoever a caller names.
+func checkNotifyEmail(addr string, caller usage.Caller) error {
+	if Mailer == nil {
+		return fmt.Errorf("email notification is not configured")
+	}
+	parsed, err := mail.ParseAddress(addr)
+	if err != nil {
+		return fmt.Errorf("not a valid email address")
+	}
+	if parsed.Address != addr {
+		return fmt.Errorf("must be a bare address such as user@example.com")
+	}
+	if Accounts != nil && caller.Tenant != "" {
+		if account, ok := Accounts.Get(caller.Tenant); ok && account.VerifiedAt != nil && strings.EqualFold(account.Email, addr) {
+			return nil
+		}
+	}
+	domain := addr[strings.LastIndex(addr, "@")+1:]
+	for _, allowed := range NotifyEmailDomains {
+		if strings.EqualFold(domain, allowed) {
+			return nil
+		}
+	}
+	return fmt.Errorf("must be your verified account address or in an allowed domain")
+}
+
+// notifyJob reports failed jobs to the operational webhooks and emails a
+// finished job's summary and a link to its results to the address it was
+// submitted with
+func notifyJob(job jobs.Job) error {
+	if job.Status == jobs.StatusFailed || job.ExportError != "" {
+		go notifyJobFailed(job)
+	}
+	if job.NotifyEmail == "" {
+		return nil
+	}
+	if Mailer == nil {
+		return fmt.Errorf("email notification is not configured")
+	}
+
+	subject := fmt.Sprintf("Batch job %s %s", job.ID, job.Status)
+	var b strings.Builder
+	fmt.Fprintf(&b, "Your %s batch job %s has %s.\n\n", job.Model, job.ID, job.Status)
+	fmt.Fprintf(&b, "Rows:       %d\n", job.Total)
+	fmt.Fprintf(&b, "Succeeded:  %d\n", job.Processed-job.Failed)
+	fmt.Fprintf(&b, "Failed:     %d\n", job.Failed)
+	if job.StartedAt != nil && job.CompletedAt != nil {
+		fmt.Fprintf(&b, "Duration:   %s\n", job.CompletedAt.Sub(*job.StartedAt).Round(time.Second))
+	}
+	if len(job.Errors) > 0 {
+		b.WriteString("\nFirst errors:\n")
+		for _, e := range job.Errors[:min(len(job.Errors), 5)] {
+			fmt.Fprintf(&b, "  row %d: %s\n", e.Row, e.Error)
+		}
+	}
+	if job.ExportError != "" {
+		fmt.Fprintf(&b, "\nExport to %s failed: %s\n", job.Destination, job.ExportError)
+	}
+	switch {
+	case job.ResultURL != "":
+		fmt.Fprintf(&b, "\nResults: %s\n", job.ResultURL)
+	case PublicURL != "":
+		fmt.Fprintf(&b, "\nResults: %s/api/v1/jobs/%s/results\n", PublicURL, job.ID)
+		b.WriteString("Results are kept for an hour after the job finishes.\n")
+	default:
+		fmt.Fprintf(&b, "\nResults: GET /api/v1/jobs/%s/results\n", job.ID)
+		b.WriteString("Results are kept for an hour after the job finishes.\n")
+	}
+
+	err := Mailer.Send(job.NotifyEmail, subject, b.String())
+	outcome := "sent"
+	if err != nil {
+		outcome = "failed"
+		log.Printf("WARN job_notify_failed job=%s error=%q", job.ID, err)
+	}
+	Metrics.Incr("job.notifications", "status:"+job.Status, "outcome:"+outcome)
+	return err
+}
+
+// predictRow predicts a single batch job row and records it in history,
+// billing it to the job's owner
+func predictRow(owner jobs.Owner, modelName string, input map[string]interface{}) (map[string]interface{}, error) {
+	startTime := time.Now()
+
+	model, ok := Registry.Get(modelName)
+	if !ok {
+		return nil, fmt.Errorf("model %q is not registered", modelName)
+	}
+
+	caller := usage.Caller{Tenant: owner.Tenant, APIKey: owner.APIKey}
+	if perr := checkDarkLaunch(nil, caller, model, input); perr != nil {
+		return nil, perr
+	}
+	mlResp, perr := predict(nil, model, input)
+	if perr != nil {
+		return nil, perr
+	}
+	chargeUsage(caller, modelName, usage.BatchRow)
+	recordPrediction(caller, model, input, mlResp, startTime)
+	return mlResp, nil
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"cloud-ai-api/models"
+)
+
+// lastMLCall records when each model last called the ML service, so the
+// keep-warm loop can skip models that already have traffic
+var lastMLCall sync.Map
+
+// keepWarm holds the latest keep-warm ping per model
+var keepWarm = struct {
+	sync.RWMutex
+	pings map[string]models.KeepWarmPing
+}{pings: make(map[string]models.KeepWarmPing)}
+
+// StartKeepWarm sends each model's canary payload through the prediction
+// pipeline every interval, unless the model has called the ML service
+// within the interval anyway, so the ML service does not go cold while idle
+func StartKeepWarm(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		// pinged is when each model's last ping finished; calls after it
+		// were real traffic
+		pinged := make(map[string]time.Time)
+		for range ticker.C {
+			for _, model := range Registry.Models() {
+				if last, ok := lastMLCall.Load(model.Name); ok {
+					if at := last.(time.Time); at.After(pinged[model.Name]) && time.Since(at) < interval {
+						continue
+					}
+				}
+				payload, declared := model.CanaryPayload()
+				if !declared {
+					continue
+				}
+
+				start := time.Now()
+				_, perr := predict(nil, model, payload)
+				latency := time.Since(start)
+				pinged[model.Name] = time.Now()
+
+				ping := models.KeepWarmPing{
+					At:        start.UTC().Format(time.RFC3339),
+					LatencyMs: float64(latency.Microseconds()) / 1000,
+				}
+				outcome := "success"
+				if perr != nil {
+					ping.Error = perr.Response.Error + ": " + perr.Response.Details
+					outcome = "error"
+					log.Printf("Keep-warm ping failed: model=%s error=%q", model.Name, ping.Error)
+				}
+				Metrics.Timing("ml.keepwarm.duration", latency, "model:"+model.Name, "outcome:"+outcome)
+
+				keepWarm.Lock()
+				keepWarm.pings[model.Name] = ping
+				keepWarm.Unlock()
+			}
+		}
+	}()
+}
+
+// keepWarmPings returns a copy of the latest keep-warm ping per model
+func keepWarmPings() map[string]models.KeepWarmPing {
+	keepWarm.RLock()
+	defer keepWarm.RUnlock()
+	out := make(map[string]models.KeepWarmPing, len(keepWarm.pings))
+	for name, ping := range keepWarm.pings {
+		out[name] = ping
+	}
+	return out
+}
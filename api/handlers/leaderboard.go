@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"cloud-ai-api/models"
+	"cloud-ai-api/plausibility"
+	"github.com/gin-gonic/gin"
+)
+
+// Query parameters controlling a leaderboard
+const (
+	leaderboardTopParam   = "top"
+	leaderboardOrderParam = "order"
+)
+
+// defaultLeaderboardTop is how many entries a leaderboard returns by default
+const defaultLeaderboardTop = 10
+
+// LeaderboardHandler handles GET /api/v1/predict/:model/leaderboard. The
+// query string gives every request field except the model's leaderboard
+// field, which is set to each of its values in turn; the values are ranked
+// by their predictions, highest first unless order=asc, and the first top
+// (default 10) returned.
+func LeaderboardHandler(c *gin.Context) {
+	startTime := time.Now()
+
+	model, input, perr := parseQueryRequest(c)
+	if perr != nil {
+		respond(c, perr.Status, perr.Response)
+		return
+	}
+	delete(input, leaderboardTopParam)
+	delete(input, leaderboardOrderParam)
+	lb := model.Leaderboard
+	if lb == nil {
+		respond(c, http.StatusNotFound, models.ErrorResponse{
+			Error:   "No leaderboard configured",
+			Details: fmt.Sprintf("The %s model has no leaderboard", model.Name),
+		})
+		return
+	}
+	invalid := func(field, details string) {
+		respond(c, http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid leaderboard request",
+			Details: details,
+			Fields:  []string{field},
+		})
+	}
+	if _, ok := input[lb.Field]; ok {
+		invalid(lb.Field, fmt.Sprintf("%s is set by the leaderboard and must not be given", lb.Field))
+		return
+	}
+	top := defaultLeaderboardTop
+	if v := c.Query(leaderboardTopParam); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			invalid(leaderboardTopParam, "Must be a positive integer")
+			return
+		}
+		top = n
+	}
+	order := c.DefaultQuery(leaderboardOrderParam, "desc")
+	if order != "desc" && order != "asc" {
+		invalid(leaderboardOrderParam, "Must be one of: desc, asc")
+		return
+	}
+	if perr := checkModelEnabled(c.Request, model); perr != nil {
+		respond(c, perr.Status, perr.Response)
+		return
+	}
+
+	payloads := make([]map[string]interface{}, len(lb.Values))
+	for i, name := range lb.Values {
+		payloads[i] = withField(input, lb.Field, name)
+	}
+	results := fanOut(c.Request, model, payloads, "leaderboard")
+
+	board := models.Leaderboard{
+		Model:    model.Name,
+		Field:    lb.Field,
+		RankedBy: model.Interval.Value,
+		Order:    order,
+		Input:    input,
+		Entries:  []models.LeaderboardEntry{},
+	}
+	type rankedValue struct {
+		name string
+		fanOutResult
+	}
+	var ranked []rankedValue
+	for i, r := range results {
+		if r.perr != nil {
+			// Every value shares the rest of the input, so a request error
+			// for one is an error in the input
+			requestError := r.perr.Code == models.CodeValidationFailed || r.perr.Code == models.CodeTransformFailed
+			if requestError && r.perr.Status < http.StatusInternalServerError {
+				respond(c, r.perr.Status, r.perr.Response)
+				return
+			}
+			board.Failed = append(board.Failed, models.LeaderboardFailure{Name: lb.Values[i], Error: r.perr.Response.Details})
+			continue
+		}
+		if r.cached {
+			board.Cached++
+		}
+		ranked = append(ranked, rankedValue{lb.Values[i], r})
+	}
+	Metrics.Incr("leaderboard.requests", "model:"+model.Name, "failed:"+strconv.Itoa(len(board.Failed)))
+	if len(ranked) == 0 {
+		respond(c, http.StatusBadGateway, models.ErrorResponse{
+			Error:   "ML service error",
+			Details: "Every leaderboard prediction failed",
+		})
+		return
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if order == "asc" {
+			return ranked[i].value < ranked[j].value
+		}
+		return ranked[i].value > ranked[j].value
+	})
+	if len(ranked) > top {
+		ranked = ranked[:top]
+	}
+	for i, r := range ranked {
+		entry := models.LeaderboardEntry{Rank: i + 1, Name: r.name, Value: r.value}
+		entry.Warnings, _ = r.resp["warnings"].([]plausibility.Warning)
+		board.Entries = append(board.Entries, entry)
+	}
+	board.ProcessingTimeMs = float64(time.Since(startTime).Milliseconds())
+	respond(c, http.StatusOK, board)
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cloud-ai-api/models"
+	"cloud-ai-api/registry"
+	"github.com/gin-gonic/gin"
+)
+
+// lenientParam is the query parameter a caller sets to have loosely typed
+// field values normalized before validation
+const lenientParam = "lenient"
+
+// parseLenient reads the lenient query parameter, returning false if it is
+// not set
+func parseLenient(c *gin.Context) (bool, *predictionError) {
+	v := c.Query(lenientParam)
+	if v == "" {
+		return false, nil
+	}
+	lenient, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, &predictionError{http.StatusBadRequest, models.CodeInvalidRequest, models.ErrorResponse{
+			Error:   "Invalid lenient",
+			Details: "Must be true or false",
+		}}
+	}
+	return lenient, nil
+}
+
+// applyLenient normalizes the payload in place if the request asks for
+// lenient input. It runs before the payload is hashed, so lenient and
+// strict requests for the same normalized input share cache entries.
+func applyLenient(c *gin.Context, model *registry.Model, payload map[string]interface{}) *predictionError {
+	lenient, perr := parseLenient(c)
+	if perr != nil || !lenient {
+		return perr
+	}
+	coercePayload(model, payload)
+	return nil
+}
+
+// coercePayload converts the values of the model's declared fields to the
+// declared types where they clearly mean the same thing: numbers and
+// booleans given as strings, enum values in any case, and full words the
+// field lists as synonyms ("detached" for "D"). Values that cannot be
+// converted are left for validation to report.
+func coercePayload(model *registry.Model, payload map[string]interface{}) {
+	for _, f := range model.Fields {
+		value, ok := payload[f.Name]
+		if !ok {
+			continue
+		}
+		payload[f.Name] = coerceValue(&f, value)
+	}
+}
+
+func coerceValue(f *registry.Field, value interface{}) interface{} {
+	switch f.Type {
+	case "integer", "number", "boolean":
+		if s, ok := value.(string); ok {
+			return registry.TypedValue(strings.TrimSpace(s), f.Type)
+		}
+	case "string":
+		s, ok := scalarString(value)
+		if !ok {
+			return value
+		}
+		s = strings.TrimSpace(s)
+		if len(f.Enum) == 0 {
+			return s
+		}
+		for _, allowed := range f.Enum {
+			if strings.EqualFold(s, allowed) {
+				return allowed
+			}
+		}
+		if code, ok := f.Synonym(s); ok {
+			return code
+		}
+		return s
+	}
+	return value
+}
+
+// scalarString writes a string, number or boolean value as a string
+func scalarString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number, float64, int, bool:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"cloud-ai-api/models"
+	"cloud-ai-api/registry"
+)
+
+// FeedbackURL is where callers send feedback on a prediction, with {id}
+// standing for the prediction ID. Empty leaves the feedback link out.
+var FeedbackURL string
+
+// predictionQuery returns the query string of the GET form of a
+// prediction: the payload's fields and the reserved parameters shaping the
+// response. It returns false if a field has no query form, such as an
+// object or array. The payload must be the caller's, before hooks ran.
+func predictionQuery(query url.Values, payload map[string]interface{}) (url.Values, bool) {
+	q := url.Values{}
+	for name, values := range query {
+		if reservedParams[name] {
+			q[name] = values
+		}
+	}
+	for name, v := range payload {
+		switch v.(type) {
+		case map[string]interface{}, []interface{}, nil:
+			return nil, false
+		}
+		q.Set(name, fmt.Sprint(v))
+	}
+	return q, true
+}
+
+// predictionLinks returns the resources related to a prediction: its GET
+// permalink (self), the model's metadata, for housing the report charting
+// the same property's price over the preceding year (trend), and where to
+// send feedback on it. self and trend need the prediction's query, and
+// feedback its ID.
+func predictionLinks(model *registry.Model, query url.Values, id string) map[string]models.Link {
+	links := map[string]models.Link{
+		"model": {Href: "/api/v2/models/" + url.PathEscape(model.Name)},
+	}
+	if query != nil {
+		links["self"] = models.Link{Href: "/api/v1/predict/" + url.PathEscape(model.Name) + "?" + query.Encode()}
+		if model.Name == "housing" && model.Interval != nil {
+			trend := url.Values{}
+			for name, values := range query {
+				if !reservedParams[name] {
+					trend[name] = values
+				}
+			}
+			trend.Set("format", "html")
+			links["trend"] = models.Link{Href: "/api/v1/reports/housing?" + trend.Encode(), Type: "text/html"}
+		}
+	}
+	if FeedbackURL != "" && id != "" {
+		links["feedback"] = models.Link{Href: strings.ReplaceAll(FeedbackURL, "{id}", url.PathEscape(id))}
+	}
+	return links
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"cloud-ai-api/i18n"
+	"cloud-ai-api/middleware"
+	"cloud-ai-api/models"
+	"cloud-ai-api/plausibility"
+	"github.com/gin-gonic/gin"
+)
+
+// Messages translates error messages and warnings into the language the
+// caller asked for in Accept-Language
+var Messages = i18n.Default()
+
+// localize returns a copy of a prediction route response with its error
+// messages and warnings in the caller's language. Prediction responses are
+// translated here rather than by LocalizeMiddleware because they may be
+// signed, and because their warnings appear in successful responses.
+func localize(c *gin.Context, obj interface{}) interface{} {
+	lang := middleware.Language(c)
+	if lang == i18n.English {
+		return obj
+	}
+	t := func(s string) string { return Messages.Translate(lang, s) }
+
+	switch v := obj.(type) {
+	case models.ErrorResponse:
+		v.Error = t(v.Error)
+		v.Details = t(v.Details)
+		v.Violations = localizeViolations(v.Violations, t)
+		return v
+	case models.V2Response:
+		if v.Error != nil {
+			e := *v.Error
+			e.Message = t(e.Message)
+			e.Details = t(e.Details)
+			e.Violations = localizeViolations(e.Violations, t)
+			v.Error = &e
+		}
+		v.Meta.Warnings = localizeWarnings(v.Meta.Warnings, t)
+		return v
+	case models.StreamPredictionResponse:
+		if v.Error != nil {
+			e := localize(c, *v.Error).(models.ErrorResponse)
+			v.Error = &e
+		}
+		if v.Result != nil {
+			v.Result = localize(c, v.Result).(map[string]interface{})
+		}
+		return v
+	case models.EnsembleResponse:
+		v.Warnings = localizeWarnings(v.Warnings, t)
+		return v
+	case models.Leaderboard:
+		entries := make([]models.LeaderboardEntry, len(v.Entries))
+		for i, entry := range v.Entries {
+			entry.Warnings = localizeWarnings(entry.Warnings, t)
+			entries[i] = entry
+		}
+		v.Entries = entries
+		return v
+	case map[string]interface{}:
+		// Copied rather than changed in place, as callers may still hold
+		// the response
+		warnings, ok := v["warnings"].([]plausibility.Warning)
+		if !ok {
+			return v
+		}
+		out := make(map[string]interface{}, len(v))
+		for k, value := range v {
+			out[k] = value
+		}
+		out["warnings"] = localizeWarnings(warnings, t)
+		return out
+	}
+	return obj
+}
+
+func localizeViolations(violations []models.Violation, t func(string) string) []models.Violation {
+	if violations == nil {
+		return nil
+	}
+	out := make([]models.Violation, len(violations))
+	for i, v := range violations {
+		v.Message = t(v.Message)
+		out[i] = v
+	}
+	return out
+}
+
+func localizeWarnings(warnings []plausibility.Warning, t func(string) string) []plausibility.Warning {
+	if warnings == nil {
+		return nil
+	}
+	out := make([]plausibility.Warning, len(warnings))
+	for i, w := range warnings {
+		w.Message = t(w.Message)
+		out[i] = w
+	}
+	return out
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"cloud-ai-api/discovery"
+	"cloud-ai-api/mlroute"
+	"cloud-ai-api/registry"
+)
+
+// MLRoutes sends particular tenants' and API keys' predictions to their own
+// ML backends or model versions; nil sends every caller's alike
+var MLRoutes *mlroute.Table
+
+// callerRoute returns the ML route for the request's caller, or nil. r is
+// nil for internal predictions, which are never routed.
+func callerRoute(r *http.Request) *mlroute.Route {
+	if r == nil {
+		return nil
+	}
+	caller := usageCaller(r)
+	route, _ := MLRoutes.Lookup(caller.Tenant, caller.APIKey)
+	return route
+}
+
+// AffinityHeader carries a client-chosen token that keeps its requests on
+// one ML backend, in place of its API key
+const AffinityHeader = "X-Affinity-Token"
+
+// affinityKey returns the key pinning a request for model to an ML backend:
+// the affinity token, or else the X-API-Key header. Affinity only spreads
+// load, so the key need not be authenticated. It is empty for models
+// without affinity, for internal predictions and for callers sending
+// neither.
+func affinityKey(r *http.Request, model *registry.Model) string {
+	if r == nil || !model.Affinity {
+		return ""
+	}
+	if token := r.Header.Get(AffinityHeader); token != "" {
+		return token
+	}
+	return r.Header.Get("X-API-Key")
+}
+
+// mlTarget is an ML service URL to send a prediction to, the region it is
+// in if regions are configured, and whether it is a backend being rolled out
+type mlTarget struct {
+	url    string
+	region string
+	canary bool
+}
+
+// mlTargets returns the URLs to try, in order, for a prediction for model:
+// the caller's route's backend where it has one, otherwise the ML service,
+// or with regions a backend in the caller's region followed by one in each
+// failover region. Calls picked for a rollout try its new backend first. For models with affinity the backend within the ML
+// service or region is the one the caller's affinity key hashes to. The
+// path is the route's for the model, if any, otherwise its ml_path.
+func mlTargets(r *http.Request, model *registry.Model) ([]mlTarget, error) {
+	path, key := model.MLPath, affinityKey(r, model)
+	if route := callerRoute(r); route != nil {
+		path = route.MLPath(model.Name, path)
+		Metrics.Incr("ml.routed", "model:"+model.Name, "route:"+route.ID)
+		if route.URL != "" {
+			return []mlTarget{{url: route.URL + path}}, nil
+		}
+	}
+	var targets []mlTarget
+	if backend, ok := Rollouts.Target(model.Name, key); ok {
+		targets = append(targets, mlTarget{url: backend + path, canary: true})
+	}
+	if MLRegions == nil {
+		base, err := mlBaseURL(key)
+		if err != nil {
+			return nil, err
+		}
+		return append(targets, mlTarget{url: base + path}), nil
+	}
+	for _, name := range MLRegions.Order(callerRegion(r)) {
+		if base, err := MLRegions.Pick(name, key); err == nil {
+			targets = append(targets, mlTarget{url: base + path, region: name})
+		}
+	}
+	if len(targets) == 0 {
+		return nil, discovery.ErrNoBackends
+	}
+	return targets, nil
+}
+
+// routeCacheKeySuffix distinguishes cached responses from a caller's own
+// backend or model version, and for models with affinity those for each
+// affinity key, as the backend's state may differ between clients
+func routeCacheKeySuffix(r *http.Request, model *registry.Model) string {
+	var suffix string
+	if route := callerRoute(r); route != nil {
+		suffix = ":route=" + route.ID
+	}
+	if key := affinityKey(r, model); key != "" {
+		suffix += ":affinity=" + mlroute.KeyHash(key)
+	}
+	return suffix
+}
+
+// CheckMLRoutes rejects routes that override ml_path for models that are
+// not registered
+func CheckMLRoutes(table *mlroute.Table) error {
+	for _, name := range table.Models() {
+		if _, ok := Registry.Get(name); !ok {
+			return fmt.Errorf("models: unknown model %q", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"cloud-ai-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// modelVersions holds the last model version reported for each model
+var modelVersions = struct {
+	sync.Mutex
+	versions map[string]models.ModelVersion
+}{versions: make(map[string]models.ModelVersion)}
+
+// observeModelVersion records a model's version. When it differs from the
+// version seen before, the model was rolled over: its cached predictions
+// are purged and the rollover is logged and counted.
+func observeModelVersion(model, version, source string) {
+	if version == "" {
+		return
+	}
+
+	modelVersions.Lock()
+	prev, known := modelVersions.versions[model]
+	if known && prev.Version == version {
+		modelVersions.Unlock()
+		return
+	}
+	modelVersions.versions[model] = models.ModelVersion{
+		Version: version,
+		Since:   time.Now().UTC().Format(time.RFC3339),
+		Source:  source,
+	}
+	modelVersions.Unlock()
+
+	// The first version seen is a baseline, not a rollover
+	if !known {
+		log.Printf("Model version: model=%s version=%s source=%s", model, version, source)
+		return
+	}
+
+	purged := 0
+	if ResponseCache != nil {
+		purged = ResponseCache.PurgeModel(model)
+	}
+	if FanOutCache != nil {
+		purged += FanOutCache.PurgeModel(model)
+	}
+	log.Printf("model_rollover model=%s from=%s to=%s source=%s purged=%d", model, prev.Version, version, source, purged)
+	Metrics.Incr("model.rollover", "model:"+model, "source:"+source)
+}
+
+// StartModelVersionPoll checks the ML service's model list every interval
+// for version changes, syncing the model catalog
+func StartModelVersionPoll(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for ; ; <-ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			listing, err := fetchModelListing(ctx)
+			cancel()
+			if err != nil {
+				log.Printf("Model version poll failed: %v", err)
+				continue
+			}
+			versions := listingVersions(listing)
+			syncCatalog(listing, versions)
+			for _, name := range Registry.Names() {
+				observeModelVersion(name, versions[name], "poll")
+			}
+		}
+	}()
+}
+
+// fetchModelListing reads the ML service's /models listing: each model's
+// attributes by name
+func fetchModelListing(ctx context.Context) (map[string]map[string]interface{}, error) {
+	base, err := mlBaseURL("")
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := MLClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var listing struct {
+		AvailableModels map[string]map[string]interface{} `json:"available_models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&listing); err != nil {
+		return nil, fmt.Errorf("invalid response format: %w", err)
+	}
+	if listing.AvailableModels == nil {
+		listing.AvailableModels = map[string]map[string]interface{}{}
+	}
+	return listing.AvailableModels, nil
+}
+
+// listingVersions reads each model's version from a /models listing,
+// preferring version or model_version over model_type
+func listingVersions(listing map[string]map[string]interface{}) map[string]string {
+	versions := make(map[string]string, len(listing))
+	for name, info := range listing {
+		for _, field := range []string{"version", "model_version", "model_type"} {
+			if v, ok := info[field].(string); ok && v != "" {
+				versions[name] = v
+				break
+			}
+		}
+	}
+	return versions
+}
+
+// ModelVersionsHandler lists the last version reported for each model
+func ModelVersionsHandler(c *gin.Context) {
+	modelVersions.Lock()
+	defer modelVersions.Unlock()
+	out := make(map[string]models.ModelVersion, len(modelVersions.versions))
+	for name, v := range modelVersions.versions {
+		out[name] = v
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// ModelVersionWebhookHandler accepts a model version notification from the
+// ML service's deployment pipeline
+func ModelVersionWebhookHandler(c *gin.Context) {
+	var req models.ModelVersionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+	if req.Model == "" || req.Version == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Missing required fields",
+			Details: "Required: model, version",
+			Fields:  []string{"model", "version"},
+		})
+		return
+	}
+	if _, ok := Registry.Get(req.Model); !ok {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Unknown model",
+			Details: fmt.Sprintf("Must be one of: %v", Registry.Names()),
+			Fields:  []string{"model"},
+		})
+		return
+	}
+
+	observeModelVersion(req.Model, req.Version, "webhook")
+	if change, ok, err := ModelCatalog.Observe(req.Model, req.Version, "webhook", time.Now().UTC()); err != nil {
+		log.Printf("WARN %v", err)
+	} else if ok {
+		catalogChanged(change)
+	}
+
+	modelVersions.Lock()
+	current := modelVersions.versions[req.Model]
+	modelVersions.Unlock()
+	c.JSON(http.StatusOK, current)
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+
+	"cloud-ai-api/models"
+	"cloud-ai-api/registry"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gin-gonic/gin/render"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Binary content types accepted and produced by the prediction route in
+// addition to JSON and XML. Protobuf bodies are google.protobuf.Struct
+// messages.
+const (
+	mimeProtobuf  = binding.MIMEPROTOBUF // application/x-protobuf
+	mimeProtobuf2 = "application/protobuf"
+	mimeMsgPack   = binding.MIMEMSGPACK2 // application/msgpack
+	mimeMsgPack2  = binding.MIMEMSGPACK  // application/x-msgpack
+)
+
+// msgpackHandle decodes MessagePack maps with string keys and strings as
+// Go strings, matching the shape of decoded JSON
+var msgpackHandle = func() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{}
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	h.RawToString = true
+	return h
+}()
+
+// decodeRequest reads a payload object for model from the request body in
+// the format given by its Content-Type. Protobuf, MessagePack, XML and form
+// payloads are converted to the same representation as JSON so validation
+// behaves identically.
+func decodeRequest(c *gin.Context, model *registry.Model) (map[string]interface{}, error) {
+	switch c.ContentType() {
+	case mimeXML, mimeXML2:
+		return decodeXMLPayload(c.Request.Body, model)
+	case mimeForm:
+		return decodeFormPayload(c.Request.Body, model)
+	case mimeProtobuf, mimeProtobuf2:
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return nil, err
+		}
+		var s structpb.Struct
+		if err := proto.Unmarshal(body, &s); err != nil {
+			return nil, fmt.Errorf("invalid protobuf Struct: %w", err)
+		}
+		return normalizePayload(s.AsMap())
+	case mimeMsgPack, mimeMsgPack2:
+		var payload map[string]interface{}
+		if err := codec.NewDecoder(c.Request.Body, msgpackHandle).Decode(&payload); err != nil {
+			return nil, fmt.Errorf("invalid MessagePack: %w", err)
+		}
+		if payload == nil {
+			return nil, fmt.Errorf("request body must be a MessagePack map")
+		}
+		return normalizePayload(payload)
+	}
+	return decodePayload(c.Request.Body)
+}
+
+// normalizePayload re-decodes a payload through JSON so numbers become
+// json.Number, as they are for JSON requests
+func normalizePayload(payload map[string]interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return decodePayload(bytes.NewReader(data))
+}
+
+// respond writes obj in the format requested by the Accept header, falling
+// back to JSON. XML documents have a <prediction> root, <error> for v1
+// error responses and <response> for the v2 envelope. Messages and
+// warnings are translated into the caller's language first.
+func respond(c *gin.Context, status int, obj interface{}) {
+	obj = localize(c, obj)
+	switch format := c.NegotiateFormat(binding.MIMEJSON, mimeProtobuf, mimeProtobuf2, mimeMsgPack, mimeMsgPack2, mimeXML, mimeXML2); format {
+	case mimeXML, mimeXML2:
+		root := "prediction"
+		switch obj.(type) {
+		case models.ErrorResponse:
+			root = "error"
+		case models.V2Response:
+			root = "response"
+		}
+		buf := getBuffer()
+		defer putBuffer(buf)
+		if err := writeXML(buf, root, obj); err != nil {
+			c.JSON(status, obj)
+			return
+		}
+		c.Data(status, format+"; charset=utf-8", buf.Bytes())
+	case mimeProtobuf, mimeProtobuf2:
+		m, err := plainMap(obj)
+		if err == nil {
+			var s *structpb.Struct
+			if s, err = structpb.NewStruct(m); err == nil {
+				c.ProtoBuf(status, s)
+				return
+			}
+		}
+		c.JSON(status, obj)
+	case mimeMsgPack, mimeMsgPack2:
+		m, err := plainMap(obj)
+		if err != nil {
+			c.JSON(status, obj)
+			return
+		}
+		c.Render(status, render.MsgPack{Data: m})
+	default:
+		c.JSON(status, obj)
+	}
+}
+
+// plainMap converts a response to a map of plain Go values using its JSON
+// field names. Integral numbers become int64 so MessagePack encodes them as
+// integers.
+func plainMap(obj interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var m map[string]interface{}
+	if err := decoder.Decode(&m); err != nil {
+		return nil, err
+	}
+	return plainValue(m).(map[string]interface{}), nil
+}
+
+func plainValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case json.Number:
+		if n, err := value.Int64(); err == nil {
+			return n
+		}
+		f, _ := value.Float64()
+		return f
+	case map[string]interface{}:
+		for k, item := range value {
+			value[k] = plainValue(item)
+		}
+		return value
+	case []interface{}:
+		for i, item := range value {
+			value[i] = plainValue(item)
+		}
+		return value
+	}
+	return v
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"cloud-ai-api/middleware"
+	"cloud-ai-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// maxSuggestions is how many close routes a 404 suggests at most
+const maxSuggestions = 3
+
+// Endpoints holds the public router's routes, set once they are all
+// registered
+var Endpoints = new(middleware.RouteTable)
+
+// publicRoutes returns the routes listed in 404 responses: every route on
+// the public router except admin and debug ones
+func publicRoutes() gin.RoutesInfo {
+	var routes gin.RoutesInfo
+	for _, route := range Endpoints.Routes() {
+		if route.Method == http.MethodHead || strings.HasPrefix(route.Path, "/admin") || strings.HasPrefix(route.Path, "/debug") {
+			continue
+		}
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// NotFoundHandler answers requests matching no route with the routes that
+// exist and those closest to the requested path
+func NotFoundHandler(c *gin.Context) {
+	path := c.Request.URL.Path
+	resp := models.ErrorResponse{
+		Error:       "Not found",
+		Details:     fmt.Sprintf("No route matches %s %s", c.Request.Method, path),
+		Suggestions: suggestRoutes(c.Request.Method, path),
+	}
+	if len(resp.Suggestions) > 0 {
+		resp.Details += fmt.Sprintf("; did you mean %s?", resp.Suggestions[0])
+	}
+	c.JSON(http.StatusNotFound, models.RouteErrorResponse{ErrorResponse: resp, Routes: routeList(publicRoutes())})
+}
+
+// MethodNotAllowedHandler answers requests whose path has routes, but none
+// for the request's method, listing the methods it does have
+func MethodNotAllowedHandler(c *gin.Context) {
+	methods := strings.Join(Endpoints.Methods(c.Request.URL.Path), ", ")
+	c.Header("Allow", methods)
+	c.JSON(http.StatusMethodNotAllowed, models.RouteErrorResponse{
+		ErrorResponse: models.ErrorResponse{
+			Error:   "Method not allowed",
+			Details: fmt.Sprintf("Allowed methods: %s", methods),
+		},
+		Routes: routeList(Endpoints.Match(c.Request.URL.Path)),
+	})
+}
+
+// routeList describes routes as "METHOD /path", naming each registered
+// model in place of :model
+func routeList(routes gin.RoutesInfo) []string {
+	sorted := append(gin.RoutesInfo(nil), routes...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Path != sorted[j].Path {
+			return sorted[i].Path < sorted[j].Path
+		}
+		return sorted[i].Method < sorted[j].Method
+	})
+	list := []string{}
+	for _, route := range sorted {
+		for _, path := range expandModels(route.Path) {
+			list = append(list, route.Method+" "+path)
+		}
+	}
+	return list
+}
+
+// expandModels returns a route pattern once per registered model if it has
+// a :model segment, and as it is otherwise
+func expandModels(pattern string) []string {
+	if !strings.Contains(pattern, "/:model") {
+		return []string{pattern}
+	}
+	var paths []string
+	for _, name := range Registry.Names() {
+		paths = append(paths, strings.Replace(pattern, ":model", name, 1))
+	}
+	return paths
+}
+
+// suggestRoutes returns the paths of up to maxSuggestions routes closest to
+// path by edit distance, preferring routes for method. Parameters other
+// than :model take the request's segment in the same place, so that only
+// the fixed parts of a route count towards its distance.
+func suggestRoutes(method, path string) []string {
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	if suggestions := closestRoutes(path, func(route gin.RouteInfo) bool { return route.Method == method }); len(suggestions) > 0 {
+		return suggestions
+	}
+	return closestRoutes(path, func(gin.RouteInfo) bool { return true })
+}
+
+// closestRoutes returns the paths of up to maxSuggestions included routes
+// within an edit distance of a fifth of path's length, at least 2. Routes
+// ending in a wildcard match too much to be suggested.
+func closestRoutes(path string, include func(gin.RouteInfo) bool) []string {
+	type candidate struct {
+		path     string
+		distance int
+	}
+	limit := len(path) / 5
+	if limit < 2 {
+		limit = 2
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	seen := map[string]bool{}
+	var candidates []candidate
+	for _, route := range publicRoutes() {
+		if !include(route) || strings.Contains(route.Path, "/*") {
+			continue
+		}
+		for _, pattern := range expandModels(route.Path) {
+			p := fillParams(pattern, segments)
+			if seen[p] {
+				continue
+			}
+			seen[p] = true
+			if d := editDistance(p, path); d > 0 && d <= limit {
+				candidates = append(candidates, candidate{p, d})
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].path < candidates[j].path
+	})
+	var suggestions []string
+	for i := 0; i < len(candidates) && i < maxSuggestions; i++ {
+		suggestions = append(suggestions, candidates[i].path)
+	}
+	return suggestions
+}
+
+// fillParams replaces a route pattern's parameters with the request path's
+// segments in the same places, leaving those the path has no segment for
+func fillParams(pattern string, segments []string) string {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	for i, p := range patternSegments {
+		if i < len(segments) && strings.HasPrefix(p, ":") {
+			patternSegments[i] = segments[i]
+		}
+	}
+	return "/" + strings.Join(patternSegments, "/")
+}
+
+// editDistance is the Levenshtein distance between two strings, in bytes
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"cloud-ai-api/health"
+	"cloud-ai-api/jobs"
+	"cloud-ai-api/notify"
+)
+
+// Notifier posts operational events to chat webhooks; nil disables them
+var Notifier *notify.Notifier
+
+// OpsThresholds decide when WatchOperations raises an event. MLUnhealthyAfter
+// is how long the ML service must be down; ErrorRate is the share of
+// requests within the statistics window that failed with a server error,
+// checked once there are MinRequests of them.
+type OpsThresholds struct {
+	MLUnhealthyAfter time.Duration
+	ErrorRate        float64
+	MinRequests      int64
+}
+
+// WatchOperations checks the ML service health and the request error rate
+// every interval until ctx is done, notifying once when a condition starts
+// and once when it clears
+func WatchOperations(ctx context.Context, interval time.Duration, t OpsThresholds) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var downSince time.Time
+	var mlAlerting, errorsAlerting bool
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+
+		// ML service health
+		if Notifier.Enabled(notify.MLUnhealthy) {
+			var ml *health.ComponentStatus
+			_, components := Health.Report()
+			for i := range components {
+				if components[i].Name == MLServiceComponent {
+					ml = &components[i]
+				}
+			}
+			switch {
+			case ml == nil:
+			case ml.Status == health.StatusDown:
+				if downSince.IsZero() {
+					downSince = now
+				}
+				if !mlAlerting && now.Sub(downSince) >= t.MLUnhealthyAfter {
+					mlAlerting = true
+					sendOpsEvent(notify.Event{
+						Kind:  notify.MLUnhealthy,
+						Title: "ML service unhealthy",
+						Text:  fmt.Sprintf("The ML service has failed health checks for %s.", now.Sub(downSince).Round(time.Second)),
+						Fields: []notify.Field{
+							{Name: "Last error", Value: ml.LastError},
+							{Name: "Last success", Value: orNever(ml.LastSuccess)},
+						},
+					})
+				}
+			default:
+				if mlAlerting {
+					sendOpsEvent(notify.Event{
+						Kind:     notify.MLUnhealthy,
+						Title:    "ML service recovered",
+						Text:     fmt.Sprintf("The ML service is passing health checks again after %s.", now.Sub(downSince).Round(time.Second)),
+						Resolved: true,
+					})
+				}
+				mlAlerting = false
+				downSince = time.Time{}
+			}
+		}
+
+		// Server error rate across all routes
+		if Notifier.Enabled(notify.ErrorRate) {
+			var count, failed int64
+			worst, worstRate := "", 0.0
+			for route, s := range RequestStats.Snapshot() {
+				count += s.Count
+				failed += s.Errors
+				if s.Errors > 0 && s.ErrorRate > worstRate {
+					worst, worstRate = route, s.ErrorRate
+				}
+			}
+			var rate float64
+			if count > 0 {
+				rate = float64(failed) / float64(count)
+			}
+			window := RequestStats.Window().String()
+			switch {
+			case !errorsAlerting && count >= t.MinRequests && rate >= t.ErrorRate:
+				errorsAlerting = true
+				sendOpsEvent(notify.Event{
+					Kind:  notify.ErrorRate,
+					Title: "Error rate above threshold",
+					Text:  fmt.Sprintf("%.1f%% of requests in the last %s failed with a server error.", rate*100, window),
+					Fields: []notify.Field{
+						{Name: "Requests", Value: fmt.Sprint(count)},
+						{Name: "Errors", Value: fmt.Sprint(failed)},
+						{Name: "Threshold", Value: fmt.Sprintf("%.1f%%", t.ErrorRate*100)},
+						{Name: "Worst route", Value: fmt.Sprintf("%s (%.1f%%)", worst, worstRate*100)},
+					},
+				})
+			case errorsAlerting && rate < t.ErrorRate:
+				errorsAlerting = false
+				sendOpsEvent(notify.Event{
+					Kind:     notify.ErrorRate,
+					Title:    "Error rate back to normal",
+					Text:     fmt.Sprintf("%.1f%% of requests in the last %s failed with a server error.", rate*100, window),
+					Resolved: true,
+				})
+			}
+		}
+	}
+}
+
+// notifyJobFailed reports a job in which every row failed or whose export
+// failed
+func notifyJobFailed(job jobs.Job) {
+	if !Notifier.Enabled(notify.JobFailed) {
+		return
+	}
+	ev := notify.Event{
+		Kind:  notify.JobFailed,
+		Title: fmt.Sprintf("Batch job %s failed", job.ID),
+		Text:  fmt.Sprintf("All %d rows of the %s batch job failed.", job.Total, job.Model),
+		Fields: []notify.Field{
+			{Name: "Model", Value: job.Model},
+			{Name: "Rows", Value: fmt.Sprint(job.Total)},
+			{Name: "Failed", Value: fmt.Sprint(job.Failed)},
+		},
+	}
+	if job.Status != jobs.StatusFailed {
+		ev.Title = fmt.Sprintf("Batch job %s export failed", job.ID)
+		ev.Text = fmt.Sprintf("The results of the %s batch job could not be written to %s.", job.Model, job.Destination)
+	}
+	if job.ExportError != "" {
+		ev.Fields = append(ev.Fields, notify.Field{Name: "Export error", Value: job.ExportError})
+	}
+	if len(job.Errors) > 0 {
+		ev.Fields = append(ev.Fields, notify.Field{Name: "First error", Value: job.Errors[0].Error})
+	}
+	sendOpsEvent(ev)
+}
+
+// sendOpsEvent posts an event, logging webhooks that fail
+func sendOpsEvent(ev notify.Event) {
+	err := Notifier.Notify(ev)
+	outcome := "sent"
+	if err != nil {
+		outcome = "failed"
+		log.Printf("WARN notify_failed kind=%s error=%q", ev.Kind, err)
+	}
+	Metrics.Incr("notify.events", "kind:"+ev.Kind, "resolved:"+strconv.FormatBool(ev.Resolved), "outcome:"+outcome)
+}
+
+// orNever shows an empty timestamp as "never"
+func orNever(ts string) string {
+	if ts == "" {
+		return "never"
+	}
+	return ts
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"cloud-ai-api/middleware"
+	"cloud-ai-api/models"
+	"cloud-ai-api/oidc"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminOIDC authenticates admin API callers against the corporate identity
+// provider, or is nil if the admin API uses only the static token
+var AdminOIDC *oidc.Provider
+
+// adminLoginCookie holds the state of a login between the redirect to the
+// identity provider and its callback
+const adminLoginCookie = "admin_login"
+
+// AdminLoginHandler handles GET /admin/login, redirecting the browser to
+// the identity provider. next is where to return after logging in.
+func AdminLoginHandler(c *gin.Context) {
+	next := c.DefaultQuery("next", "/admin/whoami")
+	if !strings.HasPrefix(next, "/admin") || strings.HasPrefix(next, "//") {
+		next = "/admin/whoami"
+	}
+	target, login, err := AdminOIDC.StartLogin(c.Request.Context(), next)
+	if err != nil {
+		log.Printf("WARN admin_login_failed error=%q", err)
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+			Error:   "Identity provider unavailable",
+			Details: err.Error(),
+		})
+		return
+	}
+	setAdminCookie(c, adminLoginCookie, AdminOIDC.Sign(login), 600)
+	c.Redirect(http.StatusFound, target)
+}
+
+// AdminCallbackHandler handles GET /admin/callback, completing the login
+// and starting an admin session
+func AdminCallbackHandler(c *gin.Context) {
+	if e := c.Query("error"); e != "" {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "Login failed",
+			Details: strings.TrimSpace(e + ": " + c.Query("error_description")),
+		})
+		return
+	}
+	var login oidc.Login
+	cookie, err := c.Cookie(adminLoginCookie)
+	if err != nil || !AdminOIDC.Unsign(cookie, &login) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Login failed",
+			Details: "No login in progress; start again at /admin/login",
+		})
+		return
+	}
+	setAdminCookie(c, adminLoginCookie, "", -1)
+
+	id, err := AdminOIDC.FinishLogin(c.Request.Context(), login, c.Query("state"), c.Query("code"))
+	if err != nil {
+		status := http.StatusUnauthorized
+		if errors.Is(err, oidc.ErrNoRole) {
+			status = http.StatusForbidden
+		}
+		log.Printf("WARN admin_login_failed sub=%q error=%q", id.Subject, err)
+		c.JSON(status, models.ErrorResponse{
+			Error:   "Login failed",
+			Details: err.Error(),
+		})
+		return
+	}
+	log.Printf("Admin login: sub=%s email=%s role=%s", id.Subject, id.Email, id.Role)
+	setAdminCookie(c, middleware.AdminSessionCookie, AdminOIDC.Session(id), 0)
+	c.Redirect(http.StatusFound, login.Next)
+}
+
+// AdminLogoutHandler handles GET /admin/logout, ending the admin session
+func AdminLogoutHandler(c *gin.Context) {
+	setAdminCookie(c, middleware.AdminSessionCookie, "", -1)
+	c.JSON(http.StatusOK, gin.H{"status": "logged_out"})
+}
+
+// AdminWhoAmIHandler handles GET /admin/whoami, returning the caller's
+// identity and role
+func AdminWhoAmIHandler(c *gin.Context) {
+	id, _ := c.Get(middleware.AdminIdentityKey)
+	c.JSON(http.StatusOK, id)
+}
+
+// setAdminCookie sets an HTTP-only cookie scoped to the admin API, secure
+// when the gateway is served over HTTPS. A negative maxAge deletes it.
+func setAdminCookie(c *gin.Context, name, value string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(name, value, maxAge, "/admin", "", strings.HasPrefix(PublicURL, "https://"), true)
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"cloud-ai-api/models"
+	"cloud-ai-api/plausibility"
+	"cloud-ai-api/registry"
+)
+
+// Plausibility flags inputs outside the data each model was trained on;
+// nil disables the check
+var Plausibility = plausibility.Default()
+
+// RejectImplausible rejects implausible inputs with 422 instead of
+// returning the prediction with warnings
+var RejectImplausible bool
+
+// checkPlausibility returns the plausibility warnings for a validated
+// payload, or an error listing them if RejectImplausible is set
+func checkPlausibility(model *registry.Model, payload map[string]interface{}) ([]plausibility.Warning, *predictionError) {
+	if Plausibility == nil {
+		return nil, nil
+	}
+	warnings := Plausibility.Check(model.Name, payload)
+	if len(warnings) == 0 {
+		return nil, nil
+	}
+	for _, w := range warnings {
+		Metrics.Incr("prediction.implausible", "model:"+model.Name, "code:"+w.Code, "rejected:"+strconv.FormatBool(RejectImplausible))
+	}
+	if !RejectImplausible {
+		return warnings, nil
+	}
+
+	resp := models.ErrorResponse{
+		Error:   "Implausible input",
+		Details: "The input lies outside the model's training data",
+	}
+	seen := make(map[string]bool)
+	for _, w := range warnings {
+		for _, f := range w.Fields {
+			if !seen[f] {
+				seen[f] = true
+				resp.Fields = append(resp.Fields, f)
+			}
+			resp.Violations = append(resp.Violations, models.Violation{Pointer: "/" + f, Message: w.Message})
+		}
+	}
+	return nil, &predictionError{http.StatusUnprocessableEntity, models.CodeImplausibleInput, resp}
+}
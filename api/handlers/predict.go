@@ -0,0 +1,791 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud-ai-api/cache"
+	"cloud-ai-api/discovery"
+	"cloud-ai-api/errorreport"
+	"cloud-ai-api/events"
+	"cloud-ai-api/history"
+	"cloud-ai-api/hooks"
+	"cloud-ai-api/middleware"
+	"cloud-ai-api/mlcontract"
+	"cloud-ai-api/models"
+	"cloud-ai-api/plausibility"
+	"cloud-ai-api/registry"
+	"cloud-ai-api/usage"
+	"github.com/gin-gonic/gin"
+)
+
+// MLServiceURL is the URL of the Python ML service
+var MLServiceURL = "http://ml-service:5000"
+
+// MLBackends, if set, is the discovered pool of ML service instances used
+// in place of MLServiceURL
+var MLBackends *discovery.Pool
+
+// MaxMLResponseBytes caps the size of ML service responses read into
+// memory; 0 means no limit. Streamed responses are not limited.
+var MaxMLResponseBytes int64 = 10 << 20
+
+// errMLResponseTooLarge marks an ML response over MaxMLResponseBytes
+var errMLResponseTooLarge = errors.New("ML service response too large")
+
+// MLClient is used for prediction requests to the ML service
+var MLClient = &http.Client{Timeout: 30 * time.Second}
+
+// ErrorReporter sends server errors to an error tracker
+var ErrorReporter errorreport.Reporter = errorreport.Nop{}
+
+// ResponseCache caches prediction responses by model and payload; nil
+// disables caching
+var ResponseCache *cache.Cache
+
+// Registry holds the models served by the prediction route
+var Registry = registry.Default()
+
+// RegistryLoaded is when Registry was loaded. Deterministic GET responses
+// are cached relative to it.
+var RegistryLoaded = time.Now()
+
+// History stores recent successful predictions
+var History = history.NewStore(1000)
+
+// Events publishes successful predictions to external consumers
+var Events events.Publisher = events.Nop{}
+
+// PredictionHandler handles prediction requests for any registered model.
+// Requests and responses are JSON unless Content-Type/Accept select
+// Protobuf, MessagePack or XML; requests may also be form-encoded.
+func PredictionHandler(c *gin.Context) {
+	startTime := time.Now()
+
+	model, payload, perr := parseBodyRequest(c)
+	if perr != nil {
+		respond(c, perr.Status, perr.Response)
+		return
+	}
+	servePrediction(c, model, payload, startTime)
+}
+
+// PredictionQueryHandler handles GET prediction requests whose fields are
+// given as query parameters, e.g. /api/v1/predict/housing?county=KENT&year=2024.
+// Values are converted to the types the model declares for them.
+func PredictionQueryHandler(c *gin.Context) {
+	startTime := time.Now()
+
+	model, payload, perr := parseQueryRequest(c)
+	if perr != nil {
+		respond(c, perr.Status, perr.Response)
+		return
+	}
+	servePrediction(c, model, payload, startTime)
+}
+
+// servePrediction runs the prediction pipeline for a decoded payload and
+// writes the v1 response
+func servePrediction(c *gin.Context, model *registry.Model, payload map[string]interface{}, startTime time.Time) {
+	if perr := applyLenient(c, model, payload); perr != nil {
+		respond(c, perr.Status, perr.Response)
+		return
+	}
+	if flagEnabled(darkFlag(model.Name), c.Request) {
+		accepted, perr := runDarkLaunch(c, model, payload)
+		if perr != nil {
+			respond(c, perr.Status, perr.Response)
+			return
+		}
+		respond(c, http.StatusAccepted, accepted)
+		return
+	}
+
+	minConfidence, perr := parseMinConfidence(c, model)
+	if perr != nil {
+		respond(c, perr.Status, perr.Response)
+		return
+	}
+	interval, perr := parseIntervalRequest(c, model)
+	if perr != nil {
+		respond(c, perr.Status, perr.Response)
+		return
+	}
+	c.Request = withIntervalRequest(c.Request, interval)
+	priceFormat, perr := parsePriceFormat(c, model)
+	if perr != nil {
+		respond(c, perr.Status, perr.Response)
+		return
+	}
+
+	if model.StreamResponse {
+		if perr := streamPrediction(c, model, payload, startTime, "", nil); perr != nil {
+			respond(c, perr.Status, perr.Response)
+		}
+		return
+	}
+
+	query, _ := predictionQuery(c.Request.URL.Query(), payload)
+	mlResp, id, perr := runPrediction(c, model, payload, startTime)
+	if perr == nil {
+		perr = checkConfidence(model, mlResp, minConfidence)
+	}
+	if perr == nil {
+		perr = shapeInterval(model, mlResp, interval)
+	}
+	if perr == nil {
+		perr = addFormattedPrice(model, mlResp, priceFormat)
+	}
+	if perr != nil {
+		respond(c, perr.Status, perr.Response)
+		return
+	}
+	// mlResp is the map cached and recorded in history, so links go on a
+	// copy; fields then applies to them like any other field
+	resp := make(map[string]interface{}, len(mlResp)+1)
+	for k, v := range mlResp {
+		resp[k] = v
+	}
+	resp["_links"] = predictionLinks(model, query, id)
+	respond(c, http.StatusOK, selectFields(resp, c.Query("fields")))
+}
+
+// parseBodyRequest resolves the route's model and decodes the request body.
+// It is shared by every API version.
+func parseBodyRequest(c *gin.Context) (*registry.Model, map[string]interface{}, *predictionError) {
+	// Look up model
+	model, perr := lookupRouteModel(c)
+	if perr != nil {
+		return nil, nil, perr
+	}
+
+	// Parse request
+	payload, err := decodeRequest(c, model)
+	if err != nil {
+		return nil, nil, &predictionError{http.StatusBadRequest, models.CodeInvalidRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		}}
+	}
+	return model, payload, nil
+}
+
+// parseQueryRequest resolves the route's model and maps query parameters
+// onto the request. It is shared by every API version.
+func parseQueryRequest(c *gin.Context) (*registry.Model, map[string]interface{}, *predictionError) {
+	// Look up model
+	model, perr := lookupRouteModel(c)
+	if perr != nil {
+		return nil, nil, perr
+	}
+
+	return model, queryPayload(c, model), nil
+}
+
+// queryPayload maps query parameters other than the reserved ones onto a
+// request for model, converting values to the declared field types
+func queryPayload(c *gin.Context, model *registry.Model) map[string]interface{} {
+	types := fieldTypes(model)
+	payload := make(map[string]interface{})
+	for name, values := range c.Request.URL.Query() {
+		if reservedParams[name] || len(values) == 0 {
+			continue
+		}
+		payload[name] = registry.TypedValue(values[0], types[name])
+	}
+	return payload
+}
+
+// reservedParams are query parameters that control the response rather
+// than supply request fields
+var reservedParams = map[string]bool{
+	"fields":             true,
+	minConfidenceParam:   true,
+	confidenceLevelParam: true,
+	quantilesParam:       true,
+	localeParam:          true,
+	currencyParam:        true,
+	lenientParam:         true,
+
+	middleware.EnvelopeParam: true,
+}
+
+// lookupModel finds a registered model
+func lookupModel(name string) (*registry.Model, *predictionError) {
+	model, ok := Registry.Get(name)
+	if !ok {
+		return nil, &predictionError{http.StatusNotFound, models.CodeUnknownModel, models.ErrorResponse{
+			Error:   "Unknown model",
+			Details: fmt.Sprintf("Must be one of: %s", strings.Join(Registry.Names(), ", ")),
+		}}
+	}
+	return model, nil
+}
+
+// lookupRouteModel finds the model named in the route, suggesting paths
+// close to the request's if there is none
+func lookupRouteModel(c *gin.Context) (*registry.Model, *predictionError) {
+	model, perr := lookupModel(c.Param("model"))
+	if perr != nil {
+		perr.Response.Suggestions = suggestRoutes(c.Request.Method, c.Request.URL.Path)
+	}
+	return model, perr
+}
+
+// runPrediction runs the prediction pipeline, adds timing fields and records
+// the prediction, returning the response and its history ID. It is shared
+// by every API version.
+func runPrediction(c *gin.Context, model *registry.Model, payload map[string]interface{}, startTime time.Time) (map[string]interface{}, string, *predictionError) {
+	// Run prediction pipeline
+	hash := payloadHash(payload)
+	c.Set(middleware.PayloadFingerprintKey, hash[:16])
+
+	if perr := checkModelEnabled(c.Request, model); perr != nil {
+		return nil, "", perr
+	}
+	if perr := checkDarkLaunch(c.Request, usageCaller(c.Request), model, payload); perr != nil {
+		return nil, "", perr
+	}
+	if perr := checkAnomaly(c, model, payload, hash[:16]); perr != nil {
+		return nil, "", perr
+	}
+	captureRequest(c, model, payload)
+
+	// Serve repeated inputs from the response cache. Cached responses skip
+	// validation, so strict callers always go through the pipeline.
+	var mlResp map[string]interface{}
+	key := cacheKey(model.Name, hash) + intervalRequestFrom(c.Request).cacheKeySuffix() + routeCacheKeySuffix(c.Request, model)
+	strict := flagEnabled(FlagStrictValidation, c.Request)
+	useCache := ResponseCache != nil && !strict && flagEnabled(FlagResponseCache, c.Request)
+	if useCache {
+		if cached, ok := ResponseCache.Get(key); ok {
+			c.Header("X-Cache", "HIT")
+			chargeUsage(usageCaller(c.Request), model.Name, usage.CacheHit)
+			markCacheHit(cached)
+			mlResp = cached
+		} else {
+			c.Header("X-Cache", "MISS")
+		}
+	}
+
+	if mlResp == nil {
+		var perr *predictionError
+		mlResp, perr = runPipeline(c.Request, model, payload, strict)
+		if perr != nil {
+			if perr.Status >= http.StatusInternalServerError {
+				reportPredictionError(c, model, payload, perr)
+			}
+			return nil, "", perr
+		}
+		if useCache {
+			ResponseCache.Set(key, model.Name, mlResp)
+		}
+	}
+
+	// Add processing time
+	mlResp["processing_time_ms"] = float64(time.Since(startTime).Milliseconds())
+	mlResp["prediction_time"] = time.Now().Format(time.RFC3339)
+
+	id := recordPrediction(usageCaller(c.Request), model, payload, mlResp, startTime)
+	return mlResp, id, nil
+}
+
+// reportPredictionError sends a failed prediction to the error tracker with
+// its request context and sanitized payload
+func reportPredictionError(c *gin.Context, model *registry.Model, payload map[string]interface{}, perr *predictionError) {
+	ErrorReporter.Capture(errorreport.Event{
+		Message:   perr.Response.Error,
+		Err:       fmt.Errorf("%s: %s", perr.Code, perr.Response.Details),
+		Method:    c.Request.Method,
+		Route:     c.FullPath(),
+		Path:      c.Request.URL.Path,
+		RequestID: middleware.RequestID(c),
+		Status:    perr.Status,
+		Model:     model.Name,
+		Payload:   payload,
+	})
+	c.Set(middleware.ErrorReportedKey, true)
+}
+
+// payloadHash is the hex SHA-256 of a payload's canonical JSON encoding
+// (object keys sorted), so identical inputs share a hash
+func payloadHash(payload map[string]interface{}) string {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		data = []byte(fmt.Sprint(payload))
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// payloadFingerprint is a short payload hash for logs
+func payloadFingerprint(payload map[string]interface{}) string {
+	return payloadHash(payload)[:16]
+}
+
+// cacheKey is the response cache key for a model and payload hash
+func cacheKey(model, hash string) string {
+	return model + ":" + hash
+}
+
+// fieldTypes maps the model's declared field names to their types
+func fieldTypes(model *registry.Model) map[string]string {
+	types := make(map[string]string, len(model.Fields))
+	for _, f := range model.Fields {
+		types[f.Name] = f.Type
+	}
+	return types
+}
+
+// selectFields prunes a response to the comma-separated top-level fields in
+// fields. An empty list keeps every field; unknown names are ignored.
+func selectFields(resp map[string]interface{}, fields string) map[string]interface{} {
+	if strings.TrimSpace(fields) == "" {
+		return resp
+	}
+	selected := make(map[string]interface{})
+	for _, name := range strings.Split(fields, ",") {
+		name = strings.TrimSpace(name)
+		if v, ok := resp[name]; ok {
+			selected[name] = v
+		}
+	}
+	return selected
+}
+
+// predictionError is a failed prediction with the HTTP status, stable error
+// code and body to return
+type predictionError struct {
+	Status   int
+	Code     string
+	Response models.ErrorResponse
+}
+
+func (e *predictionError) Error() string {
+	if e.Response.Details != "" {
+		return fmt.Sprintf("%s: %s", e.Response.Error, e.Response.Details)
+	}
+	return e.Response.Error
+}
+
+// predict checks the model is enabled and not dark-launched for the caller,
+// then runs the hook, validation and forwarding pipeline and returns the ML
+// service response
+func predict(r *http.Request, model *registry.Model, payload map[string]interface{}) (map[string]interface{}, *predictionError) {
+	if perr := checkModelEnabled(r, model); perr != nil {
+		return nil, perr
+	}
+	if r != nil {
+		if perr := checkDarkLaunch(r, usageCaller(r), model, payload); perr != nil {
+			return nil, perr
+		}
+	}
+	return runPipeline(r, model, payload, flagEnabled(FlagStrictValidation, r))
+}
+
+// checkModelEnabled rejects predictions for a model whose flag is off for
+// the caller
+func checkModelEnabled(r *http.Request, model *registry.Model) *predictionError {
+	if flagEnabled(modelFlag(model.Name), r) {
+		return nil
+	}
+	return &predictionError{http.StatusForbidden, models.CodeModelDisabled, models.ErrorResponse{
+		Error:   "Model disabled",
+		Details: fmt.Sprintf("The %s model is not enabled for this caller", model.Name),
+	}}
+}
+
+// runPipeline runs the hook, validation and forwarding pipeline for a
+// model. Strict validation also rejects fields the model does not declare.
+func runPipeline(r *http.Request, model *registry.Model, payload map[string]interface{}, strict bool) (map[string]interface{}, *predictionError) {
+	warnings, perr := prepareRequest(r, model, payload, strict)
+	if perr != nil {
+		return nil, perr
+	}
+
+	// Forward request to ML service, waiting for a slot if it is saturated
+	release, perr := acquireML(r)
+	if perr != nil {
+		return nil, perr
+	}
+	mlStart := time.Now()
+	mlResp, backend, err := callMLService(r, model, payload)
+	release()
+	observeMLCall(r, model.Name, payload, time.Since(mlStart), err)
+	if err != nil {
+		return nil, mlCallError(err)
+	}
+
+	// Run post-response hooks
+	if err := runHooks(r, model, hooks.PostResponse, mlResp); err != nil {
+		return nil, &predictionError{http.StatusInternalServerError, models.CodeTransformFailed, models.ErrorResponse{
+			Error:   "Response transformation failed",
+			Details: err.Error(),
+		}}
+	}
+
+	// Flag predictions outside the model's sanity bounds
+	outOfRange, perr := checkSanity(model, mlResp)
+	if perr != nil {
+		return nil, perr
+	}
+	warnings = append(warnings, outOfRange...)
+
+	if len(warnings) > 0 {
+		mlResp["warnings"] = warnings
+	}
+	mlResp[provenanceField] = newProvenance(model, mlResp, backend)
+	return mlResp, nil
+}
+
+// prepareRequest runs the pre-validate hooks, validation, plausibility
+// checks and pre-forward hooks, leaving the payload ready to forward to the
+// ML service. It returns any plausibility warnings for the response.
+func prepareRequest(r *http.Request, model *registry.Model, payload map[string]interface{}, strict bool) ([]plausibility.Warning, *predictionError) {
+	// Run pre-validate hooks
+	if err := runHooks(r, model, hooks.PreValidate, payload); err != nil {
+		return nil, &predictionError{http.StatusBadRequest, models.CodeTransformFailed, models.ErrorResponse{
+			Error:   "Request transformation failed",
+			Details: err.Error(),
+		}}
+	}
+
+	// Validate against the model's declared fields
+	errs := model.Validate(payload)
+	if strict {
+		errs = append(errs, model.UnknownFields(payload)...)
+	}
+	if len(errs) > 0 {
+		return nil, &predictionError{http.StatusBadRequest, models.CodeValidationFailed, validationErrorResponse(errs)}
+	}
+
+	// Check the inputs against the model's training data
+	warnings, perr := checkPlausibility(model, payload)
+	if perr != nil {
+		return nil, perr
+	}
+
+	// Run pre-forward hooks
+	if err := runHooks(r, model, hooks.PreForward, payload); err != nil {
+		return nil, &predictionError{http.StatusBadRequest, models.CodeTransformFailed, models.ErrorResponse{
+			Error:   "Request transformation failed",
+			Details: err.Error(),
+		}}
+	}
+
+	// Ask the ML service for the caller's confidence level and quantiles
+	if ir := intervalRequestFrom(r); ir != nil {
+		ir.forward(payload)
+	}
+	return warnings, nil
+}
+
+// mlCallError converts a failed ML service call into an error response:
+// 502 when the response was too large, not a JSON object or failed the
+// model's response schema, 503 when no instance is available, otherwise 500
+func mlCallError(err error) *predictionError {
+	var invalid *mlResponseInvalidError
+	if errors.As(err, &invalid) {
+		return &predictionError{http.StatusBadGateway, models.CodeMLServiceError, models.ErrorResponse{
+			Error:      "Invalid ML response",
+			Details:    err.Error(),
+			Violations: invalid.violations(),
+		}}
+	}
+	if errors.Is(err, errMLResponseTooLarge) {
+		return &predictionError{http.StatusBadGateway, models.CodeMLServiceError, models.ErrorResponse{
+			Error:   "ML response too large",
+			Details: err.Error(),
+		}}
+	}
+	if errors.Is(err, discovery.ErrNoBackends) {
+		return &predictionError{http.StatusServiceUnavailable, models.CodeMLServiceError, models.ErrorResponse{
+			Error:   "ML service unavailable",
+			Details: err.Error(),
+		}}
+	}
+	return &predictionError{http.StatusInternalServerError, models.CodeMLServiceError, models.ErrorResponse{
+		Error:   "ML service error",
+		Details: err.Error(),
+	}}
+}
+
+// mlBaseURL returns the ML service instance to call: a backend in the
+// default region, a discovered backend, or MLServiceURL without either.
+// The backend is the one an affinity key hashes to, or with no key the
+// next one.
+func mlBaseURL(affinity string) (string, error) {
+	if MLRegions != nil {
+		return MLRegions.Pick(MLRegions.Default, affinity)
+	}
+	if MLBackends == nil {
+		return MLServiceURL, nil
+	}
+	return MLBackends.Pick(affinity)
+}
+
+// recordPrediction stores a successful prediction made for caller in the
+// history and publishes it as a prediction event
+func recordPrediction(caller usage.Caller, model *registry.Model, payload, mlResp map[string]interface{}, startTime time.Time) string {
+	latency := float64(time.Since(startTime).Microseconds()) / 1000
+	observeDrift(model, payload, mlResp)
+	observeAnomalies(model, payload)
+
+	id := History.Record(history.Entry{
+		Model:     model.Name,
+		Request:   payload,
+		Response:  mlResp,
+		LatencyMs: latency,
+		Timestamp: startTime,
+		Tenant:    caller.Tenant,
+		APIKey:    caller.APIKey,
+	})
+
+	Events.Publish(events.PredictionEvent{
+		SchemaVersion: events.SchemaVersion,
+		EventID:       id,
+		Timestamp:     startTime,
+		Model:         model.Name,
+		ModelVersion:  events.ModelVersion(mlResp),
+		Request:       payload,
+		Response:      mlResp,
+		LatencyMs:     latency,
+	})
+
+	return id
+}
+
+// runHooks applies the model's config transformations and registered hooks for a stage
+func runHooks(r *http.Request, model *registry.Model, stage hooks.Stage, payload map[string]interface{}) error {
+	ctx := &hooks.Context{Model: model.Name, Stage: stage, Request: r}
+	return hooks.Run(ctx, model.Hooks[stage], payload)
+}
+
+// decodePayload reads a JSON object from the request body, keeping numbers
+// as json.Number so integer fields can be validated exactly
+func decodePayload(body io.Reader) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(body)
+	decoder.UseNumber()
+
+	var payload map[string]interface{}
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, err
+	}
+	if payload == nil {
+		return nil, fmt.Errorf("request body must be a JSON object")
+	}
+	return payload, nil
+}
+
+// validationErrorResponse converts field errors into an error response,
+// reporting missing fields first and otherwise the first invalid field.
+// Every error is also listed as a violation located by JSON pointer.
+func validationErrorResponse(errs []registry.FieldError) models.ErrorResponse {
+	var missing, invalid []string
+	violations := make([]models.Violation, 0, len(errs))
+	seen := make(map[string]bool)
+	for _, e := range errs {
+		violations = append(violations, models.Violation{Pointer: e.Pointer, Message: e.Message})
+		if e.Field == "" || seen[e.Field] {
+			continue
+		}
+		seen[e.Field] = true
+		if e.Missing {
+			missing = append(missing, e.Field)
+		} else {
+			invalid = append(invalid, e.Field)
+		}
+	}
+
+	if len(missing) > 0 {
+		return models.ErrorResponse{
+			Error:      "Missing required fields",
+			Details:    fmt.Sprintf("Required: %s", strings.Join(missing, ", ")),
+			Fields:     missing,
+			Violations: violations,
+		}
+	}
+
+	return models.ErrorResponse{
+		Error:      fmt.Sprintf("Invalid %s", errs[0].Label),
+		Details:    errs[0].Message,
+		Fields:     invalid,
+		Violations: violations,
+	}
+}
+
+// callMLService makes HTTP request to Python ML service, returning the
+// response and the backend that sent it
+func callMLService(r *http.Request, model *registry.Model, payload map[string]interface{}) (map[string]interface{}, string, error) {
+	resp, err := postML(r, model, payload)
+	if err != nil {
+		return nil, "", err
+	}
+	mlResp, err := readMLResponse(model, resp)
+	return mlResp, responseBackend(resp), err
+}
+
+// readMLResponse reads and decodes an ML service response, enforcing the
+// size limit, and closes its body
+func readMLResponse(model *registry.Model, resp *http.Response) (map[string]interface{}, error) {
+	defer resp.Body.Close()
+
+	// Refuse responses over the size limit before reading them, where the
+	// ML service says how large they are
+	if MaxMLResponseBytes > 0 && resp.ContentLength > MaxMLResponseBytes {
+		return nil, mlResponseTooLarge(model, resp.ContentLength)
+	}
+
+	// Read response body, at most one byte past the limit
+	buf := getBuffer()
+	defer putBuffer(buf)
+	var body io.Reader = resp.Body
+	if MaxMLResponseBytes > 0 {
+		body = io.LimitReader(resp.Body, MaxMLResponseBytes+1)
+	}
+	if _, err := buf.ReadFrom(body); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if MaxMLResponseBytes > 0 && int64(buf.Len()) > MaxMLResponseBytes {
+		return nil, mlResponseTooLarge(model, -1)
+	}
+	Metrics.Histogram("ml.response_bytes", float64(buf.Len()), "model:"+model.Name)
+
+	// Check status code
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ML service returned status %d: %s", resp.StatusCode, buf.String())
+	}
+
+	// Parse response
+	var mlResp map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &mlResp); err != nil {
+		return nil, mlResponseInvalid(model, []mlcontract.Failure{{Message: "response is not JSON: " + err.Error()}})
+	}
+	if mlResp == nil {
+		return nil, mlResponseInvalid(model, []mlcontract.Failure{{Message: "response is not a JSON object"}})
+	}
+
+	// Refuse responses that do not match the model's response schema
+	if model.Response != nil {
+		if failures := model.Response.Validate(mlResp); len(failures) > 0 {
+			return nil, mlResponseInvalid(model, failures)
+		}
+	}
+
+	return mlResp, nil
+}
+
+// mlResponseInvalidError is an ML response that does not match its
+// model's response schema
+type mlResponseInvalidError struct {
+	failures []mlcontract.Failure
+}
+
+func (e *mlResponseInvalidError) Error() string {
+	parts := make([]string, len(e.failures))
+	for i, f := range e.failures {
+		parts[i] = f.String()
+	}
+	return "ML service response failed validation: " + strings.Join(parts, "; ")
+}
+
+// violations locates each failure by JSON pointer; failures spanning
+// several fields point at the whole response
+func (e *mlResponseInvalidError) violations() []models.Violation {
+	violations := make([]models.Violation, len(e.failures))
+	for i, f := range e.failures {
+		violations[i] = models.Violation{Message: f.Message}
+		if f.Field != "" {
+			violations[i].Pointer = "/" + f.Field
+		}
+	}
+	return violations
+}
+
+// mlResponseInvalid records and describes an ML response that failed its
+// model's response schema
+func mlResponseInvalid(model *registry.Model, failures []mlcontract.Failure) error {
+	err := &mlResponseInvalidError{failures: failures}
+	Metrics.Incr("ml.response_invalid", "model:"+model.Name)
+	log.Printf("WARN ml_response_invalid model=%s error=%q", model.Name, err.Error())
+	return err
+}
+
+// mlResponseTooLarge records and describes an ML response over the size
+// limit; size is -1 if the ML service did not declare it
+func mlResponseTooLarge(model *registry.Model, size int64) error {
+	Metrics.Incr("ml.response_too_large", "model:"+model.Name)
+	if size < 0 {
+		return fmt.Errorf("%w: more than %d bytes", errMLResponseTooLarge, MaxMLResponseBytes)
+	}
+	return fmt.Errorf("%w: %d bytes (limit %d)", errMLResponseTooLarge, size, MaxMLResponseBytes)
+}
+
+// postML sends a payload to the model's ML service endpoint for the
+// request's caller, returning the response with its body unread. A call
+// that fails to connect or gets a 5xx response is retried on the stable
+// backends if it went to a backend being rolled out, and with regions in
+// the next failover region.
+func postML(r *http.Request, model *registry.Model, payload map[string]interface{}) (*http.Response, error) {
+	targets, err := mlTargets(r, model)
+	if err != nil {
+		return nil, err
+	}
+	for i, target := range targets {
+		start := time.Now()
+		resp, err := postMLURL(target.url, payload)
+		latency := time.Since(start)
+		failed := err != nil || resp.StatusCode >= http.StatusInternalServerError
+		Rollouts.Observe(model.Name, target.canary, latency, failed)
+		if target.region != "" {
+			observeRegionCall(target.region, model.Name, latency, failed)
+		}
+		if !failed || i == len(targets)-1 {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if target.canary {
+			Metrics.Incr("rollout.fallbacks", "model:"+model.Name)
+		} else {
+			failoverRegion(r, model.Name, target.region, targets[i+1].region, err, resp)
+		}
+	}
+	return nil, discovery.ErrNoBackends
+}
+
+// postMLURL sends a payload to an ML service URL, returning the response
+// with its body unread
+func postMLURL(url string, payload map[string]interface{}) (*http.Response, error) {
+	// Prepare request body in a pooled buffer, returned when the client
+	// closes the body
+	buf := getBuffer()
+	if err := json.NewEncoder(buf).Encode(payload); err != nil {
+		putBuffer(buf)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Make HTTP request
+	req, err := http.NewRequest(http.MethodPost, url, nil)
+	if err != nil {
+		putBuffer(buf)
+		return nil, fmt.Errorf("failed to call ML service: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Body = newPooledBody(buf)
+	req.ContentLength = int64(buf.Len())
+	resp, err := MLClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call ML service: %w", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"cloud-ai-api/i18n"
+	"cloud-ai-api/models"
+	"cloud-ai-api/registry"
+	"github.com/gin-gonic/gin"
+)
+
+// Query parameters asking for the predicted price formatted for display
+const (
+	localeParam   = "locale"
+	currencyParam = "currency"
+)
+
+// formattedPriceField is the response field holding the formatted price
+const formattedPriceField = "formatted_price"
+
+// priceFormat is how a caller asked for the predicted price to be written
+type priceFormat struct {
+	format   i18n.MoneyFormat
+	currency string
+}
+
+// parsePriceFormat reads the locale and currency query parameters,
+// returning nil if neither is set. The locale defaults to en and the
+// currency to the model's; amounts are not converted, so a currency other
+// than the model's is refused.
+func parsePriceFormat(c *gin.Context, model *registry.Model) (*priceFormat, *predictionError) {
+	locale := c.Query(localeParam)
+	currency := strings.ToUpper(c.Query(currencyParam))
+	if locale == "" && currency == "" {
+		return nil, nil
+	}
+	invalid := func(format string, args ...interface{}) *predictionError {
+		return &predictionError{http.StatusBadRequest, models.CodeInvalidRequest, models.ErrorResponse{
+			Error:   "Invalid price format",
+			Details: fmt.Sprintf(format, args...),
+		}}
+	}
+	if model.Currency == "" {
+		return nil, invalid("The %s model does not predict a price", model.Name)
+	}
+	if currency != "" && currency != model.Currency {
+		return nil, invalid("The %s model predicts prices in %s; amounts are not converted", model.Name, model.Currency)
+	}
+	if locale == "" {
+		locale = i18n.English
+	}
+	format, ok := i18n.LookupMoneyFormat(locale)
+	if !ok {
+		return nil, invalid("locale must be one of: %s", strings.Join(i18n.MoneyLocales(), ", "))
+	}
+	return &priceFormat{format: format, currency: model.Currency}, nil
+}
+
+// addFormattedPrice adds the predicted price, formatted as the caller
+// asked, to the response. A response without a numeric price is an ML
+// service error.
+func addFormattedPrice(model *registry.Model, mlResp map[string]interface{}, pf *priceFormat) *predictionError {
+	if pf == nil {
+		return nil
+	}
+	price, ok := responseNumber(mlResp[model.Interval.Value])
+	if !ok {
+		return &predictionError{http.StatusBadGateway, models.CodeMLServiceError, models.ErrorResponse{
+			Error:   "ML service error",
+			Details: fmt.Sprintf("Response has no numeric %q field", model.Interval.Value),
+		}}
+	}
+	mlResp[formattedPriceField] = pf.format.Format(price, pf.currency)
+	return nil
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"cloud-ai-api/admission"
+	"cloud-ai-api/middleware"
+	"cloud-ai-api/models"
+)
+
+// MLGate bounds concurrent ML service calls, admitting waiting calls by
+// priority; nil leaves calls unbounded
+var MLGate *admission.Gate
+
+// MLQueueTimeout is how long a call may wait for the ML gate
+var MLQueueTimeout = 5 * time.Second
+
+// PriorityTiers maps API keys to their priority class; other callers are
+// normal priority
+var PriorityTiers = map[string]admission.Priority{}
+
+// requestPriority returns the priority class for a request: the tier of
+// the caller's authenticated API key, lowered by an X-Priority header if
+// given. Callers cannot raise their own priority, and an X-API-Key header
+// the gateway has not authenticated earns no tier. Internal calls (r is
+// nil), such as batch jobs and scheduled predictions, are low priority.
+func requestPriority(r *http.Request) admission.Priority {
+	if r == nil {
+		return admission.Low
+	}
+	p := admission.Normal
+	if tier, ok := PriorityTiers[middleware.CallerFrom(r).APIKey]; ok {
+		p = tier
+	}
+	if asked, ok := admission.ParsePriority(r.Header.Get("X-Priority")); ok && asked < p {
+		p = asked
+	}
+	return p
+}
+
+// acquireML waits for an ML gate slot at the request's priority, returning
+// the function that releases it
+func acquireML(r *http.Request) (func(), *predictionError) {
+	if MLGate == nil {
+		return func() {}, nil
+	}
+
+	ctx := context.Background()
+	if r != nil {
+		ctx = r.Context()
+	}
+	ctx, cancel := context.WithTimeout(ctx, MLQueueTimeout)
+	defer cancel()
+
+	priority := requestPriority(r)
+	start := time.Now()
+	err := MLGate.Acquire(ctx, priority)
+	Metrics.Timing("ml.queue.wait", time.Since(start), "priority:"+priority.String())
+	if err != nil {
+		Metrics.Incr("ml.queue.rejected", "priority:"+priority.String())
+		return nil, &predictionError{http.StatusServiceUnavailable, models.CodeMLServiceError, models.ErrorResponse{
+			Error:   "ML service busy",
+			Details: "Timed out waiting for the ML service; please retry later",
+		}}
+	}
+	return MLGate.Release, nil
+}
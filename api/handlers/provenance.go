@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+
+	"cloud-ai-api/buildinfo"
+	"cloud-ai-api/events"
+	"cloud-ai-api/registry"
+)
+
+// provenanceField is the response field recording what produced a
+// prediction
+const provenanceField = "provenance"
+
+// newProvenance describes what produced an ML response: the model version
+// it reports, the ML backend that served it, the gateway version and the
+// model's feature set. It is stored with the response, so cached and
+// recorded predictions keep it.
+func newProvenance(model *registry.Model, mlResp map[string]interface{}, backend string) map[string]interface{} {
+	return map[string]interface{}{
+		"model_version":    events.ModelVersion(mlResp),
+		"backend":          backend,
+		"gateway_version":  buildinfo.Version,
+		"cache_hit":        false,
+		"feature_set_hash": model.FeatureSetHash(),
+	}
+}
+
+// markCacheHit flags a cached response's provenance as served from the
+// cache. The provenance map is shared with the cache entry, so it is
+// replaced rather than modified.
+func markCacheHit(mlResp map[string]interface{}) {
+	p, ok := mlResp[provenanceField].(map[string]interface{})
+	if !ok {
+		return
+	}
+	hit := make(map[string]interface{}, len(p))
+	for k, v := range p {
+		hit[k] = v
+	}
+	hit["cache_hit"] = true
+	mlResp[provenanceField] = hit
+}
+
+// responseBackend is the host of the ML backend that sent a response
+func responseBackend(resp *http.Response) string {
+	if resp.Request == nil || resp.Request.URL == nil {
+		return ""
+	}
+	return resp.Request.URL.Host
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"cloud-ai-api/middleware"
+	"cloud-ai-api/registry"
+	"github.com/gin-gonic/gin"
+)
+
+// streamBufferSize is the size of the chunks a streamed response is copied in
+const streamBufferSize = 32 * 1024
+
+// streamErrorBodyLimit caps how much of a failed ML response is read into
+// the error details
+const streamErrorBodyLimit = 64 * 1024
+
+// flushWriter flushes after every write so each chunk reaches the client
+// as soon as the ML service sends it
+type flushWriter struct {
+	w gin.ResponseWriter
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	f.w.Flush()
+	return n, err
+}
+
+// streamPrediction runs the pipeline for a model with stream_response set,
+// copying the ML service's response body to the client as it arrives rather
+// than buffering it. prefix is written before the body and suffix, given
+// the history ID, after it; both may be empty. Errors before the body
+// starts are returned for the caller to write. If the ML service fails
+// mid-stream the response is cut short and the failure logged. Plausibility
+// warnings are sent as Warning headers, as the body is not parsed.
+func streamPrediction(c *gin.Context, model *registry.Model, payload map[string]interface{}, startTime time.Time, prefix string, suffix func(id string) string) *predictionError {
+	hash := payloadHash(payload)
+	c.Set(middleware.PayloadFingerprintKey, hash[:16])
+
+	if perr := checkModelEnabled(c.Request, model); perr != nil {
+		return perr
+	}
+	warnings, perr := prepareRequest(c.Request, model, payload, flagEnabled(FlagStrictValidation, c.Request))
+	if perr != nil {
+		return perr
+	}
+
+	release, perr := acquireML(c.Request)
+	if perr != nil {
+		return perr
+	}
+	defer release()
+
+	mlStart := time.Now()
+	resp, err := postML(c.Request, model, payload)
+	if err == nil && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, streamErrorBodyLimit))
+		resp.Body.Close()
+		err = fmt.Errorf("ML service returned status %d: %s", resp.StatusCode, string(body))
+	}
+	if err != nil {
+		observeMLCall(c.Request, model.Name, payload, time.Since(mlStart), err)
+		perr := mlCallError(err)
+		if perr.Status >= http.StatusInternalServerError {
+			reportPredictionError(c, model, payload, perr)
+		}
+		return perr
+	}
+	defer resp.Body.Close()
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" || prefix != "" {
+		contentType = "application/json; charset=utf-8"
+	}
+	c.Header("Content-Type", contentType)
+	for _, w := range warnings {
+		c.Writer.Header().Add("Warning", fmt.Sprintf("199 - %q", w.Message))
+	}
+	c.Status(http.StatusOK)
+
+	w := flushWriter{c.Writer}
+	io.WriteString(w, prefix)
+	n, err := io.CopyBuffer(w, resp.Body, make([]byte, streamBufferSize))
+	observeMLCall(c.Request, model.Name, payload, time.Since(mlStart), err)
+	Metrics.Histogram("ml.response_bytes", float64(n), "model:"+model.Name, "streamed:true")
+	if err != nil {
+		log.Printf("WARN stream_interrupted request_id=%s model=%s bytes=%d error=%v",
+			middleware.RequestID(c), model.Name, n, err)
+		c.Abort()
+		return nil
+	}
+
+	id := recordPrediction(usageCaller(c.Request), model, payload, map[string]interface{}{
+		"streamed":       true,
+		"response_bytes": n,
+	}, startTime)
+	if suffix != nil {
+		io.WriteString(w, suffix(id))
+	}
+	return nil
+}
+
+// streamPredictionV2 streams a prediction inside the v2 envelope: the ML
+// service's response becomes "data", followed by the metadata once the
+// body is complete
+func streamPredictionV2(c *gin.Context, model *registry.Model, payload map[string]interface{}, startTime time.Time) *predictionError {
+	return streamPrediction(c, model, payload, startTime, `{"data":`, func(id string) string {
+		meta := v2Meta(startTime)
+		meta.RequestID = id
+		meta.Model = model.Name
+		tail, err := json.Marshal(gin.H{
+			"meta": meta,
+			"links": map[string]string{
+				"self":  c.Request.URL.RequestURI(),
+				"model": "/api/v2/models/" + model.Name,
+			},
+		})
+		if err != nil {
+			return "}"
+		}
+		// Splice the metadata object's fields in after "data"
+		return "," + string(tail[1:])
+	})
+}
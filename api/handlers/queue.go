@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"cloud-ai-api/models"
+)
+
+// QueueMessageHandler handles a prediction request consumed from a message
+// queue. Messages use the same {id, model, input} format as the WebSocket
+// stream, and the reply body is the matching stream response.
+func QueueMessageHandler(body []byte) []byte {
+	var resp models.StreamPredictionResponse
+
+	// Parse request
+	var req models.StreamPredictionRequest
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&req); err != nil {
+		resp = models.StreamPredictionResponse{
+			Status: http.StatusBadRequest,
+			Error:  &models.ErrorResponse{Error: "Invalid request format", Details: err.Error()},
+		}
+	} else {
+		resp = streamPredict(nil, req)
+	}
+
+	reply, err := json.Marshal(resp)
+	if err != nil {
+		reply, _ = json.Marshal(models.StreamPredictionResponse{
+			ID:     req.ID,
+			Model:  req.Model,
+			Status: http.StatusInternalServerError,
+			Error:  &models.ErrorResponse{Error: "Failed to encode response", Details: err.Error()},
+		})
+	}
+	return reply
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"cloud-ai-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// readiness holds the latest self-test results. The gateway is ready until
+// a self-test is started and stays not ready until every check passes.
+var readiness = struct {
+	sync.RWMutex
+	ready  bool
+	checks []models.ReadinessCheck
+}{ready: true, checks: []models.ReadinessCheck{}}
+
+// ReadinessHandler handles readiness probe requests
+func ReadinessHandler(c *gin.Context) {
+	readiness.RLock()
+	resp := models.ReadinessResponse{Ready: readiness.ready, Checks: readiness.checks}
+	readiness.RUnlock()
+
+	status := http.StatusOK
+	if !resp.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, resp)
+}
+
+// StartSelfTest marks the gateway not ready and sends each model's canary
+// payload through the prediction pipeline, retrying every interval until
+// all canaries succeed. Canary predictions are not recorded in the history.
+func StartSelfTest(interval time.Duration) {
+	readiness.Lock()
+	readiness.ready = false
+	readiness.Unlock()
+
+	go func() {
+		for attempt := 1; ; attempt++ {
+			checks, ok := runSelfTest()
+
+			readiness.Lock()
+			readiness.ready = ok
+			readiness.checks = checks
+			readiness.Unlock()
+
+			if ok {
+				log.Printf("Self-test passed after %d attempt(s)", attempt)
+				return
+			}
+			for _, check := range checks {
+				if check.Status != "pass" {
+					log.Printf("Self-test failed: check=%s details=%q", check.Name, check.Details)
+				}
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// runSelfTest runs one round of canary predictions
+func runSelfTest() ([]models.ReadinessCheck, bool) {
+	checks := []models.ReadinessCheck{}
+	ok := true
+	for _, model := range Registry.Models() {
+		payload, declared := model.CanaryPayload()
+		if !declared {
+			continue
+		}
+
+		check := models.ReadinessCheck{
+			Name:    "canary:" + model.Name,
+			Status:  "pass",
+			Checked: time.Now().Format(time.RFC3339),
+		}
+		if _, perr := predict(nil, model, payload); perr != nil {
+			check.Status = "fail"
+			check.Details = perr.Response.Error
+			if perr.Response.Details != "" {
+				check.Details += ": " + perr.Response.Details
+			}
+			ok = false
+		}
+		checks = append(checks, check)
+	}
+	return checks, ok
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud-ai-api/history"
+	"cloud-ai-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// trendMonths is how many months of prices the regional trend covers
+const trendMonths = 12
+
+// RegionalStatsHandler handles GET /api/v1/housing/stats, summarizing the
+// housing predictions in the history: their count and mean, lowest and
+// highest price, and the mean price per property sale month over the 12
+// months up to the latest one predicted. ?county= limits it to one county.
+func RegionalStatsHandler(c *gin.Context) {
+	model, perr := lookupModel("housing")
+	if perr != nil {
+		c.JSON(perr.Status, perr.Response)
+		return
+	}
+	if model.Interval == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Regional statistics unavailable",
+			Details: "The housing model declares no predicted value field",
+		})
+		return
+	}
+	county := strings.TrimSpace(c.Query("county"))
+
+	type sample struct {
+		price float64
+		month time.Time
+	}
+	var samples []sample
+	resp := models.RegionalStats{County: strings.ToUpper(county), Trend: []models.MonthlyPrice{}}
+	for _, e := range History.List(history.Filter{Model: model.Name}) {
+		if county != "" {
+			if v, _ := e.Request["county"].(string); !strings.EqualFold(v, county) {
+				continue
+			}
+		}
+		price, ok := responseNumber(e.Response[model.Interval.Value])
+		if !ok {
+			continue
+		}
+		s := sample{price: price}
+		year, okYear := responseNumber(e.Request["year"])
+		month, okMonth := responseNumber(e.Request["month"])
+		if okYear && okMonth && month >= 1 && month <= 12 {
+			s.month = time.Date(int(year), time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+		}
+		samples = append(samples, s)
+
+		// History is listed newest first
+		ts := e.Timestamp.UTC().Format(time.RFC3339)
+		if resp.Until == "" {
+			resp.Until = ts
+		}
+		resp.Since = ts
+	}
+
+	resp.Count = len(samples)
+	if resp.Count == 0 {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	var sum float64
+	min, max := samples[0].price, samples[0].price
+	var latest time.Time
+	for _, s := range samples {
+		sum += s.price
+		min = math.Min(min, s.price)
+		max = math.Max(max, s.price)
+		if s.month.After(latest) {
+			latest = s.month
+		}
+	}
+	mean := roundPrice(sum / float64(resp.Count))
+	resp.MeanPrice, resp.MinPrice, resp.MaxPrice = &mean, &min, &max
+
+	if latest.IsZero() {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	first := latest.AddDate(0, -(trendMonths - 1), 0)
+	sums := make([]float64, trendMonths)
+	counts := make([]int, trendMonths)
+	for _, s := range samples {
+		if s.month.IsZero() || s.month.Before(first) {
+			continue
+		}
+		i := (s.month.Year()-first.Year())*12 + int(s.month.Month()) - int(first.Month())
+		sums[i] += s.price
+		counts[i]++
+	}
+	var means []float64
+	for i := 0; i < trendMonths; i++ {
+		m := models.MonthlyPrice{Month: first.AddDate(0, i, 0).Format("2006-01"), Count: counts[i]}
+		if counts[i] > 0 {
+			v := roundPrice(sums[i] / float64(counts[i]))
+			m.MeanPrice = &v
+			means = append(means, v)
+		}
+		resp.Trend = append(resp.Trend, m)
+	}
+	// The trend change compares the earliest and latest months with data
+	if n := len(means); n > 1 && means[0] != 0 {
+		change := math.Round((means[n-1]/means[0]-1)*1e4) / 100
+		resp.TrendChange = &change
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// roundPrice rounds a mean price to two decimal places
+func roundPrice(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"cloud-ai-api/middleware"
+	"cloud-ai-api/mlroute"
+	"cloud-ai-api/stats"
+	"github.com/gin-gonic/gin"
+)
+
+// RegionHeader designates the ML region to send a request's predictions to
+const RegionHeader = "X-ML-Region"
+
+// MLRegions sends predictions to ML backends in the caller's region,
+// failing over to other regions; nil uses the ML service or discovery
+var MLRegions *mlroute.Regions
+
+// CountryHeader carries the caller's country code, as set by a CDN or load
+// balancer, for picking the nearest region
+var CountryHeader = "CF-IPCountry"
+
+// RegionStats holds per-region ML service call statistics
+var RegionStats = stats.NewRecorder(5 * time.Minute)
+
+// callerRegion returns the region for the request's caller. r is nil for
+// internal predictions, which go to the default region.
+func callerRegion(r *http.Request) string {
+	if r == nil {
+		return MLRegions.Default
+	}
+	designated := r.Header.Get(RegionHeader)
+	if designated != "" && !MLRegions.Has(designated) {
+		Metrics.Incr("ml.region.unknown")
+	}
+	return MLRegions.Select(designated, usageCaller(r).Tenant, r.Header.Get(CountryHeader))
+}
+
+// observeRegionCall records an ML call to a region in the statistics and
+// metrics. Failed calls are those that got no response or a 5xx.
+func observeRegionCall(region, model string, latency time.Duration, failed bool) {
+	RegionStats.Observe(region, latency, failed)
+	outcome := "success"
+	if failed {
+		outcome = "error"
+	}
+	Metrics.Timing("ml.region.duration", latency, "region:"+region, "model:"+model, "outcome:"+outcome)
+}
+
+// failoverRegion records retrying a failed ML call in the next region
+func failoverRegion(r *http.Request, model, from, to string, err error, resp *http.Response) {
+	var requestID string
+	if r != nil {
+		requestID = middleware.RequestIDFromContext(r.Context())
+	}
+	reason := "connection"
+	if err == nil {
+		reason = http.StatusText(resp.StatusCode)
+	}
+	log.Printf("WARN ml_region_failover request_id=%s model=%s from=%s to=%s reason=%q", requestID, model, from, to, reason)
+	Metrics.Incr("ml.region.failover", "model:"+model, "from:"+from, "to:"+to)
+}
+
+// RegionsHandler lists the ML regions with their backends, failover order
+// and call statistics over the window
+func RegionsHandler(c *gin.Context) {
+	if MLRegions == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false, "regions": gin.H{}})
+		return
+	}
+	snapshot := RegionStats.Snapshot()
+	regions := make(gin.H, len(MLRegions.Regions))
+	for _, name := range MLRegions.Names() {
+		region := MLRegions.Regions[name]
+		regions[name] = gin.H{
+			"backends": region.Backends,
+			"failover": MLRegions.Order(name)[1:],
+			"calls":    snapshot[name],
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":        true,
+		"default":        MLRegions.Default,
+		"window_seconds": int64(RegionStats.Window().Seconds()),
+		"regions":        regions,
+	})
+}
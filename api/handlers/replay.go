@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cloud-ai-api/atrest"
+	"cloud-ai-api/errorreport"
+	"cloud-ai-api/middleware"
+	"cloud-ai-api/models"
+	"cloud-ai-api/objectstore"
+	"cloud-ai-api/registry"
+	"cloud-ai-api/replay"
+	"github.com/gin-gonic/gin"
+)
+
+// Capture records sampled prediction requests while an admin has capture
+// switched on
+var Capture = replay.NewRecorder()
+
+// Replays re-sends captured requests to target gateways
+var Replays = replay.NewReplayer(&http.Client{Timeout: 30 * time.Second}, 24*time.Hour)
+
+// CaptureDir is the directory captures named by a file name are written to
+// and replayed from
+var CaptureDir = "captures"
+
+// StorageKeys encrypts captures and the gateway's state files; nil when
+// STORAGE_ENCRYPTION_KEYS is not set
+var StorageKeys *atrest.Keyring
+
+// maxReplayRate caps the requests per second of a replay
+const maxReplayRate = 1000
+
+// maxReplayRecords caps the records read from one capture
+const maxReplayRecords = 100000
+
+// maxCaptureBytes caps the size of a capture downloaded for replay
+const maxCaptureBytes = 256 << 20
+
+// captureRequest records a prediction request, with sensitive-looking
+// fields redacted, if a capture is running. Replayed requests are not
+// captured again.
+func captureRequest(c *gin.Context, model *registry.Model, payload map[string]interface{}) {
+	if !Capture.Active() || c.GetHeader(replay.RunHeader) != "" {
+		return
+	}
+	Capture.Record(replay.Record{
+		At:        time.Now().UTC(),
+		RequestID: middleware.RequestID(c),
+		Model:     model.Name,
+		Payload:   errorreport.Sanitize(payload),
+	})
+}
+
+// CaptureStatusHandler reports the running capture, or the last one
+func CaptureStatusHandler(c *gin.Context) {
+	capture, running := Capture.Status()
+	c.JSON(http.StatusOK, gin.H{"running": running, "capture": capture})
+}
+
+// UpdateCaptureHandler starts capturing prediction requests to a file in
+// CAPTURE_DIR or an s3:// or gs:// object, or stops the running capture.
+// Bucket captures are uploaded when they stop.
+func UpdateCaptureHandler(c *gin.Context) {
+	var req models.CaptureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+	if req.Enabled == nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Missing required fields",
+			Details: "Required: enabled",
+			Fields:  []string{"enabled"},
+		})
+		return
+	}
+
+	if !*req.Enabled {
+		capture, ok := Capture.Stop()
+		if !ok {
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: "No capture is running"})
+			return
+		}
+		log.Printf("Capture stopped: destination=%s captured=%d error=%q", capture.Destination, capture.Captured, capture.Error)
+		c.JSON(http.StatusOK, gin.H{"running": false, "capture": capture})
+		return
+	}
+
+	sampleRate := 1.0
+	if req.SampleRate != nil {
+		sampleRate = *req.SampleRate
+	}
+	if sampleRate <= 0 || sampleRate > 1 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid sample_rate",
+			Details: "Must be greater than 0 and at most 1",
+			Fields:  []string{"sample_rate"},
+		})
+		return
+	}
+	if req.MaxRequests < 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid max_requests",
+			Details: "Must be at least 0",
+			Fields:  []string{"max_requests"},
+		})
+		return
+	}
+	for _, name := range req.Models {
+		if _, ok := Registry.Get(name); !ok {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Unknown model",
+				Details: fmt.Sprintf("Must be one of: %s", strings.Join(Registry.Names(), ", ")),
+				Fields:  []string{"models"},
+			})
+			return
+		}
+	}
+	if req.Destination == "" {
+		req.Destination = "capture-" + time.Now().UTC().Format("20060102T150405Z") + ".jsonl"
+	}
+	out, err := openCapture(req.Destination)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid destination",
+			Details: err.Error(),
+			Fields:  []string{"destination"},
+		})
+		return
+	}
+
+	if err := Capture.Start(replay.Capture{
+		Destination: req.Destination,
+		SampleRate:  sampleRate,
+		MaxRequests: req.MaxRequests,
+		Models:      req.Models,
+	}, out); err != nil {
+		out.Close()
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Capture already running", Details: err.Error()})
+		return
+	}
+	log.Printf("Capture started: destination=%s sample_rate=%g max_requests=%d", req.Destination, sampleRate, req.MaxRequests)
+	capture, _ := Capture.Status()
+	c.JSON(http.StatusOK, gin.H{"running": true, "capture": capture})
+}
+
+// StartReplayHandler re-sends a capture to a target gateway at a fixed rate
+// in the background
+func StartReplayHandler(c *gin.Context) {
+	var req models.ReplayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+	if req.Source == "" {
+		if last, running := Capture.Status(); last != nil && !running {
+			req.Source = last.Destination
+		}
+	}
+	if req.Source == "" || req.Target == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Missing required fields",
+			Details: "Required: source (unless a capture has finished) and target",
+			Fields:  []string{"source", "target"},
+		})
+		return
+	}
+	if u, err := url.Parse(strings.ReplaceAll(req.Target, "{model}", "model")); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid target",
+			Details: "Must be an http(s) URL of the target gateway",
+			Fields:  []string{"target"},
+		})
+		return
+	}
+	if req.Rate == 0 {
+		req.Rate = 10
+	}
+	if req.Rate < 0 || req.Rate > maxReplayRate {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid rate",
+			Details: fmt.Sprintf("Must be greater than 0 and at most %d requests per second", maxReplayRate),
+			Fields:  []string{"rate"},
+		})
+		return
+	}
+	if req.Concurrency == 0 {
+		req.Concurrency = 16
+	}
+	if req.Concurrency < 0 || req.Concurrency > 256 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid concurrency",
+			Details: "Must be between 1 and 256",
+			Fields:  []string{"concurrency"},
+		})
+		return
+	}
+
+	data, err := readCapture(c.Request.Context(), req.Source)
+	if err == nil {
+		data, err = StorageKeys.OpenLines(data)
+	}
+	var records []replay.Record
+	if err == nil {
+		records, err = replay.Read(data, maxReplayRecords)
+	}
+	if err == nil && len(records) == 0 {
+		err = fmt.Errorf("capture is empty")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid source",
+			Details: err.Error(),
+			Fields:  []string{"source"},
+		})
+		return
+	}
+
+	run := Replays.Start(req.Source, records, replay.Target{
+		URL:         req.Target,
+		Rate:        req.Rate,
+		Concurrency: req.Concurrency,
+		APIKey:      req.APIKey,
+	})
+	log.Printf("Replay started: id=%s source=%s target=%s records=%d rate=%g", run.ID, run.Source, run.Target, run.Total, run.Rate)
+	c.Header("Location", "/admin/replay/"+run.ID)
+	c.JSON(http.StatusAccepted, run)
+}
+
+// ListReplaysHandler lists recent replay runs, newest first
+func ListReplaysHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"runs": Replays.List()})
+}
+
+// ReplayStatusHandler returns a replay run's progress and results
+func ReplayStatusHandler(c *gin.Context) {
+	run, ok := Replays.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Replay not found"})
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+// CancelReplayHandler stops a running replay
+func CancelReplayHandler(c *gin.Context) {
+	if !Replays.Cancel(c.Param("id")) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "No running replay with this ID"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// openCapture opens the output of a capture: a file in CaptureDir, or a
+// buffer uploaded to object storage when it is closed
+func openCapture(destination string) (io.WriteCloser, error) {
+	if strings.Contains(destination, "://") {
+		loc, err := objectstore.Parse(destination)
+		if err != nil {
+			return nil, err
+		}
+		if ObjectStores[loc.Scheme] == nil {
+			return nil, fmt.Errorf("%s:// storage is not configured", loc.Scheme)
+		}
+		if loc.Key == "" || strings.HasSuffix(loc.Key, "/") {
+			return nil, fmt.Errorf("destination must name an object")
+		}
+		return sealCapture(&bucketCapture{store: ObjectStores[loc.Scheme], loc: loc}), nil
+	}
+	path, err := capturePath(destination)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(CaptureDir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return sealCapture(f), nil
+}
+
+// sealCapture encrypts each captured request as a line of its own when
+// StorageKeys are set, so captures can still be appended to
+func sealCapture(out io.WriteCloser) io.WriteCloser {
+	if StorageKeys == nil {
+		return out
+	}
+	return atrest.NewLineWriter(StorageKeys, out)
+}
+
+// readCapture reads a capture from a file in CaptureDir or from object
+// storage
+func readCapture(ctx context.Context, source string) ([]byte, error) {
+	if strings.Contains(source, "://") {
+		loc, err := objectstore.Parse(source)
+		if err != nil {
+			return nil, err
+		}
+		store := ObjectStores[loc.Scheme]
+		if store == nil {
+			return nil, fmt.Errorf("%s:// storage is not configured", loc.Scheme)
+		}
+		return store.Get(ctx, loc.Bucket, loc.Key, maxCaptureBytes)
+	}
+	path, err := capturePath(source)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxCaptureBytes {
+		return nil, fmt.Errorf("capture is larger than %d bytes", maxCaptureBytes)
+	}
+	return os.ReadFile(path)
+}
+
+// capturePath resolves a capture file name inside CaptureDir
+func capturePath(name string) (string, error) {
+	if name != filepath.Base(name) || name == "." || name == ".." {
+		return "", fmt.Errorf("must be a file name in the capture directory or an s3:// or gs:// URL")
+	}
+	return filepath.Join(CaptureDir, name), nil
+}
+
+// bucketCapture buffers a capture and uploads it when closed
+type bucketCapture struct {
+	bytes.Buffer
+	store *objectstore.Client
+	loc   objectstore.Location
+}
+
+func (b *bucketCapture) Close() error {
+	_, err := b.store.Put(context.Background(), b.loc.Bucket, b.loc.Key, "application/x-ndjson", b.Bytes())
+	return err
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"cloud-ai-api/events"
+	"cloud-ai-api/models"
+	"cloud-ai-api/plausibility"
+	"cloud-ai-api/report"
+	"github.com/gin-gonic/gin"
+)
+
+// housingFieldLabels names the housing model's fields in reports
+var housingFieldLabels = map[string]string{
+	"property_type": "Property type",
+	"is_new":        "Build",
+	"duration":      "Tenure",
+	"county":        "County",
+	"year":          "Year of sale",
+	"month":         "Month of sale",
+}
+
+// housingValueLabels describes the housing model's coded field values
+var housingValueLabels = map[string]map[string]string{
+	"property_type": {"D": "Detached", "S": "Semi-detached", "T": "Terraced", "F": "Flat/apartment", "O": "Other"},
+	"is_new":        {"Y": "New build", "N": "Established"},
+	"duration":      {"F": "Freehold", "L": "Leasehold", "U": "Unknown"},
+}
+
+// ReportHandler handles POST /api/v1/reports/housing, rendering a housing
+// prediction, its confidence band and a trend chart of the same property's
+// predicted price over the 12 months up to the requested one as a
+// downloadable PDF (the default) or self-contained HTML page (format=html).
+// GET takes the request fields as query parameters, so a report can be
+// linked to.
+func ReportHandler(c *gin.Context) {
+	startTime := time.Now()
+
+	format := c.DefaultQuery("format", "pdf")
+	if format != "pdf" && format != "html" {
+		respond(c, http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid format",
+			Details: "Must be one of: pdf, html",
+			Fields:  []string{"format"},
+		})
+		return
+	}
+	model, perr := lookupModel("housing")
+	if perr != nil {
+		respond(c, perr.Status, perr.Response)
+		return
+	}
+	if model.Interval == nil {
+		respond(c, http.StatusNotFound, models.ErrorResponse{
+			Error:   "Reports unavailable",
+			Details: "The housing model declares no confidence interval",
+		})
+		return
+	}
+	var payload map[string]interface{}
+	var err error
+	if c.Request.Method == http.MethodGet {
+		payload = queryPayload(c, model)
+		delete(payload, "format")
+	} else if payload, err = decodeRequest(c, model); err != nil {
+		respond(c, http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Build the trend from the original input, before the pipeline's hooks
+	// change it
+	var trendPayloads []map[string]interface{}
+	var months []time.Time
+	year, okYear := responseNumber(payload["year"])
+	month, okMonth := responseNumber(payload["month"])
+	if okYear && okMonth {
+		last := time.Date(int(year), time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+		for i := trendMonths - 1; i >= 0; i-- {
+			m := last.AddDate(0, -i, 0)
+			p := withField(payload, "year", m.Year())
+			p["month"] = int(m.Month())
+			trendPayloads = append(trendPayloads, p)
+			months = append(months, m)
+		}
+	}
+
+	mlResp, id, perr := runPrediction(c, model, payload, startTime)
+	if perr != nil {
+		respond(c, perr.Status, perr.Response)
+		return
+	}
+	value, okValue := responseNumber(mlResp[model.Interval.Value])
+	lower, okLower := responseNumber(mlResp[model.Interval.Lower])
+	upper, okUpper := responseNumber(mlResp[model.Interval.Upper])
+	if !okValue || !okLower || !okUpper {
+		respond(c, http.StatusBadGateway, models.ErrorResponse{
+			Error:   "ML service error",
+			Details: fmt.Sprintf("Response has no confidence interval (%s, %s, %s)", model.Interval.Value, model.Interval.Lower, model.Interval.Upper),
+		})
+		return
+	}
+
+	data := &report.Data{
+		Title:        "Property Price Report",
+		Subtitle:     "Estimated sale price from the " + model.Description + " model",
+		GeneratedAt:  time.Now().UTC().Format("2 January 2006 15:04 MST"),
+		PredictionID: id,
+		ModelVersion: events.ModelVersion(mlResp),
+		Value:        value,
+		Lower:        lower,
+		Upper:        upper,
+	}
+	for _, f := range model.Fields {
+		v, ok := payload[f.Name]
+		if !ok {
+			continue
+		}
+		label := housingFieldLabels[f.Name]
+		if label == "" {
+			label = f.Name
+		}
+		text := fmt.Sprint(v)
+		if names, ok := housingValueLabels[f.Name]; ok && names[text] != "" {
+			text = names[text]
+		}
+		data.Inputs = append(data.Inputs, report.Input{Label: label, Value: text})
+	}
+	warnings, _ := mlResp["warnings"].([]plausibility.Warning)
+	for _, w := range warnings {
+		data.Warnings = append(data.Warnings, w.Message)
+	}
+
+	// Months the model cannot predict, such as those before its training
+	// data starts, are left out of the chart
+	for i, r := range fanOut(c.Request, model, trendPayloads, "report") {
+		if r.perr != nil {
+			continue
+		}
+		point := report.TrendPoint{Month: months[i].Format("2006-01"), Value: r.value, Lower: r.value, Upper: r.value}
+		if v, ok := responseNumber(r.resp[model.Interval.Lower]); ok {
+			point.Lower = v
+		}
+		if v, ok := responseNumber(r.resp[model.Interval.Upper]); ok {
+			point.Upper = v
+		}
+		data.Trend = append(data.Trend, point)
+	}
+
+	var buf bytes.Buffer
+	contentType := "application/pdf"
+	if format == "html" {
+		contentType = "text/html; charset=utf-8"
+		err = report.HTML(&buf, data)
+	} else {
+		err = report.PDF(&buf, data)
+	}
+	if err != nil {
+		respond(c, http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Report generation failed",
+			Details: err.Error(),
+		})
+		return
+	}
+	Metrics.Incr("report.generated", "model:"+model.Name, "format:"+format)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="housing-report-%s.%s"`, id, format))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"cloud-ai-api/models"
+	"cloud-ai-api/rollout"
+	"github.com/gin-gonic/gin"
+)
+
+// Rollouts sends a growing share of ML calls to a new backend while an
+// admin rolls it out
+var Rollouts = rollout.NewController(logRolloutStep)
+
+// logRolloutStep logs and counts each step of a rollout
+func logRolloutStep(r rollout.Rollout, step rollout.Step) {
+	log.Printf("rollout_step backend=%s decision=%s percent=%g canary_calls=%d canary_error_rate=%.3f canary_p95_ms=%.1f stable_p95_ms=%.1f reason=%q",
+		r.Backend, step.Decision, r.Percent, step.Canary.Count, step.Canary.ErrorRate, step.Canary.P95Ms, step.Stable.P95Ms, step.Reason)
+	Metrics.Incr("rollout.steps", "decision:"+step.Decision)
+	if r.State == rollout.RolledBack {
+		log.Printf("WARN rollout_rolled_back backend=%s reason=%q", r.Backend, r.Reason)
+	}
+}
+
+// RolloutStatusHandler reports the active rollout, or the last one
+func RolloutStatusHandler(c *gin.Context) {
+	r, active := Rollouts.Status()
+	c.JSON(http.StatusOK, gin.H{"active": active, "rollout": r})
+}
+
+// StartRolloutHandler starts rolling out a new ML backend
+func StartRolloutHandler(c *gin.Context) {
+	var req models.RolloutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+	if req.Backend == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Missing required fields",
+			Details: "Required: backend",
+			Fields:  []string{"backend"},
+		})
+		return
+	}
+	if u, err := url.Parse(req.Backend); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid backend",
+			Details: "Must be an http(s) URL of the ML service",
+			Fields:  []string{"backend"},
+		})
+		return
+	}
+	for _, name := range req.Models {
+		if _, ok := Registry.Get(name); !ok {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Unknown model",
+				Details: fmt.Sprintf("Must be one of: %s", strings.Join(Registry.Names(), ", ")),
+				Fields:  []string{"models"},
+			})
+			return
+		}
+	}
+
+	r := rollout.Rollout{
+		Backend:         strings.TrimRight(req.Backend, "/"),
+		Models:          req.Models,
+		Percent:         floatOr(req.Percent, 5),
+		Step:            floatOr(req.Step, 10),
+		IntervalSeconds: intOr(req.IntervalSeconds, 300),
+		MinRequests:     intOr(req.MinRequests, 20),
+		MaxErrorRate:    floatOr(req.MaxErrorRate, 0.05),
+		MaxLatencyRatio: floatOr(req.MaxLatencyRatio, 1.5),
+	}
+	for _, check := range []struct {
+		field, details string
+		ok             bool
+	}{
+		{"percent", "Must be greater than 0 and at most 100", r.Percent > 0 && r.Percent <= 100},
+		{"step", "Must be greater than 0 and at most 100", r.Step > 0 && r.Step <= 100},
+		{"interval_seconds", "Must be at least 1", r.IntervalSeconds >= 1},
+		{"min_requests", "Must be at least 0", r.MinRequests >= 0},
+		{"max_error_rate", "Must be between 0 and 1", r.MaxErrorRate >= 0 && r.MaxErrorRate <= 1},
+		{"max_latency_ratio", "Must be 0, which turns the latency check off, or at least 1", r.MaxLatencyRatio == 0 || r.MaxLatencyRatio >= 1},
+	} {
+		if !check.ok {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid " + check.field,
+				Details: check.details,
+				Fields:  []string{check.field},
+			})
+			return
+		}
+	}
+
+	if err := Rollouts.Start(r); err != nil {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Rollout already active", Details: err.Error()})
+		return
+	}
+	log.Printf("Rollout started: backend=%s percent=%g step=%g interval=%ds models=%v", r.Backend, r.Percent, r.Step, r.IntervalSeconds, r.Models)
+	status, _ := Rollouts.Status()
+	c.JSON(http.StatusOK, gin.H{"active": true, "rollout": status})
+}
+
+// StopRolloutHandler aborts the running rollout, or ends a promoted one
+func StopRolloutHandler(c *gin.Context) {
+	r, ok := Rollouts.Stop()
+	if !ok {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "No rollout is active"})
+		return
+	}
+	log.Printf("Rollout stopped: backend=%s state=%s percent=%g", r.Backend, r.State, r.Percent)
+	c.JSON(http.StatusOK, gin.H{"active": false, "rollout": r})
+}
+
+func floatOr(v *float64, def float64) float64 {
+	if v == nil {
+		return def
+	}
+	return *v
+}
+
+func intOr(v *int, def int) int {
+	if v == nil {
+		return def
+	}
+	return *v
+}
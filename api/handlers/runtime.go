@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// started is when the process started, for uptime reporting
+var started = time.Now()
+
+// gcPauseSamples is how many recent GC pauses the runtime report includes
+const gcPauseSamples = 16
+
+// RuntimeHandler reports goroutine, heap and garbage collector statistics
+func RuntimeHandler(c *gin.Context) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	// PauseNs is a circular buffer; the most recent pause is at (NumGC+255)%256
+	n := int(m.NumGC)
+	if n > gcPauseSamples {
+		n = gcPauseSamples
+	}
+	pauses := make([]float64, 0, n)
+	for i := 0; i < n; i++ {
+		ns := m.PauseNs[(int(m.NumGC)-1-i+len(m.PauseNs))%len(m.PauseNs)]
+		pauses = append(pauses, float64(ns)/1e6)
+	}
+
+	var lastGC string
+	if m.LastGC > 0 {
+		lastGC = time.Unix(0, int64(m.LastGC)).UTC().Format(time.RFC3339)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"uptime_seconds": int64(time.Since(started).Seconds()),
+		"goroutines":     runtime.NumGoroutine(),
+		"gomaxprocs":     runtime.GOMAXPROCS(0),
+		"num_cpu":        runtime.NumCPU(),
+		"heap": gin.H{
+			"alloc_bytes":    m.HeapAlloc,
+			"inuse_bytes":    m.HeapInuse,
+			"idle_bytes":     m.HeapIdle,
+			"released_bytes": m.HeapReleased,
+			"sys_bytes":      m.HeapSys,
+			"objects":        m.HeapObjects,
+		},
+		"memory": gin.H{
+			"sys_bytes":         m.Sys,
+			"total_alloc_bytes": m.TotalAlloc,
+			"mallocs":           m.Mallocs,
+			"frees":             m.Frees,
+		},
+		"gc": gin.H{
+			"num_gc":           m.NumGC,
+			"num_forced_gc":    m.NumForcedGC,
+			"next_gc_bytes":    m.NextGC,
+			"last_gc":          lastGC,
+			"pause_total_ms":   float64(m.PauseTotalNs) / 1e6,
+			"recent_pauses_ms": pauses,
+			"cpu_fraction":     m.GCCPUFraction,
+		},
+	})
+}
+
+// ProfileHandler serves the net/http/pprof profiles under /debug/pprof/
+func ProfileHandler(c *gin.Context) {
+	switch c.Param("profile") {
+	case "/cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "/profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "/symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "/trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		pprof.Index(c.Writer, c.Request)
+	}
+}
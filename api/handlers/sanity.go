@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"cloud-ai-api/models"
+	"cloud-ai-api/plausibility"
+	"cloud-ai-api/registry"
+	"cloud-ai-api/sanity"
+)
+
+// SanityBounds flags predictions outside the range each model's answers
+// can believably fall in; nil disables the check
+var SanityBounds = sanity.Default()
+
+// RejectOutOfRange rejects out-of-range predictions with 502 instead of
+// returning them with warnings
+var RejectOutOfRange bool
+
+// checkSanity returns warnings for the fields of an ML response outside
+// the model's sanity bounds, or an error listing them if RejectOutOfRange
+// is set. Each is logged and counted either way.
+func checkSanity(model *registry.Model, mlResp map[string]interface{}) ([]plausibility.Warning, *predictionError) {
+	if SanityBounds == nil {
+		return nil, nil
+	}
+	violations := SanityBounds.Check(model.Name, mlResp)
+	if len(violations) == 0 {
+		return nil, nil
+	}
+
+	warnings := make([]plausibility.Warning, len(violations))
+	for i, v := range violations {
+		warnings[i] = v.Warning()
+		log.Printf("WARN prediction_out_of_range model=%s field=%s value=%g rejected=%t message=%q",
+			model.Name, v.Field, v.Value, RejectOutOfRange, warnings[i].Message)
+		Metrics.Incr("prediction.out_of_range", "model:"+model.Name, "field:"+v.Field, "rejected:"+strconv.FormatBool(RejectOutOfRange))
+	}
+	if !RejectOutOfRange {
+		return warnings, nil
+	}
+
+	resp := models.ErrorResponse{
+		Error:   "Implausible prediction",
+		Details: "The prediction lies outside the model's sanity bounds",
+	}
+	for _, w := range warnings {
+		resp.Fields = append(resp.Fields, w.Fields...)
+		resp.Violations = append(resp.Violations, models.Violation{Pointer: "/" + w.Fields[0], Message: w.Message})
+	}
+	return nil, &predictionError{http.StatusBadGateway, models.CodeImplausiblePrediction, resp}
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud-ai-api/jobs"
+	"cloud-ai-api/models"
+	"cloud-ai-api/scheduler"
+	"github.com/gin-gonic/gin"
+)
+
+// Schedules runs recurring predictions, billed as batch rows to the
+// schedule's owner
+var Schedules = scheduler.New(func(owner scheduler.Owner, model string, input map[string]interface{}) (map[string]interface{}, error) {
+	return predictRow(jobs.Owner{Tenant: owner.Tenant, APIKey: owner.APIKey}, model, input)
+})
+
+// MaxSchedulesPerOwner is the maximum number of schedules one caller may
+// have
+var MaxSchedulesPerOwner = 20
+
+// scheduleOwner returns the authenticated caller a schedule request comes
+// from. Schedules belong to whoever created them, so anonymous callers may
+// not manage any.
+func scheduleOwner(c *gin.Context) (scheduler.Owner, bool) {
+	caller := usageCaller(c.Request)
+	if caller.Tenant == "" && caller.APIKey == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Caller not identified",
+			Details: "Authenticate with an API key to manage schedules",
+		})
+		return scheduler.Owner{}, false
+	}
+	return scheduler.Owner{Tenant: caller.Tenant, APIKey: caller.APIKey}, true
+}
+
+// ListSchedulesHandler lists the caller's schedules
+func ListSchedulesHandler(c *gin.Context) {
+	owner, ok := scheduleOwner(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"schedules": Schedules.List(owner)})
+}
+
+// GetScheduleHandler returns a single schedule of the caller's
+func GetScheduleHandler(c *gin.Context) {
+	owner, ok := scheduleOwner(c)
+	if !ok {
+		return
+	}
+	sched, ok := Schedules.Get(owner, c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Schedule not found"})
+		return
+	}
+	c.JSON(http.StatusOK, sched)
+}
+
+// CreateScheduleHandler registers a recurring prediction owned by the
+// caller
+func CreateScheduleHandler(c *gin.Context) {
+	owner, ok := scheduleOwner(c)
+	if !ok {
+		return
+	}
+	sched, errResp := parseScheduleRequest(c)
+	if errResp != nil {
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+	sched.Owner = owner
+
+	created, err := Schedules.Create(sched, MaxSchedulesPerOwner)
+	if err == scheduler.ErrLimit {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Schedule limit reached",
+			Details: fmt.Sprintf("Each caller may have at most %d schedules", MaxSchedulesPerOwner),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid schedule",
+			Details: err.Error(),
+		})
+		return
+	}
+	c.Header("Location", "/api/v1/schedules/"+created.ID)
+	c.JSON(http.StatusCreated, created)
+}
+
+// UpdateScheduleHandler replaces the definition of a schedule of the
+// caller's
+func UpdateScheduleHandler(c *gin.Context) {
+	owner, ok := scheduleOwner(c)
+	if !ok {
+		return
+	}
+	sched, errResp := parseScheduleRequest(c)
+	if errResp != nil {
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	updated, err := Schedules.Update(owner, c.Param("id"), sched)
+	if err == scheduler.ErrNotFound {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Schedule not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid schedule",
+			Details: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteScheduleHandler removes a schedule of the caller's
+func DeleteScheduleHandler(c *gin.Context) {
+	owner, ok := scheduleOwner(c)
+	if !ok {
+		return
+	}
+	if err := Schedules.Delete(owner, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Schedule not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// parseScheduleRequest decodes and validates a schedule definition. The
+// template is rendered for the current time and validated against the model
+// so broken templates are rejected up front rather than failing every run.
+func parseScheduleRequest(c *gin.Context) (scheduler.Schedule, *models.ErrorResponse) {
+	// Parse request
+	var req models.ScheduleRequest
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&req); err != nil {
+		return scheduler.Schedule{}, &models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		}
+	}
+
+	// Validate cron expression
+	if err := scheduler.ValidateCron(req.Cron); err != nil {
+		return scheduler.Schedule{}, &models.ErrorResponse{
+			Error:   "Invalid cron expression",
+			Details: err.Error(),
+			Fields:  []string{"cron"},
+		}
+	}
+
+	// Validate model
+	model, ok := Registry.Get(req.Model)
+	if !ok {
+		return scheduler.Schedule{}, &models.ErrorResponse{
+			Error:   "Unknown model",
+			Details: fmt.Sprintf("Must be one of: %s", strings.Join(Registry.Names(), ", ")),
+			Fields:  []string{"model"},
+		}
+	}
+
+	// Validate template
+	if req.Template == nil {
+		return scheduler.Schedule{}, &models.ErrorResponse{
+			Error:   "Invalid template",
+			Details: "template must be a JSON object",
+			Fields:  []string{"template"},
+		}
+	}
+	if err := scheduler.CheckTemplate(req.Template); err != nil {
+		return scheduler.Schedule{}, &models.ErrorResponse{
+			Error:   "Invalid template",
+			Details: err.Error(),
+			Fields:  []string{"template"},
+		}
+	}
+	if errs := model.Validate(scheduler.Render(req.Template, time.Now())); len(errs) > 0 {
+		resp := validationErrorResponse(errs)
+		resp.Error = "Invalid template: " + resp.Error
+		return scheduler.Schedule{}, &resp
+	}
+
+	// Validate webhook URL
+	if req.WebhookURL != "" {
+		if err := scheduler.CheckWebhook(c.Request.Context(), req.WebhookURL); err != nil {
+			return scheduler.Schedule{}, &models.ErrorResponse{
+				Error:   "Invalid webhook URL",
+				Details: err.Error(),
+				Fields:  []string{"webhook_url"},
+			}
+		}
+	}
+
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	return scheduler.Schedule{
+		Name:       req.Name,
+		Cron:       req.Cron,
+		Model:      req.Model,
+		Template:   req.Template,
+		WebhookURL: req.WebhookURL,
+		Enabled:    enabled,
+	}, nil
+}
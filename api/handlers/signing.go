@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+
+	"cloud-ai-api/signing"
+	"github.com/gin-gonic/gin"
+)
+
+// ResponseSigner signs prediction responses; nil when signing is off
+var ResponseSigner *signing.Signer
+
+// SigningKeysHandler handles GET /.well-known/jwks.json, publishing the
+// public key that verifies X-Signature headers
+func SigningKeysHandler(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.JSON(http.StatusOK, ResponseSigner.JWKS())
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"cloud-ai-api/models"
+	"cloud-ai-api/slo"
+	"github.com/gin-gonic/gin"
+)
+
+// SLO tracks requests against the service level objectives; nil when none
+// are configured
+var SLO *slo.Tracker
+
+// SLOHandler handles GET /admin/slo, reporting each objective's error
+// budget and burn rates over its rolling window and its compliance over a
+// calendar month (the month query parameter, YYYY-MM, defaults to the
+// current one)
+func SLOHandler(c *gin.Context) {
+	now := time.Now().UTC()
+	month := now
+	if m := c.Query("month"); m != "" {
+		parsed, err := time.Parse("2006-01", m)
+		if err != nil || parsed.After(now) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid month",
+				Details: "Must be a past or current month as YYYY-MM",
+			})
+			return
+		}
+		month = parsed
+	}
+
+	objectives := []slo.ObjectiveReport{}
+	if SLO != nil {
+		objectives = SLO.Report(now, month)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"generated_at": now.Format(time.RFC3339),
+		"objectives":   objectives,
+	})
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"cloud-ai-api/metrics"
+	"cloud-ai-api/middleware"
+	"cloud-ai-api/mltransport"
+	"cloud-ai-api/stats"
+	"cloud-ai-api/usage"
+	"github.com/gin-gonic/gin"
+)
+
+// RequestStats holds per-route request statistics
+var RequestStats = stats.NewRecorder(5 * time.Minute)
+
+// MLStats holds per-model ML service call statistics
+var MLStats = stats.NewRecorder(5 * time.Minute)
+
+// Metrics pushes request and ML call metrics to an external system
+var Metrics metrics.Emitter = metrics.Nop{}
+
+// SlowMLThreshold is the ML call latency above which a warning is logged;
+// zero disables the warning
+var SlowMLThreshold = 2 * time.Second
+
+// observeMLCall records an ML service call in the statistics and metrics,
+// warning about slow calls. r is nil for calls outside an HTTP request.
+func observeMLCall(r *http.Request, model string, payload map[string]interface{}, latency time.Duration, err error) {
+	MLStats.Observe(model, latency, err != nil)
+	if err == nil && r != nil {
+		chargeUsage(usageCaller(r), model, usage.Call)
+	}
+	lastMLCall.Store(model, time.Now())
+
+	if SlowMLThreshold > 0 && latency >= SlowMLThreshold {
+		var requestID string
+		if r != nil {
+			requestID = middleware.RequestIDFromContext(r.Context())
+		}
+		log.Printf("WARN slow_ml_call request_id=%s model=%s duration_ms=%d threshold_ms=%d error=%t fingerprint=%s",
+			requestID, model, latency.Milliseconds(), SlowMLThreshold.Milliseconds(), err != nil, payloadFingerprint(payload))
+		Metrics.Incr("ml.slow", "model:"+model)
+	}
+
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+		Metrics.Incr("ml.errors", "model:"+model)
+	}
+	Metrics.Timing("ml.duration", latency, "model:"+model, "outcome:"+outcome)
+}
+
+// StatsHandler reports request counts, error rates and latency percentiles
+// per route and for ML service calls over the sliding window, the latest
+// keep-warm ping per model, ML service connection counts and, if enabled,
+// the ML gate's load and the load shedder's state
+func StatsHandler(c *gin.Context) {
+	resp := gin.H{
+		"window_seconds": int64(RequestStats.Window().Seconds()),
+		"endpoints":      RequestStats.Snapshot(),
+		"ml_service":     MLStats.Snapshot(),
+		"keep_warm":      keepWarmPings(),
+	}
+	if MLGate != nil {
+		resp["ml_gate"] = MLGate.Status()
+	}
+	if t, ok := MLClient.Transport.(*mltransport.Transport); ok {
+		resp["ml_connections"] = t.Stats()
+	}
+	if Shedder != nil {
+		resp["load_shedding"] = Shedder.Status()
+	}
+	c.JSON(http.StatusOK, resp)
+}
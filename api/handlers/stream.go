@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"cloud-ai-api/middleware"
+	"cloud-ai-api/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// streamMaxInFlight limits concurrent predictions per WebSocket connection
+	streamMaxInFlight = 16
+	// streamMaxMessageSize limits the size of a single client message
+	streamMaxMessageSize = 64 * 1024
+	streamWriteTimeout   = 10 * time.Second
+	streamPongTimeout    = 60 * time.Second
+	streamPingInterval   = 50 * time.Second
+)
+
+// streamMessageRoute is the route a streamed prediction is admitted as
+const streamMessageRoute = "/api/v1/predict/:model"
+
+// AllowedOrigins are the browser origins allowed by CORS_ALLOWED_ORIGINS;
+// nil when it is not set
+var AllowedOrigins *middleware.Origins
+
+// StreamAdmission checks each streamed prediction as the middleware chain
+// checks requests; nil admits every message
+var StreamAdmission *middleware.Admission
+
+// upgrader accepts WebSocket connections from pages on allowed origins
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	CheckOrigin:     func(r *http.Request) bool { return AllowedOrigins.AllowsWebSocket(r) },
+}
+
+// StreamPredictionHandler upgrades the connection to a WebSocket on which the
+// client streams prediction requests and receives results as they complete.
+// Results may arrive out of order; clients correlate them by ID.
+func StreamPredictionHandler(c *gin.Context) {
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade has already written an error response
+		return
+	}
+	defer conn.Close()
+
+	conn.SetReadLimit(streamMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(streamPongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(streamPongTimeout))
+	})
+
+	var writeMu sync.Mutex
+	send := func(msg models.StreamPredictionResponse) {
+		msg = localize(c, msg).(models.StreamPredictionResponse)
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if err := conn.WriteJSON(msg); err != nil {
+			log.Printf("WebSocket write failed: %v", err)
+		}
+	}
+
+	// Keep the connection alive through proxies
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(streamPingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				writeMu.Lock()
+				err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout))
+				writeMu.Unlock()
+				if err != nil {
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	inFlight := make(chan struct{}, streamMaxInFlight)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		_, reader, err := conn.NextReader()
+		if err != nil {
+			return
+		}
+
+		// Parse request
+		var req models.StreamPredictionRequest
+		decoder := json.NewDecoder(reader)
+		decoder.UseNumber()
+		if err := decoder.Decode(&req); err != nil {
+			send(models.StreamPredictionResponse{
+				Status: http.StatusBadRequest,
+				Error:  &models.ErrorResponse{Error: "Invalid request format", Details: err.Error()},
+			})
+			continue
+		}
+
+		inFlight <- struct{}{}
+		wg.Add(1)
+		go func(req models.StreamPredictionRequest) {
+			defer wg.Done()
+			defer func() { <-inFlight }()
+			send(streamMessage(c, req))
+		}(req)
+	}
+}
+
+// streamMessage admits a streamed prediction as a request to the model's
+// prediction route, then runs it. The middleware chain only saw the
+// upgrade, so bans, maintenance, disabled routes and the rate and
+// concurrency limits are checked again for every message.
+func streamMessage(c *gin.Context, req models.StreamPredictionRequest) models.StreamPredictionResponse {
+	done, rej := StreamAdmission.Admit(c, http.MethodPost, streamMessageRoute, "/api/v1/predict/"+req.Model)
+	if rej != nil {
+		return models.StreamPredictionResponse{ID: req.ID, Model: req.Model, Status: rej.Status, RetryAfter: rej.RetryAfter, Error: &rej.Response}
+	}
+	resp := streamPredict(c.Request, req)
+	done(resp.Status)
+	return resp
+}
+
+// streamPredict runs a single streamed prediction through the shared pipeline
+func streamPredict(r *http.Request, req models.StreamPredictionRequest) models.StreamPredictionResponse {
+	startTime := time.Now()
+	resp := models.StreamPredictionResponse{ID: req.ID, Model: req.Model}
+
+	model, ok := Registry.Get(req.Model)
+	if !ok {
+		resp.Status = http.StatusNotFound
+		resp.Error = &models.ErrorResponse{Error: "Unknown model", Details: req.Model}
+		return resp
+	}
+	if req.Input == nil {
+		resp.Status = http.StatusBadRequest
+		resp.Error = &models.ErrorResponse{Error: "Invalid request format", Details: "input must be a JSON object"}
+		return resp
+	}
+
+	mlResp, perr := predict(r, model, req.Input)
+	if perr != nil {
+		resp.Status = perr.Status
+		resp.Error = &perr.Response
+		return resp
+	}
+
+	mlResp["processing_time_ms"] = float64(time.Since(startTime).Milliseconds())
+	mlResp["prediction_time"] = time.Now().Format(time.RFC3339)
+	recordPrediction(usageCaller(r), model, req.Input, mlResp, startTime)
+
+	resp.Status = http.StatusOK
+	resp.Result = mlResp
+	return resp
+}
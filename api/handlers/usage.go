@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cloud-ai-api/flags"
+	"cloud-ai-api/middleware"
+	"cloud-ai-api/models"
+	"cloud-ai-api/usage"
+	"github.com/gin-gonic/gin"
+)
+
+// Usage accumulates the cost of ML service calls per caller
+var Usage = usage.NewLedger()
+
+// CostCurrency is the currency costs are reported in
+var CostCurrency = "GBP"
+
+// DefaultCostPerCall is the cost of an ML service call for models without
+// a cost_per_call of their own
+var DefaultCostPerCall float64
+
+// chargeUsage bills a prediction to a caller. ML service calls and batch
+// rows cost the model's cost_per_call; cache hits are free. ML calls made
+// outside a request, such as warm-up probes, are not charged, and batch
+// rows are charged to the job's owner by predictRow instead.
+func chargeUsage(caller usage.Caller, modelName string, kind usage.Kind) {
+	cost := DefaultCostPerCall
+	if model, ok := Registry.Get(modelName); ok && model.CostPerCall != nil {
+		cost = *model.CostPerCall
+	}
+	if kind == usage.CacheHit {
+		cost = 0
+	}
+	Usage.Charge(caller, modelName, kind, cost)
+}
+
+// usageCaller identifies the authenticated caller of a request. Anonymous
+// requests, whose X-Tenant-ID and X-API-Key headers nobody vouches for,
+// have the zero caller.
+func usageCaller(r *http.Request) usage.Caller {
+	caller := middleware.CallerFrom(r)
+	return usage.Caller{Tenant: caller.Tenant, APIKey: caller.APIKey}
+}
+
+// usageMonth reads the month query parameter (YYYY-MM), defaulting to the
+// current month
+func usageMonth(c *gin.Context) (string, bool) {
+	month := c.Query("month")
+	if month == "" {
+		return time.Now().UTC().Format("2006-01"), true
+	}
+	if _, err := time.Parse("2006-01", month); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid month",
+			Details: "Must be formatted as YYYY-MM",
+		})
+		return "", false
+	}
+	return month, true
+}
+
+// UsageHandler handles GET /api/v1/usage, reporting the calling API key's
+// ML service calls and their cost in a month. A tenant without an API key
+// sees the total across its keys.
+func UsageHandler(c *gin.Context) {
+	caller := usageCaller(c.Request)
+	if caller.Tenant == "" && caller.APIKey == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Caller not identified",
+			Details: "Authenticate with an API key to see usage",
+		})
+		return
+	}
+	month, ok := usageMonth(c)
+	if !ok {
+		return
+	}
+
+	summary := Usage.For(caller, month)
+	if summary.APIKey != "" {
+		summary.APIKey = flags.MaskKey(summary.APIKey)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"month":    month,
+		"currency": CostCurrency,
+		"usage":    summary,
+	})
+}
+
+// AdminUsageHandler handles GET /admin/usage, listing every caller's ML
+// service calls and cost in a month for chargeback, most expensive first.
+// group=tenant totals each tenant's keys.
+func AdminUsageHandler(c *gin.Context) {
+	month, ok := usageMonth(c)
+	if !ok {
+		return
+	}
+	group := c.DefaultQuery("group", "key")
+	if group != "key" && group != "tenant" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid group",
+			Details: "Must be key or tenant",
+		})
+		return
+	}
+
+	callers := Usage.Month(month, group == "tenant")
+	var calls int64
+	var cost float64
+	for i := range callers {
+		calls += callers[i].Calls
+		cost += callers[i].Cost
+		if callers[i].APIKey != "" {
+			callers[i].APIKey = flags.MaskKey(callers[i].APIKey)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"month":       month,
+		"months":      Usage.Months(),
+		"currency":    CostCurrency,
+		"total_calls": calls,
+		"total_cost":  usageRound(cost),
+		"callers":     callers,
+	})
+}
+
+// AdminBillingHandler handles GET /admin/billing, exporting a month's
+// invoice lines: each tenant's calls, cached hits, batch rows and cost per
+// model. format=csv downloads them as a spreadsheet.
+func AdminBillingHandler(c *gin.Context) {
+	month, ok := usageMonth(c)
+	if !ok {
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid format",
+			Details: "Must be one of: json, csv",
+		})
+		return
+	}
+
+	lines := Usage.Lines(month)
+	if format == "json" {
+		var total usage.Charges
+		for _, line := range lines {
+			total.Calls += line.Calls
+			total.CachedHits += line.CachedHits
+			total.BatchRows += line.BatchRows
+			total.Cost += line.Cost
+		}
+		total.Cost = usageRound(total.Cost)
+		c.JSON(http.StatusOK, gin.H{
+			"month":    month,
+			"currency": CostCurrency,
+			"lines":    lines,
+			"total":    total,
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="billing-%s.csv"`, month))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"month", "tenant", "model", "calls", "cached_hits", "batch_rows", "cost", "currency"})
+	for _, line := range lines {
+		w.Write([]string{
+			month,
+			line.Tenant,
+			line.Model,
+			strconv.FormatInt(line.Calls, 10),
+			strconv.FormatInt(line.CachedHits, 10),
+			strconv.FormatInt(line.BatchRows, 10),
+			strconv.FormatFloat(line.Cost, 'f', -1, 64),
+			CostCurrency,
+		})
+	}
+	w.Flush()
+}
+
+// usageRound rounds a cost to a millionth of the currency unit
+func usageRound(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
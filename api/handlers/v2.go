@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"cloud-ai-api/events"
+	"cloud-ai-api/models"
+	"cloud-ai-api/plausibility"
+	"cloud-ai-api/registry"
+	"github.com/gin-gonic/gin"
+)
+
+// apiV2 is the version reported in API v2 response metadata
+const apiV2 = "2"
+
+// PredictionV2Handler handles POST /api/v2/predict/:model. It runs the same
+// pipeline as v1 and wraps the result in the v2 envelope.
+func PredictionV2Handler(c *gin.Context) {
+	startTime := time.Now()
+
+	model, payload, perr := parseBodyRequest(c)
+	if perr != nil {
+		respondV2Error(c, perr, startTime)
+		return
+	}
+	servePredictionV2(c, model, payload, startTime)
+}
+
+// PredictionQueryV2Handler handles GET /api/v2/predict/:model with the
+// request fields given as query parameters
+func PredictionQueryV2Handler(c *gin.Context) {
+	startTime := time.Now()
+
+	model, payload, perr := parseQueryRequest(c)
+	if perr != nil {
+		respondV2Error(c, perr, startTime)
+		return
+	}
+	servePredictionV2(c, model, payload, startTime)
+}
+
+// ModelsV2Handler lists the registered models and their request fields
+func ModelsV2Handler(c *gin.Context) {
+	list := make([]gin.H, 0, len(Registry.Names()))
+	for _, m := range Registry.Models() {
+		list = append(list, modelV2(m))
+	}
+	respond(c, http.StatusOK, models.V2Response{
+		Data:  list,
+		Meta:  models.V2Meta{APIVersion: apiV2, Timestamp: time.Now().Format(time.RFC3339)},
+		Links: map[string]string{"self": "/api/v2/models"},
+	})
+}
+
+// ModelV2Handler describes a single registered model
+func ModelV2Handler(c *gin.Context) {
+	startTime := time.Now()
+
+	model, perr := lookupRouteModel(c)
+	if perr != nil {
+		respondV2Error(c, perr, startTime)
+		return
+	}
+	respond(c, http.StatusOK, models.V2Response{
+		Data: modelV2(model),
+		Meta: models.V2Meta{APIVersion: apiV2, Model: model.Name, Timestamp: time.Now().Format(time.RFC3339)},
+		Links: map[string]string{
+			"self":    "/api/v2/models/" + model.Name,
+			"predict": "/api/v2/predict/" + model.Name,
+		},
+	})
+}
+
+// servePredictionV2 runs a prediction and writes the v2 envelope. Timing
+// fields and plausibility warnings move from the prediction into the
+// metadata block.
+func servePredictionV2(c *gin.Context, model *registry.Model, payload map[string]interface{}, startTime time.Time) {
+	if perr := applyLenient(c, model, payload); perr != nil {
+		respondV2Error(c, perr, startTime)
+		return
+	}
+	if flagEnabled(darkFlag(model.Name), c.Request) {
+		accepted, perr := runDarkLaunch(c, model, payload)
+		if perr != nil {
+			respondV2Error(c, perr, startTime)
+			return
+		}
+		meta := v2Meta(startTime)
+		meta.Model = model.Name
+		respond(c, http.StatusAccepted, models.V2Response{Data: accepted, Meta: meta})
+		return
+	}
+
+	minConfidence, perr := parseMinConfidence(c, model)
+	if perr != nil {
+		respondV2Error(c, perr, startTime)
+		return
+	}
+	interval, perr := parseIntervalRequest(c, model)
+	if perr != nil {
+		respondV2Error(c, perr, startTime)
+		return
+	}
+	c.Request = withIntervalRequest(c.Request, interval)
+	priceFormat, perr := parsePriceFormat(c, model)
+	if perr != nil {
+		respondV2Error(c, perr, startTime)
+		return
+	}
+
+	if model.StreamResponse {
+		if perr := streamPredictionV2(c, model, payload, startTime); perr != nil {
+			respondV2Error(c, perr, startTime)
+		}
+		return
+	}
+
+	query, _ := predictionQuery(c.Request.URL.Query(), payload)
+	mlResp, id, perr := runPrediction(c, model, payload, startTime)
+	if perr == nil {
+		perr = checkConfidence(model, mlResp, minConfidence)
+	}
+	if perr == nil {
+		perr = shapeInterval(model, mlResp, interval)
+	}
+	if perr == nil {
+		perr = addFormattedPrice(model, mlResp, priceFormat)
+	}
+	if perr != nil {
+		respondV2Error(c, perr, startTime)
+		return
+	}
+
+	data := make(map[string]interface{}, len(mlResp))
+	for k, v := range mlResp {
+		if k != "processing_time_ms" && k != "prediction_time" && k != "warnings" && k != provenanceField {
+			data[k] = v
+		}
+	}
+
+	meta := v2Meta(startTime)
+	meta.Warnings, _ = mlResp["warnings"].([]plausibility.Warning)
+	meta.RequestID = id
+	meta.Model = model.Name
+	meta.ModelVersion = events.ModelVersion(mlResp)
+	meta.Provenance, _ = mlResp[provenanceField].(map[string]interface{})
+
+	links := map[string]string{
+		"self":  c.Request.URL.RequestURI(),
+		"model": "/api/v2/models/" + model.Name,
+	}
+	for name, link := range predictionLinks(model, query, id) {
+		if name == "trend" || name == "feedback" {
+			links[name] = link.Href
+		}
+	}
+
+	respond(c, http.StatusOK, models.V2Response{
+		Data:  selectFields(data, c.Query("fields")),
+		Meta:  meta,
+		Links: links,
+	})
+}
+
+// respondV2Error writes a failed request in the v2 envelope
+func respondV2Error(c *gin.Context, perr *predictionError, startTime time.Time) {
+	meta := v2Meta(startTime)
+	meta.Model = c.Param("model")
+	if _, ok := Registry.Get(meta.Model); !ok {
+		meta.Model = ""
+	}
+	respond(c, perr.Status, models.V2Response{
+		Error: &models.V2Error{
+			Code:       perr.Code,
+			Message:    perr.Response.Error,
+			Details:    perr.Response.Details,
+			Fields:     perr.Response.Fields,
+			Violations: perr.Response.Violations,
+			Interval:   perr.Response.Interval,
+
+			Suggestions: perr.Response.Suggestions,
+		},
+		Meta:  meta,
+		Links: map[string]string{"models": "/api/v2/models"},
+	})
+}
+
+// v2Meta builds the metadata block common to every v2 response
+func v2Meta(startTime time.Time) models.V2Meta {
+	elapsed := float64(time.Since(startTime).Microseconds()) / 1000
+	return models.V2Meta{
+		APIVersion:       apiV2,
+		Timestamp:        time.Now().Format(time.RFC3339),
+		ProcessingTimeMs: &elapsed,
+	}
+}
+
+// modelV2 describes a model for API v2
+func modelV2(m *registry.Model) gin.H {
+	fields := m.Fields
+	if fields == nil {
+		fields = []registry.Field{}
+	}
+	return gin.H{
+		"name":        m.Name,
+		"description": m.Description,
+		"fields":      fields,
+		"has_schema":  len(m.Schema) > 0 || m.SchemaFile != "",
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"sync"
+
+	"cloud-ai-api/mlcontract"
+	"cloud-ai-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// MLContract is the suite of canonical requests the ML service is verified
+// against
+var MLContract = mlcontract.Default()
+
+// verifyingML allows one contract verification at a time
+var verifyingML sync.Mutex
+
+// VerifyMLHandler runs the ML contract suite against every ML service
+// instance predictions are sent to and reports which cases passed
+func VerifyMLHandler(c *gin.Context) {
+	if !verifyingML.TryLock() {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Verification already running",
+			Details: "Wait for the running verification to finish",
+		})
+		return
+	}
+	defer verifyingML.Unlock()
+
+	backends := []string{MLServiceURL}
+	if MLBackends != nil {
+		backends, _ = MLBackends.Backends()
+	}
+	if len(backends) == 0 {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+			Error:   "No ML service instances",
+			Details: "Discovery has not found any ML service instance",
+		})
+		return
+	}
+
+	passed := true
+	reports := make([]mlcontract.Report, 0, len(backends))
+	for _, base := range backends {
+		rep := mlcontract.Verify(c.Request.Context(), MLClient, base, MLContract)
+		log.Printf("ML contract verification: target=%s passed=%t failed=%d/%d", base, rep.Passed, rep.Failed, rep.Total)
+		passed = passed && rep.Passed
+		reports = append(reports, rep)
+	}
+	c.JSON(http.StatusOK, gin.H{"passed": passed, "reports": reports})
+}
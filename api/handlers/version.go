@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+
+	"cloud-ai-api/buildinfo"
+	"cloud-ai-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Features lists the optional features enabled in this instance
+var Features = map[string]bool{}
+
+// VersionHandler handles build information requests
+func VersionHandler(c *gin.Context) {
+	info := buildinfo.Get()
+	c.JSON(http.StatusOK, models.VersionResponse{
+		Service:   "Cloud AI API Gateway",
+		Version:   info.Version,
+		Commit:    info.Commit,
+		BuildDate: info.BuildDate,
+		GoVersion: info.GoVersion,
+		Features:  Features,
+	})
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"cloud-ai-api/cache"
+	"github.com/robfig/cron/v3"
+)
+
+// warmupConcurrency is how many warm-up predictions run at once, to avoid
+// flooding the ML service
+const warmupConcurrency = 4
+
+// StartWarmup seeds the response cache with predictions for the warm-up
+// sets now and, if schedule is a cron expression, on that schedule
+func StartWarmup(sets []cache.WarmupSet, schedule string) error {
+	if schedule != "" {
+		c := cron.New()
+		if _, err := c.AddFunc(schedule, func() { warmCache(sets) }); err != nil {
+			return err
+		}
+		c.Start()
+	}
+	go warmCache(sets)
+	return nil
+}
+
+// warmCache runs each warm-up payload through the prediction pipeline and
+// caches the response. Warm-up predictions are not recorded in the history.
+func warmCache(sets []cache.WarmupSet) {
+	start := time.Now()
+	var mu sync.Mutex
+	var seeded, failed int
+
+	sem := make(chan struct{}, warmupConcurrency)
+	var wg sync.WaitGroup
+	for _, set := range sets {
+		model, ok := Registry.Get(set.Model)
+		if !ok {
+			log.Printf("Cache warm-up skipped unknown model %q", set.Model)
+			continue
+		}
+		for _, payload := range set.Payloads() {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(payload map[string]interface{}) {
+				defer func() { <-sem; wg.Done() }()
+
+				key := cacheKey(model.Name, payloadHash(payload))
+				mlResp, perr := predict(nil, model, payload)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if perr != nil {
+					failed++
+					log.Printf("Cache warm-up failed: model=%s error=%q details=%q", model.Name, perr.Response.Error, perr.Response.Details)
+					return
+				}
+				ResponseCache.Set(key, model.Name, mlResp)
+				seeded++
+			}(payload)
+		}
+	}
+	wg.Wait()
+
+	log.Printf("Cache warm-up finished: seeded=%d failed=%d duration_ms=%d", seeded, failed, time.Since(start).Milliseconds())
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"cloud-ai-api/registry"
+)
+
+// XML content types accepted and produced by the prediction route
+const (
+	mimeXML  = "application/xml"
+	mimeXML2 = "text/xml"
+)
+
+// decodeXMLPayload reads a payload from an XML document. The root element
+// name is ignored and each child element becomes a field. Because XML text
+// is untyped, values of fields the model declares as integer, number or
+// boolean are converted to those types; other values stay strings. Elements
+// with child elements become objects, and repeated elements become arrays.
+func decodeXMLPayload(body io.Reader, model *registry.Model) (map[string]interface{}, error) {
+	decoder := xml.NewDecoder(body)
+
+	// Find the root element
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				return nil, fmt.Errorf("request body must contain an XML element")
+			}
+			return nil, err
+		}
+		if _, ok := tok.(xml.StartElement); ok {
+			break
+		}
+	}
+
+	value, err := decodeXMLElement(decoder)
+	if err != nil {
+		return nil, err
+	}
+	payload, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("root element must contain field elements")
+	}
+
+	types := fieldTypes(model)
+	for name, v := range payload {
+		if s, ok := v.(string); ok {
+			payload[name] = registry.TypedValue(s, types[name])
+		}
+	}
+	return payload, nil
+}
+
+// decodeXMLElement reads the content of the current element up to its end
+// tag, returning either its text or a map of its child elements
+func decodeXMLElement(decoder *xml.Decoder) (interface{}, error) {
+	var text strings.Builder
+	var children map[string]interface{}
+
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := decodeXMLElement(decoder)
+			if err != nil {
+				return nil, err
+			}
+			if children == nil {
+				children = make(map[string]interface{})
+			}
+			name := t.Name.Local
+			switch existing := children[name].(type) {
+			case nil:
+				children[name] = child
+			case []interface{}:
+				children[name] = append(existing, child)
+			default:
+				children[name] = []interface{}{existing, child}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if children != nil {
+				return children, nil
+			}
+			return strings.TrimSpace(text.String()), nil
+		}
+	}
+}
+
+// writeXML writes a response as an XML document with the given root element.
+// Object keys become elements in sorted order and array items are written
+// as repeated <item> elements.
+func writeXML(w io.Writer, root string, obj interface{}) error {
+	m, err := plainMap(obj)
+	if err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	if err := encodeXMLValue(encoder, root, m); err != nil {
+		return err
+	}
+	return encoder.Flush()
+}
+
+func encodeXMLValue(encoder *xml.Encoder, name string, v interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if !validXMLName(name) {
+		start = xml.StartElement{
+			Name: xml.Name{Local: "field"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "name"}, Value: name}},
+		}
+	}
+
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if err := encoder.EncodeToken(start); err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := encodeXMLValue(encoder, k, value[k]); err != nil {
+				return err
+			}
+		}
+		return encoder.EncodeToken(start.End())
+	case []interface{}:
+		if err := encoder.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, item := range value {
+			if err := encodeXMLValue(encoder, "item", item); err != nil {
+				return err
+			}
+		}
+		return encoder.EncodeToken(start.End())
+	case nil:
+		return encoder.EncodeElement("", start)
+	}
+	return encoder.EncodeElement(fmt.Sprint(v), start)
+}
+
+// validXMLName reports whether s can be used as an element name as-is
+func validXMLName(s string) bool {
+	if s == "" || strings.HasPrefix(strings.ToLower(s), "xml") {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'):
+		case i > 0 && (r == '-' || r == '.' || (r >= '0' && r <= '9')):
+		default:
+			return false
+		}
+	}
+	return true
+}
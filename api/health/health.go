@@ -0,0 +1,158 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Statuses of a component and of the service overall
+const (
+	StatusUp        = "up"
+	StatusDown      = "down"
+	StatusHealthy   = "healthy"
+	StatusDegraded  = "degraded"
+	StatusUnhealthy = "unhealthy"
+)
+
+// Check reports whether a dependency is usable
+type Check func(ctx context.Context) error
+
+// Component is a dependency whose health is monitored. The service is
+// unhealthy when a critical component is down and degraded when any other
+// component is down.
+type Component struct {
+	Name     string
+	Critical bool
+	Check    Check
+}
+
+// ComponentStatus is the latest check result for a component
+type ComponentStatus struct {
+	Name        string  `json:"name"`
+	Status      string  `json:"status"`
+	Critical    bool    `json:"critical"`
+	LatencyMs   float64 `json:"latency_ms"`
+	LastError   string  `json:"last_error,omitempty"`
+	LastChecked string  `json:"last_checked"`
+	LastSuccess string  `json:"last_success,omitempty"`
+}
+
+// Monitor checks components in the background and caches the results, so
+// frequent health probes do not each call every dependency
+type Monitor struct {
+	interval time.Duration
+	timeout  time.Duration
+
+	mu         sync.Mutex
+	components []Component
+	statuses   map[string]ComponentStatus
+	checked    time.Time
+}
+
+// NewMonitor creates a monitor checking every interval, giving each check
+// up to timeout
+func NewMonitor(interval, timeout time.Duration) *Monitor {
+	return &Monitor{interval: interval, timeout: timeout, statuses: make(map[string]ComponentStatus)}
+}
+
+// Add registers a component. Components must be added before Start.
+func (m *Monitor) Add(c Component) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.components = append(m.components, c)
+}
+
+// Start checks all components now and then every interval
+func (m *Monitor) Start() {
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			m.store(m.checkAll())
+			<-ticker.C
+		}
+	}()
+}
+
+// Report returns the overall status and the status of each component in
+// registration order. If the background checks have fallen more than two
+// intervals behind, the components are checked before returning; concurrent
+// callers wait for that one check.
+func (m *Monitor) Report() (string, []ComponentStatus) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if time.Since(m.checked) > 2*m.interval {
+		m.storeLocked(m.checkAll())
+	}
+
+	overall := StatusHealthy
+	out := make([]ComponentStatus, 0, len(m.components))
+	for _, c := range m.components {
+		s := m.statuses[c.Name]
+		if s.Status == StatusDown {
+			if c.Critical {
+				overall = StatusUnhealthy
+			} else if overall == StatusHealthy {
+				overall = StatusDegraded
+			}
+		}
+		out = append(out, s)
+	}
+	return overall, out
+}
+
+// checkAll checks every component in parallel
+func (m *Monitor) checkAll() []ComponentStatus {
+	results := make([]ComponentStatus, len(m.components))
+	var wg sync.WaitGroup
+	for i, c := range m.components {
+		wg.Add(1)
+		go func(i int, c Component) {
+			defer wg.Done()
+			results[i] = m.check(c)
+		}(i, c)
+	}
+	wg.Wait()
+	return results
+}
+
+func (m *Monitor) check(c Component) ComponentStatus {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.Check(ctx)
+	s := ComponentStatus{
+		Name:        c.Name,
+		Status:      StatusUp,
+		Critical:    c.Critical,
+		LatencyMs:   float64(time.Since(start).Microseconds()) / 1000,
+		LastChecked: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if err != nil {
+		s.Status = StatusDown
+		s.LastError = err.Error()
+	} else {
+		s.LastSuccess = s.LastChecked
+	}
+	return s
+}
+
+func (m *Monitor) store(results []ComponentStatus) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.storeLocked(results)
+}
+
+// storeLocked saves check results, keeping each component's last success
+func (m *Monitor) storeLocked(results []ComponentStatus) {
+	for _, s := range results {
+		if s.LastSuccess == "" {
+			s.LastSuccess = m.statuses[s.Name].LastSuccess
+		}
+		m.statuses[s.Name] = s
+	}
+	m.checked = time.Now()
+}
@@ -0,0 +1,120 @@
+package history
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Entry is a single recorded prediction. Tenant and APIKey identify the
+// authenticated caller it was made for, if any; they are never exported.
+type Entry struct {
+	ID        string                 `json:"id"`
+	Model     string                 `json:"model"`
+	Request   map[string]interface{} `json:"request"`
+	Response  map[string]interface{} `json:"response"`
+	LatencyMs float64                `json:"latency_ms"`
+	Timestamp time.Time              `json:"timestamp"`
+	Tenant    string                 `json:"-"`
+	APIKey    string                 `json:"-"`
+}
+
+// Filter selects entries from the store. Zero values match everything, so
+// a filter for one caller's entries must set Tenant, APIKey or both.
+type Filter struct {
+	Model  string
+	Tenant string
+	APIKey string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
+
+// Store keeps the most recent predictions in memory, oldest evicted first.
+// Entries are never persisted.
+type Store struct {
+	mu      sync.RWMutex
+	entries []Entry
+	next    int
+	full    bool
+	seq     uint64
+}
+
+// NewStore creates a store holding up to size entries
+func NewStore(size int) *Store {
+	if size < 1 {
+		size = 1
+	}
+	return &Store{entries: make([]Entry, size)}
+}
+
+// Record adds a prediction to the store and returns its ID
+func (s *Store) Record(e Entry) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+	s.seq++
+	e.ID = fmt.Sprintf("pred-%d-%d", e.Timestamp.Unix(), s.seq)
+
+	s.entries[s.next] = e
+	s.next = (s.next + 1) % len(s.entries)
+	if s.next == 0 {
+		s.full = true
+	}
+	return e.ID
+}
+
+// List returns matching entries, newest first
+func (s *Store) List(f Filter) []Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := s.next
+	if s.full {
+		count = len(s.entries)
+	}
+
+	var out []Entry
+	for i := 0; i < count; i++ {
+		idx := (s.next - 1 - i + len(s.entries)) % len(s.entries)
+		e := s.entries[idx]
+		if !f.matches(e) {
+			continue
+		}
+		out = append(out, e)
+		if f.Limit > 0 && len(out) >= f.Limit {
+			break
+		}
+	}
+	return out
+}
+
+// Len returns the number of stored entries
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.full {
+		return len(s.entries)
+	}
+	return s.next
+}
+
+func (f Filter) matches(e Entry) bool {
+	if f.Model != "" && e.Model != f.Model {
+		return false
+	}
+	if (f.Tenant != "" && e.Tenant != f.Tenant) || (f.APIKey != "" && e.APIKey != f.APIKey) {
+		return false
+	}
+	if !f.Since.IsZero() && e.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.Timestamp.After(f.Until) {
+		return false
+	}
+	return true
+}
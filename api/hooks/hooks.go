@@ -0,0 +1,92 @@
+package hooks
+
+import (
+	"fmt"
+	"net/http"
+	"plugin"
+	"sync"
+)
+
+// Stage identifies where in the prediction pipeline a hook runs
+type Stage string
+
+const (
+	// PreValidate hooks run on the decoded request before validation
+	PreValidate Stage = "pre_validate"
+	// PreForward hooks run on the validated request before it is sent to the ML service
+	PreForward Stage = "pre_forward"
+	// PostResponse hooks run on the ML service response before it is returned
+	PostResponse Stage = "post_response"
+)
+
+// AllModels registers a hook for every model
+const AllModels = "*"
+
+// Context describes the prediction a hook is running for. Request is nil for
+// predictions that are not tied to an HTTP request, such as batch job rows.
+type Context struct {
+	Model   string
+	Stage   Stage
+	Request *http.Request
+}
+
+// Func transforms a request or response payload in place. Returning an
+// error aborts the prediction.
+type Func func(ctx *Context, payload map[string]interface{}) error
+
+var (
+	mu         sync.RWMutex
+	registered = make(map[string]map[Stage][]Func)
+)
+
+// Register adds a hook for a model (or AllModels) at the given stage.
+// Hooks run in registration order, after the model's config transformations.
+func Register(model string, stage Stage, fn Func) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if registered[model] == nil {
+		registered[model] = make(map[Stage][]Func)
+	}
+	registered[model][stage] = append(registered[model][stage], fn)
+}
+
+// Run applies the config transformations and then the registered hooks
+// for the context's model and stage
+func Run(ctx *Context, transforms []Transform, payload map[string]interface{}) error {
+	for _, t := range transforms {
+		if err := t.Apply(payload); err != nil {
+			return fmt.Errorf("%s transform %q: %w", ctx.Stage, t.Type, err)
+		}
+	}
+
+	mu.RLock()
+	fns := append([]Func{}, registered[AllModels][ctx.Stage]...)
+	if ctx.Model != AllModels {
+		fns = append(fns, registered[ctx.Model][ctx.Stage]...)
+	}
+	mu.RUnlock()
+
+	for _, fn := range fns {
+		if err := fn(ctx, payload); err != nil {
+			return fmt.Errorf("%s hook: %w", ctx.Stage, err)
+		}
+	}
+	return nil
+}
+
+// LoadPlugins opens Go plugins built with -buildmode=plugin. A plugin
+// registers its hooks by calling Register from an init function.
+func LoadPlugins(paths []string) error {
+	for _, path := range paths {
+		if _, err := plugin.Open(path); err != nil {
+			return fmt.Errorf("failed to load hook plugin %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// ValidStage reports whether s names a pipeline stage
+func ValidStage(s Stage) bool {
+	return s == PreValidate || s == PreForward || s == PostResponse
+}
@@ -0,0 +1,88 @@
+package hooks
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Transform is a config-defined payload transformation:
+//
+//	rename:  moves From to To
+//	default: sets Field to Value when it is missing or null
+//	scale:   replaces numeric Field with Field*Factor + Offset (unit conversion)
+//	remove:  deletes Field
+type Transform struct {
+	Type   string      `json:"type"`
+	Field  string      `json:"field,omitempty"`
+	From   string      `json:"from,omitempty"`
+	To     string      `json:"to,omitempty"`
+	Value  interface{} `json:"value,omitempty"`
+	Factor *float64    `json:"factor,omitempty"`
+	Offset float64     `json:"offset,omitempty"`
+}
+
+// Check verifies that a transform declaration is complete
+func (t Transform) Check() error {
+	switch t.Type {
+	case "rename":
+		if t.From == "" || t.To == "" {
+			return fmt.Errorf("rename transform requires from and to")
+		}
+	case "default":
+		if t.Field == "" || t.Value == nil {
+			return fmt.Errorf("default transform requires field and value")
+		}
+	case "scale":
+		if t.Field == "" || t.Factor == nil {
+			return fmt.Errorf("scale transform requires field and factor")
+		}
+	case "remove":
+		if t.Field == "" {
+			return fmt.Errorf("remove transform requires field")
+		}
+	default:
+		return fmt.Errorf("unknown transform type %q (must be one of: rename, default, scale, remove)", t.Type)
+	}
+	return nil
+}
+
+// Apply performs the transformation on the payload in place
+func (t Transform) Apply(payload map[string]interface{}) error {
+	switch t.Type {
+	case "rename":
+		if value, ok := payload[t.From]; ok {
+			delete(payload, t.From)
+			payload[t.To] = value
+		}
+	case "default":
+		if value, ok := payload[t.Field]; !ok || value == nil {
+			payload[t.Field] = t.Value
+		}
+	case "scale":
+		value, ok := payload[t.Field]
+		if !ok || value == nil {
+			return nil
+		}
+		n, ok := toFloat(value)
+		if !ok {
+			return fmt.Errorf("field %q is not a number", t.Field)
+		}
+		payload[t.Field] = n*(*t.Factor) + t.Offset
+	case "remove":
+		delete(payload, t.Field)
+	}
+	return nil
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		n, err := v.Float64()
+		return n, err == nil
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,164 @@
+// Command extract lists the English error messages the gateway's handlers
+// and middleware write, for the startup check that every catalog language
+// translates them. It reads the Error and Details of every
+// models.ErrorResponse literal whose value is a string literal or a
+// fmt.Sprintf format, writing verbs as placeholders {0}, {1}, ..., and
+// writes them to messages_gen.go. Run it with go generate ./i18n after
+// adding or changing a message.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sources are the packages, relative to the i18n directory, whose
+// messages reach callers
+var sources = []string{"../handlers", "../middleware"}
+
+// verb matches a fmt verb such as %s, %d or %.2f
+var verb = regexp.MustCompile(`%[-+# 0]*\d*(\.\d+)?[a-zA-Z]`)
+
+func main() {
+	seen := make(map[string]bool)
+	fset := token.NewFileSet()
+	for _, dir := range sources {
+		files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, path := range files {
+			if strings.HasSuffix(path, "_test.go") {
+				continue
+			}
+			file, err := parser.ParseFile(fset, path, nil, 0)
+			if err != nil {
+				log.Fatal(err)
+			}
+			ast.Inspect(file, func(n ast.Node) bool {
+				lit, ok := n.(*ast.CompositeLit)
+				if !ok || !isErrorResponse(lit.Type) {
+					return true
+				}
+				for _, elt := range lit.Elts {
+					kv, ok := elt.(*ast.KeyValueExpr)
+					if !ok {
+						continue
+					}
+					if key, ok := kv.Key.(*ast.Ident); ok && (key.Name == "Error" || key.Name == "Details") {
+						if msg, ok := message(kv.Value); ok && msg != "" {
+							seen[msg] = true
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+
+	messages := make([]string, 0, len(seen))
+	for msg := range seen {
+		messages = append(messages, msg)
+	}
+	sort.Strings(messages)
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by go run ./extract; DO NOT EDIT.\n\npackage i18n\n\n")
+	b.WriteString("// Messages are the English error messages the gateway's handlers and\n")
+	b.WriteString("// middleware write, with variable parts as placeholders\n")
+	b.WriteString("var Messages = []string{\n")
+	for _, msg := range messages {
+		fmt.Fprintf(&b, "\t%s,\n", strconv.Quote(msg))
+	}
+	b.WriteString("}\n")
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("messages_gen.go", src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// isErrorResponse reports whether a composite literal's type is
+// models.ErrorResponse
+func isErrorResponse(expr ast.Expr) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "ErrorResponse" {
+		return false
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	return ok && pkg.Name == "models"
+}
+
+// message returns the text of a string literal, or the format of a
+// fmt.Sprintf call with its verbs numbered as placeholders
+func message(expr ast.Expr) (string, bool) {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		if e.Kind != token.STRING {
+			return "", false
+		}
+		s, err := strconv.Unquote(e.Value)
+		return s, err == nil
+	case *ast.CallExpr:
+		fn, ok := e.Fun.(*ast.SelectorExpr)
+		if !ok || fn.Sel.Name != "Sprintf" || len(e.Args) == 0 {
+			return "", false
+		}
+		if pkg, ok := fn.X.(*ast.Ident); !ok || pkg.Name != "fmt" {
+			return "", false
+		}
+		format, ok := message(e.Args[0])
+		if !ok {
+			return "", false
+		}
+		n := 0
+		return verb.ReplaceAllStringFunc(format, func(string) string {
+			n++
+			return "{" + strconv.Itoa(n-1) + "}"
+		}), true
+	case *ast.BinaryExpr:
+		// A concatenation such as "Response has no positive " + field
+		// leaves a placeholder for each operand that is not a literal
+		if e.Op != token.ADD {
+			return "", false
+		}
+		left, okLeft := message(e.X)
+		right, okRight := message(e.Y)
+		if !okLeft && !okRight {
+			return "", false
+		}
+		if !okLeft {
+			left = "{}"
+		}
+		if !okRight {
+			right = "{}"
+		}
+		return renumber(left + right), true
+	}
+	return "", false
+}
+
+// placeholder matches a numbered or unnumbered placeholder
+var placeholder = regexp.MustCompile(`\{\d*\}`)
+
+// renumber numbers the placeholders of a concatenated message from {0}
+func renumber(msg string) string {
+	n := 0
+	return placeholder.ReplaceAllStringFunc(msg, func(string) string {
+		n++
+		return "{" + strconv.Itoa(n-1) + "}"
+	})
+}
@@ -0,0 +1,277 @@
+package i18n
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:generate go run ./extract
+
+//go:embed default.json
+var defaultCatalog []byte
+
+// English is the language the gateway writes its messages in
+const English = "en"
+
+// placeholder marks a variable part of a message, such as {0}
+var placeholder = regexp.MustCompile(`\{(\d+)\}`)
+
+// Catalog holds translations of the gateway's English messages by
+// language. Messages are looked up by their English text; keys may contain
+// placeholders {0}, {1}, ... that match any text and are carried over to
+// the translation, themselves translated when the catalog has an entry
+// for them.
+type Catalog struct {
+	languages map[string]*messages
+}
+
+type messages struct {
+	exact    map[string]string
+	patterns []pattern
+}
+
+// pattern is a message key with placeholders
+type pattern struct {
+	key    string
+	re     *regexp.Regexp
+	args   []int // placeholder number of each capture group
+	target string
+}
+
+// Default returns the bundled catalog, with Albanian and German
+func Default() *Catalog {
+	c, err := Parse(defaultCatalog)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in message catalog: %s", err))
+	}
+	return c
+}
+
+// Load reads a message catalog from a JSON file and layers it over the
+// bundled one: its languages are added and its entries replace bundled
+// entries with the same key
+func Load(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message catalog: %w", err)
+	}
+	extra, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Parse(defaultCatalog)
+	if err != nil {
+		return nil, err
+	}
+	for lang, m := range extra.languages {
+		base, ok := c.languages[lang]
+		if !ok {
+			c.languages[lang] = m
+			continue
+		}
+		for key, target := range m.exact {
+			base.exact[key] = target
+		}
+		for _, p := range m.patterns {
+			base.addPattern(p)
+		}
+		base.sortPatterns()
+	}
+	return c, nil
+}
+
+// Parse builds a catalog from its JSON representation, an object mapping
+// language tags to objects of English messages and their translations
+func Parse(data []byte) (*Catalog, error) {
+	var raw map[string]map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse message catalog: %w", err)
+	}
+	c := &Catalog{languages: make(map[string]*messages, len(raw))}
+	for tag, entries := range raw {
+		lang := strings.ToLower(tag)
+		if lang == "" || lang == English {
+			return nil, fmt.Errorf("language %q: messages are written in English; list translations only", tag)
+		}
+		m := &messages{exact: make(map[string]string)}
+		for key, target := range entries {
+			if key == "" || target == "" {
+				return nil, fmt.Errorf("language %q: empty message or translation", tag)
+			}
+			if !placeholder.MatchString(key) {
+				m.exact[key] = target
+				continue
+			}
+			p, err := compilePattern(key, target)
+			if err != nil {
+				return nil, fmt.Errorf("language %q: message %q: %w", tag, key, err)
+			}
+			m.addPattern(p)
+		}
+		m.sortPatterns()
+		c.languages[lang] = m
+	}
+	return c, nil
+}
+
+func compilePattern(key, target string) (pattern, error) {
+	p := pattern{key: key, target: target}
+	var expr strings.Builder
+	expr.WriteString("^")
+	last := 0
+	used := make(map[int]bool)
+	for _, loc := range placeholder.FindAllStringSubmatchIndex(key, -1) {
+		expr.WriteString(regexp.QuoteMeta(key[last:loc[0]]))
+		expr.WriteString("(.+?)")
+		n, _ := strconv.Atoi(key[loc[2]:loc[3]])
+		if used[n] {
+			return p, fmt.Errorf("placeholder {%d} appears twice", n)
+		}
+		used[n] = true
+		p.args = append(p.args, n)
+		last = loc[1]
+	}
+	expr.WriteString(regexp.QuoteMeta(key[last:]))
+	expr.WriteString("$")
+	for _, m := range placeholder.FindAllStringSubmatch(target, -1) {
+		n, _ := strconv.Atoi(m[1])
+		if !used[n] {
+			return p, fmt.Errorf("translation uses {%d}, which the message does not have", n)
+		}
+	}
+	p.re = regexp.MustCompile(expr.String())
+	return p, nil
+}
+
+// addPattern adds p, replacing any pattern with the same key
+func (m *messages) addPattern(p pattern) {
+	for i := range m.patterns {
+		if m.patterns[i].key == p.key {
+			m.patterns[i] = p
+			return
+		}
+	}
+	m.patterns = append(m.patterns, p)
+}
+
+// sortPatterns orders patterns with the most literal text first, so the
+// most specific key matches a message
+func (m *messages) sortPatterns() {
+	sort.SliceStable(m.patterns, func(i, j int) bool {
+		li := len(placeholder.ReplaceAllString(m.patterns[i].key, ""))
+		lj := len(placeholder.ReplaceAllString(m.patterns[j].key, ""))
+		if li != lj {
+			return li > lj
+		}
+		return m.patterns[i].key < m.patterns[j].key
+	})
+}
+
+// Languages lists the supported language tags, English first
+func (c *Catalog) Languages() []string {
+	langs := make([]string, 0, len(c.languages))
+	for lang := range c.languages {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return append([]string{English}, langs...)
+}
+
+// Negotiate picks the supported language an Accept-Language header
+// prefers, matching a regional tag such as de-AT by its primary language.
+// It returns English when the header names no supported language.
+func (c *Catalog) Negotiate(header string) string {
+	type choice struct {
+		tag string
+		q   float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			choices = append(choices, choice{tag, q})
+		}
+	}
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+
+	for _, ch := range choices {
+		if ch.tag == "*" {
+			return English
+		}
+		primary, _, _ := strings.Cut(ch.tag, "-")
+		for _, lang := range []string{ch.tag, primary} {
+			if lang == English {
+				return English
+			}
+			if _, ok := c.languages[lang]; ok {
+				return lang
+			}
+		}
+	}
+	return English
+}
+
+// Translate returns text in lang, or text itself when the catalog has no
+// translation for it
+func (c *Catalog) Translate(lang, text string) string {
+	m, ok := c.languages[lang]
+	if !ok || text == "" {
+		return text
+	}
+	if target, ok := m.exact[text]; ok {
+		return target
+	}
+	for _, p := range m.patterns {
+		match := p.re.FindStringSubmatch(text)
+		if match == nil {
+			continue
+		}
+		args := make(map[string]string, len(p.args))
+		for i, n := range p.args {
+			arg := match[i+1]
+			if target, ok := m.exact[arg]; ok {
+				arg = target
+			}
+			args[strconv.Itoa(n)] = arg
+		}
+		return placeholder.ReplaceAllStringFunc(p.target, func(s string) string {
+			return args[s[1:len(s)-1]]
+		})
+	}
+	return text
+}
+
+// Untranslated returns, by language, the messages a language leaves in
+// English, so gaps in a catalog can be reported at startup. Messages made
+// only of placeholders are skipped.
+func (c *Catalog) Untranslated(messages []string) map[string][]string {
+	missing := make(map[string][]string)
+	for lang := range c.languages {
+		for _, msg := range messages {
+			if strings.TrimSpace(placeholder.ReplaceAllString(msg, "")) == "" {
+				continue
+			}
+			if c.Translate(lang, msg) == msg {
+				missing[lang] = append(missing[lang], msg)
+			}
+		}
+	}
+	return missing
+}
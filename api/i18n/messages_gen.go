@@ -0,0 +1,205 @@
+// Code generated by go run ./extract; DO NOT EDIT.
+
+package i18n
+
+// Messages are the English error messages the gateway's handlers and
+// middleware write, with variable parts as placeholders
+var Messages = []string{
+	"Accepted for evaluation",
+	"Account exists",
+	"Affordability unavailable",
+	"Allowed methods: {0}",
+	"An unexpected error occurred; quote request ID {0} when reporting it",
+	"Anomalous input",
+	"Anomaly score {0} is at or above the limit {1}",
+	"Appraisals unavailable",
+	"At most {0} payloads can be compared at once",
+	"Authenticate with an API key to export your predictions",
+	"Authenticate with an API key to manage schedules",
+	"Authenticate with an API key to see usage",
+	"Ban not found",
+	"Caller not identified",
+	"Capture already running",
+	"Confidence {0} is below the requested {1}",
+	"Discovery has not found any ML service instance",
+	"Drift monitoring disabled",
+	"Dynamic configuration is disabled",
+	"Each caller may have at most {0} schedules",
+	"Every ensemble member failed",
+	"Every leaderboard prediction failed",
+	"Failed to create key",
+	"Failed to encode response",
+	"Failed to register account",
+	"Failed to revoke key",
+	"Failed to rotate key",
+	"Failed to send verification email",
+	"Failed to update IP rules",
+	"Failed to update maintenance mode",
+	"Failed to update route",
+	"Failed to verify account",
+	"Forbidden",
+	"Identity provider unavailable",
+	"Implausible input",
+	"Implausible prediction",
+	"Internal server error",
+	"Invalid API key",
+	"Invalid ML response",
+	"Invalid affordability request",
+	"Invalid backend",
+	"Invalid candidate",
+	"Invalid combine method",
+	"Invalid concurrency",
+	"Invalid cron expression",
+	"Invalid destination",
+	"Invalid email",
+	"Invalid format",
+	"Invalid group",
+	"Invalid history",
+	"Invalid interval request",
+	"Invalid leaderboard request",
+	"Invalid lenient",
+	"Invalid limit",
+	"Invalid max_requests",
+	"Invalid min_confidence",
+	"Invalid model",
+	"Invalid month",
+	"Invalid notify_email",
+	"Invalid path",
+	"Invalid price format",
+	"Invalid properties",
+	"Invalid rate",
+	"Invalid request",
+	"Invalid request format",
+	"Invalid retry_after",
+	"Invalid rows",
+	"Invalid rules",
+	"Invalid sample_rate",
+	"Invalid schedule",
+	"Invalid source",
+	"Invalid target",
+	"Invalid template",
+	"Invalid token",
+	"Invalid variables",
+	"Invalid webhook URL",
+	"Invalid {0}",
+	"Job is {0}",
+	"Job not finished",
+	"Job not found",
+	"Key already rotated",
+	"Key not cached",
+	"Key not found",
+	"Login failed",
+	"ML response too large",
+	"ML service busy",
+	"ML service error",
+	"ML service unavailable",
+	"Method not allowed",
+	"Missing query",
+	"Missing required fields",
+	"Model disabled",
+	"Must be a bare address such as user@example.com",
+	"Must be a number between 0 and 1 (exclusive)",
+	"Must be a past or current month as YYYY-MM",
+	"Must be a positive integer",
+	"Must be a request path starting with / (optionally ending in *); admin routes cannot be disabled",
+	"Must be an http(s) URL of the ML service",
+	"Must be an http(s) URL of the target gateway",
+	"Must be at least 0",
+	"Must be between 1 and 256",
+	"Must be between 1 and 32",
+	"Must be between 1 and {0}",
+	"Must be formatted as YYYY-MM",
+	"Must be greater than 0 and at most 1",
+	"Must be greater than 0 and at most {0} requests per second",
+	"Must be key or tenant",
+	"Must be one of: csv, xlsx",
+	"Must be one of: json, csv",
+	"Must be one of: mean, median, weighted",
+	"Must be one of: pdf, html",
+	"Must be one of: {0}",
+	"Must be true or false",
+	"Must contain between 1 and {0} properties",
+	"Must contain between 1 and {0} rows",
+	"No ML service instances",
+	"No capture is running",
+	"No ensemble configured",
+	"No leaderboard configured",
+	"No login in progress; start again at /admin/login",
+	"No payloads",
+	"No rollout is active",
+	"No route matches {0} {1}",
+	"No running replay with this ID",
+	"Not found",
+	"Prediction confidence too low",
+	"Quota exceeded",
+	"Rate limit exceeded",
+	"Regional statistics unavailable",
+	"Replay not found",
+	"Report generation failed",
+	"Reports unavailable",
+	"Request transformation failed",
+	"Requests from your address are not allowed on this route",
+	"Required: backend",
+	"Required: enabled",
+	"Required: field, as the model declares no interval",
+	"Required: model",
+	"Required: model, version",
+	"Required: source (unless a capture has finished) and target",
+	"Required: {0}",
+	"Response cache is disabled",
+	"Response has no confidence interval ({0}, {1}, {2})",
+	"Response has no numeric {0} field",
+	"Response has no positive {0}",
+	"Response is missing the requested {0}",
+	"Response transformation failed",
+	"Rollout already active",
+	"Route is not disabled",
+	"Rules would lock you out",
+	"Schedule limit reached",
+	"Schedule not found",
+	"Send the account token from verification as a Bearer token",
+	"Send the token from the verification link as token",
+	"Service overloaded",
+	"Service under maintenance",
+	"Set DRIFT_WINDOW to enable it",
+	"Set DYNAMIC_CONFIG to etcd or consul to enable it",
+	"Set RESPONSE_CACHE_SIZE to enable it",
+	"Streamed models cannot be compared",
+	"Temporarily disabled",
+	"The API key has used its daily request quota; it resets at midnight UTC",
+	"The API key is unknown, revoked or expired",
+	"The baseline and candidate are the same backend and path",
+	"The gateway is shedding load; retry after the time in the Retry-After header",
+	"The history has no {0} predictions; send payloads",
+	"The housing model declares no confidence interval",
+	"The housing model declares no predicted value field",
+	"The input lies outside the model's training data",
+	"The key is already being replaced; rotate its replacement instead",
+	"The prediction lies outside the model's sanity bounds",
+	"The rule for {0} would block your address {1} ({2}); add ?force=true to apply anyway",
+	"The verification link is invalid or has expired; register again for a new one",
+	"The {0} model does not report a confidence interval",
+	"The {0} model has no ensemble",
+	"The {0} model has no leaderboard",
+	"The {0} model is not enabled for this caller",
+	"The {0} role may only read admin state",
+	"Timed out waiting for the ML service; please retry later",
+	"Too many concurrent requests",
+	"Too many jobs notify this address; retry after the time in the Retry-After header or omit notify_email",
+	"Too many keys",
+	"Too many notification emails",
+	"Too many payloads",
+	"Too many registrations",
+	"Too many requests; retry after the time in the Retry-After header",
+	"Too many verification emails requested; retry after the time in the Retry-After header",
+	"Try registering again later",
+	"Unauthorized",
+	"Unknown model",
+	"Verification already running",
+	"Wait for the running verification to finish",
+	"Your address is temporarily banned after too many failed requests; retry after the time in the Retry-After header",
+	"input must be a JSON object",
+	"template must be a JSON object",
+	"{0} is at capacity; please retry shortly",
+	"{0} is not banned",
+}
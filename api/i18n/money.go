@@ -0,0 +1,95 @@
+package i18n
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MoneyFormat is a locale's convention for writing amounts of money in
+// whole units: the digit group separator and where the currency symbol
+// goes. Symbols are separated from the amount by a no-break space.
+type MoneyFormat struct {
+	Group       string
+	SymbolAfter bool
+	SymbolSpace bool
+}
+
+// moneyFormats by language tag; regional tags not listed use their
+// language's format
+var moneyFormats = map[string]MoneyFormat{
+	"en":    {Group: ","},
+	"de":    {Group: ".", SymbolAfter: true, SymbolSpace: true},
+	"de-ch": {Group: "’", SymbolSpace: true},
+	"sq":    {Group: "\u00a0", SymbolAfter: true, SymbolSpace: true},
+	"fr":    {Group: "\u202f", SymbolAfter: true, SymbolSpace: true},
+	"es":    {Group: ".", SymbolAfter: true, SymbolSpace: true},
+	"it":    {Group: ".", SymbolAfter: true, SymbolSpace: true},
+	"nl":    {Group: ".", SymbolSpace: true},
+}
+
+// currencySymbols by ISO 4217 code; other currencies are written with
+// their code
+var currencySymbols = map[string]string{
+	"GBP": "£",
+	"EUR": "€",
+	"USD": "$",
+	"CHF": "CHF",
+	"ALL": "Lekë",
+}
+
+// LookupMoneyFormat returns the money format for a locale such as en-GB,
+// matching a regional tag by its language when the region has no format
+// of its own
+func LookupMoneyFormat(locale string) (MoneyFormat, bool) {
+	tag := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+	if f, ok := moneyFormats[tag]; ok {
+		return f, true
+	}
+	primary, _, _ := strings.Cut(tag, "-")
+	f, ok := moneyFormats[primary]
+	return f, ok
+}
+
+// MoneyLocales lists the languages and regions with a money format
+func MoneyLocales() []string {
+	locales := make([]string, 0, len(moneyFormats))
+	for tag := range moneyFormats {
+		locales = append(locales, tag)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Format writes amount, rounded to whole units, in currency, e.g.
+// "£285,000" for en or "285.000 €" for de
+func (f MoneyFormat) Format(amount float64, currency string) string {
+	n := int64(math.Round(amount))
+	sign := ""
+	if n < 0 {
+		sign = "-"
+		n = -n
+	}
+	digits := strconv.FormatInt(n, 10)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(f.Group)
+		}
+		b.WriteRune(d)
+	}
+
+	symbol, ok := currencySymbols[currency]
+	if !ok {
+		symbol = currency
+	}
+	space := ""
+	if f.SymbolSpace || len([]rune(symbol)) > 1 {
+		space = "\u00a0"
+	}
+	if f.SymbolAfter {
+		return sign + b.String() + space + symbol
+	}
+	return sign + symbol + space + b.String()
+}
@@ -0,0 +1,261 @@
+package ipfilter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"cloud-ai-api/atrest"
+)
+
+// Reasons a request is blocked
+const (
+	// Denied means the client is in the rule's deny list
+	Denied = "denied"
+	// NotAllowed means the rule has an allow list the client is not in
+	NotAllowed = "not_allowed"
+)
+
+// Rule restricts which clients may make requests whose path matches Path,
+// either exactly or, if Path ends in "*", by prefix. Clients in Deny are
+// blocked; if Allow is set, clients outside it are blocked too. Entries are
+// IP addresses or CIDR ranges.
+type Rule struct {
+	Path    string   `json:"path"`
+	Allow   []string `json:"allow,omitempty"`
+	Deny    []string `json:"deny,omitempty"`
+	Comment string   `json:"comment,omitempty"`
+
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// matches reports whether the rule applies to a path
+func (r *Rule) matches(path string) bool {
+	if prefix, ok := strings.CutSuffix(r.Path, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+	return r.Path == path
+}
+
+// compile parses the rule's networks
+func (r *Rule) compile() error {
+	if !strings.HasPrefix(r.Path, "/") && r.Path != "*" {
+		return fmt.Errorf("path %q must start with / or be *", r.Path)
+	}
+	if len(r.Allow) == 0 && len(r.Deny) == 0 {
+		return fmt.Errorf("rule for %s has neither allow nor deny", r.Path)
+	}
+	var err error
+	if r.allow, err = ParseNetworks(r.Allow); err != nil {
+		return fmt.Errorf("rule for %s: %w", r.Path, err)
+	}
+	if r.deny, err = ParseNetworks(r.Deny); err != nil {
+		return fmt.Errorf("rule for %s: %w", r.Path, err)
+	}
+	return nil
+}
+
+// specificity orders rules so the longest path wins, and an exact path wins
+// over a prefix of the same length
+func (r *Rule) specificity() int {
+	n := 2 * len(strings.TrimSuffix(r.Path, "*"))
+	if !strings.HasSuffix(r.Path, "*") {
+		n++
+	}
+	return n
+}
+
+// ParseNetworks parses IP addresses and CIDR ranges. A bare address is a
+// single-host range.
+func ParseNetworks(entries []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		cidr := entry
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP or CIDR %q", entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func contains(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// RuleStatus is a rule with the number of requests it has blocked since
+// startup
+type RuleStatus struct {
+	Rule
+	Blocked int64 `json:"blocked"`
+}
+
+// Filter holds the IP rules, which can be replaced at runtime. Rules are
+// written to a JSON file so they survive restarts; an empty file name keeps
+// them in memory.
+type Filter struct {
+	file string
+	keys *atrest.Keyring
+
+	mu      sync.RWMutex
+	rules   []*Rule
+	blocked map[string]int64
+}
+
+// Open loads the rules from file, which need not exist yet, and encrypts
+// them with keys when they are saved
+func Open(file string, keys *atrest.Keyring) (*Filter, error) {
+	f := &Filter{file: file, keys: keys, blocked: make(map[string]int64)}
+	if file == "" {
+		return f, nil
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err == nil {
+		data, err = keys.Open(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st struct {
+		Rules []Rule `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("invalid IP filter file: %w", err)
+	}
+	rules, err := Compile(st.Rules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP filter file: %w", err)
+	}
+	f.rules = rules
+	return f, nil
+}
+
+// Compile validates rules and parses their networks. Paths must be unique.
+func Compile(rules []Rule) ([]*Rule, error) {
+	seen := make(map[string]bool)
+	out := make([]*Rule, 0, len(rules))
+	for i := range rules {
+		r := rules[i]
+		if seen[r.Path] {
+			return nil, fmt.Errorf("duplicate rule for %s", r.Path)
+		}
+		seen[r.Path] = true
+		if err := r.compile(); err != nil {
+			return nil, err
+		}
+		out = append(out, &r)
+	}
+	return out, nil
+}
+
+// Check finds the most specific rule for a path and reports whether it
+// blocks ip, and why. Requests no rule matches are never blocked. A client
+// without a parseable IP, such as one on a Unix socket, is blocked by any
+// allow list.
+func Check(rules []*Rule, path string, ip net.IP) (rule *Rule, reason string) {
+	for _, r := range rules {
+		if r.matches(path) && (rule == nil || r.specificity() > rule.specificity()) {
+			rule = r
+		}
+	}
+	if rule == nil {
+		return nil, ""
+	}
+	if ip != nil && contains(rule.deny, ip) {
+		return rule, Denied
+	}
+	if len(rule.allow) > 0 && (ip == nil || !contains(rule.allow, ip)) {
+		return rule, NotAllowed
+	}
+	return rule, ""
+}
+
+// Check reports the rule that blocks a request, and why, or an empty
+// reason if the request is allowed. Blocks are counted per rule.
+func (f *Filter) Check(path string, ip net.IP) (string, string) {
+	f.mu.RLock()
+	rule, reason := Check(f.rules, path, ip)
+	f.mu.RUnlock()
+	if reason == "" {
+		return "", ""
+	}
+	f.mu.Lock()
+	f.blocked[rule.Path]++
+	f.mu.Unlock()
+	return rule.Path, reason
+}
+
+// Rules returns the rules and their block counts
+func (f *Filter) Rules() []RuleStatus {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	out := make([]RuleStatus, 0, len(f.rules))
+	for _, r := range f.rules {
+		out = append(out, RuleStatus{Rule: *r, Blocked: f.blocked[r.Path]})
+	}
+	return out
+}
+
+// Set replaces the rules, compiled by Compile
+func (f *Filter) Set(rules []*Rule) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file != "" {
+		plain := make([]Rule, 0, len(rules))
+		for _, r := range rules {
+			plain = append(plain, *r)
+		}
+		data, err := json.MarshalIndent(struct {
+			Rules []Rule `json:"rules"`
+		}{plain}, "", "  ")
+		if err == nil {
+			data, err = f.keys.Seal(data)
+		}
+		if err != nil {
+			return err
+		}
+		// Write atomically so a crash never leaves a truncated file
+		tmp, err := os.CreateTemp(filepath.Dir(f.file), ".ipfilter-*.json")
+		if err != nil {
+			return fmt.Errorf("failed to save IP filter: %w", err)
+		}
+		_, werr := tmp.Write(data)
+		cerr := tmp.Close()
+		if werr == nil {
+			werr = cerr
+		}
+		if werr == nil {
+			werr = os.Rename(tmp.Name(), f.file)
+		}
+		if werr != nil {
+			os.Remove(tmp.Name())
+			return fmt.Errorf("failed to save IP filter: %w", werr)
+		}
+	}
+	f.rules = rules
+	return nil
+}
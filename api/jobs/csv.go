@@ -0,0 +1,81 @@
+package jobs
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// WriteCSV writes job results as CSV with one line per row. Columns are
+// row and error followed by every input field as "input.<name>" and every
+// output field as "output.<name>", in sorted order; fields missing from a
+// row are left empty and nested values are written as JSON.
+func WriteCSV(w io.Writer, results []Result) error {
+	inputs := columns(results, func(r Result) map[string]interface{} { return r.Input })
+	outputs := columns(results, func(r Result) map[string]interface{} { return r.Output })
+
+	header := []string{"row", "error"}
+	for _, name := range inputs {
+		header = append(header, "input."+name)
+	}
+	for _, name := range outputs {
+		header = append(header, "output."+name)
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, r := range results {
+		record := []string{fmt.Sprint(r.Row), r.Error}
+		for _, name := range inputs {
+			record = append(record, CSVValue(r.Input[name]))
+		}
+		for _, name := range outputs {
+			record = append(record, CSVValue(r.Output[name]))
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// CSVValue formats a JSON value for a CSV cell
+func CSVValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+		return string(data)
+	}
+	return fmt.Sprint(v)
+}
+
+// columns returns the sorted union of the keys selected from each result
+func columns(results []Result, fields func(Result) map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, r := range results {
+		for name := range fields(r) {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
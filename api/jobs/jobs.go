@@ -0,0 +1,368 @@
+package jobs
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Job statuses
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+// maxReportedErrors caps the row errors kept on a job
+const maxReportedErrors = 100
+
+// Owner identifies who submitted a job
+type Owner struct {
+	Tenant string
+	APIKey string
+}
+
+// PredictFunc predicts a single row of a job submitted by owner with the
+// named model
+type PredictFunc func(owner Owner, model string, input map[string]interface{}) (map[string]interface{}, error)
+
+// ExportFunc writes the results of a finished job to its destination and
+// returns the URL of the written object
+type ExportFunc func(job Job, results []Result) (string, error)
+
+// NotifyFunc is called once a job has finished. Its result is recorded on
+// jobs with a NotifyEmail as NotifiedAt or NotifyError.
+type NotifyFunc func(job Job) error
+
+// RowError describes a row that failed to predict
+type RowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// Result is the outcome of a single row
+type Result struct {
+	Row    int                    `json:"row"`
+	Input  map[string]interface{} `json:"input"`
+	Output map[string]interface{} `json:"output,omitempty"`
+	Error  string                 `json:"error,omitempty"`
+}
+
+// Job is an asynchronous batch of predictions
+type Job struct {
+	ID          string     `json:"id"`
+	Model       string     `json:"model"`
+	Status      string     `json:"status"`
+	Total       int        `json:"total"`
+	Processed   int        `json:"processed"`
+	Failed      int        `json:"failed"`
+	Errors      []RowError `json:"errors,omitempty"`
+	Destination string     `json:"destination,omitempty"`
+	ResultURL   string     `json:"result_url,omitempty"`
+	ExportError string     `json:"export_error,omitempty"`
+	NotifyEmail string     `json:"notify_email,omitempty"`
+	NotifiedAt  *time.Time `json:"notified_at,omitempty"`
+	NotifyError string     `json:"notify_error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+
+	owner   Owner
+	rows    []map[string]interface{}
+	results []Result
+}
+
+// Event is a progress update published while a job runs
+type Event struct {
+	JobID      string     `json:"job_id"`
+	Status     string     `json:"status"`
+	Total      int        `json:"total"`
+	Processed  int        `json:"processed"`
+	Failed     int        `json:"failed"`
+	ETASeconds *float64   `json:"eta_seconds,omitempty"`
+	Errors     []RowError `json:"errors,omitempty"`
+	ResultURL  string     `json:"result_url,omitempty"`
+}
+
+// Done reports whether the event is the final event for its job
+func (e Event) Done() bool {
+	return e.Status == StatusCompleted || e.Status == StatusFailed
+}
+
+// Manager runs jobs in the background and tracks their progress
+type Manager struct {
+	predict     PredictFunc
+	export      ExportFunc
+	notify      NotifyFunc
+	concurrency int
+	retention   time.Duration
+
+	mu          sync.RWMutex
+	jobs        map[string]*Job
+	subscribers map[string]map[chan Event]bool
+	seq         uint64
+}
+
+// NewManager creates a manager that predicts rows with predict, writes the
+// results of jobs with a destination using export and reports finished jobs
+// to notify, running up to concurrency rows of
+// a job in parallel and forgetting finished jobs after retention
+func NewManager(predict PredictFunc, export ExportFunc, notify NotifyFunc, concurrency int, retention time.Duration) *Manager {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Manager{
+		predict:     predict,
+		export:      export,
+		notify:      notify,
+		concurrency: concurrency,
+		retention:   retention,
+		jobs:        make(map[string]*Job),
+		subscribers: make(map[string]map[chan Event]bool),
+	}
+}
+
+// Submit queues a job and starts it in the background. If destination is
+// set, results are exported there before the job completes; if notifyEmail
+// is set, it is notified once the job has finished. Rows are predicted on
+// behalf of owner.
+func (m *Manager) Submit(owner Owner, model string, rows []map[string]interface{}, destination, notifyEmail string) Job {
+	m.mu.Lock()
+	m.expire()
+	m.seq++
+	now := time.Now()
+	job := &Job{
+		ID:          fmt.Sprintf("job-%d-%d", now.Unix(), m.seq),
+		Model:       model,
+		Status:      StatusQueued,
+		Total:       len(rows),
+		Destination: destination,
+		NotifyEmail: notifyEmail,
+		CreatedAt:   now,
+		owner:       owner,
+		rows:        rows,
+		results:     make([]Result, len(rows)),
+	}
+	m.jobs[job.ID] = job
+	snapshot := job.snapshot()
+	m.mu.Unlock()
+
+	go m.run(job)
+	return snapshot
+}
+
+// Get returns a snapshot of a job
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return job.snapshot(), true
+}
+
+// Results returns the per-row results of a finished job
+func (m *Manager) Results(id string) ([]Result, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	job, ok := m.jobs[id]
+	if !ok || (job.Status != StatusCompleted && job.Status != StatusFailed) {
+		return nil, false
+	}
+	return job.results, true
+}
+
+// Subscribe returns a channel receiving progress events for a job, starting
+// with its current state. The channel is closed after the final event or
+// when cancel is called.
+func (m *Manager) Subscribe(id string) (<-chan Event, func(), bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, nil, false
+	}
+
+	ch := make(chan Event, 16)
+	current := job.event(nil)
+	ch <- current
+	if current.Done() {
+		close(ch)
+		return ch, func() {}, true
+	}
+
+	if m.subscribers[id] == nil {
+		m.subscribers[id] = make(map[chan Event]bool)
+	}
+	m.subscribers[id][ch] = true
+
+	cancel := func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.subscribers[id][ch] {
+			delete(m.subscribers[id], ch)
+			close(ch)
+		}
+	}
+	return ch, cancel, true
+}
+
+// run predicts every row of a job, publishing progress as rows complete
+func (m *Manager) run(job *Job) {
+	m.mu.Lock()
+	started := time.Now()
+	job.Status = StatusRunning
+	job.StartedAt = &started
+	m.publish(job, nil)
+	m.mu.Unlock()
+
+	sem := make(chan struct{}, m.concurrency)
+	var wg sync.WaitGroup
+	for i, row := range job.rows {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, row map[string]interface{}) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			output, err := m.predict(job.owner, job.Model, row)
+			result := Result{Row: i, Input: row, Output: output}
+
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			var rowErr *RowError
+			if err != nil {
+				result.Error = err.Error()
+				job.Failed++
+				rowErr = &RowError{Row: i, Error: err.Error()}
+				if len(job.Errors) < maxReportedErrors {
+					job.Errors = append(job.Errors, *rowErr)
+				}
+			}
+			job.results[i] = result
+			job.Processed++
+			m.publish(job, rowErr)
+		}(i, row)
+	}
+	wg.Wait()
+
+	// Export results before reporting completion so the URL is available
+	// when clients see the final status
+	var resultURL, exportErr string
+	if job.Destination != "" && m.export != nil {
+		m.mu.RLock()
+		snapshot := job.snapshot()
+		m.mu.RUnlock()
+		if url, err := m.export(snapshot, job.results); err != nil {
+			exportErr = err.Error()
+		} else {
+			resultURL = url
+		}
+	}
+
+	m.mu.Lock()
+	job.ResultURL = resultURL
+	job.ExportError = exportErr
+	completed := time.Now()
+	job.CompletedAt = &completed
+	job.Status = StatusCompleted
+	if job.Total > 0 && job.Failed == job.Total {
+		job.Status = StatusFailed
+	}
+	job.rows = nil
+	m.publish(job, nil)
+	snapshot := job.snapshot()
+	m.mu.Unlock()
+
+	// Notify after the final status is visible so the message never
+	// arrives before the job reads as finished
+	if m.notify == nil {
+		return
+	}
+	err := m.notify(snapshot)
+	if job.NotifyEmail == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		job.NotifyError = err.Error()
+		return
+	}
+	notified := time.Now()
+	job.NotifiedAt = &notified
+}
+
+// publish sends a progress event to the job's subscribers, dropping it for
+// subscribers that are not keeping up. The caller must hold m.mu.
+func (m *Manager) publish(job *Job, rowErr *RowError) {
+	ev := job.event(rowErr)
+	for ch := range m.subscribers[job.ID] {
+		if ev.Done() {
+			// The final event must be delivered, so make room for it
+			select {
+			case ch <- ev:
+			default:
+				<-ch
+				ch <- ev
+			}
+			close(ch)
+			continue
+		}
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+	if ev.Done() {
+		delete(m.subscribers, job.ID)
+	}
+}
+
+// expire forgets finished jobs older than the retention period. The caller must hold m.mu.
+func (m *Manager) expire() {
+	if m.retention <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-m.retention)
+	for id, job := range m.jobs {
+		if job.CompletedAt != nil && job.CompletedAt.Before(cutoff) {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// snapshot copies the exported job state
+func (j *Job) snapshot() Job {
+	s := *j
+	s.Errors = append([]RowError(nil), j.Errors...)
+	s.rows = nil
+	s.results = nil
+	return s
+}
+
+// event builds a progress event for the job's current state
+func (j *Job) event(rowErr *RowError) Event {
+	ev := Event{
+		JobID:     j.ID,
+		Status:    j.Status,
+		Total:     j.Total,
+		Processed: j.Processed,
+		Failed:    j.Failed,
+		ResultURL: j.ResultURL,
+	}
+	if rowErr != nil {
+		ev.Errors = []RowError{*rowErr}
+	}
+	if j.Status == StatusRunning && j.StartedAt != nil && j.Processed > 0 {
+		perRow := time.Since(*j.StartedAt).Seconds() / float64(j.Processed)
+		eta := perRow * float64(j.Total-j.Processed)
+		ev.ETASeconds = &eta
+	}
+	return ev
+}
@@ -0,0 +1,106 @@
+package loadtest
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+
+	"cloud-ai-api/plausibility"
+)
+
+// Generator produces the payload of one randomized prediction request
+type Generator func(rng *rand.Rand) map[string]interface{}
+
+// weighted picks values in proportion to their weights
+type weighted struct {
+	values []string
+	cumul  []float64
+}
+
+func newWeighted(weights map[string]float64) weighted {
+	var w weighted
+	for v := range weights {
+		w.values = append(w.values, v)
+	}
+	sort.Strings(w.values)
+	total := 0.0
+	for _, v := range w.values {
+		total += weights[v]
+		w.cumul = append(w.cumul, total)
+	}
+	return w
+}
+
+func (w weighted) pick(rng *rand.Rand) string {
+	x := rng.Float64() * w.cumul[len(w.cumul)-1]
+	i := sort.SearchFloat64s(w.cumul, x)
+	if i == len(w.values) {
+		i--
+	}
+	return w.values[i]
+}
+
+// Shares of property types, new builds and tenures in the price paid data
+var (
+	propertyTypes = newWeighted(map[string]float64{"D": 0.23, "S": 0.28, "T": 0.30, "F": 0.18, "O": 0.01})
+	newBuilds     = newWeighted(map[string]float64{"N": 0.90, "Y": 0.10})
+	tenures       = newWeighted(map[string]float64{"F": 0.77, "L": 0.229, "U": 0.001})
+)
+
+// fallbackCounties are used when the plausibility metadata lists none
+var fallbackCounties = map[string]float64{"GREATER LONDON": 1, "GREATER MANCHESTER": 1, "WEST MIDLANDS": 1, "KENT": 1, "ESSEX": 1}
+
+// Housing returns a generator of housing requests resembling production
+// traffic: counties in proportion to their training samples in rules,
+// property types and tenures in their usual shares, and dates spread over
+// the training horizon with a few beyond it
+func Housing(rules *plausibility.Rules) Generator {
+	counties := fallbackCounties
+	earliest, latest := 1995, 2017
+	if rules != nil && rules.Models["housing"] != nil {
+		m := rules.Models["housing"]
+		for _, cov := range m.Coverage {
+			if cov.Field != "county" || len(cov.Samples) == 0 {
+				continue
+			}
+			counties = make(map[string]float64, len(cov.Samples))
+			for county, n := range cov.Samples {
+				counties[county] = float64(n)
+			}
+		}
+		if h := m.Horizon; h != nil {
+			if y, ok := yearOf(h.Earliest); ok {
+				earliest = y
+			}
+			if y, ok := yearOf(h.Latest); ok {
+				latest = y
+			}
+		}
+	}
+	countyPicker := newWeighted(counties)
+
+	return func(rng *rand.Rand) map[string]interface{} {
+		year := earliest + rng.Intn(latest-earliest+1)
+		// One request in twenty asks about a later year, as forecasts do
+		if rng.Intn(20) == 0 {
+			year = latest + 1 + rng.Intn(8)
+		}
+		return map[string]interface{}{
+			"property_type": propertyTypes.pick(rng),
+			"is_new":        newBuilds.pick(rng),
+			"duration":      tenures.pick(rng),
+			"county":        countyPicker.pick(rng),
+			"year":          year,
+			"month":         1 + rng.Intn(12),
+		}
+	}
+}
+
+// yearOf reads the year of a YYYY-MM month
+func yearOf(month string) (int, bool) {
+	t, err := time.Parse("2006-01", month)
+	if err != nil {
+		return 0, false
+	}
+	return t.Year(), true
+}
@@ -0,0 +1,229 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud-ai-api/stats"
+)
+
+// Options configures a load test
+type Options struct {
+	// Target is the base URL of the gateway under test
+	Target   string
+	Model    string
+	RPS      float64
+	Duration time.Duration
+	// Concurrency caps requests in flight; requests due while it is reached
+	// are dropped rather than delayed, so the offered rate stays fixed
+	Concurrency int
+	Timeout     time.Duration
+	APIKey      string
+	// Progress, if set, is called every ProgressInterval with the results
+	// so far
+	Progress         func(Report)
+	ProgressInterval time.Duration
+}
+
+// Report summarizes the requests sent during a load test
+type Report struct {
+	Target      string         `json:"target"`
+	Model       string         `json:"model"`
+	TargetRPS   float64        `json:"target_rps"`
+	AchievedRPS float64        `json:"achieved_rps"`
+	DurationS   float64        `json:"duration_s"`
+	Sent        int            `json:"sent"`
+	Succeeded   int            `json:"succeeded"`
+	Failed      int            `json:"failed"`
+	Dropped     int            `json:"dropped"`
+	StatusCodes map[string]int `json:"status_codes"`
+	P50Ms       float64        `json:"p50_ms"`
+	P90Ms       float64        `json:"p90_ms"`
+	P95Ms       float64        `json:"p95_ms"`
+	P99Ms       float64        `json:"p99_ms"`
+	MaxMs       float64        `json:"max_ms"`
+	Errors      []string       `json:"errors,omitempty"`
+}
+
+// maxReportedErrors caps the distinct errors kept on a report
+const maxReportedErrors = 10
+
+// Run sends requests made by gen to the target's prediction route at a
+// fixed rate until the duration has passed or ctx is done, then waits for
+// requests in flight and reports their latencies
+func Run(ctx context.Context, opts Options, gen Generator) Report {
+	client := &http.Client{
+		Timeout: opts.Timeout,
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			MaxIdleConnsPerHost: opts.Concurrency,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+	url := strings.TrimSuffix(opts.Target, "/") + "/api/v1/predict/" + opts.Model
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	r := &recorder{report: Report{
+		Target:      opts.Target,
+		Model:       opts.Model,
+		TargetRPS:   opts.RPS,
+		StatusCodes: make(map[string]int),
+	}}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.RPS))
+	defer ticker.Stop()
+	var progress <-chan time.Time
+	if opts.Progress != nil && opts.ProgressInterval > 0 {
+		t := time.NewTicker(opts.ProgressInterval)
+		defer t.Stop()
+		progress = t.C
+	}
+
+	start := time.Now()
+	slots := make(chan struct{}, opts.Concurrency)
+	var wg sync.WaitGroup
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-progress:
+			opts.Progress(r.snapshot(time.Since(start)))
+		case <-ticker.C:
+			select {
+			case slots <- struct{}{}:
+			default:
+				r.drop()
+				continue
+			}
+			body, _ := json.Marshal(gen(rng))
+			wg.Add(1)
+			go func() {
+				defer func() { <-slots; wg.Done() }()
+				status, latency, err := send(client, url, opts.APIKey, body)
+				r.observe(status, latency, err)
+			}()
+		}
+	}
+	wg.Wait()
+	return r.snapshot(time.Since(start))
+}
+
+// send posts one request and returns the response status and latency
+func send(client *http.Client, url, apiKey string, body []byte) (int, time.Duration, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, time.Since(start), err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp.StatusCode, time.Since(start), nil
+}
+
+// recorder collects request outcomes from concurrent senders
+type recorder struct {
+	mu        sync.Mutex
+	report    Report
+	latencies []float64
+}
+
+func (r *recorder) drop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.report.Dropped++
+}
+
+func (r *recorder) observe(status int, latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.report.Sent++
+	r.latencies = append(r.latencies, float64(latency.Microseconds())/1000)
+	switch {
+	case err != nil:
+		r.report.Failed++
+		r.report.StatusCodes["error"]++
+		msg := err.Error()
+		for _, seen := range r.report.Errors {
+			if seen == msg {
+				return
+			}
+		}
+		if len(r.report.Errors) < maxReportedErrors {
+			r.report.Errors = append(r.report.Errors, msg)
+		}
+	case status >= 200 && status < 300:
+		r.report.Succeeded++
+		r.report.StatusCodes[strconv.Itoa(status)]++
+	default:
+		r.report.Failed++
+		r.report.StatusCodes[strconv.Itoa(status)]++
+	}
+}
+
+// snapshot returns the report so far, with percentiles computed over the
+// requests completed within elapsed
+func (r *recorder) snapshot(elapsed time.Duration) Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := r.report
+	out.StatusCodes = make(map[string]int, len(r.report.StatusCodes))
+	for code, n := range r.report.StatusCodes {
+		out.StatusCodes[code] = n
+	}
+	out.Errors = append([]string(nil), r.report.Errors...)
+	out.DurationS = elapsed.Seconds()
+	if out.DurationS > 0 {
+		out.AchievedRPS = float64(out.Sent) / out.DurationS
+	}
+	if len(r.latencies) > 0 {
+		sorted := append([]float64(nil), r.latencies...)
+		sort.Float64s(sorted)
+		out.P50Ms = stats.Percentile(sorted, 0.50)
+		out.P90Ms = stats.Percentile(sorted, 0.90)
+		out.P95Ms = stats.Percentile(sorted, 0.95)
+		out.P99Ms = stats.Percentile(sorted, 0.99)
+		out.MaxMs = sorted[len(sorted)-1]
+	}
+	return out
+}
+
+// WriteText writes a report for people to read
+func WriteText(w io.Writer, rep Report) {
+	fmt.Fprintf(w, "Target:       %s (model %s)\n", rep.Target, rep.Model)
+	fmt.Fprintf(w, "Duration:     %.1fs\n", rep.DurationS)
+	fmt.Fprintf(w, "Rate:         %.1f req/s achieved of %.1f offered\n", rep.AchievedRPS, rep.TargetRPS)
+	fmt.Fprintf(w, "Requests:     %d sent, %d succeeded, %d failed, %d dropped\n", rep.Sent, rep.Succeeded, rep.Failed, rep.Dropped)
+	codes := make([]string, 0, len(rep.StatusCodes))
+	for code := range rep.StatusCodes {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for i, code := range codes {
+		codes[i] = fmt.Sprintf("%s=%d", code, rep.StatusCodes[code])
+	}
+	fmt.Fprintf(w, "Status codes: %s\n", strings.Join(codes, " "))
+	fmt.Fprintf(w, "Latency (ms): p50=%.1f p90=%.1f p95=%.1f p99=%.1f max=%.1f\n", rep.P50Ms, rep.P90Ms, rep.P95Ms, rep.P99Ms, rep.MaxMs)
+	for _, e := range rep.Errors {
+		fmt.Fprintf(w, "Error:        %s\n", e)
+	}
+}
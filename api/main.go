@@ -1,91 +1,1886 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
-	"github.com/gin-gonic/gin"
+	"cloud-ai-api/accounts"
+	"cloud-ai-api/admission"
+	"cloud-ai-api/alerts"
+	"cloud-ai-api/anomaly"
+	"cloud-ai-api/atrest"
+	"cloud-ai-api/buildinfo"
+	"cloud-ai-api/cache"
+	"cloud-ai-api/catalog"
+	"cloud-ai-api/client"
+	"cloud-ai-api/config"
+	"cloud-ai-api/demo"
+	"cloud-ai-api/discovery"
+	"cloud-ai-api/drift"
+	"cloud-ai-api/dynconfig"
+	"cloud-ai-api/ensemble"
+	"cloud-ai-api/errorreport"
+	"cloud-ai-api/events"
+	"cloud-ai-api/flags"
 	"cloud-ai-api/handlers"
+	"cloud-ai-api/health"
+	"cloud-ai-api/history"
+	"cloud-ai-api/hooks"
+	"cloud-ai-api/i18n"
+	"cloud-ai-api/ipfilter"
+	"cloud-ai-api/jobs"
+	"cloud-ai-api/loadtest"
+	"cloud-ai-api/mailer"
+	"cloud-ai-api/metrics"
 	"cloud-ai-api/middleware"
+	"cloud-ai-api/mlcontract"
+	"cloud-ai-api/mlroute"
+	"cloud-ai-api/mltransport"
+	"cloud-ai-api/models"
+	"cloud-ai-api/notify"
+	"cloud-ai-api/objectstore"
+	"cloud-ai-api/oidc"
+	"cloud-ai-api/plausibility"
+	"cloud-ai-api/queue"
+	"cloud-ai-api/registry"
+	"cloud-ai-api/routes"
+	"cloud-ai-api/sanity"
+	"cloud-ai-api/secrets"
+	"cloud-ai-api/signing"
+	"cloud-ai-api/slo"
+	"cloud-ai-api/stats"
+	"cloud-ai-api/upgrade"
+	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	// Get port from environment or use default
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// Run a subcommand instead of serving; without one, or with "serve",
+	// the gateway serves
+	if len(os.Args) > 1 && os.Args[1] != "serve" {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
+	// Replace secret references (vault:... or aws-sm:...) in the environment
+	// with the secrets before reading the configuration
+	secretStores := secrets.FromEnv()
+	resolveCtx, cancelResolve := context.WithTimeout(context.Background(), 30*time.Second)
+	name, err := secretStores.Resolve(resolveCtx)
+	cancelResolve()
+	if err != nil {
+		reportConfigProblems([]config.Problem{{Var: name, Message: err.Error()}})
+		os.Exit(1)
+	}
+
+	// Read and validate configuration, reporting every problem at once
+	cfg, problems := config.Load()
+	secretStores.Restore()
+
+	handlers.MLServiceURL = cfg.MLServiceURL
+	handlers.Features = cfg.Features()
+	handlers.EffectiveConfig = cfg.Redacted()
+	handlers.MLClient.Timeout = cfg.MLTimeout
+	handlers.MaxMLResponseBytes = int64(cfg.MLMaxBody)
+	handlers.SlowMLThreshold = cfg.SlowML
+	handlers.MLQueueTimeout = cfg.MLQueueTimeout
+	for _, entry := range cfg.PriorityKeys {
+		key, tier, _ := strings.Cut(entry, ":")
+		handlers.PriorityTiers[key], _ = admission.ParsePriority(tier)
+	}
+	if cfg.MLMaxConcurrent > 0 {
+		handlers.MLGate = admission.NewGate(cfg.MLMaxConcurrent, cfg.MLStarvation)
+	}
+	handlers.Health = health.NewMonitor(cfg.HealthCheck, 3*time.Second)
+
+	// State files and captures are encrypted when keys are configured
+	if len(cfg.StorageKeys) > 0 {
+		handlers.StorageKeys, err = atrest.ParseKeyring(cfg.StorageKeys)
+		if err != nil {
+			problems = append(problems, config.Problem{Var: "STORAGE_ENCRYPTION_KEYS", Message: err.Error()})
+		}
+	}
+
+	// Load model registry from file if configured, otherwise use built-in models
+	if cfg.RegistryFile != "" {
+		reg, err := registry.Load(cfg.RegistryFile)
+		if err != nil {
+			problems = append(problems, config.Problem{Var: "MODEL_REGISTRY_FILE", Message: err.Error()})
+		} else {
+			handlers.Registry = reg
+			handlers.RegistryLoaded = time.Now()
+		}
+	}
+	if cfg.CatalogFile != "" {
+		cat, err := catalog.Open(cfg.CatalogFile, handlers.StorageKeys)
+		if err != nil {
+			problems = append(problems, config.Problem{Var: "MODEL_CATALOG_FILE", Message: err.Error()})
+		} else {
+			handlers.ModelCatalog = cat
+		}
+	}
+
+	// Load plausibility metadata from file if configured, otherwise use the
+	// bundled metadata
+	switch {
+	case cfg.PlausibilityMode == "off":
+		handlers.Plausibility = nil
+	case cfg.PlausibilityFile != "":
+		rules, err := plausibility.Load(cfg.PlausibilityFile)
+		if err != nil {
+			problems = append(problems, config.Problem{Var: "PLAUSIBILITY_FILE", Message: err.Error()})
+		} else {
+			handlers.Plausibility = rules
+		}
+	}
+	handlers.RejectImplausible = cfg.PlausibilityMode == "reject"
+
+	// Load prediction sanity bounds from file if configured, otherwise use
+	// the bundled bounds
+	switch {
+	case cfg.SanityMode == "off":
+		handlers.SanityBounds = nil
+	case cfg.SanityFile != "":
+		bounds, err := sanity.Load(cfg.SanityFile)
+		if err != nil {
+			problems = append(problems, config.Problem{Var: "SANITY_BOUNDS_FILE", Message: err.Error()})
+		} else {
+			handlers.SanityBounds = bounds
+		}
+	}
+	handlers.RejectOutOfRange = cfg.SanityMode == "reject"
+
+	if cfg.MLContractFile != "" {
+		suite, err := mlcontract.Load(cfg.MLContractFile)
+		if err != nil {
+			problems = append(problems, config.Problem{Var: "ML_CONTRACT_FILE", Message: err.Error()})
+		} else {
+			handlers.MLContract = suite
+		}
+	}
+
+	if cfg.MessagesFile != "" {
+		catalog, err := i18n.Load(cfg.MessagesFile)
+		if err != nil {
+			problems = append(problems, config.Problem{Var: "MESSAGE_CATALOG_FILE", Message: err.Error()})
+		} else {
+			handlers.Messages = catalog
+		}
+	}
+	// Every catalog language should translate every error message the
+	// handlers write; i18n.Messages is regenerated by go generate ./i18n
+	untranslated := handlers.Messages.Untranslated(i18n.Messages)
+	langs := make([]string, 0, len(untranslated))
+	for lang := range untranslated {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		log.Printf("WARN i18n_untranslated lang=%s count=%d messages=%q", lang, len(untranslated[lang]), untranslated[lang])
+	}
+
+	if cfg.EnsembleFile != "" {
+		ensembles, err := ensemble.Load(cfg.EnsembleFile)
+		if err == nil {
+			err = handlers.CheckEnsembles(ensembles)
+		}
+		if err == nil && cfg.MLSocket != "" {
+			for name, e := range ensembles {
+				for _, m := range e.Members {
+					if m.URL != "" {
+						err = fmt.Errorf("ensemble %q: member %q: url cannot be used with ML_SERVICE_SOCKET", name, m.Name)
+					}
+				}
+			}
+		}
+		if err != nil {
+			problems = append(problems, config.Problem{Var: "ENSEMBLE_FILE", Message: err.Error()})
+		}
+		handlers.Ensembles = ensembles
+	}
+
+	if cfg.MLRoutesFile != "" {
+		table, err := mlroute.Load(cfg.MLRoutesFile)
+		if err == nil {
+			err = handlers.CheckMLRoutes(table)
+		}
+		if err == nil && cfg.MLSocket != "" && table.HasURL() {
+			err = fmt.Errorf("url cannot be used with ML_SERVICE_SOCKET")
+		}
+		if err != nil {
+			problems = append(problems, config.Problem{Var: "ML_ROUTES_FILE", Message: err.Error()})
+		}
+		handlers.MLRoutes = table
+	}
+
+	if cfg.MLRegionsFile != "" {
+		regions, err := mlroute.LoadRegions(cfg.MLRegionsFile)
+		if err != nil {
+			problems = append(problems, config.Problem{Var: "ML_REGIONS_FILE", Message: err.Error()})
+		} else {
+			handlers.MLRegions = regions
+			handlers.CountryHeader = cfg.CountryHeader
+			log.Printf("Routing predictions across ML regions %v (default %s)", regions.Names(), regions.Default)
+		}
+	}
+
+	if cfg.AlertRulesFile != "" {
+		rules, err := alerts.Load(cfg.AlertRulesFile)
+		if err == nil {
+			handlers.Alerts, err = alerts.NewEngine(rules, handlers.AlertMetrics, cfg.StatsWindow, handlers.MeasureAlert)
+		}
+		if err != nil {
+			problems = append(problems, config.Problem{Var: "ALERT_RULES_FILE", Message: err.Error()})
+		}
+	}
+
+	if cfg.SLOFile != "" {
+		objectives, err := slo.Load(cfg.SLOFile)
+		if err == nil {
+			handlers.SLO, err = slo.NewTracker(objectives)
+		}
+		if err == nil && cfg.SLOStateFile != "" {
+			err = handlers.SLO.Restore(cfg.SLOStateFile, handlers.StorageKeys)
+		}
+		if err != nil {
+			problems = append(problems, config.Problem{Var: "SLO_FILE", Message: err.Error()})
+		}
+	}
+
+	handlers.CostCurrency = cfg.CostCurrency
+	handlers.DefaultCostPerCall = cfg.CostPerCall
+	if cfg.UsageStateFile != "" {
+		if err := handlers.Usage.Restore(cfg.UsageStateFile, handlers.StorageKeys); err != nil {
+			problems = append(problems, config.Problem{Var: "USAGE_STATE_FILE", Message: err.Error()})
+		}
+	}
+
+	// Load hook plugins (.so paths)
+	if len(cfg.HookPlugins) > 0 {
+		if err := hooks.LoadPlugins(cfg.HookPlugins); err != nil {
+			problems = append(problems, config.Problem{Var: "HOOK_PLUGINS", Message: err.Error()})
+		}
+	}
+
+	var deprecations []middleware.Deprecation
+	if cfg.DeprecationFile != "" {
+		rules, err := middleware.LoadDeprecations(cfg.DeprecationFile)
+		if err != nil {
+			problems = append(problems, config.Problem{Var: "DEPRECATION_FILE", Message: err.Error()})
+		}
+		deprecations = rules
+	}
+
+	var bulkheads *middleware.Bulkheads
+	if cfg.ConcurrencyFile != "" {
+		b, err := middleware.LoadConcurrencyLimits(cfg.ConcurrencyFile)
+		if err != nil {
+			problems = append(problems, config.Problem{Var: "CONCURRENCY_FILE", Message: err.Error()})
+		}
+		bulkheads = b
+	}
+
+	var fileFlags map[string]flags.Flag
+	if cfg.FlagsFile != "" {
+		loaded, err := flags.Load(cfg.FlagsFile)
+		if err == nil {
+			err = handlers.CheckFlags(loaded)
+		}
+		if err != nil {
+			problems = append(problems, config.Problem{Var: "FEATURE_FLAGS_FILE", Message: err.Error()})
+		}
+		fileFlags = loaded
 	}
 
-	// Get ML service URL from environment or use default
-	mlServiceURL := os.Getenv("ML_SERVICE_URL")
-	if mlServiceURL != "" {
-		handlers.MLServiceURL = mlServiceURL
+	var warmup []cache.WarmupSet
+	if cfg.WarmupFile != "" {
+		sets, err := cache.LoadWarmup(cfg.WarmupFile)
+		if err != nil {
+			problems = append(problems, config.Problem{Var: "WARMUP_FILE", Message: err.Error()})
+		}
+		warmup = sets
+	}
+
+	if cfg.SigningKeyFile != "" {
+		signer, err := signing.Load(cfg.SigningKeyFile, cfg.SigningKeyID)
+		if err != nil {
+			problems = append(problems, config.Problem{Var: "RESPONSE_SIGNING_KEY_FILE", Message: err.Error()})
+		}
+		handlers.ResponseSigner = signer
+	}
+
+	maintenanceAllow, err := middleware.ParseAllowlist(cfg.MaintenanceAllowIPs, cfg.MaintenanceAllowKeys)
+	if err != nil {
+		problems = append(problems, config.Problem{Var: "MAINTENANCE_ALLOW_IPS", Message: err.Error()})
+	}
+	if len(cfg.CORSOrigins) > 0 {
+		handlers.AllowedOrigins, err = middleware.ParseOrigins(cfg.CORSOrigins)
+		if err != nil {
+			problems = append(problems, config.Problem{Var: "CORS_ALLOWED_ORIGINS", Message: err.Error()})
+		}
+	}
+	abuseExempt, err := ipfilter.ParseNetworks(cfg.AbuseExemptIPs)
+	if err != nil {
+		problems = append(problems, config.Problem{Var: "ABUSE_EXEMPT_IPS", Message: err.Error()})
+	}
+	proxies, err := ipfilter.ParseNetworks(cfg.TrustedProxies)
+	if err != nil {
+		problems = append(problems, config.Problem{Var: "TRUSTED_PROXIES", Message: err.Error()})
+	}
+	for _, network := range proxies {
+		// Trusting every address would let any client choose its IP
+		if ones, _ := network.Mask.Size(); ones == 0 {
+			problems = append(problems, config.Problem{Var: "TRUSTED_PROXIES", Message: fmt.Sprintf("%s trusts every address; list only your proxies", network)})
+		}
+	}
+
+	for _, dest := range cfg.ExportDestinations {
+		if reported(problems, "EXPORT_DESTINATIONS") {
+			break
+		}
+		loc, err := objectstore.Parse(dest)
+		if err != nil {
+			problems = append(problems, config.Problem{Var: "EXPORT_DESTINATIONS", Message: err.Error()})
+			continue
+		}
+		handlers.ExportDestinations = append(handlers.ExportDestinations, loc)
+	}
+	if cfg.Accounts.File != "" && !reported(problems, "ACCOUNTS_FILE") {
+		store, err := accounts.Open(cfg.Accounts.File, accounts.Options{
+			DefaultQuota:  accounts.Quota{DailyRequests: cfg.Accounts.DailyQuota},
+			MaxKeys:       cfg.Accounts.MaxKeys,
+			VerifyTTL:     cfg.Accounts.VerifyTTL,
+			RotationGrace: cfg.Accounts.RotationGrace,
+			Keys:          handlers.StorageKeys,
+		})
+		if err != nil {
+			problems = append(problems, config.Problem{Var: "ACCOUNTS_FILE", Message: err.Error()})
+		}
+		handlers.Accounts = store
+	}
+	var statsd *metrics.StatsD
+	if cfg.StatsD.Addr != "" && !reported(problems, "STATSD_ADDR") {
+		statsd, err = metrics.NewStatsD(cfg.StatsD.Addr, cfg.StatsD.Prefix, cfg.StatsD.Tags, cfg.StatsD.DogStatsD)
+		if err != nil {
+			problems = append(problems, config.Problem{Var: "STATSD_ADDR", Message: err.Error()})
+		}
+	}
+	var sentry *errorreport.Sentry
+	if cfg.Sentry.DSN != "" && !reported(problems, "SENTRY_DSN") {
+		sentry, err = errorreport.NewSentry(errorreport.SentryConfig{
+			DSN:         cfg.Sentry.DSN,
+			Environment: cfg.Sentry.Environment,
+			Release:     buildinfo.Version,
+			SampleRate:  cfg.Sentry.SampleRate,
+		})
+		if err != nil {
+			problems = append(problems, config.Problem{Var: "SENTRY_DSN", Message: err.Error()})
+		}
+	}
+
+	mlWatcher, err := newMLWatcher(cfg)
+	if err != nil {
+		problems = append(problems, config.Problem{Var: "ML_DISCOVERY", Message: err.Error()})
+	}
+
+	if len(problems) > 0 {
+		reportConfigProblems(problems)
+		os.Exit(1)
+	}
+
+	handlers.History = history.NewStore(cfg.HistorySize)
+	handlers.RequestStats = stats.NewRecorder(cfg.StatsWindow)
+	handlers.MLStats = stats.NewRecorder(cfg.StatsWindow)
+	handlers.RegionStats = stats.NewRecorder(cfg.StatsWindow)
+	handlers.DarkStats = stats.NewRecorder(cfg.StatsWindow)
+	handlers.MaxJobRows = cfg.JobMaxRows
+	handlers.MaxAppraisalProperties = cfg.AppraisalMax
+	handlers.MaxSchedulesPerOwner = cfg.ScheduleMax
+	handlers.CaptureDir = cfg.CaptureDir
+
+	// Score prediction inputs against recent traffic
+	if cfg.AnomalyWindow > 0 {
+		handlers.Anomalies = anomaly.NewDetector(anomaly.Options{
+			Window:      cfg.AnomalyWindow,
+			MinSamples:  cfg.AnomalyMinSamples,
+			BurstWindow: cfg.AnomalyBurstWindow,
+			BurstLimit:  cfg.AnomalyBurstLimit,
+		})
+		handlers.RejectAnomalyScore = cfg.AnomalyRejectScore
+	}
+
+	// Compare recent prediction inputs and outputs with the baseline period
+	if cfg.DriftWindow > 0 {
+		handlers.Drift = drift.NewMonitor(cfg.DriftWindow, cfg.DriftBaseline, drift.Thresholds{
+			PSI:        cfg.DriftPSI,
+			Shift:      cfg.DriftShift,
+			MinSamples: cfg.DriftMinSamples,
+		})
+		go handlers.Drift.Watch(context.Background(), func(model string, a drift.Alert) {
+			log.Printf("WARN drift_alert model=%s metric=%s value=%.4f threshold=%.4f", model, a.Metric, a.Value, a.Threshold)
+			handlers.Metrics.Incr("drift.alerts", "model:"+model, "metric:"+a.Metric)
+		}, func(model, metric string) {
+			log.Printf("drift_resolved model=%s metric=%s", model, metric)
+		})
+	}
+
+	// Feature flags: built-in defaults, then FEATURE_FLAGS_FILE
+	baseFlags := handlers.DefaultFlags()
+	for name, f := range fileFlags {
+		baseFlags[name] = f
+	}
+	handlers.Flags = flags.NewSet(baseFlags)
+
+	// Discover ML service instances instead of using ML_SERVICE_URL
+	if mlWatcher != nil {
+		handlers.MLBackends = discovery.NewPool()
+		discovery.Run(context.Background(), mlWatcher, handlers.MLBackends)
+		log.Printf("Discovering ML backends via %s: %s", cfg.Discovery.Mode, cfg.Discovery.Name)
+	}
+
+	// Read rate limits and ML backends from etcd/Consul, following changes
+	limiter := middleware.NewRateLimiter(middleware.RateLimit{RequestsPerSecond: cfg.RateLimitRPS, Burst: cfg.RateLimitBurst})
+	if cfg.Dynamic.Source != "" {
+		startDynamicConfig(cfg, limiter)
+	}
+
+	// Cache prediction responses and seed the cache with popular inputs
+	if cfg.ResponseCacheSize > 0 {
+		handlers.ResponseCache = cache.New(cfg.ResponseCacheSize, cfg.ResponseCacheTTL)
+	}
+	if cfg.FanOutCacheSize > 0 {
+		handlers.FanOutCache = cache.New(cfg.FanOutCacheSize, cfg.FanOutCacheTTL)
+	}
+
+	// Configure object storage for batch job result exports
+	if cfg.S3.AccessKey != "" {
+		handlers.ObjectStores["s3"] = objectstore.NewS3(objectstore.Config{
+			Endpoint:     cfg.S3.Endpoint,
+			Region:       cfg.S3.Region,
+			AccessKey:    cfg.S3.AccessKey,
+			SecretKey:    cfg.S3.SecretKey,
+			SessionToken: cfg.S3.SessionToken,
+		})
+	}
+	if cfg.GCS.AccessKey != "" {
+		handlers.ObjectStores["gs"] = objectstore.NewGCS(objectstore.Config{
+			AccessKey: cfg.GCS.AccessKey,
+			SecretKey: cfg.GCS.SecretKey,
+		})
+	}
+
+	// Email batch job owners who ask to be notified
+	if cfg.SMTP.Host != "" {
+		handlers.Mailer = mailer.New(mailer.Config{
+			Host:     cfg.SMTP.Host,
+			Port:     cfg.SMTP.Port,
+			Username: cfg.SMTP.Username,
+			Password: cfg.SMTP.Password,
+			From:     cfg.SMTP.From,
+			TLS:      cfg.SMTP.TLS,
+		})
+		handlers.NotifyEmailDomains = cfg.SMTP.NotifyDomains
+		handlers.NotifyEmailsPerAddress.SetLimit(middleware.RateLimit{
+			RequestsPerSecond: float64(cfg.SMTP.NotifyPerHour) / 3600,
+			Burst:             cfg.SMTP.NotifyPerHour,
+		})
+	}
+	handlers.PublicURL = cfg.PublicURL
+	handlers.FeedbackURL = cfg.FeedbackURL
+
+	// Let pilot users register and issue their own API keys
+	if handlers.Accounts != nil {
+		handlers.AccountVerifyTTL = cfg.Accounts.VerifyTTL
+		handlers.RegistrationsPerAddress.SetLimit(middleware.RateLimit{
+			RequestsPerSecond: float64(cfg.Accounts.RegistrationsPerAddress) / 3600,
+			Burst:             cfg.Accounts.RegistrationsPerAddress,
+		})
+		handlers.RegistrationsPerIP.SetLimit(middleware.RateLimit{
+			RequestsPerSecond: float64(cfg.Accounts.RegistrationsPerIP) / 3600,
+			Burst:             cfg.Accounts.RegistrationsPerIP,
+		})
+	}
+
+	// Post operational events to Slack/Teams, checked at the health check
+	// interval
+	if len(cfg.Notify.SlackWebhooks)+len(cfg.Notify.TeamsWebhooks) > 0 {
+		var webhooks []notify.Webhook
+		for _, u := range cfg.Notify.SlackWebhooks {
+			webhooks = append(webhooks, notify.Webhook{URL: u, Format: notify.Slack})
+		}
+		for _, u := range cfg.Notify.TeamsWebhooks {
+			webhooks = append(webhooks, notify.Webhook{URL: u, Format: notify.Teams})
+		}
+		handlers.Notifier = notify.New(webhooks, cfg.Notify.Events)
+		go handlers.WatchOperations(context.Background(), cfg.HealthCheck, handlers.OpsThresholds{
+			MLUnhealthyAfter: cfg.Notify.MLUnhealthyAfter,
+			ErrorRate:        cfg.Notify.ErrorRate,
+			MinRequests:      int64(cfg.Notify.MinRequests),
+		})
+	}
+
+	// Evaluate alert rules against the request and ML call statistics
+	if handlers.Alerts != nil {
+		go handlers.Alerts.Watch(context.Background(), cfg.AlertInterval, handlers.AlertChanged)
+	}
+
+	// Persist SLO counts and usage so monthly reports survive restarts
+	if (handlers.SLO != nil && cfg.SLOStateFile != "") || cfg.UsageStateFile != "" {
+		go func() {
+			for range time.Tick(time.Minute) {
+				saveState(cfg)
+			}
+		}()
+	}
+
+	// Re-fetch secrets, restarting onto changed ones if asked to
+	if vars := secretStores.Vars(); len(vars) > 0 {
+		log.Printf("Resolved secrets: vars=%s", strings.Join(vars, ","))
+		if cfg.Secrets.Refresh > 0 {
+			go secretStores.Watch(context.Background(), cfg.Secrets.Refresh, func(changed []string) {
+				log.Printf("WARN secrets_changed vars=%s reload=%t", strings.Join(changed, ","), cfg.Secrets.Reload)
+				handlers.Metrics.Incr("secrets.changed")
+				if cfg.Secrets.Reload {
+					syscall.Kill(os.Getpid(), syscall.SIGHUP)
+				}
+			})
+		}
+	}
+
+	// Publish prediction events to Kafka if configured
+	if len(cfg.KafkaBrokers) > 0 {
+		handlers.Events = events.NewKafkaPublisher(cfg.KafkaBrokers, cfg.KafkaTopic)
+		log.Printf("Publishing prediction events to Kafka topic %s", cfg.KafkaTopic)
+	}
+
+	// Push metrics to StatsD/DogStatsD if configured
+	if statsd != nil {
+		handlers.Metrics = statsd
+		log.Printf("Pushing metrics to StatsD at %s", cfg.StatsD.Addr)
+	}
+
+	// Tune connections to the ML service
+	handlers.MLClient.Transport = mltransport.New(mltransport.Options{
+		HTTP2:        cfg.MLHTTP2,
+		Socket:       cfg.MLSocket,
+		IdleTimeout:  cfg.MLIdleConn,
+		MaxIdleConns: cfg.MLMaxIdle,
+		MaxStreams:   cfg.MLMaxStreams,
+	}, handlers.Metrics)
+
+	// Report panics and server errors to Sentry if configured
+	if sentry != nil {
+		handlers.ErrorReporter = sentry
+		log.Printf("Reporting errors to Sentry (%s)", cfg.Sentry.Environment)
+	}
+
+	// Load routes disabled at runtime
+	sw, err := routes.Open(cfg.RouteStateFile, handlers.StorageKeys)
+	if err != nil {
+		log.Fatal("Failed to load route state: ", err)
+	}
+	handlers.Routes = sw
+
+	// Load the IP allow and deny rules
+	ipFilter, err := ipfilter.Open(cfg.IPFilterFile, handlers.StorageKeys)
+	if err != nil {
+		log.Fatal("Failed to load IP filter: ", err)
+	}
+	handlers.IPFilter = ipFilter
+
+	// Maintenance mode can be forced on from config
+	if cfg.Maintenance && !sw.Maintenance().Enabled {
+		if err := sw.SetMaintenance(routes.Maintenance{
+			Enabled: true,
+			Message: cfg.MaintenanceMessage,
+		}); err != nil {
+			log.Fatal("Failed to enable maintenance mode: ", err)
+		}
 	}
 
 	// Set Gin mode (release for production)
-	if os.Getenv("GIN_MODE") == "" {
+	if cfg.GinMode == "" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	// Create router
-	router := gin.Default()
+	// Hold readiness until canary predictions succeed
+	if cfg.SelfTest {
+		handlers.StartSelfTest(5 * time.Second)
+	}
+
+	// Create router, answering unknown routes and methods with JSON errors
+	router := gin.New()
+	trustProxies(router, cfg)
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(handlers.NotFoundHandler)
+	router.NoMethod(handlers.MethodNotAllowedHandler)
 
 	// Add middleware
-	router.Use(middleware.CORSMiddleware())
+	router.Use(middleware.RequestIDMiddleware())
+	router.Use(middleware.EnvelopeMiddleware(cfg.EnvelopeKeys))
+	router.Use(middleware.AccessLogMiddleware(middleware.AccessLogOptions{
+		SampleRate:    cfg.AccessLogSampleRate,
+		CaptureBodies: cfg.AccessLogBodies,
+		MaxBodyBytes:  cfg.AccessLogMaxBody,
+	}))
+	router.Use(middleware.StatsMiddleware(handlers.RequestStats))
+	if handlers.SLO != nil {
+		router.Use(middleware.SLOMiddleware(handlers.SLO))
+	}
+	router.Use(middleware.MetricsMiddleware(handlers.Metrics))
+	if cfg.SlowRequest > 0 {
+		router.Use(middleware.SlowRequestMiddleware(cfg.SlowRequest, handlers.Metrics))
+	}
+	router.Use(middleware.ErrorReportMiddleware(handlers.ErrorReporter))
+	router.Use(middleware.RecoveryMiddleware(handlers.ErrorReporter))
+	router.Use(middleware.LocalizeMiddleware(handlers.Messages))
+	router.Use(middleware.CallerMiddleware(callerHeaderProxies(cfg)))
+	if cfg.AbuseMaxErrors > 0 || cfg.AbuseMaxAuthFailures > 0 || len(cfg.HoneypotPaths) > 0 {
+		handlers.Abuse = middleware.NewAbuseGuard(middleware.AbuseDetection{
+			Window:          cfg.AbuseWindow,
+			MaxErrors:       cfg.AbuseMaxErrors,
+			MaxAuthFailures: cfg.AbuseMaxAuthFailures,
+			BanDuration:     cfg.AbuseBanDuration,
+			Exempt:          abuseExempt,
+		})
+		router.Use(middleware.AbuseMiddleware(handlers.Abuse, handlers.Metrics))
+		if len(cfg.HoneypotPaths) > 0 {
+			router.Use(middleware.HoneypotMiddleware(cfg.HoneypotPaths, handlers.Abuse, handlers.Metrics))
+		}
+	}
+	router.Use(middleware.IPFilterMiddleware(handlers.IPFilter, handlers.Metrics))
+	router.Use(middleware.CORSMiddleware(handlers.Endpoints, handlers.AllowedOrigins))
+	router.Use(middleware.MaintenanceMiddleware(handlers.Routes, maintenanceAllow))
+	router.Use(middleware.RouteSwitchMiddleware(handlers.Routes))
+	if len(deprecations) > 0 {
+		router.Use(middleware.DeprecationMiddleware(deprecations))
+	}
+	if cfg.ShedQueueDepth > 0 || cfg.ShedP99 > 0 {
+		handlers.Shedder = middleware.NewLoadShedder(middleware.LoadShedding{
+			MaxQueueDepth: cfg.ShedQueueDepth,
+			MaxP99:        cfg.ShedP99,
+			MaxFraction:   cfg.ShedMaxFraction,
+		})
+		router.Use(middleware.LoadSheddingMiddleware(handlers.Shedder, handlers.Metrics))
+	}
+	if handlers.Accounts != nil {
+		router.Use(middleware.AccountKeysMiddleware(handlers.Accounts))
+	}
+	// Limit after keys are authenticated, so callers are told apart by
+	// keys they cannot make up
+	router.Use(middleware.RateLimitMiddleware(limiter))
+	if bulkheads != nil {
+		router.Use(middleware.ConcurrencyMiddleware(bulkheads, handlers.Metrics))
+		handlers.Bulkheads = bulkheads
+	}
+	handlers.StreamAdmission = &middleware.Admission{
+		Abuse:       handlers.Abuse,
+		Routes:      handlers.Routes,
+		Maintenance: maintenanceAllow,
+		Limiter:     limiter,
+		Bulkheads:   bulkheads,
+		Metrics:     handlers.Metrics,
+	}
+
+	// Start recurring prediction scheduler
+	if err := handlers.Schedules.Open(cfg.SchedulesFile, handlers.StorageKeys); err != nil {
+		log.Fatal("Failed to load schedules: ", err)
+	}
+	handlers.Schedules.Start()
+
+	// Consume prediction requests from a message queue if configured
+	var consumer queue.Consumer
+	if cfg.Queue.Driver != "" {
+		consumer = startQueueConsumer(cfg.Queue)
+	}
+
+	// Precompute predictions for popular inputs
+	if len(warmup) > 0 {
+		if err := handlers.StartWarmup(warmup, cfg.WarmupSchedule); err != nil {
+			log.Fatal("Failed to schedule cache warm-up: ", err)
+		}
+	}
+
+	// Keep the ML service warm while idle
+	if cfg.KeepWarm > 0 {
+		handlers.StartKeepWarm(cfg.KeepWarm)
+	}
+
+	// Purge cached predictions when the ML service rolls a model over, and
+	// keep the model catalog in sync; without polling it is synced once
+	if cfg.VersionPoll > 0 {
+		handlers.StartModelVersionPoll(cfg.VersionPoll)
+	} else {
+		go handlers.SyncModelCatalog(30 * time.Second)
+	}
+
+	// Monitor dependencies in the background for /health
+	startHealthChecks(cfg, consumer)
 
 	// Print banner
-	printBanner(port)
+	mlService := cfg.MLServiceURL
+	if cfg.Discovery.Mode != "" {
+		mlService = cfg.Discovery.Mode + ":" + cfg.Discovery.Name
+	}
+	if cfg.MLSocket != "" {
+		mlService += " via unix:" + cfg.MLSocket
+	}
+	printBanner(cfg.Port, mlService)
+
+	// Deterministic GET routes can be cached by browsers and CDNs
+	httpCache := middleware.CacheMiddleware(cfg.HTTPCacheMaxAge, func() time.Time { return handlers.RegistryLoaded })
+	signed := middleware.SignatureMiddleware(handlers.ResponseSigner)
+	digest := middleware.DigestMiddleware()
+	if handlers.ResponseSigner != nil {
+		router.GET("/.well-known/jwks.json", handlers.SigningKeysHandler)
+	}
 
 	// Register routes
 	v1 := router.Group("/api/v1")
 	{
 		v1.GET("/health", handlers.HealthCheckHandler)
-		v1.POST("/predict/housing", handlers.HousingPredictionHandler)
-		v1.POST("/predict/electricity", handlers.ElectricityPredictionHandler)
+		v1.GET("/ready", handlers.ReadinessHandler)
+		v1.GET("/version", handlers.VersionHandler)
+		v1.GET("/stats", handlers.StatsHandler)
+		v1.GET("/metrics/drift", handlers.DriftHandler)
+		v1.GET("/usage", handlers.UsageHandler)
+		v1.GET("/housing/stats", handlers.RegionalStatsHandler)
+		v1.GET("/models/catalog", handlers.CatalogHandler)
+		v1.POST("/reports/housing", digest, handlers.ReportHandler)
+		v1.GET("/reports/housing", digest, handlers.ReportHandler)
+		v1.POST("/appraisals/housing", signed, handlers.AppraisalHandler)
+		v1.POST("/tools/affordability", signed, handlers.AffordabilityHandler)
+		v1.POST("/predict/:model", signed, handlers.PredictionHandler)
+		v1.GET("/predict/:model", httpCache, signed, handlers.PredictionQueryHandler)
+		v1.POST("/predict/:model/ensemble", signed, handlers.EnsembleHandler)
+		v1.GET("/predict/:model/leaderboard", handlers.LeaderboardHandler)
+		v1.GET("/ws/predict", handlers.StreamPredictionHandler)
+		v1.GET("/predictions/export", digest, handlers.ExportHistoryHandler)
+		v1.POST("/jobs", handlers.SubmitJobHandler)
+		v1.GET("/jobs/:id", handlers.JobStatusHandler)
+		v1.GET("/jobs/:id/results", digest, handlers.JobResultsHandler)
+		v1.GET("/jobs/:id/events", handlers.JobEventsHandler)
+		v1.GET("/schedules", handlers.ListSchedulesHandler)
+		v1.POST("/schedules", handlers.CreateScheduleHandler)
+		v1.GET("/schedules/:id", handlers.GetScheduleHandler)
+		v1.PUT("/schedules/:id", handlers.UpdateScheduleHandler)
+		v1.DELETE("/schedules/:id", handlers.DeleteScheduleHandler)
+		if handlers.Accounts != nil {
+			v1.POST("/accounts", handlers.RegisterAccountHandler)
+			v1.GET("/accounts/verify", handlers.VerifyAccountPageHandler)
+			v1.POST("/accounts/verify", handlers.VerifyAccountHandler)
+			v1.GET("/accounts/me", handlers.GetAccountHandler)
+			v1.POST("/accounts/keys", handlers.CreateKeyHandler)
+			v1.DELETE("/accounts/keys/:id", handlers.RevokeKeyHandler)
+			v1.POST("/accounts/keys/:id/rotate", handlers.RotateKeyHandler)
+			v1.GET("/accounts/events", handlers.AccountEventsHandler)
+		}
+	}
+
+	// API v2 routes share the v1 pipeline with a versioned response envelope
+	v2 := router.Group("/api/v2")
+	{
+		v2.POST("/predict/:model", signed, handlers.PredictionV2Handler)
+		v2.GET("/predict/:model", httpCache, signed, handlers.PredictionQueryV2Handler)
+		v2.GET("/models", httpCache, handlers.ModelsV2Handler)
+		v2.GET("/models/:model", httpCache, handlers.ModelV2Handler)
+	}
+
+	// Demo page for trying the models from a browser
+	if cfg.DemoUI {
+		router.StaticFS("/demo", http.FS(demo.Files))
+	}
+
+	// GraphQL route
+	router.GET("/graphql", handlers.GraphQLHandler)
+	router.POST("/graphql", handlers.GraphQLHandler)
+
+	// Admin routes, enabled only when an admin token or OIDC is configured
+	if cfg.OIDC.Issuer != "" {
+		handlers.AdminOIDC = newAdminOIDC(cfg)
+		if handlers.AdminOIDC.LoginEnabled() {
+			router.GET("/admin/login", handlers.AdminLoginHandler)
+			router.GET("/admin/callback", handlers.AdminCallbackHandler)
+			router.GET("/admin/logout", handlers.AdminLogoutHandler)
+		}
+		admin := router.Group("/admin", middleware.OIDCAdminMiddleware(handlers.AdminOIDC, cfg.AdminToken))
+		admin.GET("/whoami", handlers.AdminWhoAmIHandler)
+		registerAdminRoutes(admin)
+	} else if cfg.AdminToken != "" {
+		registerAdminRoutes(router.Group("/admin", middleware.AdminAuthMiddleware(cfg.AdminToken)))
+	} else {
+		log.Printf("ADMIN_TOKEN not set; admin routes are disabled on public listeners")
+	}
+
+	// Listening sockets, taken over from the previous process after an upgrade
+	upgrader, err := upgrade.New()
+	if err != nil {
+		log.Fatal("Failed to take over listeners: ", err)
+	}
+	var servers []*http.Server
+
+	// Profiling and runtime diagnostics on a separate port
+	if cfg.AdminPort != "" {
+		servers = append(servers, serveListener(upgrader, newDiagnosticsRouter(cfg).Handler(), "diagnostics", ":"+cfg.AdminPort, cfg.SocketMode))
 	}
 
 	// Root route
-	router.GET("/", func(c *gin.Context) {
+	router.GET("/", httpCache, func(c *gin.Context) {
 		c.JSON(200, gin.H{
-			"service": "Cloud AI API Gateway",
-			"version": "1.0.0",
-			"endpoints": []string{
-				"GET  /api/v1/health",
-				"POST /api/v1/predict/housing",
-				"POST /api/v1/predict/electricity",
-			},
+			"service":   "Cloud AI API Gateway",
+			"version":   buildinfo.Version,
+			"endpoints": endpoints(),
 		})
 	})
+	handlers.Endpoints.Set(router.Routes())
+	public := middleware.MethodHandler(router, handlers.Endpoints, cfg.MethodOverride)
+
+	// Serve on a Unix socket too, for sidecars in the same pod
+	if cfg.ListenSocket != "" {
+		servers = append(servers, serveListener(upgrader, public, "public", "unix:"+cfg.ListenSocket, cfg.SocketMode))
+	}
+
+	// Additional listeners: public ones serve the API as on PORT; internal
+	// ones serve admin, diagnostics and monitoring routes without a token
+	var internal *gin.Engine
+	for _, entry := range cfg.Listeners {
+		profile, addr, _ := strings.Cut(entry, "=")
+		if profile == "public" {
+			servers = append(servers, serveListener(upgrader, public, profile, addr, cfg.SocketMode))
+			continue
+		}
+		if internal == nil {
+			internal = newInternalRouter(cfg)
+		}
+		servers = append(servers, serveListener(upgrader, internal.Handler(), profile, addr, cfg.SocketMode))
+	}
 
 	// Start server
-	log.Printf("Server starting on :%s", port)
-	if err := router.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	log.Printf("Server starting on :%s", cfg.Port)
+	servers = append(servers, serveListener(upgrader, public, "public", ":"+cfg.Port, cfg.SocketMode))
+
+	// Tell the previous process, if any, that it can drain and exit
+	if err := upgrader.Ready(); err != nil {
+		log.Printf("WARN failed to signal readiness to previous process: %v", err)
+	}
+	if upgrader.Inherited() {
+		log.Printf("Took over listeners from previous process")
+	}
+	if cfg.PIDFile != "" {
+		if err := os.WriteFile(cfg.PIDFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+			log.Printf("WARN failed to write PID file: %v", err)
+		}
+	}
+
+	waitForStop(upgrader, cfg.Upgrade)
+	shutdown(servers, cfg.Shutdown)
+	saveState(cfg)
+}
+
+// waitForStop blocks until the process should stop: on SIGTERM or SIGINT,
+// or once SIGHUP has handed the listeners to a new copy of the binary. A
+// failed upgrade is logged and the process carries on serving.
+func waitForStop(upgrader *upgrade.Upgrader, upgradeTimeout time.Duration) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGTERM, syscall.SIGINT)
+	for {
+		select {
+		case sig := <-signals:
+			if sig != syscall.SIGHUP {
+				log.Printf("Received %s; shutting down", sig)
+				return
+			}
+			log.Printf("Upgrade requested; starting new process")
+			go func() {
+				if err := upgrader.Upgrade(upgradeTimeout); err != nil {
+					log.Printf("ERROR upgrade failed, still serving: %v", err)
+				}
+			}()
+		case <-upgrader.Exit():
+			log.Printf("New process is serving; draining and exiting")
+			return
+		}
+	}
+}
+
+// shutdown stops accepting connections and waits up to timeout for
+// in-flight requests to finish, then flushes any running capture, metrics
+// and events
+func shutdown(servers []*http.Server, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func(srv *http.Server) {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				log.Printf("WARN requests still in flight at shutdown: %v", err)
+			}
+		}(srv)
+	}
+	wg.Wait()
+
+	if capture, ok := handlers.Capture.Stop(); ok {
+		log.Printf("Capture stopped at shutdown: destination=%s captured=%d error=%q", capture.Destination, capture.Captured, capture.Error)
+	}
+	handlers.Events.Close()
+	handlers.Metrics.Close()
+	log.Printf("Server stopped")
+}
+
+// newAdminOIDC creates the OIDC provider that authenticates admin callers.
+// Without OIDC_SESSION_SECRET, sessions are signed with a random key and
+// end when the process restarts.
+func newAdminOIDC(cfg *config.Config) *oidc.Provider {
+	roles := make(map[string]string, len(cfg.OIDC.RoleMap))
+	for _, entry := range cfg.OIDC.RoleMap {
+		group, role, _ := strings.Cut(entry, "=")
+		roles[group] = role
+	}
+	secret := []byte(cfg.OIDC.SessionSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatal("Failed to generate admin session key: ", err)
+		}
+		if cfg.OIDC.ClientSecret != "" {
+			log.Printf("OIDC_SESSION_SECRET not set; admin sessions end on restart and are not shared between replicas")
+		}
+	}
+	p := oidc.New(oidc.Config{
+		Issuer:        cfg.OIDC.Issuer,
+		ClientID:      cfg.OIDC.ClientID,
+		ClientSecret:  cfg.OIDC.ClientSecret,
+		Audiences:     cfg.OIDC.Audiences,
+		GroupsClaim:   cfg.OIDC.GroupsClaim,
+		RoleMap:       roles,
+		Scopes:        cfg.OIDC.Scopes,
+		RedirectURL:   cfg.PublicURL + "/admin/callback",
+		SessionTTL:    cfg.OIDC.SessionTTL,
+		SessionSecret: secret,
+	})
+	log.Printf("Admin API authenticates with OIDC (%s)", cfg.OIDC.Issuer)
+	return p
+}
+
+// registerAdminRoutes adds the admin API to a group
+func registerAdminRoutes(admin *gin.RouterGroup) {
+	admin.GET("/config", handlers.ConfigHandler)
+	admin.GET("/cache", handlers.CacheStatsHandler)
+	admin.DELETE("/cache", handlers.FlushCacheHandler)
+	admin.GET("/cache/keys", handlers.CacheKeysHandler)
+	admin.DELETE("/cache/keys/:key", handlers.DeleteCacheKeyHandler)
+	admin.POST("/cache/purge", handlers.PurgeCacheHandler)
+	admin.GET("/backends", handlers.BackendsHandler)
+	admin.GET("/regions", handlers.RegionsHandler)
+	admin.GET("/rollout", handlers.RolloutStatusHandler)
+	admin.POST("/rollout", handlers.StartRolloutHandler)
+	admin.DELETE("/rollout", handlers.StopRolloutHandler)
+	admin.POST("/diff-models", handlers.DiffModelsHandler)
+	admin.POST("/verify-ml", handlers.VerifyMLHandler)
+	admin.GET("/config/dynamic", handlers.DynamicConfigHandler)
+	admin.GET("/concurrency", handlers.ConcurrencyHandler)
+	admin.GET("/dark-launch", handlers.DarkLaunchHandler)
+	admin.GET("/models/versions", handlers.ModelVersionsHandler)
+	admin.POST("/models/versions", handlers.ModelVersionWebhookHandler)
+	admin.GET("/routes", handlers.ListRoutesHandler)
+	admin.POST("/routes", handlers.UpdateRouteHandler)
+	admin.GET("/alerts", handlers.AlertsHandler)
+	admin.GET("/slo", handlers.SLOHandler)
+	admin.GET("/usage", handlers.AdminUsageHandler)
+	admin.GET("/predictions/export", middleware.DigestMiddleware(), handlers.AdminExportHistoryHandler)
+	admin.GET("/billing", middleware.DigestMiddleware(), handlers.AdminBillingHandler)
+	if handlers.Accounts != nil {
+		admin.GET("/accounts", handlers.ListAccountsHandler)
+	}
+	admin.GET("/ip-filter", handlers.ListIPRulesHandler)
+	admin.POST("/ip-filter", handlers.UpdateIPRulesHandler)
+	if handlers.Abuse != nil {
+		admin.GET("/bans", handlers.ListBansHandler)
+		admin.DELETE("/bans/:ip", handlers.LiftBanHandler)
+	}
+	admin.GET("/maintenance", handlers.MaintenanceStatusHandler)
+	admin.POST("/maintenance", handlers.UpdateMaintenanceHandler)
+	admin.GET("/capture", handlers.CaptureStatusHandler)
+	admin.POST("/capture", handlers.UpdateCaptureHandler)
+	admin.GET("/replay", handlers.ListReplaysHandler)
+	admin.POST("/replay", handlers.StartReplayHandler)
+	admin.GET("/replay/:id", handlers.ReplayStatusHandler)
+	admin.DELETE("/replay/:id", handlers.CancelReplayHandler)
+}
+
+// registerDiagnosticsRoutes adds runtime statistics and profiling routes
+func registerDiagnosticsRoutes(g gin.IRoutes) {
+	g.GET("/admin/runtime", handlers.RuntimeHandler)
+	g.Any("/debug/pprof/*profile", handlers.ProfileHandler)
+}
+
+// newInternalRouter builds the router for internal listeners: the admin
+// API, diagnostics and monitoring routes, without admin authentication.
+// Internal listeners must only be reachable from inside the mesh.
+func newInternalRouter(cfg *config.Config) *gin.Engine {
+	internal := gin.New()
+	trustProxies(internal, cfg)
+	internal.Use(middleware.RequestIDMiddleware())
+	internal.Use(middleware.AccessLogMiddleware(middleware.AccessLogOptions{
+		SampleRate:    cfg.AccessLogSampleRate,
+		CaptureBodies: cfg.AccessLogBodies,
+		MaxBodyBytes:  cfg.AccessLogMaxBody,
+	}))
+	internal.Use(middleware.RecoveryMiddleware(handlers.ErrorReporter))
+
+	internal.GET("/api/v1/health", handlers.HealthCheckHandler)
+	internal.GET("/api/v1/ready", handlers.ReadinessHandler)
+	internal.GET("/api/v1/version", handlers.VersionHandler)
+	internal.GET("/api/v1/stats", handlers.StatsHandler)
+	registerAdminRoutes(internal.Group("/admin"))
+	registerDiagnosticsRoutes(internal)
+	return internal
+}
+
+// trustProxies makes router take the client IP from the real-IP headers
+// when the connection comes from a trusted proxy, and from the connection
+// otherwise. With no trusted proxies the headers are ignored: every router
+// must be passed through here, since gin.New trusts all proxies by default.
+func trustProxies(router *gin.Engine, cfg *config.Config) {
+	router.RemoteIPHeaders = cfg.RealIPHeaders
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatal("Failed to set trusted proxies: ", err)
+	}
+}
+
+// callerHeaderProxies returns the proxies whose X-Tenant-ID and X-API-Key
+// headers identify the caller: the trusted proxies if TRUST_CALLER_HEADERS
+// is set, else none
+func callerHeaderProxies(cfg *config.Config) []*net.IPNet {
+	if !cfg.TrustCallerHeaders {
+		return nil
+	}
+	networks, err := ipfilter.ParseNetworks(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatal("Failed to set trusted proxies: ", err)
 	}
+	return networks
 }
 
-func printBanner(port string) {
+// serveListener serves handler in the background on addr, a TCP address
+// such as ":9090" or "[::1]:8080", or "unix:" and a socket path created
+// with mode. The listener is taken over from the previous process after an
+// upgrade. The process exits if the listener cannot be opened.
+func serveListener(upgrader *upgrade.Upgrader, handler http.Handler, profile, addr string, mode os.FileMode) *http.Server {
+	network := "tcp"
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		network, addr = "unix", path
+	}
+	listener, err := upgrader.Listen(network, addr)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s %s: %v", network, addr, err)
+	}
+	if network == "unix" {
+		if err := os.Chmod(addr, mode); err != nil {
+			log.Fatal("Failed to set Unix socket permissions: ", err)
+		}
+	}
+
+	log.Printf("Server listening on %s:%s (%s)", network, addr, profile)
+	srv := &http.Server{Handler: handler}
+	go func() {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to serve on %s %s: %v", network, addr, err)
+		}
+	}()
+	return srv
+}
+
+// startQueueConsumer connects to the configured broker and processes queued
+// prediction requests in the background. The process exits if the consumer
+// stops so that it is restarted with a fresh connection.
+func startQueueConsumer(qc config.QueueConfig) queue.Consumer {
+	consumer, err := queue.New(queue.Config{
+		Driver:       qc.Driver,
+		URL:          qc.URL,
+		RequestQueue: qc.Request,
+		ReplyQueue:   qc.Reply,
+		Concurrency:  qc.Concurrency,
+	})
+	if err != nil {
+		log.Fatal("Failed to start queue consumer: ", err)
+	}
+	log.Printf("Consuming prediction requests from %s %s", qc.Driver, qc.Request)
+
+	go func() {
+		defer consumer.Close()
+		if err := consumer.Run(context.Background(), handlers.QueueMessageHandler); err != nil {
+			log.Fatal("Queue consumer stopped: ", err)
+		}
+	}()
+	return consumer
+}
+
+// saveState writes the SLO counts to SLO_STATE_FILE and the usage ledger
+// to USAGE_STATE_FILE, where set
+func saveState(cfg *config.Config) {
+	if handlers.SLO != nil && cfg.SLOStateFile != "" {
+		if err := handlers.SLO.Save(cfg.SLOStateFile, handlers.StorageKeys); err != nil {
+			log.Printf("WARN %v", err)
+		}
+	}
+	if cfg.UsageStateFile != "" {
+		if err := handlers.Usage.Save(cfg.UsageStateFile, handlers.StorageKeys); err != nil {
+			log.Printf("WARN %v", err)
+		}
+	}
+}
+
+// newMLWatcher returns the ML backend watcher for the configured discovery
+// mode, or nil to use ML_SERVICE_URL
+func newMLWatcher(cfg *config.Config) (discovery.Watcher, error) {
+	dc := cfg.Discovery
+	switch dc.Mode {
+	case "srv":
+		return &discovery.SRVWatcher{Name: dc.Name, Scheme: dc.Scheme, Interval: dc.Interval}, nil
+	case "kubernetes":
+		w, err := discovery.NewKubernetesWatcher(dc.Namespace, dc.Name, dc.Port, dc.Scheme)
+		if err != nil {
+			return nil, err
+		}
+		return w, nil
+	case "consul":
+		return &discovery.ConsulWatcher{Addr: cfg.Consul.Addr, Token: cfg.Consul.Token, Service: dc.Name, Scheme: dc.Scheme}, nil
+	}
+	return nil, nil
+}
+
+// startDynamicConfig watches etcd or Consul KV for the settings replicas
+// pick up without a restart: rate_limit, flags and, unless ML_DISCOVERY is
+// set, ml_backends. Removing a key restores the environment's value.
+func startDynamicConfig(cfg *config.Config, limiter *middleware.RateLimiter) {
+	var src dynconfig.Source
+	if cfg.Dynamic.Source == "etcd" {
+		src = &dynconfig.EtcdSource{Endpoints: cfg.Dynamic.EtcdEndpoints, Prefix: cfg.Dynamic.Prefix}
+	} else {
+		src = &dynconfig.ConsulSource{Addr: cfg.Consul.Addr, Token: cfg.Consul.Token, Prefix: cfg.Dynamic.Prefix}
+	}
+	store := dynconfig.NewStore()
+
+	static := limiter.Limit()
+	store.OnChange("rate_limit", func(value []byte) {
+		limit := static
+		if value != nil {
+			limit = middleware.RateLimit{}
+			if err := json.Unmarshal(value, &limit); err != nil || limit.RequestsPerSecond < 0 || limit.Burst < 0 {
+				log.Printf("Ignoring invalid dynamic rate_limit: %s", value)
+				return
+			}
+		}
+		limiter.SetLimit(limit)
+		limit = limiter.Limit()
+		log.Printf("Rate limit set: requests_per_second=%g burst=%d", limit.RequestsPerSecond, limit.Burst)
+	})
+
+	store.OnChange("flags", func(value []byte) {
+		var configured map[string]flags.Flag
+		if value != nil {
+			var err error
+			if configured, err = flags.Parse(value); err == nil {
+				err = handlers.CheckFlags(configured)
+			}
+			if err != nil {
+				log.Printf("Ignoring invalid dynamic flags: %v", err)
+				return
+			}
+		}
+		handlers.Flags.Replace(configured)
+		log.Printf("Feature flags updated: %d overridden", len(configured))
+	})
+
+	if handlers.MLBackends != nil {
+		store.OnChange("ml_backends", func([]byte) {
+			log.Printf("Ignoring dynamic ml_backends: ML_DISCOVERY is set")
+		})
+	} else if handlers.MLRegions != nil {
+		store.OnChange("ml_backends", func([]byte) {
+			log.Printf("Ignoring dynamic ml_backends: ML_REGIONS_FILE is set")
+		})
+	} else {
+		handlers.MLBackends = discovery.NewPool(cfg.MLServiceURL)
+		store.OnChange("ml_backends", func(value []byte) {
+			backends := []string{cfg.MLServiceURL}
+			if value != nil {
+				var err error
+				if backends, err = discovery.ParseBackends(value); err != nil {
+					log.Printf("Ignoring invalid dynamic ml_backends: %v", err)
+					return
+				}
+			}
+			if handlers.MLBackends.Set(backends) {
+				log.Printf("ML backends updated: count=%d backends=%v", len(backends), backends)
+			}
+		})
+	}
+
+	handlers.DynamicConfig = store
+	dynconfig.Run(context.Background(), src, store)
+	log.Printf("Watching dynamic configuration in %s under %s", cfg.Dynamic.Source, cfg.Dynamic.Prefix)
+}
+
+// startHealthChecks registers each configured dependency with the health
+// monitor and starts checking them
+func startHealthChecks(cfg *config.Config, consumer queue.Consumer) {
+	critical := make(map[string]bool)
+	for _, name := range cfg.HealthCritical {
+		critical[name] = true
+	}
+
+	handlers.Health.Add(health.Component{
+		Name:     handlers.MLServiceComponent,
+		Critical: critical["ml_service"],
+		Check:    handlers.CheckMLService,
+	})
+	if kafka, ok := handlers.Events.(*events.KafkaPublisher); ok {
+		handlers.Health.Add(health.Component{Name: "kafka", Critical: critical["kafka"], Check: kafka.Ping})
+	}
+	if consumer != nil {
+		handlers.Health.Add(health.Component{Name: "queue", Critical: critical["queue"], Check: consumer.Ping})
+	}
+	handlers.Health.Start()
+}
+
+// newDiagnosticsRouter builds the router serving pprof profiles and
+// runtime statistics on their own port, so they can be kept off the public
+// network
+func newDiagnosticsRouter(cfg *config.Config) *gin.Engine {
+	diag := gin.New()
+	trustProxies(diag, cfg)
+	diag.Use(middleware.RecoveryMiddleware(handlers.ErrorReporter), middleware.AdminAuthMiddleware(cfg.AdminToken))
+	registerDiagnosticsRoutes(diag)
+	return diag
+}
+
+// usage describes the subcommands
+const usage = `Usage: cloud-ai-api [command] [flags]
+
+Commands:
+  serve       Run the gateway (the default)
+  predict     Request a prediction: predict <model> --<field> <value> ...
+  batch       Run a batch job from a file: batch <model> --input rows.csv
+  health      Check a running gateway's health
+  loadtest    Send generated housing traffic to a gateway
+  verify-ml   Check the ML service against its contract
+
+Run "cloud-ai-api <command> -h" for a command's flags.
+`
+
+// runCommand runs a subcommand and returns the process exit status: 0 on
+// success, 1 if it failed and 2 if it was used wrongly
+func runCommand(name string, args []string) int {
+	switch name {
+	case "predict":
+		return runPredict(args)
+	case "batch":
+		return runBatch(args)
+	case "health":
+		return runHealth(args)
+	case "loadtest":
+		return runLoadTest(args)
+	case "verify-ml":
+		return runVerifyML(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", name, usage)
+	return 2
+}
+
+// gatewayFlags adds the flags locating the gateway to a client subcommand
+// and returns a function making the client once they are parsed
+func gatewayFlags(fs *flag.FlagSet) func() (*client.Client, error) {
+	target := fs.String("url", envDefault("CLOUD_AI_URL", "http://localhost:8080"), "base URL of the gateway (default $CLOUD_AI_URL)")
+	apiKey := fs.String("api-key", os.Getenv("CLOUD_AI_API_KEY"), "X-API-Key to send (default $CLOUD_AI_API_KEY)")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout of each request")
+	return func() (*client.Client, error) {
+		if u, err := url.Parse(*target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, errors.New("--url must be an http(s) URL")
+		}
+		if *timeout <= 0 {
+			return nil, errors.New("--timeout must be positive")
+		}
+		return client.New(*target, *apiKey, *timeout), nil
+	}
+}
+
+// commandModel returns the model named by a client subcommand's first
+// argument, as declared in MODEL_REGISTRY_FILE or the built-in registry.
+// Models unknown locally are returned without fields.
+func commandModel(command string, args []string) (*registry.Model, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return nil, fmt.Errorf("usage: cloud-ai-api %s <model> [flags]", command)
+	}
+	reg := registry.Default()
+	if file := os.Getenv("MODEL_REGISTRY_FILE"); file != "" {
+		var err error
+		if reg, err = registry.Load(file); err != nil {
+			return nil, err
+		}
+	}
+	if model, ok := reg.Get(args[0]); ok {
+		return model, nil
+	}
+	return &registry.Model{Name: args[0]}, nil
+}
+
+// fieldFlag sets a request field from the command line, converted to the
+// field's type
+type fieldFlag struct {
+	payload map[string]interface{}
+	field   registry.Field
+}
+
+func (f fieldFlag) String() string { return "" }
+
+func (f fieldFlag) Set(s string) error {
+	f.payload[f.field.Name] = registry.TypedValue(s, f.field.Type)
+	return nil
+}
+
+// runPredict requests one prediction and prints it. Each field of the
+// model is a flag, under its name (with underscores or dashes) and its
+// aliases.
+func runPredict(args []string) int {
+	model, err := commandModel("predict", args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	fs := flag.NewFlagSet("predict "+model.Name, flag.ContinueOnError)
+	newClient := gatewayFlags(fs)
+	data := fs.String("data", "", "request as a JSON object; field flags override its values")
+	field := fs.String("field", "", "print only this response field, e.g. price")
+	asJSON := fs.Bool("json", false, "print the response as JSON")
+	payload := make(map[string]interface{})
+	for _, f := range model.Fields {
+		usage := f.Type
+		if len(f.Enum) > 0 {
+			usage = "one of " + strings.Join(f.Enum, ", ")
+		}
+		if f.Required {
+			usage += " (required)"
+		}
+		names := append([]string{f.Name}, f.Aliases...)
+		if dashed := strings.ReplaceAll(f.Name, "_", "-"); dashed != f.Name {
+			names = append(names, dashed)
+		}
+		for _, name := range names {
+			fs.Var(fieldFlag{payload: payload, field: f}, name, usage)
+		}
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected argument %q\n", fs.Arg(0))
+		return 2
+	}
+	c, err := newClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	request := make(map[string]interface{})
+	if *data != "" {
+		decoder := json.NewDecoder(strings.NewReader(*data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&request); err != nil || request == nil {
+			fmt.Fprintln(os.Stderr, "--data must be a JSON object")
+			return 2
+		}
+		request = client.Canonical(model, request)
+	}
+	for name, value := range payload {
+		request[name] = value
+	}
+
+	resp, err := c.Predict(context.Background(), model.Name, request)
+	if err != nil {
+		printClientError(err)
+		return 1
+	}
+	switch {
+	case *field != "":
+		value, ok := resp[*field]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "response has no field %q\n", *field)
+			return 1
+		}
+		fmt.Println(jobs.CSVValue(value))
+	case *asJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(resp)
+	default:
+		names := make([]string, 0, len(resp))
+		for name := range resp {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%s: %s\n", name, jobs.CSVValue(resp[name]))
+		}
+	}
+	return 0
+}
+
+// runBatch submits the rows of a CSV or JSON file as batch jobs, waits for
+// them and writes the results as CSV or JSON. It exits with status 1 if any
+// row failed.
+func runBatch(args []string) int {
+	model, err := commandModel("batch", args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	fs := flag.NewFlagSet("batch "+model.Name, flag.ContinueOnError)
+	newClient := gatewayFlags(fs)
+	input := fs.String("input", "-", "CSV, JSON array or JSON lines file of rows; - reads standard input")
+	format := fs.String("format", "", "input format: csv, json or jsonl (default from the file extension, else csv)")
+	output := fs.String("output", "-", "file to write the results to; - writes standard output")
+	asJSON := fs.Bool("json", false, "write the results as JSON instead of CSV")
+	chunk := fs.Int("chunk-size", 10000, "most rows per job; larger inputs are split into several jobs")
+	poll := fs.Duration("poll", time.Second, "how often to check job progress")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	c, err := newClient()
+	if err == nil && (*chunk < 1 || *poll <= 0) {
+		err = errors.New("--chunk-size and --poll must be positive")
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	in := os.Stdin
+	if *input != "-" {
+		if in, err = os.Open(*input); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		defer in.Close()
+	}
+	if *format == "" {
+		*format = client.FormatCSV
+		switch {
+		case strings.HasSuffix(*input, ".jsonl"), strings.HasSuffix(*input, ".ndjson"):
+			*format = client.FormatJSONL
+		case strings.HasSuffix(*input, ".json"):
+			*format = client.FormatJSON
+		}
+	}
+	rows, err := client.ReadRows(in, *format, model)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if len(rows) == 0 {
+		fmt.Fprintln(os.Stderr, "no rows to predict")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	var results []jobs.Result
+	failed := 0
+	for start := 0; start < len(rows); start += *chunk {
+		end := start + *chunk
+		if end > len(rows) {
+			end = len(rows)
+		}
+		job, err := c.SubmitJob(ctx, model.Name, rows[start:end])
+		if err != nil {
+			printClientError(err)
+			return 1
+		}
+		fmt.Fprintf(os.Stderr, "Job %s: %d rows\n", job.ID, job.Total)
+		job, err = c.WaitJob(ctx, job.ID, *poll, func(j jobs.Job) {
+			fmt.Fprintf(os.Stderr, "Job %s: %s, %d/%d processed, %d failed\n", j.ID, j.Status, j.Processed, j.Total, j.Failed)
+		})
+		if err != nil {
+			printClientError(err)
+			return 1
+		}
+		if job.Status == jobs.StatusFailed {
+			fmt.Fprintf(os.Stderr, "Job %s failed\n", job.ID)
+			return 1
+		}
+		chunkResults, err := c.JobResults(ctx, job.ID)
+		if err != nil {
+			printClientError(err)
+			return 1
+		}
+		for _, r := range chunkResults {
+			r.Row += start
+			if r.Error != "" {
+				failed++
+			}
+			results = append(results, r)
+		}
+	}
+
+	out := os.Stdout
+	if *output != "-" {
+		if out, err = os.Create(*output); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer out.Close()
+	}
+	if *asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(results)
+	} else {
+		err = jobs.WriteCSV(out, results)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write results: %s\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "%d rows predicted, %d failed\n", len(results)-failed, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runHealth prints a running gateway's health and exits with status 1
+// unless it is healthy (and, with --ready, ready)
+func runHealth(args []string) int {
+	fs := flag.NewFlagSet("health", flag.ContinueOnError)
+	newClient := gatewayFlags(fs)
+	ready := fs.Bool("ready", false, "also require the gateway to be ready")
+	asJSON := fs.Bool("json", false, "print the health report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	c, err := newClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	ctx := context.Background()
+	report, err := c.Health(ctx)
+	if err != nil {
+		printClientError(err)
+		return 1
+	}
+	ok := report.Status == health.StatusHealthy
+	var readiness models.ReadinessResponse
+	if *ready {
+		if readiness, err = c.Ready(ctx); err != nil {
+			printClientError(err)
+			return 1
+		}
+		ok = ok && readiness.Ready
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		fmt.Printf("%s (version %s)\n", report.Status, report.Version)
+		for _, comp := range report.Components {
+			line := fmt.Sprintf("  %-20s %-5s %7.1fms", comp.Name, comp.Status, comp.LatencyMs)
+			if comp.LastError != "" {
+				line += "  " + comp.LastError
+			}
+			fmt.Println(line)
+		}
+		if *ready {
+			fmt.Printf("ready: %t\n", readiness.Ready)
+			for _, check := range readiness.Checks {
+				fmt.Printf("  %-20s %s %s\n", check.Name, check.Status, check.Details)
+			}
+		}
+	}
+	if !ok {
+		return 1
+	}
+	return 0
+}
+
+// printClientError reports a failed gateway call, listing each violation
+// of a rejected request
+func printClientError(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
+		for _, v := range apiErr.Response.Violations {
+			fmt.Fprintf(os.Stderr, "  %s: %s\n", strings.TrimPrefix(v.Pointer, "/"), v.Message)
+		}
+	}
+}
+
+// envDefault returns an environment variable, or def if it is unset
+func envDefault(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// runLoadTest sends randomized housing requests to a gateway at a fixed
+// rate and prints latency percentiles. It returns the process exit status:
+// 2 if the flags are invalid, 1 if every request failed.
+func runLoadTest(args []string) int {
+	fs := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	target := fs.String("target", "http://localhost:8080", "base URL of the gateway under test")
+	rps := fs.Float64("rps", 50, "requests per second to offer")
+	duration := fs.Duration("duration", time.Minute, "how long to send requests")
+	concurrency := fs.Int("concurrency", 256, "most requests in flight; further requests are dropped")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout of each request")
+	apiKey := fs.String("api-key", os.Getenv("LOADTEST_API_KEY"), "X-API-Key to send (default $LOADTEST_API_KEY)")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	var problems []string
+	if u, err := url.Parse(*target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		problems = append(problems, "--target must be an http(s) URL")
+	}
+	if *rps <= 0 || *rps > 100000 {
+		problems = append(problems, "--rps must be greater than 0 and at most 100000")
+	}
+	if *duration <= 0 {
+		problems = append(problems, "--duration must be positive")
+	}
+	if *concurrency < 1 {
+		problems = append(problems, "--concurrency must be at least 1")
+	}
+	if *timeout <= 0 {
+		problems = append(problems, "--timeout must be positive")
+	}
+	if len(problems) > 0 {
+		for _, p := range problems {
+			fmt.Fprintln(os.Stderr, p)
+		}
+		return 2
+	}
+
+	// Stop early on Ctrl-C, still reporting what was sent
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Fprintf(os.Stderr, "Sending %.0f req/s of housing predictions to %s for %s\n", *rps, *target, *duration)
+	report := loadtest.Run(ctx, loadtest.Options{
+		Target:           *target,
+		Model:            "housing",
+		RPS:              *rps,
+		Duration:         *duration,
+		Concurrency:      *concurrency,
+		Timeout:          *timeout,
+		APIKey:           *apiKey,
+		ProgressInterval: 10 * time.Second,
+		Progress: func(r loadtest.Report) {
+			fmt.Fprintf(os.Stderr, "%5.0fs sent=%d failed=%d dropped=%d p50=%.1fms p99=%.1fms\n", r.DurationS, r.Sent, r.Failed, r.Dropped, r.P50Ms, r.P99Ms)
+		},
+	}, loadtest.Housing(plausibility.Default()))
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		loadtest.WriteText(os.Stdout, report)
+	}
+	if report.Sent > 0 && report.Succeeded == 0 {
+		return 1
+	}
+	return 0
+}
+
+// runVerifyML runs the ML contract suite against an ML service and prints
+// the report, exiting with status 1 if any case failed
+func runVerifyML(args []string) int {
+	fs := flag.NewFlagSet("verify-ml", flag.ContinueOnError)
+	target := fs.String("url", envDefault("ML_SERVICE_URL", "http://localhost:5000"), "base URL of the ML service (default $ML_SERVICE_URL)")
+	suiteFile := fs.String("suite", os.Getenv("ML_CONTRACT_FILE"), "JSON contract suite (default $ML_CONTRACT_FILE, or the built-in suite)")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout of each request")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if u, err := url.Parse(*target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fmt.Fprintln(os.Stderr, "--url must be an http(s) URL")
+		return 2
+	}
+	if *timeout <= 0 {
+		fmt.Fprintln(os.Stderr, "--timeout must be positive")
+		return 2
+	}
+	suite := mlcontract.Default()
+	if *suiteFile != "" {
+		var err error
+		if suite, err = mlcontract.Load(*suiteFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	report := mlcontract.Verify(ctx, &http.Client{Timeout: *timeout}, *target, suite)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		mlcontract.WriteText(os.Stdout, report)
+	}
+	if !report.Passed {
+		return 1
+	}
+	return 0
+}
+
+// reported reports whether problems already include one for name, so a
+// value the config loader rejected is not checked a second time
+func reported(problems []config.Problem, name string) bool {
+	for _, p := range problems {
+		if p.Var == name {
+			return true
+		}
+	}
+	return false
+}
+
+// reportConfigProblems logs one line per invalid setting so that every
+// problem can be fixed before the next start
+func reportConfigProblems(problems []config.Problem) {
+	log.Printf("Configuration invalid: %d problem(s) found", len(problems))
+	for _, p := range problems {
+		log.Printf("config_error var=%s message=%q", p.Var, p.Message)
+	}
+}
+
+func printBanner(port, mlService string) {
 	banner := `
 ================================================================================
   Cloud AI API Gateway - Team Yunus
 ================================================================================
 
 Service:      API Gateway (Go)
-Version:      1.0.0
+Version:      %s
 Port:         %s
 ML Service:   %s
 
 Endpoints:
   GET  /                        - Service info
   GET  /api/v1/health           - Health check
-  POST /api/v1/predict/housing  - Predict UK housing price
-  POST /api/v1/predict/electricity - Predict UK electricity demand
-
+  GET  /api/v1/ready            - Readiness (startup self-test)
+  GET  /api/v1/version          - Build information
+  GET  /api/v1/stats            - Latency percentiles and error rates
+  GET  /api/v1/metrics/drift    - Input and prediction drift
+  GET  /api/v1/usage            - ML calls and cost for the caller
+  GET  /api/v1/housing/stats    - Regional price statistics from history
+  GET  /api/v1/models/catalog   - Models and versions served by the ML service
+  POST /api/v1/reports/housing  - PDF/HTML prediction report
+  POST /api/v1/appraisals/housing - Portfolio appraisal
+  POST /api/v1/tools/affordability - Mortgage affordability
+%s  GET  /api/v1/ws/predict      - WebSocket prediction stream
+  GET  /api/v1/predictions/export - Download history (CSV/Excel)
+  POST /api/v1/jobs             - Submit async batch job
+  GET  /api/v1/jobs/:id/events  - Job progress (server-sent events)
+  POST /api/v1/schedules        - Register recurring prediction
+  POST /api/v1/accounts         - Self-service registration (ACCOUNTS_FILE)
+  POST /api/v2/predict/:model   - Prediction (v2 envelope)
+  GET  /api/v2/models           - Model metadata (v2)
+  POST /graphql                 - GraphQL queries
 Documentation:
   http://localhost:%s/
 
 ================================================================================
 `
-	fmt.Printf(banner, port, handlers.MLServiceURL, port)
+	var predictions strings.Builder
+	for _, m := range handlers.Registry.Models() {
+		fmt.Fprintf(&predictions, "  POST /api/v1/predict/%s - %s\n", m.Name, m.Description)
+		if handlers.Ensembles[m.Name] != nil {
+			fmt.Fprintf(&predictions, "  POST /api/v1/predict/%s/ensemble - Ensemble of %d models\n", m.Name, len(handlers.Ensembles[m.Name].Members))
+		}
+		if m.Leaderboard != nil {
+			fmt.Fprintf(&predictions, "  GET  /api/v1/predict/%s/leaderboard - Rank %d %s values\n", m.Name, len(m.Leaderboard.Values), m.Leaderboard.Field)
+		}
+	}
+	fmt.Printf(banner, buildinfo.Version, port, mlService, predictions.String(), port)
+}
+
+// endpoints lists the public routes, including one prediction route per registered model
+func endpoints() []string {
+	list := []string{"GET  /api/v1/health", "GET  /api/v1/ready", "GET  /api/v1/version", "GET  /api/v1/stats", "GET  /api/v1/metrics/drift", "GET  /api/v1/usage", "GET  /api/v1/housing/stats", "GET  /api/v1/models/catalog", "POST /api/v1/reports/housing", "GET  /api/v1/reports/housing", "POST /api/v1/appraisals/housing", "POST /api/v1/tools/affordability"}
+	for _, name := range handlers.Registry.Names() {
+		list = append(list, "POST /api/v1/predict/"+name, "GET  /api/v1/predict/"+name)
+		if handlers.Ensembles[name] != nil {
+			list = append(list, "POST /api/v1/predict/"+name+"/ensemble")
+		}
+		if model, _ := handlers.Registry.Get(name); model.Leaderboard != nil {
+			list = append(list, "GET  /api/v1/predict/"+name+"/leaderboard")
+		}
+	}
+	list = append(list,
+		"GET  /api/v1/ws/predict",
+		"GET  /api/v1/predictions/export",
+		"POST /api/v1/jobs",
+		"GET  /api/v1/jobs/:id",
+		"GET  /api/v1/jobs/:id/results",
+		"GET  /api/v1/jobs/:id/events",
+		"GET  /api/v1/schedules",
+		"POST /api/v1/schedules",
+		"GET  /api/v1/schedules/:id",
+		"PUT  /api/v1/schedules/:id",
+		"DELETE /api/v1/schedules/:id",
+	)
+	if handlers.Accounts != nil {
+		list = append(list,
+			"POST /api/v1/accounts",
+			"GET  /api/v1/accounts/verify",
+			"POST /api/v1/accounts/verify",
+			"GET  /api/v1/accounts/me",
+			"POST /api/v1/accounts/keys",
+			"DELETE /api/v1/accounts/keys/:id",
+			"POST /api/v1/accounts/keys/:id/rotate",
+			"GET  /api/v1/accounts/events",
+		)
+	}
+	if handlers.ResponseSigner != nil {
+		list = append(list, "GET  /.well-known/jwks.json")
+	}
+	return append(list,
+		"POST /api/v2/predict/:model",
+		"GET  /api/v2/predict/:model",
+		"GET  /api/v2/models",
+		"GET  /api/v2/models/:model",
+		"POST /graphql",
+	)
 }
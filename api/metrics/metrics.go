@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"time"
+)
+
+// Emitter pushes metrics to an external system. Calls must not block the
+// request path. Tags are "key:value" strings.
+type Emitter interface {
+	Timing(name string, d time.Duration, tags ...string)
+	Incr(name string, tags ...string)
+	Histogram(name string, value float64, tags ...string)
+	Close() error
+}
+
+// Nop discards all metrics
+type Nop struct{}
+
+// Timing discards the timing
+func (Nop) Timing(string, time.Duration, ...string) {}
+
+// Incr discards the counter
+func (Nop) Incr(string, ...string) {}
+
+// Histogram discards the value
+func (Nop) Histogram(string, float64, ...string) {}
+
+// Close does nothing
+func (Nop) Close() error { return nil }
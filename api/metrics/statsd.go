@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPacketSize keeps UDP datagrams under a typical Ethernet MTU
+const maxPacketSize = 1432
+
+// flushInterval bounds how long a metric waits in a partly filled packet
+const flushInterval = time.Second
+
+// StatsD pushes metrics over UDP in StatsD line format. With DogStatsD
+// enabled, tags are appended in Datadog's "|#tag1,tag2" extension;
+// otherwise they are dropped, as plain StatsD has no tags.
+type StatsD struct {
+	conn      net.Conn
+	prefix    string
+	tags      []string
+	dogstatsd bool
+	lines     chan string
+	done      chan struct{}
+}
+
+// NewStatsD creates an emitter sending to addr (host:port). prefix is
+// prepended to every metric name and tags are added to every metric.
+func NewStatsD(addr, prefix string, tags []string, dogstatsd bool) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open statsd connection: %w", err)
+	}
+	s := &StatsD{
+		conn:      conn,
+		prefix:    prefix,
+		tags:      tags,
+		dogstatsd: dogstatsd,
+		lines:     make(chan string, 4096),
+		done:      make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Timing records a duration in milliseconds
+func (s *StatsD) Timing(name string, d time.Duration, tags ...string) {
+	ms := strconv.FormatFloat(float64(d.Microseconds())/1000, 'f', -1, 64)
+	s.send(name, ms+"|ms", tags)
+}
+
+// Incr increments a counter by one
+func (s *StatsD) Incr(name string, tags ...string) {
+	s.send(name, "1|c", tags)
+}
+
+// Histogram records a value, such as a size, in a distribution
+func (s *StatsD) Histogram(name string, value float64, tags ...string) {
+	s.send(name, strconv.FormatFloat(value, 'f', -1, 64)+"|h", tags)
+}
+
+// send formats a metric and queues it, dropping it if the queue is full
+func (s *StatsD) send(name, value string, tags []string) {
+	var b strings.Builder
+	b.WriteString(s.prefix)
+	b.WriteString(name)
+	b.WriteByte(':')
+	b.WriteString(value)
+	if s.dogstatsd && len(s.tags)+len(tags) > 0 {
+		b.WriteString("|#")
+		b.WriteString(strings.Join(append(append([]string{}, s.tags...), tags...), ","))
+	}
+
+	select {
+	case s.lines <- b.String():
+	default:
+	}
+}
+
+// run batches queued metrics into packets until the emitter is closed
+func (s *StatsD) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	var packet []byte
+	flush := func() {
+		if len(packet) == 0 {
+			return
+		}
+		if _, err := s.conn.Write(packet); err != nil {
+			log.Printf("Failed to send statsd metrics: %v", err)
+		}
+		packet = packet[:0]
+	}
+
+	for {
+		select {
+		case line, ok := <-s.lines:
+			if !ok {
+				flush()
+				return
+			}
+			if len(packet) > 0 && len(packet)+1+len(line) > maxPacketSize {
+				flush()
+			}
+			if len(packet) > 0 {
+				packet = append(packet, '\n')
+			}
+			packet = append(packet, line...)
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// Close flushes pending metrics and closes the connection
+func (s *StatsD) Close() error {
+	close(s.lines)
+	<-s.done
+	return s.conn.Close()
+}
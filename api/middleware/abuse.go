@@ -0,0 +1,247 @@
+package middleware
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud-ai-api/metrics"
+	"cloud-ai-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Reasons a client IP is banned
+const (
+	// BanErrors means the client had too many client error responses
+	BanErrors = "errors"
+	// BanAuthFailures means the client had too many 401 and 429 responses
+	BanAuthFailures = "auth_failures"
+	// BanHoneypot means the client requested a honeypot route
+	BanHoneypot = "honeypot"
+)
+
+// AbuseDetection sets when a client IP is banned: after MaxErrors client
+// error (4xx) responses, or MaxAuthFailures 401 and 429 responses, within
+// Window. A zero threshold ignores that signal. Bans last BanDuration.
+// Clients in Exempt are never banned.
+type AbuseDetection struct {
+	Window          time.Duration
+	MaxErrors       int
+	MaxAuthFailures int
+	BanDuration     time.Duration
+	Exempt          []*net.IPNet
+}
+
+// Ban is a client IP banned for abuse
+type Ban struct {
+	IP           string    `json:"ip"`
+	Reason       string    `json:"reason"`
+	Since        time.Time `json:"since"`
+	Until        time.Time `json:"until"`
+	Errors       int       `json:"errors"`
+	AuthFailures int       `json:"auth_failures"`
+	Trap         string    `json:"trap,omitempty"`
+	Rejected     int64     `json:"rejected"`
+}
+
+// abuseWindow counts a client's offending responses in the current window
+type abuseWindow struct {
+	start        time.Time
+	errors       int
+	authFailures int
+}
+
+// AbuseGuard tracks error rates per client IP and bans clients that exceed
+// them for a cooling-off period
+type AbuseGuard struct {
+	limits AbuseDetection
+
+	mu      sync.Mutex
+	windows map[string]*abuseWindow
+	bans    map[string]*Ban
+	swept   time.Time
+}
+
+// NewAbuseGuard creates a guard with the given thresholds
+func NewAbuseGuard(limits AbuseDetection) *AbuseGuard {
+	return &AbuseGuard{
+		limits:  limits,
+		windows: make(map[string]*abuseWindow),
+		bans:    make(map[string]*Ban),
+		swept:   time.Now(),
+	}
+}
+
+// exempt reports whether a client may never be banned
+func (g *AbuseGuard) exempt(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return true
+	}
+	for _, network := range g.limits.Exempt {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// banned returns the client's ban, counting the rejected request
+func (g *AbuseGuard) banned(ip string, now time.Time) (Ban, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	b, ok := g.bans[ip]
+	if !ok {
+		return Ban{}, false
+	}
+	if !now.Before(b.Until) {
+		delete(g.bans, ip)
+		return Ban{}, false
+	}
+	b.Rejected++
+	return *b, true
+}
+
+// record counts a response to a client and bans it if that takes it over a
+// threshold, returning the new ban
+func (g *AbuseGuard) record(ip string, status int, now time.Time) (Ban, bool) {
+	authFailure := status == http.StatusUnauthorized || status == http.StatusTooManyRequests
+	if status < 400 || status >= 500 || g.exempt(ip) {
+		return Ban{}, false
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// Drop finished windows and expired bans, so past clients use no memory
+	if now.Sub(g.swept) > g.limits.Window {
+		for key, w := range g.windows {
+			if now.Sub(w.start) >= g.limits.Window {
+				delete(g.windows, key)
+			}
+		}
+		for key, b := range g.bans {
+			if !now.Before(b.Until) {
+				delete(g.bans, key)
+			}
+		}
+		g.swept = now
+	}
+
+	if _, ok := g.bans[ip]; ok {
+		return Ban{}, false
+	}
+	w, ok := g.windows[ip]
+	if !ok || now.Sub(w.start) >= g.limits.Window {
+		w = &abuseWindow{start: now}
+		g.windows[ip] = w
+	}
+	w.errors++
+	if authFailure {
+		w.authFailures++
+	}
+
+	reason := ""
+	switch {
+	case g.limits.MaxAuthFailures > 0 && w.authFailures >= g.limits.MaxAuthFailures:
+		reason = BanAuthFailures
+	case g.limits.MaxErrors > 0 && w.errors >= g.limits.MaxErrors:
+		reason = BanErrors
+	default:
+		return Ban{}, false
+	}
+	b := &Ban{
+		IP:           ip,
+		Reason:       reason,
+		Since:        now,
+		Until:        now.Add(g.limits.BanDuration),
+		Errors:       w.errors,
+		AuthFailures: w.authFailures,
+	}
+	g.bans[ip] = b
+	delete(g.windows, ip)
+	return *b, true
+}
+
+// trap bans a client for requesting a honeypot route, unless it is exempt
+func (g *AbuseGuard) trap(ip, path string, now time.Time) (Ban, bool) {
+	if g.exempt(ip) {
+		return Ban{}, false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	b := &Ban{
+		IP:     ip,
+		Reason: BanHoneypot,
+		Since:  now,
+		Until:  now.Add(g.limits.BanDuration),
+		Trap:   path,
+	}
+	g.bans[ip] = b
+	delete(g.windows, ip)
+	return *b, true
+}
+
+// Bans lists the clients currently banned, oldest first
+func (g *AbuseGuard) Bans() []Ban {
+	now := time.Now()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	out := make([]Ban, 0, len(g.bans))
+	for _, b := range g.bans {
+		if now.Before(b.Until) {
+			out = append(out, *b)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Since.Before(out[j].Since) })
+	return out
+}
+
+// Lift ends a client's ban, reporting whether it was banned
+func (g *AbuseGuard) Lift(ip string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	b, ok := g.bans[ip]
+	delete(g.bans, ip)
+	delete(g.windows, ip)
+	return ok && time.Now().Before(b.Until)
+}
+
+// AbuseMiddleware rejects banned clients with 403 Forbidden and Retry-After,
+// and bans clients whose responses exceed the guard's error thresholds
+func AbuseMiddleware(g *AbuseGuard, emitter metrics.Emitter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientIP := c.ClientIP()
+		now := time.Now()
+		if b, ok := g.banned(clientIP, now); ok {
+			emitter.Incr("abuse.rejected", "reason:"+b.Reason)
+			banRejection(b, now).abort(c)
+			return
+		}
+
+		c.Next()
+
+		g.observe(clientIP, c.Writer.Status(), emitter)
+	}
+}
+
+// banRejection rejects a banned client until its ban ends
+func banRejection(b Ban, now time.Time) *Rejection {
+	return &Rejection{http.StatusForbidden, retryAfterSeconds(b.Until.Sub(now)), models.ErrorResponse{
+		Error:   "Forbidden",
+		Details: "Your address is temporarily banned after too many failed requests; retry after the time in the Retry-After header",
+	}}
+}
+
+// observe records a response to a client, logging and counting the ban if
+// it is banned as a result
+func (g *AbuseGuard) observe(ip string, status int, emitter metrics.Emitter) {
+	if b, ok := g.record(ip, status, time.Now()); ok {
+		log.Printf("WARN ip_banned client_ip=%s reason=%s errors=%d auth_failures=%d until=%s",
+			b.IP, b.Reason, b.Errors, b.AuthFailures, b.Until.UTC().Format(time.RFC3339))
+		emitter.Incr("abuse.bans", "reason:"+b.Reason)
+	}
+}
@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+
+	"cloud-ai-api/errorreport"
+	"github.com/gin-gonic/gin"
+)
+
+// maxParsedBody is the most body bytes buffered for redaction; larger
+// bodies are summarized rather than logged
+const maxParsedBody = 64 << 10
+
+// AccessLogOptions controls which requests are logged and what is captured
+type AccessLogOptions struct {
+	// SampleRate is the fraction of successful (< 400) requests logged;
+	// errors are always logged
+	SampleRate float64
+	// CaptureBodies logs JSON request and response bodies, redacted
+	CaptureBodies bool
+	// MaxBodyBytes caps each logged body
+	MaxBodyBytes int
+}
+
+// AccessLogMiddleware writes one structured line per request, logging every
+// error response and a sample of successful ones. With body capture on,
+// JSON bodies are logged with sensitive-looking fields redacted; other
+// bodies are summarized by size and content type.
+func AccessLogMiddleware(opts AccessLogOptions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		var reqBody []byte
+		var respBody *bodyCapture
+		if opts.CaptureBodies && !upgrade(c) {
+			reqBody = peekBody(c)
+			respBody = &bodyCapture{ResponseWriter: c.Writer}
+			c.Writer = respBody
+		}
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if status < 400 && (opts.SampleRate <= 0 || rand.Float64() >= opts.SampleRate) {
+			return
+		}
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "access request_id=%s method=%s path=%s route=%s status=%d duration_ms=%.1f bytes=%d client_ip=%s",
+			RequestID(c), c.Request.Method, c.Request.URL.Path, c.FullPath(), status,
+			float64(time.Since(start).Microseconds())/1000, c.Writer.Size(), c.ClientIP())
+		if score, ok := c.Get(AnomalyScoreKey); ok {
+			fmt.Fprintf(&b, " anomaly_score=%.2f", score)
+		}
+		if respBody != nil {
+			fmt.Fprintf(&b, " request_body=%q response_body=%q",
+				logBody(reqBody, c.ContentType(), int(c.Request.ContentLength), opts.MaxBodyBytes),
+				logBody(respBody.buf.Bytes(), c.Writer.Header().Get("Content-Type"), c.Writer.Size(), opts.MaxBodyBytes))
+		}
+		log.Print(b.String())
+	}
+}
+
+// upgrade reports whether the request is a WebSocket upgrade, whose
+// connection must not be wrapped
+func upgrade(c *gin.Context) bool {
+	return strings.EqualFold(c.GetHeader("Upgrade"), "websocket")
+}
+
+// peekBody reads up to maxParsedBody bytes of the request body and puts
+// them back so handlers still see the whole body
+func peekBody(c *gin.Context) []byte {
+	if c.Request.Body == nil {
+		return nil
+	}
+	head, err := io.ReadAll(io.LimitReader(c.Request.Body, maxParsedBody))
+	if err != nil {
+		return nil
+	}
+	c.Request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), c.Request.Body), c.Request.Body}
+	return head
+}
+
+// logBody renders a captured body for the access log
+func logBody(body []byte, contentType string, size, maxBytes int) string {
+	if len(body) == 0 {
+		return ""
+	}
+	if size < len(body) {
+		size = len(body)
+	}
+	if !strings.Contains(contentType, "json") || len(body) >= maxParsedBody {
+		return fmt.Sprintf("<%d bytes %s>", size, contentType)
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return fmt.Sprintf("<%d bytes %s>", size, contentType)
+	}
+	redacted, err := json.Marshal(errorreport.Sanitize(payload))
+	if err != nil {
+		return fmt.Sprintf("<%d bytes %s>", size, contentType)
+	}
+	if maxBytes > 0 && len(redacted) > maxBytes {
+		return string(redacted[:maxBytes]) + "...(truncated)"
+	}
+	return string(redacted)
+}
+
+// bodyCapture copies the start of a response body while writing it through
+type bodyCapture struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *bodyCapture) Write(data []byte) (int, error) {
+	w.keep(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyCapture) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *bodyCapture) keep(data []byte) {
+	if room := maxParsedBody - w.buf.Len(); room > 0 {
+		if len(data) > room {
+			data = data[:room]
+		}
+		w.buf.Write(data)
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud-ai-api/accounts"
+	"cloud-ai-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// AccountKeysMiddleware admits requests made with self-service API keys.
+// Unknown, revoked and expired issued keys are rejected with 401
+// Unauthorized and keys over their daily quota with 429 Too Many Requests.
+// Responses to rotated keys still in their grace period carry
+// X-API-Key-Expires. Admitted requests come from the key's account: it is
+// recorded as their Caller, with the account ID as tenant, and X-Tenant-ID
+// is set to the ID. Other keys pass through unchanged, and the routes
+// exempt from maintenance mode are not counted.
+func AccountKeysMiddleware(store *accounts.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-API-Key")
+		if !strings.HasPrefix(key, accounts.KeyPrefix) || maintenanceExempt(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		now := time.Now()
+		adm, ok := store.Admit(key, now)
+		if !ok || !adm.Key.Active(now) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error:   "Invalid API key",
+				Details: "The API key is unknown, revoked or expired",
+			})
+			return
+		}
+		if adm.Key.ExpiresAt != nil {
+			c.Header("X-API-Key-Expires", adm.Key.ExpiresAt.UTC().Format(time.RFC3339))
+		}
+		if adm.Remaining >= 0 {
+			c.Header("X-Quota-Limit", strconv.Itoa(adm.Key.Quota.DailyRequests))
+			c.Header("X-Quota-Remaining", strconv.Itoa(adm.Remaining))
+		}
+		if !adm.Allowed {
+			midnight := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+			c.Header("Retry-After", strconv.Itoa(int(midnight.Sub(now).Seconds())+1))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, models.ErrorResponse{
+				Error:   "Quota exceeded",
+				Details: "The API key has used its daily request quota; it resets at midnight UTC",
+			})
+			return
+		}
+
+		c.Request.Header.Set("X-Tenant-ID", adm.Account.ID)
+		c.Request = WithCaller(c.Request, Caller{Tenant: adm.Account.ID, APIKey: key})
+		c.Next()
+	}
+}
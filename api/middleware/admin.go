@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"cloud-ai-api/models"
+	"cloud-ai-api/routes"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuthMiddleware requires an "Authorization: Bearer <token>" header
+// matching the admin token
+func AdminAuthMiddleware(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		given, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error: "Unauthorized",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RouteSwitchMiddleware rejects requests to routes disabled at runtime with
+// 503 Service Unavailable. Admin routes are never disabled.
+func RouteSwitchMiddleware(sw *routes.Switch) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if path == "/admin" || strings.HasPrefix(path, "/admin/") {
+			c.Next()
+			return
+		}
+
+		rule, disabled := sw.Match(c.Request.Method, path)
+		if !disabled {
+			c.Next()
+			return
+		}
+		disabledRejection(rule).abort(c)
+	}
+}
+
+// disabledRejection rejects requests to a route disabled by rule
+func disabledRejection(rule routes.Rule) *Rejection {
+	return &Rejection{http.StatusServiceUnavailable, rule.RetryAfter, models.ErrorResponse{
+		Error:   "Temporarily disabled",
+		Details: rule.Reason,
+	}}
+}
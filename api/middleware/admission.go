@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"cloud-ai-api/metrics"
+	"cloud-ai-api/models"
+	"cloud-ai-api/routes"
+	"github.com/gin-gonic/gin"
+)
+
+// Rejection is why a request, or work arriving on an admitted connection,
+// was turned away: the status, the error body and how many seconds to wait
+// before retrying, if known
+type Rejection struct {
+	Status     int
+	RetryAfter int
+	Response   models.ErrorResponse
+}
+
+// abort writes the rejection as the request's response
+func (r *Rejection) abort(c *gin.Context) {
+	if r.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(r.RetryAfter))
+	}
+	c.AbortWithStatusJSON(r.Status, r.Response)
+}
+
+// retryAfterSeconds rounds a wait up to whole seconds for Retry-After
+func retryAfterSeconds(wait time.Duration) int {
+	return int(math.Ceil(wait.Seconds()))
+}
+
+// Admission repeats the middleware chain's admission checks for work that
+// arrives on a connection the chain has already admitted, such as messages
+// on a WebSocket: abuse bans, maintenance mode, disabled routes, the rate
+// limit and concurrency limits. Nil checks are skipped, and a nil Admission
+// admits everything.
+type Admission struct {
+	Abuse       *AbuseGuard
+	Routes      *routes.Switch
+	Maintenance *Allowlist
+	Limiter     *RateLimiter
+	Bulkheads   *Bulkheads
+	Metrics     metrics.Emitter
+}
+
+// Admit checks whether the caller of c may run work as if it were its own
+// request to path, which matched the route pattern route. Work that is
+// admitted must call done with its status when it finishes, which frees its
+// concurrency slot and counts failures towards abuse bans.
+func (a *Admission) Admit(c *gin.Context, method, route, path string) (done func(status int), rej *Rejection) {
+	if a == nil {
+		return func(int) {}, nil
+	}
+	clientIP := c.ClientIP()
+	now := time.Now()
+	if a.Abuse != nil {
+		if b, ok := a.Abuse.banned(clientIP, now); ok {
+			a.Metrics.Incr("abuse.rejected", "reason:"+b.Reason)
+			return nil, banRejection(b, now)
+		}
+	}
+	if a.Routes != nil {
+		if m := a.Routes.Maintenance(); m.Enabled && !a.Maintenance.Allows(c) {
+			return nil, maintenanceRejection(m)
+		}
+		// The connection's own route can be disabled while it is open
+		for _, r := range []struct{ method, path string }{{c.Request.Method, c.Request.URL.Path}, {method, path}} {
+			if rule, disabled := a.Routes.Match(r.method, r.path); disabled {
+				return nil, disabledRejection(rule)
+			}
+		}
+	}
+	if a.Limiter != nil {
+		if ok, wait := a.Limiter.allow(rateLimitKey(c), now); !ok {
+			return nil, rateLimitRejection(wait)
+		}
+	}
+
+	release := func() {}
+	if a.Bulkheads != nil {
+		if r := a.Bulkheads.match(method, route, path); r != nil {
+			ok, reason := r.acquire(c.Request.Context().Done())
+			if !ok {
+				a.Metrics.Incr("route.concurrency.rejected", "route:"+r.Path, "reason:"+reason)
+				return nil, capacityRejection(r.Path)
+			}
+			release = func() { <-r.slots }
+		}
+	}
+	return func(status int) {
+		release()
+		if a.Abuse != nil {
+			a.Abuse.observe(clientIP, status, a.Metrics)
+		}
+	}, nil
+}
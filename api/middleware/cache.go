@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CacheMiddleware makes successful GET responses cacheable for maxAge and
+// answers conditional requests with 304 Not Modified. It is meant for
+// deterministic routes, whose response depends only on the request URL,
+// the Accept and Accept-Language headers and the data behind lastModified. The ETag is derived
+// from those, so a matching If-None-Match is answered without running the
+// handler; If-Modified-Since is compared with lastModified.
+func CacheMiddleware(maxAge time.Duration, lastModified func() time.Time) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+
+		modified := lastModified().UTC().Truncate(time.Second)
+		etag := requestETag(c.Request, modified)
+
+		header := c.Writer.Header()
+		header.Add("Vary", "Accept")
+		cacheHeaders := map[string]string{
+			"Cache-Control": fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())),
+			"ETag":          etag,
+			"Last-Modified": modified.Format(http.TimeFormat),
+		}
+
+		// Answer conditional requests; If-None-Match takes precedence
+		if inm := c.GetHeader("If-None-Match"); inm != "" {
+			if etagMatches(inm, etag) {
+				notModified(c, cacheHeaders)
+				return
+			}
+		} else if ims := c.GetHeader("If-Modified-Since"); ims != "" {
+			if t, err := http.ParseTime(ims); err == nil && !modified.After(t) {
+				notModified(c, cacheHeaders)
+				return
+			}
+		}
+
+		c.Writer = &cacheWriter{ResponseWriter: c.Writer, headers: cacheHeaders}
+		c.Next()
+	}
+}
+
+// requestETag derives a strong ETag from the request URL, Accept and
+// Accept-Language headers and last modification time
+func requestETag(r *http.Request, modified time.Time) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\n%s\n%s\n%s\n%s", modified.Unix(), r.URL.Path, r.URL.Query().Encode(), r.Header.Get("Accept"), r.Header.Get("Accept-Language"))
+	return `"` + hex.EncodeToString(h.Sum(nil))[:32] + `"`
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, using
+// weak comparison as RFC 9110 requires
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func notModified(c *gin.Context, headers map[string]string) {
+	for k, v := range headers {
+		c.Header(k, v)
+	}
+	c.AbortWithStatus(http.StatusNotModified)
+}
+
+// cacheWriter adds caching headers when a successful response is written
+// and marks any other response as not storable
+type cacheWriter struct {
+	gin.ResponseWriter
+	headers map[string]string
+	applied bool
+}
+
+func (w *cacheWriter) apply() {
+	if w.applied {
+		return
+	}
+	w.applied = true
+	if w.Status() == http.StatusOK {
+		for k, v := range w.headers {
+			w.Header().Set(k, v)
+		}
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+func (w *cacheWriter) WriteHeaderNow() {
+	w.apply()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *cacheWriter) Write(data []byte) (int, error) {
+	w.apply()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *cacheWriter) WriteString(s string) (int, error) {
+	w.apply()
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Caller is who a request comes from: the tenant and API key of a
+// credential the gateway has authenticated. Both are empty for anonymous
+// requests.
+type Caller struct {
+	Tenant string
+	APIKey string
+}
+
+// callerKey is the request context key holding the Caller
+type callerKey struct{}
+
+// CallerFrom returns the caller recorded on a request, or the anonymous
+// caller if there is none
+func CallerFrom(r *http.Request) Caller {
+	if r == nil {
+		return Caller{}
+	}
+	caller, _ := r.Context().Value(callerKey{}).(Caller)
+	return caller
+}
+
+// WithCaller returns a copy of r recording caller as who it comes from
+func WithCaller(r *http.Request, caller Caller) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), callerKey{}, caller))
+}
+
+// CallerMiddleware decides who each request comes from. X-Tenant-ID and
+// X-API-Key are set by the client, so they identify the caller only on
+// connections from headerProxies, proxies trusted to authenticate callers
+// and set the headers themselves. Other requests are anonymous and their
+// X-Tenant-ID is removed; AccountKeysMiddleware, running after this one,
+// identifies callers using self-service API keys.
+func CallerMiddleware(headerProxies []*net.IPNet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ip := net.ParseIP(c.RemoteIP()); ip != nil && containsIP(headerProxies, ip) {
+			c.Request = WithCaller(c.Request, Caller{
+				Tenant: c.GetHeader("X-Tenant-ID"),
+				APIKey: c.GetHeader("X-API-Key"),
+			})
+		} else {
+			c.Request.Header.Del("X-Tenant-ID")
+		}
+		c.Next()
+	}
+}
+
+// containsIP reports whether any of networks contains ip
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"cloud-ai-api/metrics"
+	"cloud-ai-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// ConcurrencyLimit bounds the requests a route serves at once. Path is a
+// route pattern as registered (e.g. "/api/v1/jobs"), a concrete path (e.g.
+// "/api/v1/predict/housing") or a prefix ending in "*"; Method is optional.
+// Requests beyond MaxConcurrent wait in a queue of up to QueueLength for at
+// most QueueTimeout (e.g. "2s"); requests that find the queue full or time
+// out get 503. Routes matched by one rule share its limit.
+type ConcurrencyLimit struct {
+	Method        string `json:"method,omitempty"`
+	Path          string `json:"path"`
+	MaxConcurrent int    `json:"max_concurrent"`
+	QueueLength   int    `json:"queue_length"`
+	QueueTimeout  string `json:"queue_timeout,omitempty"`
+}
+
+// ConcurrencyStatus reports a rule's current load and rejections
+type ConcurrencyStatus struct {
+	Method        string `json:"method,omitempty"`
+	Path          string `json:"path"`
+	MaxConcurrent int    `json:"max_concurrent"`
+	QueueLength   int    `json:"queue_length"`
+	QueueTimeout  string `json:"queue_timeout"`
+	Active        int    `json:"active"`
+	Queued        int64  `json:"queued"`
+	Rejected      int64  `json:"rejected"`
+	TimedOut      int64  `json:"timed_out"`
+}
+
+// defaultQueueTimeout applies to rules without a queue_timeout
+const defaultQueueTimeout = 5 * time.Second
+
+// Bulkheads enforces concurrency limits, one pool of slots per rule
+type Bulkheads struct {
+	rules []*bulkhead
+}
+
+type bulkhead struct {
+	ConcurrencyLimit
+	timeout  time.Duration
+	slots    chan struct{}
+	queued   int64
+	rejected int64
+	timedOut int64
+}
+
+// LoadConcurrencyLimits reads concurrency limits from a JSON file of the
+// form {"routes": [...]}
+func LoadConcurrencyLimits(path string) (*Bulkheads, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f struct {
+		Routes []ConcurrencyLimit `json:"routes"`
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid concurrency file: %w", err)
+	}
+	return NewBulkheads(f.Routes)
+}
+
+// NewBulkheads checks the limits and creates their slot pools
+func NewBulkheads(limits []ConcurrencyLimit) (*Bulkheads, error) {
+	b := &Bulkheads{}
+	for i, l := range limits {
+		if l.Path == "" {
+			return nil, fmt.Errorf("route %d: path is required", i)
+		}
+		if l.MaxConcurrent < 1 {
+			return nil, fmt.Errorf("route %s: max_concurrent must be at least 1", l.Path)
+		}
+		if l.QueueLength < 0 {
+			return nil, fmt.Errorf("route %s: queue_length must not be negative", l.Path)
+		}
+		timeout := defaultQueueTimeout
+		if l.QueueTimeout != "" {
+			d, err := time.ParseDuration(l.QueueTimeout)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("route %s: queue_timeout must be a positive duration such as 2s", l.Path)
+			}
+			timeout = d
+		}
+		b.rules = append(b.rules, &bulkhead{
+			ConcurrencyLimit: l,
+			timeout:          timeout,
+			slots:            make(chan struct{}, l.MaxConcurrent),
+		})
+	}
+	return b, nil
+}
+
+// Status reports each rule's load
+func (b *Bulkheads) Status() []ConcurrencyStatus {
+	out := make([]ConcurrencyStatus, 0, len(b.rules))
+	for _, r := range b.rules {
+		out = append(out, ConcurrencyStatus{
+			Method:        r.Method,
+			Path:          r.Path,
+			MaxConcurrent: r.MaxConcurrent,
+			QueueLength:   r.QueueLength,
+			QueueTimeout:  r.timeout.String(),
+			Active:        len(r.slots),
+			Queued:        atomic.LoadInt64(&r.queued),
+			Rejected:      atomic.LoadInt64(&r.rejected),
+			TimedOut:      atomic.LoadInt64(&r.timedOut),
+		})
+	}
+	return out
+}
+
+// match returns the first rule covering the request
+func (b *Bulkheads) match(method, route, path string) *bulkhead {
+	for _, r := range b.rules {
+		if r.Method != "" && !strings.EqualFold(r.Method, method) {
+			continue
+		}
+		if prefix, ok := strings.CutSuffix(r.Path, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return r
+			}
+		} else if r.Path == route || r.Path == path {
+			return r
+		}
+	}
+	return nil
+}
+
+// acquire takes a slot, waiting in the queue if there is room, and reports
+// why it failed ("queue_full" or "queue_timeout")
+func (r *bulkhead) acquire(done <-chan struct{}) (bool, string) {
+	select {
+	case r.slots <- struct{}{}:
+		return true, ""
+	default:
+	}
+
+	if atomic.AddInt64(&r.queued, 1) > int64(r.QueueLength) {
+		atomic.AddInt64(&r.queued, -1)
+		atomic.AddInt64(&r.rejected, 1)
+		return false, "queue_full"
+	}
+	defer atomic.AddInt64(&r.queued, -1)
+
+	timer := time.NewTimer(r.timeout)
+	defer timer.Stop()
+	select {
+	case r.slots <- struct{}{}:
+		return true, ""
+	case <-timer.C:
+	case <-done:
+	}
+	atomic.AddInt64(&r.timedOut, 1)
+	return false, "queue_timeout"
+}
+
+// ConcurrencyMiddleware enforces the concurrency limits, rejecting requests
+// that cannot get a slot with 503 Service Unavailable
+func ConcurrencyMiddleware(b *Bulkheads, emitter metrics.Emitter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r := b.match(c.Request.Method, c.FullPath(), c.Request.URL.Path)
+		if r == nil {
+			c.Next()
+			return
+		}
+
+		ok, reason := r.acquire(c.Request.Context().Done())
+		if !ok {
+			emitter.Incr("route.concurrency.rejected", "route:"+r.Path, "reason:"+reason)
+			capacityRejection(r.Path).abort(c)
+			return
+		}
+		defer func() { <-r.slots }()
+		c.Next()
+	}
+}
+
+// capacityRejection rejects requests a rule has no slot for
+func capacityRejection(path string) *Rejection {
+	return &Rejection{http.StatusServiceUnavailable, 1, models.ErrorResponse{
+		Error:   "Too many concurrent requests",
+		Details: fmt.Sprintf("%s is at capacity; please retry shortly", path),
+	}}
+}
@@ -1,23 +1,93 @@
 package middleware
 
 import (
+	"fmt"
 	"log"
+	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// CORSMiddleware adds CORS headers to all responses
-func CORSMiddleware() gin.HandlerFunc {
+// Origins are the browser origins allowed to call the API. A nil Origins
+// means none were configured.
+type Origins struct {
+	any     bool
+	origins map[string]bool
+}
+
+// ParseOrigins builds an allowlist from origins such as
+// "https://app.example.com"; "*" allows every origin
+func ParseOrigins(list []string) (*Origins, error) {
+	o := &Origins{origins: make(map[string]bool)}
+	for _, origin := range list {
+		origin = strings.TrimSpace(origin)
+		if origin == "*" {
+			o.any = true
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			return nil, fmt.Errorf("invalid origin %q: must be a scheme and host such as https://app.example.com", origin)
+		}
+		o.origins[strings.ToLower(u.Scheme+"://"+u.Host)] = true
+	}
+	return o, nil
+}
+
+// Allows reports whether a browser origin is on the allowlist
+func (o *Origins) Allows(origin string) bool {
+	if o == nil || origin == "" {
+		return false
+	}
+	return o.any || o.origins[strings.ToLower(origin)]
+}
+
+// AllowsWebSocket reports whether a WebSocket upgrade may be accepted:
+// clients that send no Origin are not browsers, pages on the gateway's own
+// origin are always allowed, and other pages only from allowed origins.
+// Without an allowlist, cross-origin pages are refused even though
+// CORSMiddleware lets them read HTTP responses, since a WebSocket carries
+// the browser's credentials without a preflight.
+func (o *Origins) AllowsWebSocket(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || o.Allows(origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// CORSMiddleware adds CORS headers to all responses, and answers OPTIONS
+// requests for paths with routes with the methods table routes for them,
+// in Allow and Access-Control-Allow-Methods. OPTIONS requests for other
+// paths fall through to the not-found handler. Without an allowlist every
+// origin is allowed; with one, only listed origins are named in
+// Access-Control-Allow-Origin.
+func CORSMiddleware(table *RouteTable, origins *Origins) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		if origins == nil || origins.any {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Writer.Header().Add("Vary", "Origin")
+			if origin := c.GetHeader("Origin"); origins.Allows(origin) {
+				c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+		}
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Request-ID, X-API-Key, "+MethodOverrideHeader)
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
+			if methods := table.Methods(c.Request.URL.Path); methods != nil {
+				allow := strings.Join(methods, ", ")
+				c.Header("Allow", allow)
+				c.Header("Access-Control-Allow-Methods", allow)
+				c.AbortWithStatus(204)
+				return
+			}
 		}
 
 		c.Next()
@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Deprecation marks routes as deprecated. Path is a route pattern as
+// registered (e.g. "/api/v1/predict/:model") or a prefix ending in "*"
+// (e.g. "/api/v1/*"); Method is optional.
+type Deprecation struct {
+	Method       string    `json:"method,omitempty"`
+	Path         string    `json:"path"`
+	DeprecatedAt time.Time `json:"deprecated_at"`
+	Sunset       time.Time `json:"sunset,omitempty"`
+	// Successor is the replacement route; ":param" segments are filled in
+	// from the request
+	Successor string `json:"successor,omitempty"`
+	// Link points to migration documentation
+	Link    string `json:"link,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// LoadDeprecations reads deprecation rules from a JSON file of the form
+// {"routes": [...]}
+func LoadDeprecations(path string) ([]Deprecation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f struct {
+		Routes []Deprecation `json:"routes"`
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid deprecation file: %w", err)
+	}
+	for i, d := range f.Routes {
+		if d.Path == "" {
+			return nil, fmt.Errorf("route %d: path is required", i)
+		}
+		if d.DeprecatedAt.IsZero() {
+			return nil, fmt.Errorf("route %s: deprecated_at is required", d.Path)
+		}
+		if !d.Sunset.IsZero() && d.Sunset.Before(d.DeprecatedAt) {
+			return nil, fmt.Errorf("route %s: sunset is before deprecated_at", d.Path)
+		}
+	}
+	return f.Routes, nil
+}
+
+// DeprecationMiddleware adds Deprecation (RFC 9745), Sunset (RFC 8594) and
+// Link headers to responses from deprecated routes, and a "warning" field
+// to JSON object responses
+func DeprecationMiddleware(rules []Deprecation) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rule := matchDeprecation(rules, c.Request.Method, c.FullPath())
+		if rule == nil {
+			c.Next()
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Set("Deprecation", fmt.Sprintf("@%d", rule.DeprecatedAt.Unix()))
+		if !rule.Sunset.IsZero() {
+			header.Set("Sunset", rule.Sunset.UTC().Format(http.TimeFormat))
+		}
+		successor := ""
+		if rule.Successor != "" {
+			successor = fillParams(rule.Successor, c)
+			header.Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successor))
+		}
+		if rule.Link != "" {
+			header.Add("Link", fmt.Sprintf(`<%s>; rel="deprecation"; type="text/html"`, rule.Link))
+		}
+
+		w := &warningWriter{ResponseWriter: c.Writer, warning: rule.warning(successor)}
+		c.Writer = w
+		c.Next()
+		w.finish()
+	}
+}
+
+// matchDeprecation returns the first rule matching a request
+func matchDeprecation(rules []Deprecation, method, route string) *Deprecation {
+	if route == "" {
+		return nil
+	}
+	for i := range rules {
+		r := &rules[i]
+		if r.Method != "" && !strings.EqualFold(r.Method, method) {
+			continue
+		}
+		if prefix, ok := strings.CutSuffix(r.Path, "*"); ok {
+			if strings.HasPrefix(route, prefix) {
+				return r
+			}
+		} else if r.Path == route {
+			return r
+		}
+	}
+	return nil
+}
+
+// warning returns the message added to JSON responses
+func (d *Deprecation) warning(successor string) string {
+	if d.Message != "" {
+		return d.Message
+	}
+	msg := "This endpoint is deprecated"
+	if !d.Sunset.IsZero() {
+		msg += " and will be removed on " + d.Sunset.UTC().Format("2006-01-02")
+	}
+	if successor != "" {
+		msg += "; use " + successor + " instead"
+	}
+	return msg + "."
+}
+
+// fillParams replaces ":name" segments of a route with the request's values
+func fillParams(route string, c *gin.Context) string {
+	segments := strings.Split(route, "/")
+	for i, s := range segments {
+		if name, ok := strings.CutPrefix(s, ":"); ok {
+			if v := c.Param(name); v != "" {
+				segments[i] = v
+			}
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// warningWriter buffers JSON responses so a warning field can be added to
+// them; other responses (streams, binary formats) pass through unchanged
+type warningWriter struct {
+	gin.ResponseWriter
+	warning string
+	decided bool
+	buffer  bool
+	body    bytes.Buffer
+}
+
+func (w *warningWriter) decide() {
+	if !w.decided {
+		w.decided = true
+		w.buffer = strings.HasPrefix(w.Header().Get("Content-Type"), "application/json")
+	}
+}
+
+func (w *warningWriter) Write(data []byte) (int, error) {
+	w.decide()
+	if w.buffer {
+		return w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *warningWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *warningWriter) WriteHeaderNow() {
+	w.decide()
+	if !w.buffer {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *warningWriter) Flush() {
+	if !w.buffer {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// finish writes a buffered JSON response with the warning field added as
+// its first member, keeping the order of the existing fields
+func (w *warningWriter) finish() {
+	if !w.buffer {
+		return
+	}
+	body := bytes.TrimSpace(w.body.Bytes())
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(body, &obj); err == nil && obj != nil {
+		if _, exists := obj["warning"]; !exists {
+			warning, _ := json.Marshal(w.warning)
+			rewritten := append([]byte(`{"warning":`), warning...)
+			if len(obj) > 0 {
+				rewritten = append(rewritten, ',')
+			}
+			body = append(rewritten, body[1:]...)
+		}
+	}
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.Write(body)
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DigestMiddleware adds checksums of the response body, so clients can
+// check large downloads arrived intact: Digest (RFC 3230, SHA-256),
+// Repr-Digest (RFC 9530, SHA-256) and Content-MD5. The body is held back
+// until the handler finishes; streamed responses get no checksums.
+func DigestMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		holdBody(c, func(body []byte) {
+			sha := sha256.Sum256(body)
+			sum := md5.Sum(body)
+			encoded := base64.StdEncoding.EncodeToString(sha[:])
+			c.Header("Digest", "SHA-256="+encoded)
+			c.Header("Repr-Digest", "sha-256=:"+encoded+":")
+			c.Header("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EnvelopeParam is the query parameter asking for responses in an envelope
+const EnvelopeParam = "envelope"
+
+// envelope wraps a JSON response: the body is data if the request
+// succeeded and error if it did not, the other being null
+type envelope struct {
+	Data  json.RawMessage `json:"data"`
+	Error json.RawMessage `json:"error"`
+	Meta  envelopeMeta    `json:"meta"`
+}
+
+type envelopeMeta struct {
+	RequestID  string  `json:"request_id"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// EnvelopeMiddleware wraps JSON responses in {data, error, meta} for
+// callers that ask with ?envelope=true, and for the API keys listed, which
+// can opt out with ?envelope=false. The status code and headers are kept.
+// Other content types, streams and bodies carrying a signature or digest
+// are passed through unchanged.
+func EnvelopeMiddleware(keys []string) gin.HandlerFunc {
+	enveloped := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		enveloped[key] = true
+	}
+	return func(c *gin.Context) {
+		if len(enveloped) > 0 {
+			c.Writer.Header().Add("Vary", "X-API-Key")
+		}
+		want := enveloped[c.GetHeader("X-API-Key")]
+		if v, err := strconv.ParseBool(c.Query(EnvelopeParam)); err == nil {
+			want = v
+		}
+		if !want {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		w := &holdWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+		if w.streaming {
+			return
+		}
+
+		body := w.body.Bytes()
+		header := w.Header()
+		if strings.HasPrefix(header.Get("Content-Type"), gin.MIMEJSON) && json.Valid(body) &&
+			header.Get(SignatureHeader) == "" && header.Get("Digest") == "" {
+			env := envelope{Meta: envelopeMeta{
+				RequestID:  c.GetString(RequestIDKey),
+				DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+			}}
+			if w.Status() >= http.StatusBadRequest {
+				env.Error = body
+			} else {
+				env.Data = body
+			}
+			if wrapped, err := json.Marshal(env); err == nil {
+				body = wrapped
+				header.Del("Content-Length")
+			}
+		}
+		w.ResponseWriter.WriteHeaderNow()
+		if len(body) > 0 && c.Request.Method != http.MethodHead {
+			w.ResponseWriter.Write(body)
+		}
+	}
+}
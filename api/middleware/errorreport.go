@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"cloud-ai-api/errorreport"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorReportedKey marks a request whose error a handler already reported
+// with fuller context, so the middleware does not report it again
+const ErrorReportedKey = "error_reported"
+
+// ErrorReportMiddleware reports 5xx responses to the error tracker with the
+// route and request ID. 503 responses are deliberate (maintenance, disabled
+// routes, readiness) and are not reported. Panics are reported by
+// RecoveryMiddleware.
+func ErrorReportMiddleware(reporter errorreport.Reporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if status := c.Writer.Status(); status >= 500 && status != http.StatusServiceUnavailable && !c.GetBool(ErrorReportedKey) {
+			reporter.Capture(errorreport.Event{
+				Message:   fmt.Sprintf("HTTP %d on %s %s", status, c.Request.Method, c.FullPath()),
+				Method:    c.Request.Method,
+				Route:     c.FullPath(),
+				Path:      c.Request.URL.Path,
+				RequestID: RequestID(c),
+				Status:    status,
+			})
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// holdBody runs the rest of the chain with the response body held back,
+// then calls headers with the complete body so it can add headers computed
+// over it, and sends the response. Responses the handler flushes early,
+// such as streams, are passed through without calling headers.
+func holdBody(c *gin.Context, headers func(body []byte)) {
+	w := &holdWriter{ResponseWriter: c.Writer}
+	c.Writer = w
+	c.Next()
+	c.Writer = w.ResponseWriter
+
+	if w.streaming {
+		return
+	}
+	if w.body.Len() > 0 {
+		headers(w.body.Bytes())
+	}
+	w.ResponseWriter.WriteHeaderNow()
+	if w.body.Len() > 0 && c.Request.Method != http.MethodHead {
+		w.ResponseWriter.Write(w.body.Bytes())
+	}
+}
+
+// holdWriter holds back the response body, or passes it through once the
+// handler starts streaming
+type holdWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	streaming bool
+}
+
+func (w *holdWriter) Write(data []byte) (int, error) {
+	if w.streaming {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.body.Write(data)
+}
+
+func (w *holdWriter) WriteString(s string) (int, error) {
+	if w.streaming {
+		return w.ResponseWriter.WriteString(s)
+	}
+	return w.body.WriteString(s)
+}
+
+func (w *holdWriter) WriteHeaderNow() {
+	if w.streaming {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+// Written reports a response as started once the handler writes to it, so
+// gin does not render over a held-back body
+func (w *holdWriter) Written() bool {
+	return w.body.Len() > 0 || w.ResponseWriter.Written()
+}
+
+// Flush gives up holding: the held-back body is sent and the rest streamed
+func (w *holdWriter) Flush() {
+	if !w.streaming {
+		w.streaming = true
+		w.ResponseWriter.WriteHeaderNow()
+		if w.body.Len() > 0 {
+			w.ResponseWriter.Write(w.body.Bytes())
+			w.body.Reset()
+		}
+	}
+	w.ResponseWriter.Flush()
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud-ai-api/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// honeypotMatch returns the honeypot pattern matching a path: an exact
+// path, or a prefix if the pattern ends in "*"
+func honeypotMatch(patterns []string, path string) (string, bool) {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return pattern, true
+			}
+		} else if pattern == path {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// HoneypotMiddleware flags clients requesting decoy routes that only
+// scanners look for, such as /wp-login.php or /.env, and bans them at once
+// through the abuse guard. The decoys answer 404 Not Found like any unknown
+// route, so scanners cannot tell they were caught.
+func HoneypotMiddleware(patterns []string, g *AbuseGuard, emitter metrics.Emitter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pattern, ok := honeypotMatch(patterns, c.Request.URL.Path)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		clientIP := c.ClientIP()
+		b, banned := g.trap(clientIP, pattern, time.Now())
+		log.Printf("WARN honeypot_hit client_ip=%s method=%s path=%s user_agent=%q banned=%t",
+			clientIP, c.Request.Method, c.Request.URL.Path, c.Request.UserAgent(), banned)
+		emitter.Incr("honeypot.hits", "trap:"+pattern)
+		if banned {
+			log.Printf("WARN ip_banned client_ip=%s reason=%s trap=%s until=%s",
+				b.IP, b.Reason, b.Trap, b.Until.UTC().Format(time.RFC3339))
+			emitter.Incr("abuse.bans", "reason:"+b.Reason)
+		}
+		c.Data(http.StatusNotFound, "text/plain", []byte("404 page not found"))
+		c.Abort()
+	}
+}
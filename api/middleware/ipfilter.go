@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"log"
+	"net"
+	"net/http"
+
+	"cloud-ai-api/ipfilter"
+	"cloud-ai-api/metrics"
+	"cloud-ai-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// IPFilterMiddleware rejects clients blocked by the IP rules with 403
+// Forbidden, logging and counting each blocked request
+func IPFilterMiddleware(f *ipfilter.Filter, emitter metrics.Emitter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientIP := c.ClientIP()
+		rule, reason := f.Check(c.Request.URL.Path, net.ParseIP(clientIP))
+		if reason == "" {
+			c.Next()
+			return
+		}
+
+		log.Printf("WARN ip_blocked client_ip=%s method=%s path=%s rule=%s reason=%s",
+			clientIP, c.Request.Method, c.Request.URL.Path, rule, reason)
+		emitter.Incr("ipfilter.blocked", "rule:"+rule, "reason:"+reason)
+		c.AbortWithStatusJSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "Forbidden",
+			Details: "Requests from your address are not allowed on this route",
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"cloud-ai-api/i18n"
+	"github.com/gin-gonic/gin"
+)
+
+// LanguageKey is the gin context key holding the response language
+const LanguageKey = "language"
+
+// localizedKeys are the JSON keys whose string values are human-readable
+// messages
+var localizedKeys = map[string]bool{"error": true, "details": true, "message": true}
+
+// LocalizeMiddleware picks the response language from Accept-Language
+// among the catalog's languages, stores it under LanguageKey and announces
+// it in Content-Language. JSON error responses written in English are
+// translated on their way out; handlers that build localized responses
+// themselves write them translated, and are passed through unchanged.
+// Bodies carrying a signature or digest are never rewritten, since those
+// were computed over the bytes as written.
+func LocalizeMiddleware(catalog *i18n.Catalog) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lang := catalog.Negotiate(c.GetHeader("Accept-Language"))
+		c.Set(LanguageKey, lang)
+		c.Header("Content-Language", lang)
+		c.Writer.Header().Add("Vary", "Accept-Language")
+		if lang == i18n.English {
+			c.Next()
+			return
+		}
+
+		w := &localizeWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+		if !w.held {
+			return
+		}
+
+		body := w.body.Bytes()
+		header := w.Header()
+		if strings.HasPrefix(header.Get("Content-Type"), gin.MIMEJSON) &&
+			header.Get(SignatureHeader) == "" && header.Get("Digest") == "" {
+			if translated, ok := localizeJSON(body, func(s string) string { return catalog.Translate(lang, s) }); ok {
+				body = translated
+				header.Del("Content-Length")
+			}
+		}
+		w.ResponseWriter.WriteHeaderNow()
+		if len(body) > 0 && c.Request.Method != http.MethodHead {
+			w.ResponseWriter.Write(body)
+		}
+	}
+}
+
+// Language returns the response language chosen for the request, English
+// if LocalizeMiddleware did not run
+func Language(c *gin.Context) string {
+	if lang := c.GetString(LanguageKey); lang != "" {
+		return lang
+	}
+	return i18n.English
+}
+
+// localizeJSON translates the messages in a JSON body, reporting whether
+// any changed. Numbers are kept as written.
+func localizeJSON(body []byte, translate func(string) string) ([]byte, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, false
+	}
+	if !localizeValue(doc, translate) {
+		return nil, false
+	}
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+func localizeValue(v interface{}, translate func(string) string) bool {
+	changed := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if s, ok := value.(string); ok && localizedKeys[key] {
+				if t := translate(s); t != s {
+					v[key] = t
+					changed = true
+				}
+				continue
+			}
+			if localizeValue(value, translate) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if localizeValue(item, translate) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// localizeWriter holds back error responses so they can be translated,
+// and passes successful ones straight through
+type localizeWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+	held bool
+}
+
+func (w *localizeWriter) hold() bool {
+	if !w.held && w.Status() >= http.StatusBadRequest && !w.ResponseWriter.Written() {
+		w.held = true
+	}
+	return w.held
+}
+
+func (w *localizeWriter) Write(data []byte) (int, error) {
+	if w.hold() {
+		return w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *localizeWriter) WriteString(s string) (int, error) {
+	if w.hold() {
+		return w.body.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *localizeWriter) WriteHeaderNow() {
+	if !w.hold() {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+// Written reports a held-back response as started, so gin does not
+// render over it
+func (w *localizeWriter) Written() bool {
+	return w.held || w.ResponseWriter.Written()
+}
+
+// Flush gives up holding: the held-back body is sent and the rest streamed
+func (w *localizeWriter) Flush() {
+	if w.held {
+		w.held = false
+		w.ResponseWriter.WriteHeaderNow()
+		w.ResponseWriter.Write(w.body.Bytes())
+		w.body.Reset()
+	}
+	w.ResponseWriter.Flush()
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"cloud-ai-api/ipfilter"
+	"cloud-ai-api/models"
+	"cloud-ai-api/routes"
+	"github.com/gin-gonic/gin"
+)
+
+// Allowlist admits callers during maintenance by client IP (or CIDR range)
+// or by authenticated API key
+type Allowlist struct {
+	networks []*net.IPNet
+	keys     map[string]bool
+}
+
+// ParseAllowlist builds an allowlist from IP addresses or CIDR ranges and API keys
+func ParseAllowlist(ips, keys []string) (*Allowlist, error) {
+	networks, err := ipfilter.ParseNetworks(ips)
+	if err != nil {
+		return nil, err
+	}
+	a := &Allowlist{networks: networks, keys: make(map[string]bool)}
+	for _, key := range keys {
+		if key = strings.TrimSpace(key); key != "" {
+			a.keys[key] = true
+		}
+	}
+	return a, nil
+}
+
+// Allows reports whether a request may bypass maintenance mode
+func (a *Allowlist) Allows(c *gin.Context) bool {
+	if a == nil {
+		return false
+	}
+	if key := CallerFrom(c.Request).APIKey; key != "" && a.keys[key] {
+		return true
+	}
+	ip := net.ParseIP(c.ClientIP())
+	return ip != nil && containsIP(a.networks, ip)
+}
+
+// MaintenanceMiddleware rejects requests with 503 Service Unavailable while
+// maintenance mode is on, except for the service info, health, readiness
+// and admin routes and allowlisted callers
+func MaintenanceMiddleware(sw *routes.Switch, allow *Allowlist) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m := sw.Maintenance()
+		if !m.Enabled || maintenanceExempt(c.Request.URL.Path) || allow.Allows(c) {
+			c.Next()
+			return
+		}
+		maintenanceRejection(m).abort(c)
+	}
+}
+
+// maintenanceRejection rejects callers while maintenance mode is on
+func maintenanceRejection(m routes.Maintenance) *Rejection {
+	details := m.Message
+	if details == "" {
+		details = "The service is undergoing maintenance; please retry later"
+	}
+	return &Rejection{http.StatusServiceUnavailable, m.RetryAfter, models.ErrorResponse{
+		Error:   "Service under maintenance",
+		Details: details,
+	}}
+}
+
+func maintenanceExempt(path string) bool {
+	switch path {
+	case "/", "/api/v1/health", "/api/v1/ready", "/admin":
+		return true
+	}
+	return strings.HasPrefix(path, "/admin/")
+}
@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MethodOverrideHeader names the method a POST request stands for, for
+// clients behind proxies that only pass GET and POST
+const MethodOverrideHeader = "X-HTTP-Method-Override"
+
+// RouteTable tells which methods a router serves for a path. It is set
+// once every route is registered, before serving.
+type RouteTable struct {
+	routes gin.RoutesInfo
+}
+
+// Set records the router's routes
+func (t *RouteTable) Set(routes gin.RoutesInfo) {
+	t.routes = routes
+}
+
+// Routes returns the recorded routes
+func (t *RouteTable) Routes() gin.RoutesInfo {
+	return t.routes
+}
+
+// Match returns the routes whose pattern matches path
+func (t *RouteTable) Match(path string) gin.RoutesInfo {
+	var matched gin.RoutesInfo
+	for _, route := range t.routes {
+		if matchRoute(route.Path, path) {
+			matched = append(matched, route)
+		}
+	}
+	return matched
+}
+
+// Routed reports whether a route serves method for path
+func (t *RouteTable) Routed(method, path string) bool {
+	for _, route := range t.routes {
+		if route.Method == method && matchRoute(route.Path, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Methods returns the methods served for path, sorted: those routed, HEAD
+// where GET is, and OPTIONS if there are any
+func (t *RouteTable) Methods(path string) []string {
+	set := map[string]bool{}
+	for _, route := range t.Match(path) {
+		set[route.Method] = true
+		if route.Method == http.MethodGet {
+			set[http.MethodHead] = true
+		}
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	set[http.MethodOptions] = true
+	methods := make([]string, 0, len(set))
+	for method := range set {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// matchRoute reports whether path matches a route pattern, in which :name
+// matches one segment and *name the rest of the path
+func matchRoute(pattern, path string) bool {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, p := range patternSegments {
+		if strings.HasPrefix(p, "*") {
+			return true
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if strings.HasPrefix(p, ":") {
+			if segments[i] == "" {
+				return false
+			}
+		} else if p != segments[i] {
+			return false
+		}
+	}
+	return len(patternSegments) == len(segments)
+}
+
+// MethodHandler serves router, answering HEAD requests for GET routes with
+// the GET handler, the server leaving out the body. With override set, a
+// POST request carrying X-HTTP-Method-Override is routed as the method it
+// names. This wraps the router rather than running as middleware because
+// gin picks the route by method before any middleware runs.
+func MethodHandler(router *gin.Engine, table *RouteTable, override bool) http.Handler {
+	handler := router.Handler()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := r.Method
+		if override && method == http.MethodPost {
+			if m := strings.ToUpper(strings.TrimSpace(r.Header.Get(MethodOverrideHeader))); m != "" {
+				method = m
+			}
+		}
+		if method == http.MethodHead && !table.Routed(http.MethodHead, r.URL.Path) && table.Routed(http.MethodGet, r.URL.Path) {
+			method = http.MethodGet
+		}
+		if method != r.Method {
+			// A copy, so that the server still sees the request's own
+			// method and sends no body for HEAD
+			r = r.WithContext(r.Context())
+			r.Method = method
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"cloud-ai-api/models"
+	"cloud-ai-api/oidc"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminSessionCookie holds the signed session of an admin who logged in
+// through the browser
+const AdminSessionCookie = "admin_session"
+
+// AdminIdentityKey is the gin context key of the authenticated admin's
+// oidc.Identity
+const AdminIdentityKey = "admin_identity"
+
+// OIDCAdminMiddleware authenticates admin requests with a JWT access token
+// from the OIDC provider ("Authorization: Bearer <jwt>", e.g. from the
+// client credentials grant) or a browser login session. The static admin
+// token, if set, is still accepted with the admin role. Viewers may only
+// make GET and HEAD requests; other requests get 403 Forbidden. Browsers
+// without a session are redirected to the login when it is configured.
+func OIDCAdminMiddleware(p *oidc.Provider, token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := adminIdentity(c, p, token)
+		if err != nil {
+			if errors.Is(err, oidc.ErrNoRole) {
+				c.AbortWithStatusJSON(http.StatusForbidden, models.ErrorResponse{
+					Error:   "Forbidden",
+					Details: err.Error(),
+				})
+				return
+			}
+			if c.Request.Method == http.MethodGet && p.LoginEnabled() &&
+				strings.Contains(c.GetHeader("Accept"), "text/html") {
+				c.Redirect(http.StatusFound, "/admin/login?next="+url.QueryEscape(c.Request.URL.RequestURI()))
+				c.Abort()
+				return
+			}
+			c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error:   "Unauthorized",
+				Details: err.Error(),
+			})
+			return
+		}
+		if !id.Allows(c.Request.Method) {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.ErrorResponse{
+				Error:   "Forbidden",
+				Details: "The " + id.Role + " role may only read admin state",
+			})
+			return
+		}
+		c.Set(AdminIdentityKey, id)
+		c.Next()
+	}
+}
+
+// adminIdentity authenticates a request by bearer token or session cookie
+func adminIdentity(c *gin.Context, p *oidc.Provider, token string) (oidc.Identity, error) {
+	if given, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		if token != "" && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1 {
+			return oidc.Identity{Subject: "admin-token", Role: oidc.RoleAdmin}, nil
+		}
+		return p.Verify(c.Request.Context(), given)
+	}
+	if cookie, err := c.Cookie(AdminSessionCookie); err == nil {
+		if id, ok := p.ParseSession(cookie); ok {
+			return id, nil
+		}
+		return oidc.Identity{}, errors.New("session has expired; log in again")
+	}
+	return oidc.Identity{}, errors.New("send a bearer token or log in at /admin/login")
+}
@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"cloud-ai-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit is a per-caller token bucket: RequestsPerSecond sustained, with
+// bursts of up to Burst requests. A zero rate disables limiting.
+type RateLimit struct {
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int     `json:"burst"`
+}
+
+// RateLimiter limits each caller, identified by its authenticated API key
+// or else client IP. Its limit can be changed while serving.
+type RateLimiter struct {
+	mu      sync.Mutex
+	limit   RateLimit
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a limiter with the given initial limit
+func NewRateLimiter(limit RateLimit) *RateLimiter {
+	rl := &RateLimiter{buckets: make(map[string]*bucket), swept: time.Now()}
+	rl.SetLimit(limit)
+	return rl
+}
+
+// SetLimit replaces the limit. Burst defaults to one second's worth of
+// requests. Callers start again with a full bucket.
+func (rl *RateLimiter) SetLimit(limit RateLimit) {
+	if limit.Burst <= 0 {
+		limit.Burst = int(math.Ceil(limit.RequestsPerSecond))
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.limit = limit
+	rl.buckets = make(map[string]*bucket)
+}
+
+// Limit returns the current limit
+func (rl *RateLimiter) Limit() RateLimit {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.limit
+}
+
+// allow takes a token from the caller's bucket, returning how long to wait
+// for one if it is empty
+func (rl *RateLimiter) allow(caller string, now time.Time) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.limit.RequestsPerSecond <= 0 {
+		return true, 0
+	}
+	rate, burst := rl.limit.RequestsPerSecond, float64(rl.limit.Burst)
+
+	// Drop buckets that have refilled, so idle callers use no memory
+	if now.Sub(rl.swept) > time.Minute {
+		for key, b := range rl.buckets {
+			if b.tokens+now.Sub(b.last).Seconds()*rate >= burst {
+				delete(rl.buckets, key)
+			}
+		}
+		rl.swept = now
+	}
+
+	b, ok := rl.buckets[caller]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		rl.buckets[caller] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// Allow takes a token from key's bucket, returning how long to wait for
+// one if it is empty. Handlers use it to limit actions by keys of their
+// own, such as an email address.
+func (rl *RateLimiter) Allow(key string) (bool, time.Duration) {
+	return rl.allow(key, time.Now())
+}
+
+// RateLimitMiddleware rejects callers over the limit with 429 Too Many
+// Requests and Retry-After. The service info, health, readiness and admin
+// routes are not limited. Only authenticated API keys get a bucket of their
+// own, since anyone can send a new X-API-Key with each request; it must
+// run after CallerMiddleware and AccountKeysMiddleware.
+func RateLimitMiddleware(rl *RateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maintenanceExempt(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		ok, wait := rl.allow(rateLimitKey(c), time.Now())
+		if ok {
+			c.Next()
+			return
+		}
+		rateLimitRejection(wait).abort(c)
+	}
+}
+
+// rateLimitKey names the bucket a request's caller draws from
+func rateLimitKey(c *gin.Context) string {
+	if key := CallerFrom(c.Request).APIKey; key != "" {
+		return "key:" + key
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// rateLimitRejection rejects a caller whose bucket is empty for wait
+func rateLimitRejection(wait time.Duration) *Rejection {
+	return &Rejection{http.StatusTooManyRequests, retryAfterSeconds(wait), models.ErrorResponse{
+		Error:   "Rate limit exceeded",
+		Details: "Too many requests; retry after the time in the Retry-After header",
+	}}
+}
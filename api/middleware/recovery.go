@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+	"syscall"
+
+	"cloud-ai-api/errorreport"
+	"cloud-ai-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// RecoveryMiddleware recovers from panics in later handlers, logs the stack
+// trace with the request ID, reports the panic to the error tracker and
+// answers with a structured 500 body. If the client has already gone away
+// the panic is logged but no response is written.
+func RecoveryMiddleware(reporter errorreport.Reporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+
+			id := RequestID(c)
+			stack := debug.Stack()
+			log.Printf("panic recovered: request_id=%s method=%s path=%s error=%v\n%s",
+				id, c.Request.Method, c.Request.URL.Path, err, stack)
+
+			if brokenPipe(err) {
+				c.Abort()
+				return
+			}
+
+			reporter.Capture(errorreport.Event{
+				Panic:     err,
+				Stack:     stack,
+				Method:    c.Request.Method,
+				Route:     c.FullPath(),
+				Path:      c.Request.URL.Path,
+				RequestID: id,
+				Status:    http.StatusInternalServerError,
+			})
+			c.Set(ErrorReportedKey, true)
+
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "Internal server error",
+				Details: "An unexpected error occurred; quote request ID " + id + " when reporting it",
+			})
+		}()
+		c.Next()
+	}
+}
+
+// brokenPipe reports whether a panic was caused by the client closing the
+// connection, in which case no response can be written
+func brokenPipe(err interface{}) bool {
+	e, ok := err.(error)
+	if !ok {
+		return false
+	}
+	var opErr *net.OpError
+	if !errors.As(e, &opErr) {
+		return false
+	}
+	var sysErr *os.SyscallError
+	if errors.As(opErr, &sysErr) {
+		if errors.Is(sysErr.Err, syscall.EPIPE) || errors.Is(sysErr.Err, syscall.ECONNRESET) {
+			return true
+		}
+	}
+	msg := strings.ToLower(opErr.Error())
+	return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+}
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cloud-ai-api/metrics"
+	"cloud-ai-api/models"
+	"cloud-ai-api/stats"
+	"github.com/gin-gonic/gin"
+)
+
+// shedInterval is how often the shedder re-evaluates load
+const shedInterval = time.Second
+
+// shedRecovery is how much the shed fraction falls per interval once load
+// is back under the thresholds, so traffic returns gradually
+const shedRecovery = 0.05
+
+// shedLatencyKey is the single key latencies are recorded under
+const shedLatencyKey = "all"
+
+// LoadShedding sets when the gateway is overloaded. MaxQueueDepth bounds
+// the requests in flight (being served or waiting in a queue) and MaxP99
+// the p99 latency over the last 30 seconds; a zero value ignores that
+// signal. MaxFraction caps the share of requests shed.
+type LoadShedding struct {
+	MaxQueueDepth int
+	MaxP99        time.Duration
+	MaxFraction   float64
+}
+
+// LoadSheddingStatus reports the shedder's current load and decisions
+type LoadSheddingStatus struct {
+	Fraction float64 `json:"shed_fraction"`
+	InFlight int64   `json:"in_flight"`
+	P99Ms    float64 `json:"p99_ms"`
+	Admitted int64   `json:"admitted"`
+	Shed     int64   `json:"shed"`
+}
+
+// LoadShedder rejects a fraction of requests while the gateway is
+// overloaded, growing the fraction with the load so the requests it admits
+// are still served in time
+type LoadShedder struct {
+	limits    LoadShedding
+	latencies *stats.Recorder
+	inFlight  int64
+	admitted  int64
+	shed      int64
+
+	mu       sync.Mutex
+	fraction float64
+	p99      float64
+}
+
+// NewLoadShedder creates a shedder and starts evaluating load every second
+func NewLoadShedder(limits LoadShedding) *LoadShedder {
+	s := &LoadShedder{limits: limits, latencies: stats.NewRecorder(30 * time.Second)}
+	go func() {
+		for range time.Tick(shedInterval) {
+			s.evaluate()
+		}
+	}()
+	return s
+}
+
+// evaluate sets the shed fraction from the current load. Over a threshold,
+// it sheds enough to bring the load back to it at once; under, it recovers
+// by shedRecovery per interval.
+func (s *LoadShedder) evaluate() {
+	p99 := s.latencies.Snapshot()[shedLatencyKey].P99Ms
+
+	pressure := 0.0
+	if s.limits.MaxQueueDepth > 0 {
+		pressure = float64(atomic.LoadInt64(&s.inFlight)) / float64(s.limits.MaxQueueDepth)
+	}
+	if s.limits.MaxP99 > 0 {
+		pressure = math.Max(pressure, p99/float64(s.limits.MaxP99.Milliseconds()))
+	}
+	target := 0.0
+	if pressure > 1 {
+		target = math.Min(1-1/pressure, s.limits.MaxFraction)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.p99 = p99
+	if target > s.fraction {
+		s.fraction = target
+	} else {
+		s.fraction = math.Max(target, s.fraction-shedRecovery)
+	}
+}
+
+// Fraction returns the share of requests currently shed
+func (s *LoadShedder) Fraction() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fraction
+}
+
+// Status reports the current load, shed fraction and totals
+func (s *LoadShedder) Status() LoadSheddingStatus {
+	s.mu.Lock()
+	fraction, p99 := s.fraction, s.p99
+	s.mu.Unlock()
+	return LoadSheddingStatus{
+		Fraction: fraction,
+		InFlight: atomic.LoadInt64(&s.inFlight),
+		P99Ms:    p99,
+		Admitted: atomic.LoadInt64(&s.admitted),
+		Shed:     atomic.LoadInt64(&s.shed),
+	}
+}
+
+// LoadSheddingMiddleware rejects the shedder's current fraction of requests
+// with 503 Service Unavailable and a Retry-After that grows with the
+// fraction. The service info, health, readiness and admin routes are never
+// shed.
+func LoadSheddingMiddleware(s *LoadShedder, emitter metrics.Emitter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maintenanceExempt(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		if fraction := s.Fraction(); fraction > 0 && rand.Float64() < fraction {
+			atomic.AddInt64(&s.shed, 1)
+			if route := c.FullPath(); route != "" {
+				emitter.Incr("load.shed", "route:"+route)
+			} else {
+				emitter.Incr("load.shed")
+			}
+			c.Header("Retry-After", strconv.Itoa(1+int(fraction*10)))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, models.ErrorResponse{
+				Error:   "Service overloaded",
+				Details: "The gateway is shedding load; retry after the time in the Retry-After header",
+			})
+			return
+		}
+
+		atomic.AddInt64(&s.admitted, 1)
+		emitter.Incr("load.admitted")
+		atomic.AddInt64(&s.inFlight, 1)
+		start := time.Now()
+		defer func() {
+			atomic.AddInt64(&s.inFlight, -1)
+			s.latencies.Observe(shedLatencyKey, time.Since(start), false)
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"log"
+
+	"cloud-ai-api/signing"
+	"github.com/gin-gonic/gin"
+)
+
+// SignatureHeader carries the detached JWS of a signed response body
+const SignatureHeader = "X-Signature"
+
+// SignatureMiddleware signs response bodies, adding their detached JWS in
+// the X-Signature header so consumers that store responses can prove they
+// were not altered. The body is held back until the handler finishes;
+// streamed responses, which flush early, are sent unsigned. A nil signer
+// signs nothing.
+func SignatureMiddleware(s *signing.Signer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s == nil {
+			c.Next()
+			return
+		}
+		holdBody(c, func(body []byte) {
+			sig, err := s.Sign(body)
+			if err != nil {
+				log.Printf("WARN response_signing_failed path=%s error=%q", c.Request.URL.Path, err)
+				return
+			}
+			c.Header(SignatureHeader, sig)
+		})
+	}
+}
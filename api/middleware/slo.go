@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"time"
+
+	"cloud-ai-api/slo"
+	"github.com/gin-gonic/gin"
+)
+
+// SLOMiddleware counts every request to a registered route against the
+// service level objectives covering it
+func SLOMiddleware(t *slo.Tracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			return
+		}
+		t.Observe(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
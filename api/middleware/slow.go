@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cloud-ai-api/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// PayloadFingerprintKey is the gin context key holding a fingerprint of the
+// request payload, set by prediction handlers
+const PayloadFingerprintKey = "payload_fingerprint"
+
+// AnomalyScoreKey is the gin context key holding a request's anomaly score,
+// set by prediction handlers when it is above zero
+const AnomalyScoreKey = "anomaly_score"
+
+// SlowRequestMiddleware logs a WARN line and increments the request.slow
+// counter for requests taking at least threshold, including the payload
+// fingerprint so slow inputs can be found and replayed
+func SlowRequestMiddleware(threshold time.Duration, emitter metrics.Emitter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		elapsed := time.Since(start)
+		if elapsed < threshold {
+			return
+		}
+
+		route := c.FullPath()
+		log.Printf("WARN slow_request request_id=%s method=%s route=%s path=%s status=%d duration_ms=%d threshold_ms=%d model=%s fingerprint=%s",
+			RequestID(c), c.Request.Method, route, c.Request.URL.Path, c.Writer.Status(),
+			elapsed.Milliseconds(), threshold.Milliseconds(), c.Param("model"), c.GetString(PayloadFingerprintKey))
+
+		if route != "" {
+			tags := []string{"method:" + c.Request.Method, "route:" + route}
+			if model := c.Param("model"); model != "" && c.Writer.Status() != http.StatusNotFound {
+				tags = append(tags, "model:"+model)
+			}
+			emitter.Incr("request.slow", append(tags, "status:"+strconv.Itoa(c.Writer.Status()))...)
+		}
+	}
+}
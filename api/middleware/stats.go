@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"cloud-ai-api/metrics"
+	"cloud-ai-api/stats"
+	"github.com/gin-gonic/gin"
+)
+
+// StatsMiddleware records the latency and outcome of every request to a
+// registered route, keyed by method and route pattern. Server errors (5xx)
+// count as errors; requests that match no route are not recorded.
+func StatsMiddleware(rec *stats.Recorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			return
+		}
+		rec.Observe(c.Request.Method+" "+route, time.Since(start), c.Writer.Status() >= 500)
+	}
+}
+
+// MetricsMiddleware pushes a request timing and counter for every request to
+// a registered route, tagged with the method, route pattern and status, and
+// the model for prediction routes. Unknown models (404) are not tagged so
+// callers cannot create unbounded tag values.
+func MetricsMiddleware(emitter metrics.Emitter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			return
+		}
+		status := c.Writer.Status()
+		tags := []string{"method:" + c.Request.Method, "route:" + route, "status:" + strconv.Itoa(status)}
+		if model := c.Param("model"); model != "" && status != http.StatusNotFound {
+			tags = append(tags, "model:"+model)
+		}
+		emitter.Timing("request.duration", time.Since(start), tags...)
+		emitter.Incr("request.count", tags...)
+	}
+}
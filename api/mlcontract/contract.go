@@ -0,0 +1,180 @@
+package mlcontract
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+//go:embed default.json
+var defaultSuite []byte
+
+// Value types a field check can require
+const (
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeInteger = "integer"
+	TypeBoolean = "boolean"
+	TypeObject  = "object"
+	TypeArray   = "array"
+)
+
+// Suite is a set of canonical requests to the ML service and what their
+// responses must look like
+type Suite struct {
+	Cases []Case `json:"cases"`
+}
+
+// Case is one request and the response expected for it. Method defaults to
+// POST, or GET when there is no payload. Warmup requests are sent first
+// without being checked or timed, for endpoints that load a model lazily.
+type Case struct {
+	Name    string                 `json:"name"`
+	Method  string                 `json:"method,omitempty"`
+	Path    string                 `json:"path"`
+	Payload map[string]interface{} `json:"payload,omitempty"`
+	Warmup  int                    `json:"warmup,omitempty"`
+	Expect  Expect                 `json:"expect"`
+}
+
+// Expect describes a valid response. Status defaults to 200. Fields lists
+// the top-level response fields that must be present, with their types and
+// ranges. Ordered names numeric fields whose values must not decrease, such
+// as a confidence interval around its estimate. MaxLatencyMs is the latency
+// budget; 0 means none.
+type Expect struct {
+	Status       int                   `json:"status,omitempty"`
+	Fields       map[string]FieldCheck `json:"fields,omitempty"`
+	Ordered      []string              `json:"ordered,omitempty"`
+	MaxLatencyMs float64               `json:"max_latency_ms,omitempty"`
+}
+
+// FieldCheck is what one response field must hold. ExclusiveMin is a lower
+// bound the value must be above, such as 0 for a price. Optional fields are
+// only checked when present.
+type FieldCheck struct {
+	Type         string   `json:"type"`
+	Min          *float64 `json:"min,omitempty"`
+	ExclusiveMin *float64 `json:"exclusive_min,omitempty"`
+	Max          *float64 `json:"max,omitempty"`
+	Enum         []string `json:"enum,omitempty"`
+	Optional     bool     `json:"optional,omitempty"`
+}
+
+// ResponseSchema is what every successful response from a model's ML
+// endpoint must hold: its fields, with their types and ranges, and the
+// numeric fields whose values must not decrease, as in Expect
+type ResponseSchema struct {
+	Fields  map[string]FieldCheck `json:"fields"`
+	Ordered []string              `json:"ordered,omitempty"`
+}
+
+// Check verifies a response schema declaration
+func (s *ResponseSchema) Check() error {
+	if len(s.Fields) == 0 {
+		return fmt.Errorf("fields is required")
+	}
+	return checkFields(s.Fields, s.Ordered)
+}
+
+// Default returns the bundled suite for the built-in models
+func Default() *Suite {
+	suite, err := Parse(defaultSuite)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in ML contract suite: %s", err))
+	}
+	return suite
+}
+
+// Load reads a suite from a JSON file
+func Load(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ML contract suite: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse decodes and checks a suite
+func Parse(data []byte) (*Suite, error) {
+	var suite Suite
+	if err := json.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("invalid ML contract suite: %w", err)
+	}
+	if len(suite.Cases) == 0 {
+		return nil, fmt.Errorf("ML contract suite has no cases")
+	}
+	seen := make(map[string]bool)
+	for i := range suite.Cases {
+		c := &suite.Cases[i]
+		if c.Name == "" {
+			return nil, fmt.Errorf("case %d: name is required", i+1)
+		}
+		if seen[c.Name] {
+			return nil, fmt.Errorf("case %q declared more than once", c.Name)
+		}
+		seen[c.Name] = true
+		if err := c.check(); err != nil {
+			return nil, fmt.Errorf("case %q: %w", c.Name, err)
+		}
+	}
+	return &suite, nil
+}
+
+// check verifies a case and fills in its defaults
+func (c *Case) check() error {
+	if !strings.HasPrefix(c.Path, "/") {
+		return fmt.Errorf("path must start with '/'")
+	}
+	c.Method = strings.ToUpper(c.Method)
+	switch {
+	case c.Method == "" && c.Payload == nil:
+		c.Method = http.MethodGet
+	case c.Method == "":
+		c.Method = http.MethodPost
+	case c.Method != http.MethodGet && c.Method != http.MethodPost:
+		return fmt.Errorf("method must be GET or POST")
+	}
+	if c.Warmup < 0 {
+		return fmt.Errorf("warmup must not be negative")
+	}
+	if c.Expect.Status == 0 {
+		c.Expect.Status = http.StatusOK
+	}
+	if c.Expect.MaxLatencyMs < 0 {
+		return fmt.Errorf("max_latency_ms must not be negative")
+	}
+	return checkFields(c.Expect.Fields, c.Expect.Ordered)
+}
+
+// checkFields verifies field checks and the ordered fields among them
+func checkFields(fields map[string]FieldCheck, ordered []string) error {
+	for name, f := range fields {
+		switch f.Type {
+		case TypeString, TypeNumber, TypeInteger, TypeBoolean, TypeObject, TypeArray:
+		default:
+			return fmt.Errorf("field %q: unknown type %q", name, f.Type)
+		}
+		if (f.Min != nil || f.ExclusiveMin != nil || f.Max != nil) && f.Type != TypeNumber && f.Type != TypeInteger {
+			return fmt.Errorf("field %q: min, exclusive_min and max apply to numbers only", name)
+		}
+		if f.Min != nil && f.ExclusiveMin != nil {
+			return fmt.Errorf("field %q: declare either min or exclusive_min, not both", name)
+		}
+		if f.Min != nil && f.Max != nil && *f.Min > *f.Max {
+			return fmt.Errorf("field %q: min is greater than max", name)
+		}
+		if len(f.Enum) > 0 && f.Type != TypeString {
+			return fmt.Errorf("field %q: enum applies to strings only", name)
+		}
+	}
+	for _, name := range ordered {
+		if f, ok := fields[name]; !ok || (f.Type != TypeNumber && f.Type != TypeInteger) {
+			return fmt.Errorf("ordered field %q must be declared as a number", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package mlcontract
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxResponseBytes caps the response body read for each case
+const maxResponseBytes = 1 << 20
+
+// Result is the outcome of one case
+type Result struct {
+	Name         string   `json:"name"`
+	Method       string   `json:"method"`
+	Path         string   `json:"path"`
+	Passed       bool     `json:"passed"`
+	StatusCode   int      `json:"status_code,omitempty"`
+	LatencyMs    float64  `json:"latency_ms"`
+	MaxLatencyMs float64  `json:"max_latency_ms,omitempty"`
+	Failures     []string `json:"failures,omitempty"`
+}
+
+// Report is the outcome of running a suite against an ML service
+type Report struct {
+	Target     string    `json:"target"`
+	Passed     bool      `json:"passed"`
+	Total      int       `json:"total"`
+	Failed     int       `json:"failed"`
+	DurationMs float64   `json:"duration_ms"`
+	StartedAt  time.Time `json:"started_at"`
+	Cases      []Result  `json:"cases"`
+}
+
+// Verify sends each case of the suite to the ML service at base, one at a
+// time so latencies are not skewed by each other, and checks the responses
+func Verify(ctx context.Context, client *http.Client, base string, suite *Suite) Report {
+	base = strings.TrimSuffix(base, "/")
+	rep := Report{Target: base, StartedAt: time.Now(), Cases: make([]Result, 0, len(suite.Cases))}
+	for _, c := range suite.Cases {
+		res := run(ctx, client, base, c)
+		if !res.Passed {
+			rep.Failed++
+		}
+		rep.Cases = append(rep.Cases, res)
+	}
+	rep.Total = len(rep.Cases)
+	rep.Passed = rep.Failed == 0
+	rep.DurationMs = float64(time.Since(rep.StartedAt).Microseconds()) / 1000
+	return rep
+}
+
+// run sends one case, after its warmup requests, and checks the response
+func run(ctx context.Context, client *http.Client, base string, c Case) Result {
+	res := Result{Name: c.Name, Method: c.Method, Path: c.Path, MaxLatencyMs: c.Expect.MaxLatencyMs}
+	for i := 0; i < c.Warmup; i++ {
+		if _, _, _, err := send(ctx, client, base, c); err != nil {
+			break
+		}
+	}
+
+	status, body, latency, err := send(ctx, client, base, c)
+	res.LatencyMs = float64(latency.Microseconds()) / 1000
+	if err != nil {
+		res.Failures = []string{err.Error()}
+		return res
+	}
+	res.StatusCode = status
+	res.Failures = check(c.Expect, status, body, res.LatencyMs)
+	res.Passed = len(res.Failures) == 0
+	return res
+}
+
+// send makes a case's request and returns the response status, body and
+// latency
+func send(ctx context.Context, client *http.Client, base string, c Case) (int, []byte, time.Duration, error) {
+	var reqBody io.Reader
+	if c.Payload != nil {
+		data, err := json.Marshal(c.Payload)
+		if err != nil {
+			return 0, nil, 0, err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, c.Method, base+c.Path, reqBody)
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, time.Since(start), err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	latency := time.Since(start)
+	if err != nil {
+		return resp.StatusCode, nil, latency, fmt.Errorf("failed to read response: %w", err)
+	}
+	return resp.StatusCode, body, latency, nil
+}
+
+// check compares a response with what a case expects and describes every
+// difference
+func check(want Expect, status int, body []byte, latencyMs float64) []string {
+	var failures []string
+	if status != want.Status {
+		failures = append(failures, fmt.Sprintf("status %d, want %d", status, want.Status))
+	}
+	if want.MaxLatencyMs > 0 && latencyMs > want.MaxLatencyMs {
+		failures = append(failures, fmt.Sprintf("latency %.1fms over budget of %.0fms", latencyMs, want.MaxLatencyMs))
+	}
+	if len(want.Fields) == 0 {
+		return failures
+	}
+
+	var resp map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&resp); err != nil || resp == nil {
+		return append(failures, "response is not a JSON object")
+	}
+	for _, f := range checkResponse(want.Fields, want.Ordered, resp) {
+		failures = append(failures, f.String())
+	}
+	return failures
+}
+
+// Failure is one way a response breaks its field checks. Field is empty
+// for failures that are not about one field.
+type Failure struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (f Failure) String() string {
+	if f.Field == "" {
+		return f.Message
+	}
+	return f.Field + ": " + f.Message
+}
+
+// Validate checks a decoded response against the schema and describes
+// every way it breaks it
+func (s *ResponseSchema) Validate(resp map[string]interface{}) []Failure {
+	return checkResponse(s.Fields, s.Ordered, resp)
+}
+
+// checkResponse checks a decoded response's fields and the order of the
+// ordered ones
+func checkResponse(fields map[string]FieldCheck, ordered []string, resp map[string]interface{}) []Failure {
+	var failures []Failure
+	for _, name := range sortedKeys(fields) {
+		f := fields[name]
+		value, present := resp[name]
+		if !present {
+			if !f.Optional {
+				failures = append(failures, Failure{name, "missing"})
+			}
+			continue
+		}
+		if msg := f.check(value); msg != "" {
+			failures = append(failures, Failure{name, msg})
+		}
+	}
+	for i := 1; i < len(ordered); i++ {
+		lo, hi := ordered[i-1], ordered[i]
+		a, aok := number(resp[lo])
+		b, bok := number(resp[hi])
+		if aok && bok && a > b {
+			failures = append(failures, Failure{Message: fmt.Sprintf("%s (%g) is greater than %s (%g)", lo, a, hi, b)})
+		}
+	}
+	return failures
+}
+
+// check reports how a value breaks the field check, or "" if it does not
+func (f FieldCheck) check(value interface{}) string {
+	switch f.Type {
+	case TypeString:
+		s, ok := value.(string)
+		if !ok {
+			return "want a string"
+		}
+		if len(f.Enum) > 0 && !contains(f.Enum, s) {
+			return fmt.Sprintf("%q is not one of %s", s, strings.Join(f.Enum, ", "))
+		}
+	case TypeNumber, TypeInteger:
+		n, ok := number(value)
+		if !ok {
+			return "want a number"
+		}
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return "want a finite number"
+		}
+		if f.Type == TypeInteger && n != math.Trunc(n) {
+			return fmt.Sprintf("%g is not an integer", n)
+		}
+		if f.Min != nil && n < *f.Min {
+			return fmt.Sprintf("%g is below the minimum of %g", n, *f.Min)
+		}
+		if f.ExclusiveMin != nil && n <= *f.ExclusiveMin {
+			return fmt.Sprintf("%g is not above %g", n, *f.ExclusiveMin)
+		}
+		if f.Max != nil && n > *f.Max {
+			return fmt.Sprintf("%g is above the maximum of %g", n, *f.Max)
+		}
+	case TypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return "want a boolean"
+		}
+	case TypeObject:
+		if _, ok := value.(map[string]interface{}); !ok {
+			return "want an object"
+		}
+	case TypeArray:
+		if _, ok := value.([]interface{}); !ok {
+			return "want an array"
+		}
+	}
+	return ""
+}
+
+// number reads a decoded JSON number, whether decoded as json.Number or
+// float64
+func number(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(fields map[string]FieldCheck) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WriteText writes a report for people to read
+func WriteText(w io.Writer, rep Report) {
+	fmt.Fprintf(w, "ML service: %s\n", rep.Target)
+	for _, res := range rep.Cases {
+		verdict := "PASS"
+		if !res.Passed {
+			verdict = "FAIL"
+		}
+		fmt.Fprintf(w, "%s  %-32s %-4s %-24s %4d %8.1fms\n", verdict, res.Name, res.Method, res.Path, res.StatusCode, res.LatencyMs)
+		for _, f := range res.Failures {
+			fmt.Fprintf(w, "        - %s\n", f)
+		}
+	}
+	verdict := "PASSED"
+	if !rep.Passed {
+		verdict = "FAILED"
+	}
+	fmt.Fprintf(w, "%s: %d of %d cases passed in %.0fms\n", verdict, rep.Total-rep.Failed, rep.Total, rep.DurationMs)
+}
@@ -0,0 +1,133 @@
+package mlroute
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Route sends a caller's predictions to a dedicated ML backend or model
+// version. URL overrides the ML service URL; Models overrides ml_path by
+// model name, e.g. to a customer's own regional model. Models not listed
+// keep their ml_path.
+type Route struct {
+	URL    string            `json:"url,omitempty"`
+	Models map[string]string `json:"models,omitempty"`
+
+	// ID names the route in cache keys, logs and metrics: "tenant:<id>" or
+	// "key:" and a hash of the API key
+	ID string `json:"-"`
+}
+
+// MLPath returns the path to call for a model whose ml_path is path
+func (r *Route) MLPath(model, path string) string {
+	if p, ok := r.Models[model]; ok {
+		return p
+	}
+	return path
+}
+
+// Table holds the routes by tenant (X-Tenant-ID) and API key (X-API-Key).
+// An API key's route wins over its tenant's.
+type Table struct {
+	Tenants map[string]*Route `json:"tenants,omitempty"`
+	Keys    map[string]*Route `json:"keys,omitempty"`
+}
+
+// Load reads a routing table from a JSON file holding
+// {"tenants": {"<tenant>": {...}}, "keys": {"<api-key>": {...}}}
+func Load(path string) (*Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ML routes file: %w", err)
+	}
+	var t Table
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("failed to parse ML routes file: %w", err)
+	}
+	for tenant, r := range t.Tenants {
+		if err := r.check(fmt.Sprintf("tenant %q", tenant)); err != nil {
+			return nil, err
+		}
+		r.ID = "tenant:" + tenant
+	}
+	for key, r := range t.Keys {
+		id := "key:" + KeyHash(key)
+		if err := r.check(id); err != nil {
+			return nil, err
+		}
+		r.ID = id
+	}
+	return &t, nil
+}
+
+func (r *Route) check(name string) error {
+	if r == nil || (r.URL == "" && len(r.Models) == 0) {
+		return fmt.Errorf("%s: url or models is required", name)
+	}
+	if r.URL != "" {
+		if u, err := url.Parse(r.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%s: url must be an http(s) URL", name)
+		}
+		r.URL = strings.TrimRight(r.URL, "/")
+	}
+	for model, path := range r.Models {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("%s: model %q: ml_path must start with '/'", name, model)
+		}
+	}
+	return nil
+}
+
+// Lookup returns the route for a caller, if any; either may be empty
+func (t *Table) Lookup(tenant, apiKey string) (*Route, bool) {
+	if t == nil {
+		return nil, false
+	}
+	if r, ok := t.Keys[apiKey]; ok && apiKey != "" {
+		return r, true
+	}
+	if r, ok := t.Tenants[tenant]; ok && tenant != "" {
+		return r, true
+	}
+	return nil, false
+}
+
+// Models lists every model name the table overrides ml_path for
+func (t *Table) Models() []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, routes := range []map[string]*Route{t.Tenants, t.Keys} {
+		for _, r := range routes {
+			for model := range r.Models {
+				if !seen[model] {
+					seen[model] = true
+					names = append(names, model)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// HasURL reports whether any route overrides the ML service URL
+func (t *Table) HasURL() bool {
+	for _, routes := range []map[string]*Route{t.Tenants, t.Keys} {
+		for _, r := range routes {
+			if r.URL != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// KeyHash identifies an API key without revealing it
+func KeyHash(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])[:12]
+}
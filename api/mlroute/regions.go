@@ -0,0 +1,142 @@
+package mlroute
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"cloud-ai-api/discovery"
+)
+
+// Region is a set of ML backends in one location, used in round-robin
+// order. Failover lists the regions to try, in order, when the region's
+// call fails; empty tries every other region in name order.
+type Region struct {
+	Backends []string `json:"backends"`
+	Failover []string `json:"failover,omitempty"`
+
+	pool *discovery.Pool
+}
+
+// Regions assigns callers to ML regions: by tenant (X-Tenant-ID), then by
+// country code, falling back to Default
+type Regions struct {
+	Default   string             `json:"default"`
+	Tenants   map[string]string  `json:"tenants,omitempty"`
+	Countries map[string]string  `json:"countries,omitempty"`
+	Regions   map[string]*Region `json:"regions"`
+}
+
+// LoadRegions reads ML regions from a JSON file holding
+// {"default": "<region>", "regions": {"<region>": {"backends": [...]}}}
+// and optional "tenants" and "countries" maps of region names
+func LoadRegions(path string) (*Regions, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ML regions file: %w", err)
+	}
+	var g Regions
+	if err := json.Unmarshal(data, &g); err != nil {
+		return nil, fmt.Errorf("failed to parse ML regions file: %w", err)
+	}
+	if len(g.Regions) == 0 {
+		return nil, fmt.Errorf("at least one region is required")
+	}
+	if _, ok := g.Regions[g.Default]; !ok {
+		return nil, fmt.Errorf("default: unknown region %q", g.Default)
+	}
+	for name, r := range g.Regions {
+		if r == nil || len(r.Backends) == 0 {
+			return nil, fmt.Errorf("region %q: backends is required", name)
+		}
+		raw, _ := json.Marshal(r.Backends)
+		backends, err := discovery.ParseBackends(raw)
+		if err != nil {
+			return nil, fmt.Errorf("region %q: %w", name, err)
+		}
+		for _, f := range r.Failover {
+			if _, ok := g.Regions[f]; !ok || f == name {
+				return nil, fmt.Errorf("region %q: failover: unknown region %q", name, f)
+			}
+		}
+		r.Backends = backends
+		r.pool = discovery.NewPool(backends...)
+	}
+	for tenant, name := range g.Tenants {
+		if _, ok := g.Regions[name]; !ok {
+			return nil, fmt.Errorf("tenant %q: unknown region %q", tenant, name)
+		}
+	}
+	countries := make(map[string]string, len(g.Countries))
+	for country, name := range g.Countries {
+		if _, ok := g.Regions[name]; !ok {
+			return nil, fmt.Errorf("country %q: unknown region %q", country, name)
+		}
+		countries[strings.ToUpper(country)] = name
+	}
+	g.Countries = countries
+	return &g, nil
+}
+
+// Has reports whether a region exists
+func (g *Regions) Has(name string) bool {
+	_, ok := g.Regions[name]
+	return ok
+}
+
+// Select returns the region for a caller: the designated region if it
+// exists, then the tenant's, then the country's, then the default. Any of
+// them may be empty.
+func (g *Regions) Select(designated, tenant, country string) string {
+	if g.Has(designated) {
+		return designated
+	}
+	if name, ok := g.Tenants[tenant]; ok && tenant != "" {
+		return name
+	}
+	if name, ok := g.Countries[strings.ToUpper(country)]; ok && country != "" {
+		return name
+	}
+	return g.Default
+}
+
+// Order returns the regions to try for a call to name: name first, then
+// its failover regions
+func (g *Regions) Order(name string) []string {
+	r, ok := g.Regions[name]
+	if !ok {
+		return nil
+	}
+	if len(r.Failover) > 0 {
+		return append([]string{name}, r.Failover...)
+	}
+	order := []string{name}
+	for _, other := range g.Names() {
+		if other != name {
+			order = append(order, other)
+		}
+	}
+	return order
+}
+
+// Pick returns the backend in a region for an affinity key, or the next
+// one for an empty key; see discovery.Pool.Pick
+func (g *Regions) Pick(name, key string) (string, error) {
+	r, ok := g.Regions[name]
+	if !ok {
+		return "", discovery.ErrNoBackends
+	}
+	return r.pool.Pick(key)
+}
+
+// Names lists the regions, sorted
+func (g *Regions) Names() []string {
+	names := make([]string, 0, len(g.Regions))
+	for name := range g.Regions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,104 @@
+package mltransport
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+
+	"golang.org/x/net/http2"
+)
+
+// errNoHTTP2 is returned when an https:// backend does not negotiate HTTP/2
+var errNoHTTP2 = errors.New("ML service did not negotiate HTTP/2")
+
+// connPool is an HTTP/2 connection pool that spreads requests over as
+// many connections per backend as keep each under maxStreams concurrent
+// streams
+type connPool struct {
+	t          *http2.Transport
+	maxStreams int
+	dial       func(ctx context.Context, scheme, addr string) (net.Conn, error)
+
+	mu    sync.Mutex
+	conns map[string][]*http2.ClientConn
+}
+
+func newConnPool(t *http2.Transport, maxStreams int, dial func(ctx context.Context, scheme, addr string) (net.Conn, error)) *connPool {
+	return &connPool{t: t, maxStreams: maxStreams, dial: dial, conns: make(map[string][]*http2.ClientConn)}
+}
+
+// GetClientConn returns a connection to addr with room for another stream,
+// reserving the stream, and dials a new one if none has room. Dials are
+// serialized so a burst of requests opens one connection at a time.
+func (p *connPool) GetClientConn(req *http.Request, addr string) (*http2.ClientConn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, cc := range p.conns[addr] {
+		if p.hasRoom(cc) && cc.ReserveNewRequest() {
+			return cc, nil
+		}
+	}
+
+	conn, err := p.dial(req.Context(), req.URL.Scheme, addr)
+	if err != nil {
+		return nil, err
+	}
+	cc, err := p.t.NewClientConn(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !cc.ReserveNewRequest() {
+		cc.Close()
+		return nil, http2.ErrNoCachedConn
+	}
+	p.conns[addr] = append(p.conns[addr], cc)
+	return cc, nil
+}
+
+// hasRoom reports whether a connection is under the stream cap
+func (p *connPool) hasRoom(cc *http2.ClientConn) bool {
+	if p.maxStreams <= 0 {
+		return true
+	}
+	st := cc.State()
+	return st.StreamsActive+st.StreamsReserved+st.StreamsPending < p.maxStreams
+}
+
+// MarkDead removes a connection that can no longer be used
+func (p *connPool) MarkDead(cc *http2.ClientConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for addr, conns := range p.conns {
+		for i, c := range conns {
+			if c != cc {
+				continue
+			}
+			conns = append(conns[:i], conns[i+1:]...)
+			if len(conns) == 0 {
+				delete(p.conns, addr)
+			} else {
+				p.conns[addr] = conns
+			}
+			return
+		}
+	}
+}
+
+// closeIdle shuts down connections with no active or reserved streams
+func (p *connPool) closeIdle() {
+	p.mu.Lock()
+	conns := make([]*http2.ClientConn, 0)
+	for _, list := range p.conns {
+		conns = append(conns, list...)
+	}
+	p.mu.Unlock()
+	for _, cc := range conns {
+		if st := cc.State(); st.StreamsActive == 0 && st.StreamsReserved == 0 {
+			cc.Shutdown(context.Background())
+		}
+	}
+}
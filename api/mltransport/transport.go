@@ -0,0 +1,172 @@
+package mltransport
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+	"time"
+
+	"cloud-ai-api/metrics"
+	"golang.org/x/net/http2"
+)
+
+// dialTimeout bounds establishing a connection to the ML service
+const dialTimeout = 5 * time.Second
+
+// Options tunes connections to the ML service. With HTTP2 set, plain
+// http:// backends are spoken to over h2c (HTTP/2 with prior knowledge)
+// and https:// backends must negotiate h2. IdleTimeout closes connections
+// unused for that long; MaxIdleConns bounds idle HTTP/1.1 connections kept
+// per backend. MaxStreams caps concurrent requests per HTTP/2 connection,
+// opening further connections beyond it; 0 uses the server's limit.
+// Socket, if set, is a Unix socket every connection is dialed to in place
+// of the URL's host, for an ML service in the same pod.
+type Options struct {
+	HTTP2        bool
+	Socket       string
+	IdleTimeout  time.Duration
+	MaxIdleConns int
+	MaxStreams   int
+}
+
+// Stats reports connection pool activity since startup
+type Stats struct {
+	Protocol string `json:"protocol"`
+	Open     int64  `json:"open_connections"`
+	Dialed   int64  `json:"dialed"`
+	Requests int64  `json:"requests"`
+	Reused   int64  `json:"reused"`
+}
+
+// Transport is an http.RoundTripper for ML service calls that counts
+// connections and reports whether each request reused one
+type Transport struct {
+	base     http.RoundTripper
+	pool     *connPool
+	protocol string
+	emitter  metrics.Emitter
+
+	open     int64
+	dialed   int64
+	requests int64
+	reused   int64
+}
+
+// New creates a transport with the given options, reporting connection
+// reuse to emitter
+func New(opts Options, emitter metrics.Emitter) *Transport {
+	t := &Transport{protocol: "http/1.1", emitter: emitter}
+	dialer := &net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if opts.Socket != "" {
+			network, addr = "unix", opts.Socket
+		}
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return t.track(conn), nil
+	}
+
+	if !opts.HTTP2 {
+		t.base = &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			DialContext:         dial,
+			MaxIdleConns:        0,
+			MaxIdleConnsPerHost: opts.MaxIdleConns,
+			IdleConnTimeout:     opts.IdleTimeout,
+			TLSHandshakeTimeout: dialTimeout,
+		}
+		return t
+	}
+
+	t.protocol = "h2"
+	h2 := &http2.Transport{
+		AllowHTTP:       true,
+		IdleConnTimeout: opts.IdleTimeout,
+		ReadIdleTimeout: 30 * time.Second,
+	}
+	t.pool = newConnPool(h2, opts.MaxStreams, func(ctx context.Context, scheme, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, "tcp", addr)
+		if err != nil || scheme != "https" {
+			return conn, err
+		}
+		host, _, _ := net.SplitHostPort(addr)
+		tc := tls.Client(conn, &tls.Config{ServerName: host, NextProtos: []string{http2.NextProtoTLS}})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if tc.ConnectionState().NegotiatedProtocol != http2.NextProtoTLS {
+			tc.Close()
+			return nil, errNoHTTP2
+		}
+		return tc, nil
+	})
+	h2.ConnPool = t.pool
+	t.base = h2
+	return t
+}
+
+// RoundTrip sends the request, counting whether it reused a connection
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			atomic.AddInt64(&t.requests, 1)
+			reused := "false"
+			if info.Reused {
+				atomic.AddInt64(&t.reused, 1)
+				reused = "true"
+			}
+			t.emitter.Incr("ml.conn.requests", "protocol:"+t.protocol, "reused:"+reused)
+		},
+	}
+	return t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
+
+// CloseIdleConnections closes connections not carrying a request
+func (t *Transport) CloseIdleConnections() {
+	if t.pool != nil {
+		t.pool.closeIdle()
+		return
+	}
+	if c, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// Stats returns the transport's connection counts
+func (t *Transport) Stats() Stats {
+	return Stats{
+		Protocol: t.protocol,
+		Open:     atomic.LoadInt64(&t.open),
+		Dialed:   atomic.LoadInt64(&t.dialed),
+		Requests: atomic.LoadInt64(&t.requests),
+		Reused:   atomic.LoadInt64(&t.reused),
+	}
+}
+
+// track counts a new connection until it is closed
+func (t *Transport) track(conn net.Conn) net.Conn {
+	atomic.AddInt64(&t.dialed, 1)
+	atomic.AddInt64(&t.open, 1)
+	t.emitter.Incr("ml.conn.dialed", "protocol:"+t.protocol)
+	return &trackedConn{Conn: conn, open: &t.open}
+}
+
+// trackedConn decrements the open connection count once when closed
+type trackedConn struct {
+	net.Conn
+	open   *int64
+	closed int32
+}
+
+func (c *trackedConn) Close() error {
+	if atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		atomic.AddInt64(c.open, -1)
+	}
+	return c.Conn.Close()
+}
@@ -1,5 +1,12 @@
 package models
 
+import (
+	"cloud-ai-api/health"
+	"cloud-ai-api/ipfilter"
+	"cloud-ai-api/plausibility"
+	"cloud-ai-api/stats"
+)
+
 // HousingPredictionRequest represents the request for housing price prediction
 type HousingPredictionRequest struct {
 	PropertyType string `json:"property_type" binding:"required"`
@@ -12,42 +19,606 @@ type HousingPredictionRequest struct {
 
 // HousingPredictionResponse represents the response from housing price prediction
 type HousingPredictionResponse struct {
-	Price             float64 `json:"price"`
-	PriceLog          float64 `json:"price_log"`
-	ConfidenceLower   float64 `json:"confidence_lower"`
-	ConfidenceUpper   float64 `json:"confidence_upper"`
-	Model             string  `json:"model"`
-	FeaturesUsed      int     `json:"features_used"`
-	PredictionTime    string  `json:"prediction_time,omitempty"`
-	ProcessingTimeMs  float64 `json:"processing_time_ms,omitempty"`
+	Price            float64 `json:"price"`
+	PriceLog         float64 `json:"price_log"`
+	ConfidenceLower  float64 `json:"confidence_lower"`
+	ConfidenceUpper  float64 `json:"confidence_upper"`
+	Model            string  `json:"model"`
+	FeaturesUsed     int     `json:"features_used"`
+	PredictionTime   string  `json:"prediction_time,omitempty"`
+	ProcessingTimeMs float64 `json:"processing_time_ms,omitempty"`
+	FormattedPrice   string  `json:"formatted_price,omitempty"`
+
+	Warnings []plausibility.Warning `json:"warnings,omitempty"`
 }
 
 // ErrorResponse represents an error response
 type ErrorResponse struct {
-	Error   string   `json:"error"`
-	Details string   `json:"details,omitempty"`
-	Fields  []string `json:"fields,omitempty"`
+	Error      string      `json:"error"`
+	Details    string      `json:"details,omitempty"`
+	Fields     []string    `json:"fields,omitempty"`
+	Violations []Violation `json:"violations,omitempty"`
+
+	Interval    *ConfidenceInterval `json:"interval,omitempty"`
+	Suggestions []string            `json:"suggestions,omitempty"`
+}
+
+// RouteErrorResponse is the error for a request matching no route, or no
+// route for its method, listing the routes that do exist
+type RouteErrorResponse struct {
+	ErrorResponse
+	Routes []string `json:"routes"`
+}
+
+// ConfidenceInterval describes a prediction's confidence interval and how
+// it compares with the confidence the caller asked for
+type ConfidenceInterval struct {
+	Value      float64 `json:"value"`
+	Lower      float64 `json:"lower"`
+	Upper      float64 `json:"upper"`
+	Confidence float64 `json:"confidence"`
+	Required   float64 `json:"required"`
+}
+
+// PredictionInterval is a prediction's interval at the confidence level
+// the caller asked for
+type PredictionInterval struct {
+	Level float64 `json:"level"`
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+}
+
+// Quantile is one predicted quantile of the target's distribution
+type Quantile struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+// EnsembleResponse combines the predictions of every member of a model's
+// ensemble into one estimate
+type EnsembleResponse struct {
+	Model            string                 `json:"model"`
+	Combine          string                 `json:"combine"`
+	Field            string                 `json:"field"`
+	Estimate         float64                `json:"estimate"`
+	Spread           EnsembleSpread         `json:"spread"`
+	Succeeded        int                    `json:"succeeded"`
+	Failed           int                    `json:"failed"`
+	Members          []EnsembleMember       `json:"members"`
+	Warnings         []plausibility.Warning `json:"warnings,omitempty"`
+	RequestID        string                 `json:"request_id"`
+	ProcessingTimeMs float64                `json:"processing_time_ms"`
+}
+
+// EnsembleSpread describes how far apart the members' predictions are
+type EnsembleSpread struct {
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	StdDev float64 `json:"stddev"`
+}
+
+// EnsembleMember is one member's prediction, or the error it returned
+type EnsembleMember struct {
+	Name       string                 `json:"name"`
+	Weight     float64                `json:"weight"`
+	Value      *float64               `json:"value,omitempty"`
+	Prediction map[string]interface{} `json:"prediction,omitempty"`
+	LatencyMs  float64                `json:"latency_ms"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// Leaderboard ranks the values of a field, such as counties, by the value
+// predicted for the same input with each of them
+type Leaderboard struct {
+	Model            string                 `json:"model"`
+	Field            string                 `json:"field"`
+	RankedBy         string                 `json:"ranked_by"`
+	Order            string                 `json:"order"`
+	Input            map[string]interface{} `json:"input"`
+	Entries          []LeaderboardEntry     `json:"entries"`
+	Failed           []LeaderboardFailure   `json:"failed,omitempty"`
+	Cached           int                    `json:"cached"`
+	ProcessingTimeMs float64                `json:"processing_time_ms"`
+}
+
+// LeaderboardEntry is one ranked field value and its prediction
+type LeaderboardEntry struct {
+	Rank     int                    `json:"rank"`
+	Name     string                 `json:"name"`
+	Value    float64                `json:"value"`
+	Warnings []plausibility.Warning `json:"warnings,omitempty"`
+}
+
+// LeaderboardFailure is a field value whose prediction failed
+type LeaderboardFailure struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// AppraisalRequest is a portfolio of properties to appraise. Each property
+// holds the model's request fields and an optional id naming it.
+type AppraisalRequest struct {
+	Properties []map[string]interface{} `json:"properties"`
+}
+
+// Appraisal is the prediction for each property in a portfolio and the
+// portfolio's totals
+type Appraisal struct {
+	Model            string              `json:"model"`
+	Currency         string              `json:"currency,omitempty"`
+	Summary          PortfolioSummary    `json:"summary"`
+	Properties       []AppraisedProperty `json:"properties"`
+	Cached           int                 `json:"cached"`
+	ProcessingTimeMs float64             `json:"processing_time_ms"`
+}
+
+// AppraisedProperty is one property's prediction, or the error it returned
+type AppraisedProperty struct {
+	Index      int                    `json:"index"`
+	ID         string                 `json:"id,omitempty"`
+	Input      map[string]interface{} `json:"input"`
+	Value      *float64               `json:"value,omitempty"`
+	Lower      *float64               `json:"lower,omitempty"`
+	Upper      *float64               `json:"upper,omitempty"`
+	Confidence *float64               `json:"confidence,omitempty"`
+	Warnings   []plausibility.Warning `json:"warnings,omitempty"`
+	Error      *ErrorResponse         `json:"error,omitempty"`
+}
+
+// PortfolioSummary totals the values of a portfolio's appraised properties.
+// ConfidenceWeightedValue discounts each value by its prediction's
+// confidence, so uncertain appraisals count for less.
+type PortfolioSummary struct {
+	Properties              int           `json:"properties"`
+	Appraised               int           `json:"appraised"`
+	Failed                  int           `json:"failed"`
+	TotalValue              float64       `json:"total_value"`
+	TotalLower              float64       `json:"total_lower"`
+	TotalUpper              float64       `json:"total_upper"`
+	ConfidenceWeightedValue float64       `json:"confidence_weighted_value"`
+	ByCounty                []CountyValue `json:"by_county"`
+}
+
+// CountyValue is the total value of a portfolio's properties in one county
+// and its share of the portfolio's total
+type CountyValue struct {
+	County     string  `json:"county"`
+	Properties int     `json:"properties"`
+	TotalValue float64 `json:"total_value"`
+	Share      float64 `json:"share"`
+}
+
+// AffordabilityRequest is a housing prediction request and the buyer's
+// mortgage terms. InterestRate is the annual rate in percent.
+type AffordabilityRequest struct {
+	Property     map[string]interface{} `json:"property"`
+	Deposit      *float64               `json:"deposit"`
+	InterestRate *float64               `json:"interest_rate"`
+	TermYears    *int                   `json:"term_years"`
+}
+
+// Affordability is the mortgage a predicted price implies for a deposit and
+// interest rate. Each range runs from the value implied by one end of the
+// prediction's confidence interval to the other, with Expected implied by
+// the estimate itself.
+type Affordability struct {
+	Model            string                 `json:"model"`
+	Currency         string                 `json:"currency,omitempty"`
+	Price            AffordabilityRange     `json:"price"`
+	Deposit          float64                `json:"deposit"`
+	InterestRate     float64                `json:"interest_rate"`
+	TermYears        int                    `json:"term_years"`
+	Loan             AffordabilityRange     `json:"loan"`
+	LoanToValue      AffordabilityRange     `json:"loan_to_value"`
+	MonthlyPayment   AffordabilityRange     `json:"monthly_payment"`
+	TotalRepayable   AffordabilityRange     `json:"total_repayable"`
+	Warnings         []plausibility.Warning `json:"warnings,omitempty"`
+	RequestID        string                 `json:"request_id"`
+	ProcessingTimeMs float64                `json:"processing_time_ms"`
+}
+
+// AffordabilityRange is the lowest, expected and highest value of a figure
+type AffordabilityRange struct {
+	Low      float64 `json:"low"`
+	Expected float64 `json:"expected"`
+	High     float64 `json:"high"`
+}
+
+// RegionalStats summarizes the stored housing predictions for a county, or
+// for every county if none is given
+type RegionalStats struct {
+	County      string         `json:"county,omitempty"`
+	Count       int            `json:"count"`
+	MeanPrice   *float64       `json:"mean_price,omitempty"`
+	MinPrice    *float64       `json:"min_price,omitempty"`
+	MaxPrice    *float64       `json:"max_price,omitempty"`
+	Trend       []MonthlyPrice `json:"trend"`
+	TrendChange *float64       `json:"trend_change_pct,omitempty"`
+	Since       string         `json:"since,omitempty"`
+	Until       string         `json:"until,omitempty"`
+}
+
+// MonthlyPrice is the mean predicted price for one property sale month
+type MonthlyPrice struct {
+	Month     string   `json:"month"`
+	Count     int      `json:"count"`
+	MeanPrice *float64 `json:"mean_price,omitempty"`
+}
+
+// Violation describes a single invalid value, located by JSON pointer
+type Violation struct {
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+// Link is a related resource in a response's _links block. Type is the
+// media type of a resource that is not JSON.
+type Link struct {
+	Href string `json:"href"`
+	Type string `json:"type,omitempty"`
 }
 
 // HealthResponse represents the health check response
 type HealthResponse struct {
-	Status            string `json:"status"`
-	Service           string `json:"service"`
-	Version           string `json:"version"`
-	MLServiceHealthy  bool   `json:"ml_service_healthy"`
-	MLServiceResponse string `json:"ml_service_response,omitempty"`
+	Status             string                   `json:"status"`
+	Service            string                   `json:"service"`
+	Version            string                   `json:"version"`
+	MLServiceHealthy   bool                     `json:"ml_service_healthy"`
+	MLServiceResponse  string                   `json:"ml_service_response,omitempty"`
+	MLServiceLatencyMs float64                  `json:"ml_service_latency_ms"`
+	LastChecked        string                   `json:"last_checked"`
+	Components         []health.ComponentStatus `json:"components"`
+}
+
+// VersionResponse represents the build and feature information response
+type VersionResponse struct {
+	Service   string          `json:"service"`
+	Version   string          `json:"version"`
+	Commit    string          `json:"commit"`
+	BuildDate string          `json:"build_date"`
+	GoVersion string          `json:"go_version"`
+	Features  map[string]bool `json:"features"`
+}
+
+// KeepWarmPing is the outcome of the latest keep-warm ping for a model
+type KeepWarmPing struct {
+	At        string  `json:"at"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// DarkLaunchAccepted is returned with 202 Accepted for a prediction
+// request to a dark-launched model
+type DarkLaunchAccepted struct {
+	Status    string `json:"status"`
+	Model     string `json:"model"`
+	RequestID string `json:"request_id,omitempty"`
+	Message   string `json:"message"`
+}
+
+// DarkLaunchResult is one dark-launched prediction kept for evaluation
+type DarkLaunchResult struct {
+	At          string                 `json:"at"`
+	RequestID   string                 `json:"request_id,omitempty"`
+	Fingerprint string                 `json:"fingerprint"`
+	LatencyMs   float64                `json:"latency_ms"`
+	Input       map[string]interface{} `json:"input"`
+	Output      map[string]interface{} `json:"output,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	Comparison  *DarkLaunchComparison  `json:"comparison,omitempty"`
+}
+
+// DarkLaunchComparison compares a dark-launched prediction with the newest
+// live prediction for the same input
+type DarkLaunchComparison struct {
+	LiveID      string   `json:"live_id"`
+	Matches     bool     `json:"matches"`
+	Differences []string `json:"differences,omitempty"`
+}
+
+// DarkLaunchSummary reports a model's dark-launched predictions
+type DarkLaunchSummary struct {
+	Stats      stats.Summary      `json:"stats"`
+	Compared   int                `json:"compared"`
+	Mismatches int                `json:"mismatches"`
+	Recent     []DarkLaunchResult `json:"recent"`
+}
+
+// ModelVersion is the last version reported for a model and where it came
+// from ("poll" or "webhook")
+type ModelVersion struct {
+	Version string `json:"version"`
+	Since   string `json:"since"`
+	Source  string `json:"source"`
+}
+
+// ModelVersionRequest represents a model version webhook notification
+type ModelVersionRequest struct {
+	Model   string `json:"model"`
+	Version string `json:"version"`
+}
+
+// ReadinessResponse represents the readiness check response
+type ReadinessResponse struct {
+	Ready  bool             `json:"ready"`
+	Checks []ReadinessCheck `json:"checks"`
+}
+
+// ReadinessCheck is the outcome of one startup self-test
+type ReadinessCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Details string `json:"details,omitempty"`
+	Checked string `json:"checked_at,omitempty"`
 }
 
 // ElectricityPredictionRequest represents the request for electricity demand prediction
 type ElectricityPredictionRequest struct {
-	// TODO: Add fields based on electricity model requirements
-	Timestamp string  `json:"timestamp"`
-	Features  map[string]interface{} `json:"features"`
+	Year  int  `json:"year" binding:"required"`
+	Month int  `json:"month" binding:"required"`
+	Day   int  `json:"day" binding:"required"`
+	Hour  *int `json:"hour,omitempty"`
 }
 
 // ElectricityPredictionResponse represents the response from electricity prediction
 type ElectricityPredictionResponse struct {
-	Demand           float64 `json:"demand"`
-	PredictionTime   string  `json:"prediction_time"`
-	ProcessingTimeMs float64 `json:"processing_time_ms"`
+	DemandMW         float64 `json:"demand_mw"`
+	Datetime         string  `json:"datetime"`
+	Model            string  `json:"model"`
+	Note             string  `json:"note,omitempty"`
+	PredictionTime   string  `json:"prediction_time,omitempty"`
+	ProcessingTimeMs float64 `json:"processing_time_ms,omitempty"`
+}
+
+// StreamPredictionRequest is a prediction request sent over the WebSocket channel
+type StreamPredictionRequest struct {
+	ID    string                 `json:"id"`
+	Model string                 `json:"model"`
+	Input map[string]interface{} `json:"input"`
+}
+
+// StreamPredictionResponse is the result of a streamed prediction request
+type StreamPredictionResponse struct {
+	ID     string                 `json:"id,omitempty"`
+	Model  string                 `json:"model,omitempty"`
+	Status int                    `json:"status"`
+	Result map[string]interface{} `json:"result,omitempty"`
+	Error  *ErrorResponse         `json:"error,omitempty"`
+	// RetryAfter is how many seconds to wait before retrying a rejected
+	// request, when known
+	RetryAfter int `json:"retry_after,omitempty"`
+}
+
+// JobRequest represents the request to submit an asynchronous batch job
+type JobRequest struct {
+	Model       string                   `json:"model"`
+	Rows        []map[string]interface{} `json:"rows"`
+	Destination string                   `json:"destination,omitempty"`
+	NotifyEmail string                   `json:"notify_email,omitempty"`
+}
+
+// ScheduleRequest represents the request to create or update a recurring prediction
+type ScheduleRequest struct {
+	Name       string                 `json:"name"`
+	Cron       string                 `json:"cron"`
+	Model      string                 `json:"model"`
+	Template   map[string]interface{} `json:"template"`
+	WebhookURL string                 `json:"webhook_url"`
+	Enabled    *bool                  `json:"enabled"`
+}
+
+// RouteToggleRequest represents an admin request to disable or re-enable a route
+type RouteToggleRequest struct {
+	Method     string `json:"method,omitempty"`
+	Path       string `json:"path"`
+	Enabled    *bool  `json:"enabled"`
+	Reason     string `json:"reason,omitempty"`
+	RetryAfter int    `json:"retry_after,omitempty"`
+}
+
+// MaintenanceRequest represents an admin request to turn maintenance mode on or off
+type MaintenanceRequest struct {
+	Enabled    *bool  `json:"enabled"`
+	Message    string `json:"message,omitempty"`
+	RetryAfter int    `json:"retry_after,omitempty"`
+}
+
+// IPRulesRequest represents an admin request to replace the IP allow and
+// deny rules
+type IPRulesRequest struct {
+	Rules []ipfilter.Rule `json:"rules"`
+}
+
+// CachePurgeRequest represents an admin request to purge cached predictions
+// for a model, or for one input if given
+type CachePurgeRequest struct {
+	Model string                 `json:"model"`
+	Input map[string]interface{} `json:"input,omitempty"`
+}
+
+// CaptureRequest represents an admin request to start or stop capturing
+// prediction requests
+type CaptureRequest struct {
+	Enabled     *bool    `json:"enabled"`
+	Destination string   `json:"destination,omitempty"`
+	SampleRate  *float64 `json:"sample_rate,omitempty"`
+	MaxRequests int      `json:"max_requests,omitempty"`
+	Models      []string `json:"models,omitempty"`
+}
+
+// ReplayRequest represents an admin request to re-send a captured set of
+// prediction requests to a target gateway
+type ReplayRequest struct {
+	Source      string  `json:"source"`
+	Target      string  `json:"target"`
+	Rate        float64 `json:"rate,omitempty"`
+	Concurrency int     `json:"concurrency,omitempty"`
+	APIKey      string  `json:"api_key,omitempty"`
+}
+
+// RolloutRequest represents an admin request to roll out a new ML backend,
+// starting at percent of calls and raising the share by step every
+// interval_seconds while it stays within the error and latency bounds
+type RolloutRequest struct {
+	Backend         string   `json:"backend"`
+	Models          []string `json:"models,omitempty"`
+	Percent         *float64 `json:"percent,omitempty"`
+	Step            *float64 `json:"step,omitempty"`
+	IntervalSeconds *int     `json:"interval_seconds,omitempty"`
+	MinRequests     *int     `json:"min_requests,omitempty"`
+	MaxErrorRate    *float64 `json:"max_error_rate,omitempty"`
+	MaxLatencyRatio *float64 `json:"max_latency_ratio,omitempty"`
+}
+
+// DiffTarget identifies a model version by the ML backend serving it and
+// its path, defaulting to the ML service and the model's ml_path
+type DiffTarget struct {
+	Name   string `json:"name,omitempty"`
+	URL    string `json:"url,omitempty"`
+	MLPath string `json:"ml_path,omitempty"`
+}
+
+// DiffModelsRequest represents an admin request to run the same payloads
+// against two versions of a model: those given, or else the most recent
+// history entries
+type DiffModelsRequest struct {
+	Model       string                   `json:"model"`
+	Baseline    DiffTarget               `json:"baseline"`
+	Candidate   DiffTarget               `json:"candidate"`
+	Field       string                   `json:"field,omitempty"`
+	Payloads    []map[string]interface{} `json:"payloads,omitempty"`
+	History     int                      `json:"history,omitempty"`
+	Concurrency int                      `json:"concurrency,omitempty"`
+}
+
+// DiffModelsResponse compares two versions of a model over a set of
+// payloads: how far apart their predictions of a field are and how fast
+// each answered. Deltas are the candidate's value minus the baseline's.
+type DiffModelsResponse struct {
+	Model            string            `json:"model"`
+	Field            string            `json:"field"`
+	Baseline         string            `json:"baseline"`
+	Candidate        string            `json:"candidate"`
+	Source           string            `json:"source"`
+	Samples          int               `json:"samples"`
+	Compared         int               `json:"compared"`
+	Identical        int               `json:"identical"`
+	Invalid          int               `json:"invalid"`
+	Failed           DiffCounts        `json:"failed"`
+	Deltas           *DiffDeltas       `json:"deltas"`
+	Latency          DiffLatency       `json:"latency"`
+	Largest          []DiffSample      `json:"largest"`
+	Errors           []DiffSampleError `json:"errors,omitempty"`
+	ProcessingTimeMs float64           `json:"processing_time_ms"`
+}
+
+// DiffCounts counts samples for each version
+type DiffCounts struct {
+	Baseline  int `json:"baseline"`
+	Candidate int `json:"candidate"`
+}
+
+// DiffDeltas describes the distribution of prediction deltas. Relative
+// deltas are taken against the baseline's value, where it is not zero.
+type DiffDeltas struct {
+	Mean            float64      `json:"mean"`
+	StdDev          float64      `json:"stddev"`
+	MeanAbs         float64      `json:"mean_abs"`
+	P50Abs          float64      `json:"p50_abs"`
+	P90Abs          float64      `json:"p90_abs"`
+	P99Abs          float64      `json:"p99_abs"`
+	MaxAbs          float64      `json:"max_abs"`
+	MeanAbsRelative float64      `json:"mean_abs_relative"`
+	P90AbsRelative  float64      `json:"p90_abs_relative"`
+	Histogram       []DiffBucket `json:"histogram"`
+}
+
+// DiffBucket counts the deltas from From up to To
+type DiffBucket struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Count int     `json:"count"`
+}
+
+// DiffLatency compares the versions' ML call latencies; the ratios are the
+// candidate's over the baseline's
+type DiffLatency struct {
+	Baseline  LatencySummary `json:"baseline"`
+	Candidate LatencySummary `json:"candidate"`
+	P50Ratio  float64        `json:"p50_ratio"`
+	P95Ratio  float64        `json:"p95_ratio"`
+}
+
+// LatencySummary describes a set of call latencies
+type LatencySummary struct {
+	MeanMs float64 `json:"mean_ms"`
+	P50Ms  float64 `json:"p50_ms"`
+	P95Ms  float64 `json:"p95_ms"`
+	P99Ms  float64 `json:"p99_ms"`
+}
+
+// DiffSample is one payload with both versions' values
+type DiffSample struct {
+	Input     map[string]interface{} `json:"input"`
+	Baseline  float64                `json:"baseline"`
+	Candidate float64                `json:"candidate"`
+	Delta     float64                `json:"delta"`
+}
+
+// DiffSampleError is why a sample could not be compared
+type DiffSampleError struct {
+	Sample  int    `json:"sample"`
+	Version string `json:"version,omitempty"`
+	Error   string `json:"error"`
+}
+
+// Stable error codes reported by API v2. Codes are never renamed or reused;
+// messages may change.
+const (
+	CodeInvalidRequest   = "INVALID_REQUEST"
+	CodeUnknownModel     = "UNKNOWN_MODEL"
+	CodeValidationFailed = "VALIDATION_FAILED"
+	CodeTransformFailed  = "TRANSFORM_FAILED"
+	CodeMLServiceError   = "ML_SERVICE_ERROR"
+	CodeModelDisabled    = "MODEL_DISABLED"
+	CodeImplausibleInput = "IMPLAUSIBLE_INPUT"
+	CodeLowConfidence    = "LOW_CONFIDENCE"
+	CodeAnomalousInput   = "ANOMALOUS_INPUT"
+
+	CodeImplausiblePrediction = "IMPLAUSIBLE_PREDICTION"
+	CodeAcceptedForEvaluation = "ACCEPTED_FOR_EVALUATION"
+)
+
+// V2Response is the response envelope used by every API v2 route. Exactly
+// one of Data and Error is set.
+type V2Response struct {
+	Data  interface{}       `json:"data,omitempty"`
+	Error *V2Error          `json:"error,omitempty"`
+	Meta  V2Meta            `json:"meta"`
+	Links map[string]string `json:"links,omitempty"`
+}
+
+// V2Error is an API v2 error with a stable machine-readable code
+type V2Error struct {
+	Code       string      `json:"code"`
+	Message    string      `json:"message"`
+	Details    string      `json:"details,omitempty"`
+	Fields     []string    `json:"fields,omitempty"`
+	Violations []Violation `json:"violations,omitempty"`
+
+	Interval    *ConfidenceInterval `json:"interval,omitempty"`
+	Suggestions []string            `json:"suggestions,omitempty"`
+}
+
+// V2Meta describes how an API v2 response was produced
+type V2Meta struct {
+	APIVersion       string   `json:"api_version"`
+	RequestID        string   `json:"request_id,omitempty"`
+	Model            string   `json:"model,omitempty"`
+	ModelVersion     string   `json:"model_version,omitempty"`
+	Timestamp        string   `json:"timestamp"`
+	ProcessingTimeMs *float64 `json:"processing_time_ms,omitempty"`
+
+	Warnings   []plausibility.Warning `json:"warnings,omitempty"`
+	Provenance map[string]interface{} `json:"provenance,omitempty"`
 }
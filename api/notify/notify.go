@@ -0,0 +1,162 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Event kinds
+const (
+	MLUnhealthy  = "ml_unhealthy"
+	ErrorRate    = "error_rate"
+	JobFailed    = "job_failed"
+	Alert        = "alert"
+	ModelVersion = "model_version"
+)
+
+// Kinds lists every event kind
+var Kinds = []string{MLUnhealthy, ErrorRate, JobFailed, Alert, ModelVersion}
+
+// Webhook formats
+const (
+	Slack = "slack"
+	Teams = "teams"
+)
+
+// Webhook is an incoming webhook URL and the message format it expects
+type Webhook struct {
+	URL    string
+	Format string
+}
+
+// Field is a labelled detail shown with an event
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Event is an operational event. Resolved marks the end of a condition
+// that an earlier event reported.
+type Event struct {
+	Kind     string
+	Title    string
+	Text     string
+	Fields   []Field
+	Resolved bool
+}
+
+// Notifier posts events of the enabled kinds to chat webhooks
+type Notifier struct {
+	webhooks []Webhook
+	kinds    map[string]bool
+	client   *http.Client
+}
+
+// New creates a notifier posting events of the given kinds to webhooks
+func New(webhooks []Webhook, kinds []string) *Notifier {
+	n := &Notifier{
+		webhooks: webhooks,
+		kinds:    make(map[string]bool, len(kinds)),
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, k := range kinds {
+		n.kinds[k] = true
+	}
+	return n
+}
+
+// Enabled reports whether events of kind are posted
+func (n *Notifier) Enabled(kind string) bool {
+	return n != nil && n.kinds[kind]
+}
+
+// Notify posts ev to every webhook, returning the errors of those that
+// failed. Events of disabled kinds are dropped.
+func (n *Notifier) Notify(ev Event) error {
+	if !n.Enabled(ev.Kind) {
+		return nil
+	}
+	var errs []error
+	for _, w := range n.webhooks {
+		if err := n.post(w, ev); err != nil {
+			errs = append(errs, fmt.Errorf("%s webhook: %w", w.Format, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (n *Notifier) post(w Webhook, ev Event) error {
+	var payload interface{}
+	if w.Format == Teams {
+		payload = teamsMessage(ev)
+	} else {
+		payload = slackMessage(ev)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.Post(w.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(detail))
+	}
+	return nil
+}
+
+// color is the accent color of an event: red while a condition holds,
+// green once it is resolved
+func color(ev Event) string {
+	if ev.Resolved {
+		return "2EB67D"
+	}
+	return "E01E5A"
+}
+
+// slackMessage formats an event as a Slack incoming webhook message with
+// one attachment
+func slackMessage(ev Event) map[string]interface{} {
+	fields := make([]map[string]interface{}, len(ev.Fields))
+	for i, f := range ev.Fields {
+		fields[i] = map[string]interface{}{"title": f.Name, "value": f.Value, "short": true}
+	}
+	return map[string]interface{}{
+		"text": ev.Title,
+		"attachments": []map[string]interface{}{{
+			"color":  "#" + color(ev),
+			"text":   ev.Text,
+			"fields": fields,
+			"footer": "Cloud AI API Gateway · " + ev.Kind,
+			"ts":     time.Now().Unix(),
+		}},
+	}
+}
+
+// teamsMessage formats an event as a Microsoft Teams connector card
+func teamsMessage(ev Event) map[string]interface{} {
+	facts := make([]map[string]string, len(ev.Fields))
+	for i, f := range ev.Fields {
+		facts[i] = map[string]string{"name": f.Name, "value": f.Value}
+	}
+	return map[string]interface{}{
+		"@type":      "MessageCard",
+		"@context":   "https://schema.org/extensions",
+		"summary":    ev.Title,
+		"themeColor": color(ev),
+		"title":      ev.Title,
+		"text":       ev.Text,
+		"sections": []map[string]interface{}{{
+			"facts":            facts,
+			"activitySubtitle": "Cloud AI API Gateway · " + ev.Kind,
+		}},
+	}
+}
@@ -0,0 +1,202 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// leeway allows for clock skew between the gateway and the identity provider
+const leeway = time.Minute
+
+// claims are the registered JWT claims the gateway checks, plus every claim
+// for group lookup
+type claims struct {
+	Issuer    string      `json:"iss"`
+	Subject   string      `json:"sub"`
+	Audience  audience    `json:"aud"`
+	Expiry    json.Number `json:"exp"`
+	NotBefore json.Number `json:"nbf"`
+	Email     string      `json:"email"`
+	Nonce     string      `json:"nonce"`
+
+	all map[string]interface{}
+}
+
+// audience is the aud claim, a string or a list of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*a = audience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return errors.New("aud must be a string or a list of strings")
+	}
+	*a = many
+	return nil
+}
+
+// jwk is a JSON Web Key as published in a provider's JWKS
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey decodes an RSA or P-256 signing key. Other keys are skipped.
+func (k jwk) publicKey() (crypto.PublicKey, bool) {
+	if k.Use != "" && k.Use != "sig" {
+		return nil, false
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+		e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+		if err1 != nil || err2 != nil || len(e) > 4 {
+			return nil, false
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, true
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, false
+		}
+		x, err1 := base64.RawURLEncoding.DecodeString(k.X)
+		y, err2 := base64.RawURLEncoding.DecodeString(k.Y)
+		if err1 != nil || err2 != nil {
+			return nil, false
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, false
+		}
+		return key, true
+	}
+	return nil, false
+}
+
+// parseJWT splits a compact JWS and decodes its header and claims without
+// verifying it
+func parseJWT(raw string) (alg, kid string, c claims, signed, sig []byte, err error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return "", "", c, nil, nil, errors.New("token is not a JWT")
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", "", c, nil, nil, errors.New("malformed token header")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return "", "", c, nil, nil, errors.New("malformed token header")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", "", c, nil, nil, errors.New("malformed token claims")
+	}
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return "", "", c, nil, nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+	if err := json.Unmarshal(payload, &c.all); err != nil {
+		return "", "", c, nil, nil, errors.New("malformed token claims")
+	}
+	sig, err = base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", "", c, nil, nil, errors.New("malformed token signature")
+	}
+	return header.Alg, header.Kid, c, []byte(parts[0] + "." + parts[1]), sig, nil
+}
+
+// verifySignature checks a JWS signature. Only RS256 and ES256 are accepted.
+func verifySignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	digest := sha256.Sum256(signed)
+	switch alg {
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("key does not match token algorithm")
+		}
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) != nil {
+			return errors.New("invalid token signature")
+		}
+		return nil
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("key does not match token algorithm")
+		}
+		if len(sig) != 64 {
+			return errors.New("invalid token signature")
+		}
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return errors.New("invalid token signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported token algorithm %q", alg)
+}
+
+// checkTimes checks exp and nbf against now
+func (c claims) checkTimes(now time.Time) error {
+	exp, err := c.Expiry.Int64()
+	if err != nil {
+		return errors.New("token has no expiry")
+	}
+	if now.After(time.Unix(exp, 0).Add(leeway)) {
+		return errors.New("token has expired")
+	}
+	if c.NotBefore != "" {
+		if nbf, err := c.NotBefore.Int64(); err == nil && now.Add(leeway).Before(time.Unix(nbf, 0)) {
+			return errors.New("token is not valid yet")
+		}
+	}
+	return nil
+}
+
+// groups reads a claim holding group names, either a list or a
+// space-separated string. A dotted name such as realm_access.roles looks in
+// nested objects.
+func (c claims) groups(name string) []string {
+	var v interface{} = c.all
+	for _, part := range strings.Split(name, ".") {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = obj[part]
+	}
+	switch g := v.(type) {
+	case string:
+		return strings.Fields(g)
+	case []interface{}:
+		out := make([]string, 0, len(g))
+		for _, item := range g {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
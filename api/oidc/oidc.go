@@ -0,0 +1,401 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Roles granted on the admin API, from least to most privileged
+const (
+	// RoleViewer may read admin state (GET and HEAD requests)
+	RoleViewer = "viewer"
+	// RoleAdmin may do anything
+	RoleAdmin = "admin"
+)
+
+// ErrNoRole is returned for valid tokens whose groups map to no role
+var ErrNoRole = errors.New("none of the token's groups has an admin role")
+
+// jwksRefresh is how often keys are refetched, and the least time between
+// refetches for tokens signed by an unknown key
+const (
+	jwksRefresh     = time.Hour
+	jwksMinInterval = time.Minute
+)
+
+// Config configures a provider
+type Config struct {
+	// Issuer is the identity provider's issuer URL. Its discovery document
+	// is read from Issuer/.well-known/openid-configuration.
+	Issuer string
+	// ClientID and ClientSecret are the gateway's client credentials. The
+	// secret is only needed for the browser login.
+	ClientID     string
+	ClientSecret string
+	// Audiences are accepted in the aud claim of access tokens, in addition
+	// to ClientID
+	Audiences []string
+	// GroupsClaim names the claim holding the caller's groups
+	GroupsClaim string
+	// RoleMap maps group names to RoleAdmin or RoleViewer
+	RoleMap map[string]string
+	// Scopes are requested at login, besides openid
+	Scopes []string
+	// RedirectURL is the login callback URL registered with the provider
+	RedirectURL string
+	// SessionTTL is how long a login lasts
+	SessionTTL time.Duration
+	// SessionSecret signs session cookies. Replicas behind one load
+	// balancer must share it.
+	SessionSecret []byte
+}
+
+// Identity is an authenticated caller of the admin API
+type Identity struct {
+	Subject string    `json:"sub"`
+	Email   string    `json:"email,omitempty"`
+	Groups  []string  `json:"groups,omitempty"`
+	Role    string    `json:"role"`
+	Expiry  time.Time `json:"exp"`
+}
+
+// Allows reports whether the identity's role permits a request method
+func (id Identity) Allows(method string) bool {
+	if id.Role == RoleAdmin {
+		return true
+	}
+	return id.Role == RoleViewer && (method == http.MethodGet || method == http.MethodHead)
+}
+
+// discovery is the subset of the provider metadata the gateway uses
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider verifies tokens issued by an OpenID Connect provider and runs
+// the authorization code login. Provider metadata and signing keys are
+// fetched on first use and cached.
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu        sync.Mutex
+	meta      *discovery
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+// New creates a provider. It does not contact the identity provider.
+func New(cfg Config) *Provider {
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	return &Provider{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// LoginEnabled reports whether the browser login is configured
+func (p *Provider) LoginEnabled() bool {
+	return p.cfg.ClientSecret != "" && p.cfg.RedirectURL != ""
+}
+
+// metadata returns the discovery document, fetching it if needed
+func (p *Provider) metadata(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return p.meta, nil
+	}
+
+	var meta discovery
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.cfg.Issuer, "/")+"/.well-known/openid-configuration", &meta); err != nil {
+		return nil, fmt.Errorf("oidc discovery failed: %w", err)
+	}
+	if meta.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("oidc discovery failed: issuer is %q, want %q", meta.Issuer, p.cfg.Issuer)
+	}
+	if meta.JWKSURI == "" || meta.TokenEndpoint == "" || meta.AuthorizationEndpoint == "" {
+		return nil, errors.New("oidc discovery failed: missing jwks_uri, token_endpoint or authorization_endpoint")
+	}
+	p.meta = &meta
+	return p.meta, nil
+}
+
+// key returns the signing key with the given ID, refetching the key set
+// when it is stale or the key is unknown
+func (p *Provider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key, ok := p.keys[kid]
+	stale := time.Since(p.fetchedAt) > jwksRefresh
+	if ok && !stale {
+		return key, nil
+	}
+	if !stale && time.Since(p.fetchedAt) < jwksMinInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.getJSON(ctx, meta.JWKSURI, &set); err != nil {
+		if ok {
+			// Keep using a known key if the provider is briefly unreachable
+			return key, nil
+		}
+		return nil, fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if pub, ok := k.publicKey(); ok {
+			keys[k.Kid] = pub
+		}
+	}
+	p.keys, p.fetchedAt = keys, time.Now()
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// Verify checks a JWT access token, such as one issued by the client
+// credentials grant, and maps its groups to a role. The token must be
+// signed by the provider, issued by Issuer, unexpired and meant for
+// ClientID or one of Audiences.
+func (p *Provider) Verify(ctx context.Context, raw string) (Identity, error) {
+	return p.verify(ctx, raw, "")
+}
+
+// verify checks a JWT, and its nonce if one is expected
+func (p *Provider) verify(ctx context.Context, raw, nonce string) (Identity, error) {
+	alg, kid, c, signed, sig, err := parseJWT(raw)
+	if err != nil {
+		return Identity{}, err
+	}
+	key, err := p.key(ctx, kid)
+	if err != nil {
+		return Identity{}, err
+	}
+	if err := verifySignature(alg, key, signed, sig); err != nil {
+		return Identity{}, err
+	}
+	if c.Issuer != p.cfg.Issuer {
+		return Identity{}, fmt.Errorf("token issuer %q is not trusted", c.Issuer)
+	}
+	if !p.audienceAllowed(c) {
+		return Identity{}, errors.New("token is not meant for this service")
+	}
+	if err := c.checkTimes(time.Now()); err != nil {
+		return Identity{}, err
+	}
+	if nonce != "" && !hmac.Equal([]byte(c.Nonce), []byte(nonce)) {
+		return Identity{}, errors.New("token nonce does not match the login")
+	}
+
+	exp, _ := c.Expiry.Int64()
+	id := Identity{
+		Subject: c.Subject,
+		Email:   c.Email,
+		Groups:  c.groups(p.cfg.GroupsClaim),
+		Expiry:  time.Unix(exp, 0).UTC(),
+	}
+	for _, g := range id.Groups {
+		if role := p.cfg.RoleMap[g]; role == RoleAdmin || (role == RoleViewer && id.Role == "") {
+			id.Role = role
+		}
+	}
+	if id.Role == "" {
+		return id, ErrNoRole
+	}
+	return id, nil
+}
+
+func (p *Provider) audienceAllowed(c claims) bool {
+	for _, aud := range c.Audience {
+		if aud == p.cfg.ClientID {
+			return true
+		}
+		for _, allowed := range p.cfg.Audiences {
+			if aud == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Login is an authorization code login in progress. It is kept in a
+// signed cookie between the redirect to the provider and the callback.
+type Login struct {
+	State    string `json:"state"`
+	Verifier string `json:"verifier"`
+	Nonce    string `json:"nonce"`
+	Next     string `json:"next"`
+	Expires  int64  `json:"exp"`
+}
+
+// StartLogin begins an authorization code login with PKCE that returns to
+// next, returning the provider URL to redirect to and the login state to
+// keep until the callback
+func (p *Provider) StartLogin(ctx context.Context, next string) (string, Login, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", Login{}, err
+	}
+	login := Login{
+		State:    randomString(),
+		Verifier: randomString() + randomString(),
+		Nonce:    randomString(),
+		Next:     next,
+		Expires:  time.Now().Add(10 * time.Minute).Unix(),
+	}
+	challenge := sha256.Sum256([]byte(login.Verifier))
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, p.cfg.Scopes...), " ")},
+		"state":                 {login.State},
+		"nonce":                 {login.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return meta.AuthorizationEndpoint + sep + q.Encode(), login, nil
+}
+
+// FinishLogin exchanges the code from the provider's callback for an ID
+// token and returns the identity it carries
+func (p *Provider) FinishLogin(ctx context.Context, login Login, state, code string) (Identity, error) {
+	if time.Now().Unix() > login.Expires {
+		return Identity{}, errors.New("login has expired; start again")
+	}
+	if state == "" || !hmac.Equal([]byte(state), []byte(login.State)) {
+		return Identity{}, errors.New("login state does not match")
+	}
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"code_verifier": {login.Verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := p.doJSON(req, &tokens); err != nil {
+		return Identity{}, fmt.Errorf("code exchange failed: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return Identity{}, errors.New("code exchange failed: no id_token in response")
+	}
+	return p.verify(ctx, tokens.IDToken, login.Nonce)
+}
+
+// Session returns a signed session cookie value for an identity, valid for
+// SessionTTL
+func (p *Provider) Session(id Identity) string {
+	id.Expiry = time.Now().Add(p.cfg.SessionTTL).UTC()
+	return p.Sign(id)
+}
+
+// ParseSession returns the identity in a session cookie, if it is validly
+// signed and unexpired
+func (p *Provider) ParseSession(value string) (Identity, bool) {
+	var id Identity
+	if !p.Unsign(value, &id) || time.Now().After(id.Expiry) {
+		return Identity{}, false
+	}
+	return id, true
+}
+
+// Sign encodes v as a cookie value signed with SessionSecret
+func (p *Provider) Sign(v interface{}) string {
+	data, _ := json.Marshal(v)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + p.mac(payload)
+}
+
+// Unsign decodes a cookie value made by Sign into v, reporting whether its
+// signature is valid
+func (p *Provider) Unsign(value string, v interface{}) bool {
+	payload, mac, ok := strings.Cut(value, ".")
+	if !ok || !hmac.Equal([]byte(mac), []byte(p.mac(payload))) {
+		return false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	return err == nil && json.Unmarshal(data, v) == nil
+}
+
+func (p *Provider) mac(payload string) string {
+	h := hmac.New(sha256.New, p.cfg.SessionSecret)
+	h.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+func (p *Provider) getJSON(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	return p.doJSON(req, v)
+}
+
+func (p *Provider) doJSON(req *http.Request, v interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d: %s", req.URL.Host, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, v)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,198 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A4 page size in points
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+// Document builds a PDF of A4 pages drawn with lines, filled shapes and
+// text in the standard Helvetica fonts, which PDF readers provide, so no
+// fonts are embedded. Coordinates are in points from the bottom-left
+// corner of the page.
+type Document struct {
+	title string
+	pages []*Page
+}
+
+// Page is one page's drawing operations
+type Page struct {
+	content bytes.Buffer
+}
+
+// Color is an RGB color with components from 0 to 1
+type Color struct{ R, G, B float64 }
+
+// Point is a position on the page
+type Point struct{ X, Y float64 }
+
+// New starts a document with the given title
+func New(title string) *Document {
+	return &Document{title: title}
+}
+
+// AddPage appends a blank page and returns it for drawing
+func (d *Document) AddPage() *Page {
+	p := &Page{}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// Text draws s with its baseline starting at (x, y). Characters outside
+// the Windows-1252 character set are replaced with '?'.
+func (p *Page) Text(x, y, size float64, bold bool, c Color, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(&p.content, "BT %s rg /%s %s Tf %s %s Td (%s) Tj ET\n",
+		rgb(c), font, num(size), num(x), num(y), escape(s))
+}
+
+// Line draws a straight line
+func (p *Page) Line(from, to Point, width float64, c Color) {
+	fmt.Fprintf(&p.content, "%s RG %s w %s %s m %s %s l S\n",
+		rgb(c), num(width), num(from.X), num(from.Y), num(to.X), num(to.Y))
+}
+
+// Polyline draws connected line segments through points
+func (p *Page) Polyline(points []Point, width float64, c Color) {
+	if len(points) < 2 {
+		return
+	}
+	fmt.Fprintf(&p.content, "%s RG %s w 1 j ", rgb(c), num(width))
+	p.path(points)
+	p.content.WriteString("S\n")
+}
+
+// Polygon fills the shape enclosed by points
+func (p *Page) Polygon(points []Point, c Color) {
+	if len(points) < 3 {
+		return
+	}
+	fmt.Fprintf(&p.content, "%s rg ", rgb(c))
+	p.path(points)
+	p.content.WriteString("h f\n")
+}
+
+// Rect fills a rectangle with its bottom-left corner at (x, y)
+func (p *Page) Rect(x, y, w, h float64, c Color) {
+	fmt.Fprintf(&p.content, "%s rg %s %s %s %s re f\n", rgb(c), num(x), num(y), num(w), num(h))
+}
+
+func (p *Page) path(points []Point) {
+	for i, pt := range points {
+		op := "l"
+		if i == 0 {
+			op = "m"
+		}
+		fmt.Fprintf(&p.content, "%s %s %s ", num(pt.X), num(pt.Y), op)
+	}
+}
+
+// TextWidth estimates the width of s in Helvetica at size, for aligning
+// short labels
+func TextWidth(s string, size float64) float64 {
+	var units float64
+	for _, r := range s {
+		switch {
+		case strings.ContainsRune("ijl.,:;'|!", r):
+			units += 0.24
+		case strings.ContainsRune("frt()-[] ", r):
+			units += 0.31
+		case strings.ContainsRune("mwMW@%", r):
+			units += 0.86
+		case r >= 'A' && r <= 'Z':
+			units += 0.69
+		default:
+			units += 0.55
+		}
+	}
+	return units * size
+}
+
+// WriteTo writes the document as PDF
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1-5 are fixed; each page then takes a page and a content
+	// object
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 6+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	object(fmt.Sprintf("<< /Title (%s) /Producer (Cloud AI API Gateway) /CreationDate (D:%s) >>",
+		escape(d.title), time.Now().UTC().Format("20060102150405Z")))
+	for i, p := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			num(PageWidth), num(PageHeight), 7+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.content.Len(), p.content.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+// escape encodes s as the body of a PDF literal string in Windows-1252
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			b.WriteByte(byte(r))
+		case r == '–':
+			b.WriteByte(0x96)
+		case r == '—':
+			b.WriteByte(0x97)
+		case r == '•':
+			b.WriteByte(0x95)
+		case r == '€':
+			b.WriteByte(0x80)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+func rgb(c Color) string {
+	return num(c.R) + " " + num(c.G) + " " + num(c.B)
+}
+
+// num formats a coordinate or size with at most two decimal places
+func num(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
@@ -0,0 +1,219 @@
+package plausibility
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed default.json
+var defaultRules []byte
+
+// Warning codes
+const (
+	CodeBeforeTraining = "before_training_data"
+	CodeBeyondHorizon  = "beyond_training_horizon"
+	CodeSparseData     = "sparse_training_data"
+)
+
+// Warning flags an input that passed validation but lies outside what the
+// model was trained on, so its prediction may be unreliable
+type Warning struct {
+	Code    string   `json:"code"`
+	Fields  []string `json:"fields"`
+	Message string   `json:"message"`
+}
+
+// Horizon is the period a model's training data covers, as YYYY-MM
+// months read from the payload's year and month fields. MonthField may be
+// empty for models with yearly inputs.
+type Horizon struct {
+	YearField  string `json:"year_field"`
+	MonthField string `json:"month_field,omitempty"`
+	Earliest   string `json:"earliest,omitempty"`
+	Latest     string `json:"latest,omitempty"`
+
+	earliest, latest int
+}
+
+// Coverage gives the number of training samples per value of a field.
+// Values with fewer than MinSamples are flagged; values not listed are
+// flagged only if Complete is set, otherwise they are not checked.
+type Coverage struct {
+	Field      string         `json:"field"`
+	MinSamples int            `json:"min_samples"`
+	Samples    map[string]int `json:"samples"`
+	Complete   bool           `json:"complete,omitempty"`
+}
+
+// ModelRules is the plausibility metadata for one model
+type ModelRules struct {
+	Horizon  *Horizon   `json:"horizon,omitempty"`
+	Coverage []Coverage `json:"coverage,omitempty"`
+}
+
+// Rules holds plausibility metadata by model name
+type Rules struct {
+	Models map[string]*ModelRules `json:"models"`
+}
+
+// Default returns the bundled metadata for the built-in models
+func Default() *Rules {
+	rules, err := Parse(defaultRules)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in plausibility rules: %s", err))
+	}
+	return rules
+}
+
+// Load reads plausibility metadata from a JSON file
+func Load(path string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plausibility rules: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse builds plausibility rules from their JSON representation
+func Parse(data []byte) (*Rules, error) {
+	var r Rules
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse plausibility rules: %w", err)
+	}
+	for name, m := range r.Models {
+		if m == nil {
+			return nil, fmt.Errorf("model %q: no rules", name)
+		}
+		if h := m.Horizon; h != nil {
+			if h.YearField == "" {
+				return nil, fmt.Errorf("model %q: horizon year_field is required", name)
+			}
+			var err error
+			if h.earliest, err = parseMonth(h.Earliest); err != nil {
+				return nil, fmt.Errorf("model %q: horizon earliest: %w", name, err)
+			}
+			if h.latest, err = parseMonth(h.Latest); err != nil {
+				return nil, fmt.Errorf("model %q: horizon latest: %w", name, err)
+			}
+			if h.earliest != 0 && h.latest != 0 && h.earliest > h.latest {
+				return nil, fmt.Errorf("model %q: horizon earliest is after latest", name)
+			}
+		}
+		for _, c := range m.Coverage {
+			if c.Field == "" {
+				return nil, fmt.Errorf("model %q: coverage declared without a field", name)
+			}
+			if c.MinSamples < 1 {
+				return nil, fmt.Errorf("model %q: coverage of %q: min_samples must be positive", name, c.Field)
+			}
+		}
+	}
+	return &r, nil
+}
+
+// Check returns the plausibility warnings for a validated payload. Models
+// without metadata, and fields missing from the payload, are not checked.
+func (r *Rules) Check(model string, payload map[string]interface{}) []Warning {
+	m, ok := r.Models[model]
+	if !ok {
+		return nil
+	}
+
+	var warnings []Warning
+	if h := m.Horizon; h != nil {
+		if w, ok := h.check(payload); ok {
+			warnings = append(warnings, w)
+		}
+	}
+	for _, c := range m.Coverage {
+		if w, ok := c.check(payload); ok {
+			warnings = append(warnings, w)
+		}
+	}
+	return warnings
+}
+
+func (h *Horizon) check(payload map[string]interface{}) (Warning, bool) {
+	year, ok := number(payload[h.YearField])
+	if !ok {
+		return Warning{}, false
+	}
+	fields := []string{h.YearField}
+	month := 1
+	lastMonth := 12
+	if h.MonthField != "" {
+		m, ok := number(payload[h.MonthField])
+		if !ok {
+			return Warning{}, false
+		}
+		month, lastMonth = m, m
+		fields = append(fields, h.MonthField)
+	}
+
+	switch {
+	case h.latest != 0 && year*12+month-1 > h.latest:
+		return Warning{
+			Code:    CodeBeyondHorizon,
+			Fields:  fields,
+			Message: fmt.Sprintf("Training data ends in %s; predictions further ahead are extrapolated", h.Latest),
+		}, true
+	case h.earliest != 0 && year*12+lastMonth-1 < h.earliest:
+		return Warning{
+			Code:    CodeBeforeTraining,
+			Fields:  fields,
+			Message: fmt.Sprintf("Training data starts in %s; earlier dates are extrapolated", h.Earliest),
+		}, true
+	}
+	return Warning{}, false
+}
+
+func (c *Coverage) check(payload map[string]interface{}) (Warning, bool) {
+	value, ok := payload[c.Field].(string)
+	if !ok {
+		return Warning{}, false
+	}
+	n, listed := c.Samples[strings.ToUpper(value)]
+	if !listed && !c.Complete {
+		return Warning{}, false
+	}
+	if n >= c.MinSamples {
+		return Warning{}, false
+	}
+	message := fmt.Sprintf("Only %d training samples have %s %q", n, c.Field, value)
+	if n == 0 {
+		message = fmt.Sprintf("No training samples have %s %q", c.Field, value)
+	}
+	return Warning{Code: CodeSparseData, Fields: []string{c.Field}, Message: message}, true
+}
+
+// parseMonth converts YYYY-MM to a month count (year*12 + month-1); empty
+// is 0, meaning unbounded
+func parseMonth(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	t, err := time.Parse("2006-01", s)
+	if err != nil {
+		return 0, fmt.Errorf("must be YYYY-MM, got %q", s)
+	}
+	return t.Year()*12 + int(t.Month()) - 1, nil
+}
+
+// number reads an integer payload value, decoded as json.Number or float64
+func number(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		i, err := strconv.Atoi(n.String())
+		return i, err == nil
+	case float64:
+		return int(n), n == float64(int(n))
+	case int:
+		return n, true
+	}
+	return 0, false
+}
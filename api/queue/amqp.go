@@ -0,0 +1,118 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// amqpConsumer consumes requests from a durable RabbitMQ queue and publishes
+// replies to each message's reply_to queue or, failing that, to the
+// configured reply queue, preserving the correlation ID. Requests are
+// acknowledged after their reply is published.
+type amqpConsumer struct {
+	cfg  Config
+	conn *amqp.Connection
+	ch   *amqp.Channel
+	mu   sync.Mutex
+}
+
+func newAMQPConsumer(cfg Config) (*amqpConsumer, error) {
+	conn, err := amqp.Dial(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	if err := ch.Qos(cfg.Concurrency, 0, false); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to set prefetch: %w", err)
+	}
+
+	for _, name := range []string{cfg.RequestQueue, cfg.ReplyQueue} {
+		if name == "" {
+			continue
+		}
+		if _, err := ch.QueueDeclare(name, true, false, false, false, nil); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to declare queue %s: %w", name, err)
+		}
+	}
+
+	return &amqpConsumer{cfg: cfg, conn: conn, ch: ch}, nil
+}
+
+func (a *amqpConsumer) Run(ctx context.Context, handle HandlerFunc) error {
+	deliveries, err := a.ch.Consume(a.cfg.RequestQueue, consumerGroup, false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to consume %s: %w", a.cfg.RequestQueue, err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < a.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range deliveries {
+				a.process(ctx, d, handle)
+			}
+		}()
+	}
+
+	closed := a.conn.NotifyClose(make(chan *amqp.Error, 1))
+	select {
+	case <-ctx.Done():
+		err = a.ch.Cancel(consumerGroup, false)
+	case amqpErr := <-closed:
+		if amqpErr != nil {
+			err = fmt.Errorf("RabbitMQ connection closed: %w", amqpErr)
+		}
+	}
+	wg.Wait()
+	return err
+}
+
+// process handles one delivery, publishes its reply and acknowledges it
+func (a *amqpConsumer) process(ctx context.Context, d amqp.Delivery, handle HandlerFunc) {
+	reply := handle(d.Body)
+
+	routingKey := d.ReplyTo
+	if routingKey == "" {
+		routingKey = a.cfg.ReplyQueue
+	}
+	if routingKey != "" {
+		a.mu.Lock()
+		err := a.ch.PublishWithContext(ctx, "", routingKey, false, false, amqp.Publishing{
+			ContentType:   "application/json",
+			CorrelationId: d.CorrelationId,
+			Body:          reply,
+		})
+		a.mu.Unlock()
+		if err != nil {
+			log.Printf("Failed to publish RabbitMQ reply to %s: %v", routingKey, err)
+			// Requeue so the request is retried once the broker recovers
+			d.Nack(false, true)
+			return
+		}
+	}
+	d.Ack(false)
+}
+
+func (a *amqpConsumer) Ping(ctx context.Context) error {
+	if a.conn.IsClosed() {
+		return fmt.Errorf("RabbitMQ connection is closed")
+	}
+	return nil
+}
+
+func (a *amqpConsumer) Close() error {
+	return a.conn.Close()
+}
@@ -0,0 +1,72 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsConsumer consumes requests from a NATS subject as part of a queue
+// group and replies to each message's reply subject (request/reply) or,
+// failing that, to the configured reply subject
+type natsConsumer struct {
+	cfg  Config
+	conn *nats.Conn
+}
+
+func newNATSConsumer(cfg Config) (*natsConsumer, error) {
+	conn, err := nats.Connect(cfg.URL, nats.Name(consumerGroup), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	return &natsConsumer{cfg: cfg, conn: conn}, nil
+}
+
+func (n *natsConsumer) Run(ctx context.Context, handle HandlerFunc) error {
+	msgs := make(chan *nats.Msg, n.cfg.Concurrency*2)
+	sub, err := n.conn.ChanQueueSubscribe(n.cfg.RequestQueue, consumerGroup, msgs)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", n.cfg.RequestQueue, err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < n.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range msgs {
+				reply := handle(msg.Data)
+				subject := msg.Reply
+				if subject == "" {
+					subject = n.cfg.ReplyQueue
+				}
+				if subject == "" {
+					continue
+				}
+				if err := n.conn.Publish(subject, reply); err != nil {
+					log.Printf("Failed to publish NATS reply to %s: %v", subject, err)
+				}
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	err = sub.Unsubscribe()
+	close(msgs)
+	wg.Wait()
+	return err
+}
+
+func (n *natsConsumer) Ping(ctx context.Context) error {
+	if !n.conn.IsConnected() {
+		return fmt.Errorf("NATS connection is %s", n.conn.Status())
+	}
+	return n.conn.FlushWithContext(ctx)
+}
+
+func (n *natsConsumer) Close() error {
+	return n.conn.Drain()
+}
@@ -0,0 +1,54 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+)
+
+// HandlerFunc processes a request message body and returns the reply body
+type HandlerFunc func(body []byte) []byte
+
+// Consumer reads prediction requests from a message broker and publishes
+// a reply for each one
+type Consumer interface {
+	// Run consumes requests until ctx is cancelled or the connection fails
+	Run(ctx context.Context, handle HandlerFunc) error
+	// Ping checks that the broker connection is usable
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// Config selects and configures a consumer
+type Config struct {
+	// Driver is "nats" or "amqp" (RabbitMQ)
+	Driver string
+	URL    string
+	// RequestQueue is the NATS subject or AMQP queue requests are read from
+	RequestQueue string
+	// ReplyQueue receives replies for requests without their own reply address
+	ReplyQueue string
+	// Concurrency is the number of requests processed in parallel
+	Concurrency int
+}
+
+// consumerGroup is the NATS queue group and AMQP consumer tag, so replicas
+// share the request load
+const consumerGroup = "cloud-ai-api"
+
+// New connects a consumer for the configured driver
+func New(cfg Config) (Consumer, error) {
+	if cfg.RequestQueue == "" {
+		return nil, fmt.Errorf("request queue is required")
+	}
+	if cfg.Concurrency < 1 {
+		cfg.Concurrency = 1
+	}
+
+	switch cfg.Driver {
+	case "nats":
+		return newNATSConsumer(cfg)
+	case "amqp":
+		return newAMQPConsumer(cfg)
+	}
+	return nil, fmt.Errorf("unknown queue driver %q (must be nats or amqp)", cfg.Driver)
+}
@@ -0,0 +1,388 @@
+package registry
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cloud-ai-api/hooks"
+	"cloud-ai-api/mlcontract"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+//go:embed default.json
+var defaultRegistry []byte
+
+// Field describes a single request field and its validation rules. Aliases
+// are shorter names the command-line client accepts for the field.
+// Synonyms map full words to enum values, such as "detached" to "D", for
+// lenient requests.
+type Field struct {
+	Name     string            `json:"name"`
+	Label    string            `json:"label,omitempty"`
+	Type     string            `json:"type"`
+	Required bool              `json:"required,omitempty"`
+	Enum     []string          `json:"enum,omitempty"`
+	Min      *float64          `json:"min,omitempty"`
+	Max      *float64          `json:"max,omitempty"`
+	Aliases  []string          `json:"aliases,omitempty"`
+	Synonyms map[string]string `json:"synonyms,omitempty"`
+}
+
+// Model describes a prediction model exposed by the gateway. Requests are
+// validated against Fields and, if declared, against a JSON Schema given
+// inline (Schema) or as a file (SchemaFile). Hooks lists config-defined
+// transformations per pipeline stage. Canary is an optional known-good
+// payload used to self-test the ML service at startup. StreamResponse
+// passes the ML service's response body through to the client as it
+// arrives, for models returning large result sets such as forecast
+// horizons; such responses are not parsed, so post-response hooks, field
+// selection and the response cache do not apply to them. Affinity sends
+// each client's requests to the same ML backend, for backends keeping
+// per-client state. Interval names the response fields holding the point
+// estimate and its confidence interval, for models that report one.
+// Leaderboard lists the values of a field to rank by predicted value. CostPerCall is what one ML service call for the
+// model costs, overriding the gateway's default. Currency is the ISO 4217
+// code of the predicted value, for models that predict a price. Response
+// is the schema the ML service's responses must match; responses that do
+// not are rejected.
+type Model struct {
+	Name           string                            `json:"name"`
+	Description    string                            `json:"description,omitempty"`
+	MLPath         string                            `json:"ml_path"`
+	Fields         []Field                           `json:"fields,omitempty"`
+	Schema         json.RawMessage                   `json:"schema,omitempty"`
+	SchemaFile     string                            `json:"schema_file,omitempty"`
+	Hooks          map[hooks.Stage][]hooks.Transform `json:"hooks,omitempty"`
+	Canary         json.RawMessage                   `json:"canary,omitempty"`
+	StreamResponse bool                              `json:"stream_response,omitempty"`
+	Affinity       bool                              `json:"affinity,omitempty"`
+	Interval       *Interval                         `json:"interval,omitempty"`
+	Leaderboard    *Leaderboard                      `json:"leaderboard,omitempty"`
+	CostPerCall    *float64                          `json:"cost_per_call,omitempty"`
+	Currency       string                            `json:"currency,omitempty"`
+	Response       *mlcontract.ResponseSchema        `json:"response,omitempty"`
+
+	compiled     *jsonschema.Schema
+	schemaSource json.RawMessage
+}
+
+// Interval names the response fields holding a model's point estimate and
+// the lower and upper bounds of its confidence interval. Levels lists the
+// confidence levels (as fractions) callers may ask the ML service for, and
+// Quantiles whether it can report arbitrary quantiles.
+type Interval struct {
+	Value     string    `json:"value"`
+	Lower     string    `json:"lower"`
+	Upper     string    `json:"upper"`
+	Levels    []float64 `json:"levels,omitempty"`
+	Quantiles bool      `json:"quantiles,omitempty"`
+}
+
+// Leaderboard names a string field and the values of it that a leaderboard
+// predicts and ranks, such as the counties compared for one property
+type Leaderboard struct {
+	Field  string   `json:"field"`
+	Values []string `json:"values"`
+}
+
+// Registry holds the models the gateway can route predictions to
+type Registry struct {
+	models map[string]*Model
+	order  []string
+}
+
+// file is the on-disk registry format
+type file struct {
+	Models []Model `json:"models"`
+}
+
+// Default returns the built-in registry with the housing and electricity models
+func Default() *Registry {
+	reg, err := parse(defaultRegistry, ".")
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in model registry: %s", err))
+	}
+	return reg
+}
+
+// Load reads a registry from a JSON file. Schema files referenced by the
+// registry are resolved relative to the registry file's directory.
+func Load(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model registry: %w", err)
+	}
+	return parse(data, filepath.Dir(path))
+}
+
+// Parse builds a registry from its JSON representation
+func Parse(data []byte) (*Registry, error) {
+	return parse(data, ".")
+}
+
+func parse(data []byte, baseDir string) (*Registry, error) {
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse model registry: %w", err)
+	}
+
+	reg := &Registry{models: make(map[string]*Model)}
+	for i := range f.Models {
+		m := f.Models[i]
+		if err := m.check(); err != nil {
+			return nil, err
+		}
+		if err := m.compileSchema(baseDir); err != nil {
+			return nil, err
+		}
+		if _, exists := reg.models[m.Name]; exists {
+			return nil, fmt.Errorf("model %q declared more than once", m.Name)
+		}
+		reg.models[m.Name] = &m
+		reg.order = append(reg.order, m.Name)
+	}
+	if len(reg.models) == 0 {
+		return nil, fmt.Errorf("model registry declares no models")
+	}
+	return reg, nil
+}
+
+// Get returns the model with the given name
+func (r *Registry) Get(name string) (*Model, bool) {
+	m, ok := r.models[name]
+	return m, ok
+}
+
+// Names returns the registered model names in declaration order
+func (r *Registry) Names() []string {
+	names := make([]string, len(r.order))
+	copy(names, r.order)
+	return names
+}
+
+// Models returns the registered models in declaration order
+func (r *Registry) Models() []*Model {
+	models := make([]*Model, 0, len(r.order))
+	for _, name := range r.order {
+		models = append(models, r.models[name])
+	}
+	return models
+}
+
+// Field finds a declared field by name or alias
+func (m *Model) Field(name string) (*Field, bool) {
+	for i := range m.Fields {
+		f := &m.Fields[i]
+		if f.Name == name || contains(f.Aliases, name) {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// Synonym finds the enum value a word stands for, ignoring case and
+// treating hyphens and underscores as spaces
+func (f *Field) Synonym(word string) (string, bool) {
+	value, ok := f.Synonyms[synonymKey(word)]
+	return value, ok
+}
+
+// synonymKey normalizes a word for synonym lookup, so "Semi-Detached",
+// "semi_detached" and "semi detached" all match
+func synonymKey(s string) string {
+	s = strings.NewReplacer("-", " ", "_", " ").Replace(strings.ToLower(s))
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// CanaryPayload decodes a fresh copy of the model's canary payload, if declared
+func (m *Model) CanaryPayload() (map[string]interface{}, bool) {
+	if len(m.Canary) == 0 {
+		return nil, false
+	}
+	var payload map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(m.Canary))
+	dec.UseNumber()
+	if err := dec.Decode(&payload); err != nil || payload == nil {
+		return nil, false
+	}
+	return payload, true
+}
+
+// FeatureSetHash identifies the features the model is sent: a short hex
+// SHA-256 of its declared fields, schema (inline or from its file) and
+// hooks. It changes whenever a registry change could change what reaches
+// the ML service.
+func (m *Model) FeatureSetHash() string {
+	data, _ := json.Marshal(struct {
+		Fields []Field                           `json:"fields"`
+		Schema json.RawMessage                   `json:"schema,omitempty"`
+		Hooks  map[hooks.Stage][]hooks.Transform `json:"hooks,omitempty"`
+	}{m.Fields, m.schemaSource, m.Hooks})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// check verifies that a model declaration is usable
+func (m *Model) check() error {
+	if m.Name == "" {
+		return fmt.Errorf("model declared without a name")
+	}
+	if !strings.HasPrefix(m.MLPath, "/") {
+		return fmt.Errorf("model %q: ml_path must start with '/'", m.Name)
+	}
+
+	if len(m.Canary) > 0 {
+		if _, ok := m.CanaryPayload(); !ok {
+			return fmt.Errorf("model %q: canary must be a JSON object", m.Name)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, f := range m.Fields {
+		if f.Name == "" {
+			return fmt.Errorf("model %q: field declared without a name", m.Name)
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("model %q: field %q declared more than once", m.Name, f.Name)
+		}
+		seen[f.Name] = true
+
+		if !validTypes[f.Type] {
+			return fmt.Errorf("model %q: field %q has unknown type %q (must be one of: %s)",
+				m.Name, f.Name, f.Type, strings.Join(typeNames(), ", "))
+		}
+
+		for word, value := range f.Synonyms {
+			if synonymKey(word) != word {
+				return fmt.Errorf("model %q: synonym %q of field %q must be lower case with words separated by single spaces", m.Name, word, f.Name)
+			}
+			if !contains(f.Enum, value) {
+				return fmt.Errorf("model %q: synonym %q of field %q must map to one of its enum values", m.Name, word, f.Name)
+			}
+		}
+	}
+
+	for _, f := range m.Fields {
+		for _, alias := range f.Aliases {
+			if seen[alias] {
+				return fmt.Errorf("model %q: alias %q of field %q is already a field name or alias", m.Name, alias, f.Name)
+			}
+			seen[alias] = true
+		}
+	}
+
+	if m.StreamResponse && len(m.Hooks[hooks.PostResponse]) > 0 {
+		return fmt.Errorf("model %q: %s hooks cannot be used with stream_response", m.Name, hooks.PostResponse)
+	}
+
+	if m.Response != nil {
+		if m.StreamResponse {
+			return fmt.Errorf("model %q: response cannot be used with stream_response", m.Name)
+		}
+		if err := m.Response.Check(); err != nil {
+			return fmt.Errorf("model %q: response: %w", m.Name, err)
+		}
+	}
+
+	if i := m.Interval; i != nil {
+		if i.Value == "" || i.Lower == "" || i.Upper == "" {
+			return fmt.Errorf("model %q: interval must name the value, lower and upper fields", m.Name)
+		}
+		for _, level := range i.Levels {
+			if level <= 0 || level >= 1 {
+				return fmt.Errorf("model %q: interval levels must be between 0 and 1 (exclusive)", m.Name)
+			}
+		}
+	}
+
+	if lb := m.Leaderboard; lb != nil {
+		if m.Interval == nil || m.StreamResponse {
+			return fmt.Errorf("model %q: leaderboard requires an interval naming the value to rank by", m.Name)
+		}
+		declared := false
+		for _, f := range m.Fields {
+			if f.Name == lb.Field {
+				declared = f.Type == "string"
+			}
+		}
+		if !declared {
+			return fmt.Errorf("model %q: leaderboard field %q must be a declared string field", m.Name, lb.Field)
+		}
+		if len(lb.Values) < 2 {
+			return fmt.Errorf("model %q: leaderboard must list at least two values", m.Name)
+		}
+		values := make(map[string]bool)
+		for _, v := range lb.Values {
+			if values[v] {
+				return fmt.Errorf("model %q: leaderboard value %q listed more than once", m.Name, v)
+			}
+			values[v] = true
+		}
+	}
+
+	if m.Currency != "" {
+		if m.Interval == nil || m.StreamResponse {
+			return fmt.Errorf("model %q: currency requires an interval naming the predicted value", m.Name)
+		}
+		if !isCurrencyCode(m.Currency) {
+			return fmt.Errorf("model %q: currency must be an ISO 4217 code such as GBP", m.Name)
+		}
+	}
+
+	if m.CostPerCall != nil && *m.CostPerCall < 0 {
+		return fmt.Errorf("model %q: cost_per_call must not be negative", m.Name)
+	}
+
+	for stage, transforms := range m.Hooks {
+		if !hooks.ValidStage(stage) {
+			return fmt.Errorf("model %q: unknown hook stage %q", m.Name, stage)
+		}
+		for _, t := range transforms {
+			if err := t.Check(); err != nil {
+				return fmt.Errorf("model %q: %s: %w", m.Name, stage, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validTypes lists the field types understood by the validator
+var validTypes = map[string]bool{
+	"string":  true,
+	"integer": true,
+	"number":  true,
+	"boolean": true,
+	"object":  true,
+	"array":   true,
+}
+
+func typeNames() []string {
+	names := make([]string, 0, len(validTypes))
+	for name := range validTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isCurrencyCode reports whether s looks like an ISO 4217 code: three
+// upper-case letters
+func isCurrencyCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package registry
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// compileSchema loads and compiles the model's JSON Schema, if one is declared.
+// Relative schema_file paths are resolved against baseDir.
+func (m *Model) compileSchema(baseDir string) error {
+	if m.SchemaFile != "" && len(m.Schema) > 0 {
+		return fmt.Errorf("model %q: declare either schema or schema_file, not both", m.Name)
+	}
+
+	source := []byte(m.Schema)
+	if m.SchemaFile != "" {
+		path := m.SchemaFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("model %q: failed to read schema: %w", m.Name, err)
+		}
+		source = data
+	}
+	if len(source) == 0 {
+		return nil
+	}
+	m.schemaSource = source
+
+	url := fmt.Sprintf("registry://%s.schema.json", m.Name)
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(url, bytes.NewReader(source)); err != nil {
+		return fmt.Errorf("model %q: failed to load schema: %w", m.Name, err)
+	}
+	schema, err := compiler.Compile(url)
+	if err != nil {
+		return fmt.Errorf("model %q: failed to compile schema: %w", m.Name, err)
+	}
+
+	m.compiled = schema
+	return nil
+}
+
+// validateSchema checks a payload against the compiled schema and returns
+// one error per failing leaf, located by JSON pointer
+func (m *Model) validateSchema(payload map[string]interface{}) []FieldError {
+	err := m.compiled.Validate(payload)
+	if err == nil {
+		return nil
+	}
+
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return []FieldError{{Label: "request", Message: err.Error()}}
+	}
+
+	var errs []FieldError
+	for _, leaf := range leaves(verr) {
+		field := topLevelField(leaf.InstanceLocation)
+		label := "request"
+		if field != "" {
+			label = strings.ReplaceAll(field, "_", " ")
+		}
+		errs = append(errs, FieldError{
+			Field:   field,
+			Label:   label,
+			Pointer: leaf.InstanceLocation,
+			Message: leaf.Message,
+		})
+	}
+	return errs
+}
+
+// leaves flattens a validation error tree into its most specific causes
+func leaves(verr *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(verr.Causes) == 0 {
+		return []*jsonschema.ValidationError{verr}
+	}
+
+	var out []*jsonschema.ValidationError
+	for _, cause := range verr.Causes {
+		out = append(out, leaves(cause)...)
+	}
+	return out
+}
+
+// topLevelField returns the first segment of a JSON pointer, unescaped
+func topLevelField(pointer string) string {
+	segment := strings.SplitN(strings.TrimPrefix(pointer, "/"), "/", 2)[0]
+	segment = strings.ReplaceAll(segment, "~1", "/")
+	return strings.ReplaceAll(segment, "~0", "~")
+}
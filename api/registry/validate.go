@@ -0,0 +1,198 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FieldError describes a validation failure for a single field. Pointer is
+// the JSON pointer of the offending value within the request payload.
+type FieldError struct {
+	Field   string
+	Label   string
+	Pointer string
+	Message string
+	Missing bool
+}
+
+// Validate checks a decoded request payload against the model's field rules
+// and JSON Schema. The payload should be decoded with json.Decoder.UseNumber
+// so integer fields can be told apart from fractional numbers.
+func (m *Model) Validate(payload map[string]interface{}) []FieldError {
+	errs := m.validateFields(payload)
+	if m.compiled != nil {
+		errs = append(errs, m.validateSchema(payload)...)
+	}
+	return errs
+}
+
+// validateFields checks the payload against the declared field rules
+func (m *Model) validateFields(payload map[string]interface{}) []FieldError {
+	var errs []FieldError
+
+	for _, f := range m.Fields {
+		value, present := payload[f.Name]
+		if !present || value == nil {
+			if f.Required {
+				errs = append(errs, FieldError{
+					Field:   f.Name,
+					Label:   f.label(),
+					Pointer: "/" + f.Name,
+					Message: "Field is required",
+					Missing: true,
+				})
+			}
+			continue
+		}
+
+		if msg := f.check(value); msg != "" {
+			errs = append(errs, FieldError{Field: f.Name, Label: f.label(), Pointer: "/" + f.Name, Message: msg})
+		}
+	}
+
+	return errs
+}
+
+// UnknownFields reports payload fields the model does not declare. Models
+// without declared fields accept any field.
+func (m *Model) UnknownFields(payload map[string]interface{}) []FieldError {
+	if len(m.Fields) == 0 {
+		return nil
+	}
+	declared := make(map[string]bool, len(m.Fields))
+	for _, f := range m.Fields {
+		declared[f.Name] = true
+	}
+
+	var names []string
+	for name := range payload {
+		if !declared[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	errs := make([]FieldError, 0, len(names))
+	for _, name := range names {
+		errs = append(errs, FieldError{
+			Field:   name,
+			Label:   strings.ReplaceAll(name, "_", " "),
+			Pointer: "/" + name,
+			Message: "Unknown field",
+		})
+	}
+	return errs
+}
+
+// label returns the human-readable field name used in error messages
+func (f *Field) label() string {
+	if f.Label != "" {
+		return f.Label
+	}
+	return strings.ReplaceAll(f.Name, "_", " ")
+}
+
+// check validates a single present value, returning an empty string if valid
+func (f *Field) check(value interface{}) string {
+	switch f.Type {
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return "Must be a string"
+		}
+		if len(f.Enum) > 0 && !contains(f.Enum, s) {
+			return fmt.Sprintf("Must be one of: %s", strings.Join(f.Enum, ", "))
+		}
+	case "integer", "number":
+		n, ok := toFloat(value)
+		if !ok {
+			return fmt.Sprintf("Must be %s", article(f.Type))
+		}
+		if f.Type == "integer" && n != math.Trunc(n) {
+			return "Must be an integer"
+		}
+		if msg := f.checkRange(n); msg != "" {
+			return msg
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return "Must be a boolean"
+		}
+	case "object":
+		if _, ok := value.(map[string]interface{}); !ok {
+			return "Must be an object"
+		}
+	case "array":
+		if _, ok := value.([]interface{}); !ok {
+			return "Must be an array"
+		}
+	}
+	return ""
+}
+
+// checkRange validates a numeric value against the field's min/max bounds
+func (f *Field) checkRange(n float64) string {
+	switch {
+	case f.Min != nil && f.Max != nil && (n < *f.Min || n > *f.Max):
+		return fmt.Sprintf("Must be between %s and %s", formatBound(*f.Min), formatBound(*f.Max))
+	case f.Min != nil && n < *f.Min:
+		return fmt.Sprintf("Must be at least %s", formatBound(*f.Min))
+	case f.Max != nil && n > *f.Max:
+		return fmt.Sprintf("Must be at most %s", formatBound(*f.Max))
+	}
+	return ""
+}
+
+// TypedValue converts text from an untyped source (query string, XML,
+// command line) to a field type, leaving it as a string if it does not
+// parse so validation reports the error
+func TypedValue(s, fieldType string) interface{} {
+	switch fieldType {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(s, 64); err == nil {
+			return json.Number(s)
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	}
+	return s
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		n, err := v.Float64()
+		return n, err == nil
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func formatBound(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+func article(typ string) string {
+	if typ == "integer" {
+		return "an integer"
+	}
+	return "a " + typ
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Record is one captured prediction request
+type Record struct {
+	At        time.Time              `json:"at"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Model     string                 `json:"model"`
+	Payload   map[string]interface{} `json:"payload"`
+}
+
+// Capture describes a capture session and how much it has recorded
+type Capture struct {
+	Destination string     `json:"destination"`
+	SampleRate  float64    `json:"sample_rate"`
+	MaxRequests int        `json:"max_requests,omitempty"`
+	Models      []string   `json:"models,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	StoppedAt   *time.Time `json:"stopped_at,omitempty"`
+	Captured    int        `json:"captured"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// Recorder writes sampled prediction requests as JSON lines while a
+// capture is active. Only one capture runs at a time.
+type Recorder struct {
+	mu     sync.Mutex
+	active *Capture
+	last   *Capture
+	out    io.WriteCloser
+	models map[string]bool
+}
+
+// NewRecorder creates a recorder with no capture active
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Start begins a capture writing to out, which is closed when the capture
+// stops
+func (r *Recorder) Start(c Capture, out io.WriteCloser) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.active != nil {
+		return fmt.Errorf("a capture to %s is already running", r.active.Destination)
+	}
+	c.StartedAt = time.Now()
+	c.StoppedAt = nil
+	c.Captured = 0
+	c.Error = ""
+	r.active = &c
+	r.out = out
+	r.models = nil
+	if len(c.Models) > 0 {
+		r.models = make(map[string]bool, len(c.Models))
+		for _, m := range c.Models {
+			r.models[m] = true
+		}
+	}
+	return nil
+}
+
+// Active reports whether a capture is running
+func (r *Recorder) Active() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.active != nil
+}
+
+// Record writes rec if a capture is running, the model is included and the
+// request is sampled. The capture stops once it reaches MaxRequests; the
+// output is then closed in the background.
+func (r *Recorder) Record(rec Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := r.active
+	if c == nil || (r.models != nil && !r.models[rec.Model]) {
+		return
+	}
+	if c.SampleRate < 1 && rand.Float64() >= c.SampleRate {
+		return
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	if _, err := r.out.Write(append(line, '\n')); err != nil {
+		c.Error = err.Error()
+		go r.Stop()
+		return
+	}
+	c.Captured++
+	if c.MaxRequests > 0 && c.Captured >= c.MaxRequests {
+		go r.Stop()
+	}
+}
+
+// Stop ends the running capture, closing its output, and returns it. It
+// returns false if no capture was running.
+func (r *Recorder) Stop() (Capture, bool) {
+	r.mu.Lock()
+	c, out := r.active, r.out
+	if c == nil {
+		r.mu.Unlock()
+		return Capture{}, false
+	}
+	r.active, r.out = nil, nil
+	r.mu.Unlock()
+
+	// Closing may upload the capture, so it happens outside the lock
+	err := out.Close()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	c.StoppedAt = &now
+	if err != nil && c.Error == "" {
+		c.Error = err.Error()
+	}
+	r.last = c
+	return *c, true
+}
+
+// Status returns the running capture, or else the last finished one, and
+// whether it is running
+func (r *Recorder) Status() (*Capture, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.active != nil {
+		c := *r.active
+		return &c, true
+	}
+	if r.last != nil {
+		c := *r.last
+		return &c, false
+	}
+	return nil, false
+}
+
+// Read parses captured records, one JSON object per line. Blank lines are
+// skipped. It stops with an error after limit records if limit is positive.
+func Read(data []byte, limit int) ([]Record, error) {
+	var records []Record
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var rec Record
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.UseNumber()
+		if err := decoder.Decode(&rec); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if rec.Model == "" {
+			return nil, fmt.Errorf("line %d: missing model", n)
+		}
+		if limit > 0 && len(records) == limit {
+			return nil, fmt.Errorf("more than %d records", limit)
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,282 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud-ai-api/stats"
+)
+
+// Replay run statuses
+const (
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusCancelled = "cancelled"
+)
+
+// maxReportedErrors caps the errors kept on a run
+const maxReportedErrors = 20
+
+// RunHeader is sent with every replayed request, carrying the run ID, so
+// targets can tell replayed traffic apart and do not capture it again
+const RunHeader = "X-Replay-Run"
+
+// Target is where and how fast a captured set is re-sent
+type Target struct {
+	// URL is the base URL of the target gateway; requests go to
+	// <URL>/api/v1/predict/<model>, or to URL with {model} replaced if it
+	// contains that placeholder
+	URL         string
+	Rate        float64
+	Concurrency int
+	APIKey      string
+}
+
+// Run is a replay of a captured set against a target and its results
+type Run struct {
+	ID          string         `json:"id"`
+	Source      string         `json:"source"`
+	Target      string         `json:"target"`
+	Rate        float64        `json:"rate"`
+	Status      string         `json:"status"`
+	Total       int            `json:"total"`
+	Sent        int            `json:"sent"`
+	Succeeded   int            `json:"succeeded"`
+	Failed      int            `json:"failed"`
+	StatusCodes map[string]int `json:"status_codes"`
+	P50Ms       float64        `json:"p50_ms"`
+	P95Ms       float64        `json:"p95_ms"`
+	P99Ms       float64        `json:"p99_ms"`
+	MaxMs       float64        `json:"max_ms"`
+	Errors      []string       `json:"errors,omitempty"`
+	StartedAt   time.Time      `json:"started_at"`
+	CompletedAt *time.Time     `json:"completed_at,omitempty"`
+
+	latencies []float64
+	cancel    context.CancelFunc
+}
+
+// Replayer re-sends captured requests to target backends and keeps the
+// results of recent runs
+type Replayer struct {
+	client    *http.Client
+	retention time.Duration
+
+	mu   sync.Mutex
+	runs map[string]*Run
+	seq  uint64
+}
+
+// NewReplayer creates a replayer sending requests with client and
+// forgetting finished runs after retention
+func NewReplayer(client *http.Client, retention time.Duration) *Replayer {
+	return &Replayer{client: client, retention: retention, runs: make(map[string]*Run)}
+}
+
+// Start replays records against target in the background at target.Rate
+// requests per second, with at most target.Concurrency in flight
+func (p *Replayer) Start(source string, records []Record, target Target) Run {
+	if target.Concurrency < 1 {
+		target.Concurrency = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p.mu.Lock()
+	p.expire()
+	p.seq++
+	now := time.Now()
+	run := &Run{
+		ID:          fmt.Sprintf("replay-%d-%d", now.Unix(), p.seq),
+		Source:      source,
+		Target:      target.URL,
+		Rate:        target.Rate,
+		Status:      StatusRunning,
+		Total:       len(records),
+		StatusCodes: make(map[string]int),
+		StartedAt:   now,
+		cancel:      cancel,
+	}
+	p.runs[run.ID] = run
+	snapshot := run.snapshot()
+	p.mu.Unlock()
+
+	go p.run(ctx, run, records, target)
+	return snapshot
+}
+
+// Get returns a run by ID
+func (p *Replayer) Get(id string) (Run, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	run, ok := p.runs[id]
+	if !ok {
+		return Run{}, false
+	}
+	return run.snapshot(), true
+}
+
+// List returns the retained runs, newest first
+func (p *Replayer) List() []Run {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.expire()
+	runs := make([]Run, 0, len(p.runs))
+	for _, run := range p.runs {
+		runs = append(runs, run.snapshot())
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].StartedAt.After(runs[j].StartedAt) })
+	return runs
+}
+
+// Cancel stops a running replay. Requests already in flight complete.
+func (p *Replayer) Cancel(id string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	run, ok := p.runs[id]
+	if !ok || run.Status != StatusRunning {
+		return false
+	}
+	run.cancel()
+	return true
+}
+
+func (p *Replayer) run(ctx context.Context, run *Run, records []Record, target Target) {
+	interval := time.Duration(float64(time.Second) / target.Rate)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	sem := make(chan struct{}, target.Concurrency)
+	var wg sync.WaitGroup
+	cancelled := false
+	for i, rec := range records {
+		if i > 0 {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				cancelled = true
+			}
+		}
+		if cancelled {
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(rec Record) {
+			defer func() { <-sem; wg.Done() }()
+			status, latency, err := p.send(run.ID, rec, target)
+			p.observe(run, status, latency, err)
+		}(rec)
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	run.CompletedAt = &now
+	run.Status = StatusCompleted
+	if cancelled {
+		run.Status = StatusCancelled
+	}
+	run.cancel()
+}
+
+// send posts one captured request to the target and returns the response
+// status and latency
+func (p *Replayer) send(runID string, rec Record, target Target) (int, time.Duration, error) {
+	body, err := json.Marshal(rec.Payload)
+	if err != nil {
+		return 0, 0, err
+	}
+	req, err := http.NewRequest(http.MethodPost, targetURL(target.URL, rec.Model), bytes.NewReader(body))
+	if err != nil {
+		return 0, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(RunHeader, runID)
+	if rec.RequestID != "" {
+		req.Header.Set("X-Request-ID", runID+"-"+rec.RequestID)
+	}
+	if target.APIKey != "" {
+		req.Header.Set("X-API-Key", target.APIKey)
+	}
+
+	start := time.Now()
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, time.Since(start), err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp.StatusCode, time.Since(start), nil
+}
+
+// observe records the outcome of one replayed request
+func (p *Replayer) observe(run *Run, status int, latency time.Duration, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	run.Sent++
+	run.latencies = append(run.latencies, float64(latency.Microseconds())/1000)
+	switch {
+	case err != nil:
+		run.Failed++
+		run.StatusCodes["error"]++
+		if len(run.Errors) < maxReportedErrors {
+			run.Errors = append(run.Errors, err.Error())
+		}
+	case status >= 200 && status < 300:
+		run.Succeeded++
+		run.StatusCodes[strconv.Itoa(status)]++
+	default:
+		run.Failed++
+		run.StatusCodes[strconv.Itoa(status)]++
+	}
+}
+
+// snapshot copies a run with its latency percentiles filled in
+func (r *Run) snapshot() Run {
+	out := *r
+	out.StatusCodes = make(map[string]int, len(r.StatusCodes))
+	for code, n := range r.StatusCodes {
+		out.StatusCodes[code] = n
+	}
+	out.Errors = append([]string(nil), r.Errors...)
+	if len(r.latencies) > 0 {
+		sorted := append([]float64(nil), r.latencies...)
+		sort.Float64s(sorted)
+		out.P50Ms = stats.Percentile(sorted, 0.50)
+		out.P95Ms = stats.Percentile(sorted, 0.95)
+		out.P99Ms = stats.Percentile(sorted, 0.99)
+		out.MaxMs = sorted[len(sorted)-1]
+	}
+	out.latencies = nil
+	out.cancel = nil
+	return out
+}
+
+// expire forgets finished runs older than the retention period. The caller
+// must hold p.mu.
+func (p *Replayer) expire() {
+	cutoff := time.Now().Add(-p.retention)
+	for id, run := range p.runs {
+		if run.CompletedAt != nil && run.CompletedAt.Before(cutoff) {
+			delete(p.runs, id)
+		}
+	}
+}
+
+// targetURL is the prediction URL for model on the target
+func targetURL(base, model string) string {
+	if strings.Contains(base, "{model}") {
+		return strings.ReplaceAll(base, "{model}", model)
+	}
+	return strings.TrimSuffix(base, "/") + "/api/v1/predict/" + model
+}
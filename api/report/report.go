@@ -0,0 +1,295 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"strings"
+
+	"cloud-ai-api/pdf"
+)
+
+// Data is the content of a prediction report
+type Data struct {
+	Title        string
+	Subtitle     string
+	GeneratedAt  string
+	PredictionID string
+	ModelVersion string
+	Inputs       []Input
+	Value        float64
+	Lower        float64
+	Upper        float64
+	Warnings     []string
+	// Trend is the prediction for the same input in each of the months up
+	// to and including the one requested; months without one are omitted
+	Trend []TrendPoint
+}
+
+// Input is one labelled request field
+type Input struct {
+	Label string
+	Value string
+}
+
+// TrendPoint is the prediction and its confidence band for one month
+type TrendPoint struct {
+	Month string
+	Value float64
+	Lower float64
+	Upper float64
+}
+
+// Money formats an amount in pounds with thousands separators, e.g. £204,000
+func Money(v float64) string {
+	s := fmt.Sprintf("%.0f", math.Abs(v))
+	var b strings.Builder
+	for i, r := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	if v < 0 {
+		return "-£" + b.String()
+	}
+	return "£" + b.String()
+}
+
+// shortMoney formats an axis label, e.g. £250k
+func shortMoney(v float64) string {
+	if math.Abs(v) >= 1e6 {
+		return fmt.Sprintf("£%.1fm", v/1e6)
+	}
+	return fmt.Sprintf("£%.0fk", v/1e3)
+}
+
+// chart lays out the trend in a width x height box with the origin at the
+// top left, returning the scaled points and the y-axis ticks
+type chart struct {
+	values, lowers, uppers []float64
+	ticks                  []float64
+	min, max               float64
+	width, height          float64
+}
+
+func newChart(trend []TrendPoint, width, height float64) *chart {
+	c := &chart{width: width, height: height, min: math.Inf(1), max: math.Inf(-1)}
+	for _, p := range trend {
+		c.min = math.Min(c.min, p.Lower)
+		c.max = math.Max(c.max, p.Upper)
+	}
+	// Round the range out to a tick step of 1, 2 or 5 times a power of ten
+	span := c.max - c.min
+	if span <= 0 {
+		span = math.Max(math.Abs(c.max), 1)
+	}
+	step := math.Pow(10, math.Floor(math.Log10(span/4)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if span/(step*m) <= 5 {
+			step *= m
+			break
+		}
+	}
+	c.min = math.Floor(c.min/step) * step
+	c.max = math.Ceil(c.max/step) * step
+	if c.max == c.min {
+		c.max += step
+	}
+	for t := c.min; t <= c.max+step/2; t += step {
+		c.ticks = append(c.ticks, t)
+	}
+	return c
+}
+
+// x is the horizontal position of point i of n
+func (c *chart) x(i, n int) float64 {
+	if n < 2 {
+		return c.width / 2
+	}
+	return c.width * float64(i) / float64(n-1)
+}
+
+// y is the distance of v from the top of the chart
+func (c *chart) y(v float64) float64 {
+	return c.height * (c.max - v) / (c.max - c.min)
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"money": Money,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Data.Title}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; color: #222; max-width: 760px; margin: 32px auto; padding: 0 16px; }
+h1 { font-size: 24px; margin-bottom: 4px; color: #1f77b4; }
+.sub { color: #666; margin-top: 0; }
+.price { font-size: 36px; font-weight: bold; margin: 24px 0 4px; }
+.band { color: #444; }
+table { border-collapse: collapse; width: 100%; margin: 16px 0; }
+td { border-bottom: 1px solid #ddd; padding: 6px 4px; }
+td:first-child { color: #666; width: 40%; text-transform: capitalize; }
+.warning { background: #fff4e5; border-left: 4px solid #f0a030; padding: 8px 12px; margin: 8px 0; }
+footer { color: #888; font-size: 12px; margin-top: 32px; }
+svg text { font-size: 11px; fill: #555; }
+</style>
+</head>
+<body>
+<h1>{{.Data.Title}}</h1>
+<p class="sub">{{.Data.Subtitle}}</p>
+<div class="price">{{money .Data.Value}}</div>
+<div class="band">Confidence band: {{money .Data.Lower}} – {{money .Data.Upper}}</div>
+{{range .Data.Warnings}}<div class="warning">{{.}}</div>
+{{end}}<h2>Property</h2>
+<table>
+{{range .Data.Inputs}}<tr><td>{{.Label}}</td><td>{{.Value}}</td></tr>
+{{end}}</table>
+{{if .Chart}}<h2>12-Month Trend</h2>
+{{.Chart}}
+{{end}}<footer>Generated {{.Data.GeneratedAt}}{{if .Data.PredictionID}} · Prediction {{.Data.PredictionID}}{{end}}{{if .Data.ModelVersion}} · Model {{.Data.ModelVersion}}{{end}}</footer>
+</body>
+</html>
+`))
+
+// HTML writes the report as a self-contained HTML page with an inline SVG
+// trend chart
+func HTML(w io.Writer, d *Data) error {
+	return htmlTemplate.Execute(w, struct {
+		Data  *Data
+		Chart template.HTML
+	}{d, svgChart(d.Trend)})
+}
+
+// svgChart draws the trend as an SVG line with a shaded confidence band
+func svgChart(trend []TrendPoint) template.HTML {
+	if len(trend) == 0 {
+		return ""
+	}
+	const left, top, width, height = 60.0, 10.0, 660.0, 220.0
+	c := newChart(trend, width, height)
+	n := len(trend)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="100%%" viewBox="0 0 %g %g">`, left+width+20, top+height+30)
+	for _, t := range c.ticks {
+		y := top + c.y(t)
+		fmt.Fprintf(&b, `<line x1="%g" y1="%.1f" x2="%g" y2="%.1f" stroke="#e5e5e5"/>`, left, y, left+width, y)
+		fmt.Fprintf(&b, `<text x="%g" y="%.1f" text-anchor="end">%s</text>`, left-6, y+4, template.HTMLEscapeString(shortMoney(t)))
+	}
+	var band, line []string
+	for i, p := range trend {
+		band = append(band, fmt.Sprintf("%.1f,%.1f", left+c.x(i, n), top+c.y(p.Upper)))
+		line = append(line, fmt.Sprintf("%.1f,%.1f", left+c.x(i, n), top+c.y(p.Value)))
+	}
+	for i := n - 1; i >= 0; i-- {
+		band = append(band, fmt.Sprintf("%.1f,%.1f", left+c.x(i, n), top+c.y(trend[i].Lower)))
+	}
+	fmt.Fprintf(&b, `<polygon points="%s" fill="#1f77b4" fill-opacity="0.15"/>`, strings.Join(band, " "))
+	fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="#1f77b4" stroke-width="2"/>`, strings.Join(line, " "))
+	for i, p := range trend {
+		x := left + c.x(i, n)
+		fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" fill="#1f77b4"><title>%s: %s</title></circle>`,
+			x, top+c.y(p.Value), p.Month, template.HTMLEscapeString(Money(p.Value)))
+		fmt.Fprintf(&b, `<text x="%.1f" y="%g" text-anchor="middle">%s</text>`, x, top+height+18, p.Month[2:])
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// Colors used in the PDF report
+var (
+	ink    = pdf.Color{R: 0.13, G: 0.13, B: 0.13}
+	muted  = pdf.Color{R: 0.45, G: 0.45, B: 0.45}
+	accent = pdf.Color{R: 0.12, G: 0.47, B: 0.71}
+	shade  = pdf.Color{R: 0.85, G: 0.91, B: 0.96}
+	rule   = pdf.Color{R: 0.87, G: 0.87, B: 0.87}
+	amber  = pdf.Color{R: 0.94, G: 0.63, B: 0.19}
+)
+
+// PDF writes the report as a one-page A4 PDF
+func PDF(w io.Writer, d *Data) error {
+	doc := pdf.New(d.Title)
+	page := doc.AddPage()
+	const margin = 56.0
+	y := pdf.PageHeight - margin
+
+	page.Text(margin, y, 22, true, accent, d.Title)
+	y -= 18
+	page.Text(margin, y, 11, false, muted, d.Subtitle)
+	y -= 44
+	page.Text(margin, y, 30, true, ink, Money(d.Value))
+	y -= 20
+	page.Text(margin, y, 11, false, ink, "Confidence band: "+Money(d.Lower)+" – "+Money(d.Upper))
+	y -= 16
+
+	for _, warning := range d.Warnings {
+		y -= 20
+		page.Rect(margin, y-6, 3, 18, amber)
+		page.Text(margin+10, y, 10, false, ink, warning)
+	}
+
+	y -= 34
+	page.Text(margin, y, 14, true, ink, "Property")
+	y -= 8
+	for _, in := range d.Inputs {
+		y -= 20
+		page.Text(margin, y, 10, false, muted, strings.ToUpper(in.Label[:1])+in.Label[1:])
+		page.Text(margin+180, y, 10, false, ink, in.Value)
+		page.Line(pdf.Point{X: margin, Y: y - 6}, pdf.Point{X: pdf.PageWidth - margin, Y: y - 6}, 0.5, rule)
+	}
+
+	if len(d.Trend) > 0 {
+		y -= 40
+		page.Text(margin, y, 14, true, ink, "12-Month Trend")
+		y -= 16
+		pdfChart(page, d.Trend, margin+50, y, pdf.PageWidth-2*margin-60, 200)
+		y -= 240
+	}
+
+	footer := "Generated " + d.GeneratedAt
+	if d.PredictionID != "" {
+		footer += " • Prediction " + d.PredictionID
+	}
+	if d.ModelVersion != "" {
+		footer += " • Model " + d.ModelVersion
+	}
+	page.Text(margin, margin-20, 8, false, muted, footer)
+
+	_, err := doc.WriteTo(w)
+	return err
+}
+
+// pdfChart draws the trend below top with its plot area starting at left
+func pdfChart(page *pdf.Page, trend []TrendPoint, left, top, width, height float64) {
+	c := newChart(trend, width, height)
+	n := len(trend)
+	at := func(i int, v float64) pdf.Point {
+		return pdf.Point{X: left + c.x(i, n), Y: top - c.y(v)}
+	}
+
+	for _, t := range c.ticks {
+		y := top - c.y(t)
+		page.Line(pdf.Point{X: left, Y: y}, pdf.Point{X: left + width, Y: y}, 0.5, rule)
+		label := shortMoney(t)
+		page.Text(left-6-pdf.TextWidth(label, 8), y-3, 8, false, muted, label)
+	}
+	var band, line []pdf.Point
+	for i, p := range trend {
+		band = append(band, at(i, p.Upper))
+		line = append(line, at(i, p.Value))
+	}
+	for i := n - 1; i >= 0; i-- {
+		band = append(band, at(i, trend[i].Lower))
+	}
+	page.Polygon(band, shade)
+	page.Polyline(line, 1.5, accent)
+	for i, p := range trend {
+		pt := at(i, p.Value)
+		page.Rect(pt.X-1.5, pt.Y-1.5, 3, 3, accent)
+		label := p.Month[2:]
+		page.Text(pt.X-pdf.TextWidth(label, 7)/2, top-height-14, 7, false, muted, label)
+	}
+}
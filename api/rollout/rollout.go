@@ -0,0 +1,308 @@
+package rollout
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Rollout states
+const (
+	Running    = "running"
+	Promoted   = "promoted"
+	RolledBack = "rolled_back"
+	Aborted    = "aborted"
+)
+
+// Step decisions
+const (
+	Hold     = "hold"
+	Raise    = "raise"
+	Promote  = "promote"
+	RollBack = "roll_back"
+)
+
+// maxSamples caps the latencies kept per step; reservoir sampling keeps a
+// uniform sample of the step's calls
+const maxSamples = 10000
+
+// Rollout sends a share of ML calls to a new backend, raising the share
+// every Interval while the backend's error rate and latency stay within
+// bounds, and rolling back when they do not. Models limits it to some
+// models; empty means all.
+type Rollout struct {
+	Backend         string   `json:"backend"`
+	Models          []string `json:"models,omitempty"`
+	Percent         float64  `json:"percent"`
+	Step            float64  `json:"step"`
+	IntervalSeconds int      `json:"interval_seconds"`
+	MinRequests     int      `json:"min_requests"`
+	MaxErrorRate    float64  `json:"max_error_rate"`
+	MaxLatencyRatio float64  `json:"max_latency_ratio,omitempty"`
+
+	State      string     `json:"state"`
+	Reason     string     `json:"reason,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Steps      []Step     `json:"steps"`
+
+	// BaselineP95Ms is the stable backends' latency the new backend is
+	// held to, from the last step in which they had enough calls
+	BaselineP95Ms float64 `json:"baseline_p95_ms,omitempty"`
+}
+
+// Step is the outcome of one evaluation: the calls to the new backend and
+// the stable ones since the previous step, at the share in force, and what
+// was decided
+type Step struct {
+	At       time.Time `json:"at"`
+	Percent  float64   `json:"percent"`
+	Canary   Summary   `json:"canary"`
+	Stable   Summary   `json:"stable"`
+	Decision string    `json:"decision"`
+	Reason   string    `json:"reason,omitempty"`
+}
+
+// Summary describes the ML calls to one side of a rollout
+type Summary struct {
+	Count     int64   `json:"count"`
+	Errors    int64   `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+	P95Ms     float64 `json:"p95_ms"`
+}
+
+// Controller runs one rollout at a time
+type Controller struct {
+	mu     sync.Mutex
+	active *Rollout
+	last   *Rollout
+	models map[string]bool
+	canary series
+	stable series
+	stop   chan struct{}
+	notify func(Rollout, Step)
+}
+
+type series struct {
+	count   int64
+	errors  int64
+	samples []float64
+}
+
+// NewController creates a controller with no rollout active. notify, if
+// set, is called after every step, outside the controller's lock.
+func NewController(notify func(Rollout, Step)) *Controller {
+	return &Controller{notify: notify}
+}
+
+// Start begins a rollout, evaluating it every IntervalSeconds
+func (c *Controller) Start(r Rollout) error {
+	if r.IntervalSeconds < 1 {
+		return fmt.Errorf("interval_seconds must be at least 1")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active != nil {
+		return fmt.Errorf("a rollout of %s is already %s", c.active.Backend, c.active.State)
+	}
+	r.State = Running
+	r.Reason = ""
+	r.StartedAt = time.Now()
+	r.FinishedAt = nil
+	r.Steps = []Step{}
+	r.BaselineP95Ms = 0
+	c.active = &r
+	c.models = nil
+	if len(r.Models) > 0 {
+		c.models = make(map[string]bool, len(r.Models))
+		for _, m := range r.Models {
+			c.models[m] = true
+		}
+	}
+	c.canary, c.stable = series{}, series{}
+	c.stop = make(chan struct{})
+	go c.run(c.stop, time.Duration(r.IntervalSeconds)*time.Second)
+	return nil
+}
+
+func (c *Controller) run(stop chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if !c.evaluate(now) {
+				return
+			}
+		}
+	}
+}
+
+// Target returns the new backend if a call for model should go to it. A
+// non-empty key, such as an affinity key, always gets the same answer at a
+// given share, and keeps it as the share rises; without one the call is
+// sampled at random.
+func (c *Controller) Target(model, key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r := c.active
+	if r == nil || (c.models != nil && !c.models[model]) {
+		return "", false
+	}
+	if r.State == Promoted {
+		return r.Backend, true
+	}
+	var point float64
+	if key != "" {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		point = float64(h.Sum32()%10000) / 100
+	} else {
+		point = rand.Float64() * 100
+	}
+	return r.Backend, point < r.Percent
+}
+
+// Observe records an ML call for model to the new backend (canary) or a
+// stable one while a rollout is running
+func (c *Controller) Observe(model string, canary bool, latency time.Duration, failed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active == nil || c.active.State != Running || (c.models != nil && !c.models[model]) {
+		return
+	}
+	s := &c.stable
+	if canary {
+		s = &c.canary
+	}
+	s.observe(float64(latency.Microseconds())/1000, failed)
+}
+
+// Stop ends the active rollout and returns it: a running one is aborted,
+// sending every call back to the stable backends, and a promoted one stops
+// routing to the new backend. It returns false if none is active.
+func (c *Controller) Stop() (Rollout, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r := c.active
+	if r == nil {
+		return Rollout{}, false
+	}
+	if r.State == Running {
+		close(c.stop)
+		r.State = Aborted
+		r.Reason = "stopped by an admin"
+		now := time.Now()
+		r.FinishedAt = &now
+	}
+	c.active, c.last = nil, r
+	return copyRollout(r), true
+}
+
+// Status returns the active rollout, or else the last one, and whether it
+// is active
+func (c *Controller) Status() (*Rollout, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active != nil {
+		r := copyRollout(c.active)
+		return &r, true
+	}
+	if c.last != nil {
+		r := copyRollout(c.last)
+		return &r, false
+	}
+	return nil, false
+}
+
+// evaluate decides the running rollout's next step from the calls since
+// the last one, reporting whether it is still running
+func (c *Controller) evaluate(now time.Time) bool {
+	c.mu.Lock()
+	r := c.active
+	if r == nil || r.State != Running {
+		c.mu.Unlock()
+		return false
+	}
+	step := Step{At: now, Percent: r.Percent, Canary: c.canary.summary(), Stable: c.stable.summary()}
+	if step.Stable.Count >= int64(r.MinRequests) && step.Stable.Count > 0 {
+		r.BaselineP95Ms = step.Stable.P95Ms
+	}
+	switch {
+	case step.Canary.Count < int64(r.MinRequests) || step.Canary.Count == 0:
+		step.Decision = Hold
+		step.Reason = fmt.Sprintf("%d of %d calls needed", step.Canary.Count, r.MinRequests)
+	case step.Canary.ErrorRate > r.MaxErrorRate:
+		step.Decision = RollBack
+		step.Reason = fmt.Sprintf("error rate %.3f above %.3f", step.Canary.ErrorRate, r.MaxErrorRate)
+	case r.MaxLatencyRatio > 0 && r.BaselineP95Ms > 0 && step.Canary.P95Ms > r.BaselineP95Ms*r.MaxLatencyRatio:
+		step.Decision = RollBack
+		step.Reason = fmt.Sprintf("p95 latency %.1fms above %.2f times the stable %.1fms",
+			step.Canary.P95Ms, r.MaxLatencyRatio, r.BaselineP95Ms)
+	case r.Percent >= 100:
+		step.Decision = Promote
+	default:
+		step.Decision = Raise
+		r.Percent += r.Step
+		if r.Percent > 100 {
+			r.Percent = 100
+		}
+	}
+	if step.Decision != Hold {
+		c.canary, c.stable = series{}, series{}
+	}
+	switch step.Decision {
+	case RollBack:
+		r.State, r.Reason, r.Percent = RolledBack, step.Reason, 0
+		r.FinishedAt = &now
+		c.active, c.last = nil, r
+	case Promote:
+		r.State, r.Reason = Promoted, "within bounds at 100%"
+		r.FinishedAt = &now
+	}
+	r.Steps = append(r.Steps, step)
+	snapshot := copyRollout(r)
+	c.mu.Unlock()
+
+	if c.notify != nil {
+		c.notify(snapshot, step)
+	}
+	return snapshot.State == Running
+}
+
+func (s *series) observe(ms float64, failed bool) {
+	s.count++
+	if failed {
+		s.errors++
+	}
+	if len(s.samples) < maxSamples {
+		s.samples = append(s.samples, ms)
+	} else if i := rand.Int63n(s.count); i < maxSamples {
+		s.samples[i] = ms
+	}
+}
+
+func (s *series) summary() Summary {
+	sum := Summary{Count: s.count, Errors: s.errors}
+	if s.count > 0 {
+		sum.ErrorRate = float64(s.errors) / float64(s.count)
+	}
+	if len(s.samples) > 0 {
+		sorted := append([]float64(nil), s.samples...)
+		sort.Float64s(sorted)
+		sum.P95Ms = sorted[int(0.95*float64(len(sorted)-1))]
+	}
+	return sum
+}
+
+func copyRollout(r *Rollout) Rollout {
+	out := *r
+	out.Models = append([]string(nil), r.Models...)
+	out.Steps = append([]Step{}, r.Steps...)
+	return out
+}
@@ -0,0 +1,207 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud-ai-api/atrest"
+)
+
+// Rule disables requests whose path matches Path, either exactly or, if
+// Path ends in "*", by prefix. An empty Method matches every method.
+type Rule struct {
+	Method     string    `json:"method,omitempty"`
+	Path       string    `json:"path"`
+	Reason     string    `json:"reason,omitempty"`
+	RetryAfter int       `json:"retry_after,omitempty"`
+	DisabledAt time.Time `json:"disabled_at"`
+}
+
+// Matches reports whether the rule applies to a request
+func (r Rule) Matches(method, path string) bool {
+	if r.Method != "" && !strings.EqualFold(r.Method, method) {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(r.Path, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+	return r.Path == path
+}
+
+// Maintenance is the global maintenance mode state
+type Maintenance struct {
+	Enabled    bool       `json:"enabled"`
+	Message    string     `json:"message,omitempty"`
+	RetryAfter int        `json:"retry_after,omitempty"`
+	Since      *time.Time `json:"since,omitempty"`
+}
+
+// Switch holds the routes disabled at runtime and the maintenance mode.
+// Changes are written to a JSON file so they survive restarts; the zero
+// value keeps them in memory.
+type Switch struct {
+	file string
+	keys *atrest.Keyring
+
+	mu          sync.RWMutex
+	rules       []Rule
+	maintenance Maintenance
+}
+
+// state is the on-disk format
+type state struct {
+	Disabled    []Rule      `json:"disabled"`
+	Maintenance Maintenance `json:"maintenance"`
+}
+
+// Open loads the disabled routes from file, which need not exist yet, and
+// encrypts them with keys when they are saved. An empty file name keeps
+// state in memory only.
+func Open(file string, keys *atrest.Keyring) (*Switch, error) {
+	s := &Switch{file: file, keys: keys}
+	if file == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err == nil {
+		data, err = keys.Open(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("invalid route state file: %w", err)
+	}
+	s.rules = st.Disabled
+	s.maintenance = st.Maintenance
+	return s, nil
+}
+
+// Disable adds a rule, replacing any existing rule for the same method and path
+func (s *Switch) Disable(rule Rule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rule.DisabledAt.IsZero() {
+		rule.DisabledAt = time.Now()
+	}
+	rules := []Rule{rule}
+	for _, r := range s.rules {
+		if !sameRoute(r, rule.Method, rule.Path) {
+			rules = append(rules, r)
+		}
+	}
+	return s.commit(rules, s.maintenance)
+}
+
+// Enable removes the rule for a method and path, reporting whether one existed
+func (s *Switch) Enable(method, path string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rules []Rule
+	for _, r := range s.rules {
+		if !sameRoute(r, method, path) {
+			rules = append(rules, r)
+		}
+	}
+	if len(rules) == len(s.rules) {
+		return false, nil
+	}
+	return true, s.commit(rules, s.maintenance)
+}
+
+// SetMaintenance turns maintenance mode on or off
+func (s *Switch) SetMaintenance(m Maintenance) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !m.Enabled {
+		m = Maintenance{}
+	} else if m.Since == nil {
+		since := time.Now()
+		if s.maintenance.Enabled {
+			since = *s.maintenance.Since
+		}
+		m.Since = &since
+	}
+	return s.commit(s.rules, m)
+}
+
+// Maintenance returns the maintenance mode state
+func (s *Switch) Maintenance() Maintenance {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.maintenance
+}
+
+// List returns the disabled routes, most recently disabled first
+func (s *Switch) List() []Rule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Rule{}, s.rules...)
+}
+
+// Match returns the rule disabling a request, if any
+func (s *Switch) Match(method, path string) (Rule, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, r := range s.rules {
+		if r.Matches(method, path) {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// commit persists state and makes it current. The caller must hold s.mu.
+func (s *Switch) commit(rules []Rule, maintenance Maintenance) error {
+	if rules == nil {
+		rules = []Rule{}
+	}
+	if s.file != "" {
+		data, err := json.MarshalIndent(state{Disabled: rules, Maintenance: maintenance}, "", "  ")
+		if err == nil {
+			data, err = s.keys.Seal(data)
+		}
+		if err != nil {
+			return err
+		}
+		// Write atomically so a crash never leaves a truncated file
+		tmp, err := os.CreateTemp(filepath.Dir(s.file), ".routes-*.json")
+		if err != nil {
+			return fmt.Errorf("failed to save route state: %w", err)
+		}
+		_, werr := tmp.Write(data)
+		cerr := tmp.Close()
+		if werr == nil {
+			werr = cerr
+		}
+		if werr == nil {
+			werr = os.Rename(tmp.Name(), s.file)
+		}
+		if werr != nil {
+			os.Remove(tmp.Name())
+			return fmt.Errorf("failed to save route state: %w", werr)
+		}
+	}
+	s.rules = rules
+	s.maintenance = maintenance
+	return nil
+}
+
+func sameRoute(r Rule, method, path string) bool {
+	return strings.EqualFold(r.Method, method) && r.Path == path
+}
@@ -0,0 +1,136 @@
+package sanity
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"cloud-ai-api/plausibility"
+)
+
+//go:embed default.json
+var defaultBounds []byte
+
+// CodeOutOfRange is the warning code for a prediction outside its bounds
+const CodeOutOfRange = "prediction_out_of_range"
+
+// Bound is the range a numeric response field must fall within to be
+// believable, in the field's Unit (such as GBP or MW). Either end may be
+// omitted.
+type Bound struct {
+	Field string   `json:"field"`
+	Min   *float64 `json:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty"`
+	Unit  string   `json:"unit,omitempty"`
+}
+
+// Violation is a prediction field outside its bound
+type Violation struct {
+	Bound
+	Value float64
+}
+
+// Warning describes the violation for the response's warnings
+func (v Violation) Warning() plausibility.Warning {
+	var message string
+	if v.Min != nil && v.Value < *v.Min {
+		message = fmt.Sprintf("Predicted %s of %s is below the expected minimum of %s",
+			v.Field, v.format(v.Value), v.format(*v.Min))
+	} else {
+		message = fmt.Sprintf("Predicted %s of %s is above the expected maximum of %s",
+			v.Field, v.format(v.Value), v.format(*v.Max))
+	}
+	return plausibility.Warning{Code: CodeOutOfRange, Fields: []string{v.Field}, Message: message}
+}
+
+// format writes a value with the bound's unit
+func (b Bound) format(value float64) string {
+	s := strconv.FormatFloat(value, 'f', -1, 64)
+	if b.Unit != "" {
+		s += " " + b.Unit
+	}
+	return s
+}
+
+// Bounds holds the sanity bounds of each model's predictions by model name
+type Bounds struct {
+	Models map[string][]Bound `json:"models"`
+}
+
+// Default returns the bundled bounds for the built-in models
+func Default() *Bounds {
+	bounds, err := Parse(defaultBounds)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in sanity bounds: %s", err))
+	}
+	return bounds
+}
+
+// Load reads sanity bounds from a JSON file
+func Load(path string) (*Bounds, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sanity bounds: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse builds sanity bounds from their JSON representation
+func Parse(data []byte) (*Bounds, error) {
+	var b Bounds
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("failed to parse sanity bounds: %w", err)
+	}
+	for name, bounds := range b.Models {
+		seen := make(map[string]bool)
+		for _, bound := range bounds {
+			if bound.Field == "" {
+				return nil, fmt.Errorf("model %q: bound declared without a field", name)
+			}
+			if seen[bound.Field] {
+				return nil, fmt.Errorf("model %q: field %q bounded more than once", name, bound.Field)
+			}
+			seen[bound.Field] = true
+			if bound.Min == nil && bound.Max == nil {
+				return nil, fmt.Errorf("model %q: field %q: min or max is required", name, bound.Field)
+			}
+			if bound.Min != nil && bound.Max != nil && *bound.Min > *bound.Max {
+				return nil, fmt.Errorf("model %q: field %q: min is greater than max", name, bound.Field)
+			}
+		}
+	}
+	return &b, nil
+}
+
+// Check returns the fields of a prediction response outside the model's
+// bounds. Models without bounds, and fields missing from the response or
+// not numeric, are not checked.
+func (b *Bounds) Check(model string, resp map[string]interface{}) []Violation {
+	var violations []Violation
+	for _, bound := range b.Models[model] {
+		value, ok := number(resp[bound.Field])
+		if !ok {
+			continue
+		}
+		if (bound.Min != nil && value < *bound.Min) || (bound.Max != nil && value > *bound.Max) {
+			violations = append(violations, Violation{Bound: bound, Value: value})
+		}
+	}
+	return violations
+}
+
+// number reads a numeric response value, decoded as float64 or json.Number
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,425 @@
+package scheduler
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"cloud-ai-api/atrest"
+	"github.com/robfig/cron/v3"
+)
+
+// PredictFunc predicts a single input with the named model on behalf of a
+// schedule's owner
+type PredictFunc func(owner Owner, model string, input map[string]interface{}) (map[string]interface{}, error)
+
+// Owner identifies who created a schedule
+type Owner struct {
+	Tenant string `json:"tenant,omitempty"`
+	APIKey string `json:"api_key,omitempty"`
+}
+
+// Schedule is a recurring prediction
+type Schedule struct {
+	ID         string                 `json:"id"`
+	Owner      Owner                  `json:"-"`
+	Name       string                 `json:"name"`
+	Cron       string                 `json:"cron"`
+	Model      string                 `json:"model"`
+	Template   map[string]interface{} `json:"template"`
+	WebhookURL string                 `json:"webhook_url,omitempty"`
+	Enabled    bool                   `json:"enabled"`
+	CreatedAt  time.Time              `json:"created_at"`
+	NextRun    *time.Time             `json:"next_run,omitempty"`
+	LastRun    *time.Time             `json:"last_run,omitempty"`
+	LastStatus string                 `json:"last_status,omitempty"`
+	LastError  string                 `json:"last_error,omitempty"`
+}
+
+// WebhookPayload is posted to a schedule's webhook after each run
+type WebhookPayload struct {
+	ScheduleID   string                 `json:"schedule_id"`
+	ScheduleName string                 `json:"schedule_name"`
+	Model        string                 `json:"model"`
+	RunAt        time.Time              `json:"run_at"`
+	Input        map[string]interface{} `json:"input"`
+	Result       map[string]interface{} `json:"result,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+}
+
+// Scheduler runs schedules on their cron expressions. Schedules are written
+// to a JSON file so they survive restarts; an empty file name keeps them in
+// memory.
+type Scheduler struct {
+	predict PredictFunc
+	cron    *cron.Cron
+	client  *http.Client
+	file    string
+	keys    *atrest.Keyring
+
+	mu        sync.RWMutex
+	schedules map[string]*Schedule
+	entries   map[string]cron.EntryID
+}
+
+// savedSchedule is a schedule as written to the schedules file, which
+// unlike API responses records its owner
+type savedSchedule struct {
+	Schedule
+	Owner Owner `json:"owner"`
+}
+
+// parser accepts standard five-field expressions and descriptors such as @daily
+var parser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// New creates a scheduler that runs predictions with predict
+func New(predict PredictFunc) *Scheduler {
+	return &Scheduler{
+		predict:   predict,
+		cron:      cron.New(cron.WithParser(parser)),
+		client:    webhookClient(10 * time.Second),
+		schedules: make(map[string]*Schedule),
+		entries:   make(map[string]cron.EntryID),
+	}
+}
+
+// Open loads the schedules saved in file, which need not exist yet, and
+// saves every later change there, encrypted with keys
+func (s *Scheduler) Open(file string, keys *atrest.Keyring) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.file, s.keys = file, keys
+	if file == "" {
+		return nil
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err == nil {
+		data, err = keys.Open(data)
+	}
+	if err != nil {
+		return err
+	}
+	var saved []savedSchedule
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("invalid schedules file: %w", err)
+	}
+	for _, entry := range saved {
+		sched := entry.Schedule
+		sched.Owner = entry.Owner
+		if err := s.install(&sched); err != nil {
+			return fmt.Errorf("invalid schedule %s: %w", sched.ID, err)
+		}
+		s.schedules[sched.ID] = &sched
+	}
+	return nil
+}
+
+// Start begins running schedules in the background
+func (s *Scheduler) Start() {
+	s.cron.Start()
+}
+
+// Stop stops the scheduler and waits for running predictions to finish
+func (s *Scheduler) Stop() {
+	<-s.cron.Stop().Done()
+}
+
+// ValidateCron checks that a cron expression can be scheduled
+func ValidateCron(expr string) error {
+	_, err := parser.Parse(expr)
+	return err
+}
+
+// Create registers a new schedule for sched.Owner, who may have at most
+// limit schedules
+func (s *Scheduler) Create(sched Schedule, limit int) (Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	owned := 0
+	for _, existing := range s.schedules {
+		if existing.Owner == sched.Owner {
+			owned++
+		}
+	}
+	if owned >= limit {
+		return Schedule{}, ErrLimit
+	}
+
+	id, err := newID()
+	if err != nil {
+		return Schedule{}, err
+	}
+	sched.ID = id
+	sched.CreatedAt = time.Now()
+	sched.NextRun, sched.LastRun, sched.LastStatus, sched.LastError = nil, nil, "", ""
+	if err := s.install(&sched); err != nil {
+		return Schedule{}, err
+	}
+	s.schedules[sched.ID] = &sched
+	if err := s.save(); err != nil {
+		s.uninstall(sched.ID)
+		delete(s.schedules, sched.ID)
+		return Schedule{}, err
+	}
+	return s.snapshot(&sched), nil
+}
+
+// Update replaces the definition of an existing schedule of owner, keeping
+// its run state
+func (s *Scheduler) Update(owner Owner, id string, sched Schedule) (Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.schedules[id]
+	if !ok || existing.Owner != owner {
+		return Schedule{}, ErrNotFound
+	}
+
+	updated := *existing
+	updated.Name = sched.Name
+	updated.Cron = sched.Cron
+	updated.Model = sched.Model
+	updated.Template = sched.Template
+	updated.WebhookURL = sched.WebhookURL
+	updated.Enabled = sched.Enabled
+
+	s.uninstall(id)
+	if err := s.install(&updated); err != nil {
+		// Restore the previous definition
+		s.install(existing)
+		return Schedule{}, err
+	}
+	s.schedules[id] = &updated
+	if err := s.save(); err != nil {
+		s.uninstall(id)
+		s.install(existing)
+		s.schedules[id] = existing
+		return Schedule{}, err
+	}
+	return s.snapshot(&updated), nil
+}
+
+// Delete removes a schedule of owner
+func (s *Scheduler) Delete(owner Owner, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.schedules[id]
+	if !ok || existing.Owner != owner {
+		return ErrNotFound
+	}
+	s.uninstall(id)
+	delete(s.schedules, id)
+	if err := s.save(); err != nil {
+		s.install(existing)
+		s.schedules[id] = existing
+		return err
+	}
+	return nil
+}
+
+// Get returns a schedule of owner
+func (s *Scheduler) Get(owner Owner, id string) (Schedule, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sched, ok := s.schedules[id]
+	if !ok || sched.Owner != owner {
+		return Schedule{}, false
+	}
+	return s.snapshot(sched), true
+}
+
+// List returns the schedules of owner
+func (s *Scheduler) List(owner Owner) []Schedule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]Schedule, 0)
+	for _, sched := range s.schedules {
+		if sched.Owner == owner {
+			list = append(list, s.snapshot(sched))
+		}
+	}
+	return list
+}
+
+// ErrNotFound is returned for unknown schedule IDs and for schedules of
+// another owner
+var ErrNotFound = fmt.Errorf("schedule not found")
+
+// ErrLimit is returned when an owner already has as many schedules as
+// allowed
+var ErrLimit = fmt.Errorf("schedule limit reached")
+
+// newID returns a random schedule ID, so IDs reveal nothing about other
+// owners' schedules
+func newID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate schedule ID: %w", err)
+	}
+	return "sched-" + hex.EncodeToString(b), nil
+}
+
+// save writes the schedules to the schedules file, if there is one. The
+// caller must hold s.mu.
+func (s *Scheduler) save() error {
+	if s.file == "" {
+		return nil
+	}
+	saved := make([]savedSchedule, 0, len(s.schedules))
+	for _, sched := range s.schedules {
+		saved = append(saved, savedSchedule{Schedule: *sched, Owner: sched.Owner})
+	}
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err == nil {
+		data, err = s.keys.Seal(data)
+	}
+	if err != nil {
+		return err
+	}
+	// Write atomically so a crash never leaves a truncated file
+	tmp, err := os.CreateTemp(filepath.Dir(s.file), ".schedules-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to save schedules: %w", err)
+	}
+	_, werr := tmp.Write(data)
+	cerr := tmp.Close()
+	if werr == nil {
+		werr = cerr
+	}
+	if werr == nil {
+		werr = os.Rename(tmp.Name(), s.file)
+	}
+	if werr != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save schedules: %w", werr)
+	}
+	return nil
+}
+
+// install adds an enabled schedule to the cron runner. The caller must hold s.mu.
+func (s *Scheduler) install(sched *Schedule) error {
+	if _, err := parser.Parse(sched.Cron); err != nil {
+		return fmt.Errorf("invalid cron expression: %w", err)
+	}
+	if !sched.Enabled {
+		return nil
+	}
+
+	id := sched.ID
+	entryID, err := s.cron.AddFunc(sched.Cron, func() { s.run(id) })
+	if err != nil {
+		return fmt.Errorf("invalid cron expression: %w", err)
+	}
+	s.entries[id] = entryID
+	return nil
+}
+
+// uninstall removes a schedule from the cron runner. The caller must hold s.mu.
+func (s *Scheduler) uninstall(id string) {
+	if entryID, ok := s.entries[id]; ok {
+		s.cron.Remove(entryID)
+		delete(s.entries, id)
+	}
+}
+
+// snapshot copies a schedule and fills in its next run time. The caller must hold s.mu.
+func (s *Scheduler) snapshot(sched *Schedule) Schedule {
+	out := *sched
+	if entryID, ok := s.entries[sched.ID]; ok {
+		if next := s.cron.Entry(entryID).Next; !next.IsZero() {
+			out.NextRun = &next
+		} else if schedule, err := parser.Parse(sched.Cron); err == nil {
+			// The runner has not computed the next run yet
+			next := schedule.Next(time.Now())
+			out.NextRun = &next
+		}
+	}
+	return out
+}
+
+// run executes a schedule once and delivers the result to its webhook
+func (s *Scheduler) run(id string) {
+	s.mu.RLock()
+	sched, ok := s.schedules[id]
+	if !ok {
+		s.mu.RUnlock()
+		return
+	}
+	current := *sched
+	s.mu.RUnlock()
+
+	runAt := time.Now()
+	input := Render(current.Template, runAt)
+	result, err := s.predict(current.Owner, current.Model, input)
+
+	status, errMsg := "success", ""
+	if err != nil {
+		status, errMsg = "failed", err.Error()
+		log.Printf("Schedule %s (%s) failed: %v", current.ID, current.Name, err)
+	}
+
+	if current.WebhookURL != "" {
+		payload := WebhookPayload{
+			ScheduleID:   current.ID,
+			ScheduleName: current.Name,
+			Model:        current.Model,
+			RunAt:        runAt,
+			Input:        input,
+			Result:       result,
+			Error:        errMsg,
+		}
+		if err := s.deliver(current.WebhookURL, payload); err != nil {
+			log.Printf("Schedule %s (%s) webhook failed: %v", current.ID, current.Name, err)
+			if status == "success" {
+				status, errMsg = "webhook_failed", err.Error()
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sched, ok := s.schedules[id]; ok {
+		sched.LastRun = &runAt
+		sched.LastStatus = status
+		sched.LastError = errMsg
+		if err := s.save(); err != nil {
+			log.Printf("Schedule %s (%s): %v", current.ID, current.Name, err)
+		}
+	}
+}
+
+// deliver posts a run result to a webhook
+func (s *Scheduler) deliver(url string, payload WebhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	resp, err := s.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to call webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// placeholder matches template values such as "{{tomorrow.day}}"
+var placeholder = regexp.MustCompile(`^\{\{\s*(now|today|tomorrow|yesterday)\.(year|month|day|hour|weekday)\s*\}\}$`)
+
+// Render resolves date placeholders in a prediction template relative to
+// the given time. String values of the form "{{<day>.<part>}}", where day
+// is now, today, tomorrow or yesterday and part is year, month, day, hour
+// or weekday (0 = Sunday), are replaced by integers. Nested objects are
+// rendered recursively; other values are copied unchanged.
+func Render(template map[string]interface{}, at time.Time) map[string]interface{} {
+	out := make(map[string]interface{}, len(template))
+	for k, v := range template {
+		out[k] = renderValue(v, at)
+	}
+	return out
+}
+
+// CheckTemplate reports placeholders that look like template expressions
+// but cannot be resolved
+func CheckTemplate(template map[string]interface{}) error {
+	for k, v := range template {
+		switch value := v.(type) {
+		case string:
+			if len(value) > 1 && value[:2] == "{{" && !placeholder.MatchString(value) {
+				return fmt.Errorf("field %q: unknown placeholder %s", k, value)
+			}
+		case map[string]interface{}:
+			if err := CheckTemplate(value); err != nil {
+				return fmt.Errorf("field %q: %w", k, err)
+			}
+		}
+	}
+	return nil
+}
+
+func renderValue(v interface{}, at time.Time) interface{} {
+	switch value := v.(type) {
+	case string:
+		m := placeholder.FindStringSubmatch(value)
+		if m == nil {
+			return value
+		}
+		day := at
+		switch m[1] {
+		case "tomorrow":
+			day = at.AddDate(0, 0, 1)
+		case "yesterday":
+			day = at.AddDate(0, 0, -1)
+		}
+		switch m[2] {
+		case "year":
+			return day.Year()
+		case "month":
+			return int(day.Month())
+		case "day":
+			return day.Day()
+		case "hour":
+			return day.Hour()
+		case "weekday":
+			return int(day.Weekday())
+		}
+		return value
+	case map[string]interface{}:
+		return Render(value, at)
+	}
+	return v
+}
@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), internal
+// to a provider's network like the private ranges
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// checkAddress rejects addresses a webhook must not reach: loopback,
+// private, shared, link-local (which holds cloud metadata services),
+// multicast and unspecified addresses
+func checkAddress(ip net.IP) error {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() ||
+		sharedAddressSpace.Contains(ip) || (ip.To4() != nil && ip.To4()[0] == 0) {
+		return fmt.Errorf("webhook address %s is not publicly routable", ip)
+	}
+	return nil
+}
+
+// CheckWebhook verifies that rawURL is an absolute http or https URL whose
+// host resolves only to public addresses. Deliveries check the address
+// again when they connect, so a host that later resolves elsewhere is
+// still refused.
+func CheckWebhook(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("must be an absolute http or https URL")
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("cannot resolve %s", u.Hostname())
+	}
+	for _, addr := range addrs {
+		if err := checkAddress(addr.IP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// webhookClient returns an HTTP client that connects only to public
+// addresses. The check runs on the address actually dialed, after name
+// resolution and on every redirect, and no proxy is used so the gateway
+// itself makes the connection.
+func webhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil {
+				return fmt.Errorf("webhook address %s is not an IP address", host)
+			}
+			return checkAddress(ip)
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
@@ -0,0 +1,152 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AWSConfig holds the region and credentials for AWS Secrets Manager.
+// Endpoint overrides the regional endpoint (e.g. for a VPC endpoint).
+type AWSConfig struct {
+	Region       string
+	Endpoint     string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+// SecretsManager reads secrets from AWS Secrets Manager, signing requests
+// with AWS Signature Version 4
+type SecretsManager struct {
+	cfg  AWSConfig
+	http *http.Client
+}
+
+// NewSecretsManager creates an AWS Secrets Manager client
+func NewSecretsManager(cfg AWSConfig) *SecretsManager {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", cfg.Region)
+	}
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+	return &SecretsManager{cfg: cfg, http: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Get reads the current version of a secret by name or ARN. The whole
+// secret string is the field ""; a secret holding a JSON object also has
+// one field per key.
+func (s *SecretsManager) Get(ctx context.Context, id string) (map[string]string, error) {
+	if s.cfg.AccessKey == "" || s.cfg.SecretKey == "" {
+		return nil, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required to read from AWS Secrets Manager")
+	}
+	body, _ := json.Marshal(map[string]string{"SecretId": id})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.Endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	s.sign(req, body, time.Now().UTC())
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("secrets manager request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var awsErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &awsErr)
+		return nil, fmt.Errorf("secrets manager returned status %d for %s: %s %s", resp.StatusCode, id, awsErr.Type, awsErr.Message)
+	}
+
+	var secret struct {
+		SecretString *string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(data, &secret); err != nil {
+		return nil, fmt.Errorf("invalid secrets manager response for %s: %w", id, err)
+	}
+	if secret.SecretString == nil {
+		return nil, fmt.Errorf("secret %s is binary; only string secrets are supported", id)
+	}
+	fields := map[string]string{}
+	var object map[string]interface{}
+	if json.Unmarshal([]byte(*secret.SecretString), &object) == nil {
+		fields = stringFields(object)
+	}
+	fields[""] = *secret.SecretString
+	return fields, nil
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to req
+func (s *SecretsManager) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if s.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.cfg.SessionToken)
+	}
+
+	signed := []string{"content-type", "host", "x-amz-date"}
+	if s.cfg.SessionToken != "" {
+		signed = append(signed, "x-amz-security-token")
+	}
+	signed = append(signed, "x-amz-target")
+	var headers strings.Builder
+	for _, name := range signed {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		fmt.Fprintf(&headers, "%s:%s\n", name, strings.TrimSpace(value))
+	}
+	signedHeaders := strings.Join(signed, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		"/",
+		"",
+		headers.String(),
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/secretsmanager/aws4_request", day, s.cfg.Region)
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonical))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), day)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "secretsmanager")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,205 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Prefixes of environment values that refer to a secret instead of holding
+// it, e.g. ADMIN_TOKEN=vault:secret/data/gateway#admin_token or
+// SMTP_PASSWORD=aws-sm:prod/gateway/smtp#password
+const (
+	VaultPrefix = "vault:"
+	AWSPrefix   = "aws-sm:"
+)
+
+// Store reads a secret as a set of named fields
+type Store interface {
+	Get(ctx context.Context, path string) (map[string]string, error)
+}
+
+// Ref is an environment variable whose value refers to a secret
+type Ref struct {
+	Var   string
+	Value string
+	store string
+	path  string
+	field string
+}
+
+// parseRef parses a secret reference of the form <prefix><path>#<field>.
+// The field is optional for AWS Secrets Manager, where it selects a key of
+// a JSON secret.
+func parseRef(name, value string) (Ref, bool, error) {
+	var store, rest string
+	switch {
+	case strings.HasPrefix(value, VaultPrefix):
+		store, rest = "vault", strings.TrimPrefix(value, VaultPrefix)
+	case strings.HasPrefix(value, AWSPrefix):
+		store, rest = "aws-sm", strings.TrimPrefix(value, AWSPrefix)
+	default:
+		return Ref{}, false, nil
+	}
+	path, field, _ := strings.Cut(rest, "#")
+	if path == "" {
+		return Ref{}, true, fmt.Errorf("secret reference %q has no path", value)
+	}
+	if store == "vault" && field == "" {
+		return Ref{}, true, fmt.Errorf("vault reference %q needs a #field", value)
+	}
+	return Ref{Var: name, Value: value, store: store, path: strings.TrimPrefix(path, "/"), field: field}, true, nil
+}
+
+// Resolver replaces secret references in the environment with the secrets'
+// values, and can watch the secrets for changes
+type Resolver struct {
+	Vault *Vault
+	AWS   *SecretsManager
+
+	refs   []Ref
+	values map[string]string
+}
+
+// FromEnv creates a resolver for the stores configured in the environment:
+// Vault when VAULT_ADDR is set and AWS Secrets Manager when a region is
+// set. Credentials for the stores cannot themselves be references.
+func FromEnv() *Resolver {
+	r := &Resolver{}
+	if addr := os.Getenv("VAULT_ADDR"); addr != "" {
+		r.Vault = NewVault(VaultConfig{
+			Addr:      addr,
+			Token:     os.Getenv("VAULT_TOKEN"),
+			Namespace: os.Getenv("VAULT_NAMESPACE"),
+			Role:      os.Getenv("VAULT_K8S_ROLE"),
+			AuthPath:  os.Getenv("VAULT_K8S_AUTH_PATH"),
+		})
+	}
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region != "" {
+		r.AWS = NewSecretsManager(AWSConfig{
+			Region:       region,
+			Endpoint:     os.Getenv("AWS_SECRETS_ENDPOINT"),
+			AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		})
+	}
+	return r
+}
+
+// Resolve finds the environment variables holding secret references,
+// fetches the secrets and sets the variables to their values. The error
+// names the variable that could not be resolved.
+func (r *Resolver) Resolve(ctx context.Context) (string, error) {
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		ref, ok, err := parseRef(name, value)
+		if err != nil {
+			return name, err
+		}
+		if ok {
+			r.refs = append(r.refs, ref)
+		}
+	}
+	sort.Slice(r.refs, func(i, j int) bool { return r.refs[i].Var < r.refs[j].Var })
+
+	values, name, err := r.fetch(ctx)
+	if err != nil {
+		return name, err
+	}
+	for _, ref := range r.refs {
+		os.Setenv(ref.Var, values[ref.Var])
+	}
+	r.values = values
+	return "", nil
+}
+
+// Restore sets the variables back to their references, so the plain values
+// do not linger in the environment and a new process started after an
+// upgrade fetches the secrets afresh
+func (r *Resolver) Restore() {
+	for _, ref := range r.refs {
+		os.Setenv(ref.Var, ref.Value)
+	}
+}
+
+// Vars lists the variables resolved from secret stores
+func (r *Resolver) Vars() []string {
+	vars := make([]string, 0, len(r.refs))
+	for _, ref := range r.refs {
+		vars = append(vars, ref.Var)
+	}
+	return vars
+}
+
+// fetch reads every referenced secret, each path once
+func (r *Resolver) fetch(ctx context.Context) (map[string]string, string, error) {
+	secrets := make(map[string]map[string]string)
+	values := make(map[string]string, len(r.refs))
+	for _, ref := range r.refs {
+		key := ref.store + ":" + ref.path
+		fields, ok := secrets[key]
+		if !ok {
+			var store Store
+			switch {
+			case ref.store == "vault" && r.Vault != nil:
+				store = r.Vault
+			case ref.store == "aws-sm" && r.AWS != nil:
+				store = r.AWS
+			case ref.store == "vault":
+				return nil, ref.Var, fmt.Errorf("refers to Vault but VAULT_ADDR is not set")
+			default:
+				return nil, ref.Var, fmt.Errorf("refers to AWS Secrets Manager but AWS_REGION is not set")
+			}
+			var err error
+			if fields, err = store.Get(ctx, ref.path); err != nil {
+				return nil, ref.Var, err
+			}
+			secrets[key] = fields
+		}
+		value, ok := fields[ref.field]
+		if !ok {
+			return nil, ref.Var, fmt.Errorf("secret %s has no field %q", ref.path, ref.field)
+		}
+		values[ref.Var] = value
+	}
+	return values, "", nil
+}
+
+// Watch re-fetches the secrets every interval until ctx is done, calling
+// changed with the variables whose secrets changed. Failed fetches are
+// logged and retried at the next interval.
+func (r *Resolver) Watch(ctx context.Context, interval time.Duration, changed func(vars []string)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		values, name, err := r.fetch(ctx)
+		if err != nil {
+			log.Printf("WARN secrets_refresh_failed var=%s error=%q", name, err)
+			continue
+		}
+		var vars []string
+		for _, ref := range r.refs {
+			if values[ref.Var] != r.values[ref.Var] {
+				vars = append(vars, ref.Var)
+			}
+		}
+		r.values = values
+		if len(vars) > 0 {
+			changed(vars)
+		}
+	}
+}
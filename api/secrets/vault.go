@@ -0,0 +1,163 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serviceAccountToken is where Kubernetes mounts the pod's service account
+// token, used to log in to Vault's Kubernetes auth method
+const serviceAccountToken = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// VaultConfig sets how to reach and log in to Vault. With a Role the pod's
+// service account token is exchanged for a Vault token through the
+// Kubernetes auth method mounted at AuthPath (default "kubernetes");
+// otherwise Token is used as is.
+type VaultConfig struct {
+	Addr      string
+	Token     string
+	Namespace string
+	Role      string
+	AuthPath  string
+}
+
+// Vault reads secrets from HashiCorp Vault's KV secrets engine, version 1
+// or 2
+type Vault struct {
+	cfg  VaultConfig
+	http *http.Client
+
+	mu    sync.Mutex
+	token string
+}
+
+// NewVault creates a Vault client
+func NewVault(cfg VaultConfig) *Vault {
+	cfg.Addr = strings.TrimSuffix(cfg.Addr, "/")
+	if cfg.AuthPath == "" {
+		cfg.AuthPath = "kubernetes"
+	}
+	return &Vault{cfg: cfg, http: &http.Client{Timeout: 10 * time.Second}, token: cfg.Token}
+}
+
+// Get reads the secret at path, e.g. secret/data/gateway for KV version 2
+// mounted at secret/
+func (v *Vault) Get(ctx context.Context, path string) (map[string]string, error) {
+	token, err := v.currentToken(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	body, status, err := v.do(ctx, http.MethodGet, "/v1/"+path, token, nil)
+	if status == http.StatusForbidden && v.cfg.Role != "" {
+		// The token may have expired; log in again once
+		if token, err = v.currentToken(ctx, true); err != nil {
+			return nil, err
+		}
+		body, status, err = v.do(ctx, http.MethodGet, "/v1/"+path, token, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("vault returned status %d for %s", status, path)
+	}
+
+	var resp struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("invalid vault response for %s: %w", path, err)
+	}
+	data := resp.Data
+	// KV version 2 nests the secret under data with its metadata alongside
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = inner
+		}
+	}
+	return stringFields(data), nil
+}
+
+// currentToken returns the Vault token, logging in first if there is none
+// or relogin is set
+func (v *Vault) currentToken(ctx context.Context, relogin bool) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.token != "" && !relogin {
+		return v.token, nil
+	}
+	if v.cfg.Role == "" {
+		if v.token == "" {
+			return "", errors.New("VAULT_TOKEN or VAULT_K8S_ROLE is required to read from Vault")
+		}
+		return v.token, nil
+	}
+
+	jwt, err := os.ReadFile(serviceAccountToken)
+	if err != nil {
+		return "", fmt.Errorf("failed to read service account token: %w", err)
+	}
+	login, _ := json.Marshal(map[string]string{"role": v.cfg.Role, "jwt": strings.TrimSpace(string(jwt))})
+	body, status, err := v.do(ctx, http.MethodPost, "/v1/auth/"+v.cfg.AuthPath+"/login", "", login)
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusOK {
+		return "", fmt.Errorf("vault login returned status %d", status)
+	}
+	var resp struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Auth.ClientToken == "" {
+		return "", errors.New("vault login returned no token")
+	}
+	v.token = resp.Auth.ClientToken
+	return v.token, nil
+}
+
+// do sends a request to Vault and returns the response body and status
+func (v *Vault) do(ctx context.Context, method, path, token string, body []byte) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, v.cfg.Addr+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if v.cfg.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.cfg.Namespace)
+	}
+	resp, err := v.http.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return data, resp.StatusCode, err
+}
+
+// stringFields converts secret fields to strings. Fields that are not
+// strings are kept as JSON.
+func stringFields(data map[string]interface{}) map[string]string {
+	out := make(map[string]string, len(data))
+	for k, v := range data {
+		if s, ok := v.(string); ok {
+			out[k] = s
+			continue
+		}
+		encoded, _ := json.Marshal(v)
+		out[k] = string(encoded)
+	}
+	return out
+}
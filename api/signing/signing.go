@@ -0,0 +1,159 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// Signer signs response bodies with the gateway's private key as detached
+// JWS (RFC 7515 appendix F): the compact serialization with the payload
+// left out, which verifiers fill in with the base64url-encoded body.
+// RSA keys sign with RS256, P-256 keys with ES256 and Ed25519 keys with
+// EdDSA.
+type Signer struct {
+	key    crypto.Signer
+	alg    string
+	kid    string
+	header string
+}
+
+// Load reads a PEM private key (PKCS#8, PKCS#1 or SEC 1). kid names the
+// key in signatures and the JWKS; if empty it is the key's RFC 7638
+// thumbprint.
+func Load(file, kid string) (*Signer, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM private key found")
+	}
+	var key interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	return New(key, kid)
+}
+
+// New creates a signer for an RSA (2048 bits or more), P-256 or Ed25519
+// private key
+func New(key interface{}, kid string) (*Signer, error) {
+	s := &Signer{kid: kid}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		s.key, s.alg = k, "RS256"
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, errors.New("EC keys must use the P-256 curve")
+		}
+		s.key, s.alg = k, "ES256"
+	case ed25519.PrivateKey:
+		s.key, s.alg = k, "EdDSA"
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	if s.kid == "" {
+		s.kid = thumbprint(s.publicJWK())
+	}
+	header, _ := json.Marshal(map[string]string{"alg": s.alg, "kid": s.kid})
+	s.header = base64.RawURLEncoding.EncodeToString(header)
+	return s, nil
+}
+
+// Algorithm returns the JWS algorithm the signer uses
+func (s *Signer) Algorithm() string {
+	return s.alg
+}
+
+// KeyID returns the key ID in signatures
+func (s *Signer) KeyID() string {
+	return s.kid
+}
+
+// Sign returns the detached JWS of body, "<header>..<signature>"
+func (s *Signer) Sign(body []byte) (string, error) {
+	input := s.header + "." + base64.RawURLEncoding.EncodeToString(body)
+	var sig []byte
+	var err error
+	switch k := s.key.(type) {
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(input))
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256([]byte(input))
+		var r, ss *big.Int
+		if r, ss, err = ecdsa.Sign(rand.Reader, k, digest[:]); err == nil {
+			// JWS uses the fixed-width r || s form, not ASN.1
+			sig = make([]byte, 64)
+			r.FillBytes(sig[:32])
+			ss.FillBytes(sig[32:])
+		}
+	default:
+		digest := sha256.Sum256([]byte(input))
+		sig, err = s.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		return "", err
+	}
+	return s.header + ".." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// JWKS returns the public key as a JSON Web Key Set, for consumers to
+// verify signatures with
+func (s *Signer) JWKS() map[string]interface{} {
+	jwk := s.publicJWK()
+	jwk["kid"] = s.kid
+	jwk["alg"] = s.alg
+	jwk["use"] = "sig"
+	return map[string]interface{}{"keys": []map[string]string{jwk}}
+}
+
+// publicJWK returns the required members of the public key's JWK
+func (s *Signer) publicJWK() map[string]string {
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch pub := s.key.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "n": b64(pub.N.Bytes()), "e": b64(big.NewInt(int64(pub.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		x, y := make([]byte, 32), make([]byte, 32)
+		pub.X.FillBytes(x)
+		pub.Y.FillBytes(y)
+		return map[string]string{"kty": "EC", "crv": "P-256", "x": b64(x), "y": b64(y)}
+	case ed25519.PublicKey:
+		return map[string]string{"kty": "OKP", "crv": "Ed25519", "x": b64(pub)}
+	}
+	return nil
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint: the SHA-256 of the
+// required members in lexicographic order, which json.Marshal gives for a
+// map
+func thumbprint(jwk map[string]string) string {
+	data, _ := json.Marshal(jwk)
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,398 @@
+package slo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud-ai-api/atrest"
+)
+
+// defaultWindowDays is the rolling window of objectives that set none
+const defaultWindowDays = 30
+
+// retainDays is the minimum history kept, so the previous calendar month
+// can still be reported
+const retainDays = 62
+
+// burnLookbacks are the periods burn rates are reported over, besides the
+// whole window
+var burnLookbacks = []struct {
+	name   string
+	period time.Duration
+}{{"1h", time.Hour}, {"6h", 6 * time.Hour}, {"24h", 24 * time.Hour}}
+
+// Objective sets availability and latency targets for the routes it
+// covers. Route is "METHOD /pattern", "/pattern" for any method, or "*" for
+// every route. Availability is the share of requests that must not fail
+// with a server error; LatencyTarget is the share of the rest that must
+// finish within LatencyMs. Either target may be omitted.
+type Objective struct {
+	Name          string  `json:"name"`
+	Route         string  `json:"route"`
+	Availability  float64 `json:"availability,omitempty"`
+	LatencyMs     float64 `json:"latency_ms,omitempty"`
+	LatencyTarget float64 `json:"latency_target,omitempty"`
+	WindowDays    int     `json:"window_days,omitempty"`
+}
+
+// covers reports whether the objective applies to a request
+func (o *Objective) covers(method, route string) bool {
+	if o.Route == "*" {
+		return true
+	}
+	if m, path, ok := strings.Cut(o.Route, " "); ok {
+		return strings.EqualFold(m, method) && path == route
+	}
+	return o.Route == route
+}
+
+// counts totals requests in one hour
+type counts struct {
+	Total  int64 `json:"total"`
+	Failed int64 `json:"failed"`
+	Slow   int64 `json:"slow"`
+}
+
+func (c *counts) add(o counts) {
+	c.Total += o.Total
+	c.Failed += o.Failed
+	c.Slow += o.Slow
+}
+
+// Tracker counts requests against each objective by the hour
+type Tracker struct {
+	objectives []Objective
+	retain     int64
+
+	mu    sync.Mutex
+	hours []map[int64]*counts
+}
+
+// Load reads objectives from a JSON file of the form {"objectives": [...]}
+func Load(path string) ([]Objective, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SLO file: %w", err)
+	}
+	var f struct {
+		Objectives []Objective `json:"objectives"`
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse SLO file: %w", err)
+	}
+	return f.Objectives, nil
+}
+
+// NewTracker checks the objectives and creates a tracker for them
+func NewTracker(objectives []Objective) (*Tracker, error) {
+	t := &Tracker{retain: retainDays * 24}
+	seen := make(map[string]bool)
+	for i, o := range objectives {
+		if o.Name == "" {
+			return nil, fmt.Errorf("objective %d: name is required", i+1)
+		}
+		if seen[o.Name] {
+			return nil, fmt.Errorf("objective %q declared more than once", o.Name)
+		}
+		seen[o.Name] = true
+		if o.Route == "" {
+			return nil, fmt.Errorf("objective %q: route is required", o.Name)
+		}
+		if o.Availability == 0 && o.LatencyMs == 0 {
+			return nil, fmt.Errorf("objective %q: availability or latency_ms is required", o.Name)
+		}
+		if o.Availability < 0 || o.Availability >= 1 {
+			return nil, fmt.Errorf("objective %q: availability must be between 0 and 1, e.g. 0.999", o.Name)
+		}
+		if o.LatencyMs < 0 {
+			return nil, fmt.Errorf("objective %q: latency_ms must not be negative", o.Name)
+		}
+		if o.LatencyMs > 0 && (o.LatencyTarget <= 0 || o.LatencyTarget >= 1) {
+			return nil, fmt.Errorf("objective %q: latency_target must be between 0 and 1 when latency_ms is set", o.Name)
+		}
+		if o.WindowDays == 0 {
+			o.WindowDays = defaultWindowDays
+		}
+		if o.WindowDays < 1 || o.WindowDays > 90 {
+			return nil, fmt.Errorf("objective %q: window_days must be between 1 and 90", o.Name)
+		}
+		if h := int64(o.WindowDays) * 24; h > t.retain {
+			t.retain = h
+		}
+		t.objectives = append(t.objectives, o)
+		t.hours = append(t.hours, make(map[int64]*counts))
+	}
+	return t, nil
+}
+
+// Observe counts one request against every objective covering its route
+func (t *Tracker) Observe(method, route string, status int, latency time.Duration) {
+	hour := time.Now().Unix() / 3600
+	failed := status >= 500
+	ms := float64(latency.Microseconds()) / 1000
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range t.objectives {
+		o := &t.objectives[i]
+		if !o.covers(method, route) {
+			continue
+		}
+		c := t.hours[i][hour]
+		if c == nil {
+			c = &counts{}
+			t.hours[i][hour] = c
+			t.prune(i, hour)
+		}
+		c.Total++
+		if failed {
+			c.Failed++
+		} else if o.LatencyMs > 0 && ms > o.LatencyMs {
+			c.Slow++
+		}
+	}
+}
+
+// prune drops an objective's hours older than the retention. The caller
+// must hold t.mu.
+func (t *Tracker) prune(i int, now int64) {
+	for h := range t.hours[i] {
+		if h <= now-t.retain {
+			delete(t.hours[i], h)
+		}
+	}
+}
+
+// sum totals an objective's hours starting in [from, to). The caller must
+// hold t.mu.
+func (t *Tracker) sum(i int, from, to time.Time) counts {
+	first, last := from.Unix()/3600, to.Unix()/3600-1
+	var total counts
+	for h, c := range t.hours[i] {
+		if h >= first && h <= last {
+			total.add(*c)
+		}
+	}
+	return total
+}
+
+// Target is compliance with one target over a period
+type Target struct {
+	Target float64 `json:"target"`
+	// Actual is the share of good requests; 1 when there were none
+	Actual float64 `json:"actual"`
+	Good   int64   `json:"good"`
+	Bad    int64   `json:"bad"`
+	Met    bool    `json:"met"`
+}
+
+// Budget is a target's error budget over the rolling window and how fast
+// it is being spent. A burn rate of 1 spends exactly the budget over the
+// window; above 1 exhausts it early.
+type Budget struct {
+	Target
+	BudgetAllowed   float64            `json:"budget_allowed"`
+	BudgetRemaining float64            `json:"budget_remaining_pct"`
+	BurnRate        map[string]float64 `json:"burn_rate"`
+}
+
+// ObjectiveReport is one objective's compliance over its rolling window
+// and over a calendar month
+type ObjectiveReport struct {
+	Name         string  `json:"name"`
+	Route        string  `json:"route"`
+	Window       string  `json:"window"`
+	Requests     int64   `json:"requests"`
+	Availability *Budget `json:"availability,omitempty"`
+	Latency      *Budget `json:"latency,omitempty"`
+	LatencyMs    float64 `json:"latency_threshold_ms,omitempty"`
+	Month        *Month  `json:"month"`
+}
+
+// Month is an objective's compliance over a calendar month (UTC), up to
+// now for the current month
+type Month struct {
+	Month        string  `json:"month"`
+	Complete     bool    `json:"complete"`
+	Requests     int64   `json:"requests"`
+	Availability *Target `json:"availability,omitempty"`
+	Latency      *Target `json:"latency,omitempty"`
+	Met          bool    `json:"met"`
+}
+
+// Report computes every objective's compliance as of now. month selects
+// the calendar month reported (UTC, YYYY-MM), defaulting to the current one.
+func (t *Tracker) Report(now time.Time, month time.Time) []ObjectiveReport {
+	monthStart := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	monthEnd := monthStart.AddDate(0, 1, 0)
+	// Windows end with the current hour
+	end := now.Truncate(time.Hour).Add(time.Hour)
+	complete := !now.Before(monthEnd)
+	if !complete {
+		monthEnd = end
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	reports := make([]ObjectiveReport, 0, len(t.objectives))
+	for i, o := range t.objectives {
+		window := time.Duration(o.WindowDays) * 24 * time.Hour
+		total := t.sum(i, end.Add(-window), end)
+		r := ObjectiveReport{
+			Name:      o.Name,
+			Route:     o.Route,
+			Window:    fmt.Sprintf("%dd", o.WindowDays),
+			Requests:  total.Total,
+			LatencyMs: o.LatencyMs,
+		}
+
+		m := t.sum(i, monthStart, monthEnd)
+		r.Month = &Month{Month: monthStart.Format("2006-01"), Complete: complete, Requests: m.Total, Met: true}
+
+		if o.Availability > 0 {
+			bad := func(c counts) int64 { return c.Failed }
+			r.Availability = t.budget(i, o.Availability, total.Total, bad(total), end, window, func(c counts) (int64, int64) { return c.Total, bad(c) })
+			a := target(o.Availability, m.Total, bad(m))
+			r.Month.Availability = &a
+			r.Month.Met = r.Month.Met && a.Met
+		}
+		if o.LatencyMs > 0 {
+			eligible := func(c counts) int64 { return c.Total - c.Failed }
+			r.Latency = t.budget(i, o.LatencyTarget, eligible(total), total.Slow, end, window, func(c counts) (int64, int64) { return eligible(c), c.Slow })
+			l := target(o.LatencyTarget, eligible(m), m.Slow)
+			r.Month.Latency = &l
+			r.Month.Met = r.Month.Met && l.Met
+		}
+		reports = append(reports, r)
+	}
+	return reports
+}
+
+// budget computes a target's error budget over the window and its burn
+// rates over periods ending at end. split returns the requests judged and
+// how many were bad. The caller must hold t.mu.
+func (t *Tracker) budget(i int, goal float64, n, bad int64, end time.Time, window time.Duration, split func(counts) (int64, int64)) *Budget {
+	b := &Budget{Target: target(goal, n, bad), BurnRate: make(map[string]float64)}
+	allowed := (1 - goal) * float64(n)
+	b.BudgetAllowed = round(allowed)
+	b.BudgetRemaining = 100
+	if allowed > 0 {
+		b.BudgetRemaining = round(100 * (allowed - float64(bad)) / allowed)
+	} else if bad > 0 {
+		b.BudgetRemaining = 0
+	}
+
+	burn := func(period time.Duration) float64 {
+		total, bad := split(t.sum(i, end.Add(-period), end))
+		if total == 0 {
+			return 0
+		}
+		return round(float64(bad) / float64(total) / (1 - goal))
+	}
+	for _, lb := range burnLookbacks {
+		b.BurnRate[lb.name] = burn(lb.period)
+	}
+	b.BurnRate["window"] = burn(window)
+	return b
+}
+
+func target(goal float64, n, bad int64) Target {
+	t := Target{Target: goal, Actual: 1, Good: n - bad, Bad: bad}
+	if n > 0 {
+		t.Actual = round(float64(n-bad) / float64(n))
+	}
+	t.Met = n == 0 || float64(n-bad)/float64(n) >= goal
+	return t
+}
+
+// Objectives returns the tracked objectives
+func (t *Tracker) Objectives() []Objective {
+	return append([]Objective(nil), t.objectives...)
+}
+
+// state is the persisted form of the counts, keyed by objective name and
+// hour (Unix time / 3600)
+type state map[string]map[int64]counts
+
+// Save writes the counts to file atomically, encrypted with keys
+func (t *Tracker) Save(file string, keys *atrest.Keyring) error {
+	t.mu.Lock()
+	st := make(state, len(t.objectives))
+	for i, o := range t.objectives {
+		hours := make(map[int64]counts, len(t.hours[i]))
+		for h, c := range t.hours[i] {
+			hours[h] = *c
+		}
+		st[o.Name] = hours
+	}
+	t.mu.Unlock()
+
+	data, err := json.Marshal(st)
+	if err == nil {
+		data, err = keys.Seal(data)
+	}
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".slo-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to save SLO state: %w", err)
+	}
+	_, werr := tmp.Write(data)
+	cerr := tmp.Close()
+	if werr == nil {
+		werr = cerr
+	}
+	if werr == nil {
+		werr = os.Rename(tmp.Name(), file)
+	}
+	if werr != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save SLO state: %w", werr)
+	}
+	return nil
+}
+
+// Restore loads counts saved by Save, which need not exist yet. Counts for
+// objectives no longer configured are dropped.
+func (t *Tracker) Restore(file string, keys *atrest.Keyring) error {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err == nil {
+		data, err = keys.Open(data)
+	}
+	if err != nil {
+		return err
+	}
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return fmt.Errorf("invalid SLO state file: %w", err)
+	}
+
+	now := time.Now().Unix() / 3600
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, o := range t.objectives {
+		for h, c := range st[o.Name] {
+			if h > now-t.retain {
+				c := c
+				t.hours[i][h] = &c
+			}
+		}
+	}
+	return nil
+}
+
+func round(v float64) float64 {
+	return math.Round(v*1e4) / 1e4
+}
@@ -0,0 +1,170 @@
+package stats
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// bucketCount is how many buckets a window is divided into; statistics
+// expire one bucket at a time as the window slides
+const bucketCount = 30
+
+// maxSamples caps the latency samples kept per bucket and key. Beyond it,
+// reservoir sampling keeps a uniform sample of the bucket's requests.
+const maxSamples = 512
+
+// Summary describes the requests for one key within the window
+type Summary struct {
+	Count     int64   `json:"count"`
+	Errors    int64   `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+	P50Ms     float64 `json:"p50_ms"`
+	P95Ms     float64 `json:"p95_ms"`
+	P99Ms     float64 `json:"p99_ms"`
+	MaxMs     float64 `json:"max_ms"`
+}
+
+// Recorder keeps request counts, errors and latencies per key (such as a
+// route or model) over a sliding time window
+type Recorder struct {
+	mu      sync.Mutex
+	window  time.Duration
+	width   time.Duration
+	buckets [bucketCount]bucket
+}
+
+type bucket struct {
+	start time.Time
+	keys  map[string]*series
+}
+
+type series struct {
+	count   int64
+	errors  int64
+	max     float64
+	samples []float64
+}
+
+// NewRecorder creates a recorder covering the given window
+func NewRecorder(window time.Duration) *Recorder {
+	if window < bucketCount*time.Second {
+		window = bucketCount * time.Second
+	}
+	return &Recorder{window: window, width: window / bucketCount}
+}
+
+// Window returns the duration covered by the recorder
+func (r *Recorder) Window() time.Duration {
+	return r.window
+}
+
+// Observe records one request for key
+func (r *Recorder) Observe(key string, latency time.Duration, failed bool) {
+	ms := float64(latency.Microseconds()) / 1000
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b := r.bucket(now)
+	s, ok := b.keys[key]
+	if !ok {
+		s = &series{}
+		b.keys[key] = s
+	}
+	s.count++
+	if failed {
+		s.errors++
+	}
+	if ms > s.max {
+		s.max = ms
+	}
+	if len(s.samples) < maxSamples {
+		s.samples = append(s.samples, ms)
+	} else if i := rand.Int63n(s.count); i < maxSamples {
+		s.samples[i] = ms
+	}
+}
+
+// bucket returns the current bucket, resetting it if it has expired
+func (r *Recorder) bucket(now time.Time) *bucket {
+	start := now.Truncate(r.width)
+	b := &r.buckets[int(start.UnixNano()/int64(r.width))%bucketCount]
+	if !b.start.Equal(start) {
+		b.start = start
+		b.keys = make(map[string]*series)
+	}
+	return b
+}
+
+// Snapshot summarizes every key observed within the window
+func (r *Recorder) Snapshot() map[string]Summary {
+	return r.SnapshotWindow(r.window)
+}
+
+// SnapshotWindow summarizes every key observed within the most recent d,
+// which is rounded up to whole buckets and capped at the window
+func (r *Recorder) SnapshotWindow(d time.Duration) map[string]Summary {
+	if d > r.window {
+		d = r.window
+	}
+	if rem := d % r.width; rem != 0 {
+		d += r.width - rem
+	}
+	cutoff := time.Now().Add(-d)
+
+	r.mu.Lock()
+	merged := make(map[string]*series)
+	for i := range r.buckets {
+		b := &r.buckets[i]
+		if !b.start.After(cutoff) {
+			continue
+		}
+		for key, s := range b.keys {
+			m, ok := merged[key]
+			if !ok {
+				m = &series{}
+				merged[key] = m
+			}
+			m.count += s.count
+			m.errors += s.errors
+			if s.max > m.max {
+				m.max = s.max
+			}
+			m.samples = append(m.samples, s.samples...)
+		}
+	}
+	r.mu.Unlock()
+
+	out := make(map[string]Summary, len(merged))
+	for key, s := range merged {
+		sort.Float64s(s.samples)
+		out[key] = Summary{
+			Count:     s.count,
+			Errors:    s.errors,
+			ErrorRate: float64(s.errors) / float64(s.count),
+			P50Ms:     Percentile(s.samples, 0.50),
+			P95Ms:     Percentile(s.samples, 0.95),
+			P99Ms:     Percentile(s.samples, 0.99),
+			MaxMs:     s.max,
+		}
+	}
+	return out
+}
+
+// Percentile returns the nearest-rank percentile of sorted samples
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(p*float64(len(sorted))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
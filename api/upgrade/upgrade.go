@@ -0,0 +1,230 @@
+package upgrade
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Environment variables through which a parent process hands its
+// listeners to the process replacing it. They are set by Upgrade, not by
+// operators.
+const (
+	envListeners = "GATEWAY_UPGRADE_LISTENERS"
+	envReady     = "GATEWAY_UPGRADE_READY"
+)
+
+// firstFD is the descriptor number of the first inherited file; 0-2 are
+// stdin, stdout and stderr
+const firstFD = 3
+
+// ErrUpgrading is returned when an upgrade is already in progress
+var ErrUpgrading = errors.New("upgrade already in progress")
+
+// Upgrader replaces the running process with a new copy of its binary
+// without dropping connections, in the manner of tableflip: the new
+// process inherits the listening sockets, and the old one stops accepting
+// and drains once the new one reports it is ready
+type Upgrader struct {
+	mu        sync.Mutex
+	inherited map[string]*os.File
+	files     map[string]*os.File
+	keys      []string
+	ready     *os.File
+	child     bool
+	upgrading bool
+	exit      chan struct{}
+}
+
+// New creates an upgrader, taking over any listeners handed down by a
+// parent process
+func New() (*Upgrader, error) {
+	u := &Upgrader{
+		inherited: make(map[string]*os.File),
+		files:     make(map[string]*os.File),
+		exit:      make(chan struct{}),
+	}
+
+	if keys := os.Getenv(envListeners); keys != "" {
+		for i, key := range strings.Split(keys, ",") {
+			u.inherited[key] = os.NewFile(uintptr(firstFD+i), key)
+		}
+	}
+	if fd := os.Getenv(envReady); fd != "" {
+		n, err := strconv.Atoi(fd)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %q", envReady, fd)
+		}
+		u.ready = os.NewFile(uintptr(n), "upgrade-ready")
+		u.child = true
+	}
+	os.Unsetenv(envListeners)
+	os.Unsetenv(envReady)
+	return u, nil
+}
+
+// Inherited reports whether this process was started by an upgrade
+func (u *Upgrader) Inherited() bool {
+	return u.child
+}
+
+// Listen returns a listener for network ("tcp" or "unix") and addr, taken
+// over from the parent process if it handed one down and otherwise newly
+// opened. A stale Unix socket left by a previous run is replaced.
+func (u *Upgrader) Listen(network, addr string) (net.Listener, error) {
+	key := network + ":" + addr
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var l net.Listener
+	var err error
+	if f, ok := u.inherited[key]; ok {
+		delete(u.inherited, key)
+		l, err = net.FileListener(f)
+		f.Close()
+	} else {
+		if network == "unix" {
+			if info, statErr := os.Lstat(addr); statErr == nil && info.Mode()&os.ModeSocket != 0 {
+				os.Remove(addr)
+			}
+		}
+		l, err = net.Listen(network, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep a duplicate of the socket to hand to the next process. Unix
+	// sockets must not be unlinked when this process closes them, as the
+	// next process goes on serving them.
+	var f *os.File
+	switch l := l.(type) {
+	case *net.TCPListener:
+		f, err = l.File()
+	case *net.UnixListener:
+		l.SetUnlinkOnClose(false)
+		f, err = l.File()
+	default:
+		err = fmt.Errorf("unsupported listener type %T", l)
+	}
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+	u.files[key] = f
+	u.keys = append(u.keys, key)
+	return l, nil
+}
+
+// Ready tells the parent process, if any, that this process is serving
+// and the parent can stop. Listeners handed down but not used are closed.
+func (u *Upgrader) Ready() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for key, f := range u.inherited {
+		f.Close()
+		delete(u.inherited, key)
+	}
+	if u.ready == nil {
+		return nil
+	}
+	_, err := u.ready.Write([]byte{1})
+	u.ready.Close()
+	u.ready = nil
+	return err
+}
+
+// Exit is closed once a new process has taken over the listeners; the
+// caller should then stop accepting, drain and exit
+func (u *Upgrader) Exit() <-chan struct{} {
+	return u.exit
+}
+
+// Upgrade starts a new copy of the binary with the same arguments and
+// environment, hands it the listeners and waits up to timeout for it to
+// report it is ready. On success Exit is closed; on failure the new
+// process is killed and this one carries on serving.
+func (u *Upgrader) Upgrade(timeout time.Duration) error {
+	u.mu.Lock()
+	if u.upgrading {
+		u.mu.Unlock()
+		return ErrUpgrading
+	}
+	u.upgrading = true
+	keys := append([]string(nil), u.keys...)
+	files := make([]*os.File, 0, len(keys)+1)
+	for _, key := range keys {
+		files = append(files, u.files[key])
+	}
+	u.mu.Unlock()
+
+	success := false
+	defer func() {
+		if !success {
+			u.mu.Lock()
+			u.upgrading = false
+			u.mu.Unlock()
+		}
+	}()
+
+	binary, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate binary: %w", err)
+	}
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyR.Close()
+
+	cmd := exec.Command(binary, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = append(files, readyW)
+	cmd.Env = append(os.Environ(),
+		envListeners+"="+strings.Join(keys, ","),
+		envReady+"="+strconv.Itoa(firstFD+len(files)),
+	)
+	if err := cmd.Start(); err != nil {
+		readyW.Close()
+		return fmt.Errorf("failed to start new process: %w", err)
+	}
+	readyW.Close()
+
+	// The new process writes one byte when ready; EOF means it exited
+	// (or closed the pipe) without becoming ready
+	result := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+		if _, err := readyR.Read(buf); err != nil {
+			result <- fmt.Errorf("new process exited before becoming ready")
+			return
+		}
+		result <- nil
+	}()
+
+	select {
+	case err = <-result:
+	case <-time.After(timeout):
+		err = fmt.Errorf("new process not ready after %s", timeout)
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		go cmd.Wait()
+		return err
+	}
+
+	// The new process runs on; it is reparented once this one exits
+	go cmd.Wait()
+	success = true
+	close(u.exit)
+	return nil
+}
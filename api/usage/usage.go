@@ -0,0 +1,334 @@
+package usage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud-ai-api/atrest"
+)
+
+// retainMonths is how many calendar months of usage are kept, including
+// the current one
+const retainMonths = 13
+
+// Caller identifies who a charge is billed to. Either part may be empty.
+type Caller struct {
+	Tenant string `json:"tenant"`
+	APIKey string `json:"api_key"`
+}
+
+// Kind is the kind of prediction a charge is for
+type Kind int
+
+const (
+	// Call is a live ML service call made for a request
+	Call Kind = iota
+	// CacheHit is a request answered from the response cache
+	CacheHit
+	// BatchRow is an ML service call for a batch job row
+	BatchRow
+)
+
+// Charges are a caller's predictions and their cost for one model. Calls
+// counts live ML service calls only; cached hits and batch rows are
+// counted separately.
+type Charges struct {
+	Calls      int64   `json:"calls"`
+	CachedHits int64   `json:"cached_hits"`
+	BatchRows  int64   `json:"batch_rows"`
+	Cost       float64 `json:"cost"`
+}
+
+func (ch *Charges) add(o Charges) {
+	ch.Calls += o.Calls
+	ch.CachedHits += o.CachedHits
+	ch.BatchRows += o.BatchRows
+	ch.Cost += o.Cost
+}
+
+// Summary is a caller's usage in one month, in total and per model
+type Summary struct {
+	Tenant     string             `json:"tenant,omitempty"`
+	APIKey     string             `json:"api_key,omitempty"`
+	Calls      int64              `json:"calls"`
+	CachedHits int64              `json:"cached_hits"`
+	BatchRows  int64              `json:"batch_rows"`
+	Cost       float64            `json:"cost"`
+	Models     map[string]Charges `json:"models"`
+}
+
+// Line is an invoice line: a tenant's predictions with one model in a month
+type Line struct {
+	Tenant string `json:"tenant"`
+	Model  string `json:"model"`
+	Charges
+}
+
+// account holds one caller's charges in one month
+type account struct {
+	Caller Caller              `json:"caller"`
+	Models map[string]*Charges `json:"models"`
+}
+
+// Ledger accumulates charges per caller, model and calendar month (UTC)
+type Ledger struct {
+	mu     sync.Mutex
+	months map[string]map[Caller]*account
+}
+
+// NewLedger creates an empty ledger
+func NewLedger() *Ledger {
+	return &Ledger{months: make(map[string]map[Caller]*account)}
+}
+
+// Charge bills one prediction of the given kind with model to a caller
+func (l *Ledger) Charge(c Caller, model string, kind Kind, cost float64) {
+	month := time.Now().UTC().Format("2006-01")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	accounts := l.months[month]
+	if accounts == nil {
+		accounts = make(map[Caller]*account)
+		l.months[month] = accounts
+		l.prune()
+	}
+	a := accounts[c]
+	if a == nil {
+		a = &account{Caller: c, Models: make(map[string]*Charges)}
+		accounts[c] = a
+	}
+	ch := a.Models[model]
+	if ch == nil {
+		ch = &Charges{}
+		a.Models[model] = ch
+	}
+	switch kind {
+	case CacheHit:
+		ch.CachedHits++
+	case BatchRow:
+		ch.BatchRows++
+	default:
+		ch.Calls++
+	}
+	ch.Cost += cost
+}
+
+// prune drops months beyond the retention. The caller must hold l.mu.
+func (l *Ledger) prune() {
+	if len(l.months) <= retainMonths {
+		return
+	}
+	months := make([]string, 0, len(l.months))
+	for m := range l.months {
+		months = append(months, m)
+	}
+	sort.Strings(months)
+	for _, m := range months[:len(months)-retainMonths] {
+		delete(l.months, m)
+	}
+}
+
+// Months lists the months with usage, most recent first
+func (l *Ledger) Months() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	months := make([]string, 0, len(l.months))
+	for m := range l.months {
+		months = append(months, m)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(months)))
+	return months
+}
+
+// For returns the usage of callers matching c in month (YYYY-MM). An empty
+// APIKey matches every key of the tenant, so tenants see their total.
+func (l *Ledger) For(c Caller, month string) Summary {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s := newSummary(c)
+	for caller, a := range l.months[month] {
+		if caller.Tenant == c.Tenant && (c.APIKey == "" || caller.APIKey == c.APIKey) {
+			s.add(a)
+		}
+	}
+	return s.rounded()
+}
+
+// Month returns every caller's usage in month, grouped by tenant alone if
+// byTenant is set, most expensive first
+func (l *Ledger) Month(month string, byTenant bool) []Summary {
+	l.mu.Lock()
+	groups := make(map[Caller]*Summary)
+	for caller, a := range l.months[month] {
+		key := caller
+		if byTenant {
+			key.APIKey = ""
+		}
+		s := groups[key]
+		if s == nil {
+			summary := newSummary(key)
+			s = &summary
+			groups[key] = s
+		}
+		s.add(a)
+	}
+	l.mu.Unlock()
+
+	out := make([]Summary, 0, len(groups))
+	for _, s := range groups {
+		out = append(out, s.rounded())
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Cost != out[j].Cost {
+			return out[i].Cost > out[j].Cost
+		}
+		if out[i].Tenant != out[j].Tenant {
+			return out[i].Tenant < out[j].Tenant
+		}
+		return out[i].APIKey < out[j].APIKey
+	})
+	return out
+}
+
+// Lines returns the invoice lines for month, one per tenant and model,
+// ordered by tenant then model. Keys without a tenant are billed under an
+// empty tenant.
+func (l *Ledger) Lines(month string) []Line {
+	l.mu.Lock()
+	type lineKey struct{ tenant, model string }
+	lines := make(map[lineKey]*Line)
+	for caller, a := range l.months[month] {
+		for model, ch := range a.Models {
+			k := lineKey{caller.Tenant, model}
+			line := lines[k]
+			if line == nil {
+				line = &Line{Tenant: caller.Tenant, Model: model}
+				lines[k] = line
+			}
+			line.add(*ch)
+		}
+	}
+	l.mu.Unlock()
+
+	out := make([]Line, 0, len(lines))
+	for _, line := range lines {
+		line.Cost = round(line.Cost)
+		out = append(out, *line)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Tenant != out[j].Tenant {
+			return out[i].Tenant < out[j].Tenant
+		}
+		return out[i].Model < out[j].Model
+	})
+	return out
+}
+
+func newSummary(c Caller) Summary {
+	return Summary{Tenant: c.Tenant, APIKey: c.APIKey, Models: make(map[string]Charges)}
+}
+
+func (s *Summary) add(a *account) {
+	for model, ch := range a.Models {
+		m := s.Models[model]
+		m.add(*ch)
+		s.Models[model] = m
+		s.Calls += ch.Calls
+		s.CachedHits += ch.CachedHits
+		s.BatchRows += ch.BatchRows
+		s.Cost += ch.Cost
+	}
+}
+
+// rounded rounds costs to a millionth of the currency unit for display
+func (s Summary) rounded() Summary {
+	s.Cost = round(s.Cost)
+	for model, ch := range s.Models {
+		ch.Cost = round(ch.Cost)
+		s.Models[model] = ch
+	}
+	return s
+}
+
+// Save writes the ledger to file atomically, encrypted with keys
+func (l *Ledger) Save(file string, keys *atrest.Keyring) error {
+	l.mu.Lock()
+	st := make(map[string][]*account, len(l.months))
+	for month, accounts := range l.months {
+		for _, a := range accounts {
+			st[month] = append(st[month], a)
+		}
+	}
+	data, err := json.Marshal(st)
+	l.mu.Unlock()
+	if err == nil {
+		data, err = keys.Seal(data)
+	}
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".usage-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to save usage: %w", err)
+	}
+	_, werr := tmp.Write(data)
+	cerr := tmp.Close()
+	if werr == nil {
+		werr = cerr
+	}
+	if werr == nil {
+		werr = os.Rename(tmp.Name(), file)
+	}
+	if werr != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save usage: %w", werr)
+	}
+	return nil
+}
+
+// Restore loads a ledger saved by Save, which need not exist yet
+func (l *Ledger) Restore(file string, keys *atrest.Keyring) error {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err == nil {
+		data, err = keys.Open(data)
+	}
+	if err != nil {
+		return err
+	}
+	var st map[string][]*account
+	if err := json.Unmarshal(data, &st); err != nil {
+		return fmt.Errorf("invalid usage file: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for month, accounts := range st {
+		l.months[month] = make(map[Caller]*account, len(accounts))
+		for _, a := range accounts {
+			if a.Models == nil {
+				a.Models = make(map[string]*Charges)
+			}
+			l.months[month][a.Caller] = a
+		}
+	}
+	l.prune()
+	return nil
+}
+
+func round(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}